@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit trail
+const (
+	AuditJobSubmitted = "job_submitted"
+	AuditJobApproved  = "job_approved"
+	AuditJobRejected  = "job_rejected"
+
+	AuditThermalAlarm        = "thermal_alarm"
+	AuditThermalAcknowledged = "thermal_acknowledged"
+)
+
+// auditLogLimit is how many events are kept; older ones are dropped
+const auditLogLimit = 5000
+
+// AuditEvent is an action someone took, kept so decisions can be traced
+// back to who made them
+type AuditEvent struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Role    string    `json:"role,omitempty"`
+	Action  string    `json:"action"`  // One of the Audit constants
+	Subject string    `json:"subject"` // What was acted on, e.g. the job name
+	Details string    `json:"details,omitempty"`
+}
+
+// Describe returns the event as one line of text
+func (e *AuditEvent) Describe() string {
+	var verb string
+	switch e.Action {
+	case AuditJobSubmitted:
+		verb = "submitted"
+	case AuditJobApproved:
+		verb = "approved"
+	case AuditJobRejected:
+		verb = "rejected"
+	case AuditThermalAlarm:
+		verb = "raised an alarm on"
+	case AuditThermalAcknowledged:
+		verb = "acknowledged the alarm on"
+	default:
+		verb = e.Action
+	}
+	text := fmt.Sprintf("%s %s %s", e.User, verb, e.Subject)
+	if e.Details != "" {
+		text += ": " + e.Details
+	}
+	return text
+}
+
+// AuditLog is the append-only record of approvals and other decisions
+type AuditLog struct {
+	mu     sync.Mutex
+	events []AuditEvent
+	path   string
+}
+
+// NewAuditLog creates an empty audit log stored at path; an empty path
+// keeps it in memory only
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// auditLogFile is where the touchscreen keeps its audit trail
+func auditLogFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "audit_log.json")
+}
+
+// Load reads the stored events. A missing file leaves the log empty.
+func (l *AuditLog) Load() error {
+	data, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var events []AuditEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return fmt.Errorf("invalid audit log: %v", err)
+	}
+
+	l.mu.Lock()
+	l.events = events
+	l.mu.Unlock()
+	return nil
+}
+
+// Record appends an event, stamped now if it has no time
+func (l *AuditLog) Record(event AuditEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	l.mu.Lock()
+	l.events = append(l.events, event)
+	if len(l.events) > auditLogLimit {
+		l.events = l.events[len(l.events)-auditLogLimit:]
+	}
+	l.mu.Unlock()
+	return l.save()
+}
+
+// Events returns the recorded events, newest first
+func (l *AuditLog) Events() []AuditEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make([]AuditEvent, len(l.events))
+	for i, event := range l.events {
+		events[len(l.events)-1-i] = event
+	}
+	return events
+}
+
+// save writes the log to disk
+func (l *AuditLog) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	jsonData, err := json.MarshalIndent(l.events, "", "  ")
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.path, jsonData, 0600)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,6 +26,9 @@ type AuthManager struct {
 	tokenFile    string
 	mu           sync.RWMutex
 	onAuthChange func(bool)
+
+	// rolelessApproval lets accounts without a role approve prints
+	rolelessApproval bool
 }
 
 // User represents the authenticated user
@@ -35,6 +39,34 @@ type User struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	IsActive  bool   `json:"is_active"`
+	Role      string `json:"role,omitempty"` // One of the Role constants
+}
+
+// User roles. Staff and admins start and approve prints; students and
+// guests submit them for approval.
+const (
+	RoleAdmin   = "admin"
+	RoleStaff   = "staff"
+	RoleStudent = "student"
+	RoleGuest   = "guest"
+)
+
+// DisplayName returns the name the user is shown and recorded under
+func (u *User) DisplayName() string {
+	if u.Username != "" {
+		return u.Username
+	}
+	return u.Email
+}
+
+// CanApprove reports whether the user may start prints and approve those
+// submitted by others. Accounts without a role may not.
+func (u *User) CanApprove() bool {
+	switch strings.ToLower(u.Role) {
+	case RoleAdmin, RoleStaff:
+		return true
+	}
+	return false
 }
 
 // LoginRequest represents login credentials
@@ -59,6 +91,36 @@ type TokenData struct {
 	User         User      `json:"user"`
 }
 
+// AuthSettings are the touchscreen's login options
+type AuthSettings struct {
+	// RolelessApproval gives accounts without a role staff access, for
+	// backends that do not assign roles
+	RolelessApproval bool `json:"roleless_approval"`
+}
+
+// authSettingsFile is where the touchscreen keeps the login options
+func authSettingsFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "auth_settings.json")
+}
+
+// LoadAuthSettings reads the settings at path; a missing file gives the
+// defaults
+func LoadAuthSettings(path string) (AuthSettings, error) {
+	var settings AuthSettings
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("invalid auth settings file: %v", err)
+	}
+	return settings, nil
+}
+
 // NewAuthManager creates a new authentication manager
 func NewAuthManager(baseURL string) *AuthManager {
 	configDir, _ := os.UserConfigDir()
@@ -73,6 +135,12 @@ func NewAuthManager(baseURL string) *AuthManager {
 		tokenFile:  tokenFile,
 	}
 	
+	settings, err := LoadAuthSettings(authSettingsFile())
+	if err != nil {
+		fmt.Printf("Failed to load auth settings: %v\n", err)
+	}
+	am.rolelessApproval = settings.RolelessApproval
+	
 	// Load existing token if available
 	am.loadToken()
 	
@@ -207,6 +275,47 @@ func (am *AuthManager) GetUser() *User {
 	return am.user
 }
 
+// GetRole returns the current user's role, taken from the token when the
+// user record has none
+func (am *AuthManager) GetRole() string {
+	am.mu.RLock()
+	user := am.user
+	am.mu.RUnlock()
+	if user != nil && user.Role != "" {
+		return strings.ToLower(user.Role)
+	}
+	if claims, err := am.ParseJWTClaims(); err == nil {
+		if role, ok := claims["role"].(string); ok {
+			return strings.ToLower(role)
+		}
+	}
+	return ""
+}
+
+// CanApprove reports whether the logged in user may start and approve
+// prints. Nobody may while logged out.
+func (am *AuthManager) CanApprove() bool {
+	if !am.IsAuthenticated() || am.GetUser() == nil {
+		return false
+	}
+	user := *am.GetUser()
+	user.Role = am.GetRole()
+	if user.Role == "" {
+		am.mu.RLock()
+		defer am.mu.RUnlock()
+		return am.rolelessApproval
+	}
+	return user.CanApprove()
+}
+
+// SetRolelessApproval sets whether accounts without a role may approve
+// prints
+func (am *AuthManager) SetRolelessApproval(allowed bool) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.rolelessApproval = allowed
+}
+
 // IsAuthenticated checks if user is authenticated
 func (am *AuthManager) IsAuthenticated() bool {
 	am.mu.RLock()
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// loggedIn is an auth manager holding a token with the given claims
+func loggedIn(t *testing.T, user *User, claims jwt.MapClaims) *AuthManager {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AuthManager{
+		currentToken: token,
+		expiresAt:    time.Now().Add(time.Hour),
+		user:         user,
+	}
+}
+
+func TestGetRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   string
+		claims jwt.MapClaims
+		want   string
+	}{
+		{"user record", "Staff", jwt.MapClaims{}, RoleStaff},
+		{"user record wins over the token", RoleStudent, jwt.MapClaims{"role": "admin"}, RoleStudent},
+		{"token when the record has none", "", jwt.MapClaims{"role": "ADMIN"}, RoleAdmin},
+		{"neither", "", jwt.MapClaims{"sub": "1"}, ""},
+		{"non-string claim", "", jwt.MapClaims{"role": 3}, ""},
+	}
+	for _, test := range tests {
+		am := loggedIn(t, &User{Username: "sam", Role: test.role}, test.claims)
+		if got := am.GetRole(); got != test.want {
+			t.Errorf("%s: GetRole = %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	if got := (&AuthManager{}).GetRole(); got != "" {
+		t.Errorf("logged out GetRole = %q", got)
+	}
+}
+
+func TestCanApprove(t *testing.T) {
+	tests := []struct {
+		role     string
+		roleless bool
+		want     bool
+	}{
+		{RoleAdmin, false, true},
+		{RoleStaff, false, true},
+		{RoleStudent, false, false},
+		{RoleGuest, false, false},
+		{"", false, false},
+		{"", true, true},
+		{RoleStudent, true, false},
+		{"technician", true, false},
+	}
+	for _, test := range tests {
+		am := loggedIn(t, &User{Username: "sam", Role: test.role}, jwt.MapClaims{})
+		am.SetRolelessApproval(test.roleless)
+		if got := am.CanApprove(); got != test.want {
+			t.Errorf("role %q, roleless approval %v: CanApprove = %v, want %v", test.role, test.roleless, got, test.want)
+		}
+	}
+
+	// A role from the token counts when the user record has none
+	if am := loggedIn(t, &User{Username: "sam"}, jwt.MapClaims{"role": "staff"}); !am.CanApprove() {
+		t.Error("staff role from the token was not honoured")
+	}
+
+	expired := loggedIn(t, &User{Username: "sam", Role: RoleAdmin}, jwt.MapClaims{})
+	expired.expiresAt = time.Now().Add(-time.Minute)
+	if expired.CanApprove() {
+		t.Error("an expired login may not approve")
+	}
+	if (&AuthManager{rolelessApproval: true}).CanApprove() {
+		t.Error("nobody may approve while logged out")
+	}
+	if (&User{}).CanApprove() {
+		t.Error("a user without a role may not approve")
+	}
+}
+
+func TestLoadAuthSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth_settings.json")
+	settings, err := LoadAuthSettings(path)
+	if err != nil || settings.RolelessApproval {
+		t.Errorf("missing file = %+v, %v; want roleless approval off", settings, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"roleless_approval": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if settings, err := LoadAuthSettings(path); err != nil || !settings.RolelessApproval {
+		t.Errorf("LoadAuthSettings = %+v, %v; want roleless approval on", settings, err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAuthSettings(path); err == nil {
+		t.Error("expected an error for a corrupt settings file")
+	}
+}
+
+func TestReviewQueueItemNeedsApprover(t *testing.T) {
+	queue := NewPrintQueue(filepath.Join(t.TempDir(), "print_queue.json"))
+	submit := func(name string) QueueItem {
+		item, err := queue.Submit(GCodeFile{FileName: name}, 1, 0, nil, "student1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return item
+	}
+	state := func(id string) QueueItemState {
+		for _, item := range queue.Items() {
+			if item.ID == id {
+				return item.State
+			}
+		}
+		return ""
+	}
+
+	student := loggedIn(t, &User{Username: "student1", Role: RoleStudent}, jwt.MapClaims{})
+	roleless := loggedIn(t, &User{Username: "nobody"}, jwt.MapClaims{})
+	staff := loggedIn(t, &User{Username: "teacher"}, jwt.MapClaims{"role": "staff"})
+
+	first, second := submit("bracket.gcode"), submit("clip.gcode")
+	for _, auth := range []*AuthManager{student, roleless} {
+		if err := ApproveQueueItem(queue, auth, first.ID, auth.GetUser().DisplayName()); err == nil {
+			t.Errorf("%s approved a job", auth.GetUser().DisplayName())
+		}
+		if err := RejectQueueItem(queue, auth, second.ID, auth.GetUser().DisplayName(), "no"); err == nil {
+			t.Errorf("%s rejected a job", auth.GetUser().DisplayName())
+		}
+	}
+	if state(first.ID) != QueueItemPending || state(second.ID) != QueueItemPending {
+		t.Fatalf("refused reviews changed the queue: %s, %s", state(first.ID), state(second.ID))
+	}
+
+	if err := ApproveQueueItem(queue, staff, first.ID, "teacher"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RejectQueueItem(queue, staff, second.ID, "teacher", "needs supports"); err != nil {
+		t.Fatal(err)
+	}
+	if state(first.ID) != QueueItemQueued || state(second.ID) != QueueItemRejected {
+		t.Errorf("after review: %s, %s", state(first.ID), state(second.ID))
+	}
+
+	// Only pending items are reviewed; no logins means the touchscreen decides
+	if err := RejectQueueItem(queue, nil, first.ID, "touchscreen", "too late"); err == nil {
+		t.Error("an approved job was rejected")
+	}
+	third := submit("hook.gcode")
+	if err := ApproveQueueItem(queue, nil, third.ID, "touchscreen"); err != nil || state(third.ID) != QueueItemQueued {
+		t.Errorf("approve without logins = %v, state %s", err, state(third.ID))
+	}
+}
+
+// startRecorder is a printStarter that records the files started
+type startRecorder struct {
+	started []string
+}
+
+func (r *startRecorder) StartPrint(filename string) error {
+	r.started = append(r.started, filename)
+	return nil
+}
+
+func TestStartOrSubmit(t *testing.T) {
+	queue := NewPrintQueue(filepath.Join(t.TempDir(), "print_queue.json"))
+	file := GCodeFile{Name: "bracket.gcode", FileName: "bracket.gcode"}
+	student := loggedIn(t, &User{Username: "student1", Role: RoleStudent}, jwt.MapClaims{})
+	roleless := loggedIn(t, &User{Username: "nobody"}, jwt.MapClaims{})
+	staff := loggedIn(t, &User{Username: "teacher", Role: RoleStaff}, jwt.MapClaims{})
+	loggedOut := &AuthManager{user: &User{Username: "student1", Role: RoleStudent}}
+
+	// Students and accounts without a role are submitted, not started
+	backend := &startRecorder{}
+	for _, auth := range []*AuthManager{student, roleless} {
+		started, err := StartOrSubmit(backend, queue, auth, file, 1)
+		if err != nil || started {
+			t.Errorf("%s: started %v, %v; want a submission", auth.GetUser().DisplayName(), started, err)
+		}
+	}
+	if len(backend.started) != 0 {
+		t.Fatalf("unprivileged users started %q", backend.started)
+	}
+	items := queue.Items()
+	if len(items) != 2 || items[0].State != QueueItemPending || items[0].SubmittedBy != "student1" || items[1].SubmittedBy != "nobody" {
+		t.Errorf("queue after submissions = %+v", items)
+	}
+
+	// Without a queue, or logged out, they are refused
+	for _, test := range []struct {
+		auth  *AuthManager
+		queue *PrintQueue
+	}{{student, nil}, {loggedOut, queue}, {loggedOut, nil}} {
+		if started, err := StartOrSubmit(backend, test.queue, test.auth, file, 1); err == nil || started {
+			t.Errorf("started %v, %v; want a refusal", started, err)
+		}
+	}
+	if len(backend.started) != 0 || len(queue.Items()) != 2 {
+		t.Errorf("refused starts started %q and queued %d items", backend.started, len(queue.Items()))
+	}
+
+	// Staff, and touchscreens without logins, start the print
+	for _, auth := range []*AuthManager{staff, nil} {
+		if started, err := StartOrSubmit(backend, queue, auth, file, 1); err != nil || !started {
+			t.Errorf("started %v, %v; want a start", started, err)
+		}
+	}
+	if len(backend.started) != 2 || len(queue.Items()) != 2 {
+		t.Errorf("staff started %q and queued %d items", backend.started, len(queue.Items()))
+	}
+}
+
+func TestSchedulePrint(t *testing.T) {
+	scheduler := NewPrintScheduler(filepath.Join(t.TempDir(), "print_schedule.json"), SystemClock{})
+	scheduled := ScheduledPrint{Name: "bracket.gcode", FileName: "bracket.gcode", StartAt: time.Now().Add(time.Hour)}
+
+	for _, auth := range []*AuthManager{
+		loggedIn(t, &User{Username: "student1", Role: RoleGuest}, jwt.MapClaims{}),
+		loggedIn(t, &User{Username: "nobody"}, jwt.MapClaims{}),
+	} {
+		if _, err := SchedulePrint(scheduler, auth, scheduled); err == nil {
+			t.Errorf("%s scheduled a print", auth.GetUser().DisplayName())
+		}
+	}
+	if len(scheduler.Schedules()) != 0 {
+		t.Fatalf("refused schedules were added: %+v", scheduler.Schedules())
+	}
+
+	staff := loggedIn(t, &User{Username: "teacher"}, jwt.MapClaims{"role": "admin"})
+	if _, err := SchedulePrint(scheduler, staff, scheduled); err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduler.Schedules()) != 1 {
+		t.Errorf("%d schedules, want 1", len(scheduler.Schedules()))
+	}
+}
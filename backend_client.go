@@ -3,9 +3,12 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 	"log"
@@ -25,27 +28,41 @@ type BackendClient struct {
 
 // PrinterStatus represents the real-time status from the printer
 type PrinterStatus struct {
-	Status        string  `json:"status"`
-	Temperature   float64 `json:"temperature"`
-	BedTemp       float64 `json:"bed_temperature"`
-	Progress      float64 `json:"progress"`
-	CurrentLayer  int     `json:"current_layer"`
-	TotalLayers   int     `json:"total_layers"`
-	PositionX     float64 `json:"position_x"`
-	PositionY     float64 `json:"position_y"`
-	PositionZ     float64 `json:"position_z"`
-	EstimatedTime int     `json:"estimated_time"`
-	IsConnected   bool    `json:"is_connected"`
-}
-
-// PrintJob represents a print job from the backend
-type PrintJob struct {
+	Status          string   `json:"status"`
+	Temperature     float64  `json:"temperature"`
+	BedTemp         float64  `json:"bed_temperature"`
+	TargetTemp      float64  `json:"target_temperature"` // Hotend target, from backends without a heater list
+	BedTarget       float64  `json:"bed_target"`
+	Heaters         []HeaterReading `json:"heaters,omitempty"` // Every tool, bed, chamber and sensor, if reported
+	Progress        float64  `json:"progress"`
+	CurrentLayer    int      `json:"current_layer"`
+	TotalLayers     int      `json:"total_layers"`
+	PositionX       float64  `json:"position_x"`
+	PositionY       float64  `json:"position_y"`
+	PositionZ       float64  `json:"position_z"`
+	EstimatedTime   int      `json:"estimated_time"`
+	IsConnected     bool     `json:"is_connected"`
+	FilePosition    int64    `json:"file_position"`    // Byte offset in the printing file
+	LineNumber      int      `json:"line_number"`      // Source line being executed
+	ExcludedObjects []string `json:"excluded_objects"` // Objects cancelled during the print
+	FilamentUsed    float64  `json:"filament_used"`    // Filament extruded by the current print in mm, if the backend reports it
+	FileName        string   `json:"file_name"`        // File being printed
+	PositionE       float64  `json:"position_e"`       // Extruder position
+	FanSpeed        float64  `json:"fan_speed"`        // Part cooling fan 0-255
+}
+
+// BackendPrintJob represents a print job from the backend's /api/print-jobs
+// list. The print jobs screen uses PrintJob from the job API instead.
+type BackendPrintJob struct {
 	ID          int    `json:"id"`
 	Filename    string `json:"filename"`
 	Status      string `json:"status"`
 	Progress    float64 `json:"progress"`
 	CreatedAt   string `json:"created_at"`
 	CompletedAt string `json:"completed_at"`
+	Folder      string `json:"folder,omitempty"` // Set by backends with folder support
+	Size        int64  `json:"size,omitempty"`
+	SHA256      string `json:"sha256,omitempty"` // Content hash, if the backend keeps one
 }
 
 // NewBackendClient creates a new client for backend communication
@@ -182,6 +199,30 @@ func (c *BackendClient) GetPrinterStatus() (*PrinterStatus, error) {
 	return &status, nil
 }
 
+// GetPrinterProfile retrieves the connected printer's profile (model, build volume)
+func (c *BackendClient) GetPrinterProfile() (*PrinterProfile, error) {
+	resp, err := c.makeRequest("GET", "/api/printer/profile", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("authentication required")
+	}
+	
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get printer profile: %s", resp.Status)
+	}
+	
+	var profile PrinterProfile
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return nil, err
+	}
+	
+	return &profile, nil
+}
+
 // StartPrint starts a print job
 func (c *BackendClient) StartPrint(filename string) error {
 	command := map[string]interface{}{
@@ -295,6 +336,35 @@ func (c *BackendClient) CancelPrintJob(filename string) error {
 	return nil
 }
 
+// CancelObject stops printing one object of the current print while the rest continue
+func (c *BackendClient) CancelObject(objectID int, name string) error {
+	command := map[string]interface{}{
+		"object_id": objectID,
+		"name":      name,
+	}
+	
+	jsonData, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+	
+	resp, err := c.makeRequest("POST", "/api/printer/print/cancel_object", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authentication required")
+	}
+	
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to cancel object: %s", resp.Status)
+	}
+	
+	return nil
+}
+
 // EmergencyStop performs an emergency stop
 func (c *BackendClient) EmergencyStop() error {
 	resp, err := c.makeRequest("POST", "/api/printer/emergency-stop", nil)
@@ -372,7 +442,9 @@ func (c *BackendClient) MoveAxis(axis string, distance float64) error {
 	return nil
 }
 
-// SetTemperature sets the target temperature
+// SetTemperature sets the target temperature of a heater, named as in
+// PrinterStatus.Heaters (e.g. tool1, bed, chamber) or HeaterHotend for the
+// active tool
 func (c *BackendClient) SetTemperature(heater string, temperature float64) error {
 	command := map[string]interface{}{
 		"heater":      heater,
@@ -402,7 +474,7 @@ func (c *BackendClient) SetTemperature(heater string, temperature float64) error
 }
 
 // GetPrintJobs retrieves list of print jobs
-func (c *BackendClient) GetPrintJobs() ([]PrintJob, error) {
+func (c *BackendClient) GetPrintJobs() ([]BackendPrintJob, error) {
 	resp, err := c.makeRequest("GET", "/api/print-jobs", nil)
 	if err != nil {
 		return nil, err
@@ -417,7 +489,7 @@ func (c *BackendClient) GetPrintJobs() ([]PrintJob, error) {
 		return nil, fmt.Errorf("failed to get print jobs: %s", resp.Status)
 	}
 	
-	var jobs []PrintJob
+	var jobs []BackendPrintJob
 	if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
 		return nil, err
 	}
@@ -425,11 +497,106 @@ func (c *BackendClient) GetPrintJobs() ([]PrintJob, error) {
 	return jobs, nil
 }
 
+// uploadTimeout bounds a whole file upload or download, which can take far longer than an API call
+const uploadTimeout = 10 * time.Minute
+
 // UploadFile uploads a G-code file
 func (c *BackendClient) UploadFile(filename string, data []byte) error {
-	// Create multipart form data would go here
-	// For now, simplified version
-	return fmt.Errorf("upload not implemented yet")
+	return c.UploadStream(filename, bytes.NewReader(data))
+}
+
+// UploadStream uploads a G-code file while it is being read, so large or
+// post-processed files never have to be held in memory
+func (c *BackendClient) UploadStream(filename string, data io.Reader) error {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	
+	go func() {
+		part, err := form.CreateFormFile("file", filename)
+		if err == nil {
+			_, err = io.Copy(part, data)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	
+	url := fmt.Sprintf("http://%s/api/print-jobs/upload", c.baseURL)
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		body.Close()
+		return err
+	}
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	
+	client := &http.Client{Timeout: uploadTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		body.Close()
+		return err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authentication required")
+	}
+	
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to upload file: %s", resp.Status)
+	}
+	
+	return nil
+}
+
+// UploadGCode uploads a G-code file in any supported format. Binary and
+// compressed files are decoded on the fly and sent as plain G-code, which
+// is what the printer streams and reports byte progress against.
+func (c *BackendClient) UploadGCode(filename string, data io.Reader) (string, error) {
+	source, err := NewGCodeReader(data)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	
+	if source.Format != GCodeFormatText {
+		filename = PlainGCodeName(filename)
+	}
+	return filename, c.UploadStream(filename, source)
+}
+
+// DownloadGCode opens an uploaded G-code file for reading. The caller
+// closes the returned reader.
+func (c *BackendClient) DownloadGCode(filename string) (io.ReadCloser, error) {
+	endpoint := fmt.Sprintf("http://%s/api/print-jobs/%s/download", c.baseURL, url.PathEscape(filename))
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	
+	client := &http.Client{Timeout: uploadTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, fmt.Errorf("authentication required")
+	}
+	
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download file: %s", resp.Status)
+	}
+	
+	return resp.Body, nil
 }
 
 // DeletePrintJob deletes a print job
@@ -452,6 +619,87 @@ func (c *BackendClient) DeletePrintJob(filename string) error {
 	return nil
 }
 
+// errFoldersUnsupported is returned by the folder calls when the backend
+// has no folder API, in which case folders are kept locally
+var errFoldersUnsupported = errors.New("the backend does not support folders")
+
+// GetFolders lists the folders files are organised in
+func (c *BackendClient) GetFolders() ([]string, error) {
+	resp, err := c.makeRequest("GET", "/api/folders", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("authentication required")
+	}
+	
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, errFoldersUnsupported
+	}
+	
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get folders: %s", resp.Status)
+	}
+	
+	var folders []string
+	if err := json.NewDecoder(resp.Body).Decode(&folders); err != nil {
+		return nil, err
+	}
+	
+	return folders, nil
+}
+
+// CreateFolder creates a folder, e.g. "Brackets/v2"
+func (c *BackendClient) CreateFolder(folder string) error {
+	return c.folderRequest("POST", "/api/folders", map[string]string{"path": folder}, "create folder")
+}
+
+// DeleteFolder deletes a folder; its files move to the parent folder
+func (c *BackendClient) DeleteFolder(folder string) error {
+	return c.folderRequest("DELETE", "/api/folders?path="+url.QueryEscape(folder), nil, "delete folder")
+}
+
+// MoveFile moves an uploaded file to a folder; "" is the top level
+func (c *BackendClient) MoveFile(filename, folder string) error {
+	endpoint := fmt.Sprintf("/api/print-jobs/%s/folder", url.PathEscape(filename))
+	return c.folderRequest("PUT", endpoint, map[string]string{"folder": folder}, "move file")
+}
+
+// folderRequest sends a folder change, reporting errFoldersUnsupported
+// when the backend has no folder API
+func (c *BackendClient) folderRequest(method, endpoint string, command map[string]string, action string) error {
+	var body io.Reader
+	if command != nil {
+		jsonData, err := json.Marshal(command)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+	
+	resp, err := c.makeRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authentication required")
+	}
+	
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return errFoldersUnsupported
+	}
+	
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to %s: %s", action, resp.Status)
+	}
+	
+	return nil
+}
+
 // GetSystemLogs retrieves system logs from the backend
 func (c *BackendClient) GetSystemLogs() ([]string, error) {
 	url := fmt.Sprintf("http://%s/api/logs", c.baseURL)
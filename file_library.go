@@ -0,0 +1,639 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LibrarySort is the order of the file library
+type LibrarySort int
+
+const (
+	LibrarySortUploaded    LibrarySort = iota // Newest upload first
+	LibrarySortName                           // Alphabetical
+	LibrarySortPrintTime                      // Longest print first
+	LibrarySortFilament                       // Most filament first
+	LibrarySortLastPrinted                    // Most recently printed first
+)
+
+// LibrarySortNames are the display names of each order
+var LibrarySortNames = map[LibrarySort]string{
+	LibrarySortUploaded:    "Upload date",
+	LibrarySortName:        "Name",
+	LibrarySortPrintTime:   "Print time",
+	LibrarySortFilament:    "Filament",
+	LibrarySortLastPrinted: "Last printed",
+}
+
+// LibraryInfo is the slicer metadata indexed from a file's contents
+type LibraryInfo struct {
+	Slicer       string    `json:"slicer,omitempty"`
+	PrinterModel string    `json:"printer_model,omitempty"`
+	Material     string    `json:"material,omitempty"`
+	LayerHeight  float64   `json:"layer_height,omitempty"`
+	Infill       float64   `json:"infill,omitempty"`
+	PrintTime    float64   `json:"print_time,omitempty"`    // Estimated seconds
+	FilamentUsed float64   `json:"filament_used,omitempty"` // mm
+	Layers       int       `json:"layers,omitempty"`
+	Objects      []string  `json:"objects,omitempty"`
+	Thumbnail    string    `json:"thumbnail,omitempty"` // Cached preview image
+	IndexedAt    time.Time `json:"indexed_at"`
+}
+
+// LibraryFile is a file on the printer together with its library metadata
+type LibraryFile struct {
+	FileName     string
+	Size         int64
+	UploadedAt   time.Time
+	Folder       string // "" is the top level
+	Tags         []string
+	PrintTime    float64 // Estimated seconds, 0 when unknown
+	FilamentUsed float64 // mm, 0 when unknown
+	LastPrinted  time.Time
+	SHA256       string       // Content hash, when the backend reports it
+	Info         *LibraryInfo // nil until the file is indexed
+}
+
+// searchText is the lower-case text a search is matched against
+func (f *LibraryFile) searchText() string {
+	parts := []string{f.FileName, f.Folder}
+	parts = append(parts, f.Tags...)
+	if f.Info != nil {
+		parts = append(parts, f.Info.Slicer, f.Info.PrinterModel, f.Info.Material)
+		parts = append(parts, f.Info.Objects...)
+		if f.Info.LayerHeight > 0 {
+			parts = append(parts, formatCoordinate(f.Info.LayerHeight)+"mm")
+		}
+	}
+	return strings.ToLower(strings.Join(parts, "\n"))
+}
+
+// LibraryQuery selects and orders files of the library
+type LibraryQuery struct {
+	Folder     string
+	Subfolders bool   // Include files in folders below Folder
+	Tag        string // "" for any
+	Search     string // Words that must all appear in the name or metadata
+	Sort       LibrarySort
+}
+
+// Matches reports whether a file is selected by the query
+func (q LibraryQuery) Matches(f *LibraryFile) bool {
+	if q.Subfolders {
+		if q.Folder != "" && !inFolder(f.Folder, q.Folder) {
+			return false
+		}
+	} else if f.Folder != q.Folder {
+		return false
+	}
+	if q.Tag != "" && !containsFold(f.Tags, q.Tag) {
+		return false
+	}
+	if search := strings.Fields(strings.ToLower(q.Search)); len(search) > 0 {
+		text := f.searchText()
+		for _, word := range search {
+			if !strings.Contains(text, word) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Apply returns the files selected by the query in its order
+func (q LibraryQuery) Apply(files []LibraryFile) []LibraryFile {
+	selected := []LibraryFile{}
+	for i := range files {
+		if q.Matches(&files[i]) {
+			selected = append(selected, files[i])
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		a, b := &selected[i], &selected[j]
+		switch q.Sort {
+		case LibrarySortPrintTime:
+			if a.PrintTime != b.PrintTime {
+				return a.PrintTime > b.PrintTime
+			}
+		case LibrarySortFilament:
+			if a.FilamentUsed != b.FilamentUsed {
+				return a.FilamentUsed > b.FilamentUsed
+			}
+		case LibrarySortLastPrinted:
+			if !a.LastPrinted.Equal(b.LastPrinted) {
+				return a.LastPrinted.After(b.LastPrinted)
+			}
+		case LibrarySortUploaded:
+			if !a.UploadedAt.Equal(b.UploadedAt) {
+				return a.UploadedAt.After(b.UploadedAt)
+			}
+		}
+		return strings.ToLower(a.FileName) < strings.ToLower(b.FileName)
+	})
+	return selected
+}
+
+// libraryRecord is what the library keeps locally about a file
+type libraryRecord struct {
+	Folder      string    `json:"folder,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	LastPrinted time.Time `json:"last_printed,omitempty"`
+	SHA256      string    `json:"sha256,omitempty"` // Hash of the G-code as uploaded
+}
+
+// fileLibraryData is the stored library
+type fileLibraryData struct {
+	Folders []string                  `json:"folders"`
+	Files   map[string]*libraryRecord `json:"files"`
+	Index   map[string]*LibraryInfo   `json:"index,omitempty"` // By content hash
+}
+
+// FileLibrary keeps folders, tags and indexed metadata of the printer's
+// files. Folders live on the backend when it supports them; otherwise
+// they are kept here along with everything else. Parsed metadata and
+// previews are keyed on the file's SHA-256, so a file uploaded again under
+// another name is not parsed again.
+type FileLibrary struct {
+	mu            sync.Mutex
+	folders       []string
+	files         map[string]*libraryRecord
+	index         map[string]*LibraryInfo
+	remoteFolders bool
+	localFolders  []string // Kept on disk while the backend's are used
+	path          string
+	onChange      func()
+}
+
+// NewFileLibrary creates a library stored at path
+func NewFileLibrary(path string) *FileLibrary {
+	return &FileLibrary{
+		files: make(map[string]*libraryRecord),
+		index: make(map[string]*LibraryInfo),
+		path:  path,
+	}
+}
+
+// fileLibraryFile is where the touchscreen keeps its file library
+func fileLibraryFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "file_library.json")
+}
+
+// SetOnChange sets a callback run after every change to the library
+func (lib *FileLibrary) SetOnChange(callback func()) {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	lib.onChange = callback
+}
+
+// Load reads the stored library. A missing file leaves it empty.
+func (lib *FileLibrary) Load() error {
+	data, err := ioutil.ReadFile(lib.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored fileLibraryData
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid file library: %v", err)
+	}
+
+	lib.mu.Lock()
+	lib.folders = stored.Folders
+	lib.files = stored.Files
+	if lib.files == nil {
+		lib.files = make(map[string]*libraryRecord)
+	}
+	lib.index = stored.Index
+	if lib.index == nil {
+		lib.index = make(map[string]*LibraryInfo)
+	}
+	lib.mu.Unlock()
+	return nil
+}
+
+// SetRemoteFolders takes the folder list from the backend, which is used
+// instead of the local one from then on
+func (lib *FileLibrary) SetRemoteFolders(folders []string) {
+	lib.mu.Lock()
+	if !lib.remoteFolders {
+		lib.localFolders = lib.folders
+	}
+	lib.remoteFolders = true
+	lib.folders = normalizeFolders(folders)
+	lib.mu.Unlock()
+	lib.notify()
+}
+
+// RemoteFolders reports whether folders are kept by the backend
+func (lib *FileLibrary) RemoteFolders() bool {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	return lib.remoteFolders
+}
+
+// Folders returns every folder, parents before their subfolders
+func (lib *FileLibrary) Folders() []string {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	return append([]string(nil), lib.folders...)
+}
+
+// CreateFolder adds a folder and any missing parents
+func (lib *FileLibrary) CreateFolder(folder string) error {
+	folder, err := cleanFolder(folder)
+	if err != nil {
+		return err
+	}
+	if folder == "" {
+		return fmt.Errorf("enter a folder name")
+	}
+	return lib.update(func() error {
+		lib.folders = normalizeFolders(append(lib.folders, folder))
+		return nil
+	})
+}
+
+// DeleteFolder removes a folder and its subfolders. Their files move to
+// the folder's parent.
+func (lib *FileLibrary) DeleteFolder(folder string) error {
+	parent := path.Dir(folder)
+	if parent == "." {
+		parent = ""
+	}
+	return lib.update(func() error {
+		kept := lib.folders[:0]
+		for _, existing := range lib.folders {
+			if !inFolder(existing, folder) {
+				kept = append(kept, existing)
+			}
+		}
+		lib.folders = kept
+		for _, record := range lib.files {
+			if inFolder(record.Folder, folder) {
+				record.Folder = parent
+			}
+		}
+		return nil
+	})
+}
+
+// Move puts a file in a folder; "" is the top level
+func (lib *FileLibrary) Move(fileName, folder string) error {
+	folder, err := cleanFolder(folder)
+	if err != nil {
+		return err
+	}
+	return lib.update(func() error {
+		if folder != "" {
+			lib.folders = normalizeFolders(append(lib.folders, folder))
+		}
+		lib.record(fileName).Folder = folder
+		return nil
+	})
+}
+
+// SetTags replaces the tags of a file. Tags are trimmed and duplicates
+// differing only in case dropped.
+func (lib *FileLibrary) SetTags(fileName string, tags []string) error {
+	cleaned := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !containsFold(cleaned, tag) {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return lib.update(func() error {
+		lib.record(fileName).Tags = cleaned
+		return nil
+	})
+}
+
+// Tags returns every tag in use, sorted
+func (lib *FileLibrary) Tags() []string {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	tags := []string{}
+	for _, record := range lib.files {
+		for _, tag := range record.Tags {
+			if !containsFold(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return strings.ToLower(tags[i]) < strings.ToLower(tags[j]) })
+	return tags
+}
+
+// RecordPrinted notes that a file was printed
+func (lib *FileLibrary) RecordPrinted(fileName string, at time.Time) error {
+	return lib.update(func() error {
+		record := lib.record(fileName)
+		if at.After(record.LastPrinted) {
+			record.LastPrinted = at
+		}
+		return nil
+	})
+}
+
+// SetHash records the SHA-256 of a file's contents, e.g. after uploading it
+func (lib *FileLibrary) SetHash(fileName, sum string) error {
+	return lib.update(func() error {
+		lib.record(fileName).SHA256 = sum
+		return nil
+	})
+}
+
+// Hash returns the recorded SHA-256 of a file, or "" if unknown
+func (lib *FileLibrary) Hash(fileName string) string {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	if record, ok := lib.files[fileName]; ok {
+		return record.SHA256
+	}
+	return ""
+}
+
+// Indexed reports whether a file's contents have been indexed. sum is the
+// hash reported by the backend, or "" to use the recorded one.
+func (lib *FileLibrary) Indexed(fileName, sum string) bool {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	_, ok := lib.index[lib.hashOf(fileName, sum)]
+	return ok
+}
+
+// Index stores the slicer metadata and preview of a parsed file under the
+// hash of its contents
+func (lib *FileLibrary) Index(fileName, sum string, model *GCodeModel) error {
+	if sum == "" {
+		return fmt.Errorf("no content hash for %s", fileName)
+	}
+	metadata := model.Metadata
+	info := &LibraryInfo{
+		Slicer:       metadata.GeneratedBy,
+		PrinterModel: metadata.PrinterModel,
+		LayerHeight:  metadata.LayerHeight,
+		Infill:       metadata.InfillDensity,
+		PrintTime:    metadata.PrintTime,
+		FilamentUsed: metadata.FilamentUsed,
+		Layers:       len(model.Layers),
+		IndexedAt:    time.Now(),
+	}
+	for _, key := range []string{"filament_type", "material"} {
+		if material := metadata.SlicerSettings[key]; material != "" {
+			info.Material = strings.Trim(material, `"`)
+			break
+		}
+	}
+	for _, object := range model.Objects {
+		info.Objects = append(info.Objects, object.Name)
+	}
+
+	if thumbnail := model.PreviewThumbnail(); thumbnail != nil {
+		thumbnailPath := lib.thumbnailPath(sum, thumbnail.Format)
+		if err := os.MkdirAll(filepath.Dir(thumbnailPath), 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(thumbnailPath, thumbnail.Data, 0600); err != nil {
+			return err
+		}
+		info.Thumbnail = thumbnailPath
+	}
+
+	return lib.update(func() error {
+		lib.record(fileName).SHA256 = sum
+		lib.index[sum] = info
+		return nil
+	})
+}
+
+// Annotate adds the library's metadata to files listed by the backend.
+// Folders reported by the backend are kept when it manages them.
+func (lib *FileLibrary) Annotate(files []LibraryFile) []LibraryFile {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+
+	annotated := make([]LibraryFile, len(files))
+	for i, file := range files {
+		if record, ok := lib.files[file.FileName]; ok {
+			if !lib.remoteFolders {
+				file.Folder = record.Folder
+			}
+			file.Tags = append([]string(nil), record.Tags...)
+			if record.LastPrinted.After(file.LastPrinted) {
+				file.LastPrinted = record.LastPrinted
+			}
+		}
+		if info, ok := lib.index[lib.hashOf(file.FileName, file.SHA256)]; ok {
+			copied := *info
+			file.Info = &copied
+			if file.PrintTime <= 0 {
+				file.PrintTime = info.PrintTime
+			}
+			if file.FilamentUsed <= 0 {
+				file.FilamentUsed = info.FilamentUsed
+			}
+		}
+		annotated[i] = file
+	}
+	return annotated
+}
+
+// Prune forgets files no longer on the printer, and the metadata and
+// previews of contents no remaining file has
+func (lib *FileLibrary) Prune(existing []LibraryFile) error {
+	present := make(map[string]bool, len(existing))
+	for _, file := range existing {
+		present[file.FileName] = true
+	}
+	return lib.update(func() error {
+		for name := range lib.files {
+			if !present[name] {
+				delete(lib.files, name)
+			}
+		}
+		used := make(map[string]bool)
+		for _, file := range existing {
+			used[lib.hashOf(file.FileName, file.SHA256)] = true
+		}
+		for sum, info := range lib.index {
+			if used[sum] {
+				continue
+			}
+			if info.Thumbnail != "" {
+				os.Remove(info.Thumbnail)
+			}
+			delete(lib.index, sum)
+		}
+		return nil
+	})
+}
+
+// FilesWithHash returns the files recorded with the given contents, sorted
+func (lib *FileLibrary) FilesWithHash(sum string) []string {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	names := []string{}
+	for name, record := range lib.files {
+		if sum != "" && record.SHA256 == sum {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hashOf returns the reported hash of a file, falling back to the recorded
+// one; the caller holds the lock
+func (lib *FileLibrary) hashOf(fileName, reported string) string {
+	if reported != "" {
+		return reported
+	}
+	if record, ok := lib.files[fileName]; ok {
+		return record.SHA256
+	}
+	return ""
+}
+
+// thumbnailPath is where the preview of some contents is cached
+func (lib *FileLibrary) thumbnailPath(sum, format string) string {
+	return filepath.Join(filepath.Dir(lib.path), "thumbnails", sum+"."+strings.ToLower(format))
+}
+
+// record returns the record of a file, creating it; the caller holds the lock
+func (lib *FileLibrary) record(fileName string) *libraryRecord {
+	record, ok := lib.files[fileName]
+	if !ok {
+		record = &libraryRecord{}
+		lib.files[fileName] = record
+	}
+	return record
+}
+
+// update applies a change, saves the library and notifies listeners
+func (lib *FileLibrary) update(change func() error) error {
+	lib.mu.Lock()
+	if err := change(); err != nil {
+		lib.mu.Unlock()
+		return err
+	}
+	lib.mu.Unlock()
+
+	if err := lib.save(); err != nil {
+		return fmt.Errorf("failed to save file library: %v", err)
+	}
+	lib.notify()
+	return nil
+}
+
+// save writes the library to disk. Folders from the backend are not kept,
+// the local ones are in case the backend stops providing them.
+func (lib *FileLibrary) save() error {
+	if lib.path == "" {
+		return nil
+	}
+
+	lib.mu.Lock()
+	stored := fileLibraryData{Folders: lib.folders, Files: lib.files, Index: lib.index}
+	if lib.remoteFolders {
+		stored.Folders = lib.localFolders
+	}
+	jsonData, err := json.MarshalIndent(stored, "", "  ")
+	lib.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(lib.path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(lib.path, jsonData, 0600)
+}
+
+// notify runs the change callback
+func (lib *FileLibrary) notify() {
+	lib.mu.Lock()
+	callback := lib.onChange
+	lib.mu.Unlock()
+	if callback != nil {
+		callback()
+	}
+}
+
+// cleanFolder normalises a folder path such as " /Brackets//v2/ " to
+// "Brackets/v2"
+func cleanFolder(folder string) (string, error) {
+	parts := []string{}
+	for _, part := range strings.Split(folder, "/") {
+		part = strings.TrimSpace(part)
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", fmt.Errorf("invalid folder %q", folder)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// normalizeFolders cleans, de-duplicates and sorts folders, adding any
+// missing parents
+func normalizeFolders(folders []string) []string {
+	seen := map[string]bool{}
+	for _, folder := range folders {
+		folder, err := cleanFolder(folder)
+		if err != nil {
+			continue
+		}
+		for folder != "" && folder != "." && !seen[folder] {
+			seen[folder] = true
+			folder = path.Dir(folder)
+		}
+	}
+	normalized := make([]string, 0, len(seen))
+	for folder := range seen {
+		normalized = append(normalized, folder)
+	}
+	sort.Slice(normalized, func(i, j int) bool {
+		return strings.ToLower(normalized[i]) < strings.ToLower(normalized[j])
+	})
+	return normalized
+}
+
+// inFolder reports whether folder is dir or below it
+func inFolder(folder, dir string) bool {
+	return folder == dir || strings.HasPrefix(folder, dir+"/")
+}
+
+// containsString reports whether a list holds a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether a list holds a string, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
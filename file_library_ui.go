@@ -0,0 +1,599 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Folder choices that are not folders
+const (
+	libraryAllFiles = "All Files"
+	libraryTopLevel = "Top Level"
+	libraryAllTags  = "All Tags"
+)
+
+// libraryThumbnailSize is the preview size in the grid, large enough to tap
+var libraryThumbnailSize = fyne.NewSize(180, 120)
+
+// FileLibraryUI shows the printer's files as a thumbnail grid with folders,
+// tags, search and sorting
+type FileLibraryUI struct {
+	window  fyne.Window
+	library *FileLibrary
+	backend *BackendClient // nil disables backend folders and indexing
+
+	files    []LibraryFile // Everything on the printer
+	shown    []LibraryFile // Files selected by the query
+	query    LibraryQuery
+	selected string
+
+	grid         *widget.GridWrap
+	searchEntry  *widget.Entry
+	folderSelect *widget.Select
+	tagSelect    *widget.Select
+	sortSelect   *widget.Select
+	details      *widget.Label
+	status       *widget.Label
+
+	indexMu  sync.Mutex
+	indexing bool
+
+	// OnSelected is called with the file name when a file is tapped
+	OnSelected func(fileName string)
+}
+
+// NewFileLibraryUI creates the library view. Folders come from the backend
+// when it has a folder API and contents are indexed by downloading files;
+// with a nil backend both are local only.
+func NewFileLibraryUI(window fyne.Window, library *FileLibrary, backend *BackendClient) *FileLibraryUI {
+	return &FileLibraryUI{
+		window:  window,
+		library: library,
+		backend: backend,
+		query:   LibraryQuery{Subfolders: true},
+	}
+}
+
+// CreateUI builds the library view
+func (ui *FileLibraryUI) CreateUI() fyne.CanvasObject {
+	ui.searchEntry = widget.NewEntry()
+	ui.searchEntry.SetPlaceHolder("Search name, slicer, material, objects...")
+	ui.searchEntry.OnChanged = func(text string) {
+		ui.query.Search = text
+		ui.applyQuery()
+	}
+
+	ui.folderSelect = widget.NewSelect(nil, func(selected string) {
+		switch selected {
+		case libraryAllFiles, "":
+			ui.query.Folder, ui.query.Subfolders = "", true
+		case libraryTopLevel:
+			ui.query.Folder, ui.query.Subfolders = "", false
+		default:
+			ui.query.Folder, ui.query.Subfolders = selected, false
+		}
+		ui.applyQuery()
+	})
+
+	ui.tagSelect = widget.NewSelect(nil, func(selected string) {
+		ui.query.Tag = ""
+		if selected != libraryAllTags {
+			ui.query.Tag = selected
+		}
+		ui.applyQuery()
+	})
+
+	sorts := []string{}
+	for order := LibrarySortUploaded; order <= LibrarySortLastPrinted; order++ {
+		sorts = append(sorts, LibrarySortNames[order])
+	}
+	ui.sortSelect = widget.NewSelect(sorts, func(selected string) {
+		for order, name := range LibrarySortNames {
+			if name == selected {
+				ui.query.Sort = order
+			}
+		}
+		ui.applyQuery()
+	})
+
+	ui.grid = widget.NewGridWrap(
+		func() int { return len(ui.shown) },
+		func() fyne.CanvasObject {
+			image := canvas.NewImageFromResource(theme.FileIcon())
+			image.FillMode = canvas.ImageFillContain
+			image.SetMinSize(libraryThumbnailSize)
+			name := widget.NewLabelWithStyle("File name", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+			name.Wrapping = fyne.TextTruncate
+			details := widget.NewLabelWithStyle("Details", fyne.TextAlignCenter, fyne.TextStyle{})
+			details.Wrapping = fyne.TextTruncate
+			return container.NewBorder(nil, container.NewVBox(name, details), nil, nil, image)
+		},
+		func(id widget.GridWrapItemID, obj fyne.CanvasObject) {
+			if id >= len(ui.shown) {
+				return
+			}
+			file := ui.shown[id]
+			tile := obj.(*fyne.Container)
+
+			image := tile.Objects[0].(*canvas.Image)
+			if file.Info != nil && file.Info.Thumbnail != "" {
+				image.Resource, image.File = nil, file.Info.Thumbnail
+			} else {
+				image.Resource, image.File = theme.FileIcon(), ""
+			}
+			image.Refresh()
+
+			labels := tile.Objects[1].(*fyne.Container)
+			labels.Objects[0].(*widget.Label).SetText(file.FileName)
+			labels.Objects[1].(*widget.Label).SetText(libraryTileDetails(&file))
+		},
+	)
+	ui.grid.OnSelected = func(id widget.GridWrapItemID) {
+		if id >= len(ui.shown) {
+			return
+		}
+		ui.selected = ui.shown[id].FileName
+		ui.showDetails()
+		if ui.OnSelected != nil {
+			ui.OnSelected(ui.selected)
+		}
+	}
+
+	newFolderBtn := widget.NewButtonWithIcon("New Folder", theme.FolderNewIcon(), func() {
+		ui.showNewFolderDialog()
+	})
+	moveBtn := widget.NewButtonWithIcon("Move", theme.FolderOpenIcon(), func() {
+		ui.showMoveDialog()
+	})
+	tagsBtn := widget.NewButtonWithIcon("Tags", theme.DocumentCreateIcon(), func() {
+		ui.showTagsDialog()
+	})
+	deleteFolderBtn := widget.NewButtonWithIcon("Delete Folder", theme.DeleteIcon(), func() {
+		ui.confirmDeleteFolder()
+	})
+
+	ui.details = widget.NewLabel("No file selected")
+	ui.details.Wrapping = fyne.TextWrapWord
+	ui.status = widget.NewLabel("")
+
+	ui.updateChoices()
+	ui.folderSelect.SetSelected(libraryAllFiles)
+	ui.tagSelect.SetSelected(libraryAllTags)
+	ui.sortSelect.SetSelected(LibrarySortNames[LibrarySortUploaded])
+
+	toolbar := container.NewVBox(
+		ui.searchEntry,
+		container.NewGridWithColumns(3, ui.folderSelect, ui.tagSelect, ui.sortSelect),
+		container.NewGridWithColumns(4, newFolderBtn, moveBtn, tagsBtn, deleteFolderBtn),
+	)
+	// Keep two rows of files visible inside scrolling pages
+	gridSize := canvas.NewRectangle(color.Transparent)
+	gridSize.SetMinSize(fyne.NewSize(2*libraryThumbnailSize.Width, 4*libraryThumbnailSize.Height))
+
+	return container.NewBorder(
+		toolbar,
+		container.NewVBox(widget.NewCard("Selected File", "", ui.details), ui.status),
+		nil, nil,
+		container.NewMax(gridSize, ui.grid),
+	)
+}
+
+// SetFiles replaces the files on the printer and indexes any that are new
+func (ui *FileLibraryUI) SetFiles(files []LibraryFile) {
+	if err := ui.library.Prune(files); err != nil {
+		log.Printf("Failed to update file library: %v", err)
+	}
+
+	ui.files = files
+	ui.reannotate()
+	ui.indexFiles()
+}
+
+// SyncFolders fetches the backend's folders. Backends without a folder API
+// keep using the local folders.
+func (ui *FileLibraryUI) SyncFolders() {
+	if ui.backend == nil {
+		return
+	}
+	folders, err := ui.backend.GetFolders()
+	if err == errFoldersUnsupported {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get folders: %v", err)
+		return
+	}
+	ui.library.SetRemoteFolders(folders)
+	ui.updateChoices()
+}
+
+// Selected returns the selected file name, or "" if none
+func (ui *FileLibraryUI) Selected() string {
+	return ui.selected
+}
+
+// Select selects a file as if it had been tapped
+func (ui *FileLibraryUI) Select(fileName string) {
+	ui.selected = fileName
+	ui.applyQuery()
+	if ui.OnSelected != nil {
+		ui.OnSelected(fileName)
+	}
+}
+
+// reannotate merges the library's metadata into the files and shows them
+func (ui *FileLibraryUI) reannotate() {
+	ui.files = ui.library.Annotate(ui.files)
+	ui.updateChoices()
+	ui.applyQuery()
+}
+
+// applyQuery refreshes the grid for the current query
+func (ui *FileLibraryUI) applyQuery() {
+	if ui.grid == nil {
+		return
+	}
+	ui.shown = ui.query.Apply(ui.files)
+	ui.grid.UnselectAll()
+	ui.grid.Refresh()
+	for i, file := range ui.shown {
+		if file.FileName == ui.selected {
+			ui.grid.Select(i)
+			break
+		}
+	}
+	ui.showDetails()
+	ui.status.SetText(fmt.Sprintf("%d of %d files", len(ui.shown), len(ui.files)))
+}
+
+// updateChoices refreshes the folder and tag choices
+func (ui *FileLibraryUI) updateChoices() {
+	if ui.folderSelect == nil {
+		return
+	}
+	folders := append([]string{libraryAllFiles, libraryTopLevel}, ui.library.Folders()...)
+	ui.folderSelect.Options = folders
+	if !containsString(folders, ui.folderSelect.Selected) {
+		ui.folderSelect.SetSelected(libraryAllFiles)
+	}
+	ui.folderSelect.Refresh()
+
+	tags := append([]string{libraryAllTags}, ui.library.Tags()...)
+	ui.tagSelect.Options = tags
+	if !containsString(tags, ui.tagSelect.Selected) {
+		ui.tagSelect.SetSelected(libraryAllTags)
+	}
+	ui.tagSelect.Refresh()
+}
+
+// selectedFile returns the selected file
+func (ui *FileLibraryUI) selectedFile() (LibraryFile, bool) {
+	for _, file := range ui.files {
+		if file.FileName == ui.selected {
+			return file, true
+		}
+	}
+	return LibraryFile{}, false
+}
+
+// showDetails describes the selected file
+func (ui *FileLibraryUI) showDetails() {
+	file, ok := ui.selectedFile()
+	if !ok {
+		ui.details.SetText("No file selected")
+		return
+	}
+
+	folder := file.Folder
+	if folder == "" {
+		folder = libraryTopLevel
+	}
+	lines := []string{
+		file.FileName,
+		"Folder: " + folder,
+	}
+	if len(file.Tags) > 0 {
+		lines = append(lines, "Tags: "+strings.Join(file.Tags, ", "))
+	}
+	if !file.UploadedAt.IsZero() {
+		lines = append(lines, "Uploaded: "+file.UploadedAt.Format("Jan 2 2006 15:04"))
+	}
+	if file.Size > 0 {
+		lines = append(lines, fmt.Sprintf("Size: %.1f MB", float64(file.Size)/(1024*1024)))
+	}
+	if file.PrintTime > 0 {
+		lines = append(lines, "Print time: "+formatSeconds(file.PrintTime))
+	}
+	if file.FilamentUsed > 0 {
+		lines = append(lines, fmt.Sprintf("Filament: %.2f m", file.FilamentUsed/1000))
+	}
+	if info := file.Info; info != nil {
+		if info.Slicer != "" {
+			lines = append(lines, "Slicer: "+info.Slicer)
+		}
+		if info.Material != "" {
+			lines = append(lines, "Material: "+info.Material)
+		}
+		if info.LayerHeight > 0 {
+			lines = append(lines, fmt.Sprintf("Layers: %d x %s mm", info.Layers, formatCoordinate(info.LayerHeight)))
+		}
+	}
+	if file.LastPrinted.IsZero() {
+		lines = append(lines, "Never printed")
+	} else {
+		lines = append(lines, "Last printed: "+file.LastPrinted.Format("Jan 2 2006 15:04"))
+	}
+	ui.details.SetText(strings.Join(lines, "\n"))
+}
+
+// libraryTileDetails is the short line under a file in the grid
+func libraryTileDetails(file *LibraryFile) string {
+	parts := []string{}
+	if file.PrintTime > 0 {
+		parts = append(parts, formatSeconds(file.PrintTime))
+	}
+	if file.FilamentUsed > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f m", file.FilamentUsed/1000))
+	}
+	if file.Info != nil && file.Info.Material != "" {
+		parts = append(parts, file.Info.Material)
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " | ")
+}
+
+// indexFiles downloads and parses files not yet indexed, one at a time, so
+// their slicer metadata and previews become available
+func (ui *FileLibraryUI) indexFiles() {
+	if ui.backend == nil {
+		return
+	}
+	ui.indexMu.Lock()
+	if ui.indexing {
+		ui.indexMu.Unlock()
+		return
+	}
+	ui.indexing = true
+	ui.indexMu.Unlock()
+
+	pending := []string{}
+	for _, file := range ui.files {
+		if !ui.library.Indexed(file.FileName, file.SHA256) {
+			pending = append(pending, file.FileName)
+		}
+	}
+
+	go func() {
+		defer func() {
+			ui.indexMu.Lock()
+			ui.indexing = false
+			ui.indexMu.Unlock()
+		}()
+
+		for i, name := range pending {
+			ui.status.SetText(fmt.Sprintf("Indexing %d of %d: %s", i+1, len(pending), name))
+			if err := ui.indexFile(name); err != nil {
+				log.Printf("Failed to index %s: %v", name, err)
+				continue
+			}
+			ui.reannotate()
+		}
+		if len(pending) > 0 {
+			ui.applyQuery()
+		}
+	}()
+}
+
+// indexFile downloads, parses and indexes one file. The G-code is hashed
+// as it is parsed, as uploads are, so contents already indexed under
+// another name are reused.
+func (ui *FileLibraryUI) indexFile(name string) error {
+	reader, err := ui.backend.DownloadGCode(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	model, digest, err := ParseGCodeDigest(reader)
+	if err != nil {
+		return err
+	}
+	return ui.library.Index(name, digest.SHA256, model)
+}
+
+// showNewFolderDialog asks for a folder to create
+func (ui *FileLibraryUI) showNewFolderDialog() {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("e.g. Brackets/v2")
+	if ui.query.Folder != "" {
+		nameEntry.SetText(ui.query.Folder + "/")
+	}
+
+	dialog.ShowForm("New Folder", "Create", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Folder", nameEntry),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		folder, err := cleanFolder(nameEntry.Text)
+		if err == nil && folder == "" {
+			err = fmt.Errorf("enter a folder name")
+		}
+		if err == nil && ui.library.RemoteFolders() {
+			err = ui.backend.CreateFolder(folder)
+		}
+		if err == nil {
+			err = ui.library.CreateFolder(folder)
+		}
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.updateChoices()
+	}, ui.window)
+}
+
+// showMoveDialog moves the selected file to another folder
+func (ui *FileLibraryUI) showMoveDialog() {
+	file, ok := ui.selectedFile()
+	if !ok {
+		dialog.ShowInformation("Move", "Select a file first", ui.window)
+		return
+	}
+
+	folderSelect := widget.NewSelect(append([]string{libraryTopLevel}, ui.library.Folders()...), nil)
+	if file.Folder == "" {
+		folderSelect.SetSelected(libraryTopLevel)
+	} else {
+		folderSelect.SetSelected(file.Folder)
+	}
+
+	dialog.ShowForm("Move "+file.FileName, "Move", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Folder", folderSelect),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		folder := folderSelect.Selected
+		if folder == libraryTopLevel {
+			folder = ""
+		}
+		var err error
+		if ui.library.RemoteFolders() {
+			err = ui.backend.MoveFile(file.FileName, folder)
+		}
+		if err == nil {
+			err = ui.library.Move(file.FileName, folder)
+		}
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		for i := range ui.files {
+			if ui.files[i].FileName == file.FileName {
+				ui.files[i].Folder = folder
+			}
+		}
+		ui.reannotate()
+	}, ui.window)
+}
+
+// showTagsDialog edits the tags of the selected file
+func (ui *FileLibraryUI) showTagsDialog() {
+	file, ok := ui.selectedFile()
+	if !ok {
+		dialog.ShowInformation("Tags", "Select a file first", ui.window)
+		return
+	}
+
+	tagsEntry := widget.NewEntry()
+	tagsEntry.SetText(strings.Join(file.Tags, ", "))
+	tagsEntry.SetPlaceHolder("Comma separated, e.g. customer-a, petg")
+	items := []*widget.FormItem{widget.NewFormItem("Tags", tagsEntry)}
+	if existing := ui.library.Tags(); len(existing) > 0 {
+		hint := widget.NewLabel(strings.Join(existing, ", "))
+		hint.Wrapping = fyne.TextWrapWord
+		items = append(items, widget.NewFormItem("In use", hint))
+	}
+
+	dialog.ShowForm("Tags for "+file.FileName, "Save", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if err := ui.library.SetTags(file.FileName, strings.Split(tagsEntry.Text, ",")); err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.reannotate()
+	}, ui.window)
+}
+
+// confirmDeleteFolder deletes the folder being browsed
+func (ui *FileLibraryUI) confirmDeleteFolder() {
+	folder := ui.query.Folder
+	if folder == "" {
+		dialog.ShowInformation("Delete Folder", "Choose a folder first", ui.window)
+		return
+	}
+
+	dialog.ShowConfirm("Delete Folder",
+		fmt.Sprintf("Delete %s and its subfolders? Their files move up a level and are not deleted.", folder),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			var err error
+			if ui.library.RemoteFolders() {
+				err = ui.backend.DeleteFolder(folder)
+			}
+			if err == nil {
+				err = ui.library.DeleteFolder(folder)
+			}
+			if err != nil {
+				dialog.ShowError(err, ui.window)
+				return
+			}
+			parent := ""
+			if i := strings.LastIndex(folder, "/"); i >= 0 {
+				parent = folder[:i]
+			}
+			for i := range ui.files {
+				if inFolder(ui.files[i].Folder, folder) {
+					ui.files[i].Folder = parent
+				}
+			}
+			ui.reannotate()
+		}, ui.window)
+}
+
+// libraryFilesFromJobs lists the files behind the backend's print jobs. A
+// file printed several times is listed once, uploaded when first seen and
+// last printed when a job of it last completed.
+func libraryFilesFromJobs(jobs []BackendPrintJob) []LibraryFile {
+	files := []LibraryFile{}
+	index := map[string]int{}
+	for _, job := range jobs {
+		if job.Filename == "" {
+			continue
+		}
+		created, _ := time.Parse(time.RFC3339, job.CreatedAt)
+		i, ok := index[job.Filename]
+		if !ok {
+			i = len(files)
+			index[job.Filename] = i
+			files = append(files, LibraryFile{FileName: job.Filename, UploadedAt: created})
+		}
+		file := &files[i]
+		if job.Folder != "" {
+			file.Folder = job.Folder
+		}
+		if job.Size > 0 {
+			file.Size = job.Size
+		}
+		if job.SHA256 != "" {
+			file.SHA256 = job.SHA256
+		}
+		if !created.IsZero() && (file.UploadedAt.IsZero() || created.Before(file.UploadedAt)) {
+			file.UploadedAt = created
+		}
+		if strings.EqualFold(job.Status, "completed") {
+			if completed, err := time.Parse(time.RFC3339, job.CompletedAt); err == nil && completed.After(file.LastPrinted) {
+				file.LastPrinted = completed
+			}
+		}
+	}
+	return files
+}
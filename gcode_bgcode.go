@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// Binary G-code block types
+const (
+	bgcodeBlockFileMetadata    = 0
+	bgcodeBlockGCode           = 1
+	bgcodeBlockSlicerMetadata  = 2
+	bgcodeBlockPrinterMetadata = 3
+	bgcodeBlockPrintMetadata   = 4
+	bgcodeBlockThumbnail       = 5
+)
+
+// Binary G-code block compression
+const (
+	bgcodeCompressionNone         = 0
+	bgcodeCompressionDeflate      = 1
+	bgcodeCompressionHeatshrink11 = 2
+	bgcodeCompressionHeatshrink12 = 3
+)
+
+// Binary G-code encodings of G-code blocks
+const (
+	bgcodeEncodingNone             = 0
+	bgcodeEncodingMeatPack         = 1
+	bgcodeEncodingMeatPackComments = 2
+)
+
+// bgcodeThumbnailFormats maps the thumbnail format parameter to its name
+var bgcodeThumbnailFormats = map[uint16]string{0: "PNG", 1: "JPG", 2: "QOI"}
+
+// bgcodeMaxBlockSize guards against allocating for a corrupt size field
+const bgcodeMaxBlockSize = 64 << 20
+
+// bgcodeBlock is one decoded block of a binary G-code file
+type bgcodeBlock struct {
+	Type   uint16
+	Params []byte
+	Data   []byte // Decompressed payload
+}
+
+// bgcodeReader streams the G-code text out of a binary G-code file,
+// collecting metadata and thumbnails into its owner as blocks go by
+type bgcodeReader struct {
+	src      *bufio.Reader
+	owner    *GCodeReader
+	checksum bool
+	pending  []byte
+	done     bool
+}
+
+// newBGCodeReader reads the file header and every block before the first
+// G-code block, so metadata and thumbnails are known up front
+func newBGCodeReader(src *bufio.Reader, owner *GCodeReader) (*bgcodeReader, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, fmt.Errorf("truncated file header")
+	}
+	if version := binary.LittleEndian.Uint32(header[4:8]); version != 1 {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	r := &bgcodeReader{
+		src:      src,
+		owner:    owner,
+		checksum: binary.LittleEndian.Uint16(header[8:10]) == 1,
+	}
+	for len(r.pending) == 0 && !r.done {
+		if err := r.nextBlock(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Read returns decoded G-code text, decoding blocks as needed
+func (r *bgcodeReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.nextBlock(); err != nil {
+			return 0, fmt.Errorf("invalid binary G-code: %v", err)
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// nextBlock reads one block, queueing G-code text and storing anything else
+func (r *bgcodeReader) nextBlock() error {
+	block, err := r.readBlock()
+	if err == io.EOF {
+		r.done = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch block.Type {
+	case bgcodeBlockGCode:
+		text, err := decodeBGCodeText(block)
+		if err != nil {
+			return err
+		}
+		r.pending = text
+	case bgcodeBlockFileMetadata, bgcodeBlockSlicerMetadata, bgcodeBlockPrinterMetadata, bgcodeBlockPrintMetadata:
+		parseBGCodeMetadata(block.Data, r.owner.Metadata)
+	case bgcodeBlockThumbnail:
+		r.owner.Thumbnails = append(r.owner.Thumbnails, GCodeThumbnail{
+			Format: bgcodeThumbnailFormats[binary.LittleEndian.Uint16(block.Params[0:2])],
+			Width:  int(binary.LittleEndian.Uint16(block.Params[2:4])),
+			Height: int(binary.LittleEndian.Uint16(block.Params[4:6])),
+			Data:   block.Data,
+		})
+	}
+	return nil
+}
+
+// readBlock reads, verifies and decompresses the next block. It returns
+// io.EOF only at a clean end of file.
+func (r *bgcodeReader) readBlock() (*bgcodeBlock, error) {
+	header := make([]byte, 8, 12)
+	if _, err := io.ReadFull(r.src, header); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("truncated block header")
+	}
+
+	block := &bgcodeBlock{Type: binary.LittleEndian.Uint16(header[0:2])}
+	compression := binary.LittleEndian.Uint16(header[2:4])
+	size := binary.LittleEndian.Uint32(header[4:8])
+	storedSize := size
+	if compression != bgcodeCompressionNone {
+		header = header[:12]
+		if _, err := io.ReadFull(r.src, header[8:12]); err != nil {
+			return nil, fmt.Errorf("truncated block header")
+		}
+		storedSize = binary.LittleEndian.Uint32(header[8:12])
+	}
+	if size > bgcodeMaxBlockSize || storedSize > bgcodeMaxBlockSize {
+		return nil, fmt.Errorf("block of %d bytes is too large", size)
+	}
+
+	paramsSize := 2
+	if block.Type == bgcodeBlockThumbnail {
+		paramsSize = 6
+	} else if block.Type > bgcodeBlockThumbnail {
+		return nil, fmt.Errorf("unknown block type %d", block.Type)
+	}
+
+	body := make([]byte, paramsSize+int(storedSize))
+	if _, err := io.ReadFull(r.src, body); err != nil {
+		return nil, fmt.Errorf("truncated block")
+	}
+	if r.checksum {
+		sum := make([]byte, 4)
+		if _, err := io.ReadFull(r.src, sum); err != nil {
+			return nil, fmt.Errorf("truncated block checksum")
+		}
+		crc := crc32.Update(crc32.ChecksumIEEE(header), crc32.IEEETable, body)
+		if crc != binary.LittleEndian.Uint32(sum) {
+			return nil, fmt.Errorf("block checksum mismatch")
+		}
+	}
+	block.Params = body[:paramsSize]
+	stored := body[paramsSize:]
+
+	switch compression {
+	case bgcodeCompressionNone:
+		block.Data = stored
+	case bgcodeCompressionDeflate:
+		zr, err := zlib.NewReader(bytes.NewReader(stored))
+		if err != nil {
+			return nil, fmt.Errorf("invalid deflate block: %v", err)
+		}
+		block.Data = make([]byte, size)
+		if _, err := io.ReadFull(zr, block.Data); err != nil {
+			return nil, fmt.Errorf("invalid deflate block: %v", err)
+		}
+	case bgcodeCompressionHeatshrink11:
+		block.Data = heatshrinkDecode(stored, 11, 4, int(size))
+	case bgcodeCompressionHeatshrink12:
+		block.Data = heatshrinkDecode(stored, 12, 4, int(size))
+	default:
+		return nil, fmt.Errorf("unknown compression %d", compression)
+	}
+	if len(block.Data) != int(size) {
+		return nil, fmt.Errorf("block decompressed to %d bytes, expected %d", len(block.Data), size)
+	}
+
+	return block, nil
+}
+
+// decodeBGCodeText turns a G-code block into text
+func decodeBGCodeText(block *bgcodeBlock) ([]byte, error) {
+	switch encoding := binary.LittleEndian.Uint16(block.Params); encoding {
+	case bgcodeEncodingNone:
+		return block.Data, nil
+	case bgcodeEncodingMeatPack, bgcodeEncodingMeatPackComments:
+		return meatPackDecode(block.Data), nil
+	default:
+		return nil, fmt.Errorf("unknown G-code encoding %d", encoding)
+	}
+}
+
+// parseBGCodeMetadata reads the key=value lines of a metadata block
+func parseBGCodeMetadata(data []byte, metadata map[string]string) {
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		metadata[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+}
+
+// heatshrinkDecode expands heatshrink data with the given window and
+// lookahead sizes (in bits). Backreferences before the start of the output
+// read zeros, like the zeroed window of the reference decoder.
+func heatshrinkDecode(data []byte, windowBits, lookaheadBits uint, sizeHint int) []byte {
+	out := make([]byte, 0, sizeHint)
+	bitPos := 0
+	totalBits := len(data) * 8
+
+	readBits := func(count int) (int, bool) {
+		if bitPos+count > totalBits {
+			return 0, false
+		}
+		value := 0
+		for i := 0; i < count; i++ {
+			bit := (data[bitPos/8] >> (7 - uint(bitPos%8))) & 1
+			value = value<<1 | int(bit)
+			bitPos++
+		}
+		return value, true
+	}
+
+	for {
+		tag, ok := readBits(1)
+		if !ok {
+			return out
+		}
+		if tag == 1 {
+			literal, ok := readBits(8)
+			if !ok {
+				return out
+			}
+			out = append(out, byte(literal))
+			continue
+		}
+
+		index, ok := readBits(int(windowBits))
+		if !ok {
+			return out
+		}
+		count, ok := readBits(int(lookaheadBits))
+		if !ok {
+			return out
+		}
+		offset := index + 1
+		for i := 0; i <= count; i++ {
+			if from := len(out) - offset; from >= 0 {
+				out = append(out, out[from])
+			} else {
+				out = append(out, 0)
+			}
+		}
+	}
+}
+
+// MeatPack signal and command bytes
+const (
+	meatPackSignal          = 0xff
+	meatPackEnablePacking   = 0xfb
+	meatPackDisablePacking  = 0xfa
+	meatPackResetAll        = 0xf9
+	meatPackEnableNoSpaces  = 0xf7
+	meatPackDisableNoSpaces = 0xf6
+)
+
+// meatPackParameters are the G-code words that need a space restored
+// before them when spaces were stripped
+const meatPackParameters = "XYZEFIJRPWHCA"
+
+// meatPackDecoder unpacks MeatPack's 4-bit character pairs
+type meatPackDecoder struct {
+	packing   bool
+	noSpaces  bool
+	signals   int
+	command   bool
+	fullChars int  // Full-width bytes still to come
+	deferred  byte // Packed character waiting for a full-width one
+	out       []byte
+	addSpaces bool
+}
+
+// meatPackDecode decodes a MeatPack-encoded G-code block
+func meatPackDecode(data []byte) []byte {
+	d := &meatPackDecoder{out: make([]byte, 0, len(data)*2)}
+	for _, c := range data {
+		d.receive(c)
+	}
+	return d.out
+}
+
+// receive handles signal bytes before passing a byte on for unpacking
+func (d *meatPackDecoder) receive(c byte) {
+	if c == meatPackSignal {
+		if d.signals > 0 {
+			d.command = true
+			d.signals = 0
+		} else {
+			d.signals++
+		}
+		return
+	}
+
+	if d.command {
+		switch c {
+		case meatPackEnablePacking:
+			d.packing = true
+		case meatPackDisablePacking, meatPackResetAll:
+			d.packing = false
+		case meatPackEnableNoSpaces:
+			d.noSpaces = true
+		case meatPackDisableNoSpaces:
+			d.noSpaces = false
+		}
+		d.command = false
+		return
+	}
+
+	if d.signals > 0 {
+		d.unpack(meatPackSignal)
+		d.signals = 0
+	}
+	d.unpack(c)
+}
+
+// unpack decodes one data byte
+func (d *meatPackDecoder) unpack(c byte) {
+	if !d.packing {
+		d.emit(c)
+		return
+	}
+
+	if d.fullChars > 0 {
+		d.emit(c)
+		if d.deferred != 0 {
+			d.emit(d.deferred)
+			d.deferred = 0
+		}
+		d.fullChars--
+		return
+	}
+
+	firstFull := c&0x0f == 0x0f
+	secondFull := c&0xf0 == 0xf0
+	if firstFull {
+		d.fullChars++
+		if secondFull {
+			d.fullChars++
+		} else {
+			d.deferred = d.packedChar(c >> 4)
+		}
+		return
+	}
+
+	first := d.packedChar(c & 0x0f)
+	d.emit(first)
+	if first == '\n' {
+		return
+	}
+	if secondFull {
+		d.fullChars++
+	} else {
+		d.emit(d.packedChar(c >> 4))
+	}
+}
+
+// packedChar maps a 4-bit code to its character
+func (d *meatPackDecoder) packedChar(code byte) byte {
+	switch {
+	case code <= 9:
+		return '0' + code
+	case code == 10:
+		return '.'
+	case code == 11:
+		if d.noSpaces {
+			return 'E'
+		}
+		return ' '
+	case code == 12:
+		return '\n'
+	case code == 13:
+		return 'G'
+	default:
+		return 'X'
+	}
+}
+
+// emit appends a decoded character, restoring the spaces between the words
+// of G lines and dropping repeated newlines. Unlike libbgcode, which adds
+// spaces up to the end of the line, this stops at a ';' so a comment such as
+// ";WIPE_START" comes back as written rather than as "; W I P E_START".
+func (d *meatPackDecoder) emit(c byte) {
+	last := byte('\n')
+	if len(d.out) > 0 {
+		last = d.out[len(d.out)-1]
+	}
+
+	if c == 'G' && last == '\n' {
+		d.addSpaces = true
+	} else if c == '\n' || c == ';' {
+		d.addSpaces = false
+	}
+	if d.addSpaces && last != ' ' && last != '\n' && strings.IndexByte(meatPackParameters, c) >= 0 {
+		d.out = append(d.out, ' ')
+	}
+	if c == '\n' && last == '\n' {
+		return
+	}
+	d.out = append(d.out, c)
+}
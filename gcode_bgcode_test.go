@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testdata/prusaslicer_sample.bgcode holds prusaslicer_sample.gcode in the
+// block layout PrusaSlicer writes: file, printer, thumbnail, print and slicer
+// metadata followed by three G-code blocks. The G-code blocks are
+// heatshrink 12/4 with MeatPack comments and no spaces, heatshrink 11/4 with
+// MeatPack comments, and deflate without encoding. Every block carries a
+// CRC32. It was written by testdata/make_prusaslicer_sample.py, not by
+// PrusaSlicer, so TestBGCodeSampleLayout checks it against the
+// specification without the decoder.
+
+// bgcodeSampleBlock is a block of the sample file, read without the decoder
+type bgcodeSampleBlock struct {
+	Type        uint16
+	Compression uint16
+	Params      []byte
+	Stored      []byte // As stored, still compressed
+}
+
+// readBGCodeSample splits the sample file into blocks with only the
+// standard library, checking the file header and every block's CRC32
+func readBGCodeSample(t *testing.T) []bgcodeSampleBlock {
+	t.Helper()
+	data, err := os.ReadFile("testdata/prusaslicer_sample.bgcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	le := binary.LittleEndian
+	if len(data) < 10 || string(data[:4]) != "GCDE" || le.Uint32(data[4:]) != 1 || le.Uint16(data[8:]) != 1 {
+		t.Fatalf("file header % x, want GCDE, version 1 and CRC32 checksums", data[:10])
+	}
+
+	var blocks []bgcodeSampleBlock
+	pos := 10
+	take := func(n int) []byte {
+		if pos+n > len(data) {
+			t.Fatalf("block at %d runs past the end of the file", pos)
+		}
+		field := data[pos : pos+n]
+		pos += n
+		return field
+	}
+	for pos < len(data) {
+		start := pos
+		header := take(8)
+		block := bgcodeSampleBlock{Type: le.Uint16(header), Compression: le.Uint16(header[2:])}
+		size := int(le.Uint32(header[4:]))
+		if block.Compression != 0 {
+			size = int(le.Uint32(take(4)))
+		}
+		paramsSize := 2
+		if block.Type == 5 {
+			paramsSize = 6 // Format, width and height
+		}
+		block.Params = take(paramsSize)
+		block.Stored = take(size)
+		if sum := le.Uint32(take(4)); sum != crc32.ChecksumIEEE(data[start:pos-4]) {
+			t.Errorf("block %d at %d: CRC32 %08x does not match its contents", len(blocks), start, sum)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// inflateSample decompresses a deflate block of the sample
+func inflateSample(t *testing.T, block bgcodeSampleBlock) string {
+	t.Helper()
+	zr, err := zlib.NewReader(bytes.NewReader(block.Stored))
+	if err != nil {
+		t.Fatalf("inflate: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("inflate: %v", err)
+	}
+	return string(data)
+}
+
+func TestBGCodeSampleLayout(t *testing.T) {
+	blocks := readBGCodeSample(t)
+
+	// PrusaSlicer's block order, then how each block is stored
+	wantTypes := []uint16{0, 3, 5, 4, 2, 1, 1, 1}
+	wantCompression := []uint16{0, 1, 0, 2, 1, 3, 2, 1}
+	if len(blocks) != len(wantTypes) {
+		t.Fatalf("%d blocks, want %d", len(blocks), len(wantTypes))
+	}
+	for i, block := range blocks {
+		if block.Type != wantTypes[i] || block.Compression != wantCompression[i] {
+			t.Errorf("block %d: type %d compression %d, want type %d compression %d",
+				i, block.Type, block.Compression, wantTypes[i], wantCompression[i])
+		}
+	}
+	for i, want := range []uint16{2, 2, 0} {
+		if encoding := binary.LittleEndian.Uint16(blocks[5+i].Params); encoding != want {
+			t.Errorf("G-code block %d: encoding %d, want %d", i, encoding, want)
+		}
+	}
+
+	if string(blocks[0].Stored) != "Producer=PrusaSlicer 2.7.1\n" {
+		t.Errorf("file metadata %q", blocks[0].Stored)
+	}
+	if printer := inflateSample(t, blocks[1]); !strings.Contains(printer, "printer_model=MK4\n") {
+		t.Errorf("printer metadata %q", printer)
+	}
+
+	thumbnail := blocks[2]
+	params := []uint16{
+		binary.LittleEndian.Uint16(thumbnail.Params),
+		binary.LittleEndian.Uint16(thumbnail.Params[2:]),
+		binary.LittleEndian.Uint16(thumbnail.Params[4:]),
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(thumbnail.Stored))
+	if err != nil {
+		t.Fatalf("thumbnail: %v", err)
+	}
+	if params[0] != 0 || int(params[1]) != config.Width || int(params[2]) != config.Height {
+		t.Errorf("thumbnail parameters %v for a %dx%d PNG", params, config.Width, config.Height)
+	}
+
+	// The unencoded deflate block is the end of the text file
+	text, err := os.ReadFile("testdata/prusaslicer_sample.gcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tail := inflateSample(t, blocks[7])
+	if tail == "" || !strings.HasSuffix(string(text), tail) {
+		t.Errorf("last G-code block is not the end of the text file:\n%s", tail)
+	}
+}
+
+func TestBGCodeMatchesText(t *testing.T) {
+	want, err := os.ReadFile("testdata/prusaslicer_sample.gcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := OpenGCodeFile("testdata/prusaslicer_sample.bgcode")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer reader.Close()
+	if reader.Format != GCodeFormatBinary {
+		t.Errorf("format = %v, want binary", reader.Format)
+	}
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("decoded G-code differs\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestBGCodeMetadataAndThumbnail(t *testing.T) {
+	reader, err := OpenGCodeFile("testdata/prusaslicer_sample.bgcode")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer reader.Close()
+
+	// Metadata and thumbnails come before the G-code, so they are known
+	// without reading any text
+	for key, want := range map[string]string{
+		"Producer":                              "PrusaSlicer 2.7.1",
+		"printer_model":                         "MK4",
+		"filament used [mm]":                    "14.29",
+		"estimated printing time (normal mode)": "1m 52s",
+		"layer_height":                          "0.2",
+	} {
+		if got := reader.Metadata[key]; got != want {
+			t.Errorf("metadata %q = %q, want %q", key, got, want)
+		}
+	}
+
+	if len(reader.Thumbnails) != 1 {
+		t.Fatalf("got %d thumbnails, want 1", len(reader.Thumbnails))
+	}
+	thumb := reader.Thumbnails[0]
+	if thumb.Format != "PNG" || thumb.Width != 1 || thumb.Height != 1 {
+		t.Errorf("thumbnail = %s %dx%d, want PNG 1x1", thumb.Format, thumb.Width, thumb.Height)
+	}
+	if string(thumb.Data[1:4]) != "PNG" {
+		t.Errorf("thumbnail data does not start with a PNG signature")
+	}
+}
+
+func TestBGCodeParsesLikeText(t *testing.T) {
+	parse := func(path string) *GCodeModel {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		model, err := NewGCodeParser().ParseGCode(file)
+		if err != nil {
+			t.Fatalf("parse %s: %v", path, err)
+		}
+		return model
+	}
+
+	text := parse("testdata/prusaslicer_sample.gcode")
+	binary := parse("testdata/prusaslicer_sample.bgcode")
+	if len(binary.Commands) != len(text.Commands) {
+		t.Errorf("bgcode has %d commands, text has %d", len(binary.Commands), len(text.Commands))
+	}
+	if len(binary.Layers) != len(text.Layers) {
+		t.Errorf("bgcode has %d layers, text has %d", len(binary.Layers), len(text.Layers))
+	}
+	if binary.Format != GCodeFormatBinary || len(binary.Thumbnails) != 1 {
+		t.Errorf("bgcode model format %v with %d thumbnails", binary.Format, len(binary.Thumbnails))
+	}
+}
+
+func TestBGCodeRejectsBadChecksum(t *testing.T) {
+	data, err := os.ReadFile("testdata/prusaslicer_sample.bgcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last byte belongs to the CRC of the final G-code block
+	data[len(data)-1] ^= 0xff
+
+	reader, err := NewGCodeReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Error("expected a checksum error")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// compareZTolerance is how close two layer heights must be to count as the
+// same layer in both files
+const compareZTolerance = 0.005
+
+// Thresholds below which per-layer differences are treated as noise
+const (
+	compareTimeTolerance     = 0.5  // Seconds
+	compareFilamentTolerance = 0.01 // mm of filament
+	compareBoundsTolerance   = 0.05 // mm
+	compareFeatureTolerance  = 1.0  // Percentage points of the feature mix
+)
+
+// compareFeatures are the extruding path types the feature mix is built from
+var compareFeatures = []PathType{PathTypePerimeter, PathTypeInfill, PathTypeSupport, PathTypeExtrusion}
+
+// FeatureMix is the share of a layer's extrusion per path type, in percent
+type FeatureMix map[PathType]float64
+
+// LayerComparison holds one layer height in both files. IndexA or IndexB is
+// -1 when only one file has a layer at that height.
+type LayerComparison struct {
+	Z                    float64
+	IndexA, IndexB       int // 0-based layer indices
+	TimeA, TimeB         float64
+	FilamentA, FilamentB float64
+	FeaturesA, FeaturesB FeatureMix
+	BoundsA, BoundsB     GCodeBounds
+}
+
+// SettingChange is a slicer setting that differs between the two files.
+// A or B is empty when the setting only appears in the other file.
+type SettingChange struct {
+	Key  string
+	A, B string
+}
+
+// GCodeComparison is the difference between two parsed files, A being the
+// loaded file and B the one it is compared with
+type GCodeComparison struct {
+	A, B     *GCodeModel
+	NameB    string
+	Layers   []LayerComparison
+	Settings []SettingChange
+}
+
+// CompareGCode compares two files layer by layer, matching layers by height
+// so reslices with a different first layer still line up
+func CompareGCode(a, b *GCodeModel, nameB string) *GCodeComparison {
+	c := &GCodeComparison{A: a, B: b, NameB: nameB}
+
+	i, j := 0, 0
+	for i < len(a.Layers) || j < len(b.Layers) {
+		switch {
+		case j >= len(b.Layers) || (i < len(a.Layers) && a.Layers[i].Z < b.Layers[j].Z-compareZTolerance):
+			c.Layers = append(c.Layers, c.layerComparison(i, -1))
+			i++
+		case i >= len(a.Layers) || b.Layers[j].Z < a.Layers[i].Z-compareZTolerance:
+			c.Layers = append(c.Layers, c.layerComparison(-1, j))
+			j++
+		default:
+			c.Layers = append(c.Layers, c.layerComparison(i, j))
+			i++
+			j++
+		}
+	}
+
+	c.Settings = compareSettings(a.Metadata.SlicerSettings, b.Metadata.SlicerSettings)
+	return c
+}
+
+// layerComparison collects the figures of a layer pair
+func (c *GCodeComparison) layerComparison(indexA, indexB int) LayerComparison {
+	lc := LayerComparison{IndexA: indexA, IndexB: indexB}
+	if indexA >= 0 {
+		layer := c.A.Layers[indexA]
+		lc.Z = layer.Z
+		lc.TimeA = layer.LayerTime
+		lc.FilamentA = layer.FilamentUsed
+		lc.FeaturesA = layerFeatureMix(c.A, layer)
+		lc.BoundsA = layer.BoundingBox
+	}
+	if indexB >= 0 {
+		layer := c.B.Layers[indexB]
+		lc.Z = layer.Z
+		lc.TimeB = layer.LayerTime
+		lc.FilamentB = layer.FilamentUsed
+		lc.FeaturesB = layerFeatureMix(c.B, layer)
+		lc.BoundsB = layer.BoundingBox
+	}
+	return lc
+}
+
+// layerFeatureMix splits a layer's extrusion between path types
+func layerFeatureMix(model *GCodeModel, layer GCodeLayer) FeatureMix {
+	mix := make(FeatureMix)
+	total := 0.0
+	for _, pathIndex := range layer.Paths {
+		path := model.Paths[pathIndex]
+		if path.ExtrusionAmount > 0 {
+			mix[path.PathType] += path.ExtrusionAmount
+			total += path.ExtrusionAmount
+		}
+	}
+	if total > 0 {
+		for pathType := range mix {
+			mix[pathType] = mix[pathType] / total * 100
+		}
+	}
+	return mix
+}
+
+// compareSettings lists the settings whose values differ, sorted by key
+func compareSettings(a, b map[string]string) []SettingChange {
+	changes := []SettingChange{}
+	for key, valueA := range a {
+		if valueB, ok := b[key]; !ok || valueB != valueA {
+			changes = append(changes, SettingChange{Key: key, A: valueA, B: valueB})
+		}
+	}
+	for key, valueB := range b {
+		if _, ok := a[key]; !ok {
+			changes = append(changes, SettingChange{Key: key, B: valueB})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// Changed reports whether the layer differs beyond noise
+func (lc LayerComparison) Changed() bool {
+	return lc.IndexA < 0 || lc.IndexB < 0 ||
+		math.Abs(lc.TimeB-lc.TimeA) > compareTimeTolerance ||
+		math.Abs(lc.FilamentB-lc.FilamentA) > compareFilamentTolerance ||
+		!lc.boundsMatch() ||
+		len(lc.featureChanges()) > 0
+}
+
+// boundsMatch reports whether the layer covers the same area in both files
+func (lc LayerComparison) boundsMatch() bool {
+	a, b := lc.BoundsA, lc.BoundsB
+	for _, d := range []float64{a.MinX - b.MinX, a.MaxX - b.MaxX, a.MinY - b.MinY, a.MaxY - b.MaxY} {
+		if math.Abs(d) > compareBoundsTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// featureChanges describes the path types whose share of the layer changed
+func (lc LayerComparison) featureChanges() []string {
+	changes := []string{}
+	for _, pathType := range compareFeatures {
+		if delta := lc.FeaturesB[pathType] - lc.FeaturesA[pathType]; math.Abs(delta) > compareFeatureTolerance {
+			changes = append(changes, fmt.Sprintf("%s %+.0f%%", strings.ToLower(PathTypeNames[pathType]), delta))
+		}
+	}
+	return changes
+}
+
+// Describe summarises how the layer changed from A to B
+func (lc LayerComparison) Describe() string {
+	switch {
+	case lc.IndexA < 0:
+		return fmt.Sprintf("Z %.2f: only in the compared file", lc.Z)
+	case lc.IndexB < 0:
+		return fmt.Sprintf("Z %.2f: only in the loaded file", lc.Z)
+	}
+
+	parts := []string{}
+	if delta := lc.TimeB - lc.TimeA; math.Abs(delta) > compareTimeTolerance {
+		parts = append(parts, fmt.Sprintf("time %+.1fs", delta))
+	}
+	if delta := lc.FilamentB - lc.FilamentA; math.Abs(delta) > compareFilamentTolerance {
+		parts = append(parts, fmt.Sprintf("filament %+.2fmm", delta))
+	}
+	if !lc.boundsMatch() {
+		parts = append(parts, fmt.Sprintf("bounds X %.1f-%.1f Y %.1f-%.1f",
+			lc.BoundsB.MinX, lc.BoundsB.MaxX, lc.BoundsB.MinY, lc.BoundsB.MaxY))
+	}
+	parts = append(parts, lc.featureChanges()...)
+	if len(parts) == 0 {
+		return fmt.Sprintf("Z %.2f: unchanged", lc.Z)
+	}
+	return fmt.Sprintf("Z %.2f: %s", lc.Z, strings.Join(parts, ", "))
+}
+
+// ChangedLayers returns the layers that differ between the files
+func (c *GCodeComparison) ChangedLayers() []LayerComparison {
+	changed := []LayerComparison{}
+	for _, lc := range c.Layers {
+		if lc.Changed() {
+			changed = append(changed, lc)
+		}
+	}
+	return changed
+}
+
+// LayerForA returns the comparison of a layer of the loaded file, if any
+func (c *GCodeComparison) LayerForA(indexA int) (LayerComparison, bool) {
+	for _, lc := range c.Layers {
+		if lc.IndexA == indexA {
+			return lc, true
+		}
+	}
+	return LayerComparison{}, false
+}
+
+// MatchingLayerB returns the layer of B at the height of layer indexA of A,
+// or -1 if B has no layer there
+func (c *GCodeComparison) MatchingLayerB(indexA int) int {
+	if lc, ok := c.LayerForA(indexA); ok {
+		return lc.IndexB
+	}
+	return -1
+}
+
+// Summary compares the totals of both files
+func (c *GCodeComparison) Summary() string {
+	a, b := c.A.Metadata, c.B.Metadata
+	return fmt.Sprintf(
+		"Layers: %d → %d (%d differ)\n"+
+			"Print time: %s → %s (%+.0fs)\n"+
+			"Filament: %.0f → %.0f mm (%+.1f mm)\n"+
+			"Bounds: X %.1f-%.1f Y %.1f-%.1f → X %.1f-%.1f Y %.1f-%.1f\n"+
+			"Settings changed: %d",
+		len(c.A.Layers), len(c.B.Layers), len(c.ChangedLayers()),
+		formatSeconds(a.PrintTime), formatSeconds(b.PrintTime), b.PrintTime-a.PrintTime,
+		a.FilamentUsed, b.FilamentUsed, b.FilamentUsed-a.FilamentUsed,
+		c.A.Bounds.MinX, c.A.Bounds.MaxX, c.A.Bounds.MinY, c.A.Bounds.MaxY,
+		c.B.Bounds.MinX, c.B.Bounds.MaxX, c.B.Bounds.MinY, c.B.Bounds.MaxY,
+		len(c.Settings),
+	)
+}
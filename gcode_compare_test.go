@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// layersAt is a model with empty layers at the given heights
+func layersAt(heights ...float64) *GCodeModel {
+	model := &GCodeModel{}
+	for i, z := range heights {
+		model.Layers = append(model.Layers, GCodeLayer{Index: i, Z: z})
+	}
+	return model
+}
+
+func TestCompareGCodeMatchesLayers(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *GCodeModel
+		want [][2]int // IndexA, IndexB per comparison
+	}{
+		{"same heights", layersAt(0.2, 0.4, 0.6), layersAt(0.2, 0.4, 0.6), [][2]int{{0, 0}, {1, 1}, {2, 2}}},
+		{"within tolerance", layersAt(0.2, 0.4), layersAt(0.2, 0.404), [][2]int{{0, 0}, {1, 1}}},
+		{"thicker first layer", layersAt(0.2, 0.4, 0.6), layersAt(0.3, 0.4, 0.6), [][2]int{{0, -1}, {-1, 0}, {1, 1}, {2, 2}}},
+		{"finer layers", layersAt(0.2, 0.4), layersAt(0.2, 0.3, 0.4), [][2]int{{0, 0}, {-1, 1}, {1, 2}}},
+		{"taller part", layersAt(0.2), layersAt(0.2, 0.4, 0.6), [][2]int{{0, 0}, {-1, 1}, {-1, 2}}},
+		{"empty compared file", layersAt(0.2, 0.4), layersAt(), [][2]int{{0, -1}, {1, -1}}},
+	}
+	for _, test := range tests {
+		c := CompareGCode(test.a, test.b, "b.gcode")
+		got := [][2]int{}
+		for _, lc := range c.Layers {
+			got = append(got, [2]int{lc.IndexA, lc.IndexB})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: layer pairs %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	c := CompareGCode(layersAt(0.2, 0.4), layersAt(0.3, 0.4), "b.gcode")
+	if got := c.MatchingLayerB(1); got != 1 {
+		t.Errorf("MatchingLayerB(1) = %d, want 1", got)
+	}
+	if got := c.MatchingLayerB(0); got != -1 {
+		t.Errorf("MatchingLayerB(0) = %d, want -1 for a layer only in A", got)
+	}
+}
+
+func TestLayerFeatureMix(t *testing.T) {
+	model := &GCodeModel{Paths: []GCodePath{
+		{PathType: PathTypePerimeter, ExtrusionAmount: 3},
+		{PathType: PathTypeTravel},
+		{PathType: PathTypeInfill, ExtrusionAmount: 1.5},
+		{PathType: PathTypeRetraction, ExtrusionAmount: -0.8},
+		{PathType: PathTypePerimeter, ExtrusionAmount: 3},
+		{PathType: PathTypeSupport, ExtrusionAmount: 2.5},
+	}}
+	tests := []struct {
+		name  string
+		paths []int
+		want  FeatureMix
+	}{
+		{"mixed layer", []int{0, 1, 2, 3, 4, 5}, FeatureMix{PathTypePerimeter: 60, PathTypeInfill: 15, PathTypeSupport: 25}},
+		{"one feature", []int{0, 1, 4}, FeatureMix{PathTypePerimeter: 100}},
+		{"travel and retraction only", []int{1, 3}, FeatureMix{}},
+		{"empty layer", nil, FeatureMix{}},
+	}
+	for _, test := range tests {
+		if got := layerFeatureMix(model, GCodeLayer{Paths: test.paths}); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: mix %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLayerComparisonChanged(t *testing.T) {
+	same := LayerComparison{
+		Z: 0.4, IndexA: 1, IndexB: 1,
+		TimeA: 10, TimeB: 10.3,
+		FilamentA: 20, FilamentB: 20.005,
+		FeaturesA: FeatureMix{PathTypePerimeter: 60, PathTypeInfill: 40},
+		FeaturesB: FeatureMix{PathTypePerimeter: 60.5, PathTypeInfill: 39.5},
+		BoundsA:   GCodeBounds{MinX: 10, MaxX: 50, MinY: 10, MaxY: 50},
+		BoundsB:   GCodeBounds{MinX: 10.02, MaxX: 50, MinY: 10, MaxY: 50},
+	}
+	tests := []struct {
+		name   string
+		change func(lc *LayerComparison)
+		want   string
+	}{
+		{"noise", func(lc *LayerComparison) {}, "Z 0.40: unchanged"},
+		{"only in A", func(lc *LayerComparison) { lc.IndexB = -1 }, "Z 0.40: only in the loaded file"},
+		{"only in B", func(lc *LayerComparison) { lc.IndexA = -1 }, "Z 0.40: only in the compared file"},
+		{"slower", func(lc *LayerComparison) { lc.TimeB = 12 }, "Z 0.40: time +2.0s"},
+		{"more filament", func(lc *LayerComparison) { lc.FilamentB = 20.5 }, "Z 0.40: filament +0.50mm"},
+		{"moved", func(lc *LayerComparison) { lc.BoundsB.MaxX = 60 }, "Z 0.40: bounds X 10.0-60.0 Y 10.0-50.0"},
+		{"more infill", func(lc *LayerComparison) {
+			lc.FeaturesB = FeatureMix{PathTypePerimeter: 50, PathTypeInfill: 50}
+		}, "Z 0.40: perimeter -10%, infill +10%"},
+	}
+	for _, test := range tests {
+		lc := same
+		test.change(&lc)
+		if got := lc.Describe(); got != test.want {
+			t.Errorf("%s: Describe = %q, want %q", test.name, got, test.want)
+		}
+		if changed := lc.Changed(); changed != (test.name != "noise") {
+			t.Errorf("%s: Changed = %v", test.name, changed)
+		}
+	}
+}
+
+func TestCompareSettings(t *testing.T) {
+	got := compareSettings(
+		map[string]string{"layer_height": "0.2", "infill": "15%", "brim": "0"},
+		map[string]string{"layer_height": "0.2", "infill": "20%", "ironing": "1"},
+	)
+	want := []SettingChange{
+		{Key: "brim", A: "0"},
+		{Key: "infill", A: "15%", B: "20%"},
+		{Key: "ironing", B: "1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("compareSettings = %+v, want %+v", got, want)
+	}
+	if got := compareSettings(nil, nil); len(got) != 0 {
+		t.Errorf("no settings = %+v", got)
+	}
+}
+
+// The same print as text, binary and gzipped G-code compares as unchanged
+func TestCompareGCodeFormats(t *testing.T) {
+	text, _ := parseFixture(t, "prusaslicer_sample.gcode")
+
+	data, err := os.ReadFile("testdata/prusaslicer_sample.gcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	writer := gzip.NewWriter(&gz)
+	writer.Write(data)
+	writer.Close()
+	binary, err := os.ReadFile("testdata/prusaslicer_sample.bgcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, source := range map[string][]byte{"bgcode": binary, "gzip": gz.Bytes()} {
+		model, err := NewGCodeParser().ParseGCode(bytes.NewReader(source))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		c := CompareGCode(text, model, "sample."+name)
+		if len(model.Layers) == 0 || len(c.Layers) != len(text.Layers) {
+			t.Errorf("%s: %d layers compared, want %d", name, len(c.Layers), len(text.Layers))
+		}
+		for _, lc := range c.ChangedLayers() {
+			t.Errorf("%s: %s", name, lc.Describe())
+		}
+	}
+}
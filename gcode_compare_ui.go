@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createCompareControls creates the card for comparing the loaded file with
+// another file or slicer revision
+func (ui *GCodeViewerUI) createCompareControls() *widget.Card {
+	ui.compareStatus = widget.NewLabel("")
+	ui.compareStatus.Wrapping = fyne.TextWrapWord
+	ui.updateCompareStatus()
+
+	compareBtn := widget.NewButton("Compare With...", func() {
+		ui.chooseComparisonFile()
+	})
+	detailsBtn := widget.NewButton("Differences", func() {
+		ui.showComparisonDetails()
+	})
+	stopBtn := widget.NewButton("Stop", func() {
+		ui.setComparison(nil)
+	})
+
+	return widget.NewCard("Compare", "", container.NewVBox(
+		compareBtn,
+		ui.compareStatus,
+		container.NewGridWithColumns(2, detailsBtn, stopBtn),
+	))
+}
+
+// chooseComparisonFile parses a second file and compares it with the loaded
+// one. Files open as in the viewer: text, binary and compressed G-code are
+// told apart by their content, and 3MF projects compare one sliced plate.
+func (ui *GCodeViewerUI) chooseComparisonFile() {
+	if ui.model == nil {
+		dialog.ShowInformation("Compare", "Load a G-code file first", ui.window)
+		return
+	}
+
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil || reader == nil {
+			return
+		}
+		if strings.EqualFold(reader.URI().Extension(), ".3mf") {
+			reader.Close()
+			ui.chooseComparisonPlate(reader.URI().Path())
+			return
+		}
+
+		progressDialog := dialog.NewProgressInfinite("Compare", "Parsing "+reader.URI().Name()+"...", ui.window)
+		progressDialog.Show()
+
+		go func() {
+			defer reader.Close()
+			model, err := NewGCodeParser().ParseGCode(reader)
+			progressDialog.Hide()
+
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to parse G-code: %v", err), ui.window)
+				return
+			}
+			ui.setComparison(CompareGCode(ui.model, model, reader.URI().Name()))
+		}()
+	}, ui.window)
+}
+
+// chooseComparisonPlate compares with a sliced plate of a 3MF project,
+// asking which one when there are several
+func (ui *GCodeViewerUI) chooseComparisonPlate(path string) {
+	project, err := OpenThreeMF(path)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to open project: %v", err), ui.window)
+		return
+	}
+	var plates []*ThreeMFPlate
+	var names []string
+	for i := range project.Plates {
+		if project.Plates[i].Sliced() {
+			plates = append(plates, &project.Plates[i])
+			names = append(names, project.Plates[i].DisplayName())
+		}
+	}
+	if len(plates) == 0 {
+		project.Close()
+		dialog.ShowError(fmt.Errorf("%s has no sliced plates", project.Name()), ui.window)
+		return
+	}
+
+	compare := func(plate *ThreeMFPlate) {
+		progressDialog := dialog.NewProgressInfinite("Compare", "Parsing "+plate.DisplayName()+"...", ui.window)
+		progressDialog.Show()
+
+		go func() {
+			defer project.Close()
+			model, err := project.ParsePlate(plate)
+			progressDialog.Hide()
+
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to parse %s: %v", plate.DisplayName(), err), ui.window)
+				return
+			}
+			ui.setComparison(CompareGCode(ui.model, model, project.PlateFileName(plate)))
+		}()
+	}
+	if len(plates) == 1 {
+		compare(plates[0])
+		return
+	}
+
+	plateSelect := widget.NewSelect(names, nil)
+	plateSelect.SetSelectedIndex(0)
+	dialog.ShowCustomConfirm("Compare with "+project.Name(), "Compare", "Cancel", plateSelect, func(confirmed bool) {
+		if !confirmed {
+			project.Close()
+			return
+		}
+		compare(plates[plateSelect.SelectedIndex()])
+	}, ui.window)
+}
+
+// setComparison enters comparison mode, or leaves it when c is nil
+func (ui *GCodeViewerUI) setComparison(c *GCodeComparison) {
+	ui.comparison = c
+	ui.viewer.SetComparison(c)
+	ui.updateCompareStatus()
+	ui.updateCurrentLayerInfo()
+}
+
+// refreshComparison compares the compared file with a newly shown model
+func (ui *GCodeViewerUI) refreshComparison() {
+	if ui.comparison == nil || ui.model == nil {
+		return
+	}
+	ui.setComparison(CompareGCode(ui.model, ui.comparison.B, ui.comparison.NameB))
+}
+
+// updateCompareStatus shows the totals of the comparison
+func (ui *GCodeViewerUI) updateCompareStatus() {
+	if ui.comparison == nil {
+		ui.compareStatus.SetText("Not comparing")
+		return
+	}
+	ui.compareStatus.SetText(fmt.Sprintf("Comparing with %s\n%s", ui.comparison.NameB, ui.comparison.Summary()))
+}
+
+// layerComparisonText describes how the current layer differs, for the
+// layer information card
+func (ui *GCodeViewerUI) layerComparisonText() string {
+	if ui.comparison == nil {
+		return ""
+	}
+	lc, ok := ui.comparison.LayerForA(ui.viewer.currentLayer)
+	if !ok {
+		return ""
+	}
+	return "\n\nCompared: " + lc.Describe()
+}
+
+// showComparisonDetails lists the changed layers and settings. Tapping a
+// layer shows it with the compared file overlaid.
+func (ui *GCodeViewerUI) showComparisonDetails() {
+	if ui.comparison == nil {
+		dialog.ShowInformation("Compare", "Choose a file to compare with first", ui.window)
+		return
+	}
+	c := ui.comparison
+
+	summary := widget.NewLabel(c.Summary())
+	summary.Wrapping = fyne.TextWrapWord
+
+	changed := c.ChangedLayers()
+	layerList := widget.NewList(
+		func() int {
+			return len(changed)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("Layer")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			lc := changed[id]
+			text := lc.Describe()
+			if lc.IndexA >= 0 {
+				text = fmt.Sprintf("Layer %d, %s", lc.IndexA+1, text)
+			}
+			item.(*widget.Label).SetText(text)
+		},
+	)
+	layerList.OnSelected = func(id widget.ListItemID) {
+		if index := changed[id].IndexA; index >= 0 {
+			ui.layerSlider.SetValue(float64(index))
+		}
+	}
+
+	settingList := widget.NewList(
+		func() int {
+			return len(c.Settings)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("Setting")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			change := c.Settings[id]
+			item.(*widget.Label).SetText(fmt.Sprintf("%s: %s → %s",
+				change.Key, settingOrUnset(change.A), settingOrUnset(change.B)))
+		},
+	)
+
+	tabs := container.NewAppTabs(
+		container.NewTabItem("Summary", summary),
+		container.NewTabItem(fmt.Sprintf("Layers (%d)", len(changed)), layerList),
+		container.NewTabItem(fmt.Sprintf("Settings (%d)", len(c.Settings)), settingList),
+	)
+
+	details := dialog.NewCustom("Differences from "+c.NameB, "Close", tabs, ui.window)
+	details.Resize(fyne.NewSize(680, 480))
+	details.Show()
+}
+
+// settingOrUnset shows a missing setting value
+func settingOrUnset(value string) string {
+	if value == "" {
+		return "(not set)"
+	}
+	return value
+}
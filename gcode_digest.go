@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// GCodeDigest identifies the contents of a G-code file
+type GCodeDigest struct {
+	SHA256 string      // Hash of the plain G-code as uploaded
+	Format GCodeFormat // Format the file arrived in
+	Size   int64       // Bytes of plain G-code
+}
+
+// DigestGCode hashes a file's G-code as it is uploaded, i.e. after binary
+// and compressed files are decoded, so the same print is recognised
+// whatever format or name it arrives with
+func DigestGCode(r io.Reader) (GCodeDigest, error) {
+	source, err := NewGCodeReader(r)
+	if err != nil {
+		return GCodeDigest{}, err
+	}
+	defer source.Close()
+
+	hashing := newHashingReader(source)
+	size, err := io.Copy(io.Discard, hashing)
+	if err != nil {
+		return GCodeDigest{}, err
+	}
+	return GCodeDigest{SHA256: hashing.Sum(), Format: source.Format, Size: size}, nil
+}
+
+// ParseGCodeDigest parses a file and digests its G-code in the same pass,
+// so what is parsed is keyed on the hash uploads are recorded with
+func ParseGCodeDigest(r io.Reader) (*GCodeModel, GCodeDigest, error) {
+	source, err := NewGCodeReader(r)
+	if err != nil {
+		return nil, GCodeDigest{}, err
+	}
+	defer source.Close()
+
+	hashing := newHashingReader(source)
+	model, err := NewGCodeParser().ParseGCode(hashing)
+	if err != nil {
+		return nil, GCodeDigest{}, err
+	}
+	if _, err := io.Copy(io.Discard, hashing); err != nil {
+		return nil, GCodeDigest{}, err
+	}
+	// The parser only saw the decoded text
+	source.applyMetadata(model)
+	return model, GCodeDigest{SHA256: hashing.Sum(), Format: source.Format, Size: hashing.n}, nil
+}
+
+// hashingReader computes the SHA-256 of everything read through it
+type hashingReader struct {
+	r io.Reader
+	h hash.Hash
+	n int64 // Bytes read
+}
+
+// newHashingReader hashes what is read from r
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{r: r, h: sha256.New()}
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.n += int64(n)
+	return n, err
+}
+
+// Sum returns the hex hash of what has been read so far
+func (r *hashingReader) Sum() string {
+	return hex.EncodeToString(r.h.Sum(nil))
+}
+
+// DuplicateFiles returns the files on the printer with the given contents.
+// Hashes reported by the backend are used first, then those the library
+// recorded when a file was uploaded or indexed.
+func DuplicateFiles(files []GCodeFile, sum string, library *FileLibrary) []GCodeFile {
+	duplicates := []GCodeFile{}
+	if sum == "" {
+		return duplicates
+	}
+	for _, file := range files {
+		known := file.SHA256
+		if known == "" && library != nil {
+			known = library.Hash(file.FileName)
+		}
+		if strings.EqualFold(known, sum) {
+			duplicates = append(duplicates, file)
+		}
+	}
+	return duplicates
+}
+
+// VersionedFileName returns name if it is free, otherwise the first free
+// name_v2.gcode, name_v3.gcode, ...
+func VersionedFileName(name string, taken func(string) bool) string {
+	if !taken(name) {
+		return name
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for version := 2; ; version++ {
+		candidate := fmt.Sprintf("%s_v%d%s", base, version, ext)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDigestGCodeAcrossFormats(t *testing.T) {
+	text, err := os.ReadFile("testdata/prusaslicer_sample.gcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, err := os.ReadFile("testdata/prusaslicer_sample.bgcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(text)
+	gz.Close()
+
+	sum := sha256.Sum256(text)
+	want := hex.EncodeToString(sum[:])
+	for _, test := range []struct {
+		name   string
+		data   []byte
+		format GCodeFormat
+	}{
+		{"text", text, GCodeFormatText},
+		{"binary", binary, GCodeFormatBinary},
+		{"gzip", compressed.Bytes(), GCodeFormatGzip},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			digest, err := DigestGCode(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if digest.SHA256 != want || digest.Size != int64(len(text)) || digest.Format != test.format {
+				t.Errorf("DigestGCode = %+v, want %s, %d bytes, %v", digest, want, len(text), test.format)
+			}
+
+			model, parsed, err := ParseGCodeDigest(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed != digest {
+				t.Errorf("ParseGCodeDigest = %+v, want %+v", parsed, digest)
+			}
+			if model.Format != test.format || len(model.Layers) == 0 {
+				t.Errorf("parsed %v with %d layers", model.Format, len(model.Layers))
+			}
+			if test.format == GCodeFormatBinary && (len(model.Thumbnails) == 0 || model.Metadata.PrinterModel == "") {
+				t.Error("binary metadata and thumbnails were not kept")
+			}
+		})
+	}
+}
+
+func TestDuplicateFiles(t *testing.T) {
+	library := NewFileLibrary(filepath.Join(t.TempDir(), "file_library.json"))
+	if err := library.SetHash("recorded.gcode", "bbbb"); err != nil {
+		t.Fatal(err)
+	}
+	files := []GCodeFile{
+		{FileName: "reported.gcode", SHA256: "AAAA"},
+		{FileName: "recorded.gcode"},
+		{FileName: "other.gcode", SHA256: "cccc"},
+		{FileName: "unknown.gcode"},
+		// The backend's hash wins over a stale recorded one
+		{FileName: "recorded.gcode", SHA256: "dddd"},
+	}
+
+	names := func(files []GCodeFile) []string {
+		names := []string{}
+		for _, file := range files {
+			names = append(names, file.FileName)
+		}
+		return names
+	}
+	for _, test := range []struct {
+		sum     string
+		library *FileLibrary
+		want    []string
+	}{
+		{"aaaa", library, []string{"reported.gcode"}},
+		{"bbbb", library, []string{"recorded.gcode"}},
+		{"bbbb", nil, []string{}},
+		{"eeee", library, []string{}},
+		{"", library, []string{}},
+	} {
+		if got := names(DuplicateFiles(files, test.sum, test.library)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("DuplicateFiles(%q, library %v) = %q, want %q", test.sum, test.library != nil, got, test.want)
+		}
+	}
+}
+
+func TestVersionedFileName(t *testing.T) {
+	taken := func(names ...string) func(string) bool {
+		return func(name string) bool {
+			for _, other := range names {
+				if other == name {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	tests := []struct {
+		name  string
+		taken []string
+		want  string
+	}{
+		{"bracket.gcode", nil, "bracket.gcode"},
+		{"bracket.gcode", []string{"bracket.gcode"}, "bracket_v2.gcode"},
+		{"bracket.gcode", []string{"bracket.gcode", "bracket_v2.gcode", "bracket_v3.gcode"}, "bracket_v4.gcode"},
+		{"bracket.gcode", []string{"bracket.gcode", "bracket_v3.gcode"}, "bracket_v2.gcode"},
+		{"part.v1.gcode", []string{"part.v1.gcode"}, "part.v1_v2.gcode"},
+		{"README", []string{"README"}, "README_v2"},
+	}
+	for _, test := range tests {
+		if got := VersionedFileName(test.name, taken(test.taken...)); got != test.want {
+			t.Errorf("VersionedFileName(%q) with %s taken = %q, want %q",
+				test.name, strings.Join(test.taken, ", "), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// layerStartPreviewLines is how much of the remaining file the preview shows
+const layerStartPreviewLines = 20
+
+const (
+	layerStartByLayer  = "Layer"
+	layerStartByHeight = "Height (mm)"
+)
+
+// showStartFromLayerDialog lets the loaded file be printed from a chosen
+// layer or height as a derived job
+func (ui *GCodeViewerUI) showStartFromLayerDialog() {
+	model := ui.model
+	if ui.backend == nil || model == nil || len(model.Layers) == 0 {
+		dialog.ShowError(fmt.Errorf("load a file and connect to a printer first"), ui.window)
+		return
+	}
+
+	modeSelect := widget.NewSelect([]string{layerStartByLayer, layerStartByHeight}, nil)
+	valueEntry := widget.NewEntry()
+	valueEntry.SetText(fmt.Sprintf("%d", ui.viewer.currentLayer+1))
+	homeZCheck := widget.NewCheck("Home Z (the part must not be under the Z homing point)", nil)
+	homeZCheck.SetChecked(true)
+	emptyBedCheck := widget.NewCheck("Print on an empty bed", nil)
+	nameEntry := widget.NewEntry()
+
+	target := widget.NewLabel("")
+	target.Wrapping = fyne.TextWrapWord
+	preview := widget.NewMultiLineEntry()
+	preview.Disable()
+	preview.SetMinRowsVisible(12)
+
+	plan := func() (*ResumePlan, error) {
+		layer := -1
+		switch modeSelect.Selected {
+		case layerStartByHeight:
+			z, err := parseFormFloat("height", valueEntry.Text)
+			if err != nil {
+				return nil, err
+			}
+			if layer = model.LayerAtHeight(z); layer < 0 {
+				return nil, fmt.Errorf("the file ends below Z %s", formatCoordinate(z))
+			}
+		default:
+			n, err := parseFormInt("layer", valueEntry.Text)
+			if err != nil {
+				return nil, err
+			}
+			layer = n - 1
+		}
+		return PlanLayerStart(model, layer, LayerStartOptions{
+			ResumeOptions: DefaultResumeOptions,
+			HomeZ:         homeZCheck.Checked,
+			OnEmptyBed:    emptyBedCheck.Checked,
+		})
+	}
+
+	lastLayer := -1
+	update := func() {
+		if emptyBedCheck.Checked {
+			homeZCheck.SetChecked(true)
+			homeZCheck.Disable()
+		} else {
+			homeZCheck.Enable()
+		}
+
+		p, err := plan()
+		if err != nil {
+			target.SetText(err.Error())
+			preview.SetText("")
+			return
+		}
+		if p.Layer != lastLayer {
+			lastLayer = p.Layer
+			nameEntry.SetText(fmt.Sprintf("%s_from_layer_%d.gcode", ui.exportBaseName(), p.Layer+1))
+		}
+		layer := model.Layers[p.Layer]
+		text := fmt.Sprintf("Layer %d of %d at Z %.2f mm, from line %d", p.Layer+1, len(model.Layers), layer.Z, p.Line)
+		if p.ZOffset > 0 {
+			text += fmt.Sprintf("\nThe remaining layers are lowered by %.2f mm", p.ZOffset)
+		}
+		target.SetText(text)
+		preview.SetText(layerStartPreview(model, p))
+	}
+	modeSelect.OnChanged = func(string) { update() }
+	valueEntry.OnChanged = func(string) { update() }
+	homeZCheck.OnChanged = func(bool) { update() }
+	emptyBedCheck.OnChanged = func(bool) { update() }
+	modeSelect.SetSelected(layerStartByLayer)
+
+	content := container.NewVBox(
+		widget.NewForm(
+			widget.NewFormItem("Start at", modeSelect),
+			widget.NewFormItem("Value", valueEntry),
+			widget.NewFormItem("File name", nameEntry),
+		),
+		homeZCheck,
+		emptyBedCheck,
+		target,
+		widget.NewLabel("Sent to the printer:"),
+		preview,
+	)
+
+	confirm := dialog.NewCustomConfirm("Start from Layer", "Send & Print", "Cancel", content, func(start bool) {
+		if !start {
+			return
+		}
+		p, err := plan()
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		name := strings.TrimSpace(nameEntry.Text)
+		if name == "" {
+			dialog.ShowError(fmt.Errorf("enter a file name"), ui.window)
+			return
+		}
+		ui.sendLayerStart(model, p, name)
+	}, ui.window)
+	confirm.Resize(fyne.NewSize(640, 640))
+	confirm.Show()
+}
+
+// layerStartPreview shows the start sequence and the beginning of the
+// remaining file exactly as they will be sent
+func layerStartPreview(model *GCodeModel, plan *ResumePlan) string {
+	lines := plan.Preamble()
+	first := model.CommandIndexForLine(plan.Line)
+	end := first + layerStartPreviewLines
+	if end > len(model.Commands) {
+		end = len(model.Commands)
+	}
+	for _, cmd := range model.Commands[first:end] {
+		lines = append(lines, cmd.RawLine)
+	}
+	if remaining := len(model.Commands) - end; remaining > 0 {
+		lines = append(lines, fmt.Sprintf("... %d more lines", remaining))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sendLayerStart uploads the derived file and starts it
+func (ui *GCodeViewerUI) sendLayerStart(model *GCodeModel, plan *ResumePlan, name string) {
+	progressDialog := dialog.NewProgressInfinite("Start from Layer", "Sending "+name+"...", ui.window)
+	progressDialog.Show()
+
+	go func() {
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(WriteResumeGCode(model, plan, writer))
+		}()
+		err := ui.backend.UploadStream(name, reader)
+		reader.Close()
+		started := false
+		if err == nil {
+			started, err = ui.startPrint(name)
+		}
+		progressDialog.Hide()
+
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to start %s: %v", name, err), ui.window)
+			return
+		}
+		if !started {
+			dialog.ShowInformation("Start from Layer", fmt.Sprintf("%s is waiting for staff to approve it", name), ui.window)
+			return
+		}
+		dialog.ShowInformation("Start from Layer", fmt.Sprintf("%s started from layer %d", name, plan.Layer+1), ui.window)
+	}()
+}
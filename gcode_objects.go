@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GCodeObject is a labelled object on the plate that can be cancelled on its own
+type GCodeObject struct {
+	Index   int          // Position in GCodeModel.Objects
+	ID      int          // M486 object id (Index for named objects)
+	Name    string       // Object name as the firmware knows it
+	Center  [2]float64   // XY centre (from EXCLUDE_OBJECT_DEFINE or the bounds)
+	Polygon [][2]float64 // Footprint outline, empty if the file did not define one
+	Bounds  GCodeBounds  // Extent of the object's extrusions
+}
+
+// HasExtent reports whether any extrusion was assigned to the object
+func (o GCodeObject) HasExtent() bool {
+	return o.Bounds.MinX <= o.Bounds.MaxX
+}
+
+// Outline returns the footprint polygon, falling back to the XY bounding box
+func (o GCodeObject) Outline() [][2]float64 {
+	if len(o.Polygon) >= 3 {
+		return o.Polygon
+	}
+	if !o.HasExtent() {
+		return nil
+	}
+	b := o.Bounds
+	return [][2]float64{{b.MinX, b.MinY}, {b.MaxX, b.MinY}, {b.MaxX, b.MaxY}, {b.MinX, b.MaxY}}
+}
+
+// ObjectByName returns the index of a named object, or -1
+func (m *GCodeModel) ObjectByName(name string) int {
+	for i, object := range m.Objects {
+		if object.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// trackObject follows object labels: M486, Klipper EXCLUDE_OBJECT_* and
+// slicer comments (PrusaSlicer "; printing object", Cura ";MESH:")
+func (p *GCodeParser) trackObject(model *GCodeModel, cmd GCodeCommand) {
+	switch cmd.Type {
+	case "M486":
+		p.trackM486(model, cmd)
+		return
+	case "EXCLUDE_OBJECT_DEFINE":
+		params := parseKlipperParams(cmd.RawLine)
+		index := p.objectIndex(model, params["NAME"], -1)
+		if index < 0 {
+			return
+		}
+		object := &model.Objects[index]
+		if center, ok := parseKlipperPoint(params["CENTER"]); ok {
+			object.Center = center
+		}
+		if polygon, ok := parseKlipperPolygon(params["POLYGON"]); ok {
+			object.Polygon = polygon
+		}
+		return
+	case "EXCLUDE_OBJECT_START":
+		p.currentObject = p.objectIndex(model, parseKlipperParams(cmd.RawLine)["NAME"], -1)
+		return
+	case "EXCLUDE_OBJECT_END":
+		p.currentObject = -1
+		return
+	}
+
+	comment := cmd.Comment
+	lower := strings.ToLower(comment)
+	switch {
+	case strings.HasPrefix(lower, "printing object "):
+		p.currentObject = p.objectIndex(model, strings.TrimSpace(comment[len("printing object "):]), -1)
+	case strings.HasPrefix(lower, "stop printing object"):
+		p.currentObject = -1
+	case strings.HasPrefix(comment, "MESH:"):
+		name := strings.TrimSpace(comment[len("MESH:"):])
+		if name == "NONMESH" {
+			p.currentObject = -1
+		} else {
+			p.currentObject = p.objectIndex(model, name, -1)
+		}
+	}
+}
+
+// trackM486 handles Marlin/RepRap object labels: S<id> starts an object
+// (S-1 ends it) and A<name> names the current one
+func (p *GCodeParser) trackM486(model *GCodeModel, cmd GCodeCommand) {
+	if !math.IsNaN(cmd.S) {
+		id := int(cmd.S)
+		if id < 0 {
+			p.currentObject = -1
+		} else {
+			p.currentObject = p.objectIndexByID(model, id)
+		}
+	}
+
+	if name, ok := parseM486Name(cmd.RawLine); ok && p.currentObject >= 0 {
+		model.Objects[p.currentObject].Name = name
+	}
+}
+
+// objectIndex returns the object with a name, adding it if new
+func (p *GCodeParser) objectIndex(model *GCodeModel, name string, id int) int {
+	if name == "" {
+		return -1
+	}
+	if index := model.ObjectByName(name); index >= 0 {
+		return index
+	}
+
+	index := len(model.Objects)
+	if id < 0 {
+		id = index
+	}
+	model.Objects = append(model.Objects, GCodeObject{
+		Index: index,
+		ID:    id,
+		Name:  name,
+		Bounds: GCodeBounds{
+			MinX: math.Inf(1), MaxX: math.Inf(-1),
+			MinY: math.Inf(1), MaxY: math.Inf(-1),
+			MinZ: math.Inf(1), MaxZ: math.Inf(-1),
+		},
+	})
+	return index
+}
+
+// objectIndexByID returns the M486 object with an id, adding it if new
+func (p *GCodeParser) objectIndexByID(model *GCodeModel, id int) int {
+	for i, object := range model.Objects {
+		if object.ID == id {
+			return i
+		}
+	}
+	return p.objectIndex(model, fmt.Sprintf("Object %d", id), id)
+}
+
+// addToObject assigns an extrusion path to the current object
+func (p *GCodeParser) addToObject(model *GCodeModel, path GCodePath) {
+	if p.currentObject < 0 || path.ExtrusionAmount <= 0 {
+		return
+	}
+	bounds := &model.Objects[p.currentObject].Bounds
+	p.updateBounds(bounds, path.StartX, path.StartY, path.StartZ)
+	p.updateBounds(bounds, path.EndX, path.EndY, path.EndZ)
+}
+
+// finalizeObjects fills in centres for objects that did not define one
+func (p *GCodeParser) finalizeObjects(model *GCodeModel) {
+	for i := range model.Objects {
+		object := &model.Objects[i]
+		if object.Center == [2]float64{} && object.HasExtent() {
+			object.Center = [2]float64{
+				(object.Bounds.MinX + object.Bounds.MaxX) / 2,
+				(object.Bounds.MinY + object.Bounds.MaxY) / 2,
+			}
+		}
+	}
+}
+
+// parseM486Name extracts the A<name> parameter, which may contain spaces or quotes
+func parseM486Name(raw string) (string, bool) {
+	command := strings.SplitN(raw, ";", 2)[0]
+	fields := strings.Fields(command)
+	for i, field := range fields[1:] {
+		if len(field) > 1 && (field[0] == 'A' || field[0] == 'a') {
+			name := strings.Join(append([]string{field[1:]}, fields[i+2:]...), " ")
+			return strings.Trim(name, `"'`), true
+		}
+	}
+	return "", false
+}
+
+// parseKlipperParams parses KEY=value parameters of an extended G-code command
+func parseKlipperParams(raw string) map[string]string {
+	params := make(map[string]string)
+	command := strings.SplitN(raw, ";", 2)[0]
+	for _, field := range strings.Fields(command)[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) == 2 {
+			params[strings.ToUpper(parts[0])] = parts[1]
+		}
+	}
+	return params
+}
+
+// parseKlipperPoint parses "x,y"
+func parseKlipperPoint(value string) ([2]float64, bool) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return [2]float64{}, false
+	}
+	x, errX := strconv.ParseFloat(parts[0], 64)
+	y, errY := strconv.ParseFloat(parts[1], 64)
+	if errX != nil || errY != nil {
+		return [2]float64{}, false
+	}
+	return [2]float64{x, y}, true
+}
+
+// parseKlipperPolygon parses "[[x,y],[x,y],...]"
+func parseKlipperPolygon(value string) ([][2]float64, bool) {
+	var points [][2]float64
+	if value == "" || json.Unmarshal([]byte(value), &points) != nil || len(points) < 3 {
+		return nil, false
+	}
+	return points, true
+}
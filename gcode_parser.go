@@ -33,6 +33,16 @@ type GCodePath struct {
 	LayerIndex             int
 	PathType               PathType
 	LineNumber             int
+	ObjectIndex            int // Index into Objects, -1 outside labelled objects
+}
+
+// Length returns the 3D length of the path segment
+func (path GCodePath) Length() float64 {
+	return math.Sqrt(
+		math.Pow(path.EndX-path.StartX, 2) +
+			math.Pow(path.EndY-path.StartY, 2) +
+			math.Pow(path.EndZ-path.StartZ, 2),
+	)
 }
 
 // PathType defines the type of movement
@@ -59,13 +69,18 @@ var PathTypeNames = map[PathType]string{
 
 // GCodeModel represents the complete parsed G-code
 type GCodeModel struct {
-	Commands     []GCodeCommand
-	Paths        []GCodePath
-	Layers       []GCodeLayer
-	Bounds       GCodeBounds
-	Metadata     GCodeMetadata
-	TotalLines   int
-	ParseErrors  []string
+	Commands    []GCodeCommand
+	Paths       []GCodePath
+	Layers      []GCodeLayer
+	Objects     []GCodeObject // Labelled objects (M486 / EXCLUDE_OBJECT / slicer comments)
+	Bounds      GCodeBounds
+	Metadata    GCodeMetadata
+	TotalLines  int
+	ParseErrors []string
+	LineOffsets []int64          // Byte offset of each source line (index = line number - 1)
+	TimeTable   []float64        // Cumulative estimated seconds at the end of each path
+	Format      GCodeFormat      // Container the text was read from
+	Thumbnails  []GCodeThumbnail // Embedded previews (bgcode blocks or text comment blocks)
 }
 
 // GCodeLayer represents a single layer
@@ -111,19 +126,31 @@ type GCodeParser struct {
 	currentLayer                 int
 	layerZ                       float64
 	lastExtrusionAmount          float64
+	currentObject                int
+	currentFeature               PathType // Feature announced by the last ;TYPE: comment
+	thumbnails                   textThumbnailCollector
 }
 
 // NewGCodeParser creates a new G-code parser
 func NewGCodeParser() *GCodeParser {
 	return &GCodeParser{
-		absoluteMode:  true,
-		absoluteEMode: true,
-		currentF:      1500, // Default feed rate
+		absoluteMode:   true,
+		absoluteEMode:  true,
+		currentF:       1500, // Default feed rate
+		currentObject:  -1,
+		currentFeature: PathTypeExtrusion,
 	}
 }
 
-// ParseGCode parses G-code from a reader
+// ParseGCode parses G-code from a reader. Binary and compressed G-code are
+// detected by their magic bytes and decoded first.
 func (p *GCodeParser) ParseGCode(reader io.Reader) (*GCodeModel, error) {
+	source, err := NewGCodeReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
 	model := &GCodeModel{
 		Commands:    make([]GCodeCommand, 0),
 		Paths:       make([]GCodePath, 0),
@@ -139,13 +166,27 @@ func (p *GCodeParser) ParseGCode(reader io.Reader) (*GCodeModel, error) {
 		},
 	}
 
-	scanner := bufio.NewScanner(reader)
+	scanner := bufio.NewScanner(source)
 	lineNumber := 0
+	
+	// Track the raw length of each line so byte offsets reported by the
+	// printer can be mapped back to line numbers
+	var offset int64
+	lineAdvance := 0
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			lineAdvance = advance
+		}
+		return advance, token, err
+	})
 
 	var currentLayer *GCodeLayer
 
 	for scanner.Scan() {
 		lineNumber++
+		model.LineOffsets = append(model.LineOffsets, offset)
+		offset += int64(lineAdvance)
 		line := strings.TrimSpace(scanner.Text())
 		
 		if line == "" {
@@ -156,13 +197,25 @@ func (p *GCodeParser) ParseGCode(reader io.Reader) (*GCodeModel, error) {
 		cmd := p.parseLine(line, lineNumber)
 		model.Commands = append(model.Commands, cmd)
 
+		// Thumbnails, object labels, features and slicer settings sit on
+		// comment-only lines
+		if cmd.Type == "" {
+			thumbnail, inThumbnail := p.thumbnails.add(cmd.Comment)
+			if thumbnail != nil {
+				model.Thumbnails = append(model.Thumbnails, *thumbnail)
+			}
+			if inThumbnail {
+				continue
+			}
+		}
+		p.trackObject(model, cmd)
+		p.trackFeature(cmd)
+		p.extractMetadata(&model.Metadata, cmd)
+
 		if !cmd.IsValid {
 			continue
 		}
 
-		// Extract metadata from comments
-		p.extractMetadata(&model.Metadata, cmd)
-
 		// Process movement commands
 		if cmd.Type == "G0" || cmd.Type == "G1" {
 			// Calculate new position
@@ -193,15 +246,16 @@ func (p *GCodeParser) ParseGCode(reader io.Reader) (*GCodeModel, error) {
 
 			// Create path segment
 			path := GCodePath{
-				StartX:     p.currentX,
-				StartY:     p.currentY,
-				StartZ:     p.currentZ,
-				EndX:       newX,
-				EndY:       newY,
-				EndZ:       newZ,
-				Speed:      p.currentF,
-				LayerIndex: p.currentLayer,
-				LineNumber: lineNumber,
+				StartX:      p.currentX,
+				StartY:      p.currentY,
+				StartZ:      p.currentZ,
+				EndX:        newX,
+				EndY:        newY,
+				EndZ:        newZ,
+				Speed:       p.currentF,
+				LayerIndex:  p.currentLayer,
+				LineNumber:  lineNumber,
+				ObjectIndex: p.currentObject,
 			}
 
 			// Determine path type and extrusion
@@ -217,6 +271,7 @@ func (p *GCodeParser) ParseGCode(reader io.Reader) (*GCodeModel, error) {
 			}
 
 			model.Paths = append(model.Paths, path)
+			p.addToObject(model, path)
 
 			// Update current layer
 			if currentLayer != nil {
@@ -250,6 +305,8 @@ func (p *GCodeParser) ParseGCode(reader io.Reader) (*GCodeModel, error) {
 
 	// Post-process metadata
 	p.finalizeMetadata(&model.Metadata, model)
+	p.finalizeObjects(model)
+	source.applyMetadata(model)
 
 	return model, scanner.Err()
 }
@@ -320,6 +377,8 @@ func (p *GCodeParser) parseLine(line string, lineNumber int) GCodeCommand {
 			cmd.F = value
 		case 'S':
 			cmd.S = value
+		case 'T':
+			cmd.T = int(value)
 		}
 	}
 
@@ -374,20 +433,54 @@ func (p *GCodeParser) calculateNewE(cmd GCodeCommand) float64 {
 // determinePathType determines the type of extrusion path
 func (p *GCodeParser) determinePathType(cmd GCodeCommand, extrusionAmount float64) PathType {
 	// Use comment hints if available
-	comment := strings.ToLower(cmd.Comment)
+	if pathType, ok := featurePathType(cmd.Comment); ok {
+		return pathType
+	}
+
+	// Fall back to the current feature, or generic extrusion
+	return p.currentFeature
+}
+
+// featurePathType maps a slicer feature name to a path type
+func featurePathType(feature string) (PathType, bool) {
+	feature = strings.ToLower(feature)
 	
-	if strings.Contains(comment, "perimeter") || strings.Contains(comment, "outer") {
-		return PathTypePerimeter
+	if strings.Contains(feature, "perimeter") || strings.Contains(feature, "outer") || strings.Contains(feature, "wall") {
+		return PathTypePerimeter, true
+	}
+	if strings.Contains(feature, "infill") || strings.Contains(feature, "fill") || strings.Contains(feature, "skin") {
+		return PathTypeInfill, true
 	}
-	if strings.Contains(comment, "infill") || strings.Contains(comment, "fill") {
-		return PathTypeInfill
+	if strings.Contains(feature, "support") {
+		return PathTypeSupport, true
+	}
+	return PathTypeExtrusion, false
+}
+
+// trackFeature follows the ;TYPE: (Cura, PrusaSlicer) and ; FEATURE: (Bambu
+// Studio, OrcaSlicer) comments that announce the feature of the next moves
+func (p *GCodeParser) trackFeature(cmd GCodeCommand) {
+	if cmd.Type != "" {
+		return
 	}
-	if strings.Contains(comment, "support") {
-		return PathTypeSupport
+	upper := strings.ToUpper(cmd.Comment)
+	for _, prefix := range []string{"TYPE:", "FEATURE:"} {
+		if strings.HasPrefix(upper, prefix) {
+			p.currentFeature, _ = featurePathType(cmd.Comment[len(prefix):])
+			return
+		}
 	}
+}
 
-	// Fall back to generic extrusion
-	return PathTypeExtrusion
+// metadataPatterns are common slicer metadata patterns, compiled once
+// since every comment line is checked
+var metadataPatterns = map[string]*regexp.Regexp{
+	"generated_by":     regexp.MustCompile(`generated by (.+)`),
+	"layer_height":     regexp.MustCompile(`layer_height = ([0-9.]+)`),
+	"infill_density":   regexp.MustCompile(`fill_density = ([0-9.]+)`),
+	"print_speed":      regexp.MustCompile(`perimeter_speed = ([0-9.]+)`),
+	"estimated_time":   regexp.MustCompile(`estimated printing time.*?([0-9]+)h ([0-9]+)m`),
+	"filament_used":    regexp.MustCompile(`filament used = ([0-9.]+)mm`),
 }
 
 // extractMetadata extracts metadata from comments
@@ -397,15 +490,7 @@ func (p *GCodeParser) extractMetadata(metadata *GCodeMetadata, cmd GCodeCommand)
 		return
 	}
 
-	// Common slicer metadata patterns
-	patterns := map[string]*regexp.Regexp{
-		"generated_by":     regexp.MustCompile(`generated by (.+)`),
-		"layer_height":     regexp.MustCompile(`layer_height = ([0-9.]+)`),
-		"infill_density":   regexp.MustCompile(`fill_density = ([0-9.]+)`),
-		"print_speed":      regexp.MustCompile(`perimeter_speed = ([0-9.]+)`),
-		"estimated_time":   regexp.MustCompile(`estimated printing time.*?([0-9]+)h ([0-9]+)m`),
-		"filament_used":    regexp.MustCompile(`filament used = ([0-9.]+)mm`),
-	}
+	patterns := metadataPatterns
 
 	lowerComment := strings.ToLower(comment)
 
@@ -501,19 +586,31 @@ func (p *GCodeParser) finalizeMetadata(metadata *GCodeMetadata, model *GCodeMode
 	}
 	metadata.FilamentUsed = totalFilament
 
-	// Estimate print time based on path speeds and distances
+	// Estimate print time based on path speeds and distances, keeping a
+	// cumulative time table so remaining time can be read from any line
 	totalTime := 0.0
-	for _, path := range model.Paths {
-		distance := math.Sqrt(
-			math.Pow(path.EndX-path.StartX, 2) +
-				math.Pow(path.EndY-path.StartY, 2) +
-				math.Pow(path.EndZ-path.StartZ, 2),
-		)
+	model.TimeTable = make([]float64, len(model.Paths))
+	for i, path := range model.Paths {
 		if path.Speed > 0 {
-			totalTime += distance / (path.Speed / 60.0) // Convert mm/min to mm/s
+			totalTime += path.Length() / (path.Speed / 60.0) // Convert mm/min to mm/s
 		}
+		model.TimeTable[i] = totalTime
 	}
 	metadata.PrintTime = totalTime
+	
+	// Per-layer times from the time table
+	for i := range model.Layers {
+		layer := &model.Layers[i]
+		if len(layer.Paths) == 0 {
+			continue
+		}
+		first := layer.Paths[0]
+		last := layer.Paths[len(layer.Paths)-1]
+		layer.LayerTime = model.TimeTable[last]
+		if first > 0 {
+			layer.LayerTime -= model.TimeTable[first-1]
+		}
+	}
 
 	// Set first layer height from first layer if available
 	if len(model.Layers) > 0 {
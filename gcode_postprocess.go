@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// postProcessTag starts the comment written above every inserted block
+const postProcessTag = "; post-process: "
+
+// PostProcessPosition is where in the print a source line sits
+type PostProcessPosition struct {
+	LineNumber int     // Source line number
+	Layer      int     // 1-based layer number, 0 in the start and end G-code
+	Z          float64 // Height of Layer
+	LayerStart bool    // First line of Layer; edits for the layer go before it
+}
+
+// PostProcessStep is one composable edit applied line by line, so the same
+// steps can rewrite a whole file or a stream while it is being sent
+type PostProcessStep interface {
+	// Prepare resolves layer targets against the model and resets any state
+	Prepare(model *GCodeModel) error
+	// Process returns the lines to emit in place of one line
+	Process(line string, pos PostProcessPosition) []string
+	// Describe summarises the edit for the operator
+	Describe() string
+	// StartLayer returns the 1-based layer the edit starts at, 0 for whole-file edits
+	StartLayer() int
+}
+
+// LayerTarget selects a layer by number or by print height
+type LayerTarget struct {
+	Layer int     // 1-based layer number; 0 selects by Z
+	Z     float64 // Height in mm, the first layer at or above it is used
+}
+
+// resolve returns the 1-based layer number the target refers to
+func (t LayerTarget) resolve(model *GCodeModel) (int, error) {
+	if len(model.Layers) == 0 {
+		return 0, fmt.Errorf("file has no layers")
+	}
+	if t.Layer > 0 {
+		if t.Layer > len(model.Layers) {
+			return 0, fmt.Errorf("layer %d is beyond the last layer (%d)", t.Layer, len(model.Layers))
+		}
+		return t.Layer, nil
+	}
+	for i, layer := range model.Layers {
+		if layer.Z >= t.Z-0.001 {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("Z %.2f mm is above the last layer (%.2f mm)", t.Z, model.Layers[len(model.Layers)-1].Z)
+}
+
+// String formats the target as entered
+func (t LayerTarget) String() string {
+	if t.Layer > 0 {
+		return fmt.Sprintf("layer %d", t.Layer)
+	}
+	return fmt.Sprintf("Z %.2f mm", t.Z)
+}
+
+// layerLabel formats a resolved layer with its height
+func layerLabel(model *GCodeModel, layer int) string {
+	if model == nil || layer < 1 || layer > len(model.Layers) {
+		return fmt.Sprintf("layer %d", layer)
+	}
+	return fmt.Sprintf("layer %d (Z %.2f mm)", layer, model.Layers[layer-1].Z)
+}
+
+// PostProcessor applies a list of steps to the file a model was parsed from
+type PostProcessor struct {
+	model *GCodeModel
+	steps []PostProcessStep
+}
+
+// NewPostProcessor validates the steps against the model
+func NewPostProcessor(model *GCodeModel, steps ...PostProcessStep) (*PostProcessor, error) {
+	if model == nil {
+		return nil, fmt.Errorf("no G-code loaded")
+	}
+	pp := &PostProcessor{model: model, steps: steps}
+	if err := pp.prepare(); err != nil {
+		return nil, err
+	}
+	return pp, nil
+}
+
+// prepare readies every step for a new pass over the file
+func (pp *PostProcessor) prepare() error {
+	for _, step := range pp.steps {
+		if err := step.Prepare(pp.model); err != nil {
+			return fmt.Errorf("%s: %v", step.Describe(), err)
+		}
+	}
+	return nil
+}
+
+// Steps returns the steps in the order they are applied
+func (pp *PostProcessor) Steps() []PostProcessStep {
+	return pp.steps
+}
+
+// Process rewrites src into dst. src must be the file the model was parsed from.
+func (pp *PostProcessor) Process(src io.Reader, dst io.Writer) error {
+	if err := pp.prepare(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(src)
+	writer := bufio.NewWriter(dst)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		for _, line := range pp.processLine(lineNumber, scanner.Text()) {
+			if _, err := writer.WriteString(line + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if lineNumber != pp.model.TotalLines {
+		return fmt.Errorf("source has %d lines but the parsed file had %d", lineNumber, pp.model.TotalLines)
+	}
+
+	return writer.Flush()
+}
+
+// Reader streams the processed file as it is read, without buffering it
+func (pp *PostProcessor) Reader(src io.Reader) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(pp.Process(src, writer))
+	}()
+	return reader
+}
+
+// processLine runs one source line through every step in turn
+func (pp *PostProcessor) processLine(lineNumber int, line string) []string {
+	pos := pp.position(lineNumber)
+	lines := []string{line}
+
+	for _, step := range pp.steps {
+		next := make([]string, 0, len(lines))
+		for i, l := range lines {
+			// Lines inserted by earlier steps already sit at the layer start,
+			// so only the first one may trigger another insertion
+			linePos := pos
+			linePos.LayerStart = pos.LayerStart && i == 0
+			next = append(next, step.Process(l, linePos)...)
+		}
+		lines = next
+	}
+	return lines
+}
+
+// position locates a source line in the model's layers
+func (pp *PostProcessor) position(lineNumber int) PostProcessPosition {
+	pos := PostProcessPosition{LineNumber: lineNumber}
+	index := pp.model.LayerIndexForLine(lineNumber)
+	if index >= 0 {
+		layer := pp.model.Layers[index]
+		pos.Layer = index + 1
+		pos.Z = layer.Z
+		pos.LayerStart = lineNumber == layer.StartLine
+	}
+	return pos
+}
+
+// PauseStep pauses the print, or swaps filament, before a layer starts
+type PauseStep struct {
+	At             LayerTarget
+	FilamentChange bool   // M600 instead of a plain pause
+	Command        string // Overrides the pause command (e.g. PAUSE on Klipper)
+	Message        string // Shown on the printer display with M117
+
+	model *GCodeModel
+	layer int
+}
+
+// Prepare resolves the target layer
+func (s *PauseStep) Prepare(model *GCodeModel) error {
+	layer, err := s.At.resolve(model)
+	s.model, s.layer = model, layer
+	return err
+}
+
+// Process inserts the pause ahead of the target layer
+func (s *PauseStep) Process(line string, pos PostProcessPosition) []string {
+	if !pos.LayerStart || pos.Layer != s.layer {
+		return []string{line}
+	}
+
+	lines := []string{postProcessTag + s.Describe()}
+	if s.Message != "" {
+		lines = append(lines, "M117 "+s.Message)
+	}
+	return append(lines, s.command(), line)
+}
+
+// command returns the G-code that pauses the printer
+func (s *PauseStep) command() string {
+	switch {
+	case s.Command != "":
+		return s.Command
+	case s.FilamentChange:
+		return "M600"
+	default:
+		return "M601"
+	}
+}
+
+// Describe summarises the pause
+func (s *PauseStep) Describe() string {
+	action := "Pause"
+	if s.FilamentChange {
+		action = "Filament change"
+	}
+	if s.layer > 0 {
+		return fmt.Sprintf("%s at %s", action, layerLabel(s.model, s.layer))
+	}
+	return fmt.Sprintf("%s at %s", action, s.At)
+}
+
+// StartLayer returns the layer the pause happens before
+func (s *PauseStep) StartLayer() int {
+	return s.layer
+}
+
+// LayerSetting is a print setting that can be overridden for a layer range
+type LayerSetting int
+
+const (
+	LayerSettingTemperature LayerSetting = iota // Hotend target in °C
+	LayerSettingFan                             // Part cooling fan in percent
+	LayerSettingSpeed                           // Feed rate override in percent
+)
+
+// LayerSettingNames for display
+var LayerSettingNames = map[LayerSetting]string{
+	LayerSettingTemperature: "Temperature",
+	LayerSettingFan:         "Fan",
+	LayerSettingSpeed:       "Speed",
+}
+
+// layerSettingUnits for display
+var layerSettingUnits = map[LayerSetting]string{
+	LayerSettingTemperature: "°C",
+	LayerSettingFan:         "%",
+	LayerSettingSpeed:       "%",
+}
+
+// LayerRangeStep overrides a setting from one layer to another. Commands in
+// the file that set the same value inside the range are removed, and the
+// file's own value is restored after the range. Commands switching the
+// heater or fan off are kept, as the end G-code, which the parser counts
+// as part of the last layer, relies on them.
+type LayerRangeStep struct {
+	Setting LayerSetting
+	Value   float64
+	From    LayerTarget
+	To      LayerTarget // Zero value runs to the last layer
+
+	handOver  bool // The next step takes over after the range, so nothing is restored
+	model     *GCodeModel
+	parser    *GCodeParser
+	from, to  int
+	fileValue float64 // Last value set by the file itself
+	fileSet   bool
+}
+
+// Prepare resolves the layer range
+func (s *LayerRangeStep) Prepare(model *GCodeModel) error {
+	s.model, s.parser = model, NewGCodeParser()
+	s.from, s.to = 0, 0
+	s.fileValue, s.fileSet = 0, false
+
+	from, err := s.From.resolve(model)
+	if err != nil {
+		return err
+	}
+	to := len(model.Layers)
+	if s.To != (LayerTarget{}) {
+		if to, err = s.To.resolve(model); err != nil {
+			return err
+		}
+	}
+	if to < from {
+		return fmt.Errorf("range ends at layer %d before it starts at layer %d", to, from)
+	}
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	s.from, s.to = from, to
+	return nil
+}
+
+// validate checks the value is sensible for the setting
+func (s *LayerRangeStep) validate() error {
+	switch s.Setting {
+	case LayerSettingTemperature:
+		if s.Value < 0 || s.Value > 350 {
+			return fmt.Errorf("temperature %.0f°C is out of range", s.Value)
+		}
+	case LayerSettingFan:
+		if s.Value < 0 || s.Value > 100 {
+			return fmt.Errorf("fan speed %.0f%% is out of range", s.Value)
+		}
+	case LayerSettingSpeed:
+		if s.Value < 10 || s.Value > 500 {
+			return fmt.Errorf("speed %.0f%% is out of range", s.Value)
+		}
+	}
+	return nil
+}
+
+// Process applies the override at the range start, drops conflicting
+// commands inside it and restores the file's value after it
+func (s *LayerRangeStep) Process(line string, pos PostProcessPosition) []string {
+	inRange := pos.Layer >= s.from && pos.Layer <= s.to
+	lines := []string{}
+
+	if pos.LayerStart && pos.Layer == s.from {
+		lines = append(lines, postProcessTag+s.Describe(), s.command(s.Value))
+	}
+	if pos.LayerStart && pos.Layer == s.to+1 && !s.handOver {
+		lines = append(lines, postProcessTag+"restore "+strings.ToLower(LayerSettingNames[s.Setting]))
+		lines = append(lines, s.command(s.restoreValue()))
+	}
+
+	if value, ok := s.parseSetting(line); ok {
+		s.fileValue, s.fileSet = value, true
+		if inRange && !s.switchesOff(value) {
+			return append(lines, postProcessTag+"removed "+strings.TrimSpace(line))
+		}
+	}
+	return append(lines, line)
+}
+
+// parseSetting returns the value a line sets for this step's setting
+func (s *LayerRangeStep) parseSetting(line string) (float64, bool) {
+	cmd := s.parser.parseLine(strings.TrimSpace(line), 0)
+	if !cmd.IsValid {
+		return 0, false
+	}
+
+	switch s.Setting {
+	case LayerSettingTemperature:
+		// Leave commands for other tools alone
+		if (cmd.Type == "M104" || cmd.Type == "M109") && cmd.T < 0 && !math.IsNaN(cmd.S) {
+			return cmd.S, true
+		}
+	case LayerSettingFan:
+		switch cmd.Type {
+		case "M106":
+			if math.IsNaN(cmd.S) {
+				return 100, true
+			}
+			return cmd.S / 255 * 100, true
+		case "M107":
+			return 0, true
+		}
+	case LayerSettingSpeed:
+		if cmd.Type == "M220" && !math.IsNaN(cmd.S) {
+			return cmd.S, true
+		}
+	}
+	return 0, false
+}
+
+// switchesOff reports whether a value turns the heater or fan off
+func (s *LayerRangeStep) switchesOff(value float64) bool {
+	return value <= 0 && s.Setting != LayerSettingSpeed
+}
+
+// restoreValue returns the value in effect in the file after the range
+func (s *LayerRangeStep) restoreValue() float64 {
+	if s.fileSet {
+		return s.fileValue
+	}
+	switch s.Setting {
+	case LayerSettingSpeed:
+		return 100
+	default:
+		return 0
+	}
+}
+
+// command returns the G-code that sets the value
+func (s *LayerRangeStep) command(value float64) string {
+	switch s.Setting {
+	case LayerSettingFan:
+		if value <= 0 {
+			return "M107"
+		}
+		return fmt.Sprintf("M106 S%.0f", math.Min(255, value*255/100))
+	case LayerSettingSpeed:
+		return fmt.Sprintf("M220 S%.0f", value)
+	default:
+		return fmt.Sprintf("M104 S%.0f", value)
+	}
+}
+
+// Describe summarises the override
+func (s *LayerRangeStep) Describe() string {
+	setting := fmt.Sprintf("%s %.0f%s", LayerSettingNames[s.Setting], s.Value, layerSettingUnits[s.Setting])
+	if s.from > 0 {
+		return fmt.Sprintf("%s for layers %d-%d", setting, s.from, s.to)
+	}
+	if s.To == (LayerTarget{}) {
+		return fmt.Sprintf("%s from %s", setting, s.From)
+	}
+	return fmt.Sprintf("%s from %s to %s", setting, s.From, s.To)
+}
+
+// StartLayer returns the first layer of the range
+func (s *LayerRangeStep) StartLayer() int {
+	return s.from
+}
+
+// TemperatureTowerSteps steps the hotend temperature in bands of layers,
+// starting at startLayer (1-based) and changing by step each band
+func TemperatureTowerSteps(startLayer, layersPerBand, bands int, startTemp, step float64) []PostProcessStep {
+	steps := make([]PostProcessStep, 0, bands)
+	for band := 0; band < bands; band++ {
+		from := startLayer + band*layersPerBand
+		steps = append(steps, &LayerRangeStep{
+			Setting:  LayerSettingTemperature,
+			Value:    startTemp + float64(band)*step,
+			From:     LayerTarget{Layer: from},
+			To:       LayerTarget{Layer: from + layersPerBand - 1},
+			handOver: band < bands-1, // The next band sets its own temperature
+		})
+	}
+	return steps
+}
+
+// PrimeLineStep draws an extra purge line before the first layer
+type PrimeLineStep struct {
+	X, Y     float64 // Start of the line
+	Length   float64 // Line length along +X in mm
+	Width    float64 // Line width in mm
+	Height   float64 // Line height in mm, 0 for the first layer height
+	FeedRate float64 // Extrusion feed rate in mm/min
+
+	state  GCodeMachineState
+	height float64
+}
+
+// Prepare captures the machine state the first layer expects
+func (s *PrimeLineStep) Prepare(model *GCodeModel) error {
+	if len(model.Layers) == 0 {
+		return fmt.Errorf("file has no layers")
+	}
+	if s.Length <= 0 || s.Width <= 0 || s.Height < 0 {
+		return fmt.Errorf("length and width must be positive")
+	}
+	s.state = model.MachineStateAt(model.Layers[0].StartLine - 1)
+	s.height = s.Height
+	if s.height == 0 {
+		s.height = model.Layers[0].Z
+	}
+	return nil
+}
+
+// Process inserts the line ahead of the first layer and puts the extruder
+// and positioning modes back the way the file left them. The nozzle stays at
+// the first layer height so the inserted moves do not add a layer.
+func (s *PrimeLineStep) Process(line string, pos PostProcessPosition) []string {
+	if !pos.LayerStart || pos.Layer != 1 {
+		return []string{line}
+	}
+
+	feedRate := s.FeedRate
+	if feedRate <= 0 {
+		feedRate = 1200
+	}
+	filamentArea := math.Pi * math.Pow(defaultFilamentDiameter/2, 2)
+	extrusion := s.Length * s.Width * s.height / filamentArea
+
+	lines := []string{postProcessTag + s.Describe()}
+	if !s.state.AbsolutePositioning {
+		lines = append(lines, "G90")
+	}
+	lines = append(lines,
+		fmt.Sprintf("G1 Z%.3f F600", s.height),
+		fmt.Sprintf("G0 X%.3f Y%.3f F6000", s.X, s.Y),
+	)
+	if s.state.AbsoluteExtrusion {
+		lines = append(lines,
+			"G92 E0",
+			fmt.Sprintf("G1 X%.3f E%.5f F%.0f", s.X+s.Length, extrusion, feedRate),
+			fmt.Sprintf("G92 E%.5f", s.state.E),
+		)
+	} else {
+		lines = append(lines, fmt.Sprintf("G1 X%.3f E%.5f F%.0f", s.X+s.Length, extrusion, feedRate))
+	}
+	lines = append(lines, fmt.Sprintf("G1 F%.0f", s.state.FeedRate))
+	if !s.state.AbsolutePositioning {
+		lines = append(lines, "G91")
+	}
+	return append(lines, line)
+}
+
+// Describe summarises the prime line
+func (s *PrimeLineStep) Describe() string {
+	return fmt.Sprintf("Prime line %.0f mm at X%.0f Y%.0f", s.Length, s.X, s.Y)
+}
+
+// StartLayer returns the first layer
+func (s *PrimeLineStep) StartLayer() int {
+	return 1
+}
+
+// structureComments are kept by StripCommentsStep when KeepStructure is set
+var structureComments = []string{
+	"LAYER", "TYPE:", "MESH:", "Z:", "HEIGHT:",
+	"printing object", "stop printing object",
+}
+
+// StripCommentsStep removes comments and blank lines to shrink the file
+type StripCommentsStep struct {
+	KeepStructure bool // Keep layer, feature and object comments
+}
+
+// Prepare has nothing to resolve
+func (s *StripCommentsStep) Prepare(model *GCodeModel) error {
+	return nil
+}
+
+// Process drops comment-only lines and trailing comments
+func (s *StripCommentsStep) Process(line string, pos PostProcessPosition) []string {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return nil
+	}
+
+	index := strings.Index(trimmed, ";")
+	if index < 0 {
+		return []string{line}
+	}
+	if index == 0 {
+		if s.KeepStructure && isStructureComment(trimmed[1:]) {
+			return []string{trimmed}
+		}
+		return nil
+	}
+	return []string{strings.TrimSpace(trimmed[:index])}
+}
+
+// isStructureComment reports whether a comment carries layer or object structure
+func isStructureComment(comment string) bool {
+	comment = strings.TrimSpace(comment)
+	for _, prefix := range structureComments {
+		if strings.HasPrefix(comment, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe summarises the step
+func (s *StripCommentsStep) Describe() string {
+	if s.KeepStructure {
+		return "Strip comments (keep layer and object markers)"
+	}
+	return "Strip comments"
+}
+
+// StartLayer returns 0, the step applies to the whole file
+func (s *StripCommentsStep) StartLayer() int {
+	return 0
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// postProcessFixture has four layers at Z 0.2-0.8, a temperature change in
+// layer 2 and end G-code switching the hotend and fan off
+const postProcessFixture = `; generated for tests
+G90
+M82
+M104 S210
+G28
+;LAYER:0
+G1 Z0.2 F600
+G1 X10 Y10 E1 F1200 ; skirt
+
+M106 S255
+;LAYER:1
+G1 Z0.4
+G1 X20 Y10 E2
+M104 S215
+;LAYER:2
+G1 Z0.6
+G1 X30 Y10 E3
+;LAYER:3
+G1 Z0.8
+G1 X40 Y10 E4
+M104 S0
+M107
+`
+
+// postProcessed runs the fixture through steps
+func postProcessed(t *testing.T, steps ...PostProcessStep) string {
+	t.Helper()
+	model, err := NewGCodeParser().ParseGCode(strings.NewReader(postProcessFixture))
+	if err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+	pp, err := NewPostProcessor(model, steps...)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	var out bytes.Buffer
+	if err := pp.Process(strings.NewReader(postProcessFixture), &out); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	return out.String()
+}
+
+// insertBefore returns the fixture with lines inserted before a source line
+// (1-based) and other source lines replaced, as a step should emit it
+func insertBefore(edits map[int][]string, replace map[int]string) string {
+	var out strings.Builder
+	for i, line := range strings.Split(strings.TrimSuffix(postProcessFixture, "\n"), "\n") {
+		for _, inserted := range edits[i+1] {
+			out.WriteString(inserted + "\n")
+		}
+		if replacement, ok := replace[i+1]; ok {
+			line = replacement
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+func TestPostProcessSteps(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []PostProcessStep
+		want  string
+	}{
+		{
+			name:  "pause with message",
+			steps: []PostProcessStep{&PauseStep{At: LayerTarget{Layer: 2}, Message: "Insert magnet"}},
+			want: insertBefore(map[int][]string{12: {
+				"; post-process: Pause at layer 2 (Z 0.40 mm)",
+				"M117 Insert magnet",
+				"M601",
+			}}, nil),
+		},
+		{
+			name:  "filament change by height",
+			steps: []PostProcessStep{&PauseStep{At: LayerTarget{Z: 0.6}, FilamentChange: true}},
+			want: insertBefore(map[int][]string{16: {
+				"; post-process: Filament change at layer 3 (Z 0.60 mm)",
+				"M600",
+			}}, nil),
+		},
+		{
+			name:  "custom pause command",
+			steps: []PostProcessStep{&PauseStep{At: LayerTarget{Layer: 4}, Command: "PAUSE"}},
+			want: insertBefore(map[int][]string{19: {
+				"; post-process: Pause at layer 4 (Z 0.80 mm)",
+				"PAUSE",
+			}}, nil),
+		},
+		{
+			name: "temperature range removes and restores",
+			steps: []PostProcessStep{&LayerRangeStep{
+				Setting: LayerSettingTemperature, Value: 230,
+				From: LayerTarget{Layer: 2}, To: LayerTarget{Layer: 3},
+			}},
+			want: insertBefore(map[int][]string{
+				12: {"; post-process: Temperature 230°C for layers 2-3", "M104 S230"},
+				19: {"; post-process: restore temperature", "M104 S215"},
+			}, map[int]string{14: "; post-process: removed M104 S215"}),
+		},
+		{
+			name: "temperature to the end keeps the heater off command",
+			steps: []PostProcessStep{&LayerRangeStep{
+				Setting: LayerSettingTemperature, Value: 240, From: LayerTarget{Layer: 3},
+			}},
+			want: insertBefore(map[int][]string{
+				16: {"; post-process: Temperature 240°C for layers 3-4", "M104 S240"},
+			}, nil),
+		},
+		{
+			name: "fan from a height keeps the fan off command",
+			steps: []PostProcessStep{&LayerRangeStep{
+				Setting: LayerSettingFan, Value: 50, From: LayerTarget{Z: 0.4},
+			}},
+			want: insertBefore(map[int][]string{
+				12: {"; post-process: Fan 50% for layers 2-4", "M106 S128"},
+			}, nil),
+		},
+		{
+			name: "speed range restores 100%",
+			steps: []PostProcessStep{&LayerRangeStep{
+				Setting: LayerSettingSpeed, Value: 50,
+				From: LayerTarget{Layer: 1}, To: LayerTarget{Layer: 1},
+			}},
+			want: insertBefore(map[int][]string{
+				7:  {"; post-process: Speed 50% for layers 1-1", "M220 S50"},
+				12: {"; post-process: restore speed", "M220 S100"},
+			}, nil),
+		},
+		{
+			name:  "temperature tower",
+			steps: TemperatureTowerSteps(2, 1, 3, 220, -5),
+			want: insertBefore(map[int][]string{
+				12: {"; post-process: Temperature 220°C for layers 2-2", "M104 S220"},
+				16: {"; post-process: Temperature 215°C for layers 3-3", "M104 S215"},
+				19: {"; post-process: Temperature 210°C for layers 4-4", "M104 S210"},
+			}, map[int]string{14: "; post-process: removed M104 S215"}),
+		},
+		{
+			name:  "prime line",
+			steps: []PostProcessStep{&PrimeLineStep{X: 5, Y: 5, Length: 50, Width: 0.4}},
+			want: insertBefore(map[int][]string{7: {
+				"; post-process: Prime line 50 mm at X5 Y5",
+				"G1 Z0.200 F600",
+				"G0 X5.000 Y5.000 F6000",
+				"G92 E0",
+				"G1 X55.000 E1.66301 F1200",
+				"G92 E0.00000",
+				"G1 F1500",
+			}}, nil),
+		},
+		{
+			name:  "strip comments",
+			steps: []PostProcessStep{&StripCommentsStep{}},
+			want: strings.Join([]string{
+				"G90", "M82", "M104 S210", "G28",
+				"G1 Z0.2 F600", "G1 X10 Y10 E1 F1200", "M106 S255",
+				"G1 Z0.4", "G1 X20 Y10 E2", "M104 S215",
+				"G1 Z0.6", "G1 X30 Y10 E3",
+				"G1 Z0.8", "G1 X40 Y10 E4", "M104 S0", "M107",
+			}, "\n") + "\n",
+		},
+		{
+			name:  "strip comments keeping structure",
+			steps: []PostProcessStep{&StripCommentsStep{KeepStructure: true}},
+			want: strings.Join([]string{
+				"G90", "M82", "M104 S210", "G28",
+				";LAYER:0", "G1 Z0.2 F600", "G1 X10 Y10 E1 F1200", "M106 S255",
+				";LAYER:1", "G1 Z0.4", "G1 X20 Y10 E2", "M104 S215",
+				";LAYER:2", "G1 Z0.6", "G1 X30 Y10 E3",
+				";LAYER:3", "G1 Z0.8", "G1 X40 Y10 E4", "M104 S0", "M107",
+			}, "\n") + "\n",
+		},
+		{
+			name: "steps compose in order",
+			steps: []PostProcessStep{
+				&PauseStep{At: LayerTarget{Layer: 2}},
+				&StripCommentsStep{},
+			},
+			want: strings.Join([]string{
+				"G90", "M82", "M104 S210", "G28",
+				"G1 Z0.2 F600", "G1 X10 Y10 E1 F1200", "M106 S255",
+				"M601", "G1 Z0.4", "G1 X20 Y10 E2", "M104 S215",
+				"G1 Z0.6", "G1 X30 Y10 E3",
+				"G1 Z0.8", "G1 X40 Y10 E4", "M104 S0", "M107",
+			}, "\n") + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := postProcessed(t, test.steps...); got != test.want {
+				t.Errorf("output differs\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPostProcessRejectsBadTargets(t *testing.T) {
+	model, err := NewGCodeParser().ParseGCode(strings.NewReader(postProcessFixture))
+	if err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+
+	steps := []PostProcessStep{
+		&PauseStep{At: LayerTarget{Layer: 5}},
+		&PauseStep{At: LayerTarget{Z: 2}},
+		&LayerRangeStep{Setting: LayerSettingTemperature, Value: 400, From: LayerTarget{Layer: 1}},
+		&LayerRangeStep{Setting: LayerSettingFan, Value: 50, From: LayerTarget{Layer: 3}, To: LayerTarget{Layer: 2}},
+		&PrimeLineStep{X: 5, Y: 5},
+	}
+	for _, step := range steps {
+		if _, err := NewPostProcessor(model, step); err == nil {
+			t.Errorf("%s: expected an error", step.Describe())
+		}
+	}
+}
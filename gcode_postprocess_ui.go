@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Post-processing step kinds offered in the UI
+const (
+	postProcessPause       = "Pause"
+	postProcessFilament    = "Filament Change"
+	postProcessTemperature = "Temperature"
+	postProcessFan         = "Fan"
+	postProcessSpeed       = "Speed"
+	postProcessTower       = "Temperature Tower"
+	postProcessPrimeLine   = "Prime Line"
+	postProcessStrip       = "Strip Comments"
+)
+
+// postProcessKinds lists the step kinds in menu order
+var postProcessKinds = []string{
+	postProcessPause,
+	postProcessFilament,
+	postProcessTemperature,
+	postProcessFan,
+	postProcessSpeed,
+	postProcessTower,
+	postProcessPrimeLine,
+	postProcessStrip,
+}
+
+// createPostProcessControls creates the card for building and applying G-code edits
+func (ui *GCodeViewerUI) createPostProcessControls() *widget.Card {
+	kindSelect := widget.NewSelect(postProcessKinds, nil)
+	kindSelect.SetSelected(postProcessPause)
+
+	addBtn := widget.NewButton("Add", func() {
+		ui.showAddStepDialog(kindSelect.Selected)
+	})
+
+	ui.postProcessList = container.NewVBox()
+	ui.postProcessStatus = widget.NewLabel("No edits")
+	ui.postProcessStatus.Wrapping = fyne.TextWrapWord
+
+	ui.postProcessPreview = widget.NewButton("Preview", func() {
+		if ui.previewKind == previewEdits {
+			ui.discardPreview()
+		} else {
+			ui.previewPostProcess()
+		}
+	})
+
+	uploadBtn := widget.NewButton("Upload Copy", func() {
+		ui.uploadPostProcessed(false)
+	})
+	printBtn := widget.NewButton("Print Edited", func() {
+		ui.uploadPostProcessed(true)
+	})
+	printBtn.Importance = widget.HighImportance
+
+	return widget.NewCard("G-code Edits", "", container.NewVBox(
+		container.NewBorder(nil, nil, nil, addBtn, kindSelect),
+		ui.postProcessList,
+		ui.postProcessStatus,
+		ui.postProcessPreview,
+		container.NewGridWithColumns(2, uploadBtn, printBtn),
+	))
+}
+
+// newPostProcessor builds a processor for the current steps
+func (ui *GCodeViewerUI) newPostProcessor() (*PostProcessor, error) {
+	if len(ui.postProcessSteps) == 0 {
+		return nil, fmt.Errorf("add at least one edit first")
+	}
+	if ui.currentFile == "" {
+		return nil, fmt.Errorf("no G-code file loaded")
+	}
+	return NewPostProcessor(ui.sourceModel(), ui.postProcessSteps...)
+}
+
+// addPostProcessStep validates a step against the loaded file and appends it
+func (ui *GCodeViewerUI) addPostProcessStep(steps ...PostProcessStep) {
+	if _, err := NewPostProcessor(ui.sourceModel(), steps...); err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	ui.postProcessSteps = append(ui.postProcessSteps, steps...)
+	ui.postProcessChanged()
+}
+
+// removePostProcessStep removes a step by position
+func (ui *GCodeViewerUI) removePostProcessStep(index int) {
+	if index < 0 || index >= len(ui.postProcessSteps) {
+		return
+	}
+	ui.postProcessSteps = append(ui.postProcessSteps[:index], ui.postProcessSteps[index+1:]...)
+	ui.postProcessChanged()
+}
+
+// postProcessChanged rebuilds the step list and refreshes a shown preview
+func (ui *GCodeViewerUI) postProcessChanged() {
+	ui.refreshPostProcessList()
+	if ui.previewKind == previewEdits {
+		if len(ui.postProcessSteps) == 0 {
+			ui.discardPreview()
+		} else {
+			ui.previewPostProcess()
+		}
+	}
+}
+
+// refreshPostProcessList rebuilds the rows of the step list
+func (ui *GCodeViewerUI) refreshPostProcessList() {
+	ui.postProcessList.Objects = nil
+	for i, step := range ui.postProcessSteps {
+		index := i
+		label := widget.NewLabel(fmt.Sprintf("%d. %s", i+1, step.Describe()))
+		label.Wrapping = fyne.TextWrapWord
+
+		removeBtn := widget.NewButton("✕", func() {
+			ui.removePostProcessStep(index)
+		})
+
+		buttons := container.NewHBox(removeBtn)
+		if layer := step.StartLayer(); layer > 0 {
+			showBtn := widget.NewButton("Show", func() {
+				ui.showPostProcessLayer(layer)
+			})
+			buttons = container.NewHBox(showBtn, removeBtn)
+		}
+
+		ui.postProcessList.Add(container.NewBorder(nil, nil, nil, buttons, label))
+	}
+	ui.postProcessList.Refresh()
+	ui.updatePostProcessStatus()
+}
+
+// updatePostProcessStatus describes whether a preview is shown
+func (ui *GCodeViewerUI) updatePostProcessStatus() {
+	switch {
+	case len(ui.postProcessSteps) == 0:
+		ui.postProcessStatus.SetText("No edits")
+	case ui.previewKind == previewEdits:
+		ui.postProcessStatus.SetText(fmt.Sprintf("Previewing %d edits", len(ui.postProcessSteps)))
+	default:
+		ui.postProcessStatus.SetText(fmt.Sprintf("%d edits, not applied", len(ui.postProcessSteps)))
+	}
+
+	if ui.previewKind == previewEdits {
+		ui.postProcessPreview.SetText("Discard Preview")
+	} else {
+		ui.postProcessPreview.SetText("Preview")
+	}
+}
+
+// showPostProcessLayer jumps the viewer to the layer an edit applies to
+func (ui *GCodeViewerUI) showPostProcessLayer(layer int) {
+	if ui.model == nil || layer < 1 || layer > len(ui.model.Layers) {
+		return
+	}
+	ui.layerSlider.SetValue(float64(layer - 1))
+	ui.setLayerRange(0, layer-1)
+}
+
+// previewPostProcess runs the edits and shows the result in the viewer
+func (ui *GCodeViewerUI) previewPostProcess() {
+	processor, err := ui.newPostProcessor()
+	if err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+
+	progressDialog := dialog.NewProgressInfinite("G-code Edits", "Applying edits...", ui.window)
+	progressDialog.Show()
+
+	go func() {
+		model, err := ui.runPostProcessor(processor)
+		progressDialog.Hide()
+
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to apply edits: %v", err), ui.window)
+			return
+		}
+
+		ui.showPreview(previewEdits, model)
+	}()
+}
+
+// runPostProcessor processes the current file in memory and parses the result
+func (ui *GCodeViewerUI) runPostProcessor(processor *PostProcessor) (*GCodeModel, error) {
+	file, err := OpenGCodeFile(ui.currentFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var output bytes.Buffer
+	if err := processor.Process(file, &output); err != nil {
+		return nil, err
+	}
+	return NewGCodeParser().ParseGCode(&output)
+}
+
+// uploadPostProcessed streams the edited file to the printer under a new
+// name, optionally starting the print once it is uploaded
+func (ui *GCodeViewerUI) uploadPostProcessed(startPrint bool) {
+	if ui.backend == nil {
+		dialog.ShowError(fmt.Errorf("not connected to a printer"), ui.window)
+		return
+	}
+	processor, err := ui.newPostProcessor()
+	if err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(ui.exportBaseName() + "_edited.gcode")
+
+	title, confirm := "Upload Copy", "Upload"
+	if startPrint {
+		title, confirm = "Print Edited", "Print"
+	}
+
+	dialog.ShowForm(title, confirm, "Cancel", []*widget.FormItem{
+		widget.NewFormItem("File name", nameEntry),
+	}, func(confirmed bool) {
+		name := strings.TrimSpace(nameEntry.Text)
+		if !confirmed || name == "" {
+			return
+		}
+
+		progressDialog := dialog.NewProgressInfinite(title, "Uploading "+name+"...", ui.window)
+		progressDialog.Show()
+
+		go func() {
+			err := ui.streamPostProcessed(processor, name)
+			started := true
+			if err == nil && startPrint {
+				started, err = ui.startPrint(name)
+			}
+			progressDialog.Hide()
+
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to send %s: %v", name, err), ui.window)
+				return
+			}
+			if !started {
+				dialog.ShowInformation(title, fmt.Sprintf("%s is waiting for staff to approve it", name), ui.window)
+				return
+			}
+			dialog.ShowInformation(title, fmt.Sprintf("%s sent to the printer", name), ui.window)
+		}()
+	}, ui.window)
+}
+
+// streamPostProcessed applies the edits while the file is being uploaded
+func (ui *GCodeViewerUI) streamPostProcessed(processor *PostProcessor, name string) error {
+	file, err := OpenGCodeFile(ui.currentFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	edited := processor.Reader(file)
+	defer edited.Close()
+
+	return ui.backend.UploadStream(name, edited)
+}
+
+// showAddStepDialog asks for the parameters of a new step
+func (ui *GCodeViewerUI) showAddStepDialog(kind string) {
+	if ui.sourceModel() == nil {
+		dialog.ShowError(fmt.Errorf("no G-code loaded"), ui.window)
+		return
+	}
+
+	currentLayer := strconv.Itoa(ui.viewer.currentLayer + 1)
+	entry := func(value string) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetText(value)
+		return e
+	}
+
+	var items []*widget.FormItem
+	var build func() ([]PostProcessStep, error)
+
+	switch kind {
+	case postProcessPause, postProcessFilament:
+		layer, z, message := entry(currentLayer), entry(""), entry("")
+		z.SetPlaceHolder("used when layer is empty")
+		items = []*widget.FormItem{
+			widget.NewFormItem("Layer", layer),
+			widget.NewFormItem("or Z (mm)", z),
+			widget.NewFormItem("Message", message),
+		}
+		build = func() ([]PostProcessStep, error) {
+			target, err := parseLayerTarget(layer.Text, z.Text)
+			if err != nil {
+				return nil, err
+			}
+			return []PostProcessStep{&PauseStep{
+				At:             target,
+				FilamentChange: kind == postProcessFilament,
+				Message:        strings.TrimSpace(message.Text),
+			}}, nil
+		}
+
+	case postProcessTemperature, postProcessFan, postProcessSpeed:
+		setting := map[string]LayerSetting{
+			postProcessTemperature: LayerSettingTemperature,
+			postProcessFan:         LayerSettingFan,
+			postProcessSpeed:       LayerSettingSpeed,
+		}[kind]
+		value, from, to := entry(""), entry(currentLayer), entry("")
+		to.SetPlaceHolder("last layer")
+		items = []*widget.FormItem{
+			widget.NewFormItem(fmt.Sprintf("Value (%s)", layerSettingUnits[setting]), value),
+			widget.NewFormItem("From layer", from),
+			widget.NewFormItem("To layer", to),
+		}
+		build = func() ([]PostProcessStep, error) {
+			v, err := parseFormFloat("value", value.Text)
+			if err != nil {
+				return nil, err
+			}
+			fromLayer, err := parseFormInt("from layer", from.Text)
+			if err != nil {
+				return nil, err
+			}
+			step := &LayerRangeStep{Setting: setting, Value: v, From: LayerTarget{Layer: fromLayer}}
+			if strings.TrimSpace(to.Text) != "" {
+				toLayer, err := parseFormInt("to layer", to.Text)
+				if err != nil {
+					return nil, err
+				}
+				step.To = LayerTarget{Layer: toLayer}
+			}
+			return []PostProcessStep{step}, nil
+		}
+
+	case postProcessTower:
+		start, perBand, bands := entry(currentLayer), entry("25"), entry("5")
+		startTemp, step := entry("230"), entry("-5")
+		items = []*widget.FormItem{
+			widget.NewFormItem("Start layer", start),
+			widget.NewFormItem("Layers per band", perBand),
+			widget.NewFormItem("Bands", bands),
+			widget.NewFormItem("Start (°C)", startTemp),
+			widget.NewFormItem("Step (°C)", step),
+		}
+		build = func() ([]PostProcessStep, error) {
+			values := make([]int, 3)
+			for i, field := range []struct{ name, text string }{
+				{"start layer", start.Text}, {"layers per band", perBand.Text}, {"bands", bands.Text},
+			} {
+				v, err := parseFormInt(field.name, field.text)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = v
+			}
+			temp, err := parseFormFloat("start temperature", startTemp.Text)
+			if err != nil {
+				return nil, err
+			}
+			delta, err := parseFormFloat("step", step.Text)
+			if err != nil {
+				return nil, err
+			}
+			return TemperatureTowerSteps(values[0], values[1], values[2], temp, delta), nil
+		}
+
+	case postProcessPrimeLine:
+		x, y, length := entry("5"), entry("5"), entry("100")
+		items = []*widget.FormItem{
+			widget.NewFormItem("Start X", x),
+			widget.NewFormItem("Start Y", y),
+			widget.NewFormItem("Length (mm)", length),
+		}
+		build = func() ([]PostProcessStep, error) {
+			values := make([]float64, 3)
+			for i, field := range []struct{ name, text string }{
+				{"X", x.Text}, {"Y", y.Text}, {"length", length.Text},
+			} {
+				v, err := parseFormFloat(field.name, field.text)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = v
+			}
+			return []PostProcessStep{&PrimeLineStep{
+				X: values[0], Y: values[1], Length: values[2],
+				Width: 0.8, FeedRate: 1200,
+			}}, nil
+		}
+
+	case postProcessStrip:
+		keep := widget.NewCheck("", nil)
+		keep.SetChecked(true)
+		items = []*widget.FormItem{
+			widget.NewFormItem("Keep layer markers", keep),
+		}
+		build = func() ([]PostProcessStep, error) {
+			return []PostProcessStep{&StripCommentsStep{KeepStructure: keep.Checked}}, nil
+		}
+
+	default:
+		return
+	}
+
+	dialog.ShowForm(kind, "Add", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		steps, err := build()
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.addPostProcessStep(steps...)
+	}, ui.window)
+}
+
+// parseLayerTarget reads a layer number, falling back to a Z height
+func parseLayerTarget(layer, z string) (LayerTarget, error) {
+	if strings.TrimSpace(layer) != "" {
+		n, err := parseFormInt("layer", layer)
+		return LayerTarget{Layer: n}, err
+	}
+	height, err := parseFormFloat("Z", z)
+	if err == nil && height <= 0 {
+		err = fmt.Errorf("Z must be above the bed")
+	}
+	return LayerTarget{Z: height}, err
+}
+
+// parseFormFloat parses a numeric form field
+func parseFormFloat(name, text string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+	return value, nil
+}
+
+// parseFormInt parses a positive whole-number form field
+func parseFormInt(name, text string) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("%s must be a whole number of at least 1", name)
+	}
+	return value, nil
+}
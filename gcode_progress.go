@@ -0,0 +1,54 @@
+package main
+
+import "sort"
+
+// LineForFileOffset returns the source line containing a byte offset, or 0
+func (m *GCodeModel) LineForFileOffset(offset int64) int {
+	if len(m.LineOffsets) == 0 || offset < 0 {
+		return 0
+	}
+
+	// Last line starting at or before the offset
+	index := sort.Search(len(m.LineOffsets), func(i int) bool {
+		return m.LineOffsets[i] > offset
+	})
+	return index
+}
+
+// PathIndexForLine returns the last path starting at or before a source line, or -1
+func (m *GCodeModel) PathIndexForLine(lineNumber int) int {
+	index := sort.Search(len(m.Paths), func(i int) bool {
+		return m.Paths[i].LineNumber > lineNumber
+	})
+	return index - 1
+}
+
+// ElapsedTimeAtLine returns the estimated print time in seconds up to a source line
+func (m *GCodeModel) ElapsedTimeAtLine(lineNumber int) float64 {
+	pathIndex := m.PathIndexForLine(lineNumber)
+	if pathIndex < 0 || pathIndex >= len(m.TimeTable) {
+		return 0
+	}
+	return m.TimeTable[pathIndex]
+}
+
+// RemainingTimeAtLine returns the estimated print time in seconds after a source line
+func (m *GCodeModel) RemainingTimeAtLine(lineNumber int) float64 {
+	remaining := m.Metadata.PrintTime - m.ElapsedTimeAtLine(lineNumber)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// ResolveStatusLine maps the file position reported by the printer to a source line.
+// The line number is used when the backend reports it, otherwise the byte offset.
+func (m *GCodeModel) ResolveStatusLine(status PrinterStatus) int {
+	if status.LineNumber > 0 {
+		return status.LineNumber
+	}
+	if status.FilePosition > 0 {
+		return m.LineForFileOffset(status.FilePosition)
+	}
+	return 0
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// GCodeFormat is how a G-code file is stored
+type GCodeFormat int
+
+const (
+	GCodeFormatText   GCodeFormat = iota // Plain text G-code
+	GCodeFormatGzip                      // Gzip-compressed text (.gcode.gz)
+	GCodeFormatZstd                      // Zstandard-compressed text (.gcode.zst)
+	GCodeFormatBinary                    // Prusa binary G-code (.bgcode)
+)
+
+// GCodeFormatNames are the display names of each format
+var GCodeFormatNames = map[GCodeFormat]string{
+	GCodeFormatText:   "G-code",
+	GCodeFormatGzip:   "Gzip G-code",
+	GCodeFormatZstd:   "Zstandard G-code",
+	GCodeFormatBinary: "Binary G-code",
+}
+
+// Magic bytes at the start of each non-text format
+var (
+	gzipMagic   = []byte{0x1f, 0x8b}
+	zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bgcodeMagic = []byte("GCDE")
+)
+
+// DetectGCodeFormat identifies a format from the first bytes of a file
+func DetectGCodeFormat(header []byte) GCodeFormat {
+	switch {
+	case bytes.HasPrefix(header, bgcodeMagic):
+		return GCodeFormatBinary
+	case bytes.HasPrefix(header, gzipMagic):
+		return GCodeFormatGzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return GCodeFormatZstd
+	default:
+		return GCodeFormatText
+	}
+}
+
+// GCodeThumbnail is a preview image embedded in a G-code file
+type GCodeThumbnail struct {
+	Format string // PNG, JPG or QOI
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// GCodeReader reads the G-code text of a file in any supported format.
+// Metadata and thumbnails are filled in as the container is read; for
+// bgcode everything stored before the G-code is available once the reader
+// is created.
+type GCodeReader struct {
+	Format     GCodeFormat
+	Metadata   map[string]string // Key/value pairs from bgcode metadata blocks
+	Thumbnails []GCodeThumbnail
+
+	text    io.Reader
+	closers []func() error
+}
+
+// NewGCodeReader detects the format of r by its magic bytes and returns a
+// reader of the decoded text. Closing it does not close r.
+func NewGCodeReader(r io.Reader) (*GCodeReader, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(len(bgcodeMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	reader := &GCodeReader{
+		Format:   DetectGCodeFormat(header),
+		Metadata: make(map[string]string),
+	}
+
+	switch reader.Format {
+	case GCodeFormatGzip:
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip G-code: %v", err)
+		}
+		reader.text = gz
+		reader.closers = append(reader.closers, gz.Close)
+	case GCodeFormatZstd:
+		zr, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zstd G-code: %v", err)
+		}
+		reader.text = zr
+		reader.closers = append(reader.closers, func() error {
+			zr.Close()
+			return nil
+		})
+	case GCodeFormatBinary:
+		bg, err := newBGCodeReader(buffered, reader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid binary G-code: %v", err)
+		}
+		reader.text = bg
+	default:
+		reader.text = buffered
+	}
+
+	return reader, nil
+}
+
+// OpenGCodeFile opens a G-code file in any supported format
+func OpenGCodeFile(path string) (*GCodeReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := NewGCodeReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader.closers = append(reader.closers, file.Close)
+	return reader, nil
+}
+
+// Read reads decoded G-code text
+func (r *GCodeReader) Read(p []byte) (int, error) {
+	return r.text.Read(p)
+}
+
+// Close releases the decoder and, for OpenGCodeFile, the file
+func (r *GCodeReader) Close() error {
+	var firstErr error
+	for _, closeFn := range r.closers {
+		if err := closeFn(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.closers = nil
+	return firstErr
+}
+
+// applyMetadata maps bgcode metadata onto the parsed model. Values computed
+// from the moves (print time, filament) are kept so they match the time table.
+func (r *GCodeReader) applyMetadata(model *GCodeModel) {
+	model.Format = r.Format
+	model.Thumbnails = append(model.Thumbnails, r.Thumbnails...)
+
+	metadata := &model.Metadata
+	for key, value := range r.Metadata {
+		metadata.SlicerSettings[key] = value
+	}
+
+	if producer, ok := r.Metadata["Producer"]; ok {
+		metadata.GeneratedBy = producer
+	}
+	if printerModel, ok := r.Metadata["printer_model"]; ok {
+		metadata.PrinterModel = printerModel
+	}
+	if value, ok := metadataFloat(r.Metadata, "layer_height"); ok {
+		metadata.LayerHeight = value
+	}
+	if value, ok := metadataFloat(r.Metadata, "fill_density"); ok {
+		metadata.InfillDensity = value
+	}
+	if value, ok := metadataFloat(r.Metadata, "perimeter_speed"); ok {
+		metadata.PrintSpeed = value
+	}
+	if estimate, ok := r.Metadata["estimated printing time (normal mode)"]; ok {
+		metadata.SlicerSettings["estimated_time"] = estimate
+	}
+}
+
+// metadataFloat reads a number such as "0.2" or "15%" from the metadata
+func metadataFloat(metadata map[string]string, key string) (float64, bool) {
+	value, ok := metadata[key]
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	return number, err == nil
+}
+
+// Extensions of text G-code, and of the compressed copies of it
+var (
+	gcodeTextExtensions     = []string{".gcode", ".gco", ".g"}
+	gcodeCompressExtensions = []string{".gz", ".zst", ".zstd"}
+)
+
+// trimCompressExtension removes a trailing .gz/.zst from a file name
+func trimCompressExtension(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range gcodeCompressExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// IsGCodeFileName reports whether a file name is text, binary or
+// compressed G-code
+func IsGCodeFileName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(trimCompressExtension(name)))
+	if ext == ".bgcode" {
+		return true
+	}
+	for _, textExt := range gcodeTextExtensions {
+		if ext == textExt {
+			return true
+		}
+	}
+	return false
+}
+
+// PlainGCodeName returns the name a file has once decoded to text G-code,
+// e.g. part.gcode.gz and part.bgcode both become part.gcode
+func PlainGCodeName(name string) string {
+	name = trimCompressExtension(name)
+	ext := filepath.Ext(name)
+	switch strings.ToLower(ext) {
+	case ".bgcode":
+		return strings.TrimSuffix(name, ext) + ".gcode"
+	case ".gcode", ".gco", ".g":
+		return name
+	default:
+		return name + ".gcode"
+	}
+}
+
+// textThumbnailCollector decodes the base64 previews PrusaSlicer, Cura and
+// OrcaSlicer write into text G-code as comment blocks, e.g.
+// "; thumbnail begin 300x300 12345" ... "; thumbnail end"
+type textThumbnailCollector struct {
+	current *GCodeThumbnail
+	data    strings.Builder
+}
+
+// add takes a comment and returns a thumbnail when it ends a block. active
+// reports whether the comment belonged to a thumbnail block.
+func (c *textThumbnailCollector) add(comment string) (thumbnail *GCodeThumbnail, active bool) {
+	fields := strings.Fields(comment)
+	if len(fields) >= 2 && strings.HasPrefix(fields[0], "thumbnail") {
+		format := "PNG"
+		if i := strings.IndexByte(fields[0], '_'); i >= 0 {
+			format = strings.ToUpper(fields[0][i+1:])
+		}
+		switch fields[1] {
+		case "begin":
+			c.current = &GCodeThumbnail{Format: format}
+			c.data.Reset()
+			if len(fields) >= 3 {
+				fmt.Sscanf(fields[2], "%dx%d", &c.current.Width, &c.current.Height)
+			}
+			return nil, true
+		case "end":
+			if c.current == nil {
+				return nil, true
+			}
+			thumbnail, c.current = c.current, nil
+			data, err := base64.StdEncoding.DecodeString(c.data.String())
+			if err != nil {
+				return nil, true
+			}
+			thumbnail.Data = data
+			return thumbnail, true
+		}
+	}
+	if c.current == nil {
+		return nil, false
+	}
+	c.data.WriteString(strings.TrimSpace(comment))
+	return nil, true
+}
+
+// PreviewThumbnail returns the largest thumbnail that can be decoded for
+// display, or nil if the file has none
+func (m *GCodeModel) PreviewThumbnail() *GCodeThumbnail {
+	var best *GCodeThumbnail
+	for i := range m.Thumbnails {
+		thumbnail := &m.Thumbnails[i]
+		if thumbnail.Format != "PNG" && thumbnail.Format != "JPG" {
+			continue
+		}
+		if best == nil || thumbnail.Width*thumbnail.Height > best.Width*best.Height {
+			best = thumbnail
+		}
+	}
+	return best
+}
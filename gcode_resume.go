@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ResumeOptions control the start sequence of a print resumed part-way
+type ResumeOptions struct {
+	ZLift       float64         // mm raised before homing and travelling to the resume point
+	PrimeLength float64         // mm of filament pushed out before resuming
+	TravelSpeed float64         // mm/min
+	HotendTemp  float64         // Used when the file sets no hotend temperature before the line
+	BedTemp     float64         // Used when the file sets no bed temperature before the line
+	ChamberTemp float64         // Used when the file sets no chamber temperature before the line
+	ToolTemps   map[int]float64 // Used for hotends, by tool, the file sets no temperature for
+}
+
+// DefaultResumeOptions are used unless the user changes them
+var DefaultResumeOptions = ResumeOptions{
+	ZLift:       2,
+	PrimeLength: 5,
+	TravelSpeed: 6000,
+}
+
+// ResumePlan is how a print continues from a source line
+type ResumePlan struct {
+	Line    int               // First source line printed again
+	Layer   int               // Layer of that line (0-based)
+	State   GCodeMachineState // Modal state before the line
+	ZOffset float64           // How far the remaining layers are lowered
+	Start   []string          // Commands run before the remaining file
+}
+
+// PlanPowerLossResume plans continuing a print that lost power at a source
+// line. The nozzle is still at the height it stopped, so Z is not homed:
+// the chamber and bed are reheated, the current Z is declared, the nozzle
+// lifted, X/Y homed and bed leveling turned back on, every hotend reheated
+// and the active one primed, and the position and modal state of the line
+// restored.
+func PlanPowerLossResume(model *GCodeModel, line int, opts ResumeOptions) (*ResumePlan, error) {
+	if model == nil {
+		return nil, fmt.Errorf("no G-code loaded")
+	}
+	if line <= 0 || len(model.Commands) == 0 || line > model.Commands[len(model.Commands)-1].LineNumber {
+		return nil, fmt.Errorf("line %d is outside the file", line)
+	}
+	layer := model.LayerIndexForLine(line)
+	if layer < 0 {
+		return nil, fmt.Errorf("line %d is before the first layer; start the print again instead", line)
+	}
+
+	state := model.MachineStateAt(line - 1)
+	heaters := resumeHeatersFor(state, opts)
+	if heaters.hotend <= 0 {
+		return nil, fmt.Errorf("no hotend temperature set before line %d", line)
+	}
+
+	start := []string{}
+	add := func(format string, args ...interface{}) {
+		start = append(start, fmt.Sprintf(format, args...))
+	}
+
+	if heaters.chamber > 0 {
+		add("M141 S%s ; Reheat chamber", formatCoordinate(heaters.chamber))
+	}
+	if heaters.bed > 0 {
+		add("M190 S%s ; Reheat bed", formatCoordinate(heaters.bed))
+	}
+	add("G92 Z%s ; Nozzle is still at the height it stopped", formatCoordinate(state.Z))
+	add("G91")
+	add("G1 Z%s F600 ; Lift off the print", formatCoordinate(opts.ZLift))
+	add("G90")
+	add("G28 X Y ; Home X/Y only")
+	if !state.LevelingOff {
+		add("M420 S1 ; Homing turns bed leveling off")
+	}
+	for _, tool := range heaters.idleTools() {
+		add("M104 T%d S%s ; Reheat idle hotend", tool, formatCoordinate(heaters.idle[tool]))
+	}
+	if state.Tool > 0 {
+		add("T%d", state.Tool)
+	}
+	add("M109 S%s ; Reheat hotend away from the print", formatCoordinate(heaters.hotend))
+	if opts.PrimeLength > 0 {
+		add("M83")
+		add("G1 E%s F300 ; Prime", formatCoordinate(opts.PrimeLength))
+	}
+	start = append(start, restoreStateCommands(state, state.Z, opts)...)
+	return &ResumePlan{Line: line, Layer: layer, State: state, Start: start}, nil
+}
+
+// LayerStartOptions control printing a file from a chosen layer
+type LayerStartOptions struct {
+	ResumeOptions
+	HomeZ      bool // Home Z as well; off when the printer still knows its height
+	OnEmptyBed bool // Lower the remaining layers so the chosen layer prints on the bed
+}
+
+// LayerAtHeight returns the first layer at or above a height, or -1 if the
+// file ends below it
+func (m *GCodeModel) LayerAtHeight(z float64) int {
+	for i, layer := range m.Layers {
+		if layer.Z >= z-0.001 {
+			return i
+		}
+	}
+	return -1
+}
+
+// PlanLayerStart plans printing a file from the start of a layer, e.g. to
+// finish a part after a jam or to print only its top. Every heater is
+// heated, the printer homed and bed leveling turned back on, the modal
+// state before the layer restored and the nozzle lowered onto the layer
+// from above. On an empty bed Z is declared
+// higher so the chosen layer prints at first-layer height.
+func PlanLayerStart(model *GCodeModel, layer int, opts LayerStartOptions) (*ResumePlan, error) {
+	if model == nil {
+		return nil, fmt.Errorf("no G-code loaded")
+	}
+	if layer < 0 || layer >= len(model.Layers) {
+		return nil, fmt.Errorf("layer %d is outside the file", layer+1)
+	}
+	if opts.OnEmptyBed {
+		opts.HomeZ = true
+	}
+
+	line := model.Layers[layer].StartLine
+	state := model.MachineStateAt(line - 1)
+	heaters := resumeHeatersFor(state, opts.ResumeOptions)
+	if heaters.hotend <= 0 {
+		return nil, fmt.Errorf("no hotend temperature set before layer %d", layer+1)
+	}
+
+	z := model.Layers[layer].Z
+	offset := 0.0
+	if opts.OnEmptyBed && layer > 0 {
+		offset = z - model.Layers[0].Z
+	}
+
+	start := []string{}
+	add := func(format string, args ...interface{}) {
+		start = append(start, fmt.Sprintf(format, args...))
+	}
+
+	if state.Tool > 0 {
+		add("T%d", state.Tool)
+	}
+	if heaters.chamber > 0 {
+		add("M141 S%s", formatCoordinate(heaters.chamber))
+	}
+	if heaters.bed > 0 {
+		add("M140 S%s", formatCoordinate(heaters.bed))
+	}
+	add("M104 S%s", formatCoordinate(heaters.hotend))
+	for _, tool := range heaters.idleTools() {
+		add("M104 T%d S%s", tool, formatCoordinate(heaters.idle[tool]))
+	}
+	if heaters.bed > 0 {
+		add("M190 S%s", formatCoordinate(heaters.bed))
+	}
+	add("M109 S%s", formatCoordinate(heaters.hotend))
+	if opts.HomeZ {
+		add("G28 ; Home all axes")
+	} else {
+		add("G91")
+		add("G1 Z%s F600 ; Lift off the print", formatCoordinate(opts.ZLift))
+		add("G28 X Y ; Home X/Y only, Z is still known")
+	}
+	if !state.LevelingOff {
+		add("M420 S1 ; Homing turns bed leveling off")
+	}
+	add("G90")
+	if offset > 0 {
+		// Homing may leave Z raised, so declare Z at a height moved to
+		add("G1 Z%s F600 ; Move to a known height", formatCoordinate(opts.ZLift))
+		add("G92 Z%s ; Print layer %d on the bed", formatCoordinate(opts.ZLift+offset), layer+1)
+	}
+	add("G1 Z%s F600 ; Clear the layer", formatCoordinate(z+opts.ZLift))
+	if opts.PrimeLength > 0 {
+		add("M83")
+		add("G1 E%s F300 ; Prime", formatCoordinate(opts.PrimeLength))
+	}
+	start = append(start, restoreStateCommands(state, z, opts.ResumeOptions)...)
+	return &ResumePlan{Line: line, Layer: layer, State: state, ZOffset: offset, Start: start}, nil
+}
+
+// resumeHeaters are the targets a resumed print is heated to
+type resumeHeaters struct {
+	hotend, bed, chamber float64
+	idle                 map[int]float64 // Hotends other than the active one, by tool
+}
+
+// resumeHeatersFor takes the targets the file set before the resume point,
+// falling back to the options for heaters it set none for
+func resumeHeatersFor(state GCodeMachineState, opts ResumeOptions) resumeHeaters {
+	heaters := resumeHeaters{
+		hotend:  state.HotendTemp,
+		bed:     state.BedTemp,
+		chamber: state.ChamberTemp,
+		idle:    map[int]float64{},
+	}
+	if heaters.hotend <= 0 {
+		heaters.hotend = opts.ToolTemps[state.Tool]
+	}
+	if heaters.hotend <= 0 {
+		heaters.hotend = opts.HotendTemp
+	}
+	if heaters.bed <= 0 {
+		heaters.bed = opts.BedTemp
+	}
+	if heaters.chamber <= 0 {
+		heaters.chamber = opts.ChamberTemp
+	}
+	for tool, temp := range opts.ToolTemps {
+		if _, set := state.ToolTemps[tool]; !set && tool != state.Tool && temp > 0 {
+			heaters.idle[tool] = temp
+		}
+	}
+	for tool, temp := range state.ToolTemps {
+		if tool != state.Tool && temp > 0 {
+			heaters.idle[tool] = temp
+		}
+	}
+	return heaters
+}
+
+// idleTools returns the idle hotends to heat in tool order
+func (h resumeHeaters) idleTools() []int {
+	tools := make([]int, 0, len(h.idle))
+	for tool := range h.idle {
+		tools = append(tools, tool)
+	}
+	sort.Ints(tools)
+	return tools
+}
+
+// restoreStateCommands travel to the resume point, lower the nozzle to z
+// and restore the modal state before the remaining file runs
+func restoreStateCommands(state GCodeMachineState, z float64, opts ResumeOptions) []string {
+	travel := opts.TravelSpeed
+	if travel <= 0 {
+		travel = DefaultResumeOptions.TravelSpeed
+	}
+
+	commands := []string{
+		fmt.Sprintf("G1 X%s Y%s F%s ; Travel to the resume point",
+			formatCoordinate(state.X), formatCoordinate(state.Y), formatCoordinate(travel)),
+		fmt.Sprintf("G1 Z%s F600", formatCoordinate(z)),
+	}
+	if state.FanSpeed > 0 {
+		commands = append(commands, fmt.Sprintf("M106 S%s", formatCoordinate(state.FanSpeed)))
+	} else {
+		commands = append(commands, "M107")
+	}
+	if state.SpeedFactor != 100 {
+		commands = append(commands, fmt.Sprintf("M220 S%s", formatCoordinate(state.SpeedFactor)))
+	}
+	if state.FlowFactor != 100 {
+		commands = append(commands, fmt.Sprintf("M221 S%s", formatCoordinate(state.FlowFactor)))
+	}
+	if state.AbsoluteExtrusion {
+		commands = append(commands, "M82", fmt.Sprintf("G92 E%s", formatCoordinate(state.E)))
+	} else {
+		commands = append(commands, "M83")
+	}
+	if !state.AbsolutePositioning {
+		commands = append(commands, "G91")
+	}
+	commands = append(commands, fmt.Sprintf("G1 F%s", formatCoordinate(state.FeedRate)))
+	return commands
+}
+
+// Preamble returns the header comments and start sequence written before
+// the remaining file
+func (plan *ResumePlan) Preamble() []string {
+	lines := []string{
+		fmt.Sprintf("; Resumed at source line %d, layer %d, Z %s",
+			plan.Line, plan.Layer+1, formatCoordinate(plan.State.Z)),
+	}
+	if plan.ZOffset > 0 {
+		lines = append(lines, fmt.Sprintf("; Layers lowered by %s mm", formatCoordinate(plan.ZOffset)))
+	}
+	return append(lines, plan.Start...)
+}
+
+// WriteResumeGCode writes the plan's start sequence followed by the model's
+// source from the resume line on
+func WriteResumeGCode(model *GCodeModel, plan *ResumePlan, w io.Writer) error {
+	if model == nil || plan == nil {
+		return fmt.Errorf("no resume plan")
+	}
+
+	if _, err := io.WriteString(w, strings.Join(plan.Preamble(), "\n")+"\n"); err != nil {
+		return err
+	}
+
+	for i := model.CommandIndexForLine(plan.Line); i < len(model.Commands); i++ {
+		if _, err := io.WriteString(w, model.Commands[i].RawLine+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// parseFixture parses a G-code file from testdata
+func parseFixture(t *testing.T, name string) (*GCodeModel, []string) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := NewGCodeParser().ParseGCode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("parse %s: %v", name, err)
+	}
+	return model, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestPlanPowerLossResume(t *testing.T) {
+	model, lines := parseFixture(t, "power_loss.gcode")
+
+	plan, err := PlanPowerLossResume(model, 19, DefaultResumeOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Line != 19 || plan.Layer != 1 {
+		t.Errorf("plan resumes line %d on layer %d, want line 19 on layer 1", plan.Line, plan.Layer)
+	}
+	want := []string{
+		"M190 S60 ; Reheat bed",
+		"G92 Z0.4 ; Nozzle is still at the height it stopped",
+		"G91",
+		"G1 Z2 F600 ; Lift off the print",
+		"G90",
+		"G28 X Y ; Home X/Y only",
+		"M420 S1 ; Homing turns bed leveling off",
+		"T1",
+		"M109 S215 ; Reheat hotend away from the print",
+		"M83",
+		"G1 E5 F300 ; Prime",
+		"G1 X20 Y20 F6000 ; Travel to the resume point",
+		"G1 Z0.4 F600",
+		"M106 S128",
+		"M220 S90",
+		"M83",
+		"G1 F1500",
+	}
+	if got := strings.Join(plan.Start, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("start sequence differs\ngot:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	var out bytes.Buffer
+	if err := WriteResumeGCode(model, plan, &out); err != nil {
+		t.Fatal(err)
+	}
+	wantFile := append(append([]string{"; Resumed at source line 19, layer 2, Z 0.4"}, want...), lines[18:]...)
+	if got := out.String(); got != strings.Join(wantFile, "\n")+"\n" {
+		t.Errorf("resume file differs\ngot:\n%s\nwant:\n%s", got, strings.Join(wantFile, "\n"))
+	}
+}
+
+func TestPlanPowerLossResumeFallbacks(t *testing.T) {
+	model, _ := parseFixture(t, "power_loss_cold.gcode")
+
+	if _, err := PlanPowerLossResume(model, 12, DefaultResumeOptions); err == nil {
+		t.Error("a file without temperatures should need them from the options")
+	}
+
+	opts := DefaultResumeOptions
+	opts.HotendTemp, opts.BedTemp = 210, 65
+	opts.PrimeLength = 0
+	plan, err := PlanPowerLossResume(model, 12, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"M190 S65 ; Reheat bed",
+		"G92 Z0.4 ; Nozzle is still at the height it stopped",
+		"G91",
+		"G1 Z2 F600 ; Lift off the print",
+		"G90",
+		"G28 X Y ; Home X/Y only",
+		"M420 S1 ; Homing turns bed leveling off",
+		"M109 S210 ; Reheat hotend away from the print",
+		"G1 X20 Y20 F6000 ; Travel to the resume point",
+		"G1 Z0.4 F600",
+		"M107",
+		"M82",
+		"G92 E2.4",
+		"G1 F1500",
+	}
+	if got := strings.Join(plan.Start, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("start sequence differs\ngot:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestPlanPowerLossResumeHeatsEveryHeater(t *testing.T) {
+	model, _ := parseFixture(t, "power_loss_idex.gcode")
+
+	plan, err := PlanPowerLossResume(model, 21, DefaultResumeOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"M141 S45 ; Reheat chamber",
+		"M190 S100 ; Reheat bed",
+		"G92 Z0.4 ; Nozzle is still at the height it stopped",
+		"G91",
+		"G1 Z2 F600 ; Lift off the print",
+		"G90",
+		"G28 X Y ; Home X/Y only",
+		"M420 S1 ; Homing turns bed leveling off",
+		"M104 T0 S250 ; Reheat idle hotend",
+		"T1",
+		"M109 S240 ; Reheat hotend away from the print",
+		"M83",
+		"G1 E5 F300 ; Prime",
+		"G1 X20 Y20 F6000 ; Travel to the resume point",
+		"G1 Z0.4 F600",
+		"M107",
+		"M83",
+		"G1 F1500",
+	}
+	if got := strings.Join(plan.Start, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("start sequence differs\ngot:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	// The idle hotend's standby temperature is restored
+	plan, err = PlanPowerLossResume(model, 23, DefaultResumeOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start := strings.Join(plan.Start, "\n"); !strings.Contains(start, "M104 T0 S180 ; Reheat idle hotend") {
+		t.Errorf("idle hotend not restored to standby:\n%s", start)
+	}
+
+	// Checkpointed targets heat hotends and a chamber the file sets none for
+	model, _ = parseFixture(t, "power_loss_cold.gcode")
+	opts := DefaultResumeOptions
+	opts.ToolTemps = map[int]float64{0: 210, 1: 200}
+	opts.ChamberTemp = 40
+	plan, err = PlanPowerLossResume(model, 12, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := strings.Join(plan.Start, "\n")
+	for _, command := range []string{"M141 S40 ; Reheat chamber", "M104 T1 S200 ; Reheat idle hotend", "M109 S210 ;"} {
+		if !strings.Contains(start, command) {
+			t.Errorf("start sequence lacks %q:\n%s", command, start)
+		}
+	}
+}
+
+func TestPlanResumeLevelingOff(t *testing.T) {
+	source := "M104 S200\nG28\nM420 S0\n;LAYER:0\nG1 Z0.2 F600\nG1 X10 Y10 E1\n;LAYER:1\nG1 Z0.4\nG1 X20 Y10 E2\n"
+	model, err := NewGCodeParser().ParseGCode(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := PlanPowerLossResume(model, 9, DefaultResumeOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layerPlan, err := PlanLayerStart(model, 1, LayerStartOptions{ResumeOptions: DefaultResumeOptions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, start := range [][]string{plan.Start, layerPlan.Start} {
+		if joined := strings.Join(start, "\n"); strings.Contains(joined, "M420") {
+			t.Errorf("a file that turns leveling off should not have it turned on:\n%s", joined)
+		}
+	}
+}
+
+func TestPlanLayerStart(t *testing.T) {
+	model, lines := parseFixture(t, "power_loss.gcode")
+	tail := []string{
+		"M83",
+		"G1 E5 F300 ; Prime",
+		"G1 X20 Y10 F6000 ; Travel to the resume point",
+		"G1 Z0.4 F600",
+		"M106 S128",
+		"M83",
+		"G1 F1200",
+	}
+	heat := []string{"T1", "M140 S60", "M104 S215", "M190 S60", "M109 S215"}
+
+	tests := []struct {
+		name   string
+		opts   LayerStartOptions
+		offset float64
+		homing []string
+	}{
+		{"on the print", LayerStartOptions{ResumeOptions: DefaultResumeOptions}, 0, []string{
+			"G91",
+			"G1 Z2 F600 ; Lift off the print",
+			"G28 X Y ; Home X/Y only, Z is still known",
+			"M420 S1 ; Homing turns bed leveling off",
+			"G90",
+			"G1 Z2.4 F600 ; Clear the layer",
+		}},
+		{"homing Z", LayerStartOptions{ResumeOptions: DefaultResumeOptions, HomeZ: true}, 0, []string{
+			"G28 ; Home all axes",
+			"M420 S1 ; Homing turns bed leveling off",
+			"G90",
+			"G1 Z2.4 F600 ; Clear the layer",
+		}},
+		// Z is declared at a height moved to, not wherever homing left it
+		{"on an empty bed", LayerStartOptions{ResumeOptions: DefaultResumeOptions, OnEmptyBed: true}, 0.2, []string{
+			"G28 ; Home all axes",
+			"M420 S1 ; Homing turns bed leveling off",
+			"G90",
+			"G1 Z2 F600 ; Move to a known height",
+			"G92 Z2.2 ; Print layer 2 on the bed",
+			"G1 Z2.4 F600 ; Clear the layer",
+		}},
+	}
+	for _, test := range tests {
+		plan, err := PlanLayerStart(model, 1, test.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if plan.Line != 16 || plan.Layer != 1 || plan.ZOffset != test.offset {
+			t.Errorf("%s: plan starts line %d on layer %d lowered %g, want line 16 on layer 1 lowered %g",
+				test.name, plan.Line, plan.Layer, plan.ZOffset, test.offset)
+		}
+		want := append(append(append([]string{}, heat...), test.homing...), tail...)
+		if got := strings.Join(plan.Start, "\n"); got != strings.Join(want, "\n") {
+			t.Errorf("%s: start sequence differs\ngot:\n%s\nwant:\n%s", test.name, got, strings.Join(want, "\n"))
+		}
+	}
+
+	// The rest of the file follows unchanged; its Z moves land on the
+	// lowered origin
+	plan, _ := PlanLayerStart(model, 1, LayerStartOptions{ResumeOptions: DefaultResumeOptions, OnEmptyBed: true})
+	var out bytes.Buffer
+	if err := WriteResumeGCode(model, plan, &out); err != nil {
+		t.Fatal(err)
+	}
+	wantFile := append(append([]string{
+		"; Resumed at source line 16, layer 2, Z 0.2",
+		"; Layers lowered by 0.2 mm",
+	}, plan.Start...), lines[15:]...)
+	if got := out.String(); got != strings.Join(wantFile, "\n")+"\n" {
+		t.Errorf("layer start file differs\ngot:\n%s\nwant:\n%s", got, strings.Join(wantFile, "\n"))
+	}
+
+	for _, layer := range []int{-1, 3} {
+		if _, err := PlanLayerStart(model, layer, LayerStartOptions{ResumeOptions: DefaultResumeOptions}); err == nil {
+			t.Errorf("layer %d: expected an error", layer)
+		}
+	}
+}
+
+func TestPlanPowerLossResumeRejectsLines(t *testing.T) {
+	model, _ := parseFixture(t, "power_loss.gcode")
+	for _, line := range []int{0, -3, 5, 26} {
+		if _, err := PlanPowerLossResume(model, line, DefaultResumeOptions); err == nil {
+			t.Errorf("line %d: expected an error", line)
+		}
+	}
+	if _, err := PlanPowerLossResume(nil, 19, DefaultResumeOptions); err == nil {
+		t.Error("nil model: expected an error")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// defaultFilamentDiameter is used for flow calculations when no spool is known
+const defaultFilamentDiameter = 1.75
+
+// GCodeMachineState represents the modal printer state at a given line
+type GCodeMachineState struct {
+	LineNumber          int             // Last line applied to this state
+	LayerIndex          int             // Layer containing LineNumber (-1 before first layer)
+	X, Y, Z             float64         // Logical position
+	E                   float64         // Logical extruder position
+	FeedRate            float64         // Current feed rate in mm/min
+	AbsolutePositioning bool            // G90/G91
+	AbsoluteExtrusion   bool            // M82/M83
+	Tool                int             // Active tool
+	HotendTemp          float64         // Last target of the active hotend (M104/M109)
+	ToolTemps           map[int]float64 // Targets set for a hotend by number (M104 T<n>)
+	BedTemp             float64         // Last bed target (M140/M190)
+	ChamberTemp         float64         // Last chamber target (M141/M191)
+	FanSpeed            float64         // Part cooling fan 0-255 (M106/M107)
+	SpeedFactor         float64         // Feed rate override in percent (M220)
+	FlowFactor          float64         // Extrusion override in percent (M221)
+	Homed               bool            // Whether a G28 was seen
+	LevelingOff         bool            // Bed leveling turned off by M420 S0
+}
+
+// NewGCodeMachineState returns the power-on state assumed by the parser
+func NewGCodeMachineState() GCodeMachineState {
+	return GCodeMachineState{
+		LayerIndex:          -1,
+		FeedRate:            1500,
+		AbsolutePositioning: true,
+		AbsoluteExtrusion:   true,
+		SpeedFactor:         100,
+		FlowFactor:          100,
+	}
+}
+
+// Apply updates the state with a single command
+func (s *GCodeMachineState) Apply(cmd GCodeCommand) {
+	s.LineNumber = cmd.LineNumber
+	if !cmd.IsValid || cmd.Type == "" {
+		return
+	}
+
+	switch cmd.Type {
+	case "G0", "G1", "G2", "G3":
+		s.X = s.resolveAxis(s.X, cmd.X)
+		s.Y = s.resolveAxis(s.Y, cmd.Y)
+		s.Z = s.resolveAxis(s.Z, cmd.Z)
+		if !math.IsNaN(cmd.E) {
+			if s.AbsoluteExtrusion {
+				s.E = cmd.E
+			} else {
+				s.E += cmd.E
+			}
+		}
+	case "G28":
+		homeX, homeY, homeZ := homingAxes(cmd)
+		if homeX {
+			s.X = 0
+		}
+		if homeY {
+			s.Y = 0
+		}
+		if homeZ {
+			s.Z = 0
+		}
+		s.Homed = true
+	case "G90":
+		s.AbsolutePositioning = true
+	case "G91":
+		s.AbsolutePositioning = false
+	case "G92":
+		if !math.IsNaN(cmd.X) {
+			s.X = cmd.X
+		}
+		if !math.IsNaN(cmd.Y) {
+			s.Y = cmd.Y
+		}
+		if !math.IsNaN(cmd.Z) {
+			s.Z = cmd.Z
+		}
+		if !math.IsNaN(cmd.E) {
+			s.E = cmd.E
+		}
+	case "M82":
+		s.AbsoluteExtrusion = true
+	case "M83":
+		s.AbsoluteExtrusion = false
+	case "M104", "M109":
+		if !math.IsNaN(cmd.S) {
+			s.setHotendTemp(cmd.T, cmd.S)
+		}
+	case "M140", "M190":
+		if !math.IsNaN(cmd.S) {
+			s.BedTemp = cmd.S
+		}
+	case "M141", "M191":
+		if !math.IsNaN(cmd.S) {
+			s.ChamberTemp = cmd.S
+		}
+	case "M106":
+		if math.IsNaN(cmd.S) {
+			s.FanSpeed = 255
+		} else {
+			s.FanSpeed = cmd.S
+		}
+	case "M107":
+		s.FanSpeed = 0
+	case "M220":
+		if !math.IsNaN(cmd.S) {
+			s.SpeedFactor = cmd.S
+		}
+	case "M221":
+		if !math.IsNaN(cmd.S) {
+			s.FlowFactor = cmd.S
+		}
+	case "M420":
+		if !math.IsNaN(cmd.S) {
+			s.LevelingOff = cmd.S == 0
+		}
+	case "G29":
+		s.LevelingOff = false
+	default:
+		// Tool change (T0, T1, ...)
+		if len(cmd.Type) > 1 && cmd.Type[0] == 'T' {
+			if tool, ok := parseToolNumber(cmd.Type); ok {
+				// Files that name hotends keep each one's target
+				if _, ok := s.ToolTemps[s.Tool]; !ok && len(s.ToolTemps) > 0 {
+					s.setHotendTemp(s.Tool, s.HotendTemp)
+				}
+				s.Tool = tool
+				if temp, ok := s.ToolTemps[tool]; ok {
+					s.HotendTemp = temp
+				}
+			}
+		}
+	}
+
+	if !math.IsNaN(cmd.F) {
+		s.FeedRate = cmd.F
+	}
+}
+
+// setHotendTemp records a hotend target. Without a T word it is for the
+// active hotend, which is only tracked by number once a T word named it.
+// The map is replaced rather than written so copies of the state keep
+// their own targets.
+func (s *GCodeMachineState) setHotendTemp(tool int, temp float64) {
+	if tool < 0 {
+		tool = s.Tool
+		if _, ok := s.ToolTemps[tool]; !ok {
+			s.HotendTemp = temp
+			return
+		}
+	}
+	if tool == s.Tool {
+		s.HotendTemp = temp
+	}
+	temps := make(map[int]float64, len(s.ToolTemps)+1)
+	for t, v := range s.ToolTemps {
+		temps[t] = v
+	}
+	temps[tool] = temp
+	s.ToolTemps = temps
+}
+
+// resolveAxis applies an axis word in the current positioning mode
+func (s *GCodeMachineState) resolveAxis(current, value float64) float64 {
+	if math.IsNaN(value) {
+		return current
+	}
+	if s.AbsolutePositioning {
+		return value
+	}
+	return current + value
+}
+
+// MachineStateAt replays the model up to and including the given source line
+func (m *GCodeModel) MachineStateAt(lineNumber int) GCodeMachineState {
+	state := NewGCodeMachineState()
+
+	for _, cmd := range m.Commands {
+		if cmd.LineNumber > lineNumber {
+			break
+		}
+		state.Apply(cmd)
+	}
+
+	state.LayerIndex = m.LayerIndexForLine(lineNumber)
+	return state
+}
+
+// CommandIndexForLine returns the index into Commands for a source line.
+// Lines without a command (blank lines) map to the next command.
+func (m *GCodeModel) CommandIndexForLine(lineNumber int) int {
+	index := sort.Search(len(m.Commands), func(i int) bool {
+		return m.Commands[i].LineNumber >= lineNumber
+	})
+	if index >= len(m.Commands) {
+		return len(m.Commands) - 1
+	}
+	return index
+}
+
+// LayerIndexForLine returns the layer containing a source line, or -1
+func (m *GCodeModel) LayerIndexForLine(lineNumber int) int {
+	index := sort.Search(len(m.Layers), func(i int) bool {
+		return m.Layers[i].EndLine >= lineNumber
+	})
+	if index >= len(m.Layers) || lineNumber < m.Layers[index].StartLine {
+		return -1
+	}
+	return index
+}
+
+// homingAxes returns the axes a G28 homes; without axes it homes them all.
+// Axes are usually given bare, as in "G28 X Y", which the parser drops as
+// they carry no value, so the raw line is read as well.
+func homingAxes(cmd GCodeCommand) (x, y, z bool) {
+	x, y, z = !math.IsNaN(cmd.X), !math.IsNaN(cmd.Y), !math.IsNaN(cmd.Z)
+	command := strings.SplitN(cmd.RawLine, ";", 2)[0]
+	for _, field := range strings.Fields(command) {
+		switch strings.ToUpper(field[:1]) {
+		case "X":
+			x = true
+		case "Y":
+			y = true
+		case "Z":
+			z = true
+		}
+	}
+	if !x && !y && !z {
+		return true, true, true
+	}
+	return x, y, z
+}
+
+// parseToolNumber parses the number from a T<n> command
+func parseToolNumber(command string) (int, bool) {
+	tool := 0
+	for _, c := range command[1:] {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		tool = tool*10 + int(c-'0')
+	}
+	return tool, true
+}
@@ -0,0 +1,98 @@
+package main
+
+import "testing"
+
+func TestApplyHomingAxes(t *testing.T) {
+	tests := []struct {
+		line    string
+		x, y, z float64
+	}{
+		{"G28", 0, 0, 0},
+		{"G28 ; home all", 0, 0, 0},
+		{"G28 X", 0, 20, 30},
+		{"G28 X Y", 0, 0, 30},
+		{"G28 Z", 10, 20, 0},
+		{"G28 X0 Z0", 0, 20, 0},
+		{"g28 y", 10, 0, 30},
+	}
+
+	parser := NewGCodeParser()
+	for _, test := range tests {
+		state := NewGCodeMachineState()
+		state.X, state.Y, state.Z = 10, 20, 30
+		state.Apply(parser.parseLine(test.line, 1))
+
+		if state.X != test.x || state.Y != test.y || state.Z != test.z {
+			t.Errorf("%q: got X%g Y%g Z%g, want X%g Y%g Z%g",
+				test.line, state.X, state.Y, state.Z, test.x, test.y, test.z)
+		}
+		if !state.Homed {
+			t.Errorf("%q: state not marked homed", test.line)
+		}
+	}
+}
+
+func TestApplyHotendTemps(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		tool   int
+		hotend float64
+		tools  map[int]float64
+	}{
+		{"active hotend", []string{"M104 S215", "T1", "M109 S220"}, 1, 220, nil},
+		{"numbered hotends", []string{"M104 T0 S250", "M104 T1 S240", "T1"}, 1, 240, map[int]float64{0: 250, 1: 240}},
+		{"idle hotend on standby", []string{"M104 T0 S250", "M104 T1 S240", "T1", "M104 T0 S180"}, 1, 240, map[int]float64{0: 180, 1: 240}},
+		{"active hotend once numbered", []string{"M104 S200", "M104 T1 S240", "T1", "M104 S235", "T0"}, 0, 200, map[int]float64{0: 200, 1: 235}},
+	}
+
+	parser := NewGCodeParser()
+	for _, test := range tests {
+		state := NewGCodeMachineState()
+		for i, line := range test.lines {
+			state.Apply(parser.parseLine(line, i+1))
+		}
+		if state.Tool != test.tool || state.HotendTemp != test.hotend {
+			t.Errorf("%s: T%d at %g, want T%d at %g", test.name, state.Tool, state.HotendTemp, test.tool, test.hotend)
+		}
+		if len(state.ToolTemps) != len(test.tools) {
+			t.Errorf("%s: tool targets %v, want %v", test.name, state.ToolTemps, test.tools)
+			continue
+		}
+		for tool, temp := range test.tools {
+			if state.ToolTemps[tool] != temp {
+				t.Errorf("%s: tool targets %v, want %v", test.name, state.ToolTemps, test.tools)
+			}
+		}
+	}
+
+	// Copies of a state keep their own targets
+	state := NewGCodeMachineState()
+	state.Apply(parser.parseLine("M104 T1 S240", 1))
+	copied := state
+	state.Apply(parser.parseLine("M104 T1 S0", 2))
+	if copied.ToolTemps[1] != 240 {
+		t.Errorf("copied state changed to %v", copied.ToolTemps)
+	}
+}
+
+func TestApplyLeveling(t *testing.T) {
+	parser := NewGCodeParser()
+	state := NewGCodeMachineState()
+	for i, test := range []struct {
+		line string
+		off  bool
+	}{
+		{"G28", false},
+		{"M420 S0", true},
+		{"M420 Z10", true},
+		{"M420 S1", false},
+		{"M420 S0", true},
+		{"G29", false},
+	} {
+		state.Apply(parser.parseLine(test.line, i+1))
+		if state.LevelingOff != test.off {
+			t.Errorf("after %q: leveling off %v, want %v", test.line, state.LevelingOff, test.off)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// IDEXMode is how the second carriage of an IDEX printer follows the first
+type IDEXMode int
+
+const (
+	IDEXModeNormal      IDEXMode = iota // Carriages print independently
+	IDEXModeDuplication                 // Second carriage prints a copy at an X offset
+	IDEXModeMirror                      // Second carriage prints a copy mirrored about the bed centre
+)
+
+// IDEXModeNames matches the modes offered on the printer profile screen
+var IDEXModeNames = map[IDEXMode]string{
+	IDEXModeNormal:      "Normal",
+	IDEXModeDuplication: "Duplication Mode",
+	IDEXModeMirror:      "Mirror Mode",
+}
+
+// idexModeCommands are the Marlin M605 commands that select each mode. S0
+// is full control, which leaves parking to the slicer, so normal printing
+// uses auto-park.
+var idexModeCommands = map[IDEXMode]string{
+	IDEXModeNormal:      "M605 S1",
+	IDEXModeDuplication: "M605 S2",
+	IDEXModeMirror:      "M605 S3",
+}
+
+// idexModeNamed returns the mode shown under a name
+func idexModeNamed(name string) (IDEXMode, bool) {
+	for mode, modeName := range IDEXModeNames {
+		if modeName == name {
+			return mode, true
+		}
+	}
+	return IDEXModeNormal, false
+}
+
+// GCodeTransform is a rigid XY transform of a print plus the IDEX mode it
+// is printed in
+type GCodeTransform struct {
+	TranslateX, TranslateY float64  // Shift applied to every XY position
+	MirrorX                bool     // Mirror X about CenterX after translating
+	CenterX                float64  // Bed centre; mirror line for MirrorX and IDEX mirror mode
+	Carriage               IDEXMode // How the second carriage copies the first
+	CarriageOffsetX        float64  // X distance of the copy in duplication mode
+}
+
+// ModeCommand returns the M605 command that selects the carriage mode, with
+// the copy's X offset in duplication mode
+func (t GCodeTransform) ModeCommand() string {
+	command := idexModeCommands[t.Carriage]
+	if t.Carriage == IDEXModeDuplication {
+		command += " X" + formatCoordinate(t.CarriageOffsetX)
+	}
+	return command
+}
+
+// changesGeometry reports whether the transform moves any coordinate
+func (t GCodeTransform) changesGeometry() bool {
+	return t.TranslateX != 0 || t.TranslateY != 0 || t.MirrorX
+}
+
+// transformX maps an absolute X position
+func (t GCodeTransform) transformX(x float64) float64 {
+	x += t.TranslateX
+	if t.MirrorX {
+		x = 2*t.CenterX - x
+	}
+	return x
+}
+
+// SecondCarriageX returns where the second carriage is when the first is at x;
+// ok is false when the second carriage is idle
+func (t GCodeTransform) SecondCarriageX(x float64) (float64, bool) {
+	switch t.Carriage {
+	case IDEXModeDuplication:
+		return x + t.CarriageOffsetX, true
+	case IDEXModeMirror:
+		return 2*t.CenterX - x, true
+	default:
+		return x, false
+	}
+}
+
+// TransformGCode applies a transform to a parsed file and parses the result
+func TransformGCode(model *GCodeModel, t GCodeTransform) (*GCodeModel, error) {
+	var output bytes.Buffer
+	if err := WriteTransformedGCode(model, t, &output); err != nil {
+		return nil, err
+	}
+	return NewGCodeParser().ParseGCode(&output)
+}
+
+// WriteTransformedGCode writes the model's source with the transform applied.
+// Blank lines are kept so line numbers only shift by the mode command added
+// for duplication and mirror modes. Those files end by switching the printer
+// back to normal printing.
+func WriteTransformedGCode(model *GCodeModel, t GCodeTransform, w io.Writer) error {
+	if model == nil {
+		return fmt.Errorf("no G-code loaded")
+	}
+
+	transformer := &gcodeLineTransformer{transform: t}
+	modeWritten := t.Carriage == IDEXModeNormal
+	lineNumber := 1
+
+	for _, cmd := range model.Commands {
+		for ; lineNumber < cmd.LineNumber; lineNumber++ {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+
+		// Select the carriage mode before the first move
+		if !modeWritten && (cmd.Type == "G0" || cmd.Type == "G1") {
+			if _, err := fmt.Fprintf(w, "%s ; %s\n", t.ModeCommand(), IDEXModeNames[t.Carriage]); err != nil {
+				return err
+			}
+			modeWritten = true
+		}
+
+		if _, err := io.WriteString(w, transformer.transformLine(cmd.RawLine)+"\n"); err != nil {
+			return err
+		}
+		lineNumber++
+	}
+
+	if modeWritten && t.Carriage != IDEXModeNormal {
+		normal := GCodeTransform{Carriage: IDEXModeNormal}
+		if _, err := fmt.Fprintf(w, "%s ; %s\n", normal.ModeCommand(), IDEXModeNames[IDEXModeNormal]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gcodeLineTransformer rewrites coordinates line by line, following G90/G91
+type gcodeLineTransformer struct {
+	transform GCodeTransform
+	relative  bool
+}
+
+// transformLine returns a line with its XY words transformed
+func (lt *gcodeLineTransformer) transformLine(raw string) string {
+	code, comment := raw, ""
+	if index := strings.Index(raw, ";"); index >= 0 {
+		code, comment = raw[:index], raw[index:]
+	}
+
+	fields := strings.Fields(code)
+	if len(fields) == 0 {
+		return raw
+	}
+
+	command := strings.ToUpper(fields[0])
+	switch command {
+	case "G90":
+		lt.relative = false
+		return raw
+	case "G91":
+		lt.relative = true
+		return raw
+	case "G0", "G1", "G2", "G3", "G92":
+	case "EXCLUDE_OBJECT_DEFINE":
+		if !lt.transform.changesGeometry() {
+			return raw
+		}
+		return joinGCode(lt.transformObjectDefine(fields), comment)
+	default:
+		return raw
+	}
+
+	if !lt.transform.changesGeometry() {
+		return raw
+	}
+
+	// G92 sets a position, which is absolute even in relative mode
+	absolute := !lt.relative || command == "G92"
+	t := lt.transform
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		if len(field) < 2 {
+			continue
+		}
+		value, err := strconv.ParseFloat(field[1:], 64)
+		if err != nil {
+			continue
+		}
+
+		switch field[0] {
+		case 'X', 'x':
+			if absolute {
+				value = t.transformX(value)
+			} else if t.MirrorX {
+				value = -value
+			}
+		case 'Y', 'y':
+			if absolute {
+				value += t.TranslateY
+			}
+		case 'I', 'i':
+			// Arc centre offsets are relative
+			if t.MirrorX {
+				value = -value
+			}
+		default:
+			continue
+		}
+		fields[i] = field[:1] + formatCoordinate(value)
+	}
+
+	// Mirroring reverses the direction of arcs
+	if t.MirrorX && command == "G2" {
+		fields[0] = "G3"
+	} else if t.MirrorX && command == "G3" {
+		fields[0] = "G2"
+	}
+
+	return joinGCode(fields, comment)
+}
+
+// transformObjectDefine moves the CENTER and POLYGON of a Klipper object
+// definition so exclude-object keeps working on the transformed file
+func (lt *gcodeLineTransformer) transformObjectDefine(fields []string) []string {
+	t := lt.transform
+	for i, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch strings.ToUpper(parts[0]) {
+		case "CENTER":
+			if center, ok := parseKlipperPoint(parts[1]); ok {
+				fields[i+1] = fmt.Sprintf("%s=%s,%s", parts[0],
+					formatCoordinate(t.transformX(center[0])), formatCoordinate(center[1]+t.TranslateY))
+			}
+		case "POLYGON":
+			if polygon, ok := parseKlipperPolygon(parts[1]); ok {
+				for j := range polygon {
+					polygon[j] = [2]float64{t.transformX(polygon[j][0]), polygon[j][1] + t.TranslateY}
+				}
+				if encoded, err := json.Marshal(polygon); err == nil {
+					fields[i+1] = parts[0] + "=" + string(encoded)
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// joinGCode rebuilds a line from its words and comment
+func joinGCode(fields []string, comment string) string {
+	line := strings.Join(fields, " ")
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// formatCoordinate formats a coordinate with at most three decimals
+func formatCoordinate(value float64) string {
+	value = math.Round(value*1000) / 1000
+	if value == 0 {
+		// Avoid writing -0
+		value = 0
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// extrusionBounds returns the extent of the extruding moves only, so parking
+// and purge travel does not count against the build volume
+func (m *GCodeModel) extrusionBounds() (GCodeBounds, bool) {
+	bounds := GCodeBounds{
+		MinX: math.Inf(1), MaxX: math.Inf(-1),
+		MinY: math.Inf(1), MaxY: math.Inf(-1),
+		MinZ: math.Inf(1), MaxZ: math.Inf(-1),
+	}
+	found := false
+	for _, path := range m.Paths {
+		if path.ExtrusionAmount <= 0 {
+			continue
+		}
+		found = true
+		bounds.MinX = math.Min(bounds.MinX, math.Min(path.StartX, path.EndX))
+		bounds.MaxX = math.Max(bounds.MaxX, math.Max(path.StartX, path.EndX))
+		bounds.MinY = math.Min(bounds.MinY, math.Min(path.StartY, path.EndY))
+		bounds.MaxY = math.Max(bounds.MaxY, math.Max(path.StartY, path.EndY))
+		bounds.MinZ = math.Min(bounds.MinZ, math.Min(path.StartZ, path.EndZ))
+		bounds.MaxZ = math.Max(bounds.MaxZ, math.Max(path.StartZ, path.EndZ))
+	}
+	return bounds, found
+}
+
+// BuildVolumeIssues lists the ways a print does not fit the printer,
+// including the copy printed by the second IDEX carriage. An empty list
+// means the print fits.
+func BuildVolumeIssues(model *GCodeModel, t GCodeTransform, profile *PrinterProfile) []string {
+	bed := bedFromProfile(profile)
+	if bed == nil {
+		return []string{"Printer build volume is unknown"}
+	}
+	bounds, ok := model.extrusionBounds()
+	if !ok {
+		return nil
+	}
+
+	issues := checkBedExtent("Part", bounds.MinX, bounds.MaxX, bounds.MinY, bounds.MaxY, bed)
+	if bed.SizeZ > 0 && bounds.MaxZ > bed.SizeZ+0.001 {
+		issues = append(issues, fmt.Sprintf("Part is %.1f mm tall, the printer reaches %.1f mm", bounds.MaxZ, bed.SizeZ))
+	}
+
+	switch t.Carriage {
+	case IDEXModeDuplication:
+		width := bounds.MaxX - bounds.MinX
+		if t.CarriageOffsetX < width {
+			issues = append(issues, fmt.Sprintf(
+				"Carriages would collide: the copy offset (%.1f mm) is less than the part width (%.1f mm)",
+				t.CarriageOffsetX, width))
+		}
+		issues = append(issues, checkBedExtent("Copy",
+			bounds.MinX+t.CarriageOffsetX, bounds.MaxX+t.CarriageOffsetX, bounds.MinY, bounds.MaxY, bed)...)
+	case IDEXModeMirror:
+		if bounds.MaxX > t.CenterX {
+			issues = append(issues, fmt.Sprintf(
+				"Part crosses the bed centre (X %.1f); mirror mode needs it on the left half", t.CenterX))
+		}
+		issues = append(issues, checkBedExtent("Mirrored copy",
+			2*t.CenterX-bounds.MaxX, 2*t.CenterX-bounds.MinX, bounds.MinY, bounds.MaxY, bed)...)
+	}
+
+	return issues
+}
+
+// checkBedExtent reports how far an XY extent reaches past the bed edges
+func checkBedExtent(what string, minX, maxX, minY, maxY float64, bed *bedVolume) []string {
+	const tolerance = 0.001
+	issues := []string{}
+	if over := bed.OriginX - minX; over > tolerance {
+		issues = append(issues, fmt.Sprintf("%s extends %.1f mm past the left edge", what, over))
+	}
+	if over := maxX - (bed.OriginX + bed.SizeX); over > tolerance {
+		issues = append(issues, fmt.Sprintf("%s extends %.1f mm past the right edge", what, over))
+	}
+	if over := bed.OriginY - minY; over > tolerance {
+		issues = append(issues, fmt.Sprintf("%s extends %.1f mm past the front edge", what, over))
+	}
+	if over := maxY - (bed.OriginY + bed.SizeY); over > tolerance {
+		issues = append(issues, fmt.Sprintf("%s extends %.1f mm past the back edge", what, over))
+	}
+	return issues
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// transformLines transforms G-code text line by line
+func transformLines(t GCodeTransform, lines ...string) []string {
+	transformer := &gcodeLineTransformer{transform: t}
+	out := []string{}
+	for _, line := range lines {
+		out = append(out, transformer.transformLine(line))
+	}
+	return out
+}
+
+func TestTransformLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform GCodeTransform
+		lines     []string
+		want      []string
+	}{
+		{
+			"translate",
+			GCodeTransform{TranslateX: 10, TranslateY: -5},
+			[]string{"G1 X20 Y30 E1.5 ; perimeter", "G0 Z0.4", "G92 X0 Y0", "M104 S210"},
+			[]string{"G1 X30 Y25 E1.5 ; perimeter", "G0 Z0.4", "G92 X10 Y-5", "M104 S210"},
+		},
+		{
+			"mirror about the centre after translating",
+			GCodeTransform{TranslateX: 10, MirrorX: true, CenterX: 100},
+			[]string{"G1 X20 Y30", "G1 X190"}, // Lands on -0
+			[]string{"G1 X170 Y30", "G1 X0"},
+		},
+		{
+			"relative moves are not translated but are mirrored",
+			GCodeTransform{TranslateX: 10, TranslateY: 10, MirrorX: true, CenterX: 100},
+			[]string{"G91", "G1 X5 Y-2 E0.3", "G92 X0 Y0", "G90", "G1 X5 Y5"},
+			[]string{"G91", "G1 X-5 Y-2 E0.3", "G92 X190 Y10", "G90", "G1 X185 Y15"},
+		},
+		{
+			"mirrored arcs turn the other way",
+			GCodeTransform{MirrorX: true, CenterX: 100},
+			[]string{"G2 X60 Y50 I-10 J0", "G3 X40 Y50 I10 J0"},
+			[]string{"G3 X140 Y50 I10 J0", "G2 X160 Y50 I-10 J0"},
+		},
+		{
+			"object definitions follow the part",
+			GCodeTransform{TranslateX: 5, TranslateY: 5},
+			[]string{"EXCLUDE_OBJECT_DEFINE NAME=part CENTER=10,20 POLYGON=[[0,0],[20,0],[20,40]]"},
+			[]string{"EXCLUDE_OBJECT_DEFINE NAME=part CENTER=15,25 POLYGON=[[5,5],[25,5],[25,45]]"},
+		},
+		{
+			"no geometry change leaves lines alone",
+			GCodeTransform{Carriage: IDEXModeDuplication, CarriageOffsetX: 150},
+			[]string{"G1 X20.000 Y30 ; as sliced"},
+			[]string{"G1 X20.000 Y30 ; as sliced"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := transformLines(test.transform, test.lines...); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSecondCarriageX(t *testing.T) {
+	tests := []struct {
+		transform GCodeTransform
+		x, want   float64
+		active    bool
+	}{
+		{GCodeTransform{Carriage: IDEXModeNormal}, 40, 40, false},
+		{GCodeTransform{Carriage: IDEXModeDuplication, CarriageOffsetX: 150}, 40, 190, true},
+		{GCodeTransform{Carriage: IDEXModeMirror, CenterX: 150}, 40, 260, true},
+	}
+	for _, test := range tests {
+		if got, active := test.transform.SecondCarriageX(test.x); got != test.want || active != test.active {
+			t.Errorf("%s: SecondCarriageX(%g) = %g, %v; want %g, %v",
+				IDEXModeNames[test.transform.Carriage], test.x, got, active, test.want, test.active)
+		}
+	}
+}
+
+func TestModeCommand(t *testing.T) {
+	tests := []struct {
+		transform GCodeTransform
+		want      string
+	}{
+		{GCodeTransform{Carriage: IDEXModeNormal}, "M605 S1"},
+		{GCodeTransform{Carriage: IDEXModeDuplication, CarriageOffsetX: 152.5}, "M605 S2 X152.5"},
+		{GCodeTransform{Carriage: IDEXModeMirror, CenterX: 150}, "M605 S3"},
+	}
+	for _, test := range tests {
+		if got := test.transform.ModeCommand(); got != test.want {
+			t.Errorf("%s: ModeCommand = %q, want %q", IDEXModeNames[test.transform.Carriage], got, test.want)
+		}
+	}
+	for name := range map[string]bool{"Normal": true, "Duplication Mode": true, "Mirror Mode": true} {
+		if mode, ok := idexModeNamed(name); !ok || IDEXModeNames[mode] != name {
+			t.Errorf("idexModeNamed(%q) = %v, %v", name, mode, ok)
+		}
+	}
+}
+
+// transformSource is a small print with a start sequence before the first move
+const transformSource = `; sliced for an IDEX printer
+M104 S210
+
+G28
+G1 X10 Y10 Z0.2 F3000
+G1 X30 Y10 E1
+G1 X30 Y30 E1`
+
+func TestWriteTransformedGCode(t *testing.T) {
+	model, err := NewGCodeParser().ParseGCode(strings.NewReader(transformSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(transform GCodeTransform) string {
+		var out strings.Builder
+		if err := WriteTransformedGCode(model, transform, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	// The mode is selected before the first move and reset at the end
+	got := write(GCodeTransform{TranslateX: 5, Carriage: IDEXModeDuplication, CarriageOffsetX: 150})
+	want := `; sliced for an IDEX printer
+M104 S210
+
+G28
+M605 S2 X150 ; Duplication Mode
+G1 X15 Y10 Z0.2 F3000
+G1 X35 Y10 E1
+G1 X35 Y30 E1
+M605 S1 ; Normal
+`
+	if got != want {
+		t.Errorf("duplication file differs\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := write(GCodeTransform{Carriage: IDEXModeMirror, CenterX: 150}); !strings.Contains(got, "G28\nM605 S3 ; Mirror Mode\nG1 X10 Y10") {
+		t.Errorf("mirror file does not select mirror mode before the first move:\n%s", got)
+	}
+	if got := write(GCodeTransform{}); got != transformSource+"\n" {
+		t.Errorf("normal file should be unchanged:\n%s", got)
+	}
+	if err := WriteTransformedGCode(nil, GCodeTransform{}, &strings.Builder{}); err == nil {
+		t.Error("expected an error without a model")
+	}
+}
+
+func TestBuildVolumeIssues(t *testing.T) {
+	model, err := NewGCodeParser().ParseGCode(strings.NewReader(transformSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := &PrinterProfile{BuildVolume: map[string]float64{"x": 300, "y": 200, "z": 250}}
+	tests := []struct {
+		name      string
+		transform GCodeTransform
+		want      []string
+	}{
+		{"fits", GCodeTransform{}, []string{}},
+		{"moved off the bed", GCodeTransform{TranslateX: 280, TranslateY: -20}, []string{
+			"Part extends 10.0 mm past the right edge",
+			"Part extends 10.0 mm past the front edge",
+		}},
+		{"copy fits", GCodeTransform{Carriage: IDEXModeDuplication, CarriageOffsetX: 150}, []string{}},
+		{"copy too close", GCodeTransform{Carriage: IDEXModeDuplication, CarriageOffsetX: 15}, []string{
+			"Carriages would collide: the copy offset (15.0 mm) is less than the part width (20.0 mm)",
+		}},
+		{"copy off the bed", GCodeTransform{Carriage: IDEXModeDuplication, CarriageOffsetX: 280}, []string{
+			"Copy extends 10.0 mm past the right edge",
+		}},
+		{"mirror across the centre", GCodeTransform{TranslateX: 130, Carriage: IDEXModeMirror, CenterX: 150}, []string{
+			"Part crosses the bed centre (X 150.0); mirror mode needs it on the left half",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transformed, err := TransformGCode(model, test.transform)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := BuildVolumeIssues(transformed, test.transform, profile)
+			if got == nil {
+				got = []string{}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("issues %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if issues := BuildVolumeIssues(model, GCodeTransform{}, nil); len(issues) != 1 {
+		t.Errorf("unknown bed gave %q", issues)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createTransformControls creates the card for moving, mirroring and
+// duplicating the print and checking it still fits the printer
+func (ui *GCodeViewerUI) createTransformControls() *widget.Card {
+	ui.transformXEntry = widget.NewEntry()
+	ui.transformXEntry.SetText("0")
+	ui.transformYEntry = widget.NewEntry()
+	ui.transformYEntry.SetText("0")
+	ui.transformMirrorCheck = widget.NewCheck("Mirror X", nil)
+
+	modes := []string{
+		IDEXModeNames[IDEXModeNormal],
+		IDEXModeNames[IDEXModeDuplication],
+		IDEXModeNames[IDEXModeMirror],
+	}
+	ui.transformOffsetEntry = widget.NewEntry()
+	ui.transformOffsetEntry.SetPlaceHolder("half the bed")
+	ui.transformModeSelect = widget.NewSelect(modes, func(selected string) {
+		if selected == IDEXModeNames[IDEXModeDuplication] {
+			ui.transformOffsetEntry.Enable()
+		} else {
+			ui.transformOffsetEntry.Disable()
+		}
+	})
+	ui.transformModeSelect.SetSelected(IDEXModeNames[IDEXModeNormal])
+
+	ui.transformStatus = widget.NewLabel("")
+	ui.transformStatus.Wrapping = fyne.TextWrapWord
+	ui.updateTransformStatus()
+
+	applyBtn := widget.NewButton("Apply", func() {
+		ui.applyTransform()
+	})
+	resetBtn := widget.NewButton("Reset", func() {
+		if ui.previewKind == previewTransform {
+			ui.discardPreview()
+		}
+	})
+	saveBtn := widget.NewButton("Save G-code", func() {
+		ui.saveTransformed()
+	})
+	uploadBtn := widget.NewButton("Upload", func() {
+		ui.uploadTransformed()
+	})
+
+	return widget.NewCard("Transform", "", container.NewVBox(
+		widget.NewForm(
+			widget.NewFormItem("Move X (mm)", ui.transformXEntry),
+			widget.NewFormItem("Move Y (mm)", ui.transformYEntry),
+			widget.NewFormItem("IDEX", ui.transformModeSelect),
+			widget.NewFormItem("Copy offset", ui.transformOffsetEntry),
+		),
+		ui.transformMirrorCheck,
+		container.NewGridWithColumns(2, applyBtn, resetBtn),
+		ui.transformStatus,
+		container.NewGridWithColumns(2, saveBtn, uploadBtn),
+	))
+}
+
+// transformFromControls reads the transform entered in the card
+func (ui *GCodeViewerUI) transformFromControls() (GCodeTransform, error) {
+	t := GCodeTransform{MirrorX: ui.transformMirrorCheck.Checked}
+
+	var err error
+	if t.TranslateX, err = parseFormFloat("move X", ui.transformXEntry.Text); err != nil {
+		return t, err
+	}
+	if t.TranslateY, err = parseFormFloat("move Y", ui.transformYEntry.Text); err != nil {
+		return t, err
+	}
+
+	t.Carriage, _ = idexModeNamed(ui.transformModeSelect.Selected)
+
+	// Mirror about the bed centre, or the part's own centre if the bed is unknown
+	bed := bedFromProfile(ui.printerProfile)
+	if bed != nil {
+		t.CenterX = bed.OriginX + bed.SizeX/2
+	} else if bounds, ok := ui.sourceModel().extrusionBounds(); ok {
+		t.CenterX = (bounds.MinX + bounds.MaxX) / 2
+	}
+
+	if t.Carriage == IDEXModeDuplication {
+		if strings.TrimSpace(ui.transformOffsetEntry.Text) != "" {
+			if t.CarriageOffsetX, err = parseFormFloat("copy offset", ui.transformOffsetEntry.Text); err != nil {
+				return t, err
+			}
+		} else if bed != nil {
+			t.CarriageOffsetX = bed.SizeX / 2
+		} else {
+			return t, fmt.Errorf("enter the copy offset, the bed size is unknown")
+		}
+	}
+
+	return t, nil
+}
+
+// applyTransform previews the transformed print and checks the build volume
+func (ui *GCodeViewerUI) applyTransform() {
+	source := ui.sourceModel()
+	if source == nil {
+		dialog.ShowInformation("Transform", "Load a G-code file first", ui.window)
+		return
+	}
+	t, err := ui.transformFromControls()
+	if err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+
+	progressDialog := dialog.NewProgressInfinite("Transform", "Transforming G-code...", ui.window)
+	progressDialog.Show()
+
+	go func() {
+		model, err := TransformGCode(source, t)
+		progressDialog.Hide()
+
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to transform G-code: %v", err), ui.window)
+			return
+		}
+
+		ui.transformIssues = BuildVolumeIssues(model, t, ui.printerProfile)
+		ui.viewer.SetSecondCarriage(t)
+		ui.showPreview(previewTransform, model)
+	}()
+}
+
+// updateTransformStatus shows the build volume check of the applied transform
+func (ui *GCodeViewerUI) updateTransformStatus() {
+	switch {
+	case ui.previewKind != previewTransform:
+		ui.transformStatus.SetText("No transform applied")
+	case len(ui.transformIssues) == 0:
+		ui.transformStatus.SetText("✓ Fits the build volume")
+	default:
+		ui.transformStatus.SetText("⚠ " + strings.Join(ui.transformIssues, "\n⚠ "))
+	}
+}
+
+// saveTransformed writes the transformed G-code to a local file
+func (ui *GCodeViewerUI) saveTransformed() {
+	source := ui.sourceModel()
+	if source == nil {
+		dialog.ShowInformation("Transform", "Load a G-code file first", ui.window)
+		return
+	}
+	t, err := ui.transformFromControls()
+	if err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+
+	ui.checkTransformFits("Save G-code", source, t, func() {
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil || writer == nil {
+				return
+			}
+			defer writer.Close()
+
+			if err := WriteTransformedGCode(source, t, writer); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to save G-code: %v", err), ui.window)
+			}
+		}, ui.window)
+		saveDialog.SetFileName(ui.exportBaseName() + "_transformed.gcode")
+		saveDialog.Show()
+	})
+}
+
+// uploadTransformed streams the transformed G-code to the printer
+func (ui *GCodeViewerUI) uploadTransformed() {
+	source := ui.sourceModel()
+	if ui.backend == nil || source == nil {
+		dialog.ShowError(fmt.Errorf("load a file and connect to a printer first"), ui.window)
+		return
+	}
+	t, err := ui.transformFromControls()
+	if err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+
+	ui.checkTransformFits("Upload Transformed", source, t, func() {
+		ui.confirmUploadTransformed(source, t)
+	})
+}
+
+// confirmUploadTransformed asks for the uploaded file's name and streams it
+func (ui *GCodeViewerUI) confirmUploadTransformed(source *GCodeModel, t GCodeTransform) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(ui.exportBaseName() + "_transformed.gcode")
+
+	dialog.ShowForm("Upload Transformed", "Upload", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("File name", nameEntry),
+	}, func(confirmed bool) {
+		name := strings.TrimSpace(nameEntry.Text)
+		if !confirmed || name == "" {
+			return
+		}
+
+		progressDialog := dialog.NewProgressInfinite("Upload", "Uploading "+name+"...", ui.window)
+		progressDialog.Show()
+
+		go func() {
+			reader, writer := io.Pipe()
+			go func() {
+				writer.CloseWithError(WriteTransformedGCode(source, t, writer))
+			}()
+			err := ui.backend.UploadStream(name, reader)
+			reader.Close()
+			progressDialog.Hide()
+
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to upload %s: %v", name, err), ui.window)
+				return
+			}
+			dialog.ShowInformation("Upload", fmt.Sprintf("%s sent to the printer", name), ui.window)
+		}()
+	}, ui.window)
+}
+
+// checkTransformFits checks the transformed print against the build volume
+// before it is written. A print that does not fit is refused; when the bed
+// size is unknown the user is asked whether to go ahead.
+func (ui *GCodeViewerUI) checkTransformFits(title string, source *GCodeModel, t GCodeTransform, write func()) {
+	progressDialog := dialog.NewProgressInfinite(title, "Checking the build volume...", ui.window)
+	progressDialog.Show()
+
+	go func() {
+		model, err := TransformGCode(source, t)
+		progressDialog.Hide()
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to transform G-code: %v", err), ui.window)
+			return
+		}
+
+		issues := BuildVolumeIssues(model, t, ui.printerProfile)
+		switch {
+		case len(issues) == 0:
+			write()
+		case bedFromProfile(ui.printerProfile) == nil:
+			dialog.ShowConfirm(title, "The printer's build volume is unknown, so the transformed print cannot be checked.\nContinue anyway?",
+				func(confirmed bool) {
+					if confirmed {
+						write()
+					}
+				}, ui.window)
+		default:
+			dialog.ShowError(fmt.Errorf("the transformed print does not fit the printer:\n%s", strings.Join(issues, "\n")), ui.window)
+		}
+	}()
+}
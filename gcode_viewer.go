@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"image/color"
 	"math"
+	"sync"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -17,17 +18,32 @@ type GCodeViewer struct {
 	// Data
 	model         *GCodeModel
 	currentLayer  int
-	currentLine   int
+	currentLine   int // Index into model.Commands
 	visibleLayers []int
 	
+	// Print head tracking
+	currentSourceLine int               // Source line of currentLine
+	headState         GCodeMachineState // Machine state after currentLine
+	headCommand       int               // Last command applied to headState
+	
 	// 3D view settings
+	cameraMu      sync.Mutex // Guards camera; inertia and transitions move it from their own goroutines
 	camera        Camera3D
 	width         float32
 	height        float32
+	bed           *bedVolume // Build volume from the printer profile, nil if unknown
 	
 	// Display options
 	showTravelMoves   bool
 	showSupports      bool
+	layerView2D       bool             // Top-down single layer at true scale
+	savedCamera       Camera3D         // 3D camera restored when leaving 2D mode
+	savedLayers       []int            // Visible layers restored when leaving 2D mode
+	sectionPlane      SectionPlane     // Vertical clipping plane
+	showObjects       bool             // Outline and label labelled objects
+	cancelledObjects  map[int]bool     // Object indices cancelled during the print
+	secondCarriage    GCodeTransform   // IDEX carriage mode drawn as a ghost copy
+	comparison        *GCodeComparison // Other file overlaid on the current layer
 	pathColors        map[PathType]color.Color
 	backgroundColor   color.Color
 	
@@ -41,16 +57,26 @@ type GCodeViewer struct {
 	lastDragPos       fyne.Position
 	touchStartPos     fyne.Position
 	touchStartTime    int64
+	dragMode          gestureMode
+	inertia           viewerInertia
+	
+	// Selection
+	selectedPath      int
+	onPathSelected    func(pathIndex int)
+	onObjectTapped    func(objectIndex int)
+	pickIndex         *pickCache
 }
 
 // Camera3D represents the 3D view camera
 type Camera3D struct {
-	RotationX    float64 // Rotation around X axis (pitch)
-	RotationY    float64 // Rotation around Y axis (yaw)
-	RotationZ    float64 // Rotation around Z axis (roll)
-	Zoom         float64 // Zoom level
-	PanX, PanY   float64 // Pan offset
-	Distance     float64 // Distance from object
+	RotationX    float64        // Pitch in degrees: 0 looks down, -90 looks from the front
+	RotationY    float64        // Yaw in degrees around the bed's vertical axis
+	RotationZ    float64        // Rotation around Z axis (roll)
+	Zoom         float64        // Zoom level
+	PanX, PanY   float64        // Pan offset in pixels
+	Distance     float64        // Distance from target in mm
+	Target       Point3D        // Point the camera orbits around
+	Projection   ProjectionMode // Perspective or orthographic
 }
 
 // Point3D represents a 3D point
@@ -67,17 +93,22 @@ type Point2D struct {
 func NewGCodeViewer() *GCodeViewer {
 	viewer := &GCodeViewer{
 		currentLayer:    0,
+		selectedPath:    -1,
 		visibleLayers:   make([]int, 0),
 		showTravelMoves: false,
 		showSupports:    true,
+		showObjects:     true,
 		animationSpeed:  1.0,
 		backgroundColor: color.NRGBA{R: 20, G: 20, B: 25, A: 255},
 		
+		cancelledObjects: make(map[int]bool),
+		
 		camera: Camera3D{
-			RotationX: -30,
-			RotationY: 45,
-			Zoom:      1.0,
-			Distance:  200,
+			RotationX:  viewPresetAngles[ViewIso][0],
+			RotationY:  viewPresetAngles[ViewIso][1],
+			Zoom:       1.0,
+			Distance:   200,
+			Projection: ProjectionPerspective,
 		},
 		
 		pathColors: map[PathType]color.Color{
@@ -99,6 +130,10 @@ func (v *GCodeViewer) LoadGCode(model *GCodeModel) {
 	v.model = model
 	v.currentLayer = 0
 	v.currentLine = 0
+	v.resetHead()
+	v.selectedPath = -1
+	v.pickIndex = nil
+	v.cancelledObjects = make(map[int]bool)
 	v.visibleLayers = make([]int, len(model.Layers))
 	for i := range v.visibleLayers {
 		v.visibleLayers[i] = i
@@ -158,6 +193,9 @@ func (r *gcodeViewerRenderer) Objects() []fyne.CanvasObject {
 	// Draw G-code paths
 	objects = append(objects, r.drawGCodePaths()...)
 	
+	// Draw object outlines and labels
+	objects = append(objects, r.drawObjects()...)
+	
 	// Draw current position indicator
 	objects = append(objects, r.drawCurrentPosition()...)
 	
@@ -167,147 +205,213 @@ func (r *gcodeViewerRenderer) Objects() []fyne.CanvasObject {
 	return objects
 }
 
+// sceneLine is a projected line segment shared by the on-screen renderer and offscreen export
+type sceneLine struct {
+	start, end Point2D
+	color      color.Color
+	width      float32
+}
+
 // drawBuildPlatform draws the build platform grid
 func (r *gcodeViewerRenderer) drawBuildPlatform() []fyne.CanvasObject {
-	objects := []fyne.CanvasObject{}
-	
-	if r.viewer.model == nil {
-		return objects
+	return lineObjects(r.viewer.platformLines(r.viewer.screenProjection()))
+}
+
+// drawGCodePaths draws the 3D printing paths
+func (r *gcodeViewerRenderer) drawGCodePaths() []fyne.CanvasObject {
+	project := r.viewer.screenProjection()
+	lines := r.viewer.pathLines(project, r.viewer.displayedLayers(), r.viewer.currentSourceLine)
+	return lineObjects(append(lines, r.viewer.compareLines(project)...))
+}
+
+// lineObjects converts scene lines to canvas lines
+func lineObjects(lines []sceneLine) []fyne.CanvasObject {
+	objects := make([]fyne.CanvasObject, 0, len(lines))
+	for _, l := range lines {
+		line := canvas.NewLine(l.color)
+		line.Position1 = fyne.NewPos(l.start.X, l.start.Y)
+		line.Position2 = fyne.NewPos(l.end.X, l.end.Y)
+		line.StrokeWidth = l.width
+		objects = append(objects, line)
 	}
+	return objects
+}
+
+// platformLines returns the bed grid, bed outline and origin axes.
+// Without a printer profile the grid covers the model bounds.
+func (v *GCodeViewer) platformLines(project viewProjection) []sceneLine {
+	lines := []sceneLine{}
 	
-	bounds := r.viewer.model.Bounds
-	centerX := (bounds.MinX + bounds.MaxX) / 2
-	centerY := (bounds.MinY + bounds.MaxY) / 2
+	if v.model == nil {
+		return lines
+	}
 	
-	// Draw grid lines
-	gridSize := 10.0
-	gridColor := color.NRGBA{R: 60, G: 60, B: 60, A: 255}
+	bounds := v.model.Bounds
+	minX, minY, maxX, maxY, z := bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY, bounds.MinZ
+	if v.bed != nil {
+		minX, minY = v.bed.OriginX, v.bed.OriginY
+		maxX, maxY = v.bed.OriginX+v.bed.SizeX, v.bed.OriginY+v.bed.SizeY
+		z = 0
+	}
 	
-	// Vertical lines
-	for x := math.Floor(bounds.MinX/gridSize)*gridSize; x <= bounds.MaxX; x += gridSize {
-		start := r.viewer.project3DTo2D(Point3D{X: x, Y: bounds.MinY, Z: bounds.MinZ})
-		end := r.viewer.project3DTo2D(Point3D{X: x, Y: bounds.MaxY, Z: bounds.MinZ})
-		
-		line := canvas.NewLine(gridColor)
-		line.Position1 = fyne.NewPos(start.X, start.Y)
-		line.Position2 = fyne.NewPos(end.X, end.Y)
-		line.StrokeWidth = 1
-		objects = append(objects, line)
+	addLine := func(a, b Point3D, c color.Color, width float32) {
+		start, end, ok := project.segment(a, b)
+		if ok {
+			lines = append(lines, sceneLine{start: start, end: end, color: c, width: width})
+		}
 	}
 	
-	// Horizontal lines
-	for y := math.Floor(bounds.MinY/gridSize)*gridSize; y <= bounds.MaxY; y += gridSize {
-		start := r.viewer.project3DTo2D(Point3D{X: bounds.MinX, Y: y, Z: bounds.MinZ})
-		end := r.viewer.project3DTo2D(Point3D{X: bounds.MaxX, Y: y, Z: bounds.MinZ})
-		
-		line := canvas.NewLine(gridColor)
-		line.Position1 = fyne.NewPos(start.X, start.Y)
-		line.Position2 = fyne.NewPos(end.X, end.Y)
-		line.StrokeWidth = 1
-		objects = append(objects, line)
+	gridColor := color.NRGBA{R: 60, G: 60, B: 60, A: 255}
+	majorColor := color.NRGBA{R: 90, G: 90, B: 90, A: 255}
+	gridStyle := func(value float64) color.Color {
+		if int(math.Round(value/bedGridSpacing))%bedGridMajorEvery == 0 {
+			return majorColor
+		}
+		return gridColor
 	}
 	
-	// Center axes
-	axisColor := color.NRGBA{R: 255, G: 255, B: 255, A: 128}
+	// Grid lines on multiples of the spacing in machine coordinates
+	for x := math.Ceil(minX/bedGridSpacing) * bedGridSpacing; x <= maxX; x += bedGridSpacing {
+		addLine(Point3D{X: x, Y: minY, Z: z}, Point3D{X: x, Y: maxY, Z: z}, gridStyle(x), 1)
+	}
+	for y := math.Ceil(minY/bedGridSpacing) * bedGridSpacing; y <= maxY; y += bedGridSpacing {
+		addLine(Point3D{X: minX, Y: y, Z: z}, Point3D{X: maxX, Y: y, Z: z}, gridStyle(y), 1)
+	}
 	
-	// X axis
-	xStart := r.viewer.project3DTo2D(Point3D{X: centerX - 20, Y: centerY, Z: bounds.MinZ})
-	xEnd := r.viewer.project3DTo2D(Point3D{X: centerX + 20, Y: centerY, Z: bounds.MinZ})
-	xAxis := canvas.NewLine(color.NRGBA{R: 255, G: 0, B: 0, A: 255})
-	xAxis.Position1 = fyne.NewPos(xStart.X, xStart.Y)
-	xAxis.Position2 = fyne.NewPos(xEnd.X, xEnd.Y)
-	xAxis.StrokeWidth = 2
-	objects = append(objects, xAxis)
+	// Bed outline
+	outlineColor := color.NRGBA{R: 140, G: 140, B: 140, A: 255}
+	corners := []Point3D{{X: minX, Y: minY, Z: z}, {X: maxX, Y: minY, Z: z}, {X: maxX, Y: maxY, Z: z}, {X: minX, Y: maxY, Z: z}}
+	for i := range corners {
+		addLine(corners[i], corners[(i+1)%len(corners)], outlineColor, 2)
+	}
 	
-	// Y axis
-	yStart := r.viewer.project3DTo2D(Point3D{X: centerX, Y: centerY - 20, Z: bounds.MinZ})
-	yEnd := r.viewer.project3DTo2D(Point3D{X: centerX, Y: centerY + 20, Z: bounds.MinZ})
-	yAxis := canvas.NewLine(color.NRGBA{R: 0, G: 255, B: 0, A: 255})
-	yAxis.Position1 = fyne.NewPos(yStart.X, yStart.Y)
-	yAxis.Position2 = fyne.NewPos(yEnd.X, yEnd.Y)
-	yAxis.StrokeWidth = 2
-	objects = append(objects, yAxis)
+	// Axes at the machine origin, or the model centre without a bed (X red, Y green, Z blue)
+	axisLength := 20.0
+	origin := Point3D{X: 0, Y: 0, Z: z}
+	if v.bed == nil {
+		origin = Point3D{X: (minX + maxX) / 2, Y: (minY + maxY) / 2, Z: z}
+	}
+	addLine(origin, Point3D{X: origin.X + axisLength, Y: origin.Y, Z: z}, color.NRGBA{R: 255, G: 0, B: 0, A: 255}, 2)
+	addLine(origin, Point3D{X: origin.X, Y: origin.Y + axisLength, Z: z}, color.NRGBA{R: 0, G: 255, B: 0, A: 255}, 2)
+	addLine(origin, Point3D{X: origin.X, Y: origin.Y, Z: z + axisLength}, color.NRGBA{R: 0, G: 100, B: 255, A: 255}, 2)
 	
-	return objects
+	return lines
 }
 
-// drawGCodePaths draws the 3D printing paths
-func (r *gcodeViewerRenderer) drawGCodePaths() []fyne.CanvasObject {
-	objects := []fyne.CanvasObject{}
+// pathLines returns the paths of the given layers, coloured relative to the current source line
+func (v *GCodeViewer) pathLines(project viewProjection, layers []int, currentSourceLine int) []sceneLine {
+	lines := []sceneLine{}
 	
-	if r.viewer.model == nil || len(r.viewer.model.Paths) == 0 {
-		return objects
+	if v.model == nil || len(v.model.Paths) == 0 {
+		return lines
+	}
+	
+	// In 2D mode lines are drawn at their extruded width
+	pixelsPerMM := 0.0
+	if v.layerView2D {
+		pixelsPerMM = project.orthographicScale()
 	}
 	
-	// Draw paths for visible layers
-	for _, layerIndex := range r.viewer.visibleLayers {
-		if layerIndex >= len(r.viewer.model.Layers) {
+	for _, layerIndex := range layers {
+		if layerIndex < 0 || layerIndex >= len(v.model.Layers) {
 			continue
 		}
+		layerHeight := v.model.LayerHeight(layerIndex)
 		
-		layer := r.viewer.model.Layers[layerIndex]
-		
-		for _, pathIndex := range layer.Paths {
-			if pathIndex >= len(r.viewer.model.Paths) {
+		for _, pathIndex := range v.model.Layers[layerIndex].Paths {
+			if pathIndex >= len(v.model.Paths) {
 				continue
 			}
 			
-			path := r.viewer.model.Paths[pathIndex]
+			path := v.model.Paths[pathIndex]
 			
 			// Skip travel moves if disabled
-			if !r.viewer.showTravelMoves && path.PathType == PathTypeTravel {
+			if !v.showTravelMoves && path.PathType == PathTypeTravel {
 				continue
 			}
 			
 			// Skip supports if disabled
-			if !r.viewer.showSupports && path.PathType == PathTypeSupport {
+			if !v.showSupports && path.PathType == PathTypeSupport {
 				continue
 			}
 			
-			// Determine line color and thickness
-			pathColor := r.viewer.pathColors[path.PathType]
-			lineWidth := float32(1)
-			
-			// Highlight current and completed paths
-			if path.LineNumber <= r.viewer.currentLine {
-				// Already printed - make slightly dimmer
-				if path.PathType != PathTypeTravel {
-					pathColor = r.dimColor(pathColor, 0.8)
-				}
-			} else {
-				// Not yet printed - make much dimmer
-				pathColor = r.dimColor(pathColor, 0.3)
+			a, b, visible := v.clipToSection(
+				Point3D{X: path.StartX, Y: path.StartY, Z: path.StartZ},
+				Point3D{X: path.EndX, Y: path.EndY, Z: path.EndZ},
+			)
+			if !visible {
+				continue
 			}
 			
-			// Highlight current path
-			if path.LineNumber == r.viewer.currentLine {
-				pathColor = color.NRGBA{R: 255, G: 0, B: 255, A: 255} // Magenta for current
-				lineWidth = 3
+			start, end, ok := project.segment(a, b)
+			if !ok {
+				continue
 			}
 			
-			// Adjust line width based on path type
-			switch path.PathType {
-			case PathTypePerimeter:
-				lineWidth += 1
-			case PathTypeTravel:
-				lineWidth = 1
-			case PathTypeRetraction:
-				lineWidth = 2
+			pathColor, lineWidth := v.pathStyle(pathIndex, path, currentSourceLine)
+			if width := path.ExtrusionWidth(layerHeight); pixelsPerMM > 0 && width > 0 {
+				lineWidth = float32(math.Max(1, width*pixelsPerMM))
 			}
+			lines = append(lines, sceneLine{start: start, end: end, color: pathColor, width: lineWidth})
 			
-			// Project to 2D and draw line
-			start := r.viewer.project3DTo2D(Point3D{X: path.StartX, Y: path.StartY, Z: path.StartZ})
-			end := r.viewer.project3DTo2D(Point3D{X: path.EndX, Y: path.EndY, Z: path.EndZ})
-			
-			line := canvas.NewLine(pathColor)
-			line.Position1 = fyne.NewPos(start.X, start.Y)
-			line.Position2 = fyne.NewPos(end.X, end.Y)
-			line.StrokeWidth = lineWidth
-			objects = append(objects, line)
+			// Copy printed by the second IDEX carriage
+			if ghost, ok := v.secondCarriageLine(project, a, b, path, pathColor, lineWidth); ok {
+				lines = append(lines, ghost)
+			}
 		}
 	}
 	
-	return objects
+	return lines
+}
+
+// pathStyle determines line color and thickness for a path
+func (v *GCodeViewer) pathStyle(pathIndex int, path GCodePath, currentSourceLine int) (color.Color, float32) {
+	if v.comparison != nil {
+		return v.compareStyle(pathIndex, path)
+	}
+	
+	pathColor := v.pathColors[path.PathType]
+	lineWidth := float32(1)
+	
+	// Highlight current and completed paths
+	if path.LineNumber <= currentSourceLine {
+		// Already printed - make slightly dimmer
+		if path.PathType != PathTypeTravel {
+			pathColor = dimColor(pathColor, 0.8)
+		}
+	} else {
+		// Not yet printed - make much dimmer
+		pathColor = dimColor(pathColor, 0.3)
+	}
+	
+	// Grey out objects cancelled during the print
+	if path.ObjectIndex >= 0 && v.cancelledObjects[path.ObjectIndex] {
+		pathColor = cancelledPathColor
+	}
+	
+	// Highlight current path
+	if path.LineNumber == currentSourceLine {
+		pathColor = color.NRGBA{R: 255, G: 0, B: 255, A: 255} // Magenta for current
+		lineWidth = 3
+	}
+	
+	// Highlight the path picked by the user
+	if pathIndex == v.selectedPath {
+		pathColor = color.NRGBA{R: 0, G: 255, B: 200, A: 255} // Cyan for selection
+		lineWidth = 4
+	}
+	
+	// Adjust line width based on path type
+	switch path.PathType {
+	case PathTypePerimeter:
+		lineWidth += 1
+	case PathTypeTravel:
+		lineWidth = 1
+	case PathTypeRetraction:
+		lineWidth = 2
+	}
+	
+	return pathColor, lineWidth
 }
 
 // drawCurrentPosition draws the current print head position
@@ -318,27 +422,13 @@ func (r *gcodeViewerRenderer) drawCurrentPosition() []fyne.CanvasObject {
 		return objects
 	}
 	
-	// Find current position from executed commands
-	var currentX, currentY, currentZ float64
-	
-	for i := 0; i <= r.viewer.currentLine && i < len(r.viewer.model.Commands); i++ {
-		cmd := r.viewer.model.Commands[i]
-		if cmd.Type == "G0" || cmd.Type == "G1" {
-			if !math.IsNaN(cmd.X) {
-				currentX = cmd.X
-			}
-			if !math.IsNaN(cmd.Y) {
-				currentY = cmd.Y
-			}
-			if !math.IsNaN(cmd.Z) {
-				currentZ = cmd.Z
-			}
-		}
+	// Draw print head indicator at the tracked machine position
+	head := r.viewer.headState
+	pos, ok := r.viewer.project3DTo2D(Point3D{X: head.X, Y: head.Y, Z: head.Z})
+	if !ok {
+		return objects
 	}
 	
-	// Draw print head indicator
-	pos := r.viewer.project3DTo2D(Point3D{X: currentX, Y: currentY, Z: currentZ})
-	
 	// Outer circle
 	outerCircle := canvas.NewCircle(color.NRGBA{R: 255, G: 0, B: 0, A: 255})
 	outerCircle.Resize(fyne.NewSize(12, 12))
@@ -369,6 +459,17 @@ func (r *gcodeViewerRenderer) drawUIOverlay() []fyne.CanvasObject {
 	layerLabel.TextSize = 14
 	objects = append(objects, layerLabel)
 	
+	// Legend of the compared files
+	if r.viewer.comparison != nil {
+		loadedLabel := canvas.NewText("■ Loaded file", compareColorA)
+		loadedLabel.Move(fyne.NewPos(r.viewer.width-200, 10))
+		loadedLabel.TextSize = 12
+		comparedLabel := canvas.NewText("■ "+r.viewer.comparison.NameB, compareColorB)
+		comparedLabel.Move(fyne.NewPos(r.viewer.width-200, 28))
+		comparedLabel.TextSize = 12
+		objects = append(objects, loadedLabel, comparedLabel)
+	}
+	
 	// Progress info
 	progressPercent := float64(r.viewer.currentLine) / float64(len(r.viewer.model.Commands)) * 100
 	progressText := fmt.Sprintf("Progress: %.1f%%", progressPercent)
@@ -388,7 +489,7 @@ func (r *gcodeViewerRenderer) drawUIOverlay() []fyne.CanvasObject {
 	}
 	
 	// View controls hint
-	hintText := "Touch: Rotate | Pinch: Zoom | Double-tap: Reset"
+	hintText := "Drag: Rotate | Right-drag or Shift-drag: Pan | Scroll: Zoom | Double-tap: Reset"
 	hintLabel := canvas.NewText(hintText, color.NRGBA{R: 200, G: 200, B: 200, A: 255})
 	hintLabel.Move(fyne.NewPos(10, r.viewer.height-25))
 	hintLabel.TextSize = 10
@@ -397,69 +498,29 @@ func (r *gcodeViewerRenderer) drawUIOverlay() []fyne.CanvasObject {
 	return objects
 }
 
-// project3DTo2D projects 3D coordinates to 2D screen coordinates
-func (v *GCodeViewer) project3DTo2D(point Point3D) Point2D {
-	// Apply camera transformations
-	
-	// 1. Translate to origin (center the model)
-	bounds := v.model.Bounds
-	centerX := (bounds.MinX + bounds.MaxX) / 2
-	centerY := (bounds.MinY + bounds.MaxY) / 2
-	centerZ := (bounds.MinZ + bounds.MaxZ) / 2
-	
-	x := point.X - centerX
-	y := point.Y - centerY
-	z := point.Z - centerZ
-	
-	// 2. Apply rotations
-	// Rotate around X axis (pitch)
-	radX := v.camera.RotationX * math.Pi / 180
-	y1 := y*math.Cos(radX) - z*math.Sin(radX)
-	z1 := y*math.Sin(radX) + z*math.Cos(radX)
-	y = y1
-	z = z1
-	
-	// Rotate around Y axis (yaw)
-	radY := v.camera.RotationY * math.Pi / 180
-	x1 := x*math.Cos(radY) + z*math.Sin(radY)
-	z1 = -x*math.Sin(radY) + z*math.Cos(radY)
-	x = x1
-	z = z1
-	
-	// 3. Apply perspective projection
-	distance := v.camera.Distance
-	scale := v.camera.Zoom * 100 / (distance + z)
-	
-	// 4. Convert to screen coordinates
-	screenX := float32(x*scale + float64(v.width)/2 + v.camera.PanX)
-	screenY := float32(-y*scale + float64(v.height)/2 + v.camera.PanY) // Flip Y axis
-	
-	return Point2D{X: screenX, Y: screenY}
+// project3DTo2D projects 3D coordinates to 2D screen coordinates.
+// ok is false when the point is behind the camera.
+func (v *GCodeViewer) project3DTo2D(point Point3D) (Point2D, bool) {
+	return v.screenProjection().point(point)
 }
 
-// fitToView adjusts camera to fit the entire model
+// fitToView adjusts camera to fit the model and, when known, the bed
 func (v *GCodeViewer) fitToView() {
-	if v.model == nil {
+	if v.model == nil && v.bed == nil {
 		return
 	}
 	
-	bounds := v.model.Bounds
-	
-	// Calculate model size
-	sizeX := bounds.MaxX - bounds.MinX
-	sizeY := bounds.MaxY - bounds.MinY
-	sizeZ := bounds.MaxZ - bounds.MinZ
-	maxSize := math.Max(math.Max(sizeX, sizeY), sizeZ)
-	
-	// Adjust zoom and distance
-	v.camera.Zoom = 1.0
-	v.camera.Distance = maxSize * 2
-	v.camera.PanX = 0
-	v.camera.PanY = 0
+	target, distance := v.fitCamera()
+	v.moveCamera(func(c *Camera3D) {
+		c.Target, c.Distance = target, distance
+		c.Zoom = 1.0
+		c.PanX = 0
+		c.PanY = 0
+	})
 }
 
 // dimColor reduces the brightness of a color
-func (r *gcodeViewerRenderer) dimColor(c color.Color, factor float64) color.Color {
+func dimColor(c color.Color, factor float64) color.Color {
 	r, g, b, a := c.RGBA()
 	return color.NRGBA{
 		R: uint8(float64(r>>8) * factor),
@@ -475,6 +536,9 @@ func (v *GCodeViewer) SetCurrentLayer(layer int) {
 		return
 	}
 	v.currentLayer = layer
+	if v.layerView2D {
+		v.visibleLayers = []int{layer}
+	}
 	v.Refresh()
 }
 
@@ -484,9 +548,38 @@ func (v *GCodeViewer) SetCurrentLine(line int) {
 		return
 	}
 	v.currentLine = line
+	v.advanceHead(line)
 	v.Refresh()
 }
 
+// resetHead resets print head tracking to the start of the file
+func (v *GCodeViewer) resetHead() {
+	v.headState = NewGCodeMachineState()
+	v.headCommand = -1
+	v.currentSourceLine = 0
+}
+
+// advanceHead updates the tracked machine state to a command index.
+// Moving forward only replays the new commands; moving back replays from the start.
+func (v *GCodeViewer) advanceHead(commandIndex int) {
+	if commandIndex >= len(v.model.Commands) {
+		commandIndex = len(v.model.Commands) - 1
+	}
+	if commandIndex < v.headCommand {
+		v.resetHead()
+	}
+	
+	for i := v.headCommand + 1; i <= commandIndex; i++ {
+		v.headState.Apply(v.model.Commands[i])
+	}
+	v.headCommand = commandIndex
+	
+	if commandIndex >= 0 {
+		v.currentSourceLine = v.model.Commands[commandIndex].LineNumber
+		v.headState.LayerIndex = v.model.LayerIndexForLine(v.currentSourceLine)
+	}
+}
+
 // SetVisibleLayers sets which layers to display
 func (v *GCodeViewer) SetVisibleLayers(layers []int) {
 	v.visibleLayers = make([]int, len(layers))
@@ -509,34 +602,48 @@ func (v *GCodeViewer) ShowLayersUpTo(maxLayer int) {
 
 // Rotate rotates the camera view
 func (v *GCodeViewer) Rotate(deltaX, deltaY float64) {
-	v.camera.RotationY += deltaX * 0.5
-	v.camera.RotationX += deltaY * 0.5
+	// The 2D layer view stays top-down
+	if v.layerView2D {
+		return
+	}
 	
-	// Clamp rotation
-	v.camera.RotationX = math.Max(-90, math.Min(90, v.camera.RotationX))
+	v.moveCamera(func(c *Camera3D) {
+		c.RotationY += deltaX * 0.5
+		c.RotationX += deltaY * 0.5
+		
+		// Clamp pitch between the front view and the top view
+		c.RotationX = math.Max(-90, math.Min(0, c.RotationX))
+		c.RotationY = normalizeAngle(c.RotationY)
+	})
 	
 	v.Refresh()
 }
 
 // Zoom adjusts the zoom level
 func (v *GCodeViewer) Zoom(delta float64) {
-	v.camera.Zoom *= (1.0 + delta*0.1)
-	v.camera.Zoom = math.Max(0.1, math.Min(10.0, v.camera.Zoom))
+	v.moveCamera(func(c *Camera3D) {
+		c.Zoom *= (1.0 + delta*0.1)
+		c.Zoom = math.Max(cameraMinZoom, math.Min(cameraMaxZoom, c.Zoom))
+	})
 	v.Refresh()
 }
 
 // Pan adjusts the pan offset
 func (v *GCodeViewer) Pan(deltaX, deltaY float64) {
-	v.camera.PanX += deltaX
-	v.camera.PanY += deltaY
+	v.moveCamera(func(c *Camera3D) {
+		c.PanX += deltaX
+		c.PanY += deltaY
+	})
 	v.Refresh()
 }
 
-// ResetView resets the camera to default position
+// ResetView resets the camera to the iso view without animation
 func (v *GCodeViewer) ResetView() {
-	v.camera.RotationX = -30
-	v.camera.RotationY = 45
-	v.camera.RotationZ = 0
+	v.moveCamera(func(c *Camera3D) {
+		c.RotationX = viewPresetAngles[ViewIso][0]
+		c.RotationY = viewPresetAngles[ViewIso][1]
+		c.RotationZ = 0
+	})
 	v.fitToView()
 	v.Refresh()
 }
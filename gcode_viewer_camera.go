@@ -0,0 +1,353 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Camera settings
+const (
+	cameraFieldOfView    = 45.0 // Vertical field of view in degrees (perspective)
+	cameraNearPlane      = 1.0  // Near clipping distance in mm
+	cameraFitMargin      = 1.15 // Extra room around the fitted volume
+	cameraTransitionTime = 400 * time.Millisecond
+	cameraMinZoom        = 0.1
+	cameraMaxZoom        = 20.0
+	bedGridSpacing       = 10.0 // mm between grid lines
+	bedGridMajorEvery    = 5    // Every n-th grid line is drawn brighter
+)
+
+// ProjectionMode selects how the camera maps depth
+type ProjectionMode int
+
+const (
+	ProjectionPerspective ProjectionMode = iota
+	ProjectionOrthographic
+)
+
+// ProjectionModeNames provides display names for projection modes
+var ProjectionModeNames = map[ProjectionMode]string{
+	ProjectionPerspective:  "Perspective",
+	ProjectionOrthographic: "Orthographic",
+}
+
+// ViewPreset is a named camera orientation
+type ViewPreset int
+
+const (
+	ViewIso ViewPreset = iota
+	ViewTop
+	ViewFront
+	ViewSide
+)
+
+// ViewPresetNames provides display names for view presets
+var ViewPresetNames = map[ViewPreset]string{
+	ViewIso:   "Iso",
+	ViewTop:   "Top",
+	ViewFront: "Front",
+	ViewSide:  "Side",
+}
+
+// viewPresetAngles holds pitch (RotationX) and yaw (RotationY) per preset.
+// Pitch 0 looks straight down, -90 looks horizontally from the front.
+var viewPresetAngles = map[ViewPreset][2]float64{
+	ViewIso:   {-55, -45},
+	ViewTop:   {0, 0},
+	ViewFront: {-90, 0},
+	ViewSide:  {-90, -90},
+}
+
+// bedVolume is the printable volume in machine coordinates
+type bedVolume struct {
+	OriginX, OriginY    float64 // Machine coordinates of the front-left bed corner
+	SizeX, SizeY, SizeZ float64
+}
+
+// bedFromProfile reads the build volume of a printer profile. Beds whose
+// origin is not the front-left corner (e.g. delta printers) can set
+// "origin_x"/"origin_y" in BuildVolume.
+func bedFromProfile(profile *PrinterProfile) *bedVolume {
+	if profile == nil || profile.BuildVolume == nil {
+		return nil
+	}
+
+	bed := &bedVolume{
+		SizeX: profile.BuildVolume["x"],
+		SizeY: profile.BuildVolume["y"],
+		SizeZ: profile.BuildVolume["z"],
+	}
+	if x, ok := profile.BuildVolume["origin_x"]; ok {
+		bed.OriginX = x
+	}
+	if y, ok := profile.BuildVolume["origin_y"]; ok {
+		bed.OriginY = y
+	}
+
+	if bed.SizeX <= 0 || bed.SizeY <= 0 {
+		return nil
+	}
+	return bed
+}
+
+// viewProjection maps model coordinates into a viewport
+type viewProjection struct {
+	camera        Camera3D
+	width, height float32
+}
+
+// screenProjection returns the projection for the on-screen viewport
+func (v *GCodeViewer) screenProjection() viewProjection {
+	return viewProjection{camera: v.cameraState(), width: v.width, height: v.height}
+}
+
+// cameraState returns a copy of the camera, safe to use while gestures move it
+func (v *GCodeViewer) cameraState() Camera3D {
+	v.cameraMu.Lock()
+	defer v.cameraMu.Unlock()
+	return v.camera
+}
+
+// moveCamera changes the camera under its lock
+func (v *GCodeViewer) moveCamera(update func(c *Camera3D)) {
+	v.cameraMu.Lock()
+	update(&v.camera)
+	v.cameraMu.Unlock()
+}
+
+// toView transforms a point into camera space. Yaw turns the bed around its
+// vertical axis, then pitch tilts it towards the viewer. Returns the screen
+// right/up offsets and the depth in front of the camera.
+func (c Camera3D) toView(point Point3D) (x, y, depth float64) {
+	x = point.X - c.Target.X
+	y = point.Y - c.Target.Y
+	z := point.Z - c.Target.Z
+
+	// Yaw around the vertical (Z) axis
+	radY := c.RotationY * math.Pi / 180
+	x, y = x*math.Cos(radY)-y*math.Sin(radY), x*math.Sin(radY)+y*math.Cos(radY)
+
+	// Pitch around the screen X axis
+	radX := c.RotationX * math.Pi / 180
+	y, z = y*math.Cos(radX)-z*math.Sin(radX), y*math.Sin(radX)+z*math.Cos(radX)
+
+	return x, y, c.viewDistance() - z
+}
+
+// viewDistance is the camera distance from the target; perspective zoom dollies the camera
+func (c Camera3D) viewDistance() float64 {
+	if c.Projection == ProjectionPerspective && c.Zoom > 0 {
+		return c.Distance / c.Zoom
+	}
+	return c.Distance
+}
+
+// focalLength returns the pixels per mm at unit depth for a viewport
+func focalLength(width, height float32) float64 {
+	size := math.Min(float64(width), float64(height))
+	return size / 2 / math.Tan(cameraFieldOfView/2*math.Pi/180)
+}
+
+// toScreen converts a camera-space point to screen coordinates
+func (p viewProjection) toScreen(x, y, depth float64) Point2D {
+	focal := focalLength(p.width, p.height)
+
+	var scale float64
+	if p.camera.Projection == ProjectionOrthographic {
+		scale = p.orthographicScale()
+	} else {
+		scale = focal / depth
+	}
+
+	return Point2D{
+		X: float32(x*scale + float64(p.width)/2 + p.camera.PanX),
+		Y: float32(-y*scale + float64(p.height)/2 + p.camera.PanY), // Screen Y grows downwards
+	}
+}
+
+// orthographicScale returns pixels per mm in orthographic mode, matching
+// the perspective size at the target distance
+func (p viewProjection) orthographicScale() float64 {
+	return focalLength(p.width, p.height) / p.camera.Distance * p.camera.Zoom
+}
+
+// point projects a single point; ok is false behind the near plane
+func (p viewProjection) point(point Point3D) (Point2D, bool) {
+	x, y, depth := p.camera.toView(point)
+	if p.camera.Projection == ProjectionPerspective && depth < cameraNearPlane {
+		return Point2D{}, false
+	}
+	return p.toScreen(x, y, depth), true
+}
+
+// segment projects a line, clipping it against the near plane so lines
+// passing behind the camera do not flip across the screen
+func (p viewProjection) segment(a, b Point3D) (Point2D, Point2D, bool) {
+	ax, ay, ad := p.camera.toView(a)
+	bx, by, bd := p.camera.toView(b)
+
+	if p.camera.Projection == ProjectionPerspective {
+		if ad < cameraNearPlane && bd < cameraNearPlane {
+			return Point2D{}, Point2D{}, false
+		}
+		if ad < cameraNearPlane {
+			t := (cameraNearPlane - ad) / (bd - ad)
+			ax, ay, ad = ax+(bx-ax)*t, ay+(by-ay)*t, cameraNearPlane
+		} else if bd < cameraNearPlane {
+			t := (cameraNearPlane - bd) / (ad - bd)
+			bx, by, bd = bx+(ax-bx)*t, by+(ay-by)*t, cameraNearPlane
+		}
+	}
+
+	return p.toScreen(ax, ay, ad), p.toScreen(bx, by, bd), true
+}
+
+// SetPrinterProfile sets the printer used for the bed grid and fit-to-view
+func (v *GCodeViewer) SetPrinterProfile(profile *PrinterProfile) {
+	v.bed = bedFromProfile(profile)
+	v.fitToView()
+	v.Refresh()
+}
+
+// SetProjection switches between perspective and orthographic projection
+func (v *GCodeViewer) SetProjection(mode ProjectionMode) {
+	v.moveCamera(func(c *Camera3D) { c.Projection = mode })
+	v.Refresh()
+}
+
+// Projection returns the current projection mode
+func (v *GCodeViewer) Projection() ProjectionMode {
+	return v.cameraState().Projection
+}
+
+// fitCamera returns the target and distance that frame the model and bed
+func (v *GCodeViewer) fitCamera() (Point3D, float64) {
+	minX, minY, minZ := 0.0, 0.0, 0.0
+	maxX, maxY, maxZ := 0.0, 0.0, 0.0
+	hasVolume := false
+
+	if v.model != nil {
+		bounds := v.model.Bounds
+		minX, minY, minZ = bounds.MinX, bounds.MinY, bounds.MinZ
+		maxX, maxY, maxZ = bounds.MaxX, bounds.MaxY, bounds.MaxZ
+		hasVolume = true
+	}
+
+	if v.bed != nil {
+		bedMaxX := v.bed.OriginX + v.bed.SizeX
+		bedMaxY := v.bed.OriginY + v.bed.SizeY
+		if hasVolume {
+			minX, maxX = math.Min(minX, v.bed.OriginX), math.Max(maxX, bedMaxX)
+			minY, maxY = math.Min(minY, v.bed.OriginY), math.Max(maxY, bedMaxY)
+			minZ = math.Min(minZ, 0)
+		} else {
+			minX, minY, minZ = v.bed.OriginX, v.bed.OriginY, 0
+			maxX, maxY, maxZ = bedMaxX, bedMaxY, v.bed.SizeZ
+		}
+	}
+
+	target := Point3D{X: (minX + maxX) / 2, Y: (minY + maxY) / 2, Z: (minZ + maxZ) / 2}
+
+	// Distance at which the bounding sphere fills the field of view
+	radius := math.Sqrt(math.Pow(maxX-minX, 2)+math.Pow(maxY-minY, 2)+math.Pow(maxZ-minZ, 2)) / 2
+	if radius < 1 {
+		radius = 1
+	}
+	distance := radius / math.Sin(cameraFieldOfView/2*math.Pi/180) * cameraFitMargin
+
+	return target, distance
+}
+
+// AnimateToView moves the camera to a preset orientation and refits the view
+func (v *GCodeViewer) AnimateToView(preset ViewPreset) {
+	angles, ok := viewPresetAngles[preset]
+	if !ok || v.layerView2D {
+		return
+	}
+
+	end := v.cameraState()
+	end.RotationX = angles[0]
+	end.RotationY = angles[1]
+	end.RotationZ = 0
+	end.Zoom = 1.0
+	end.PanX = 0
+	end.PanY = 0
+	end.Target, end.Distance = v.fitCamera()
+
+	v.animateCamera(end)
+}
+
+// animateCamera eases the camera towards a target. The transition shares the
+// inertia stop channel so any new gesture interrupts it.
+func (v *GCodeViewer) animateCamera(end Camera3D) {
+	v.stopInertia()
+
+	start := v.cameraState()
+	// Turn the short way round
+	end.RotationY = start.RotationY + normalizeAngle(end.RotationY-start.RotationY)
+
+	stop := make(chan struct{})
+	v.inertia.mu.Lock()
+	v.inertia.stop = stop
+	v.inertia.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(gestureFrameInterval)
+		defer ticker.Stop()
+		began := time.Now()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				t := float64(time.Since(began)) / float64(cameraTransitionTime)
+				if t >= 1 {
+					v.inertia.mu.Lock()
+					if v.inertia.stop == stop {
+						v.inertia.stop = nil
+					}
+					v.inertia.mu.Unlock()
+
+					end.RotationY = normalizeAngle(end.RotationY)
+					v.moveCamera(func(c *Camera3D) { *c = end })
+					v.Refresh()
+					return
+				}
+				current := interpolateCamera(start, end, easeInOut(t))
+				v.moveCamera(func(c *Camera3D) { *c = current })
+				v.Refresh()
+			}
+		}
+	}()
+}
+
+// interpolateCamera blends two cameras; the projection mode is taken from the end
+func interpolateCamera(a, b Camera3D, t float64) Camera3D {
+	lerp := func(x, y float64) float64 { return x + (y-x)*t }
+	return Camera3D{
+		RotationX:  lerp(a.RotationX, b.RotationX),
+		RotationY:  lerp(a.RotationY, b.RotationY),
+		RotationZ:  lerp(a.RotationZ, b.RotationZ),
+		Zoom:       lerp(a.Zoom, b.Zoom),
+		PanX:       lerp(a.PanX, b.PanX),
+		PanY:       lerp(a.PanY, b.PanY),
+		Distance:   lerp(a.Distance, b.Distance),
+		Target:     Point3D{X: lerp(a.Target.X, b.Target.X), Y: lerp(a.Target.Y, b.Target.Y), Z: lerp(a.Target.Z, b.Target.Z)},
+		Projection: b.Projection,
+	}
+}
+
+// easeInOut is a smoothstep curve for camera transitions
+func easeInOut(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// normalizeAngle wraps an angle into [-180, 180)
+func normalizeAngle(angle float64) float64 {
+	angle = math.Mod(angle+180, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	return angle - 180
+}
@@ -0,0 +1,44 @@
+package main
+
+import "image/color"
+
+// ghostAlpha is the opacity of paths printed by the second IDEX carriage
+const ghostAlpha = 0.35
+
+// SetSecondCarriage shows the copy printed by the second IDEX carriage as a
+// ghost. Only the Carriage, CarriageOffsetX and CenterX fields are used.
+func (v *GCodeViewer) SetSecondCarriage(t GCodeTransform) {
+	v.secondCarriage = t
+	v.Refresh()
+}
+
+// secondCarriageLine returns the ghost of an extruding segment, if the second
+// carriage is printing
+func (v *GCodeViewer) secondCarriageLine(project viewProjection, a, b Point3D, path GCodePath, c color.Color, width float32) (sceneLine, bool) {
+	if path.ExtrusionAmount <= 0 {
+		return sceneLine{}, false
+	}
+	ax, active := v.secondCarriage.SecondCarriageX(a.X)
+	if !active {
+		return sceneLine{}, false
+	}
+	bx, _ := v.secondCarriage.SecondCarriageX(b.X)
+	a.X, b.X = ax, bx
+
+	start, end, ok := project.segment(a, b)
+	if !ok {
+		return sceneLine{}, false
+	}
+	return sceneLine{start: start, end: end, color: ghostColor(c), width: width}, true
+}
+
+// ghostColor makes a colour translucent
+func ghostColor(c color.Color) color.Color {
+	r, g, b, a := c.RGBA()
+	return color.NRGBA{
+		R: uint8(r >> 8),
+		G: uint8(g >> 8),
+		B: uint8(b >> 8),
+		A: uint8(float64(a>>8) * ghostAlpha),
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import "image/color"
+
+// Contrasting colours of the loaded file (A) and the compared file (B)
+var (
+	compareColorA = color.NRGBA{R: 0, G: 170, B: 255, A: 255}
+	compareColorB = color.NRGBA{R: 255, G: 90, B: 40, A: 255}
+)
+
+// SetComparison overlays the matching layer of the compared file on the
+// current layer. Pass nil to leave comparison mode.
+func (v *GCodeViewer) SetComparison(c *GCodeComparison) {
+	v.comparison = c
+	v.Refresh()
+}
+
+// displayedLayers returns the layers of the loaded file to draw; comparison
+// mode shows only the chosen layer so the overlay stays readable
+func (v *GCodeViewer) displayedLayers() []int {
+	if v.comparison != nil {
+		return []int{v.currentLayer}
+	}
+	return v.visibleLayers
+}
+
+// compareStyle draws the loaded file in a single colour in comparison mode,
+// keeping the selection highlight
+func (v *GCodeViewer) compareStyle(pathIndex int, path GCodePath) (color.Color, float32) {
+	if pathIndex == v.selectedPath {
+		return color.NRGBA{R: 0, G: 255, B: 200, A: 255}, 4
+	}
+	if path.PathType == PathTypeTravel {
+		return v.pathColors[PathTypeTravel], 1
+	}
+	return compareColorA, 2
+}
+
+// compareLines returns the compared file's layer at the height of the
+// current layer
+func (v *GCodeViewer) compareLines(project viewProjection) []sceneLine {
+	lines := []sceneLine{}
+	if v.comparison == nil {
+		return lines
+	}
+	model := v.comparison.B
+	layerIndex := v.comparison.MatchingLayerB(v.currentLayer)
+	if layerIndex < 0 || layerIndex >= len(model.Layers) {
+		return lines
+	}
+
+	for _, pathIndex := range model.Layers[layerIndex].Paths {
+		path := model.Paths[pathIndex]
+		if path.PathType == PathTypeTravel && !v.showTravelMoves {
+			continue
+		}
+
+		a, b, visible := v.clipToSection(
+			Point3D{X: path.StartX, Y: path.StartY, Z: path.StartZ},
+			Point3D{X: path.EndX, Y: path.EndY, Z: path.EndZ},
+		)
+		if !visible {
+			continue
+		}
+		start, end, ok := project.segment(a, b)
+		if !ok {
+			continue
+		}
+
+		c, width := color.Color(compareColorB), float32(2)
+		if path.PathType == PathTypeTravel {
+			c, width = ghostColor(compareColorB), 1
+		}
+		lines = append(lines, sceneLine{start: start, end: end, color: c, width: width})
+	}
+	return lines
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Export settings
+const (
+	exportReferenceWidth  = 400 // Viewport assumed when the viewer has never been laid out
+	exportReferenceHeight = 300
+	exportMaxGIFFrames    = 150 // Layers are merged into fewer frames above this
+	exportGIFHoldDelay    = 2 * time.Second
+	exportHeadSegments    = 16 // Polygon segments used for the head marker
+)
+
+// SnapshotOptions controls offscreen rendering
+type SnapshotOptions struct {
+	Width       int
+	Height      int
+	ShowOverlay bool // Draw layer and progress text
+}
+
+// DefaultSnapshotOptions returns options for a plain thumbnail
+func DefaultSnapshotOptions(width, height int) SnapshotOptions {
+	return SnapshotOptions{
+		Width:  width,
+		Height: height,
+	}
+}
+
+// RenderImage renders the current view offscreen at the requested resolution.
+// It uses the viewer's camera, colour modes and visible layers, and does not
+// need a running Fyne app.
+func (v *GCodeViewer) RenderImage(options SnapshotOptions) (*image.RGBA, error) {
+	if err := v.checkExport(options); err != nil {
+		return nil, err
+	}
+
+	project, scale := v.exportProjection(options.Width, options.Height)
+
+	img := v.newExportImage(options)
+	drawSceneLines(img, v.platformLines(project), scale)
+	drawSceneLines(img, v.pathLines(project, v.displayedLayers(), v.currentSourceLine), scale)
+	drawSceneLines(img, v.compareLines(project), scale)
+	drawSceneLines(img, v.objectLines(project), scale)
+
+	// Print head indicator
+	head, ok := project.point(Point3D{X: v.headState.X, Y: v.headState.Y, Z: v.headState.Z})
+	if ok && v.currentLine < len(v.model.Commands) {
+		fillCircle(img, head, 6*scale, color.NRGBA{R: 255, G: 0, B: 0, A: 255})
+		fillCircle(img, head, 3*scale, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	}
+
+	if options.ShowOverlay {
+		progress := float64(v.currentLine) / float64(len(v.model.Commands)) * 100
+		drawExportText(img, 10, 20, fmt.Sprintf("Layer: %d/%d", v.currentLayer+1, len(v.model.Layers)))
+		drawExportText(img, 10, 36, fmt.Sprintf("Progress: %.1f%%", progress))
+	}
+
+	return img, nil
+}
+
+// ExportPNG writes the current view as a PNG
+func (v *GCodeViewer) ExportPNG(w io.Writer, options SnapshotOptions) error {
+	img, err := v.RenderImage(options)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// SavePNG writes the current view to a PNG file
+func (v *GCodeViewer) SavePNG(path string, options SnapshotOptions) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if err := v.ExportPNG(file, options); err != nil {
+		return fmt.Errorf("failed to export snapshot: %v", err)
+	}
+	return file.Close()
+}
+
+// ExportLayerGIF writes a layer-by-layer build-up animation as a GIF.
+// Models with many layers are merged into at most exportMaxGIFFrames frames.
+func (v *GCodeViewer) ExportLayerGIF(w io.Writer, options SnapshotOptions, frameDelay time.Duration) error {
+	if err := v.checkExport(options); err != nil {
+		return err
+	}
+
+	step := (len(v.model.Layers) + exportMaxGIFFrames - 1) / exportMaxGIFFrames
+	delay := int(frameDelay / (10 * time.Millisecond))
+	if delay < 1 {
+		delay = 1
+	}
+
+	animation := &gif.GIF{}
+	err := v.renderLayerFrames(options, step, func(layerIndex int, frame *image.RGBA) error {
+		paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.Draw(paletted, frame.Bounds(), frame, image.Point{}, draw.Src)
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, delay)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Hold the finished model before looping
+	animation.Delay[len(animation.Delay)-1] = int(exportGIFHoldDelay / (10 * time.Millisecond))
+	return gif.EncodeAll(w, animation)
+}
+
+// SaveLayerGIF writes the layer animation to a GIF file
+func (v *GCodeViewer) SaveLayerGIF(path string, options SnapshotOptions, frameDelay time.Duration) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if err := v.ExportLayerGIF(file, options, frameDelay); err != nil {
+		return fmt.Errorf("failed to export animation: %v", err)
+	}
+	return file.Close()
+}
+
+// ExportPNGSequence writes one PNG per layer (layer_0001.png, ...) into a directory
+// and returns the number of frames written
+func (v *GCodeViewer) ExportPNGSequence(dir string, options SnapshotOptions) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	frames := 0
+	err := v.renderLayerFrames(options, 1, func(layerIndex int, frame *image.RGBA) error {
+		path := filepath.Join(dir, fmt.Sprintf("layer_%04d.png", layerIndex+1))
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", path, err)
+		}
+		if err := png.Encode(file, frame); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		frames++
+		return file.Close()
+	})
+	return frames, err
+}
+
+// RenderGCodeThumbnail parses a G-code file and writes a PNG of the whole model.
+// It runs without a UI so the CLI and backend can generate thumbnails.
+func RenderGCodeThumbnail(inputPath, outputPath string, width, height int) error {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	model, err := NewGCodeParser().ParseGCode(file)
+	if err != nil {
+		return fmt.Errorf("failed to parse G-code: %v", err)
+	}
+
+	viewer := NewGCodeViewer()
+	viewer.LoadGCode(model)
+	viewer.SetCurrentLine(len(model.Commands) - 1)
+
+	return viewer.SavePNG(outputPath, DefaultSnapshotOptions(width, height))
+}
+
+// renderLayerFrames renders the model building up layer by layer. Layers are
+// drawn incrementally onto a base image, so each frame only costs its own paths.
+func (v *GCodeViewer) renderLayerFrames(options SnapshotOptions, step int, emit func(layerIndex int, frame *image.RGBA) error) error {
+	if err := v.checkExport(options); err != nil {
+		return err
+	}
+	if step < 1 {
+		step = 1
+	}
+
+	project, scale := v.exportProjection(options.Width, options.Height)
+
+	base := v.newExportImage(options)
+	drawSceneLines(base, v.platformLines(project), scale)
+
+	layerCount := len(v.model.Layers)
+	for layerIndex := 0; layerIndex < layerCount; layerIndex++ {
+		// Frames show finished layers, so everything drawn counts as printed
+		drawSceneLines(base, v.pathLines(project, []int{layerIndex}, math.MaxInt32), scale)
+
+		if (layerIndex+1)%step != 0 && layerIndex != layerCount-1 {
+			continue
+		}
+
+		frame := base
+		if options.ShowOverlay {
+			frame = image.NewRGBA(base.Bounds())
+			copy(frame.Pix, base.Pix)
+			layer := v.model.Layers[layerIndex]
+			drawExportText(frame, 10, 20, fmt.Sprintf("Layer: %d/%d", layerIndex+1, layerCount))
+			drawExportText(frame, 10, 36, fmt.Sprintf("Z: %.2f mm", layer.Z))
+		}
+
+		if err := emit(layerIndex, frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkExport validates that there is something to render
+func (v *GCodeViewer) checkExport(options SnapshotOptions) error {
+	if v.model == nil || len(v.model.Commands) == 0 {
+		return fmt.Errorf("no G-code loaded")
+	}
+	if options.Width <= 0 || options.Height <= 0 {
+		return fmt.Errorf("invalid export size %dx%d", options.Width, options.Height)
+	}
+	return nil
+}
+
+// exportProjection maps the on-screen view onto an image of the given size.
+// The pan offset is scaled with the image so it shows what the screen shows;
+// the returned scale is applied to line widths.
+func (v *GCodeViewer) exportProjection(width, height int) (viewProjection, float32) {
+	viewWidth, viewHeight := v.width, v.height
+	if viewWidth <= 0 || viewHeight <= 0 {
+		viewWidth, viewHeight = exportReferenceWidth, exportReferenceHeight
+	}
+
+	scale := math.Min(float64(width)/float64(viewWidth), float64(height)/float64(viewHeight))
+	camera := v.cameraState()
+	camera.PanX *= scale
+	camera.PanY *= scale
+
+	return viewProjection{camera: camera, width: float32(width), height: float32(height)}, float32(scale)
+}
+
+// newExportImage creates an image filled with the viewer background
+func (v *GCodeViewer) newExportImage(options SnapshotOptions) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, options.Width, options.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(v.backgroundColor), image.Point{}, draw.Src)
+	return img
+}
+
+// drawSceneLines rasterizes lines with widths scaled to the image
+func drawSceneLines(img *image.RGBA, lines []sceneLine, scale float32) {
+	raster := vector.NewRasterizer(0, 0)
+	for _, line := range lines {
+		width := line.width * scale
+		if width < 1 {
+			width = 1
+		}
+		strokeLine(img, raster, line.start, line.end, width, line.color)
+	}
+}
+
+// strokeLine draws an anti-aliased line as a quad. The rasterizer only
+// covers the line's clipped bounding box to keep long exports fast.
+func strokeLine(img *image.RGBA, raster *vector.Rasterizer, start, end Point2D, width float32, c color.Color) {
+	dx := float64(end.X - start.X)
+	dy := float64(end.Y - start.Y)
+	length := math.Hypot(dx, dy)
+	if math.IsNaN(length) || math.IsInf(length, 0) {
+		return
+	}
+
+	// Normal offset of half the width; zero-length lines become squares
+	half := float64(width) / 2
+	nx, ny := half, 0.0
+	ux, uy := 0.0, 0.0
+	if length > 0 {
+		nx, ny = -dy/length*half, dx/length*half
+	} else {
+		ux, uy = 0, half
+	}
+
+	corners := [4][2]float64{
+		{float64(start.X) + nx - ux, float64(start.Y) + ny - uy},
+		{float64(end.X) + nx + ux, float64(end.Y) + ny + uy},
+		{float64(end.X) - nx + ux, float64(end.Y) - ny + uy},
+		{float64(start.X) - nx - ux, float64(start.Y) - ny - uy},
+	}
+	fillPolygon(img, raster, corners[:], c)
+}
+
+// fillCircle draws a filled circle as a polygon
+func fillCircle(img *image.RGBA, center Point2D, radius float32, c color.Color) {
+	points := make([][2]float64, exportHeadSegments)
+	for i := range points {
+		angle := 2 * math.Pi * float64(i) / exportHeadSegments
+		points[i] = [2]float64{
+			float64(center.X) + float64(radius)*math.Cos(angle),
+			float64(center.Y) + float64(radius)*math.Sin(angle),
+		}
+	}
+	fillPolygon(img, vector.NewRasterizer(0, 0), points, c)
+}
+
+// fillPolygon fills a polygon clipped to the image bounds
+func fillPolygon(img *image.RGBA, raster *vector.Rasterizer, points [][2]float64, c color.Color) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+
+	rect := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX))+1, int(math.Ceil(maxY))+1)
+	rect = rect.Intersect(img.Bounds())
+	if rect.Empty() {
+		return
+	}
+
+	origin := [2]float64{float64(rect.Min.X), float64(rect.Min.Y)}
+	raster.Reset(rect.Dx(), rect.Dy())
+	raster.DrawOp = draw.Over
+	raster.MoveTo(float32(points[0][0]-origin[0]), float32(points[0][1]-origin[1]))
+	for _, p := range points[1:] {
+		raster.LineTo(float32(p[0]-origin[0]), float32(p[1]-origin[1]))
+	}
+	raster.ClosePath()
+	raster.Draw(img, rect, image.NewUniform(c), image.Point{})
+}
+
+// drawExportText draws overlay text with the built-in bitmap font
+func drawExportText(img *image.RGBA, x, y int, text string) {
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.White),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(text)
+}
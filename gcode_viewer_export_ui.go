@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// exportGIFFrameDelay is the time per layer at playback speed 1.0
+const exportGIFFrameDelay = 100 * time.Millisecond
+
+// exportSizes are the resolutions offered for snapshots and animations
+var exportSizes = [][2]int{
+	{800, 600},
+	{1280, 720},
+	{1920, 1080},
+	{3840, 2160},
+}
+
+// exportSizeName formats a resolution for the size selector
+func exportSizeName(size [2]int) string {
+	return fmt.Sprintf("%d x %d", size[0], size[1])
+}
+
+// createExportControls creates the snapshot and animation export controls
+func (ui *GCodeViewerUI) createExportControls() *widget.Card {
+	names := make([]string, len(exportSizes))
+	for i, size := range exportSizes {
+		names[i] = exportSizeName(size)
+	}
+	ui.exportSizeSelect = widget.NewSelect(names, nil)
+	ui.exportSizeSelect.SetSelected(exportSizeName(exportSizes[2]))
+
+	ui.exportOverlayCheck = widget.NewCheck("Include layer info", nil)
+	ui.exportOverlayCheck.SetChecked(true)
+
+	snapshotBtn := widget.NewButton("Snapshot PNG", func() {
+		ui.exportSnapshot()
+	})
+	gifBtn := widget.NewButton("Layer GIF", func() {
+		ui.exportLayerGIF()
+	})
+	sequenceBtn := widget.NewButton("PNG Sequence", func() {
+		ui.exportPNGSequence()
+	})
+
+	return widget.NewCard("Export", "", container.NewVBox(
+		ui.exportSizeSelect,
+		ui.exportOverlayCheck,
+		snapshotBtn,
+		container.NewGridWithColumns(2, gifBtn, sequenceBtn),
+	))
+}
+
+// exportOptions returns the snapshot options selected in the UI
+func (ui *GCodeViewerUI) exportOptions() SnapshotOptions {
+	size := exportSizes[2]
+	for _, candidate := range exportSizes {
+		if exportSizeName(candidate) == ui.exportSizeSelect.Selected {
+			size = candidate
+		}
+	}
+	options := DefaultSnapshotOptions(size[0], size[1])
+	options.ShowOverlay = ui.exportOverlayCheck.Checked
+	return options
+}
+
+// exportBaseName returns the loaded file name without extension
+func (ui *GCodeViewerUI) exportBaseName() string {
+	name := PlainGCodeName(filepath.Base(ui.currentFile))
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if name == "" || name == "." {
+		name = "gcode"
+	}
+	return name
+}
+
+// exportSnapshot saves the current view as a PNG
+func (ui *GCodeViewerUI) exportSnapshot() {
+	if ui.model == nil {
+		dialog.ShowInformation("Export", "Load a G-code file first", ui.window)
+		return
+	}
+	ui.pauseAnimation()
+	options := ui.exportOptions()
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+
+		ui.runExport("Rendering snapshot...", func() error {
+			defer writer.Close()
+			return ui.viewer.ExportPNG(writer, options)
+		})
+	}, ui.window)
+	saveDialog.SetFileName(ui.exportBaseName() + ".png")
+	saveDialog.Show()
+}
+
+// exportLayerGIF saves the layer build-up animation as a GIF.
+// Frame timing follows the playback speed slider.
+func (ui *GCodeViewerUI) exportLayerGIF() {
+	if ui.model == nil {
+		dialog.ShowInformation("Export", "Load a G-code file first", ui.window)
+		return
+	}
+	ui.pauseAnimation()
+	options := ui.exportOptions()
+	frameDelay := time.Duration(float64(exportGIFFrameDelay) / ui.playbackSpeed)
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+
+		ui.runExport("Rendering layers...", func() error {
+			defer writer.Close()
+			return ui.viewer.ExportLayerGIF(writer, options, frameDelay)
+		})
+	}, ui.window)
+	saveDialog.SetFileName(ui.exportBaseName() + "_layers.gif")
+	saveDialog.Show()
+}
+
+// exportPNGSequence saves one PNG per layer into a chosen folder
+func (ui *GCodeViewerUI) exportPNGSequence() {
+	if ui.model == nil {
+		dialog.ShowInformation("Export", "Load a G-code file first", ui.window)
+		return
+	}
+	ui.pauseAnimation()
+	options := ui.exportOptions()
+
+	dialog.ShowFolderOpen(func(folder fyne.ListableURI, err error) {
+		if err != nil || folder == nil {
+			return
+		}
+
+		dir := filepath.Join(folder.Path(), ui.exportBaseName()+"_layers")
+		ui.runExport("Rendering layers...", func() error {
+			frames, err := ui.viewer.ExportPNGSequence(dir, options)
+			if err != nil {
+				return err
+			}
+			dialog.ShowInformation("Export", fmt.Sprintf("Wrote %d images to %s", frames, dir), ui.window)
+			return nil
+		})
+	}, ui.window)
+}
+
+// runExport runs an export in the background behind a progress dialog
+func (ui *GCodeViewerUI) runExport(message string, export func() error) {
+	progressDialog := dialog.NewProgressInfinite("Export", message, ui.window)
+	progressDialog.Show()
+
+	go func() {
+		err := export()
+		progressDialog.Hide()
+
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("export failed: %v", err), ui.window)
+		}
+	}()
+}
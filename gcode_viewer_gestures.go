@@ -0,0 +1,232 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+)
+
+// Gesture tuning for the 10-inch capacitive panel. Capacitive panels report
+// a few pixels of jitter on every touch, so small movements are treated as
+// taps and drag deltas are smoothed before they feed the inertia.
+const (
+	gestureTapSlop         = 6.0  // Max movement in px before a touch becomes a drag
+	gestureRotateScale     = 0.7  // Drag px to Rotate() units (Rotate halves again)
+	gestureScrollZoomScale = 0.1  // Scroll units to Zoom() delta
+	gestureVelocitySmooth  = 0.35 // Weight of the newest drag delta in the velocity estimate
+	gestureFriction        = 0.90 // Velocity kept per inertia frame
+	gestureMinVelocity     = 0.15 // Velocity in px/frame below which inertia stops
+	gestureMaxVelocity     = 40.0 // Clamp for fast flicks so the model does not spin away
+	gestureReleaseWindow   = 80   // ms without movement after which a release carries no inertia
+	gestureFrameInterval   = 16 * time.Millisecond
+)
+
+// gestureMode defines what a drag currently does
+type gestureMode int
+
+const (
+	gestureOrbit gestureMode = iota // Single finger / primary button
+	gesturePan                      // Secondary or middle button, or shift
+)
+
+// viewerInertia carries the view on after a flick until friction stops it
+type viewerInertia struct {
+	mu        sync.Mutex
+	mode      gestureMode
+	velocityX float64
+	velocityY float64
+	lastMove  time.Time
+	stop      chan struct{}
+}
+
+// Compile-time checks for the gesture interfaces
+var (
+	_ fyne.Draggable      = (*GCodeViewer)(nil)
+	_ fyne.Scrollable     = (*GCodeViewer)(nil)
+	_ fyne.Tappable       = (*GCodeViewer)(nil)
+	_ fyne.DoubleTappable = (*GCodeViewer)(nil)
+	_ desktop.Mouseable   = (*GCodeViewer)(nil)
+)
+
+// MouseDown records which button or modifier started the gesture
+func (v *GCodeViewer) MouseDown(event *desktop.MouseEvent) {
+	v.stopInertia()
+	v.touchStartPos = event.Position
+	v.touchStartTime = time.Now().UnixNano()
+
+	if event.Button == desktop.MouseButtonSecondary ||
+		event.Button == desktop.MouseButtonTertiary ||
+		event.Modifier&fyne.KeyModifierShift != 0 {
+		v.dragMode = gesturePan
+	} else {
+		v.dragMode = gestureOrbit
+	}
+}
+
+// MouseUp is part of desktop.Mouseable; drag end is handled in DragEnd
+func (v *GCodeViewer) MouseUp(event *desktop.MouseEvent) {
+}
+
+// Dragged orbits the camera with one finger and pans in pan mode
+func (v *GCodeViewer) Dragged(event *fyne.DragEvent) {
+	if !v.isDragging {
+		// Ignore panel jitter until the finger has really moved
+		dx := float64(event.Position.X - v.touchStartPos.X)
+		dy := float64(event.Position.Y - v.touchStartPos.Y)
+		if v.touchStartTime != 0 && math.Hypot(dx, dy) < gestureTapSlop {
+			return
+		}
+
+		v.stopInertia()
+		v.isDragging = true
+		v.inertia.mu.Lock()
+		v.inertia.mode = v.dragMode
+		v.inertia.velocityX = 0
+		v.inertia.velocityY = 0
+		v.inertia.mu.Unlock()
+	}
+
+	deltaX := float64(event.Dragged.DX)
+	deltaY := float64(event.Dragged.DY)
+	v.lastDragPos = event.Position
+
+	v.inertia.mu.Lock()
+	v.inertia.velocityX = gestureVelocitySmooth*deltaX + (1-gestureVelocitySmooth)*v.inertia.velocityX
+	v.inertia.velocityY = gestureVelocitySmooth*deltaY + (1-gestureVelocitySmooth)*v.inertia.velocityY
+	v.inertia.lastMove = time.Now()
+	v.inertia.mu.Unlock()
+
+	v.applyGesture(v.dragMode, deltaX, deltaY)
+}
+
+// DragEnd releases the drag and hands remaining velocity to the inertia loop
+func (v *GCodeViewer) DragEnd() {
+	wasDragging := v.isDragging
+	v.isDragging = false
+	v.touchStartTime = 0
+	v.dragMode = gestureOrbit
+
+	if !wasDragging {
+		return
+	}
+
+	v.inertia.mu.Lock()
+	held := time.Since(v.inertia.lastMove) > gestureReleaseWindow*time.Millisecond
+	if held {
+		// Finger rested before lifting - no flick
+		v.inertia.velocityX = 0
+		v.inertia.velocityY = 0
+	}
+	v.inertia.velocityX = clampVelocity(v.inertia.velocityX)
+	v.inertia.velocityY = clampVelocity(v.inertia.velocityY)
+	v.inertia.mu.Unlock()
+
+	if !held {
+		v.startInertia()
+	}
+}
+
+// Scrolled zooms with the vertical scroll component. Fyne reports mouse
+// wheels, trackpads and pinches alike as plain scroll events without saying
+// which device sent them, so a two-finger pan cannot be told from a
+// horizontal wheel and horizontal scrolling is ignored; panning is done by
+// dragging in pan mode. A pinch zooms only where the driver turns it into a
+// vertical scroll.
+func (v *GCodeViewer) Scrolled(event *fyne.ScrollEvent) {
+	if event.Scrolled.DY == 0 {
+		return
+	}
+	v.stopInertia()
+	v.Zoom(float64(event.Scrolled.DY) * gestureScrollZoomScale)
+}
+
+// Tapped stops any running inertia, then opens a tapped object label or
+// picks the path under the finger
+func (v *GCodeViewer) Tapped(event *fyne.PointEvent) {
+	v.stopInertia()
+
+	// Object labels take priority over the paths underneath them
+	if objectIndex := v.ObjectAt(event.Position); objectIndex >= 0 && v.onObjectTapped != nil {
+		v.onObjectTapped(objectIndex)
+		return
+	}
+
+	v.SelectPath(v.PickPath(event.Position))
+}
+
+// DoubleTapped animates back to the iso view and fits the model into the view
+func (v *GCodeViewer) DoubleTapped(event *fyne.PointEvent) {
+	v.AnimateToView(ViewIso)
+}
+
+// applyGesture moves the camera for one gesture step. It is called from the
+// inertia goroutine too; Rotate and Pan take the camera lock.
+func (v *GCodeViewer) applyGesture(mode gestureMode, deltaX, deltaY float64) {
+	switch mode {
+	case gesturePan:
+		v.Pan(deltaX, deltaY)
+	default:
+		v.Rotate(deltaX*gestureRotateScale, deltaY*gestureRotateScale)
+	}
+}
+
+// startInertia runs the decay loop until the velocity dies out
+func (v *GCodeViewer) startInertia() {
+	v.inertia.mu.Lock()
+	if math.Abs(v.inertia.velocityX) < gestureMinVelocity && math.Abs(v.inertia.velocityY) < gestureMinVelocity {
+		v.inertia.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	v.inertia.stop = stop
+	mode := v.inertia.mode
+	v.inertia.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(gestureFrameInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				v.inertia.mu.Lock()
+				v.inertia.velocityX *= gestureFriction
+				v.inertia.velocityY *= gestureFriction
+				vx, vy := v.inertia.velocityX, v.inertia.velocityY
+				done := math.Abs(vx) < gestureMinVelocity && math.Abs(vy) < gestureMinVelocity
+				if done && v.inertia.stop == stop {
+					v.inertia.stop = nil
+				}
+				v.inertia.mu.Unlock()
+
+				if done {
+					return
+				}
+				v.applyGesture(mode, vx, vy)
+			}
+		}
+	}()
+}
+
+// stopInertia halts a running inertia loop or camera transition
+func (v *GCodeViewer) stopInertia() {
+	v.inertia.mu.Lock()
+	defer v.inertia.mu.Unlock()
+
+	if v.inertia.stop != nil {
+		close(v.inertia.stop)
+		v.inertia.stop = nil
+	}
+	v.inertia.velocityX = 0
+	v.inertia.velocityY = 0
+}
+
+// clampVelocity limits flick speed
+func clampVelocity(velocity float64) float64 {
+	return math.Max(-gestureMaxVelocity, math.Min(gestureMaxVelocity, velocity))
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// inspectContextLines is the number of source lines shown around a picked path
+const inspectContextLines = 3
+
+// createInspectPanel creates the side panel shown when a path is picked
+func (ui *GCodeViewerUI) createInspectPanel() {
+	ui.inspectCommandLabel = widget.NewLabel("")
+	ui.inspectCommandLabel.TextStyle = fyne.TextStyle{Monospace: true, Bold: true}
+	ui.inspectCommandLabel.Wrapping = fyne.TextWrapBreak
+
+	ui.inspectContextLabel = widget.NewLabel("")
+	ui.inspectContextLabel.TextStyle = fyne.TextStyle{Monospace: true}
+
+	ui.inspectDetailsLabel = widget.NewLabel("")
+
+	ui.setCurrentLineBtn = widget.NewButton("Set as Current Line", func() {
+		ui.jumpToSelectedPath()
+	})
+	ui.setCurrentLineBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Close", func() {
+		ui.viewer.ClearSelection()
+	})
+
+	ui.inspectPanel = container.NewBorder(
+		nil,
+		container.NewGridWithColumns(2, ui.setCurrentLineBtn, closeBtn),
+		nil, nil,
+		container.NewVScroll(container.NewVBox(
+			widget.NewCard("G-code", "", ui.inspectCommandLabel),
+			widget.NewCard("Context", "", ui.inspectContextLabel),
+			widget.NewCard("Details", "", ui.inspectDetailsLabel),
+		)),
+	)
+	ui.inspectPanel.Hide()
+
+	ui.viewer.SetOnPathSelected(func(pathIndex int) {
+		ui.showPathDetails(pathIndex)
+	})
+}
+
+// showPathDetails fills the inspect panel for a picked path
+func (ui *GCodeViewerUI) showPathDetails(pathIndex int) {
+	if ui.model == nil || pathIndex < 0 || pathIndex >= len(ui.model.Paths) {
+		ui.inspectPanel.Hide()
+		return
+	}
+
+	path := ui.model.Paths[pathIndex]
+	commandIndex := ui.model.CommandIndexForLine(path.LineNumber)
+	if commandIndex < 0 {
+		ui.inspectPanel.Hide()
+		return
+	}
+	cmd := ui.model.Commands[commandIndex]
+
+	ui.inspectCommandLabel.SetText(fmt.Sprintf("%d: %s", cmd.LineNumber, cmd.RawLine))
+	ui.inspectContextLabel.SetText(ui.formatContextLines(commandIndex))
+	ui.inspectDetailsLabel.SetText(ui.formatPathDetails(path))
+
+	ui.inspectPanel.Show()
+	ui.inspectPanel.Refresh()
+}
+
+// formatContextLines renders the commands around a command index
+func (ui *GCodeViewerUI) formatContextLines(commandIndex int) string {
+	start := commandIndex - inspectContextLines
+	if start < 0 {
+		start = 0
+	}
+	end := commandIndex + inspectContextLines
+	if end >= len(ui.model.Commands) {
+		end = len(ui.model.Commands) - 1
+	}
+
+	var lines []string
+	for i := start; i <= end; i++ {
+		marker := "  "
+		if i == commandIndex {
+			marker = "> "
+		}
+		cmd := ui.model.Commands[i]
+		lines = append(lines, fmt.Sprintf("%s%d: %s", marker, cmd.LineNumber, cmd.RawLine))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatPathDetails describes feature type, speed, flow and machine state
+func (ui *GCodeViewerUI) formatPathDetails(path GCodePath) string {
+	state := ui.model.MachineStateAt(path.LineNumber)
+	length := path.Length()
+
+	// Flow: mm of filament per mm of path and volumetric rate
+	flowRatio := 0.0
+	volumetric := 0.0
+	if length > 0 && path.ExtrusionAmount > 0 {
+		flowRatio = path.ExtrusionAmount / length
+		filamentArea := math.Pi * math.Pow(defaultFilamentDiameter/2, 2)
+		volumetric = path.ExtrusionAmount * filamentArea * (path.Speed / 60.0) / length
+	}
+
+	positioning := "absolute"
+	if !state.AbsolutePositioning {
+		positioning = "relative"
+	}
+	extrusion := "absolute"
+	if !state.AbsoluteExtrusion {
+		extrusion = "relative"
+	}
+
+	layer := "-"
+	if state.LayerIndex >= 0 {
+		layer = fmt.Sprintf("%d (Z %.2f mm)", state.LayerIndex+1, ui.model.Layers[state.LayerIndex].Z)
+	}
+
+	return fmt.Sprintf(
+		"Feature: %s\n"+
+			"Layer: %s\n"+
+			"Length: %.2f mm\n"+
+			"Speed: %.1f mm/s\n"+
+			"Extrusion: %.4f mm\n"+
+			"Flow: %.4f mm/mm (%.2f mm³/s)\n"+
+			"\n"+
+			"Position: X%.2f Y%.2f Z%.2f E%.4f\n"+
+			"Positioning: %s, extrusion: %s\n"+
+			"Tool: T%d\n"+
+			"Hotend: %.0f°C | Bed: %.0f°C\n"+
+			"Fan: %.0f%%\n"+
+			"Speed/flow override: %.0f%% / %.0f%%",
+		PathTypeNames[path.PathType],
+		layer,
+		length,
+		path.Speed/60.0,
+		path.ExtrusionAmount,
+		flowRatio, volumetric,
+		state.X, state.Y, state.Z, state.E,
+		positioning, extrusion,
+		state.Tool,
+		state.HotendTemp, state.BedTemp,
+		state.FanSpeed/255*100,
+		state.SpeedFactor, state.FlowFactor,
+	)
+}
+
+// jumpToSelectedPath moves the progress slider to the picked path
+func (ui *GCodeViewerUI) jumpToSelectedPath() {
+	pathIndex := ui.viewer.SelectedPath()
+	if ui.model == nil || pathIndex < 0 || pathIndex >= len(ui.model.Paths) {
+		return
+	}
+
+	commandIndex := ui.model.CommandIndexForLine(ui.model.Paths[pathIndex].LineNumber)
+	if commandIndex < 0 {
+		return
+	}
+
+	ui.pauseAnimation()
+	ui.progressSlider.SetValue(float64(commandIndex))
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+)
+
+// Object label sizing; labels are tap targets so they stay finger sized
+const (
+	objectLabelHeight    = 28.0
+	objectLabelPadding   = 10.0
+	objectLabelCharWidth = 7.5 // Approximate advance of the 12pt label font
+	objectLabelTextSize  = 12
+)
+
+// Object outline colours
+var (
+	objectActiveColor    = color.NRGBA{R: 255, G: 160, B: 0, A: 255}
+	objectCancelledColor = color.NRGBA{R: 110, G: 110, B: 110, A: 255}
+	cancelledPathColor   = color.NRGBA{R: 70, G: 70, B: 70, A: 255}
+)
+
+// objectLabel is an object's name tag in screen space
+type objectLabel struct {
+	objectIndex int
+	text        string
+	position    fyne.Position // Top-left corner
+	size        fyne.Size
+}
+
+// contains reports whether a screen position is on the label
+func (l objectLabel) contains(pos fyne.Position) bool {
+	return pos.X >= l.position.X && pos.X <= l.position.X+l.size.Width &&
+		pos.Y >= l.position.Y && pos.Y <= l.position.Y+l.size.Height
+}
+
+// SetShowObjects toggles object outlines and labels
+func (v *GCodeViewer) SetShowObjects(show bool) {
+	v.showObjects = show
+	v.Refresh()
+}
+
+// SetOnObjectTapped sets the callback for taps on an object label
+func (v *GCodeViewer) SetOnObjectTapped(callback func(objectIndex int)) {
+	v.onObjectTapped = callback
+}
+
+// SetObjectCancelled marks an object as cancelled so it is drawn greyed out
+func (v *GCodeViewer) SetObjectCancelled(objectIndex int, cancelled bool) {
+	if cancelled {
+		v.cancelledObjects[objectIndex] = true
+	} else {
+		delete(v.cancelledObjects, objectIndex)
+	}
+	v.Refresh()
+}
+
+// MarkObjectsCancelled greys out the named objects. Cancelling cannot be
+// undone on the printer, so objects missing from names are left as they are.
+func (v *GCodeViewer) MarkObjectsCancelled(names []string) {
+	if v.model == nil {
+		return
+	}
+
+	changed := false
+	for _, name := range names {
+		if index := v.model.ObjectByName(name); index >= 0 && !v.cancelledObjects[index] {
+			v.cancelledObjects[index] = true
+			changed = true
+		}
+	}
+	if changed {
+		v.Refresh()
+	}
+}
+
+// IsObjectCancelled reports whether an object has been cancelled
+func (v *GCodeViewer) IsObjectCancelled(objectIndex int) bool {
+	return v.cancelledObjects[objectIndex]
+}
+
+// ObjectAt returns the object whose label is at a screen position, or -1
+func (v *GCodeViewer) ObjectAt(pos fyne.Position) int {
+	if !v.showObjects {
+		return -1
+	}
+
+	labels := v.objectLabels(v.screenProjection())
+	// Labels drawn last are on top
+	for i := len(labels) - 1; i >= 0; i-- {
+		if labels[i].contains(pos) {
+			return labels[i].objectIndex
+		}
+	}
+	return -1
+}
+
+// objectLines returns the footprint outline of each object at its base
+func (v *GCodeViewer) objectLines(project viewProjection) []sceneLine {
+	lines := []sceneLine{}
+	if v.model == nil || !v.showObjects {
+		return lines
+	}
+
+	for _, object := range v.model.Objects {
+		outline := object.Outline()
+		if len(outline) == 0 {
+			continue
+		}
+
+		z := 0.0
+		if object.HasExtent() {
+			z = object.Bounds.MinZ
+		}
+
+		c := color.Color(objectActiveColor)
+		if v.cancelledObjects[object.Index] {
+			c = objectCancelledColor
+		}
+
+		for i := range outline {
+			next := outline[(i+1)%len(outline)]
+			start, end, ok := project.segment(
+				Point3D{X: outline[i][0], Y: outline[i][1], Z: z},
+				Point3D{X: next[0], Y: next[1], Z: z},
+			)
+			if ok {
+				lines = append(lines, sceneLine{start: start, end: end, color: c, width: 2})
+			}
+		}
+	}
+	return lines
+}
+
+// objectLabels places a label above the centre of each object
+func (v *GCodeViewer) objectLabels(project viewProjection) []objectLabel {
+	labels := []objectLabel{}
+	if v.model == nil {
+		return labels
+	}
+
+	for _, object := range v.model.Objects {
+		z := 0.0
+		if object.HasExtent() {
+			z = object.Bounds.MaxZ
+		}
+
+		anchor, ok := project.point(Point3D{X: object.Center[0], Y: object.Center[1], Z: z})
+		if !ok {
+			continue
+		}
+
+		text := object.Name
+		if v.cancelledObjects[object.Index] {
+			text += " (cancelled)"
+		}
+
+		width := float32(len([]rune(text)))*objectLabelCharWidth + 2*objectLabelPadding
+		labels = append(labels, objectLabel{
+			objectIndex: object.Index,
+			text:        text,
+			position:    fyne.NewPos(anchor.X-width/2, anchor.Y-objectLabelHeight-4),
+			size:        fyne.NewSize(width, objectLabelHeight),
+		})
+	}
+	return labels
+}
+
+// drawObjects draws object outlines and their tappable labels
+func (r *gcodeViewerRenderer) drawObjects() []fyne.CanvasObject {
+	if r.viewer.model == nil || !r.viewer.showObjects {
+		return nil
+	}
+
+	projection := r.viewer.screenProjection()
+	objects := lineObjects(r.viewer.objectLines(projection))
+
+	for _, label := range r.viewer.objectLabels(projection) {
+		background := color.Color(objectActiveColor)
+		if r.viewer.cancelledObjects[label.objectIndex] {
+			background = objectCancelledColor
+		}
+
+		bg := canvas.NewRectangle(background)
+		bg.CornerRadius = 6
+		bg.Move(label.position)
+		bg.Resize(label.size)
+
+		text := canvas.NewText(label.text, color.Black)
+		text.TextSize = objectLabelTextSize
+		text.Alignment = fyne.TextAlignCenter
+		text.Move(label.position)
+		text.Resize(label.size)
+
+		objects = append(objects, bg, text)
+	}
+
+	return objects
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createObjectControls wires object labels to the cancel-object flow
+func (ui *GCodeViewerUI) createObjectControls() {
+	ui.objectsCheck = widget.NewCheck("Show Objects", func(checked bool) {
+		ui.viewer.SetShowObjects(checked)
+	})
+	ui.objectsCheck.SetChecked(true)
+
+	ui.viewer.SetOnObjectTapped(func(objectIndex int) {
+		ui.confirmCancelObject(objectIndex)
+	})
+}
+
+// confirmCancelObject asks before cancelling one object of the running print
+func (ui *GCodeViewerUI) confirmCancelObject(objectIndex int) {
+	if ui.model == nil || objectIndex < 0 || objectIndex >= len(ui.model.Objects) {
+		return
+	}
+	object := ui.model.Objects[objectIndex]
+
+	if ui.viewer.IsObjectCancelled(objectIndex) {
+		dialog.ShowInformation("Object Cancelled",
+			fmt.Sprintf("%s has already been cancelled", object.Name), ui.window)
+		return
+	}
+
+	if ui.backend == nil {
+		dialog.ShowError(fmt.Errorf("not connected to a printer"), ui.window)
+		return
+	}
+
+	dialog.ShowConfirm("Cancel Object",
+		fmt.Sprintf("Stop printing %s? The other objects will continue. This cannot be undone.", object.Name),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			if err := ui.backend.CancelObject(object.ID, object.Name); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to cancel %s: %v", object.Name, err), ui.window)
+				return
+			}
+			ui.viewer.SetObjectCancelled(objectIndex, true)
+		}, ui.window)
+}
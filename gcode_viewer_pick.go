@@ -0,0 +1,227 @@
+package main
+
+import (
+	"math"
+
+	"fyne.io/fyne/v2"
+)
+
+// Picking settings
+const (
+	pickCellSize  = 32.0 // Screen-space grid cell size in px
+	pickTolerance = 14.0 // Max distance in px between a tap and a segment (fingertip sized)
+)
+
+// projectedSegment is a path segment in screen space
+type projectedSegment struct {
+	pathIndex int
+	start     Point2D
+	end       Point2D
+}
+
+// layerPickIndex is a uniform grid over the projected segments of one layer
+type layerPickIndex struct {
+	segments []projectedSegment
+	cells    map[[2]int][]int // Cell -> indices into segments
+}
+
+// pickCacheKey identifies the view a pick index was built for
+type pickCacheKey struct {
+	model         *GCodeModel
+	camera        Camera3D
+	width, height float32
+	showTravel    bool
+	showSupports  bool
+	section       SectionPlane
+}
+
+// pickCache holds lazily built per-layer indices for the current view
+type pickCache struct {
+	key    pickCacheKey
+	layers map[int]*layerPickIndex
+}
+
+// PickPath returns the index of the path closest to a screen position, or -1
+func (v *GCodeViewer) PickPath(pos fyne.Position) int {
+	if v.model == nil || len(v.model.Paths) == 0 {
+		return -1
+	}
+
+	v.validatePickCache()
+
+	best := -1
+	bestDistance := pickTolerance
+
+	// Walk from the top layer down so overlapping paths resolve to the visible one
+	layers := v.displayedLayers()
+	for i := len(layers) - 1; i >= 0; i-- {
+		layerIndex := layers[i]
+		if layerIndex < 0 || layerIndex >= len(v.model.Layers) {
+			continue
+		}
+
+		index := v.layerPickIndex(layerIndex)
+		for _, segmentIndex := range index.query(pos, pickTolerance) {
+			segment := index.segments[segmentIndex]
+			distance := distanceToSegment(pos, segment.start, segment.end)
+			if distance < bestDistance {
+				bestDistance = distance
+				best = segment.pathIndex
+			}
+		}
+	}
+
+	return best
+}
+
+// SelectPath highlights a path and notifies the selection callback
+func (v *GCodeViewer) SelectPath(pathIndex int) {
+	if v.model == nil || pathIndex >= len(v.model.Paths) {
+		pathIndex = -1
+	}
+	v.selectedPath = pathIndex
+	v.Refresh()
+
+	if v.onPathSelected != nil {
+		v.onPathSelected(pathIndex)
+	}
+}
+
+// ClearSelection removes the path highlight
+func (v *GCodeViewer) ClearSelection() {
+	v.SelectPath(-1)
+}
+
+// SelectedPath returns the selected path index, or -1
+func (v *GCodeViewer) SelectedPath() int {
+	return v.selectedPath
+}
+
+// SetOnPathSelected sets the callback for path selection changes
+func (v *GCodeViewer) SetOnPathSelected(callback func(pathIndex int)) {
+	v.onPathSelected = callback
+}
+
+// validatePickCache drops the indices when the view has changed
+func (v *GCodeViewer) validatePickCache() {
+	key := pickCacheKey{
+		model:        v.model,
+		camera:       v.cameraState(),
+		width:        v.width,
+		height:       v.height,
+		showTravel:   v.showTravelMoves,
+		showSupports: v.showSupports,
+		section:      v.sectionPlane,
+	}
+
+	if v.pickIndex == nil || v.pickIndex.key != key {
+		v.pickIndex = &pickCache{
+			key:    key,
+			layers: make(map[int]*layerPickIndex),
+		}
+	}
+}
+
+// layerPickIndex returns the index for a layer, building it on first use
+func (v *GCodeViewer) layerPickIndex(layerIndex int) *layerPickIndex {
+	if index, ok := v.pickIndex.layers[layerIndex]; ok {
+		return index
+	}
+
+	index := &layerPickIndex{
+		segments: make([]projectedSegment, 0),
+		cells:    make(map[[2]int][]int),
+	}
+
+	projection := v.screenProjection()
+	for _, pathIndex := range v.model.Layers[layerIndex].Paths {
+		if pathIndex >= len(v.model.Paths) {
+			continue
+		}
+
+		path := v.model.Paths[pathIndex]
+		if !v.showTravelMoves && path.PathType == PathTypeTravel {
+			continue
+		}
+		if !v.showSupports && path.PathType == PathTypeSupport {
+			continue
+		}
+
+		a, b, visible := v.clipToSection(
+			Point3D{X: path.StartX, Y: path.StartY, Z: path.StartZ},
+			Point3D{X: path.EndX, Y: path.EndY, Z: path.EndZ},
+		)
+		if !visible {
+			continue
+		}
+
+		start, end, ok := projection.segment(a, b)
+		if !ok {
+			continue
+		}
+		index.insert(projectedSegment{pathIndex: pathIndex, start: start, end: end})
+	}
+
+	v.pickIndex.layers[layerIndex] = index
+	return index
+}
+
+// insert adds a segment to every cell its bounding box touches
+func (index *layerPickIndex) insert(segment projectedSegment) {
+	id := len(index.segments)
+	index.segments = append(index.segments, segment)
+
+	x1, x2 := float64(segment.start.X), float64(segment.end.X)
+	y1, y2 := float64(segment.start.Y), float64(segment.end.Y)
+	minX, maxX := pickCell(math.Min(x1, x2)), pickCell(math.Max(x1, x2))
+	minY, maxY := pickCell(math.Min(y1, y2)), pickCell(math.Max(y1, y2))
+
+	for cx := minX; cx <= maxX; cx++ {
+		for cy := minY; cy <= maxY; cy++ {
+			cell := [2]int{cx, cy}
+			index.cells[cell] = append(index.cells[cell], id)
+		}
+	}
+}
+
+// query returns the segments in the cells within radius of a position
+func (index *layerPickIndex) query(pos fyne.Position, radius float64) []int {
+	minX, maxX := pickCell(float64(pos.X)-radius), pickCell(float64(pos.X)+radius)
+	minY, maxY := pickCell(float64(pos.Y)-radius), pickCell(float64(pos.Y)+radius)
+
+	seen := make(map[int]bool)
+	result := make([]int, 0)
+	for cx := minX; cx <= maxX; cx++ {
+		for cy := minY; cy <= maxY; cy++ {
+			for _, id := range index.cells[[2]int{cx, cy}] {
+				if !seen[id] {
+					seen[id] = true
+					result = append(result, id)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// pickCell converts a screen coordinate to a grid cell
+func pickCell(value float64) int {
+	return int(math.Floor(value / pickCellSize))
+}
+
+// distanceToSegment returns the screen distance between a point and a segment
+func distanceToSegment(pos fyne.Position, a, b Point2D) float64 {
+	px, py := float64(pos.X), float64(pos.Y)
+	ax, ay := float64(a.X), float64(a.Y)
+	bx, by := float64(b.X), float64(b.Y)
+
+	dx, dy := bx-ax, by-ay
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+
+	t := ((px-ax)*dx + (py-ay)*dy) / lengthSquared
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
@@ -0,0 +1,170 @@
+package main
+
+import "math"
+
+// Extrusion width limits in mm used when deriving line widths from E values
+const (
+	minExtrusionWidth = 0.1
+	maxExtrusionWidth = 3.0
+)
+
+// SectionAxis is the axis a vertical clipping plane is perpendicular to
+type SectionAxis int
+
+const (
+	SectionAxisX SectionAxis = iota
+	SectionAxisY
+)
+
+// SectionPlane is a vertical clipping plane. Geometry beyond Position along
+// Axis is hidden; Flip keeps the other side instead.
+type SectionPlane struct {
+	Enabled  bool
+	Axis     SectionAxis
+	Position float64
+	Flip     bool
+}
+
+// SetLayerRange shows only layers from..to (inclusive, 0-based).
+// In 2D mode the range applies when returning to 3D.
+func (v *GCodeViewer) SetLayerRange(from, to int) {
+	if v.model == nil || len(v.model.Layers) == 0 {
+		return
+	}
+	if from > to {
+		from, to = to, from
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to >= len(v.model.Layers) {
+		to = len(v.model.Layers) - 1
+	}
+
+	layers := make([]int, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		layers = append(layers, i)
+	}
+
+	if v.layerView2D {
+		v.savedLayers = layers
+		return
+	}
+	v.visibleLayers = layers
+	v.Refresh()
+}
+
+// SetLayerView2D switches to a top-down view of the current layer only,
+// drawn at true scale with line widths derived from the extrusion amount.
+// The 3D camera is restored when the mode is turned off.
+func (v *GCodeViewer) SetLayerView2D(enabled bool) {
+	if enabled == v.layerView2D {
+		return
+	}
+	v.stopInertia()
+	v.layerView2D = enabled
+
+	if enabled {
+		v.savedCamera = v.cameraState()
+		v.savedLayers = v.visibleLayers
+
+		v.moveCamera(func(c *Camera3D) {
+			c.RotationX = viewPresetAngles[ViewTop][0]
+			c.RotationY = viewPresetAngles[ViewTop][1]
+			c.RotationZ = 0
+			c.Projection = ProjectionOrthographic
+		})
+		v.fitToView()
+		v.visibleLayers = []int{v.currentLayer}
+	} else {
+		saved := v.savedCamera
+		v.moveCamera(func(c *Camera3D) { *c = saved })
+		v.visibleLayers = v.savedLayers
+	}
+
+	v.Refresh()
+}
+
+// LayerView2D returns whether the single-layer 2D mode is active
+func (v *GCodeViewer) LayerView2D() bool {
+	return v.layerView2D
+}
+
+// SetSectionPlane sets the vertical clipping plane
+func (v *GCodeViewer) SetSectionPlane(plane SectionPlane) {
+	v.sectionPlane = plane
+	v.Refresh()
+}
+
+// SectionPlane returns the vertical clipping plane
+func (v *GCodeViewer) SectionPlane() SectionPlane {
+	return v.sectionPlane
+}
+
+// clipToSection clips a segment against the section plane; ok is false when
+// the whole segment is hidden
+func (v *GCodeViewer) clipToSection(a, b Point3D) (Point3D, Point3D, bool) {
+	plane := v.sectionPlane
+	if !plane.Enabled {
+		return a, b, true
+	}
+
+	// Signed distance past the plane; positive means hidden
+	distance := func(p Point3D) float64 {
+		d := p.X - plane.Position
+		if plane.Axis == SectionAxisY {
+			d = p.Y - plane.Position
+		}
+		if plane.Flip {
+			d = -d
+		}
+		return d
+	}
+
+	da, db := distance(a), distance(b)
+	switch {
+	case da > 0 && db > 0:
+		return a, b, false
+	case da > 0:
+		a = lerpPoint(a, b, da/(da-db))
+	case db > 0:
+		b = lerpPoint(b, a, db/(db-da))
+	}
+	return a, b, true
+}
+
+// lerpPoint interpolates between two points
+func lerpPoint(a, b Point3D, t float64) Point3D {
+	return Point3D{
+		X: a.X + (b.X-a.X)*t,
+		Y: a.Y + (b.Y-a.Y)*t,
+		Z: a.Z + (b.Z-a.Z)*t,
+	}
+}
+
+// LayerHeight returns the thickness of a layer (0-based index)
+func (m *GCodeModel) LayerHeight(layerIndex int) float64 {
+	if layerIndex < 0 || layerIndex >= len(m.Layers) {
+		return 0
+	}
+	if layerIndex == 0 {
+		return m.Layers[0].Z
+	}
+	return m.Layers[layerIndex].Z - m.Layers[layerIndex-1].Z
+}
+
+// ExtrusionWidth estimates the width in mm of an extruded line from the
+// filament used, treating the bead as a rectangle with rounded sides.
+// Returns 0 for moves that do not extrude.
+func (path GCodePath) ExtrusionWidth(layerHeight float64) float64 {
+	length := path.Length()
+	if length <= 0 || path.ExtrusionAmount <= 0 || layerHeight <= 0 {
+		return 0
+	}
+
+	filamentArea := math.Pi * math.Pow(defaultFilamentDiameter/2, 2)
+	crossSection := path.ExtrusionAmount * filamentArea / length
+
+	width := crossSection/layerHeight + layerHeight*(1-math.Pi/4)
+	return math.Max(minExtrusionWidth, math.Min(maxExtrusionWidth, width))
+}
@@ -519,7 +519,7 @@ func simulateRealTimePrint(viewerUI *GCodeViewerUI) {
 	
 	for i := 0; i < totalCommands; i++ {
 		// Update viewer progress
-		viewerUI.SyncWithPrintProgress(i)
+		viewerUI.SyncWithPrintProgress(viewerUI.model.Commands[i].LineNumber)
 		
 		// Simulate print speed (faster for demo)
 		time.Sleep(20 * time.Millisecond)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
@@ -20,6 +22,9 @@ type GCodeViewerUI struct {
 	window     fyne.Window
 	backend    *BackendClient
 	
+	// Starts derived files, or submits them for approval; see SetPrintStarter
+	printStarter func(fileName string) (bool, error)
+	
 	// Viewer
 	viewer     *GCodeViewer
 	model      *GCodeModel
@@ -33,10 +38,22 @@ type GCodeViewerUI struct {
 	layerLabel       *widget.Label
 	showAllBtn       *widget.Button
 	showCurrentBtn   *widget.Button
+	layerRangeSlider *RangeSlider
+	layerRangeLabel  *widget.Label
+	layer2DCheck     *widget.Check
+	layerStartBtn    *widget.Button
+	
+	// Section controls
+	sectionCheck      *widget.Check
+	sectionAxisSelect *widget.Select
+	sectionFlipCheck  *widget.Check
+	sectionSlider     *widget.Slider
+	sectionLabel      *widget.Label
 	
 	// Progress controls
 	progressSlider   *widget.Slider
 	progressLabel    *widget.Label
+	remainingLabel   *widget.Label
 	playBtn          *widget.Button
 	pauseBtn         *widget.Button
 	resetBtn         *widget.Button
@@ -45,8 +62,11 @@ type GCodeViewerUI struct {
 	// Display options
 	travelMovesCheck *widget.Check
 	supportsCheck    *widget.Check
+	objectsCheck     *widget.Check
 	fullscreenBtn    *widget.Button
 	resetViewBtn     *widget.Button
+	projectionSelect *widget.Select
+	viewPresetBtns   []*widget.Button
 	
 	// File controls
 	fileSelect       *widget.Select
@@ -57,6 +77,45 @@ type GCodeViewerUI struct {
 	metadataCard     *widget.Card
 	layerInfoCard    *widget.Card
 	
+	// Path inspection
+	inspectPanel        *fyne.Container
+	inspectCommandLabel *widget.Label
+	inspectContextLabel *widget.Label
+	inspectDetailsLabel *widget.Label
+	setCurrentLineBtn   *widget.Button
+	
+	// Previews of edited or transformed copies of the loaded file
+	previewBase         *GCodeModel // Model of the loaded file while a preview is shown
+	previewKind         previewKind
+	
+	// Post-processing
+	postProcessSteps    []PostProcessStep
+	postProcessList     *fyne.Container
+	postProcessStatus   *widget.Label
+	postProcessPreview  *widget.Button
+	postProcessCard     *widget.Card
+	
+	// Transform
+	transformXEntry      *widget.Entry
+	transformYEntry      *widget.Entry
+	transformMirrorCheck *widget.Check
+	transformModeSelect  *widget.Select
+	transformOffsetEntry *widget.Entry
+	transformStatus      *widget.Label
+	transformIssues      []string // Build volume problems of the applied transform
+	transformCard        *widget.Card
+	printerProfile       *PrinterProfile
+	
+	// Comparison with another file
+	comparison          *GCodeComparison
+	compareStatus       *widget.Label
+	compareCard         *widget.Card
+	
+	// Export
+	exportSizeSelect    *widget.Select
+	exportOverlayCheck  *widget.Check
+	exportCard          *widget.Card
+	
 	// Animation
 	animationTicker  *time.Ticker
 	isPlaying        bool
@@ -95,17 +154,52 @@ func (ui *GCodeViewerUI) createControls() {
 	ui.layerLabel = widget.NewLabel("Layer: 0/0")
 	
 	ui.showAllBtn = widget.NewButton("Show All", func() {
-		ui.viewer.SetVisibleLayers(ui.getAllLayerIndices())
-		ui.viewer.Refresh()
+		ui.layerRangeSlider.SetRange(ui.layerRangeSlider.Min, ui.layerRangeSlider.Max)
 	})
 	ui.showAllBtn.Resize(fyne.NewSize(100, 40))
 	
 	ui.showCurrentBtn = widget.NewButton("Show Current", func() {
 		current := ui.viewer.currentLayer
-		ui.viewer.ShowLayersUpTo(current)
+		ui.layerRangeSlider.SetRange(0, float64(current))
 	})
 	ui.showCurrentBtn.Resize(fyne.NewSize(100, 40))
 	
+	// Layer range (A-B) isolation
+	ui.layerRangeLabel = widget.NewLabel("Range: -")
+	ui.layerRangeSlider = NewRangeSlider(0, 1)
+	ui.layerRangeSlider.OnChanged = func(low, high float64) {
+		ui.setLayerRange(int(low), int(high))
+	}
+	
+	ui.layer2DCheck = widget.NewCheck("2D Layer (true scale)", func(checked bool) {
+		ui.viewer.SetLayerView2D(checked)
+	})
+	
+	ui.layerStartBtn = widget.NewButton("Start from Layer...", func() {
+		ui.showStartFromLayerDialog()
+	})
+	
+	// Vertical section plane
+	ui.sectionLabel = widget.NewLabel("Position: -")
+	ui.sectionSlider = widget.NewSlider(0, 1)
+	ui.sectionSlider.Step = 0.5
+	ui.sectionSlider.OnChanged = func(value float64) {
+		ui.applySectionPlane()
+	}
+	
+	ui.sectionAxisSelect = widget.NewSelect([]string{"X", "Y"}, func(selected string) {
+		ui.updateSectionControls()
+	})
+	ui.sectionAxisSelect.SetSelected("X")
+	
+	ui.sectionFlipCheck = widget.NewCheck("Keep far side", func(checked bool) {
+		ui.applySectionPlane()
+	})
+	
+	ui.sectionCheck = widget.NewCheck("Clip model", func(checked bool) {
+		ui.applySectionPlane()
+	})
+	
 	// Progress controls
 	ui.progressSlider = widget.NewSlider(0, 1)
 	ui.progressSlider.OnChanged = func(value float64) {
@@ -113,6 +207,7 @@ func (ui *GCodeViewerUI) createControls() {
 	}
 	
 	ui.progressLabel = widget.NewLabel("Progress: 0%")
+	ui.remainingLabel = widget.NewLabel("Remaining: --")
 	
 	ui.playBtn = widget.NewButton("▶", func() {
 		ui.startAnimation()
@@ -158,6 +253,26 @@ func (ui *GCodeViewerUI) createControls() {
 	})
 	ui.resetViewBtn.Resize(fyne.NewSize(100, 40))
 	
+	ui.projectionSelect = widget.NewSelect([]string{
+		ProjectionModeNames[ProjectionPerspective],
+		ProjectionModeNames[ProjectionOrthographic],
+	}, func(selected string) {
+		for mode, name := range ProjectionModeNames {
+			if name == selected {
+				ui.viewer.SetProjection(mode)
+			}
+		}
+	})
+	ui.projectionSelect.SetSelected(ProjectionModeNames[ui.viewer.Projection()])
+	
+	for _, preset := range []ViewPreset{ViewTop, ViewFront, ViewSide, ViewIso} {
+		preset := preset
+		btn := widget.NewButton(ViewPresetNames[preset], func() {
+			ui.viewer.AnimateToView(preset)
+		})
+		ui.viewPresetBtns = append(ui.viewPresetBtns, btn)
+	}
+	
 	// File controls
 	ui.fileSelect = widget.NewSelect([]string{}, func(selected string) {
 		ui.currentFile = selected
@@ -177,6 +292,24 @@ func (ui *GCodeViewerUI) createControls() {
 	// Information cards
 	ui.metadataCard = widget.NewCard("File Information", "", widget.NewLabel("No file loaded"))
 	ui.layerInfoCard = widget.NewCard("Layer Information", "", widget.NewLabel("No layer selected"))
+	
+	// Path inspection panel
+	ui.createInspectPanel()
+	
+	// Object labels and cancel
+	ui.createObjectControls()
+	
+	// Snapshot and animation export
+	ui.exportCard = ui.createExportControls()
+	
+	// G-code edits
+	ui.postProcessCard = ui.createPostProcessControls()
+	
+	// Translate, mirror and IDEX copies
+	ui.transformCard = ui.createTransformControls()
+	
+	// Comparison with another file
+	ui.compareCard = ui.createCompareControls()
 }
 
 // createLayout creates the UI layout
@@ -193,12 +326,25 @@ func (ui *GCodeViewerUI) createLayout() {
 		widget.NewCard("Layers", "", container.NewVBox(
 			ui.layerLabel,
 			ui.layerSlider,
+			ui.layerRangeLabel,
+			ui.layerRangeSlider,
 			container.NewGridWithColumns(2, ui.showAllBtn, ui.showCurrentBtn),
+			ui.layer2DCheck,
+			ui.layerStartBtn,
+		)),
+		
+		// Cross-section controls
+		widget.NewCard("Section", "", container.NewVBox(
+			container.NewGridWithColumns(2, ui.sectionCheck, ui.sectionAxisSelect),
+			ui.sectionLabel,
+			ui.sectionSlider,
+			ui.sectionFlipCheck,
 		)),
 		
 		// Progress controls
 		widget.NewCard("Progress", "", container.NewVBox(
 			ui.progressLabel,
+			ui.remainingLabel,
 			ui.progressSlider,
 			container.NewGridWithColumns(3, ui.playBtn, ui.pauseBtn, ui.resetBtn),
 			container.NewHBox(
@@ -211,16 +357,32 @@ func (ui *GCodeViewerUI) createLayout() {
 		widget.NewCard("Display", "", container.NewVBox(
 			ui.travelMovesCheck,
 			ui.supportsCheck,
+			ui.objectsCheck,
+			ui.projectionSelect,
+			container.NewGridWithColumns(4,
+				ui.viewPresetBtns[0], ui.viewPresetBtns[1], ui.viewPresetBtns[2], ui.viewPresetBtns[3]),
 			container.NewGridWithColumns(2, ui.fullscreenBtn, ui.resetViewBtn),
 		)),
 		
+		// Post-processing
+		ui.postProcessCard,
+		
+		// Transform
+		ui.transformCard,
+		
+		// Compare
+		ui.compareCard,
+		
+		// Export
+		ui.exportCard,
+		
 		// Information
 		ui.metadataCard,
 		ui.layerInfoCard,
 	)
 	
-	// Right panel with viewer
-	viewerContainer := container.NewMax(ui.viewer)
+	// Right panel with viewer and path inspection panel
+	viewerContainer := container.NewBorder(nil, nil, nil, ui.inspectPanel, ui.viewer)
 	
 	// Main layout
 	ui.content = container.NewHSplit(
@@ -232,8 +394,11 @@ func (ui *GCodeViewerUI) createLayout() {
 
 // setupInteractions sets up touch and mouse interactions
 func (ui *GCodeViewerUI) setupInteractions() {
-	// TODO: Add touch gesture handling when Fyne supports it better
-	// For now, we'll use keyboard shortcuts and buttons
+	// Drag, scroll and tap gestures are handled by GCodeViewer itself.
+	// Reset animates back to the iso view, interrupting any inertia.
+	ui.resetViewBtn.OnTapped = func() {
+		ui.viewer.AnimateToView(ViewIso)
+	}
 }
 
 // loadGCodeFile loads a G-code file for viewing
@@ -244,6 +409,12 @@ func (ui *GCodeViewerUI) loadGCodeFile() {
 		}
 		defer reader.Close()
 		
+		// 3MF projects open a plate picker instead
+		if strings.EqualFold(reader.URI().Extension(), ".3mf") {
+			ui.openThreeMF(reader.URI().Path())
+			return
+		}
+		
 		// Show loading dialog
 		progressDialog := dialog.NewProgressInfinite("Loading G-code", "Parsing file...", ui.window)
 		progressDialog.Show()
@@ -261,8 +432,9 @@ func (ui *GCodeViewerUI) loadGCodeFile() {
 				return
 			}
 			
-			// Update UI on main thread
-			ui.loadModel(model, reader.URI().Name())
+			// Update UI on main thread; keep the full path so the file can be
+			// reloaded and post-processed
+			ui.loadModel(model, reader.URI().Path())
 		}()
 		
 	}, ui.window)
@@ -270,16 +442,9 @@ func (ui *GCodeViewerUI) loadGCodeFile() {
 
 // loadModel loads a parsed G-code model
 func (ui *GCodeViewerUI) loadModel(model *GCodeModel, filename string) {
-	ui.model = model
 	ui.currentFile = filename
-	
-	// Update viewer
-	ui.viewer.LoadGCode(model)
-	
-	// Update controls
-	ui.updateLayerControls()
-	ui.updateProgressControls()
-	ui.updateInformation()
+	ui.clearPreview()
+	ui.showModel(model)
 	
 	// Add to loaded files list
 	baseName := filepath.Base(filename)
@@ -290,6 +455,79 @@ func (ui *GCodeViewerUI) loadModel(model *GCodeModel, filename string) {
 	ui.fileSelect.SetSelected(baseName)
 }
 
+// showModel displays a model without changing the current file
+func (ui *GCodeViewerUI) showModel(model *GCodeModel) {
+	ui.model = model
+	
+	// Update viewer, leaving the 2D layer mode of the previous file
+	ui.layer2DCheck.SetChecked(false)
+	ui.viewer.LoadGCode(model)
+	ui.inspectPanel.Hide()
+	
+	// Update controls
+	ui.updateLayerControls()
+	ui.updateProgressControls()
+	ui.refreshComparison()
+	ui.updateInformation()
+}
+
+// previewKind identifies which feature a preview belongs to
+type previewKind int
+
+const (
+	previewNone previewKind = iota
+	previewEdits
+	previewTransform
+)
+
+// sourceModel returns the model of the loaded file, ignoring any preview
+func (ui *GCodeViewerUI) sourceModel() *GCodeModel {
+	if ui.previewBase != nil {
+		return ui.previewBase
+	}
+	return ui.model
+}
+
+// showPreview shows a model derived from the loaded file in its place
+func (ui *GCodeViewerUI) showPreview(kind previewKind, model *GCodeModel) {
+	if ui.previewBase == nil {
+		ui.previewBase = ui.model
+	}
+	if kind != previewTransform {
+		ui.viewer.SetSecondCarriage(GCodeTransform{})
+	}
+	ui.previewKind = kind
+	ui.showModel(model)
+	ui.updatePreviewStatus()
+}
+
+// discardPreview shows the loaded file again
+func (ui *GCodeViewerUI) discardPreview() {
+	base := ui.previewBase
+	ui.clearPreview()
+	if base != nil {
+		ui.showModel(base)
+	}
+}
+
+// clearPreview forgets any preview without touching the viewer
+func (ui *GCodeViewerUI) clearPreview() {
+	ui.previewBase = nil
+	ui.previewKind = previewNone
+	ui.viewer.SetSecondCarriage(GCodeTransform{})
+	ui.updatePreviewStatus()
+}
+
+// updatePreviewStatus refreshes the cards that offer previews
+func (ui *GCodeViewerUI) updatePreviewStatus() {
+	if ui.postProcessStatus != nil {
+		ui.updatePostProcessStatus()
+	}
+	if ui.transformStatus != nil {
+		ui.updateTransformStatus()
+	}
+}
+
 // updateLayerControls updates layer-related controls
 func (ui *GCodeViewerUI) updateLayerControls() {
 	if ui.model == nil || len(ui.model.Layers) == 0 {
@@ -303,6 +541,59 @@ func (ui *GCodeViewerUI) updateLayerControls() {
 	ui.layerSlider.Max = float64(layerCount - 1)
 	ui.layerSlider.SetValue(0)
 	ui.layerLabel.SetText(fmt.Sprintf("Layer: 1/%d", layerCount))
+	ui.layerRangeSlider.SetLimits(0, float64(layerCount-1))
+	ui.setLayerRange(0, layerCount-1)
+	ui.updateSectionControls()
+}
+
+// setLayerRange isolates layers from..to (0-based)
+func (ui *GCodeViewerUI) setLayerRange(from, to int) {
+	if ui.model == nil || len(ui.model.Layers) == 0 {
+		return
+	}
+	
+	ui.viewer.SetLayerRange(from, to)
+	ui.layerRangeLabel.SetText(fmt.Sprintf("Range: %d - %d (Z %.2f - %.2f mm)",
+		from+1, to+1, ui.model.Layers[from].Z, ui.model.Layers[to].Z))
+}
+
+// updateSectionControls fits the section slider to the model along the chosen axis
+func (ui *GCodeViewerUI) updateSectionControls() {
+	if ui.model == nil {
+		return
+	}
+	
+	bounds := ui.model.Bounds
+	min, max := bounds.MinX, bounds.MaxX
+	if ui.sectionAxisSelect.Selected == "Y" {
+		min, max = bounds.MinY, bounds.MaxY
+	}
+	
+	ui.sectionSlider.Min = min
+	ui.sectionSlider.Max = max
+	ui.sectionSlider.SetValue((min + max) / 2)
+	ui.applySectionPlane()
+}
+
+// applySectionPlane sends the section controls to the viewer
+func (ui *GCodeViewerUI) applySectionPlane() {
+	if ui.sectionSlider == nil || ui.sectionFlipCheck == nil || ui.sectionCheck == nil {
+		// Controls are still being created
+		return
+	}
+	
+	plane := SectionPlane{
+		Enabled:  ui.sectionCheck.Checked,
+		Axis:     SectionAxisX,
+		Position: ui.sectionSlider.Value,
+		Flip:     ui.sectionFlipCheck.Checked,
+	}
+	if ui.sectionAxisSelect.Selected == "Y" {
+		plane.Axis = SectionAxisY
+	}
+	
+	ui.sectionLabel.SetText(fmt.Sprintf("Position: %s = %.1f mm", ui.sectionAxisSelect.Selected, plane.Position))
+	ui.viewer.SetSectionPlane(plane)
 }
 
 // updateProgressControls updates progress-related controls
@@ -311,6 +602,7 @@ func (ui *GCodeViewerUI) updateProgressControls() {
 		ui.progressSlider.SetValue(0)
 		ui.progressSlider.Max = 1
 		ui.progressLabel.SetText("Progress: 0%")
+		ui.remainingLabel.SetText("Remaining: --")
 		return
 	}
 	
@@ -318,6 +610,7 @@ func (ui *GCodeViewerUI) updateProgressControls() {
 	ui.progressSlider.Max = float64(commandCount - 1)
 	ui.progressSlider.SetValue(0)
 	ui.progressLabel.SetText("Progress: 0.0%")
+	ui.remainingLabel.SetText(fmt.Sprintf("Remaining: %s", formatSeconds(ui.model.Metadata.PrintTime)))
 }
 
 // updateInformation updates information display cards
@@ -331,6 +624,7 @@ func (ui *GCodeViewerUI) updateInformation() {
 	// Update metadata
 	metadata := ui.model.Metadata
 	metadataText := fmt.Sprintf(
+		"Format: %s\n"+
 		"Generated by: %s\n"+
 		"Total layers: %d\n"+
 		"Print time: %.1f hours\n"+
@@ -338,6 +632,7 @@ func (ui *GCodeViewerUI) updateInformation() {
 		"Layer height: %.2f mm\n"+
 		"Infill density: %.1f%%\n"+
 		"Bounds: X=%.1f-%.1f, Y=%.1f-%.1f, Z=%.1f-%.1f",
+		GCodeFormatNames[ui.model.Format],
 		metadata.GeneratedBy,
 		metadata.TotalLayers,
 		metadata.PrintTime/3600,
@@ -348,7 +643,17 @@ func (ui *GCodeViewerUI) updateInformation() {
 		ui.model.Bounds.MinY, ui.model.Bounds.MaxY,
 		ui.model.Bounds.MinZ, ui.model.Bounds.MaxZ,
 	)
-	ui.metadataCard.SetContent(widget.NewLabel(metadataText))
+	metadataLabel := widget.NewLabel(metadataText)
+	
+	// Show the slicer's preview image when the file carries one
+	if thumbnail := ui.model.PreviewThumbnail(); thumbnail != nil {
+		image := canvas.NewImageFromReader(bytes.NewReader(thumbnail.Data), "thumbnail."+strings.ToLower(thumbnail.Format))
+		image.FillMode = canvas.ImageFillContain
+		image.SetMinSize(fyne.NewSize(160, 120))
+		ui.metadataCard.SetContent(container.NewVBox(image, metadataLabel))
+	} else {
+		ui.metadataCard.SetContent(metadataLabel)
+	}
 	
 	// Update layer info for current layer
 	ui.updateCurrentLayerInfo()
@@ -377,6 +682,7 @@ func (ui *GCodeViewerUI) updateCurrentLayerInfo() {
 		layer.BoundingBox.MinX, layer.BoundingBox.MaxX,
 		layer.BoundingBox.MinY, layer.BoundingBox.MaxY,
 	)
+	layerText += ui.layerComparisonText()
 	ui.layerInfoCard.SetContent(widget.NewLabel(layerText))
 }
 
@@ -403,21 +709,27 @@ func (ui *GCodeViewerUI) setProgress(progress float64) {
 	progressPercent := progress / float64(len(ui.model.Commands)-1) * 100
 	ui.progressLabel.SetText(fmt.Sprintf("Progress: %.1f%%", progressPercent))
 	
-	// Update layer based on current line
+	// Update layer and remaining time based on current line
 	if line < len(ui.model.Commands) {
-		cmd := ui.model.Commands[line]
-		for i, layer := range ui.model.Layers {
-			if cmd.LineNumber >= layer.StartLine && cmd.LineNumber <= layer.EndLine {
-				if i != ui.viewer.currentLayer {
-					ui.layerSlider.SetValue(float64(i))
-					ui.setCurrentLayer(i)
-				}
-				break
-			}
+		lineNumber := ui.model.Commands[line].LineNumber
+		
+		remaining := ui.model.RemainingTimeAtLine(lineNumber)
+		ui.remainingLabel.SetText(fmt.Sprintf("Remaining: %s", formatSeconds(remaining)))
+		
+		layer := ui.model.LayerIndexForLine(lineNumber)
+		if layer >= 0 && layer != ui.viewer.currentLayer {
+			ui.layerSlider.SetValue(float64(layer))
+			ui.setCurrentLayer(layer)
 		}
 	}
 }
 
+// formatSeconds formats a duration in seconds as h:mm:ss
+func formatSeconds(seconds float64) string {
+	total := int(seconds + 0.5)
+	return fmt.Sprintf("%d:%02d:%02d", total/3600, (total%3600)/60, total%60)
+}
+
 // startAnimation starts progress animation
 func (ui *GCodeViewerUI) startAnimation() {
 	if ui.isPlaying || ui.model == nil {
@@ -511,10 +823,18 @@ func (ui *GCodeViewerUI) toggleFullscreen() {
 	
 	// Create viewer copy for fullscreen
 	fullscreenViewer := NewGCodeViewer()
+	fullscreenViewer.bed = ui.viewer.bed
+	fullscreenViewer.SetProjection(ui.viewer.Projection())
 	if ui.model != nil {
 		fullscreenViewer.LoadGCode(ui.model)
 		fullscreenViewer.SetCurrentLayer(ui.viewer.currentLayer)
 		fullscreenViewer.SetCurrentLine(ui.viewer.currentLine)
+		for objectIndex := range ui.viewer.cancelledObjects {
+			fullscreenViewer.cancelledObjects[objectIndex] = true
+		}
+		fullscreenViewer.showObjects = ui.viewer.showObjects
+		fullscreenViewer.secondCarriage = ui.viewer.secondCarriage
+		fullscreenViewer.comparison = ui.viewer.comparison
 	}
 	
 	// Simple controls overlay
@@ -560,12 +880,33 @@ func (ui *GCodeViewerUI) GetContent() *fyne.Container {
 	return ui.content
 }
 
+// SetPrinterProfile sets the printer whose bed is drawn and used for fit-to-view
+func (ui *GCodeViewerUI) SetPrinterProfile(profile *PrinterProfile) {
+	ui.printerProfile = profile
+	ui.viewer.SetPrinterProfile(profile)
+}
+
+// SetPrintStarter routes the prints the viewer starts through the app, which
+// submits them for approval for users who may not start prints
+func (ui *GCodeViewerUI) SetPrintStarter(start func(fileName string) (bool, error)) {
+	ui.printStarter = start
+}
+
+// startPrint starts an uploaded file, reporting whether it started rather
+// than being submitted for approval. A viewer without logins starts it.
+func (ui *GCodeViewerUI) startPrint(fileName string) (bool, error) {
+	if ui.printStarter != nil {
+		return ui.printStarter(fileName)
+	}
+	return StartOrSubmit(ui.backend, nil, nil, GCodeFile{Name: fileName, FileName: fileName}, 0)
+}
+
 // Stop stops any running animations
 func (ui *GCodeViewerUI) Stop() {
 	ui.pauseAnimation()
 }
 
-// LoadGCodeFromFile loads G-code from a file path
+// LoadGCodeFromFile loads text, binary or compressed G-code from a file path
 func (ui *GCodeViewerUI) LoadGCodeFromFile(filepath string) error {
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -583,9 +924,9 @@ func (ui *GCodeViewerUI) LoadGCodeFromFile(filepath string) error {
 	return nil
 }
 
-// SyncWithPrintProgress syncs viewer with actual print progress
-func (ui *GCodeViewerUI) SyncWithPrintProgress(currentLine int) {
-	if ui.model == nil {
+// SyncWithPrintProgress syncs viewer with the source line being printed
+func (ui *GCodeViewerUI) SyncWithPrintProgress(lineNumber int) {
+	if ui.model == nil || len(ui.model.Commands) == 0 {
 		return
 	}
 	
@@ -594,6 +935,23 @@ func (ui *GCodeViewerUI) SyncWithPrintProgress(currentLine int) {
 		return
 	}
 	
-	ui.progressSlider.SetValue(float64(currentLine))
-	ui.setProgress(float64(currentLine))
+	// Map the source line to the command shown by the progress slider
+	commandIndex := ui.model.CommandIndexForLine(lineNumber)
+	ui.progressSlider.SetValue(float64(commandIndex))
+}
+
+// SyncWithPrinterStatus syncs viewer with the file position reported by the printer
+func (ui *GCodeViewerUI) SyncWithPrinterStatus(status PrinterStatus) {
+	if ui.model == nil {
+		return
+	}
+	
+	// Objects may also be cancelled from another client or the printer itself
+	ui.viewer.MarkObjectsCancelled(status.ExcludedObjects)
+	
+	lineNumber := ui.model.ResolveStatusLine(status)
+	if lineNumber <= 0 {
+		return
+	}
+	ui.SyncWithPrintProgress(lineNumber)
 } 
\ No newline at end of file
@@ -1,19 +1,21 @@
 module innovate-os-frontend
 
-go 1.21
+go 1.22
 
 require (
 	fyne.io/fyne/v2 v2.4.2
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/gorilla/websocket v1.5.1
 	github.com/golang-jwt/jwt/v5 v5.2.0
+	github.com/klauspost/compress v1.18.0
 	go.bug.st/serial v1.6.1
+	golang.org/x/image v0.11.0
 )
 
 require (
     fyne.io/systray v1.10.1-0.20230602210930-b6a2d6ca2a7b // indirect
     github.com/davecgh/go-spew v1.1.1 // indirect
     github.com/fredbi/uri v1.0.0 // indirect
-    github.com/fsnotify/fsnotify v1.6.0 // indirect
     github.com/fyne-io/gl-js v0.0.0-20220119005834-d2da28d9ccfe // indirect
     github.com/fyne-io/glfw-js v0.0.0-20220120001248-ee7290d23504 // indirect
     github.com/fyne-io/image v0.0.0-20220602074514-4956b0afb3d2 // indirect
@@ -29,7 +31,6 @@ require (
     github.com/stretchr/testify v1.8.4 // indirect
     github.com/tevino/abool v1.2.0 // indirect
     github.com/yuin/goldmark v1.5.4 // indirect
-    golang.org/x/image v0.11.0 // indirect
     golang.org/x/mobile v0.0.0-20230531173138-3c911d8e3eda // indirect
     golang.org/x/net v0.13.0 // indirect
     golang.org/x/sys v0.10.0 // indirect
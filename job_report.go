@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailureCategories are offered when a job fails or is cancelled
+var FailureCategories = []string{
+	"Bed adhesion",
+	"Spaghetti",
+	"Nozzle clog",
+	"Filament runout",
+	"Layer shift",
+	"Warping",
+	"Power loss",
+	failureUserCancelled,
+	"Other",
+}
+
+const (
+	failureUserCancelled = "User cancelled" // Suggested for cancelled jobs
+	failureNotRecorded   = "Not recorded"   // Counted for jobs nobody reported on
+)
+
+// Quality grades a completed job is signed off with
+const (
+	QualityGood       = "Good"
+	QualityAcceptable = "Acceptable"
+	QualityRejected   = "Rejected"
+)
+
+// QualityGrades are the grades in the order they are offered
+var QualityGrades = []string{QualityGood, QualityAcceptable, QualityRejected}
+
+// Size of the viewer image attached to a job report
+const (
+	jobSnapshotWidth  = 640
+	jobSnapshotHeight = 480
+)
+
+// JobSignOff is an operator's quality check of a completed job
+type JobSignOff struct {
+	Operator string    `json:"operator"`
+	Quality  string    `json:"quality"` // One of QualityGrades
+	SignedAt time.Time `json:"signed_at"`
+}
+
+// FailureCause is how often a reason ended jobs
+type FailureCause struct {
+	Reason string
+	Count  int
+}
+
+// RankFailureCauses orders failure reasons by how often they occurred,
+// most frequent first
+func RankFailureCauses(counts map[string]int) []FailureCause {
+	causes := make([]FailureCause, 0, len(counts))
+	for reason, count := range counts {
+		causes = append(causes, FailureCause{Reason: reason, Count: count})
+	}
+	sort.Slice(causes, func(i, j int) bool {
+		if causes[i].Count != causes[j].Count {
+			return causes[i].Count > causes[j].Count
+		}
+		return causes[i].Reason < causes[j].Reason
+	})
+	return causes
+}
+
+// jobSnapshotFile is where the viewer image of a job is kept
+func jobSnapshotFile(jobID uint) string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "job_snapshots", fmt.Sprintf("job_%d.png", jobID))
+}
+
+// JobPosition is where in its file a print was last reported
+type JobPosition struct {
+	FileName     string
+	LineNumber   int   // 0 when only the offset is known
+	FilePosition int64 // Byte offset in the file
+}
+
+// PositionTracker keeps the last file position of the running print from
+// the status stream, as backends stop reporting it once a print ends
+type PositionTracker struct {
+	mu       sync.Mutex
+	printing bool
+	last     JobPosition
+}
+
+// Observe takes a status update. A new print forgets the last one's position.
+func (t *PositionTracker) Observe(status PrinterStatus) {
+	state := strings.ToLower(status.Status)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state != "printing" && state != "paused" {
+		t.printing = false
+		return
+	}
+	if !t.printing {
+		t.printing = true
+		t.last = JobPosition{}
+	}
+	if status.FileName != "" && (status.LineNumber > 0 || status.FilePosition > 0) {
+		t.last = JobPosition{FileName: status.FileName, LineNumber: status.LineNumber, FilePosition: status.FilePosition}
+	}
+}
+
+// Last returns the position a print of a file was last reported at
+func (t *PositionTracker) Last(fileName string) (JobPosition, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if fileName == "" || t.last.FileName != fileName {
+		return JobPosition{}, false
+	}
+	return t.last, true
+}
+
+// JobStopLine returns the source line a job stopped at: the end of the file
+// for a completed job, the position the printer reported if known, or else
+// the line of the command its progress reached. It returns 0 for an empty
+// file.
+func JobStopLine(model *GCodeModel, entry HistoryEntry) int {
+	if model == nil || len(model.Commands) == 0 {
+		return 0
+	}
+	if entry.Succeeded() {
+		return model.Commands[len(model.Commands)-1].LineNumber
+	}
+	reported := PrinterStatus{LineNumber: entry.StopLine, FilePosition: entry.StopPosition}
+	if line := model.ResolveStatusLine(reported); line > 0 {
+		return line
+	}
+
+	index := len(model.Commands) * entry.Progress / 100
+	if index >= len(model.Commands) {
+		index = len(model.Commands) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	return model.Commands[index].LineNumber
+}
+
+// SaveJobSnapshot renders a file up to a source line, with the layer and
+// progress overlaid, and writes it to path as a PNG
+func SaveJobSnapshot(model *GCodeModel, line int, path string) error {
+	if model == nil || len(model.Commands) == 0 {
+		return fmt.Errorf("no G-code to render")
+	}
+
+	viewer := NewGCodeViewer()
+	viewer.LoadGCode(model)
+	// The viewer is positioned by command, not by source line
+	viewer.SetCurrentLine(model.CommandIndexForLine(line))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return viewer.SavePNG(path, SnapshotOptions{
+		Width:       jobSnapshotWidth,
+		Height:      jobSnapshotHeight,
+		ShowOverlay: true,
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// jobReportGCode has blank lines, so source lines and command indexes
+// differ
+const jobReportGCode = `; Sliced for the report tests
+G28
+G90
+
+;LAYER:0
+G1 Z0.2 F600
+G1 X10 Y10 E1 F1800
+; perimeter
+G1 X20 Y10 E2
+G1 X20 Y20 E3
+
+;LAYER:1
+G1 Z0.4
+G1 X10 Y20 E4
+G1 X10 Y10 E5
+`
+
+func TestJobStopLine(t *testing.T) {
+	model, err := NewGCodeParser().ParseGCode(strings.NewReader(jobReportGCode))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(model.Commands) != 13 {
+		t.Fatalf("%d commands, want 13", len(model.Commands))
+	}
+	// "G1 X20 Y10 E2" is on line 9
+	offset := int64(strings.Index(jobReportGCode, "G1 X20 Y10 E2"))
+
+	tests := []struct {
+		name  string
+		entry HistoryEntry
+		want  int
+	}{
+		{"completed", HistoryEntry{Status: "completed", Progress: 40}, 15},
+		{"reported line", HistoryEntry{Status: "failed", Progress: 90, StopLine: 10}, 10},
+		{"reported offset", HistoryEntry{Status: "cancelled", Progress: 90, StopPosition: offset}, 9},
+		{"progress", HistoryEntry{Status: "failed", Progress: 70}, 12},
+		{"no progress", HistoryEntry{Status: "cancelled"}, 1},
+		{"full progress", HistoryEntry{Status: "failed", Progress: 100}, 15},
+	}
+	for _, test := range tests {
+		if got := JobStopLine(model, test.entry); got != test.want {
+			t.Errorf("%s: line %d, want %d", test.name, got, test.want)
+		}
+	}
+
+	// The viewer is positioned at the command on that line
+	if index := model.CommandIndexForLine(JobStopLine(model, tests[1].entry)); model.Commands[index].LineNumber != 10 {
+		t.Errorf("command %d is on line %d, want 10", index, model.Commands[index].LineNumber)
+	}
+	if line := JobStopLine(&GCodeModel{}, tests[1].entry); line != 0 {
+		t.Errorf("empty file: line %d, want 0", line)
+	}
+}
+
+func TestPositionTracker(t *testing.T) {
+	var tracker PositionTracker
+	if _, ok := tracker.Last("part.gcode"); ok {
+		t.Error("position known before any print")
+	}
+
+	tracker.Observe(PrinterStatus{Status: "printing", FileName: "part.gcode", LineNumber: 120, FilePosition: 4000})
+	tracker.Observe(PrinterStatus{Status: "paused", FileName: "part.gcode", FilePosition: 4100})
+	// Backends drop the position once the print ends
+	tracker.Observe(PrinterStatus{Status: "cancelled"})
+
+	position, ok := tracker.Last("part.gcode")
+	if !ok || position.LineNumber != 0 || position.FilePosition != 4100 {
+		t.Errorf("last position %+v, %v; want offset 4100 only", position, ok)
+	}
+	if _, ok := tracker.Last("other.gcode"); ok {
+		t.Error("position reported for another file")
+	}
+
+	// A new print of the same file does not report the old position
+	tracker.Observe(PrinterStatus{Status: "printing", FileName: "part.gcode"})
+	if _, ok := tracker.Last("part.gcode"); ok {
+		t.Error("position kept from the previous print")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// ObservePrinterStatus follows the running print's file position, so the
+// snapshot of a job that stops shows where it really stopped
+func (ui *PrintJobsUI) ObservePrinterStatus(status PrinterStatus) {
+	ui.positions.Observe(status)
+}
+
+// SetOperator sets the name quality sign-offs are made under by default
+func (ui *PrintJobsUI) SetOperator(name string) {
+	ui.operator = name
+}
+
+// showJobReport asks about a job that has ended: why a failed or cancelled
+// job stopped, or a quality sign-off for a completed one. Notes and a
+// viewer image of where the job stopped can be attached either way.
+func (ui *PrintJobsUI) showJobReport(entry HistoryEntry) {
+	summary := widget.NewLabel(fmt.Sprintf("%s %s after %s at %d%%.",
+		entry.Name, entry.Status, ui.formatDuration(entry.Duration), entry.Progress))
+	summary.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(summary)
+
+	reasonSelect := widget.NewSelect(FailureCategories, nil)
+	qualityRadio := widget.NewRadioGroup(QualityGrades, nil)
+	qualityRadio.Horizontal = true
+	operatorEntry := widget.NewEntry()
+	operatorEntry.SetPlaceHolder("Operator name")
+
+	form := widget.NewForm()
+	if entry.Succeeded() {
+		operatorEntry.SetText(ui.operator)
+		if entry.SignOff != nil {
+			qualityRadio.SetSelected(entry.SignOff.Quality)
+			operatorEntry.SetText(entry.SignOff.Operator)
+		}
+		form.Append("Quality", qualityRadio)
+		form.Append("Signed off by", operatorEntry)
+	} else {
+		switch {
+		case entry.FailureReason != "":
+			reasonSelect.SetSelected(entry.FailureReason)
+		case entry.Status == "cancelled":
+			reasonSelect.SetSelected(failureUserCancelled)
+		}
+		form.Append("Reason", reasonSelect)
+	}
+
+	notesEntry := widget.NewMultiLineEntry()
+	notesEntry.SetText(entry.Notes)
+	notesEntry.SetPlaceHolder("What happened, what was changed...")
+	notesEntry.SetMinRowsVisible(3)
+	form.Append("Notes", notesEntry)
+	content.Add(form)
+
+	snapshotCheck := widget.NewCheck("Attach viewer snapshot of where the job stopped", nil)
+	switch {
+	case entry.Snapshot != "":
+		image := canvas.NewImageFromFile(entry.Snapshot)
+		image.FillMode = canvas.ImageFillContain
+		image.SetMinSize(fyne.NewSize(320, 240))
+		content.Add(image)
+		snapshotCheck.SetText("Replace the viewer snapshot")
+	case ui.backend == nil || entry.FileName == "":
+		snapshotCheck.Disable()
+	default:
+		snapshotCheck.SetChecked(!entry.Succeeded())
+	}
+	content.Add(snapshotCheck)
+
+	title := "Why Did the Job Stop?"
+	if entry.Succeeded() {
+		title = "Quality Sign-Off"
+	}
+	report := dialog.NewCustomConfirm(title, "Save", "Later", content, func(save bool) {
+		if !save {
+			return
+		}
+		var signOff *JobSignOff
+		if entry.Succeeded() && qualityRadio.Selected != "" {
+			operator := strings.TrimSpace(operatorEntry.Text)
+			if operator == "" {
+				dialog.ShowError(fmt.Errorf("enter who signs the job off"), ui.window)
+				return
+			}
+			signOff = &JobSignOff{Operator: operator, Quality: qualityRadio.Selected, SignedAt: time.Now()}
+		}
+
+		err := ui.history.Update(entry.JobID, func(e *HistoryEntry) {
+			if !e.Succeeded() {
+				e.FailureReason = reasonSelect.Selected
+			}
+			if signOff != nil {
+				e.SignOff = signOff
+			}
+			e.Notes = strings.TrimSpace(notesEntry.Text)
+		})
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to save job report: %v", err), ui.window)
+			return
+		}
+		ui.updateStatistics()
+		if snapshotCheck.Checked {
+			go ui.attachJobSnapshot(entry)
+		}
+	}, ui.window)
+	report.Resize(fyne.NewSize(520, 480))
+	report.Show()
+}
+
+// attachJobSnapshot renders the job's file as far as it got and stores the
+// image with its history entry
+func (ui *PrintJobsUI) attachJobSnapshot(entry HistoryEntry) {
+	ui.statusLabel.SetText("Rendering snapshot of " + entry.Name + "...")
+	reader, err := ui.backend.DownloadGCode(entry.FileName)
+	if err != nil {
+		ui.snapshotFailed(entry, err)
+		return
+	}
+	model, err := NewGCodeParser().ParseGCode(reader)
+	reader.Close()
+	if err != nil {
+		ui.snapshotFailed(entry, err)
+		return
+	}
+
+	path := jobSnapshotFile(entry.JobID)
+	if err := SaveJobSnapshot(model, JobStopLine(model, entry), path); err != nil {
+		ui.snapshotFailed(entry, err)
+		return
+	}
+	if err := ui.history.Update(entry.JobID, func(e *HistoryEntry) { e.Snapshot = path }); err != nil {
+		ui.snapshotFailed(entry, err)
+		return
+	}
+	ui.statusLabel.SetText("Snapshot attached to " + entry.Name)
+}
+
+// snapshotFailed reports a snapshot that could not be attached
+func (ui *PrintJobsUI) snapshotFailed(entry HistoryEntry, err error) {
+	log.Printf("Failed to attach snapshot to job %d: %v", entry.JobID, err)
+	ui.statusLabel.SetText("Failed to attach snapshot to " + entry.Name)
+}
+
+// failureCausesText ranks the failure causes of each printer, printers with
+// the most failures first
+func failureCausesText(printerFailures map[string]map[string]int) string {
+	type printerCauses struct {
+		printer string
+		total   int
+		causes  []FailureCause
+	}
+	printers := []printerCauses{}
+	for printer, counts := range printerFailures {
+		entry := printerCauses{printer: printer, causes: RankFailureCauses(counts)}
+		for _, count := range counts {
+			entry.total += count
+		}
+		printers = append(printers, entry)
+	}
+	if len(printers) == 0 {
+		return "No failed or cancelled jobs"
+	}
+	sort.Slice(printers, func(i, j int) bool {
+		if printers[i].total != printers[j].total {
+			return printers[i].total > printers[j].total
+		}
+		return printers[i].printer < printers[j].printer
+	})
+
+	lines := make([]string, len(printers))
+	for i, p := range printers {
+		causes := make([]string, len(p.causes))
+		for j, cause := range p.causes {
+			causes[j] = fmt.Sprintf("%d. %s (%d)", j+1, cause.Reason, cause.Count)
+		}
+		lines[i] = fmt.Sprintf("%s, %d stopped: %s", p.printer, p.total, strings.Join(causes, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -9,6 +9,7 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"image/color"
 	"fyne.io/fyne/v2/canvas"
+	"flag"
 	"log"
 )
 
@@ -338,6 +339,25 @@ func (m *MainApp) run() {
 }
 
 func main() {
+	// Headless thumbnail rendering for the CLI and backend
+	thumbnail := flag.String("thumbnail", "", "render a G-code file to PNG and exit")
+	output := flag.String("o", "thumbnail.png", "output file for -thumbnail")
+	width := flag.Int("width", 800, "thumbnail width in pixels")
+	height := flag.Int("height", 600, "thumbnail height in pixels")
+	debug := flag.Bool("debug", false, "log with timestamps to the microsecond and the source file and line")
+	flag.Parse()
+	
+	if *debug {
+		log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+	}
+	
+	if *thumbnail != "" {
+		if err := RenderGCodeThumbnail(*thumbnail, *output, *width, *height); err != nil {
+			log.Fatalf("Thumbnail failed: %v", err)
+		}
+		return
+	}
+	
 	// Use integrated version with backend connection
 	app := NewIntegratedApp()
 	app.run()
@@ -14,6 +14,7 @@ import (
 	"log"
 	"fmt"
 	"time"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -45,6 +46,34 @@ type IntegratedApp struct {
 	// G-code viewer
 	gcodeViewerUI *GCodeViewerUI
 	
+	// Scheduled prints
+	scheduler     *PrintScheduler
+	scheduleTimeline *ScheduleTimeline
+	
+	// Filament spools
+	spools        *SpoolInventory
+	extrusion     ExtrusionTracker
+	toolCount     int
+	
+	// Power-loss recovery
+	checkpoints   *CheckpointStore
+	
+	// File library (folders, tags, indexed metadata)
+	library       *FileLibrary
+	
+	// USB sticks plugged into the printer
+	usbWatcher    *USBWatcher
+	
+	// Files, queue, approvals and history of the job API
+	printJobsUI   *PrintJobsUI
+	printJobsView fyne.CanvasObject
+	
+	// Thermal anomaly alarms
+	thermal       *ThermalMonitor
+	thermalEvents *AuditLog
+	thermalAlarms []ThermalAlarm // Raised and not yet acknowledged
+	thermalAlert  *widget.PopUp
+	
 	// UI Components for real-time updates
 	tempLabel     *widget.Label
 	progressBar   *widget.ProgressBar
@@ -55,11 +84,17 @@ type IntegratedApp struct {
 	
 	// Current state
 	currentStatus PrinterStatus
-	printJobs     []PrintJob
+	printJobs     []BackendPrintJob
 	selectedFile  string
 	isAuthenticated bool
 }
 
+// backendHost is where the printer backend listens
+const backendHost = "localhost:8080"
+
+// localPrinter is the printer attached to the touchscreen's backend
+var localPrinter = Printer{ID: 1, Name: "Innovate 3D Printer"}
+
 func NewIntegratedApp() *IntegratedApp {
 	a := app.New()
 	a.Settings().SetTheme(&InnovateTheme{})
@@ -69,10 +104,10 @@ func NewIntegratedApp() *IntegratedApp {
 	w.SetFullScreen(true)
 	
 	// Initialize authentication
-	authManager := NewAuthManager("localhost:8080")
+	authManager := NewAuthManager(backendHost)
 	
 	// Initialize backend client
-	backend := NewBackendClient("localhost:8080")
+	backend := NewBackendClient(backendHost)
 	
 	app := &IntegratedApp{
 		app:        a,
@@ -80,9 +115,43 @@ func NewIntegratedApp() *IntegratedApp {
 		authManager: authManager,
 		backend:    backend,
 		statusChan: make(chan PrinterStatus, 100),
+		scheduler:  NewPrintScheduler(printScheduleFile(), SystemClock{}),
+		spools:     NewSpoolInventory(spoolInventoryFile()),
+		toolCount:  1,
+		checkpoints: NewCheckpointStore(printCheckpointFile()),
+		library:    NewFileLibrary(fileLibraryFile()),
 		isAuthenticated: authManager.IsAuthenticated(),
 	}
 	
+	// Restore scheduled prints; due prints start through the backend
+	if err := app.scheduler.Load(); err != nil {
+		log.Printf("Failed to load scheduled prints: %v", err)
+	}
+	app.scheduler.SetStartHandler(func(schedule ScheduledPrint) error {
+		return startScheduledFile(app.backend, schedule)
+	})
+	app.scheduler.SetPreheatHandler(func(schedule ScheduledPrint) error {
+		return preheatScheduled(app.backend, schedule)
+	})
+	app.scheduler.SetCoolDownHandler(func(schedule ScheduledPrint) {
+		coolDownScheduled(app.backend, schedule)
+	})
+	app.scheduler.SetOnChange(func() {
+		if app.scheduleTimeline != nil {
+			app.scheduleTimeline.Refresh()
+		}
+	})
+	
+	// Restore the spool inventory
+	if err := app.spools.Load(); err != nil {
+		log.Printf("Failed to load spools: %v", err)
+	}
+	
+	// Restore file folders and tags
+	if err := app.library.Load(); err != nil {
+		log.Printf("Failed to load file library: %v", err)
+	}
+	
 	// Create auth UI components
 	app.loginUI = NewLoginUI(w, authManager)
 	app.loginUI.SetLoginSuccessCallback(func() {
@@ -112,6 +181,9 @@ func NewIntegratedApp() *IntegratedApp {
 		}
 	})
 	
+	// Watch the temperatures for runaway, slow heating and sensor faults
+	app.startThermalMonitor()
+	
 	return app
 }
 
@@ -119,6 +191,9 @@ func (app *IntegratedApp) updateAuthToken() {
 	// Update backend client with new token
 	token := app.authManager.GetToken()
 	app.backend.SetAuthToken(token)
+	if app.printJobsUI != nil {
+		app.printJobsUI.SetAuthToken(token)
+	}
 }
 
 func (app *IntegratedApp) showLoginScreen() {
@@ -162,8 +237,30 @@ func (app *IntegratedApp) initializeBackend() {
 	// Start update handler
 	go app.handleStatusUpdates()
 	
+	// Start checking scheduled prints
+	app.scheduler.Start(30 * time.Second)
+	
+	// Offer imports from USB sticks as they are plugged in
+	app.startUSBWatcher()
+	
+	// Offer a spool slot per nozzle
+	go func() {
+		profile, err := app.backend.GetPrinterProfile()
+		if err != nil {
+			log.Printf("Failed to get printer profile: %v", err)
+			return
+		}
+		if profile.NozzleCount > 0 {
+			app.toolCount = profile.NozzleCount
+		}
+		app.printJobsUI.SetPrinterProfile(profile)
+	}()
+	
 	// Initial status fetch
 	app.refreshStatus()
+	
+	// Offer to resume a print interrupted by a power cut
+	app.offerRecovery()
 }
 
 func (app *IntegratedApp) handleStatusUpdates() {
@@ -171,26 +268,86 @@ func (app *IntegratedApp) handleStatusUpdates() {
 		app.currentStatus = status
 		app.updateUI()
 		
+		// Raise alarms on heater runaway, sensor faults and the like
+		app.checkThermals(status)
+		
 		// Update temperature chart if available
 		if app.temperatureUI != nil {
 			// Temperature data is automatically updated via the TemperatureUI's own ticker
 			// But we can also manually sync here if needed
 		}
 		
-		// Sync G-code viewer with print progress if available
-		if app.gcodeViewerUI != nil && status.CurrentLayer > 0 {
-			// Estimate current line based on layer progress
-			// This is a simplified approach - real implementation would need actual line tracking
-			app.gcodeViewerUI.SyncWithPrintProgress(int(status.Progress * 1000))
+		// Checkpoint the running print for power-loss recovery
+		if err := app.checkpoints.Observe(status); err != nil {
+			log.Printf("Failed to save print checkpoint: %v", err)
 		}
+		
+		// Remember where the print is, for the snapshot of a job that stops
+		if app.printJobsUI != nil {
+			app.printJobsUI.ObservePrinterStatus(status)
+		}
+		
+		// Deduct the filament of a finished print from the spool it used
+		if used, ended := app.extrusion.Observe(status); ended {
+			go app.consumeFilament(used, app.extrusion.Progress(), app.extrusion.FileName())
+		}
+		
+		// Sync G-code viewer with the real file position of the print
+		if app.gcodeViewerUI != nil && (status.LineNumber > 0 || status.FilePosition > 0) {
+			app.gcodeViewerUI.SyncWithPrinterStatus(status)
+		}
+	}
+}
+
+// consumeFilament deducts filament extruded by a print from the spools on
+// the tools the file printed with and reports it to Spoolman. Backends that
+// do not report the length extruded are charged the file's filament scaled
+// by progress. This is the only place filament is deducted.
+func (app *IntegratedApp) consumeFilament(lengthMM, progress float64, fileName string) {
+	var file map[int]float64
+	if lengthMM <= 0 || app.toolCount > 1 {
+		file = fileExtrusion(app.backend, fileName)
+	}
+	used := toolExtrusion(lengthMM, progress, file)
+	
+	tools := make([]int, 0, len(used))
+	for tool := range used {
+		tools = append(tools, tool)
+	}
+	sort.Ints(tools)
+	
+	for _, tool := range tools {
+		usage, err := app.spools.Consume(tool, used[tool])
+		if err != nil {
+			log.Printf("Failed to update spool: %v", err)
+			continue
+		}
+		if usage.SpoolID == 0 {
+			continue
+		}
+		
+		if app.logEntry != nil {
+			message := fmt.Sprintf("\n[%s] Print used %.1f g of filament", time.Now().Format("15:04:05"), usage.Grams)
+			if len(tools) > 1 {
+				message += fmt.Sprintf(" on tool %d", tool)
+			}
+			if spool, ok := app.spools.Get(usage.SpoolID); ok && spool.IsLow() {
+				message += fmt.Sprintf(" - %s is low (%.0f g left)", spool.Label(), spool.RemainingWeight)
+			}
+			app.logEntry.SetText(app.logEntry.Text + message)
+		}
+		
+		go func(usage SpoolUsage) {
+			if err := ReportSpoolUsage(app.spools, usage); err != nil {
+				log.Printf("Failed to report filament use to Spoolman: %v", err)
+			}
+		}(usage)
 	}
 }
 
 func (app *IntegratedApp) updateUI() {
 	if app.tempLabel != nil {
-		tempData := fmt.Sprintf("Hotend: %.1f°C | Bed: %.1f°C", 
-			app.currentStatus.Temperature, app.currentStatus.BedTemp)
-		app.tempLabel.SetText(tempData)
+		app.tempLabel.SetText(heaterSummary(app.currentStatus.Readings()))
 	}
 	
 	if app.progressBar != nil {
@@ -274,6 +431,13 @@ func (app *IntegratedApp) showInfo(title, message string) {
 	dialog.ShowInformation(title, message, app.window)
 }
 
+// appendLog adds a line to the dashboard log
+func (app *IntegratedApp) appendLog(line string) {
+	if app.logEntry != nil {
+		app.logEntry.SetText(app.logEntry.Text + "\n" + line)
+	}
+}
+
 func (app *IntegratedApp) createSidebar() *container.VBox {
 	// Add profile button at the top
 	profileButton := widget.NewButton("Profile", func() {
@@ -321,6 +485,11 @@ func (app *IntegratedApp) createSidebar() *container.VBox {
 	})
 	btnFiles.Resize(fyne.NewSize(200, 60))
 	
+	btnFilament := widget.NewButton("Filament", func() {
+		app.showSpools()
+	})
+	btnFilament.Resize(fyne.NewSize(200, 60))
+	
 	btnSettings := widget.NewButton("Settings", func() {
 		app.showSettings()
 	})
@@ -360,6 +529,7 @@ func (app *IntegratedApp) createSidebar() *container.VBox {
 		btnTemperature,
 		btnGCodeViewer,
 		btnFiles,
+		btnFilament,
 		btnPrintJobs,
 		btnPrinterDiscovery,
 		btnSettings,
@@ -387,13 +557,11 @@ func (app *IntegratedApp) showDashboard() {
 	if app.temperatureUI != nil && app.temperatureUI.GetChart() != nil {
 		current := app.temperatureUI.GetChart().GetCurrentTemperatures()
 		if current != nil {
-			tempData = fmt.Sprintf("Hotend: %.1f°C | Bed: %.1f°C", 
-				current.HotendActual, current.BedActual)
+			tempData = heaterSummary(current.Readings())
 		}
 	}
 	if tempData == "" {
-		tempData = fmt.Sprintf("Hotend: %.1f°C | Bed: %.1f°C", 
-			app.currentStatus.Temperature, app.currentStatus.BedTemp)
+		tempData = heaterSummary(app.currentStatus.Readings())
 	}
 	
 	app.tempLabel = widget.NewLabel(tempData)
@@ -434,9 +602,20 @@ func (app *IntegratedApp) showDashboard() {
 	logCard := widget.NewCard("System Log", "", 
 		container.NewMax(app.logEntry))
 	
+	// Scheduled prints timeline
+	app.scheduleTimeline = NewScheduleTimeline(app.scheduler)
+	scheduleCard := widget.NewCard("Scheduled Prints", "", 
+		container.NewBorder(nil, nil, nil,
+			widget.NewButton("Manage", func() {
+				showSchedulesDialog(app.window, app.scheduler)
+			}),
+			app.scheduleTimeline,
+		))
+	
 	app.mainView = container.NewVBox(
 		connectionCard.GetCard(),
 		topRow,
+		scheduleCard,
 		logCard,
 	)
 	
@@ -464,10 +643,15 @@ func (app *IntegratedApp) showPrintControl() {
 			return
 		}
 		
-		err := app.backend.StartPrint(app.selectedFile)
+		started, err := app.startPrint(app.selectedFile)
 		if err != nil {
 			app.showError("Print Start Error", fmt.Sprintf("Failed to start print: %v", err))
+		} else if !started {
+			app.showInfo("Submitted for Approval", fmt.Sprintf("%s is waiting for staff to approve it", app.selectedFile))
 		} else {
+			if err := app.library.RecordPrinted(app.selectedFile, time.Now()); err != nil {
+				log.Printf("Failed to update file library: %v", err)
+			}
 			app.showInfo("Print Started", fmt.Sprintf("Started printing %s", app.selectedFile))
 		}
 	})
@@ -548,38 +732,20 @@ func (app *IntegratedApp) showPrintControl() {
 }
 
 func (app *IntegratedApp) showFiles() {
-	// Refresh print jobs from backend
-	app.refreshPrintJobs()
-	
-	// File list with real data
-	fileList := widget.NewList(
-		func() int { return len(app.printJobs) },
-		func() fyne.CanvasObject {
-			return container.NewHBox(
-				widget.NewLabel("Template"),
-				layout.NewSpacer(),
-				widget.NewLabel("Status"),
-			)
-		},
-		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id < len(app.printJobs) {
-				job := app.printJobs[id]
-				container := obj.(*container.Container)
-				nameLabel := container.Objects[0].(*widget.Label)
-				statusLabel := container.Objects[2].(*widget.Label)
-				
-				nameLabel.SetText(job.Filename)
-				statusLabel.SetText(job.Status)
-			}
-		},
-	)
-	
-	// File selection
-	fileList.OnSelected = func(id widget.ListItemID) {
-		if id < len(app.printJobs) {
-			app.selectedFile = app.printJobs[id].Filename
-		}
+	// Thumbnail grid of the printer's files
+	library := NewFileLibraryUI(app.window, app.library, app.backend)
+	library.OnSelected = func(fileName string) {
+		app.selectedFile = fileName
+	}
+	libraryView := library.CreateUI()
+	refreshFiles := func() {
+		go func() {
+			app.refreshPrintJobs()
+			library.SyncFolders()
+			library.SetFiles(libraryFilesFromJobs(app.printJobs))
+		}()
 	}
+	refreshFiles()
 	
 	// File management buttons
 	btnUpload := widget.NewButton("Upload File", func() {
@@ -589,29 +755,21 @@ func (app *IntegratedApp) showFiles() {
 			}
 			defer reader.Close()
 			
-			// Read file content
-			content := make([]byte, 1024*1024) // Max 1MB
-			n, err := reader.Read(content)
-			if err != nil && err.Error() != "EOF" {
-				app.showError("File Read Error", fmt.Sprintf("Failed to read file: %v", err))
-				return
-			}
-			
-			// Upload to backend
-			filename := reader.URI().Name()
-			err = app.backend.UploadFile(filename, content[:n])
+			// Upload to backend; binary and compressed G-code are decoded
+			// while streaming
+			filename, err := app.backend.UploadGCode(reader.URI().Name(), reader)
 			if err != nil {
 				app.showError("Upload Error", fmt.Sprintf("Failed to upload file: %v", err))
 			} else {
 				app.showInfo("Upload Success", fmt.Sprintf("File %s uploaded successfully", filename))
-				app.refreshPrintJobs()
+				refreshFiles()
 			}
 		}, app.window)
 	})
 	btnUpload.Resize(fyne.NewSize(150, 50))
 	
 	btnRefresh := widget.NewButton("Refresh", func() {
-		app.refreshPrintJobs()
+		refreshFiles()
 	})
 	btnRefresh.Resize(fyne.NewSize(150, 50))
 	
@@ -631,7 +789,7 @@ func (app *IntegratedApp) showFiles() {
 					} else {
 						app.showInfo("Delete Success", fmt.Sprintf("File %s deleted successfully", app.selectedFile))
 						app.selectedFile = ""
-						app.refreshPrintJobs()
+						refreshFiles()
 					}
 				}
 			}, app.window)
@@ -639,12 +797,29 @@ func (app *IntegratedApp) showFiles() {
 	btnDelete.Resize(fyne.NewSize(150, 50))
 	btnDelete.Importance = widget.DangerImportance
 	
-	buttonRow := container.NewHBox(btnUpload, btnRefresh, btnDelete)
+	btnSchedule := widget.NewButton("Schedule", func() {
+		if app.selectedFile == "" {
+			app.showError("No File Selected", "Please select a file to schedule")
+			return
+		}
+		showScheduleDialog(app.window, app.scheduler, app.authManager, ScheduledPrint{
+			Name:     app.selectedFile,
+			FileName: app.selectedFile,
+		})
+	})
+	btnSchedule.Resize(fyne.NewSize(150, 50))
+	
+	btnUSB := widget.NewButton("USB Stick", func() {
+		app.openUSBMedia()
+	})
+	btnUSB.Resize(fyne.NewSize(150, 50))
+	
+	buttonRow := container.NewHBox(btnUpload, btnUSB, btnRefresh, btnSchedule, btnDelete)
 	
 	app.mainView = container.NewVBox(
-		widget.NewCard("File Manager", "", container.NewVBox(
-			buttonRow,
-			fileList,
+		widget.NewCard("File Manager", "", container.NewBorder(
+			buttonRow, nil, nil, nil,
+			libraryView,
 		)),
 	)
 	
@@ -664,6 +839,8 @@ func (app *IntegratedApp) showSettings() {
 			if printer.Name != "" {
 				printerName.SetText(printer.Name)
 			}
+			// Another printer has other heaters
+			app.thermal.Reset()
 			// Refresh status after connection
 			app.refreshStatus()
 		})
@@ -721,6 +898,18 @@ func (app *IntegratedApp) showSettings() {
 	app.mainView = container.NewVBox(
 		connectionInfo,
 		temperatureSettings,
+		app.createUSBSettings(),
+		app.createThermalAlarmSettings(),
+	)
+	
+	app.updateMainContent()
+}
+
+func (app *IntegratedApp) showSpools() {
+	spoolUI := NewSpoolInventoryUI(app.window, app.spools, app.toolCount)
+	
+	app.mainView = container.NewVBox(
+		widget.NewCard("Filament Spools", "", spoolUI.CreateUI()),
 	)
 	
 	app.updateMainContent()
@@ -729,108 +918,47 @@ func (app *IntegratedApp) showSettings() {
 func (app *IntegratedApp) showPrinterDiscovery() {
 	discoveryUI := NewPrinterDiscoveryUI(app.app, app.backend)
 	discoveryUI.SetOnConnect(func(printer DiscoveredPrinter) {
+		// Another printer has other heaters
+		app.thermal.Reset()
 		// Refresh status after connection
 		app.refreshStatus()
 	})
 	discoveryUI.Show()
 }
 
-func (app *IntegratedApp) showPrintJobs() {
-	// Refresh print jobs from backend
-	app.refreshPrintJobs()
-	
-	// Print jobs list with real data
-	jobList := widget.NewList(
-		func() int { return len(app.printJobs) },
-		func() fyne.CanvasObject {
-			return container.NewHBox(
-				widget.NewLabel("Filename"),
-				layout.NewSpacer(),
-				widget.NewLabel("Status"),
-			)
-		},
-		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id < len(app.printJobs) {
-				job := app.printJobs[id]
-				container := obj.(*container.Container)
-				nameLabel := container.Objects[0].(*widget.Label)
-				statusLabel := container.Objects[2].(*widget.Label)
-				
-				nameLabel.SetText(job.Filename)
-				statusLabel.SetText(job.Status)
-			}
-		},
-	)
-	
-	// Job selection
-	jobList.OnSelected = func(id widget.ListItemID) {
-		if id < len(app.printJobs) {
-			job := app.printJobs[id]
-			dialog.ShowInformation("Job Details", 
-				fmt.Sprintf("Filename: %s\nStatus: %s\nProgress: %.1f%%\nLayer: %d/%d\nPosition: X=%.1f, Y=%.1f, Z=%.1f",
-					job.Filename, job.Status, job.Progress*100, job.CurrentLayer, job.TotalLayers, job.PositionX, job.PositionY, job.PositionZ),
-				app.window)
-		}
+// setupPrintJobs creates the print jobs screen once the user has logged in.
+// It shares the app's file library and scheduler, and from then on
+// scheduled prints start through the job API.
+func (app *IntegratedApp) setupPrintJobs() {
+	if app.printJobsUI != nil {
+		return
 	}
 	
-	// Job management buttons
-	btnCancel := widget.NewButton("Cancel Job", func() {
-		if app.selectedFile == "" {
-			app.showError("No File Selected", "Please select a file to cancel")
-			return
-		}
-		
-		dialog.ShowConfirm("Cancel Job", 
-			fmt.Sprintf("Are you sure you want to cancel the print job for %s?", app.selectedFile),
-			func(confirmed bool) {
-				if confirmed {
-					err := app.backend.CancelPrintJob(app.selectedFile)
-					if err != nil {
-						app.showError("Cancel Error", fmt.Sprintf("Failed to cancel job: %v", err))
-					} else {
-						app.showInfo("Job Cancelled", fmt.Sprintf("Print job for %s cancelled", app.selectedFile))
-						app.selectedFile = ""
-						app.refreshPrintJobs()
-					}
-				}
-			}, app.window)
-	})
-	btnCancel.Resize(fyne.NewSize(180, 80))
-	btnCancel.Importance = widget.DangerImportance
-	
-	btnDelete := widget.NewButton("Delete Job", func() {
-		if app.selectedFile == "" {
-			app.showError("No File Selected", "Please select a file to delete")
-			return
-		}
-		
-		dialog.ShowConfirm("Delete Job", 
-			fmt.Sprintf("Are you sure you want to delete the print job for %s?", app.selectedFile),
-			func(confirmed bool) {
-				if confirmed {
-					err := app.backend.DeletePrintJob(app.selectedFile)
-					if err != nil {
-						app.showError("Delete Error", fmt.Sprintf("Failed to delete job: %v", err))
-					} else {
-						app.showInfo("Job Deleted", fmt.Sprintf("Print job for %s deleted", app.selectedFile))
-						app.selectedFile = ""
-						app.refreshPrintJobs()
-					}
-				}
-			}, app.window)
-	})
-	btnDelete.Resize(fyne.NewSize(180, 80))
-	btnDelete.Importance = widget.DangerImportance
-	
-	buttonRow := container.NewHBox(btnCancel, btnDelete)
-	
-	app.mainView = container.NewVBox(
-		widget.NewCard("Print Jobs", "", container.NewVBox(
-			buttonRow,
-			jobList,
-		)),
-	)
-	
+	printer := localPrinter
+	ui := NewPrintJobsUI(app.app, app.window, "http://"+backendHost, app.authManager.GetToken(), &printer)
+	ui.SetFileLibrary(app.library)
+	app.printJobsView = ui.CreateUI()
+	ui.SetAuth(app.authManager)
+	if user := app.authManager.GetUser(); user != nil {
+		ui.SetOperator(user.DisplayName())
+	}
+	ui.SetScheduler(app.scheduler, app.backend)
+	ui.SetSpoolInventory(app.spools)
+	app.printJobsUI = ui
+}
+
+// startPrint starts a file from one of the app's screens. Users who may not
+// approve prints have it submitted for approval instead; started reports
+// which happened.
+func (app *IntegratedApp) startPrint(fileName string) (started bool, err error) {
+	if app.printJobsUI != nil {
+		return app.printJobsUI.StartFile(app.backend, fileName)
+	}
+	return StartOrSubmit(app.backend, nil, app.authManager, GCodeFile{Name: fileName, FileName: fileName}, localPrinter.ID)
+}
+
+func (app *IntegratedApp) showPrintJobs() {
+	app.mainView = container.NewVBox(app.printJobsView)
 	app.updateMainContent()
 }
 
@@ -853,6 +981,17 @@ func (app *IntegratedApp) showGCodeViewer() {
 	// Initialize G-code viewer UI if not already done
 	if app.gcodeViewerUI == nil {
 		app.gcodeViewerUI = NewGCodeViewerUI(app.window, app.backend)
+		app.gcodeViewerUI.SetPrintStarter(app.startPrint)
+		
+		// Draw the real bed once the printer profile is known
+		go func() {
+			profile, err := app.backend.GetPrinterProfile()
+			if err != nil {
+				log.Printf("Failed to get printer profile: %v", err)
+				return
+			}
+			app.gcodeViewerUI.SetPrinterProfile(profile)
+		}()
 	}
 	
 	app.mainView = container.NewVBox(
@@ -868,6 +1007,7 @@ func (app *IntegratedApp) updateMainContent() {
 }
 
 func (app *IntegratedApp) setupUI() {
+	app.setupPrintJobs()
 	app.sidebar = app.createSidebar()
 	app.showDashboard() // Show dashboard by default
 	
@@ -899,6 +1039,10 @@ func (app *IntegratedApp) run() {
 	if app.gcodeViewerUI != nil {
 		app.gcodeViewerUI.Stop()
 	}
+	app.scheduler.Stop()
+	if app.usbWatcher != nil {
+		app.usbWatcher.Stop()
+	}
 }
 
 // Alternative main function for integrated version
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PreflightStatus is the outcome of a pre-flight check
+type PreflightStatus string
+
+const (
+	PreflightPass PreflightStatus = "pass"
+	PreflightWarn PreflightStatus = "warn" // Worth a look, but may print
+	PreflightFail PreflightStatus = "fail" // Will not print as it is
+)
+
+// PreflightResult is one check of a file against the printer
+type PreflightResult struct {
+	Check  string
+	Status PreflightStatus
+	Detail string
+}
+
+// JobEstimate is what a job will take, shown to the approver
+type JobEstimate struct {
+	PrintTime  int     // Seconds
+	FilamentMM float64 // Length of filament
+	Grams      float64
+	Material   string // Material of the loaded spool, else the one the file was sliced for
+}
+
+// EstimateJob combines the backend's estimates for a file with what its
+// G-code says, preferring the G-code where the backend has none. The weight
+// and material come from the spool on the tool the file prints with. The
+// model and spools may be nil.
+func EstimateJob(file GCodeFile, model *GCodeModel, spools *SpoolInventory) JobEstimate {
+	estimate := JobEstimate{PrintTime: file.PrintTime, FilamentMM: file.FilamentUsed}
+	if model != nil {
+		if estimate.PrintTime <= 0 {
+			estimate.PrintTime = int(model.Metadata.PrintTime)
+		}
+		if estimate.FilamentMM <= 0 {
+			estimate.FilamentMM = model.Metadata.FilamentUsed
+		}
+		for _, key := range []string{"filament_type", "material"} {
+			if material := model.Metadata.SlicerSettings[key]; material != "" {
+				estimate.Material = strings.Trim(material, `"`)
+				break
+			}
+		}
+	}
+
+	if spools != nil {
+		if spool, ok := spools.Loaded(PrintTool(model)); ok {
+			estimate.Grams = spool.Grams(estimate.FilamentMM)
+			if spool.Material != "" {
+				estimate.Material = spool.Material
+			}
+			return estimate
+		}
+	}
+	estimate.Grams = filamentMMToGrams(estimate.FilamentMM, defaultFilamentDiameter, defaultFilamentDensity)
+	return estimate
+}
+
+// RunPreflight checks a file before it is approved: that it parses, fits
+// the build volume, was sliced for this printer and that the spool on the
+// tool it prints with holds enough filament. The model, profile and spools
+// may be nil, in which case the checks needing them are reported as not run.
+func RunPreflight(file GCodeFile, model *GCodeModel, profile *PrinterProfile, spools *SpoolInventory) []PreflightResult {
+	results := []PreflightResult{}
+
+	if model == nil {
+		return append(results, PreflightResult{"G-code", PreflightWarn, "The file could not be analysed; no other checks were run"})
+	}
+	switch {
+	case len(model.Paths) == 0:
+		results = append(results, PreflightResult{"G-code", PreflightFail, "The file contains no moves"})
+	case len(model.ParseErrors) > 0:
+		results = append(results, PreflightResult{"G-code", PreflightWarn,
+			fmt.Sprintf("%d lines could not be parsed", len(model.ParseErrors))})
+	default:
+		results = append(results, PreflightResult{"G-code", PreflightPass,
+			fmt.Sprintf("%d layers", len(model.Layers))})
+	}
+
+	if bedFromProfile(profile) == nil {
+		results = append(results, PreflightResult{"Build volume", PreflightWarn, "The printer's build volume is unknown"})
+	} else if issues := BuildVolumeIssues(model, GCodeTransform{}, profile); len(issues) > 0 {
+		results = append(results, PreflightResult{"Build volume", PreflightFail, strings.Join(issues, "; ")})
+	} else {
+		results = append(results, PreflightResult{"Build volume", PreflightPass, "Fits the bed"})
+	}
+
+	slicedFor := model.Metadata.PrinterModel
+	if slicedFor == "" {
+		// Text G-code keeps it with the slicer settings
+		slicedFor = strings.Trim(model.Metadata.SlicerSettings["printer_model"], `"`)
+	}
+	switch {
+	case slicedFor == "":
+		results = append(results, PreflightResult{"Printer model", PreflightWarn, "The file does not say which printer it was sliced for"})
+	case profile == nil:
+		results = append(results, PreflightResult{"Printer model", PreflightWarn, "Sliced for " + slicedFor + "; this printer's model is unknown"})
+	case slicedForProfile(slicedFor, profile):
+		results = append(results, PreflightResult{"Printer model", PreflightPass, "Sliced for " + slicedFor})
+	default:
+		results = append(results, PreflightResult{"Printer model", PreflightWarn,
+			fmt.Sprintf("Sliced for %s, this printer is a %s", slicedFor, profile.ModelName)})
+	}
+
+	length := EstimateJob(file, model, nil).FilamentMM
+	tool := PrintTool(model)
+	if spools == nil {
+		results = append(results, PreflightResult{"Filament", PreflightWarn, "Spools are not tracked"})
+	} else if spool, ok := spools.Loaded(tool); !ok {
+		results = append(results, PreflightResult{"Filament", PreflightWarn, fmt.Sprintf("No spool is loaded on tool %d", tool)})
+	} else if warning := spools.CheckJob(tool, length); warning != "" {
+		results = append(results, PreflightResult{"Filament", PreflightFail, warning})
+	} else {
+		results = append(results, PreflightResult{"Filament", PreflightPass,
+			fmt.Sprintf("%s has %.0f g left", spool.Label(), spool.RemainingWeight)})
+	}
+
+	return results
+}
+
+// PreflightPassed reports whether no check failed
+func PreflightPassed(results []PreflightResult) bool {
+	for _, result := range results {
+		if result.Status == PreflightFail {
+			return false
+		}
+	}
+	return true
+}
+
+// slicedForProfile reports whether the printer a file names is this one
+func slicedForProfile(slicedFor string, profile *PrinterProfile) bool {
+	slicedFor = strings.ToLower(strings.Trim(slicedFor, `"`))
+	for _, name := range []string{profile.ModelName, profile.ModelID} {
+		name = strings.ToLower(name)
+		if name != "" && (strings.Contains(slicedFor, name) || strings.Contains(name, slicedFor)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApproveQueueItem lets a pending item print on behalf of the logged in
+// user. auth is nil when the touchscreen has no logins.
+func ApproveQueueItem(queue *PrintQueue, auth *AuthManager, id, reviewer string) error {
+	if err := checkApprover(auth, "approve jobs"); err != nil {
+		return err
+	}
+	return queue.Approve(id, reviewer)
+}
+
+// RejectQueueItem turns down a pending item on behalf of the logged in user
+func RejectQueueItem(queue *PrintQueue, auth *AuthManager, id, reviewer, reason string) error {
+	if err := checkApprover(auth, "reject jobs"); err != nil {
+		return err
+	}
+	return queue.Reject(id, reviewer, reason)
+}
+
+// printStarter starts a file on the printer by name, as BackendClient does
+type printStarter interface {
+	StartPrint(filename string) error
+}
+
+// StartOrSubmit starts a file for a user who may approve prints. Anyone
+// else has it submitted to queue for staff to approve, or is refused when
+// there is no queue to submit to. It reports whether the print started.
+// Every screen that starts prints goes through here.
+func StartOrSubmit(backend printStarter, queue *PrintQueue, auth *AuthManager, file GCodeFile, printerID uint) (bool, error) {
+	err := checkApprover(auth, "start prints")
+	if err == nil {
+		return true, backend.StartPrint(file.FileName)
+	}
+	if queue == nil {
+		return false, err
+	}
+	user := auth.GetUser()
+	if user == nil || !auth.IsAuthenticated() {
+		return false, fmt.Errorf("log in to submit prints")
+	}
+	_, err = queue.Submit(file, 1, printerID, nil, user.DisplayName())
+	return false, err
+}
+
+// SchedulePrint adds a print to the scheduler for a user who may approve
+// prints; scheduled prints start without review
+func SchedulePrint(scheduler *PrintScheduler, auth *AuthManager, scheduled ScheduledPrint) (ScheduledPrint, error) {
+	if err := checkApprover(auth, "schedule prints"); err != nil {
+		return ScheduledPrint{}, err
+	}
+	return scheduler.Add(scheduled)
+}
+
+// checkApprover refuses users who may not approve prints
+func checkApprover(auth *AuthManager, action string) error {
+	if auth != nil && !auth.CanApprove() {
+		return fmt.Errorf("only staff may %s", action)
+	}
+	return nil
+}
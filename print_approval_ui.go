@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// SetAuth sets who is using the touchscreen. Without it every user may
+// start prints, as on a single-user printer.
+func (ui *PrintJobsUI) SetAuth(auth *AuthManager) {
+	ui.auth = auth
+	if ui.printButton != nil {
+		ui.updatePrintButton()
+	}
+	ui.refreshApprovals()
+}
+
+// canApprove reports whether the current user may start prints and approve
+// those submitted by others
+func (ui *PrintJobsUI) canApprove() bool {
+	return ui.auth == nil || ui.auth.CanApprove()
+}
+
+// requireApprover tells the user an action is for staff, reporting whether
+// they may go ahead
+func (ui *PrintJobsUI) requireApprover(action string) bool {
+	if ui.canApprove() {
+		return true
+	}
+	dialog.ShowError(fmt.Errorf("only staff may %s", action), ui.window)
+	return false
+}
+
+// userName is who actions are recorded under; "" when logged out
+func (ui *PrintJobsUI) userName() string {
+	if ui.auth == nil {
+		if ui.operator != "" {
+			return ui.operator
+		}
+		return "touchscreen"
+	}
+	if user := ui.auth.GetUser(); user != nil && ui.auth.IsAuthenticated() {
+		return user.DisplayName()
+	}
+	return ""
+}
+
+// recordAudit adds an action of the current user to the audit trail
+func (ui *PrintJobsUI) recordAudit(action, subject, details string) {
+	event := AuditEvent{User: ui.userName(), Action: action, Subject: subject, Details: details}
+	if ui.auth != nil {
+		event.Role = ui.auth.GetRole()
+	}
+	if err := ui.audit.Record(event); err != nil {
+		log.Printf("Failed to record %s in the audit log: %v", action, err)
+	}
+}
+
+// submitForApproval queues a file that waits for staff to approve it
+func (ui *PrintJobsUI) submitForApproval(file GCodeFile, copies int, printerID uint, capabilities []string) {
+	submitter := ui.userName()
+	if submitter == "" {
+		dialog.ShowError(fmt.Errorf("log in to submit prints"), ui.window)
+		return
+	}
+	item, err := ui.queue.Submit(file, copies, printerID, capabilities, submitter)
+	if err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	ui.recordAudit(AuditJobSubmitted, item.Name, fmt.Sprintf("%d copies, %s", item.Copies, item.Assignment()))
+	ui.statusLabel.SetText(fmt.Sprintf("Submitted for approval: %s", file.Name))
+}
+
+// StartFile starts a file from another screen of the app. Users who may
+// not approve prints have it submitted for approval instead; it reports
+// whether the print started.
+func (ui *PrintJobsUI) StartFile(backend printStarter, fileName string) (bool, error) {
+	file, ok := ui.fileNamed(fileName)
+	if !ok {
+		file = GCodeFile{Name: fileName, FileName: fileName}
+	}
+	started, err := StartOrSubmit(backend, ui.queue, ui.auth, file, ui.currentPrinter.ID)
+	if err == nil && !started {
+		ui.recordAudit(AuditJobSubmitted, file.Name, "1 copy, "+ui.currentPrinter.Name)
+		ui.statusLabel.SetText(fmt.Sprintf("Submitted for approval: %s", file.Name))
+	}
+	return started, err
+}
+
+// fileNamed finds a file of the job API by its name on the printer
+func (ui *PrintJobsUI) fileNamed(fileName string) (GCodeFile, bool) {
+	for _, file := range ui.gcodeFiles {
+		if file.FileName == fileName {
+			return file, true
+		}
+	}
+	return GCodeFile{}, false
+}
+
+// createApprovalSection lists the jobs waiting for approval. Staff review
+// them from here; students and guests follow their own submissions.
+func (ui *PrintJobsUI) createApprovalSection() fyne.CanvasObject {
+	ui.approvalStatus = widget.NewLabel("")
+	ui.approvalStatus.Wrapping = fyne.TextWrapWord
+
+	ui.approvalList = widget.NewList(
+		func() int { return len(ui.approvalItems) },
+		func() fyne.CanvasObject {
+			return container.NewVBox(
+				widget.NewLabelWithStyle("Job name", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+				widget.NewLabel("Details"),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(ui.approvalItems) {
+				return
+			}
+			item := ui.approvalItems[id]
+			box := obj.(*fyne.Container)
+			box.Objects[0].(*widget.Label).SetText(item.Name)
+
+			details := fmt.Sprintf("%s | Submitted by %s %s | Copies: %d | %s",
+				item.StateLabel(), item.SubmittedBy, item.AddedAt.Format("Jan 2 15:04"),
+				item.Copies, item.Assignment())
+			if item.ReviewNote != "" {
+				details += " | " + item.ReviewNote
+			}
+			box.Objects[1].(*widget.Label).SetText(details)
+		},
+	)
+	ui.approvalList.OnSelected = func(id widget.ListItemID) {
+		ui.approvalList.Unselect(id)
+		if id < len(ui.approvalItems) {
+			ui.showApprovalDialog(ui.approvalItems[id])
+		}
+	}
+	ui.refreshApprovals()
+
+	auditBtn := widget.NewButtonWithIcon("Audit Trail", theme.HistoryIcon(), func() {
+		ui.showAuditTrail()
+	})
+
+	return container.NewBorder(
+		container.NewBorder(nil, nil, nil, auditBtn, container.NewPadded(ui.approvalStatus)),
+		nil, nil, nil,
+		ui.approvalList,
+	)
+}
+
+// refreshApprovals lists what the current user should see: pending jobs
+// for staff, their own submissions for everyone else
+func (ui *PrintJobsUI) refreshApprovals() {
+	if ui.approvalList == nil {
+		return
+	}
+
+	if ui.canApprove() {
+		ui.approvalItems = ui.queue.Pending()
+		ui.approvalStatus.SetText(fmt.Sprintf("%d jobs awaiting approval", len(ui.approvalItems)))
+	} else {
+		me := ui.userName()
+		ui.approvalItems = []QueueItem{}
+		for _, item := range ui.queue.Items() {
+			if me != "" && item.SubmittedBy == me {
+				ui.approvalItems = append(ui.approvalItems, item)
+			}
+		}
+		if me == "" {
+			ui.approvalStatus.SetText("Log in to submit prints for approval")
+		} else {
+			ui.approvalStatus.SetText(fmt.Sprintf(
+				"Signed in as %s. Prints you submit wait here until staff approve them.", me))
+		}
+	}
+	ui.approvalList.Refresh()
+}
+
+// showApprovalDialog shows a submitted job with its estimates and
+// pre-flight checks. Staff approve or reject it from here.
+func (ui *PrintJobsUI) showApprovalDialog(item QueueItem) {
+	var file GCodeFile
+	for _, candidate := range ui.gcodeFiles {
+		if candidate.ID == item.FileID {
+			file = candidate
+		}
+	}
+	if file.FileName == "" {
+		file = GCodeFile{ID: item.FileID, Name: item.Name, FileName: item.FileName}
+	}
+
+	summary := widget.NewForm(
+		widget.NewFormItem("Submitted by", widget.NewLabel(fmt.Sprintf("%s, %s",
+			item.SubmittedBy, item.AddedAt.Format("Jan 2 2006 15:04")))),
+		widget.NewFormItem("Copies", widget.NewLabel(fmt.Sprintf("%d (%s)", item.Copies, item.Assignment()))),
+		widget.NewFormItem("Status", widget.NewLabel(item.StateLabel())),
+	)
+	if item.ReviewNote != "" {
+		summary.Append("Reason", widget.NewLabel(item.ReviewNote))
+	}
+	estimates := widget.NewForm()
+	checks := container.NewVBox(widget.NewLabel("Analysing file..."))
+
+	approveBtn := widget.NewButtonWithIcon("Approve", theme.ConfirmIcon(), nil)
+	approveBtn.Importance = widget.HighImportance
+	rejectBtn := widget.NewButtonWithIcon("Reject", theme.CancelIcon(), nil)
+	approveBtn.Disable()
+	if !ui.canApprove() || item.State != QueueItemPending {
+		rejectBtn.Disable()
+	}
+
+	content := container.NewVBox(
+		summary,
+		widget.NewCard("", "Estimates", estimates),
+		widget.NewCard("", "Pre-flight Checks", checks),
+		container.NewGridWithColumns(2, approveBtn, rejectBtn),
+	)
+	approval := dialog.NewCustom("Review: "+item.Name, "Close", container.NewVScroll(content), ui.window)
+
+	var results []PreflightResult
+	approveBtn.OnTapped = func() {
+		if PreflightPassed(results) {
+			approval.Hide()
+			ui.approveQueueItem(item)
+			return
+		}
+		dialog.ShowConfirm("Approve Anyway?",
+			"Some pre-flight checks failed. Approve the job regardless?",
+			func(ok bool) {
+				if ok {
+					approval.Hide()
+					ui.approveQueueItem(item)
+				}
+			}, ui.window)
+	}
+	rejectBtn.OnTapped = func() {
+		ui.showRejectDialog(item, approval)
+	}
+
+	approval.Resize(fyne.NewSize(560, 600))
+	approval.Show()
+
+	go func() {
+		model := ui.analyseQueuedFile(item)
+		estimate := EstimateJob(file, model, ui.spools)
+		results = RunPreflight(file, model, ui.profile, ui.spools)
+
+		estimates.Append("Print time", widget.NewLabel(ui.formatDuration(estimate.PrintTime)))
+		material := estimate.Material
+		if material == "" {
+			material = "Unknown"
+		}
+		estimates.Append("Material", widget.NewLabel(fmt.Sprintf("%s, %.0f g (%.1f m)",
+			material, estimate.Grams, estimate.FilamentMM/1000)))
+
+		checks.Objects = nil
+		for _, result := range results {
+			icon := theme.ConfirmIcon()
+			switch result.Status {
+			case PreflightWarn:
+				icon = theme.WarningIcon()
+			case PreflightFail:
+				icon = theme.ErrorIcon()
+			}
+			detail := widget.NewLabel(result.Check + ": " + result.Detail)
+			detail.Wrapping = fyne.TextWrapWord
+			checks.Add(container.NewBorder(nil, nil, widget.NewIcon(icon), nil, detail))
+		}
+		checks.Refresh()
+
+		if ui.canApprove() && item.State == QueueItemPending {
+			approveBtn.Enable()
+		}
+	}()
+}
+
+// analyseQueuedFile downloads and parses a queued file for the pre-flight
+// checks; nil if it cannot be read
+func (ui *PrintJobsUI) analyseQueuedFile(item QueueItem) *GCodeModel {
+	if ui.backend == nil {
+		return nil
+	}
+	reader, err := ui.backend.DownloadGCode(item.FileName)
+	if err != nil {
+		log.Printf("Failed to download %s for pre-flight checks: %v", item.FileName, err)
+		return nil
+	}
+	defer reader.Close()
+	model, err := NewGCodeParser().ParseGCode(reader)
+	if err != nil {
+		log.Printf("Failed to parse %s for pre-flight checks: %v", item.FileName, err)
+		return nil
+	}
+	return model
+}
+
+// showRejectDialog asks why a job is turned down
+func (ui *PrintJobsUI) showRejectDialog(item QueueItem, approval dialog.Dialog) {
+	reasonEntry := widget.NewMultiLineEntry()
+	reasonEntry.SetPlaceHolder("e.g. needs supports, too long for a class slot")
+
+	dialog.ShowForm("Reject "+item.Name, "Reject", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Reason", reasonEntry),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		reason := strings.TrimSpace(reasonEntry.Text)
+		if reason == "" {
+			dialog.ShowError(fmt.Errorf("give the submitter a reason"), ui.window)
+			return
+		}
+		if err := RejectQueueItem(ui.queue, ui.auth, item.ID, ui.userName(), reason); err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		approval.Hide()
+		ui.recordAudit(AuditJobRejected, item.Name, fmt.Sprintf("submitted by %s: %s", item.SubmittedBy, reason))
+		ui.statusLabel.SetText(fmt.Sprintf("Rejected: %s", item.Name))
+	}, ui.window)
+}
+
+// approveQueueItem lets a submitted job print and starts it if the printer
+// is free
+func (ui *PrintJobsUI) approveQueueItem(item QueueItem) {
+	if err := ApproveQueueItem(ui.queue, ui.auth, item.ID, ui.userName()); err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	ui.recordAudit(AuditJobApproved, item.Name, "submitted by "+item.SubmittedBy)
+	ui.statusLabel.SetText(fmt.Sprintf("Approved: %s", item.Name))
+	ui.startNextQueued()
+}
+
+// showAuditTrail lists the recorded submissions and decisions, newest first
+func (ui *PrintJobsUI) showAuditTrail() {
+	events := ui.audit.Events()
+
+	var content fyne.CanvasObject
+	if len(events) == 0 {
+		content = widget.NewLabel("Nothing has been recorded yet.")
+	} else {
+		list := widget.NewList(
+			func() int { return len(events) },
+			func() fyne.CanvasObject {
+				return container.NewVBox(
+					widget.NewLabelWithStyle("When", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+					widget.NewLabel("What"),
+				)
+			},
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				event := events[id]
+				box := obj.(*fyne.Container)
+				when := event.Time.Format("Jan 2 2006 15:04:05")
+				if event.Role != "" {
+					when += " (" + event.Role + ")"
+				}
+				box.Objects[0].(*widget.Label).SetText(when)
+				box.Objects[1].(*widget.Label).SetText(event.Describe())
+			},
+		)
+		content = list
+	}
+
+	trail := dialog.NewCustom("Audit Trail", "Close", content, ui.window)
+	trail.Resize(fyne.NewSize(640, 520))
+	trail.Show()
+}
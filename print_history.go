@@ -0,0 +1,486 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultFilamentDensity is assumed in g/cm³ (PLA) when a job's material is
+// unknown
+const defaultFilamentDensity = 1.24
+
+// unknownMaterial groups filament whose material was not recorded
+const unknownMaterial = "Unknown"
+
+// HistoryEntry is a finished print job
+type HistoryEntry struct {
+	JobID         uint        `json:"job_id"`
+	Name          string      `json:"name"`
+	FileName      string      `json:"file_name"`
+	PrinterID     uint        `json:"printer_id"`
+	PrinterName   string      `json:"printer_name"`
+	Status        string      `json:"status"` // completed, failed or cancelled
+	StartedAt     time.Time   `json:"started_at"`
+	EndedAt       time.Time   `json:"ended_at"`
+	Duration      int         `json:"duration"`       // Seconds
+	FilamentUsed  float64     `json:"filament_used"`  // mm
+	FilamentGrams float64     `json:"filament_grams"` // 0 when only the length is known
+	Material      string      `json:"material,omitempty"`
+	SpoolID       uint        `json:"spool_id,omitempty"`
+	Cost          float64     `json:"cost,omitempty"`          // Material cost, 0 when the spool price is unknown
+	Progress      int         `json:"progress,omitempty"`      // Percent reached
+	StopLine      int         `json:"stop_line,omitempty"`     // Source line an unfinished job stopped at, if reported
+	StopPosition  int64       `json:"stop_position,omitempty"` // Byte offset an unfinished job stopped at, if reported
+	FailureReason string      `json:"failure_reason,omitempty"`
+	Notes         string      `json:"notes,omitempty"`
+	Snapshot      string      `json:"snapshot,omitempty"` // Viewer image of where the job stopped
+	SignOff       *JobSignOff `json:"sign_off,omitempty"`
+}
+
+// Succeeded reports whether the job completed
+func (e *HistoryEntry) Succeeded() bool {
+	return e.Status == "completed"
+}
+
+// Grams returns the filament weight, estimated from the length for PLA
+// when the weight was not recorded
+func (e *HistoryEntry) Grams() float64 {
+	if e.FilamentGrams > 0 {
+		return e.FilamentGrams
+	}
+	return filamentMMToGrams(e.FilamentUsed, defaultFilamentDiameter, defaultFilamentDensity)
+}
+
+// PrinterLabel returns the printer name, or its ID when the name is unknown
+func (e *HistoryEntry) PrinterLabel() string {
+	if e.PrinterName != "" {
+		return e.PrinterName
+	}
+	return fmt.Sprintf("Printer %d", e.PrinterID)
+}
+
+// MaterialName returns the material, or Unknown
+func (e *HistoryEntry) MaterialName() string {
+	if e.Material == "" {
+		return unknownMaterial
+	}
+	return e.Material
+}
+
+// filamentMMToGrams converts a filament length to its weight
+func filamentMMToGrams(lengthMM, diameterMM, density float64) float64 {
+	radius := diameterMM / 2
+	volumeCM3 := math.Pi * radius * radius * lengthMM / 1000
+	return volumeCM3 * density
+}
+
+// isFinishedJobStatus reports whether a job status is final
+func isFinishedJobStatus(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+// HistoryFilter selects history entries. Zero fields match everything.
+type HistoryFilter struct {
+	Search    string    // Matched against job, file and printer names
+	From, To  time.Time // Start time range, To exclusive
+	Status    string
+	PrinterID uint
+	FileName  string
+}
+
+// Matches reports whether an entry passes the filter
+func (f HistoryFilter) Matches(e *HistoryEntry) bool {
+	if !f.From.IsZero() && e.StartedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.StartedAt.Before(f.To) {
+		return false
+	}
+	if f.Status != "" && e.Status != f.Status {
+		return false
+	}
+	if f.PrinterID != 0 && e.PrinterID != f.PrinterID {
+		return false
+	}
+	if f.FileName != "" && e.FileName != f.FileName {
+		return false
+	}
+	if search := strings.ToLower(strings.TrimSpace(f.Search)); search != "" {
+		text := strings.ToLower(e.Name + " " + e.FileName + " " + e.PrinterName + " " + e.Material)
+		return strings.Contains(text, search)
+	}
+	return true
+}
+
+// HistoryStats are the totals of a set of history entries
+type HistoryStats struct {
+	Total              int                       `json:"total"`
+	Completed          int                       `json:"completed"`
+	Failed             int                       `json:"failed"`
+	Cancelled          int                       `json:"cancelled"`
+	SuccessRate        float64                   `json:"success_rate"`         // Percent of completed and failed jobs, leaving out cancelled ones
+	PrintHours         float64                   `json:"print_hours"`          // Hours spent printing, whatever the outcome
+	FilamentGrams      float64                   `json:"filament_grams"`       // Total filament used
+	MaterialCost       float64                   `json:"material_cost"`        // Total cost of the filament used
+	FilamentByMaterial map[string]float64        `json:"filament_by_material"` // Grams per material
+	FailureReasons     map[string]int            `json:"failure_reasons"`      // Failed and cancelled jobs per reason
+	PrinterFailures    map[string]map[string]int `json:"printer_failures"`     // Failure reasons per printer
+	QualityGrades      map[string]int            `json:"quality_grades"`       // Signed-off jobs per grade
+}
+
+// ComputeHistoryStats totals a set of history entries
+func ComputeHistoryStats(entries []HistoryEntry) HistoryStats {
+	stats := HistoryStats{
+		FilamentByMaterial: make(map[string]float64),
+		FailureReasons:     make(map[string]int),
+		PrinterFailures:    make(map[string]map[string]int),
+		QualityGrades:      make(map[string]int),
+	}
+	for i := range entries {
+		e := &entries[i]
+		stats.Total++
+		switch e.Status {
+		case "completed":
+			stats.Completed++
+		case "failed":
+			stats.Failed++
+		case "cancelled":
+			stats.Cancelled++
+		}
+		if !e.Succeeded() {
+			reason := e.FailureReason
+			if reason == "" {
+				reason = failureNotRecorded
+			}
+			stats.FailureReasons[reason]++
+			printer := e.PrinterLabel()
+			if stats.PrinterFailures[printer] == nil {
+				stats.PrinterFailures[printer] = make(map[string]int)
+			}
+			stats.PrinterFailures[printer][reason]++
+		}
+		if e.SignOff != nil {
+			stats.QualityGrades[e.SignOff.Quality]++
+		}
+
+		stats.PrintHours += float64(e.Duration) / 3600
+		grams := e.Grams()
+		stats.FilamentGrams += grams
+		stats.MaterialCost += e.Cost
+		if grams > 0 {
+			stats.FilamentByMaterial[e.MaterialName()] += grams
+		}
+	}
+	// A cancelled job may have been stopped on purpose, so it is neither a
+	// success nor a failure
+	if finished := stats.Completed + stats.Failed; finished > 0 {
+		stats.SuccessRate = float64(stats.Completed) / float64(finished) * 100
+	}
+	return stats
+}
+
+// printHistoryData is the stored form of the history
+type printHistoryData struct {
+	Entries   []HistoryEntry `json:"entries"`
+	ClearedAt time.Time      `json:"cleared_at,omitempty"`
+}
+
+// PrintHistory keeps finished jobs on the touchscreen, so statistics and
+// reports do not depend on how long the backend keeps its job list
+type PrintHistory struct {
+	mu        sync.Mutex
+	entries   []HistoryEntry
+	clearedAt time.Time // Jobs started before this were cleared and are not merged back
+	path      string
+}
+
+// NewPrintHistory creates an empty history stored at path; an empty path
+// keeps it in memory only
+func NewPrintHistory(path string) *PrintHistory {
+	return &PrintHistory{path: path}
+}
+
+// printHistoryFile is where the touchscreen keeps its print history
+func printHistoryFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "print_history.json")
+}
+
+// Load reads the stored history. A missing file leaves it empty.
+func (h *PrintHistory) Load() error {
+	data, err := ioutil.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored printHistoryData
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid history file: %v", err)
+	}
+
+	h.mu.Lock()
+	h.entries = stored.Entries
+	h.clearedAt = stored.ClearedAt
+	h.mu.Unlock()
+	return nil
+}
+
+// Record adds a finished job, or updates it if already recorded. Details
+// only known locally, such as the material or failure reason, are kept
+// when the update lacks them.
+func (h *PrintHistory) Record(entry HistoryEntry) error {
+	h.mu.Lock()
+	h.record(entry)
+	h.mu.Unlock()
+	return h.save()
+}
+
+// Update changes a recorded entry
+func (h *PrintHistory) Update(jobID uint, change func(*HistoryEntry)) error {
+	h.mu.Lock()
+	index := h.find(jobID)
+	if index < 0 {
+		h.mu.Unlock()
+		return fmt.Errorf("job %d is not in the history", jobID)
+	}
+	change(&h.entries[index])
+	h.mu.Unlock()
+	return h.save()
+}
+
+// Get returns a recorded entry
+func (h *PrintHistory) Get(jobID uint) (HistoryEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if index := h.find(jobID); index >= 0 {
+		return h.entries[index], true
+	}
+	return HistoryEntry{}, false
+}
+
+// Merge records the finished jobs of a backend job list
+func (h *PrintHistory) Merge(jobs []PrintJob) error {
+	h.mu.Lock()
+	for _, job := range jobs {
+		if !isFinishedJobStatus(job.Status) {
+			continue
+		}
+		h.record(HistoryEntryFromJob(job))
+	}
+	h.mu.Unlock()
+	return h.save()
+}
+
+// HistoryEntryFromJob converts a finished backend job
+func HistoryEntryFromJob(job PrintJob) HistoryEntry {
+	return HistoryEntry{
+		JobID:       job.ID,
+		Name:        job.Name,
+		FileName:    job.FileName,
+		PrinterID:   job.PrinterID,
+		PrinterName: job.PrinterName,
+		Status:      job.Status,
+		Progress:    job.Progress,
+		StartedAt:   job.StartedAt,
+		EndedAt:     job.StartedAt.Add(time.Duration(job.TimeElapsed) * time.Second),
+		Duration:    job.TimeElapsed,
+	}
+}
+
+// Entries returns the entries passing a filter, newest first
+func (h *PrintHistory) Entries(filter HistoryFilter) []HistoryEntry {
+	h.mu.Lock()
+	entries := []HistoryEntry{}
+	for i := range h.entries {
+		if filter.Matches(&h.entries[i]) {
+			entries = append(entries, h.entries[i])
+		}
+	}
+	h.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedAt.After(entries[j].StartedAt)
+	})
+	return entries
+}
+
+// Printers returns the printers in the history by ID
+func (h *PrintHistory) Printers() map[uint]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	printers := make(map[uint]string)
+	for _, e := range h.entries {
+		if e.PrinterID != 0 {
+			printers[e.PrinterID] = e.PrinterName
+		}
+	}
+	return printers
+}
+
+// Clear empties the history, with its snapshots. Jobs the backend still
+// lists are not merged back in.
+func (h *PrintHistory) Clear() error {
+	h.mu.Lock()
+	for _, e := range h.entries {
+		if e.Snapshot != "" {
+			os.Remove(e.Snapshot)
+		}
+	}
+	h.entries = nil
+	h.clearedAt = time.Now()
+	h.mu.Unlock()
+	return h.save()
+}
+
+// record adds or updates an entry; the caller holds the lock
+func (h *PrintHistory) record(entry HistoryEntry) {
+	index := h.find(entry.JobID)
+	if index < 0 {
+		if !h.clearedAt.IsZero() && entry.StartedAt.Before(h.clearedAt) {
+			return
+		}
+		h.entries = append(h.entries, entry)
+		return
+	}
+
+	existing := &h.entries[index]
+	if entry.FilamentUsed == 0 {
+		entry.FilamentUsed = existing.FilamentUsed
+	}
+	if entry.FilamentGrams == 0 {
+		entry.FilamentGrams = existing.FilamentGrams
+	}
+	if entry.Material == "" {
+		entry.Material = existing.Material
+	}
+	if entry.SpoolID == 0 {
+		entry.SpoolID = existing.SpoolID
+	}
+	if entry.Cost == 0 {
+		entry.Cost = existing.Cost
+	}
+	if entry.Progress == 0 {
+		entry.Progress = existing.Progress
+	}
+	if entry.FailureReason == "" {
+		entry.FailureReason = existing.FailureReason
+	}
+	if entry.Notes == "" {
+		entry.Notes = existing.Notes
+	}
+	if entry.Snapshot == "" {
+		entry.Snapshot = existing.Snapshot
+	}
+	if entry.SignOff == nil {
+		entry.SignOff = existing.SignOff
+	}
+	if entry.PrinterName == "" {
+		entry.PrinterName = existing.PrinterName
+	}
+	*existing = entry
+}
+
+// find returns the index of a job's entry, or -1; the caller holds the lock
+func (h *PrintHistory) find(jobID uint) int {
+	for i := range h.entries {
+		if h.entries[i].JobID == jobID {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the history to disk
+func (h *PrintHistory) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	h.mu.Lock()
+	jsonData, err := json.MarshalIndent(printHistoryData{Entries: h.entries, ClearedAt: h.clearedAt}, "", "  ")
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.path, jsonData, 0600)
+}
+
+// historyCSVHeader are the columns of a CSV export
+var historyCSVHeader = []string{
+	"Job ID", "Name", "File", "Printer ID", "Printer", "Status",
+	"Started", "Ended", "Duration (h)", "Filament (mm)", "Filament (g)", "Material", "Cost", "Failure Reason",
+	"Notes", "Quality", "Signed Off By", "Signed Off At",
+}
+
+// ExportHistoryCSV writes entries as CSV for spreadsheets
+func ExportHistoryCSV(w io.Writer, entries []HistoryEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(historyCSVHeader); err != nil {
+		return err
+	}
+	for i := range entries {
+		e := &entries[i]
+		quality, operator, signedAt := "", "", ""
+		if e.SignOff != nil {
+			quality = e.SignOff.Quality
+			operator = e.SignOff.Operator
+			signedAt = e.SignOff.SignedAt.Format(time.RFC3339)
+		}
+		record := []string{
+			fmt.Sprint(e.JobID),
+			e.Name,
+			e.FileName,
+			fmt.Sprint(e.PrinterID),
+			e.PrinterName,
+			e.Status,
+			e.StartedAt.Format(time.RFC3339),
+			e.EndedAt.Format(time.RFC3339),
+			fmt.Sprintf("%.2f", float64(e.Duration)/3600),
+			fmt.Sprintf("%.0f", e.FilamentUsed),
+			fmt.Sprintf("%.1f", e.Grams()),
+			e.MaterialName(),
+			fmt.Sprintf("%.2f", e.Cost),
+			e.FailureReason,
+			e.Notes,
+			quality,
+			operator,
+			signedAt,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportHistoryJSON writes entries and their statistics as JSON
+func ExportHistoryJSON(w io.Writer, entries []HistoryEntry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		ExportedAt time.Time      `json:"exported_at"`
+		Stats      HistoryStats   `json:"stats"`
+		Entries    []HistoryEntry `json:"entries"`
+	}{
+		ExportedAt: time.Now(),
+		Stats:      ComputeHistoryStats(entries),
+		Entries:    entries,
+	})
+}
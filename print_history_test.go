@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"testing"
+	"time"
+)
+
+// historyTestEntries are two printers' jobs over two days
+func historyTestEntries() []HistoryEntry {
+	day := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	return []HistoryEntry{
+		{JobID: 1, Name: "Bracket", FileName: "bracket.gcode", PrinterID: 1, PrinterName: "Left", Status: "completed",
+			StartedAt: day, Duration: 3600, FilamentGrams: 20, Material: "PETG", Cost: 0.5,
+			SignOff: &JobSignOff{Operator: "sam", Quality: "A", SignedAt: day.Add(2 * time.Hour)}},
+		{JobID: 2, Name: "Bracket", FileName: "bracket.gcode", PrinterID: 1, PrinterName: "Left", Status: "completed",
+			StartedAt: day.Add(2 * time.Hour), Duration: 3600, FilamentGrams: 20, Material: "PETG", Cost: 0.5},
+		{JobID: 3, Name: "Lid", FileName: "lid.gcode", PrinterID: 2, PrinterName: "Right", Status: "completed",
+			StartedAt: day.Add(24 * time.Hour), Duration: 1800, FilamentGrams: 10},
+		{JobID: 4, Name: "Lid", FileName: "lid.gcode", PrinterID: 2, PrinterName: "Right", Status: "failed",
+			StartedAt: day.Add(25 * time.Hour), Duration: 900, FilamentGrams: 5, FailureReason: "Spaghetti"},
+		{JobID: 5, Name: "Lid", FileName: "lid.gcode", PrinterID: 2, PrinterName: "Right", Status: "cancelled",
+			StartedAt: day.Add(26 * time.Hour), Duration: 0},
+		{JobID: 6, Name: "Hook", FileName: "hook.gcode", PrinterID: 1, PrinterName: "Left", Status: "cancelled",
+			StartedAt: day.Add(27 * time.Hour), Duration: 0},
+	}
+}
+
+func TestComputeHistoryStats(t *testing.T) {
+	stats := ComputeHistoryStats(historyTestEntries())
+
+	if stats.Total != 6 || stats.Completed != 3 || stats.Failed != 1 || stats.Cancelled != 2 {
+		t.Errorf("counts %d/%d/%d/%d, want 6 total, 3 completed, 1 failed, 2 cancelled",
+			stats.Total, stats.Completed, stats.Failed, stats.Cancelled)
+	}
+	if stats.SuccessRate != 75 {
+		t.Errorf("success rate %.1f, want 75 (cancelled jobs left out)", stats.SuccessRate)
+	}
+	if stats.PrintHours != 2.75 {
+		t.Errorf("print hours %.2f, want 2.75", stats.PrintHours)
+	}
+	if stats.FilamentGrams != 55 {
+		t.Errorf("filament %.1f g, want 55", stats.FilamentGrams)
+	}
+	if stats.MaterialCost != 1 {
+		t.Errorf("material cost %.2f, want 1", stats.MaterialCost)
+	}
+	if stats.FilamentByMaterial["PETG"] != 40 || stats.FilamentByMaterial[unknownMaterial] != 15 {
+		t.Errorf("filament by material %v", stats.FilamentByMaterial)
+	}
+	if stats.FailureReasons["Spaghetti"] != 1 || stats.FailureReasons[failureNotRecorded] != 2 {
+		t.Errorf("failure reasons %v", stats.FailureReasons)
+	}
+	if stats.PrinterFailures["Right"]["Spaghetti"] != 1 || stats.PrinterFailures["Left"][failureNotRecorded] != 1 {
+		t.Errorf("printer failures %v", stats.PrinterFailures)
+	}
+	if stats.QualityGrades["A"] != 1 || len(stats.QualityGrades) != 1 {
+		t.Errorf("quality grades %v", stats.QualityGrades)
+	}
+}
+
+func TestComputeHistoryStatsOnlyCancelled(t *testing.T) {
+	entries := historyTestEntries()[4:]
+	stats := ComputeHistoryStats(entries)
+	if stats.SuccessRate != 0 || stats.Cancelled != 2 {
+		t.Errorf("success rate %.1f with %d cancelled, want 0 with 2", stats.SuccessRate, stats.Cancelled)
+	}
+}
+
+func TestHistoryEntryGrams(t *testing.T) {
+	entry := HistoryEntry{FilamentUsed: 1000}
+	// 1 m of 1.75 mm filament is 2.405 cm³, about 2.98 g of PLA
+	if grams := entry.Grams(); math.Abs(grams-2.98) > 0.01 {
+		t.Errorf("grams %.3f, want about 2.98", grams)
+	}
+	entry.FilamentGrams = 4
+	if grams := entry.Grams(); grams != 4 {
+		t.Errorf("recorded grams %.3f, want 4", grams)
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   []uint
+	}{
+		{"all", HistoryFilter{}, []uint{1, 2, 3, 4, 5, 6}},
+		{"status", HistoryFilter{Status: "cancelled"}, []uint{5, 6}},
+		{"printer", HistoryFilter{PrinterID: 2}, []uint{3, 4, 5}},
+		{"file", HistoryFilter{FileName: "bracket.gcode"}, []uint{1, 2}},
+		{"from", HistoryFilter{From: day}, []uint{3, 4, 5, 6}},
+		{"to exclusive", HistoryFilter{To: day.Add(9 * time.Hour)}, []uint{1, 2}},
+		{"search name", HistoryFilter{Search: " hOOk "}, []uint{6}},
+		{"search printer", HistoryFilter{Search: "right"}, []uint{3, 4, 5}},
+		{"search material", HistoryFilter{Search: "petg"}, []uint{1, 2}},
+		{"combined", HistoryFilter{Status: "completed", PrinterID: 1, From: day.Add(-14 * time.Hour)}, []uint{2}},
+	}
+	entries := historyTestEntries()
+	for _, test := range tests {
+		var got []uint
+		for i := range entries {
+			if test.filter.Matches(&entries[i]) {
+				got = append(got, entries[i].JobID)
+			}
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: matched %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: matched %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestExportHistoryCSV(t *testing.T) {
+	entries := historyTestEntries()
+	entries[0].Notes = "Stringing, then \"fixed\""
+	var buf bytes.Buffer
+	if err := ExportHistoryCSV(&buf, entries[:4]); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("%d records, want a header and 4 rows", len(records))
+	}
+	for i, column := range historyCSVHeader {
+		if records[0][i] != column {
+			t.Errorf("header column %d is %q, want %q", i, records[0][i], column)
+		}
+	}
+
+	want := []string{
+		"1", "Bracket", "bracket.gcode", "1", "Left", "completed",
+		"2024-03-04T09:00:00Z", "0001-01-01T00:00:00Z", "1.00", "0", "20.0", "PETG", "0.50", "",
+		"Stringing, then \"fixed\"", "A", "sam", "2024-03-04T11:00:00Z",
+	}
+	for i := range want {
+		if records[1][i] != want[i] {
+			t.Errorf("row 1 column %q is %q, want %q", historyCSVHeader[i], records[1][i], want[i])
+		}
+	}
+
+	failed := records[4]
+	if failed[5] != "failed" || failed[13] != "Spaghetti" || failed[11] != unknownMaterial || failed[15] != "" {
+		t.Errorf("failed row %q", failed)
+	}
+}
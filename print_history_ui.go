@@ -0,0 +1,403 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// History date range choices
+const (
+	historyAllTime   = "All time"
+	historyToday     = "Today"
+	historyLast7     = "Last 7 days"
+	historyLast30    = "Last 30 days"
+	historyThisMonth = "This month"
+	historyLastMonth = "Last month"
+)
+
+// historyAllChoice is the select choice that disables a filter
+const historyAllChoice = "All"
+
+// historyChartBars is the number of bars a history chart shows
+const historyChartBars = 6
+
+// historyStatsView holds the widgets showing history statistics
+type historyStatsView struct {
+	total         *widget.Label
+	successRate   *widget.Label
+	printHours    *widget.Label
+	filament      *widget.Label
+	cost          *widget.Label
+	materialChart *BarChart
+	reasonChart   *BarChart
+	printerCauses *widget.Label
+	printerSelect *widget.Select
+	printerIDs    map[string]uint
+}
+
+// BarValue is a labelled bar of a bar chart
+type BarValue struct {
+	Label string
+	Value float64
+}
+
+// BarChart draws horizontal bars, largest first
+type BarChart struct {
+	widget.BaseWidget
+
+	title  string
+	unit   string
+	values []BarValue
+	width  float32
+	height float32
+
+	barColor  color.Color
+	textColor color.Color
+}
+
+// NewBarChart creates a bar chart whose values are shown with a unit
+func NewBarChart(title, unit string, barColor color.Color) *BarChart {
+	chart := &BarChart{
+		title:     title,
+		unit:      unit,
+		barColor:  barColor,
+		textColor: color.NRGBA{R: 28, G: 28, B: 30, A: 255}, // Dark
+	}
+	chart.ExtendBaseWidget(chart)
+	return chart
+}
+
+// SetValues replaces the bars. Values beyond the chart's capacity are
+// summed into an "Other" bar.
+func (c *BarChart) SetValues(values []BarValue) {
+	sorted := append([]BarValue(nil), values...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Value > sorted[j].Value
+	})
+	if len(sorted) > historyChartBars {
+		other := BarValue{Label: "Other"}
+		for _, value := range sorted[historyChartBars-1:] {
+			other.Value += value.Value
+		}
+		sorted = append(sorted[:historyChartBars-1], other)
+	}
+	c.values = sorted
+	c.Refresh()
+}
+
+// CreateRenderer creates the bar chart renderer
+func (c *BarChart) CreateRenderer() fyne.WidgetRenderer {
+	return &barChartRenderer{chart: c}
+}
+
+// barChartRenderer renders a bar chart
+type barChartRenderer struct {
+	chart *BarChart
+}
+
+func (r *barChartRenderer) Layout(size fyne.Size) {
+	r.chart.width = size.Width
+	r.chart.height = size.Height
+}
+
+func (r *barChartRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(240, 24+historyChartBars*20)
+}
+
+func (r *barChartRenderer) Refresh() {
+	// Objects are rebuilt on every draw
+}
+
+func (r *barChartRenderer) Destroy() {
+	// Nothing to destroy
+}
+
+func (r *barChartRenderer) Objects() []fyne.CanvasObject {
+	c := r.chart
+	objects := []fyne.CanvasObject{}
+
+	title := canvas.NewText(c.title, c.textColor)
+	title.TextStyle = fyne.TextStyle{Bold: true}
+	title.TextSize = 12
+	objects = append(objects, title)
+
+	if len(c.values) == 0 {
+		text := canvas.NewText("No data", c.textColor)
+		text.TextSize = 11
+		text.Move(fyne.NewPos(0, 24))
+		return append(objects, text)
+	}
+
+	maxValue := 0.0
+	for _, value := range c.values {
+		if value.Value > maxValue {
+			maxValue = value.Value
+		}
+	}
+
+	labelWidth := c.width * 0.35
+	valueWidth := float32(60)
+	barSpace := c.width - labelWidth - valueWidth
+	for i, value := range c.values {
+		y := float32(22 + i*20)
+
+		label := canvas.NewText(value.Label, c.textColor)
+		label.TextSize = 11
+		label.Move(fyne.NewPos(0, y))
+		objects = append(objects, label)
+
+		width := float32(0)
+		if maxValue > 0 {
+			width = float32(value.Value/maxValue) * barSpace
+		}
+		objects = append(objects, timelineRect(c.barColor, labelWidth, y+2, width, 14))
+
+		amount := canvas.NewText(fmt.Sprintf("%.0f %s", value.Value, c.unit), c.textColor)
+		amount.TextSize = 11
+		amount.Move(fyne.NewPos(labelWidth+width+4, y))
+		objects = append(objects, amount)
+	}
+	return objects
+}
+
+// createHistoryFilters creates the search and filter controls of the history
+func (ui *PrintJobsUI) createHistoryFilters() fyne.CanvasObject {
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("Search jobs, files, printers...")
+	searchEntry.OnChanged = func(text string) {
+		ui.historyFilter.Search = text
+		ui.updateStatistics()
+	}
+
+	rangeSelect := widget.NewSelect([]string{
+		historyAllTime, historyToday, historyLast7, historyLast30, historyThisMonth, historyLastMonth,
+	}, func(choice string) {
+		ui.historyFilter.From, ui.historyFilter.To = historyDateRange(choice, time.Now())
+		ui.updateStatistics()
+	})
+	rangeSelect.SetSelected(historyAllTime)
+
+	statusSelect := widget.NewSelect([]string{historyAllChoice, "Completed", "Failed", "Cancelled"}, func(choice string) {
+		if choice == historyAllChoice {
+			ui.historyFilter.Status = ""
+		} else {
+			ui.historyFilter.Status = strings.ToLower(choice)
+		}
+		ui.updateStatistics()
+	})
+	statusSelect.SetSelected(historyAllChoice)
+
+	ui.historyStats.printerSelect = widget.NewSelect([]string{historyAllChoice}, func(choice string) {
+		ui.historyFilter.PrinterID = ui.historyStats.printerIDs[choice]
+		ui.updateStatistics()
+	})
+	ui.historyStats.printerSelect.SetSelected(historyAllChoice)
+
+	return container.NewBorder(nil, nil, nil,
+		container.NewHBox(rangeSelect, statusSelect, ui.historyStats.printerSelect),
+		searchEntry,
+	)
+}
+
+// createHistoryStats creates the statistics cards and charts of the history
+func (ui *PrintJobsUI) createHistoryStats() fyne.CanvasObject {
+	stats := &ui.historyStats
+	stats.total = widget.NewLabelWithStyle("0", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	stats.successRate = widget.NewLabelWithStyle("-", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	stats.printHours = widget.NewLabelWithStyle("0 h", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	stats.filament = widget.NewLabelWithStyle("0 g", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	stats.cost = widget.NewLabelWithStyle("0.00", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	stats.materialChart = NewBarChart("Filament by Material", "g", color.NRGBA{R: 0, G: 122, B: 255, A: 255})
+	stats.reasonChart = NewBarChart("Failure Reasons", "", color.NRGBA{R: 255, G: 69, B: 58, A: 255})
+	stats.printerCauses = widget.NewLabel("No failed or cancelled jobs")
+	stats.printerCauses.Wrapping = fyne.TextWrapWord
+
+	cards := container.NewGridWithColumns(5,
+		widget.NewCard("", "Total Prints", stats.total),
+		widget.NewCard("", "Success Rate", stats.successRate),
+		widget.NewCard("", "Print Hours", stats.printHours),
+		widget.NewCard("", "Filament", stats.filament),
+		widget.NewCard("", "Material Cost", stats.cost),
+	)
+	charts := container.NewGridWithColumns(2,
+		container.NewPadded(stats.materialChart),
+		container.NewPadded(stats.reasonChart),
+	)
+	return container.NewVBox(cards, charts,
+		widget.NewCard("", "Failure Causes by Printer", stats.printerCauses))
+}
+
+// historyDateRange returns the start time range of a date range choice
+func historyDateRange(choice string, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	switch choice {
+	case historyToday:
+		return today, time.Time{}
+	case historyLast7:
+		return today.AddDate(0, 0, -6), time.Time{}
+	case historyLast30:
+		return today.AddDate(0, 0, -29), time.Time{}
+	case historyThisMonth:
+		return month, time.Time{}
+	case historyLastMonth:
+		return month.AddDate(0, -1, 0), month
+	}
+	return time.Time{}, time.Time{}
+}
+
+// showHistoryStats shows the statistics of the listed history entries
+func (ui *PrintJobsUI) showHistoryStats(stats HistoryStats) {
+	view := &ui.historyStats
+	if view.total == nil {
+		return
+	}
+
+	view.total.SetText(fmt.Sprintf("%d", stats.Total))
+	rate := "-"
+	if stats.Completed+stats.Failed > 0 {
+		rate = fmt.Sprintf("%.0f%%", stats.SuccessRate)
+	}
+	if stats.Cancelled > 0 {
+		rate += fmt.Sprintf(" (%d cancelled)", stats.Cancelled)
+	}
+	view.successRate.SetText(rate)
+	view.printHours.SetText(fmt.Sprintf("%.1f h", stats.PrintHours))
+	if stats.FilamentGrams >= 1000 {
+		view.filament.SetText(fmt.Sprintf("%.2f kg", stats.FilamentGrams/1000))
+	} else {
+		view.filament.SetText(fmt.Sprintf("%.0f g", stats.FilamentGrams))
+	}
+
+	view.cost.SetText(fmt.Sprintf("%.2f", stats.MaterialCost))
+
+	materials := []BarValue{}
+	for material, grams := range stats.FilamentByMaterial {
+		materials = append(materials, BarValue{Label: material, Value: grams})
+	}
+	view.materialChart.SetValues(materials)
+
+	reasons := []BarValue{}
+	for reason, count := range stats.FailureReasons {
+		reasons = append(reasons, BarValue{Label: reason, Value: float64(count)})
+	}
+	view.reasonChart.SetValues(reasons)
+	view.printerCauses.SetText(failureCausesText(stats.PrinterFailures))
+
+	ui.updateHistoryPrinters()
+}
+
+// updateHistoryPrinters offers the printers found in the history as filter
+// choices
+func (ui *PrintJobsUI) updateHistoryPrinters() {
+	view := &ui.historyStats
+	if view.printerSelect == nil {
+		return
+	}
+
+	view.printerIDs = map[string]uint{}
+	options := []string{}
+	for id, name := range ui.history.Printers() {
+		if name == "" {
+			name = fmt.Sprintf("Printer %d", id)
+		}
+		if _, taken := view.printerIDs[name]; taken {
+			name = fmt.Sprintf("%s (%d)", name, id)
+		}
+		view.printerIDs[name] = id
+		options = append(options, name)
+	}
+	sort.Strings(options)
+	view.printerSelect.Options = append([]string{historyAllChoice}, options...)
+	view.printerSelect.Refresh()
+}
+
+// recordFinishedJob adds a job that just ended to the history with the
+// spool it drew from and what its filament cost; the app deducts the
+// filament itself as the print ends. The extrusion total reported by the
+// backend is used when known; otherwise the file's estimate, scaled by
+// progress for an unfinished job.
+func (ui *PrintJobsUI) recordFinishedJob(job PrintJob) {
+	entry := HistoryEntryFromJob(job)
+	entry.EndedAt = time.Now()
+	if entry.StartedAt.IsZero() {
+		entry.StartedAt = entry.EndedAt.Add(-time.Duration(job.TimeElapsed) * time.Second)
+	}
+	if entry.PrinterName == "" && ui.currentPrinter != nil {
+		entry.PrinterName = ui.currentPrinter.Name
+	}
+
+	if position, ok := ui.positions.Last(job.FileName); ok && !entry.Succeeded() {
+		entry.StopLine = position.LineNumber
+		entry.StopPosition = position.FilePosition
+	}
+
+	entry.FilamentUsed = job.FilamentUsed
+	for _, file := range ui.gcodeFiles {
+		if entry.FilamentUsed > 0 {
+			break
+		}
+		if file.FileName == job.FileName {
+			entry.FilamentUsed = file.FilamentUsed
+			if !entry.Succeeded() {
+				entry.FilamentUsed = file.FilamentUsed * float64(job.Progress) / 100
+			}
+		}
+	}
+
+	if ui.spools != nil {
+		usage := ui.spools.Usage(ui.printTool(job.FileName), entry.FilamentUsed)
+		entry.SpoolID = usage.SpoolID
+		entry.Material = usage.Material
+		entry.FilamentGrams = usage.Grams
+		entry.Cost = usage.Cost
+	}
+
+	if err := ui.history.Record(entry); err != nil {
+		dialog.ShowError(fmt.Errorf("failed to save print history: %v", err), ui.window)
+	}
+	ui.updateStatistics()
+}
+
+// reportFinishedJob asks the operator about a job that was just recorded
+func (ui *PrintJobsUI) reportFinishedJob(jobID uint) {
+	if entry, ok := ui.history.Get(jobID); ok {
+		ui.showJobReport(entry)
+	}
+}
+
+// exportHistory saves the listed history entries as CSV or JSON
+func (ui *PrintJobsUI) exportHistory(format string) {
+	entries := ui.historyEntries
+	if len(entries) == 0 {
+		dialog.ShowInformation("Export", "No print jobs to export", ui.window)
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if format == "json" {
+			err = ExportHistoryJSON(writer, entries)
+		} else {
+			err = ExportHistoryCSV(writer, entries)
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to export history: %v", err), ui.window)
+		}
+	}, ui.window)
+	saveDialog.SetFileName(fmt.Sprintf("print_history_%s.%s", time.Now().Format("2006-01-02"), format))
+	saveDialog.Show()
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"path/filepath"
 	"strings"
 	"time"
@@ -27,6 +28,7 @@ type PrintJob struct {
 	StartedAt     time.Time `json:"started_at"`
 	PrinterID     uint      `json:"printer_id"`
 	PrinterName   string    `json:"printer_name"`
+	FilamentUsed  float64   `json:"filament_used"` // Filament extruded so far in mm
 }
 
 // GCodeFile represents an uploaded G-code file
@@ -39,6 +41,13 @@ type GCodeFile struct {
 	FilamentUsed float64   `json:"filament_used"`
 	LayerCount   int       `json:"layer_count"`
 	UploadedAt   time.Time `json:"uploaded_at"`
+	SHA256       string    `json:"sha256,omitempty"` // Content hash, if the backend keeps one
+}
+
+// Printer is the printer whose jobs the interface manages
+type Printer struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
 }
 
 // PrintJobsUI handles the print job interface
@@ -50,18 +59,40 @@ type PrintJobsUI struct {
 	currentPrinter *Printer
 	
 	// UI elements
-	fileList      *widget.List
+	fileLibrary   *FileLibraryUI
 	jobList       *widget.List
 	uploadButton  *widget.Button
 	printButton   *widget.Button
 	progressBar   *widget.ProgressBar
 	statusLabel   *widget.Label
+	queueList     *widget.List
+	queueStatus   *widget.Label
+	queueButton   *widget.Button
+	scheduleButton *widget.Button
 	
 	// Data
 	gcodeFiles    []GCodeFile
 	printJobs     []PrintJob
 	selectedFile  *GCodeFile
 	currentJob    *PrintJob
+	queue         *PrintQueue
+	queueItems    []QueueItem
+	profile       *PrinterProfile
+	scheduler     *PrintScheduler
+	backend       *BackendClient
+	history       *PrintHistory
+	historyFilter HistoryFilter
+	historyEntries []HistoryEntry
+	historyStats  historyStatsView
+	spools        *SpoolInventory
+	library       *FileLibrary
+	operator      string
+	positions     PositionTracker
+	auth          *AuthManager
+	audit         *AuditLog
+	approvalList  *widget.List
+	approvalItems []QueueItem
+	approvalStatus *widget.Label
 }
 
 // NewPrintJobsUI creates a new print jobs interface
@@ -74,11 +105,38 @@ func NewPrintJobsUI(app fyne.App, window fyne.Window, backendURL, authToken stri
 		currentPrinter: printer,
 		gcodeFiles:     []GCodeFile{},
 		printJobs:      []PrintJob{},
+		queue:          NewPrintQueue(printQueueFile()),
+		history:        NewPrintHistory(printHistoryFile()),
+		library:        NewFileLibrary(fileLibraryFile()),
+		audit:          NewAuditLog(auditLogFile()),
+	}
+	
+	if err := ui.history.Load(); err != nil {
+		log.Printf("Failed to load print history: %v", err)
+	}
+	if err := ui.library.Load(); err != nil {
+		log.Printf("Failed to load file library: %v", err)
+	}
+	if err := ui.audit.Load(); err != nil {
+		log.Printf("Failed to load audit log: %v", err)
 	}
 	
 	return ui
 }
 
+// SetAuthToken replaces the token sent with API requests, e.g. after the
+// user logs in again
+func (ui *PrintJobsUI) SetAuthToken(token string) {
+	ui.authToken = token
+}
+
+// SetFileLibrary shares a file library with the rest of the app, so
+// folders and tags are not overwritten by two copies of the index. Call it
+// before CreateUI.
+func (ui *PrintJobsUI) SetFileLibrary(library *FileLibrary) {
+	ui.library = library
+}
+
 // CreateUI creates the print jobs interface
 func (ui *PrintJobsUI) CreateUI() fyne.CanvasObject {
 	// Header
@@ -103,10 +161,18 @@ func (ui *PrintJobsUI) CreateUI() fyne.CanvasObject {
 	// Job history section
 	historySection := ui.createHistorySection()
 	
+	// Print queue section
+	queueSection := ui.createQueueSection()
+	
+	// Jobs waiting for approval
+	approvalSection := ui.createApprovalSection()
+	
 	// Main content with tabs
 	tabs := container.NewAppTabs(
 		container.NewTabItemWithIcon("Files", theme.FolderIcon(), fileSection),
 		container.NewTabItemWithIcon("Active Job", theme.MediaPlayIcon(), activeJobSection),
+		container.NewTabItemWithIcon("Queue", theme.ListIcon(), queueSection),
+		container.NewTabItemWithIcon("Approvals", theme.ConfirmIcon(), approvalSection),
 		container.NewTabItemWithIcon("History", theme.DocumentIcon(), historySection),
 	)
 	
@@ -120,6 +186,7 @@ func (ui *PrintJobsUI) CreateUI() fyne.CanvasObject {
 	// Load initial data
 	ui.loadGCodeFiles()
 	ui.loadPrintJobs()
+	ui.loadQueue()
 	
 	// Start status updates
 	go ui.startStatusUpdates()
@@ -140,75 +207,71 @@ func (ui *PrintJobsUI) createFileSection() fyne.CanvasObject {
 	})
 	ui.uploadButton.Importance = widget.HighImportance
 	
-	// File list
-	ui.fileList = widget.NewList(
-		func() int { return len(ui.gcodeFiles) },
-		func() fyne.CanvasObject {
-			return container.NewBorder(
-				nil, nil, nil,
-				widget.NewButtonWithIcon("", theme.DeleteIcon(), nil),
-				container.NewVBox(
-					widget.NewLabel("File name"),
-					widget.NewLabel("Details"),
-				),
-			)
-		},
-		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id >= len(ui.gcodeFiles) {
-				return
+	// Thumbnail grid with folders, tags, search and sorting. This API has
+	// no folders, so they are kept locally.
+	ui.fileLibrary = NewFileLibraryUI(ui.window, ui.library, nil)
+	ui.fileLibrary.OnSelected = func(fileName string) {
+		ui.selectedFile = nil
+		for i := range ui.gcodeFiles {
+			if ui.gcodeFiles[i].FileName == fileName {
+				ui.selectedFile = &ui.gcodeFiles[i]
 			}
-			
-			file := ui.gcodeFiles[id]
-			content := obj.(*fyne.Container).Objects[0].(*fyne.Container)
-			labels := content.Objects[0].(*fyne.Container)
-			
-			// Update labels
-			labels.Objects[0].(*widget.Label).SetText(file.Name)
-			labels.Objects[1].(*widget.Label).SetText(fmt.Sprintf(
-				"%.1f MB | %s | %d layers",
-				float64(file.FileSize)/(1024*1024),
-				ui.formatDuration(file.PrintTime),
-				file.LayerCount,
-			))
-			
-			// Update delete button
-			deleteBtn := obj.(*fyne.Container).Objects[1].(*widget.Button)
-			deleteBtn.OnTapped = func() {
-				ui.deleteFile(&file)
-			}
-		},
-	)
-	
-	ui.fileList.OnSelected = func(id widget.ListItemID) {
-		if id < len(ui.gcodeFiles) {
-			ui.selectedFile = &ui.gcodeFiles[id]
-			ui.updatePrintButton()
 		}
+		ui.updatePrintButton()
 	}
+	libraryView := ui.fileLibrary.CreateUI()
 	
 	// Print button
 	ui.printButton = widget.NewButtonWithIcon("Start Print", theme.MediaPlayIcon(), func() {
-		if ui.selectedFile != nil {
+		if ui.selectedFile == nil {
+			return
+		}
+		if ui.canApprove() {
 			ui.startPrint(ui.selectedFile)
+		} else {
+			ui.showAddToQueueDialog(ui.selectedFile)
 		}
 	})
 	ui.printButton.Importance = widget.HighImportance
 	ui.printButton.Disable()
 	
-	// File info panel
-	fileInfo := widget.NewCard("Selected File", "", widget.NewLabel("No file selected"))
+	// Queue button
+	ui.queueButton = widget.NewButtonWithIcon("Add to Queue", theme.ContentAddIcon(), func() {
+		if ui.selectedFile != nil {
+			ui.showAddToQueueDialog(ui.selectedFile)
+		}
+	})
+	ui.queueButton.Disable()
+	
+	// Schedule button
+	ui.scheduleButton = widget.NewButtonWithIcon("Schedule", theme.HistoryIcon(), func() {
+		if ui.selectedFile != nil {
+			ui.showScheduleFileDialog(ui.selectedFile)
+		}
+	})
+	ui.scheduleButton.Disable()
+	
+	// Delete button
+	deleteButton := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
+		if ui.selectedFile != nil {
+			ui.deleteFile(ui.selectedFile)
+		}
+	})
 	
 	// Layout
-	topButtons := container.NewGridWithColumns(2,
+	topButtons := container.NewGridWithColumns(5,
 		ui.uploadButton,
 		ui.printButton,
+		ui.queueButton,
+		ui.scheduleButton,
+		deleteButton,
 	)
 	
 	return container.NewBorder(
 		topButtons,
-		fileInfo,
+		nil,
 		nil, nil,
-		ui.fileList,
+		libraryView,
 	)
 }
 
@@ -277,7 +340,7 @@ func (ui *PrintJobsUI) createActiveJobSection() fyne.CanvasObject {
 func (ui *PrintJobsUI) createHistorySection() fyne.CanvasObject {
 	// History list
 	ui.jobList = widget.NewList(
-		func() int { return len(ui.printJobs) },
+		func() int { return len(ui.historyEntries) },
 		func() fyne.CanvasObject {
 			return container.NewHBox(
 				widget.NewIcon(theme.DocumentIcon()),
@@ -290,16 +353,16 @@ func (ui *PrintJobsUI) createHistorySection() fyne.CanvasObject {
 			)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id >= len(ui.printJobs) {
+			if id >= len(ui.historyEntries) {
 				return
 			}
 			
-			job := ui.printJobs[id]
+			entry := ui.historyEntries[id]
 			hbox := obj.(*fyne.Container)
 			
 			// Update icon based on status
 			icon := hbox.Objects[0].(*widget.Icon)
-			switch job.Status {
+			switch entry.Status {
 			case "completed":
 				icon.SetResource(theme.ConfirmIcon())
 			case "failed":
@@ -312,29 +375,48 @@ func (ui *PrintJobsUI) createHistorySection() fyne.CanvasObject {
 			
 			// Update labels
 			info := hbox.Objects[1].(*fyne.Container)
-			info.Objects[0].(*widget.Label).SetText(job.Name)
-			info.Objects[1].(*widget.Label).SetText(fmt.Sprintf(
-				"Started: %s | Duration: %s",
-				job.StartedAt.Format("Jan 2 15:04"),
-				ui.formatDuration(job.TimeElapsed),
-			))
+			info.Objects[0].(*widget.Label).SetText(entry.Name)
+			details := fmt.Sprintf(
+				"Started: %s | Duration: %s | %s | %.0f g %s",
+				entry.StartedAt.Format("Jan 2 15:04"),
+				ui.formatDuration(entry.Duration),
+				entry.PrinterName,
+				entry.Grams(),
+				entry.MaterialName(),
+			)
+			if entry.Cost > 0 {
+				details += fmt.Sprintf(" | Cost: %.2f", entry.Cost)
+			}
+			if entry.FailureReason != "" {
+				details += " | " + entry.FailureReason
+			}
+			if entry.SignOff != nil {
+				details += fmt.Sprintf(" | %s (%s)", entry.SignOff.Quality, entry.SignOff.Operator)
+			}
+			info.Objects[1].(*widget.Label).SetText(details)
 			
 			// Update status
 			statusLabel := hbox.Objects[3].(*widget.Label)
-			statusLabel.SetText(strings.Title(job.Status))
+			statusLabel.SetText(strings.Title(entry.Status))
 		},
 	)
+	ui.jobList.OnSelected = func(id widget.ListItemID) {
+		ui.jobList.Unselect(id)
+		if id < len(ui.historyEntries) {
+			ui.showJobReport(ui.historyEntries[id])
+		}
+	}
 	
-	// Stats card
-	statsCard := widget.NewCard("Statistics", "",
-		container.NewVBox(
-			widget.NewLabel("Total prints: 0"),
-			widget.NewLabel("Success rate: --%"),
-			widget.NewLabel("Total print time: --:--:--"),
-		),
-	)
+	// Stats cards and charts
+	statsCards := ui.createHistoryStats()
 	
-	// Clear history button
+	// Export and clear buttons
+	exportCSVBtn := widget.NewButtonWithIcon("Export CSV", theme.DocumentSaveIcon(), func() {
+		ui.exportHistory("csv")
+	})
+	exportJSONBtn := widget.NewButtonWithIcon("Export JSON", theme.DocumentSaveIcon(), func() {
+		ui.exportHistory("json")
+	})
 	clearBtn := widget.NewButtonWithIcon("Clear History", theme.DeleteIcon(), func() {
 		dialog.ShowConfirm("Clear History", 
 			"Are you sure you want to clear the print history?",
@@ -349,8 +431,8 @@ func (ui *PrintJobsUI) createHistorySection() fyne.CanvasObject {
 	
 	// Layout
 	return container.NewBorder(
-		statsCard,
-		container.NewPadded(clearBtn),
+		container.NewVBox(ui.createHistoryFilters(), statsCards),
+		container.NewPadded(container.NewGridWithColumns(3, exportCSVBtn, exportJSONBtn, clearBtn)),
 		nil, nil,
 		ui.jobList,
 	)
@@ -362,37 +444,120 @@ func (ui *PrintJobsUI) showUploadDialog() {
 		if err != nil || reader == nil {
 			return
 		}
-		defer reader.Close()
 		
 		// Check file extension
-		if !strings.HasSuffix(strings.ToLower(reader.URI().Name()), ".gcode") &&
-		   !strings.HasSuffix(strings.ToLower(reader.URI().Name()), ".gco") {
-			dialog.ShowError(fmt.Errorf("Please select a G-code file (.gcode or .gco)"), ui.window)
+		if !IsGCodeFileName(reader.URI().Name()) {
+			reader.Close()
+			dialog.ShowError(fmt.Errorf("Please select a G-code file (.gcode, .gco, .bgcode or .gcode.gz)"), ui.window)
 			return
 		}
 		
-		// Show upload progress
-		progress := dialog.NewProgressInfinite("Uploading file...", "Please wait")
+		// Hash the contents to find copies already on the printer
+		progress := dialog.NewProgressInfinite("Uploading file...", "Checking for duplicates...", ui.window)
 		progress.Show()
 		
-		// Upload file
 		go func() {
-			err := ui.uploadGCodeFile(reader)
+			uri := reader.URI()
+			digest, err := DigestGCode(reader)
+			reader.Close()
 			progress.Hide()
 			
 			if err != nil {
-				dialog.ShowError(err, ui.window)
-			} else {
-				ui.statusLabel.SetText("File uploaded successfully")
-				ui.loadGCodeFiles()
+				dialog.ShowError(fmt.Errorf("failed to read %s: %v", uri.Name(), err), ui.window)
+				return
+			}
+			
+			// Binary and compressed G-code are stored as plain text
+			name := uri.Name()
+			if digest.Format != GCodeFormatText {
+				name = PlainGCodeName(name)
 			}
+			
+			duplicates := DuplicateFiles(ui.gcodeFiles, digest.SHA256, ui.library)
+			if len(duplicates) > 0 {
+				ui.confirmDuplicateUpload(uri, name, digest, duplicates)
+				return
+			}
+			ui.uploadFromURI(uri, name, digest)
 		}()
 		
 	}, ui.window)
 }
 
-// uploadGCodeFile uploads a G-code file to the backend
-func (ui *PrintJobsUI) uploadGCodeFile(reader fyne.URIReadCloser) error {
+// confirmDuplicateUpload offers to reuse a file already on the printer
+// with the same contents, or to keep the upload as a new version
+func (ui *PrintJobsUI) confirmDuplicateUpload(uri fyne.URI, name string, digest GCodeDigest, duplicates []GCodeFile) {
+	names := make([]string, len(duplicates))
+	for i, file := range duplicates {
+		names[i] = file.FileName
+	}
+	version := VersionedFileName(name, ui.fileExists)
+	
+	message := widget.NewLabel(fmt.Sprintf(
+		"%s has the same contents as %s, which is already on the printer.\n\n"+
+			"Use the existing file, or upload a new version as %s?",
+		uri.Name(), strings.Join(names, ", "), version))
+	message.Wrapping = fyne.TextWrapWord
+	
+	var duplicateDialog dialog.Dialog
+	reuseBtn := widget.NewButton("Use Existing", func() {
+		duplicateDialog.Hide()
+		ui.fileLibrary.Select(duplicates[0].FileName)
+		ui.statusLabel.SetText(fmt.Sprintf("Using %s, already on the printer", duplicates[0].FileName))
+	})
+	reuseBtn.Importance = widget.HighImportance
+	versionBtn := widget.NewButton("Upload New Version", func() {
+		duplicateDialog.Hide()
+		ui.uploadFromURI(uri, version, digest)
+	})
+	
+	duplicateDialog = dialog.NewCustom("Duplicate File", "Cancel", container.NewVBox(
+		message,
+		container.NewGridWithColumns(2, reuseBtn, versionBtn),
+	), ui.window)
+	duplicateDialog.Resize(fyne.NewSize(480, 240))
+	duplicateDialog.Show()
+}
+
+// fileExists reports whether a file of that name is on the printer
+func (ui *PrintJobsUI) fileExists(name string) bool {
+	for _, file := range ui.gcodeFiles {
+		if file.FileName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadFromURI uploads a file under the given name and records the hash
+// taken when it was checked for duplicates, so later copies are recognised
+func (ui *PrintJobsUI) uploadFromURI(uri fyne.URI, name string, digest GCodeDigest) {
+	progress := dialog.NewProgressInfinite("Uploading file...", "Uploading "+name+"...", ui.window)
+	progress.Show()
+	
+	go func() {
+		reader, err := storage.Reader(uri)
+		if err == nil {
+			err = ui.uploadGCodeFile(reader, name, digest)
+			reader.Close()
+		}
+		progress.Hide()
+		
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		if err := ui.library.SetHash(name, digest.SHA256); err != nil {
+			log.Printf("Failed to record file hash: %v", err)
+		}
+		ui.statusLabel.SetText(fmt.Sprintf("%s uploaded successfully", name))
+		ui.loadGCodeFiles()
+	}()
+}
+
+// uploadGCodeFile uploads a G-code file to the backend under filename. The
+// digest is the one taken before uploading, so the file is not hashed again.
+func (ui *PrintJobsUI) uploadGCodeFile(reader fyne.URIReadCloser, filename string, digest GCodeDigest) error {
 	// TODO: Implement actual file upload to backend
 	// For now, simulate upload
 	time.Sleep(2 * time.Second)
@@ -400,18 +565,44 @@ func (ui *PrintJobsUI) uploadGCodeFile(reader fyne.URIReadCloser) error {
 	// Add to list (temporary simulation)
 	ui.gcodeFiles = append(ui.gcodeFiles, GCodeFile{
 		ID:           uint(len(ui.gcodeFiles) + 1),
-		Name:         filepath.Base(reader.URI().Name()),
-		FileName:     reader.URI().Name(),
-		FileSize:     1024 * 1024 * 5, // 5MB dummy
+		Name:         filepath.Base(filename),
+		FileName:     filename,
+		FileSize:     digest.Size,
 		PrintTime:    7200,             // 2 hours dummy
 		FilamentUsed: 12.5,
 		LayerCount:   150,
 		UploadedAt:   time.Now(),
+		SHA256:       digest.SHA256,
 	})
 	
 	return nil
 }
 
+// showGCodeFiles passes the loaded files to the library, which adds
+// folders and tags; files are last printed when their history says so
+func (ui *PrintJobsUI) showGCodeFiles() {
+	lastPrinted := map[string]time.Time{}
+	for _, entry := range ui.history.Entries(HistoryFilter{}) {
+		if entry.StartedAt.After(lastPrinted[entry.FileName]) {
+			lastPrinted[entry.FileName] = entry.StartedAt
+		}
+	}
+	
+	files := make([]LibraryFile, len(ui.gcodeFiles))
+	for i, file := range ui.gcodeFiles {
+		files[i] = LibraryFile{
+			FileName:     file.FileName,
+			Size:         file.FileSize,
+			UploadedAt:   file.UploadedAt,
+			PrintTime:    float64(file.PrintTime),
+			FilamentUsed: file.FilamentUsed,
+			LastPrinted:  lastPrinted[file.FileName],
+			SHA256:       file.SHA256,
+		}
+	}
+	ui.fileLibrary.SetFiles(files)
+}
+
 // Other helper methods...
 func (ui *PrintJobsUI) formatDuration(seconds int) string {
 	hours := seconds / 3600
@@ -425,16 +616,35 @@ func (ui *PrintJobsUI) formatDuration(seconds int) string {
 }
 
 func (ui *PrintJobsUI) updatePrintButton() {
-	if ui.selectedFile != nil && ui.currentJob == nil {
+	// Students and guests submit prints for approval instead of starting them
+	if ui.canApprove() {
+		ui.printButton.SetText("Start Print")
+		ui.queueButton.SetText("Add to Queue")
+	} else {
+		ui.printButton.SetText("Submit for Approval")
+		ui.queueButton.SetText("Submit to Queue")
+	}
+	
+	if ui.selectedFile != nil && (ui.currentJob == nil || !ui.canApprove()) {
 		ui.printButton.Enable()
 	} else {
 		ui.printButton.Disable()
 	}
+	if ui.selectedFile != nil {
+		ui.queueButton.Enable()
+	} else {
+		ui.queueButton.Disable()
+	}
+	if ui.selectedFile != nil && ui.canApprove() {
+		ui.scheduleButton.Enable()
+	} else {
+		ui.scheduleButton.Disable()
+	}
 }
 
 func (ui *PrintJobsUI) loadGCodeFiles() {
 	// TODO: Load from backend API
-	ui.fileList.Refresh()
+	ui.showGCodeFiles()
 }
 
 func (ui *PrintJobsUI) loadPrintJobs() {
@@ -481,7 +691,10 @@ func (ui *PrintJobsUI) deleteFile(file *GCodeFile) {
 }
 
 func (ui *PrintJobsUI) clearHistory() {
-	// TODO: Clear history via API
+	if err := ui.history.Clear(); err != nil {
+		dialog.ShowError(fmt.Errorf("failed to clear history: %v", err), ui.window)
+	}
+	ui.updateStatistics()
 }
 
 func (ui *PrintJobsUI) startStatusUpdates() {
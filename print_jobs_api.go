@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"time"
@@ -13,20 +14,33 @@ import (
 	"fyne.io/fyne/v2/dialog"
 )
 
-// uploadGCodeFile uploads a G-code file to the backend
-func (ui *PrintJobsUI) uploadGCodeFile(reader fyne.URIReadCloser) error {
+// uploadGCodeFile uploads a G-code file to the backend under filename. The
+// digest is the one taken before uploading, so the file is not hashed again.
+func (ui *PrintJobsUI) uploadGCodeFile(reader fyne.URIReadCloser, filename string, digest GCodeDigest) error {
 	// Create multipart form
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 	
+	// Binary and compressed G-code are uploaded as plain text
+	source, err := NewGCodeReader(reader)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	
 	// Add file field
-	part, err := writer.CreateFormFile("file", reader.URI().Name())
+	part, err := writer.CreateFormFile("file", filename)
 	if err != nil {
 		return err
 	}
 	
 	// Copy file content
-	if _, err := io.Copy(part, reader); err != nil {
+	if _, err := io.Copy(part, source); err != nil {
+		return err
+	}
+	
+	// Backends keeping content hashes can check it against their own
+	if err := writer.WriteField("sha256", digest.SHA256); err != nil {
 		return err
 	}
 	
@@ -82,7 +96,7 @@ func (ui *PrintJobsUI) loadGCodeFiles() {
 			var files []GCodeFile
 			if err := json.NewDecoder(resp.Body).Decode(&files); err == nil {
 				ui.gcodeFiles = files
-				ui.fileList.Refresh()
+				ui.showGCodeFiles()
 			}
 		}
 	}()
@@ -110,7 +124,9 @@ func (ui *PrintJobsUI) loadPrintJobs() {
 			var jobs []PrintJob
 			if err := json.NewDecoder(resp.Body).Decode(&jobs); err == nil {
 				ui.printJobs = jobs
-				ui.jobList.Refresh()
+				if err := ui.history.Merge(jobs); err != nil {
+					log.Printf("Failed to save print history: %v", err)
+				}
 				ui.updateStatistics()
 			}
 		}
@@ -119,55 +135,33 @@ func (ui *PrintJobsUI) loadPrintJobs() {
 
 // startPrint starts a print job
 func (ui *PrintJobsUI) startPrint(file *GCodeFile) {
-	// Create print job request
-	reqBody := struct {
-		PrinterID uint `json:"printer_id"`
-		FileID    uint `json:"file_id"`
-	}{
-		PrinterID: ui.currentPrinter.ID,
-		FileID:    file.ID,
-	}
-	
-	jsonData, err := json.Marshal(reqBody)
+	go func() {
+		tool := ui.printTool(file.FileName)
+		ui.confirmFilament(tool, file.FilamentUsed, func() {
+			go func() {
+				if _, err := ui.launchPrint(file.ID, file.Name); err != nil {
+					ui.statusLabel.SetText("Failed to start print")
+					dialog.ShowError(err, ui.window)
+				}
+			}()
+		})
+	}()
+}
+
+// launchPrint starts a file on the current printer and monitors the job
+func (ui *PrintJobsUI) launchPrint(fileID uint, name string) (*PrintJob, error) {
+	job, err := ui.createPrintJob(fileID)
 	if err != nil {
-		dialog.ShowError(err, ui.window)
-		return
+		return nil, err
 	}
 	
-	// Send request
-	go func() {
-		url := fmt.Sprintf("%s/api/v1/print-jobs", ui.backendURL)
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			dialog.ShowError(err, ui.window)
-			return
-		}
-		
-		req.Header.Set("Authorization", "Bearer "+ui.authToken)
-		req.Header.Set("Content-Type", "application/json")
-		
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			dialog.ShowError(err, ui.window)
-			return
-		}
-		defer resp.Body.Close()
-		
-		if resp.StatusCode == http.StatusOK {
-			var job PrintJob
-			if err := json.NewDecoder(resp.Body).Decode(&job); err == nil {
-				ui.currentJob = &job
-				ui.statusLabel.SetText(fmt.Sprintf("Print started: %s", file.Name))
-				ui.updateActiveJobUI()
-				
-				// Start monitoring job status
-				go ui.monitorPrintJob(&job)
-			}
-		} else {
-			ui.statusLabel.SetText("Failed to start print")
-		}
-	}()
+	ui.currentJob = job
+	ui.statusLabel.SetText(fmt.Sprintf("Print started: %s", name))
+	ui.updateActiveJobUI()
+	
+	// Start monitoring job status
+	go ui.monitorPrintJob(job)
+	return job, nil
 }
 
 // pauseJob pauses an active print job
@@ -244,7 +238,12 @@ func (ui *PrintJobsUI) cancelJob(job *PrintJob) {
 			ui.statusLabel.SetText("Print cancelled")
 			ui.currentJob = nil
 			ui.updateActiveJobUI()
+			cancelled := *job
+			cancelled.Status = "cancelled"
+			ui.recordFinishedJob(cancelled)
+			ui.queueJobEnded(job.ID, false)
 			ui.loadPrintJobs()
+			ui.reportFinishedJob(job.ID)
 		}
 	}()
 }
@@ -309,7 +308,10 @@ func (ui *PrintJobsUI) monitorPrintJob(job *PrintJob) {
 				// Stop monitoring if job is completed or cancelled
 				if updatedJob.Status == "completed" || updatedJob.Status == "cancelled" || updatedJob.Status == "failed" {
 					ui.currentJob = nil
+					ui.recordFinishedJob(updatedJob)
+					ui.queueJobEnded(updatedJob.ID, updatedJob.Status == "completed")
 					ui.loadPrintJobs()
+					ui.reportFinishedJob(updatedJob.ID)
 					return
 				}
 			}
@@ -333,22 +335,11 @@ func (ui *PrintJobsUI) updateActiveJobUI() {
 
 // updateStatistics updates the print statistics
 func (ui *PrintJobsUI) updateStatistics() {
-	totalPrints := len(ui.printJobs)
-	successCount := 0
-	totalTime := 0
-	
-	for _, job := range ui.printJobs {
-		if job.Status == "completed" {
-			successCount++
-		}
-		totalTime += job.TimeElapsed
-	}
-	
-	successRate := 0.0
-	if totalPrints > 0 {
-		successRate = float64(successCount) / float64(totalPrints) * 100
+	ui.historyEntries = ui.history.Entries(ui.historyFilter)
+	if ui.jobList != nil {
+		ui.jobList.Refresh()
 	}
 	
 	// Update stats display
-	// This would update the statistics card
-} 
\ No newline at end of file
+	ui.showHistoryStats(ComputeHistoryStats(ui.historyEntries))
+}
\ No newline at end of file
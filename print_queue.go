@@ -0,0 +1,640 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueueItemState is the lifecycle state of a queued job
+type QueueItemState string
+
+const (
+	QueueItemQueued   QueueItemState = "queued"           // Waiting for a printer
+	QueueItemStarting QueueItemState = "starting"         // Reserved while its backend job is created
+	QueueItemPrinting QueueItemState = "printing"         // A copy is on the printer
+	QueueItemDone     QueueItemState = "done"             // All copies printed
+	QueueItemFailed   QueueItemState = "failed"           // Last copy failed or was cancelled; held until retried
+	QueueItemPending  QueueItemState = "pending_approval" // Submitted by a student or guest; waiting for staff
+	QueueItemRejected QueueItemState = "rejected"         // Turned down by staff; never printed
+)
+
+// QueueState is what the queue is waiting for
+type QueueState string
+
+const (
+	QueueIdle     QueueState = "idle"      // Ready to start the next job
+	QueueStarting QueueState = "starting"  // The next job is being created
+	QueuePrinting QueueState = "printing"  // A queued job is printing
+	QueueBedClear QueueState = "bed_clear" // A print ended; waiting for the bed to be cleared
+)
+
+// QueueItem is a file waiting to be printed one or more times
+type QueueItem struct {
+	ID           string         `json:"id"`
+	FileID       uint           `json:"file_id"`
+	Name         string         `json:"name"`
+	FileName     string         `json:"file_name"`
+	Copies       int            `json:"copies"`
+	CopiesDone   int            `json:"copies_done"`
+	PrinterID    uint           `json:"printer_id,omitempty"`   // Only this printer may print it; 0 for any
+	Capabilities []string       `json:"capabilities,omitempty"` // Required when PrinterID is 0
+	State        QueueItemState `json:"state"`
+	JobID        uint           `json:"job_id,omitempty"` // Backend job of the copy being printed
+	AddedAt      time.Time      `json:"added_at"`
+	SubmittedBy  string         `json:"submitted_by,omitempty"` // User who asked for approval
+	ReviewedBy   string         `json:"reviewed_by,omitempty"`  // Staff member who approved or rejected it
+	ReviewedAt   time.Time      `json:"reviewed_at,omitempty"`
+	ReviewNote   string         `json:"review_note,omitempty"` // Reason given for a rejection
+}
+
+// QueuePrinter is the printer the queue dispatches to
+type QueuePrinter struct {
+	ID           uint
+	Capabilities []string
+}
+
+// AssignableTo reports whether the item may be printed on a printer: the
+// assigned printer if it has one, otherwise any printer with every required
+// capability
+func (item *QueueItem) AssignableTo(printer QueuePrinter) bool {
+	if item.PrinterID != 0 {
+		return item.PrinterID == printer.ID
+	}
+	for _, required := range item.Capabilities {
+		found := false
+		for _, capability := range printer.Capabilities {
+			if strings.EqualFold(capability, required) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Assignment describes which printers may print the item
+func (item *QueueItem) Assignment() string {
+	switch {
+	case item.PrinterID != 0:
+		return fmt.Sprintf("Printer #%d", item.PrinterID)
+	case len(item.Capabilities) > 0:
+		return "Needs " + strings.Join(item.Capabilities, ", ")
+	default:
+		return "Any printer"
+	}
+}
+
+// queueItemStateLabels are the display names of item states
+var queueItemStateLabels = map[QueueItemState]string{
+	QueueItemQueued:   "Queued",
+	QueueItemStarting: "Starting",
+	QueueItemPrinting: "Printing",
+	QueueItemDone:     "Done",
+	QueueItemFailed:   "Failed",
+	QueueItemPending:  "Awaiting approval",
+	QueueItemRejected: "Rejected",
+}
+
+// StateLabel describes the item's state for display
+func (item *QueueItem) StateLabel() string {
+	if item.State == QueueItemRejected && item.ReviewedBy != "" {
+		return "Rejected by " + item.ReviewedBy
+	}
+	if label, ok := queueItemStateLabels[item.State]; ok {
+		return label
+	}
+	return string(item.State)
+}
+
+// printQueueData is the stored and synced form of the queue
+type printQueueData struct {
+	Items     []QueueItem `json:"items"`
+	Paused    bool        `json:"paused"`
+	State     QueueState  `json:"state"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// PrintQueue is the ordered list of jobs waiting for the printer. Every
+// change is written to disk so the queue survives a restart.
+type PrintQueue struct {
+	mu        sync.Mutex
+	items     []*QueueItem
+	paused    bool
+	state     QueueState
+	updatedAt time.Time
+	nextID    int
+
+	saveMu   sync.Mutex // Orders writes of the queue file
+	savedAt  time.Time  // UpdatedAt of the last copy written
+	path     string
+	onChange func()
+}
+
+// NewPrintQueue creates an empty queue stored at path; an empty path keeps
+// the queue in memory only
+func NewPrintQueue(path string) *PrintQueue {
+	return &PrintQueue{state: QueueIdle, path: path}
+}
+
+// printQueueFile is where the touchscreen keeps its queue
+func printQueueFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "print_queue.json")
+}
+
+// SetOnChange sets a callback run after every change to the queue
+func (q *PrintQueue) SetOnChange(callback func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onChange = callback
+}
+
+// Load reads the stored queue. A missing file leaves the queue empty. An
+// item that was being started when the touchscreen stopped goes back to
+// waiting, as its job may never have been created.
+func (q *PrintQueue) Load() error {
+	data, err := ioutil.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored printQueueData
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid queue file: %v", err)
+	}
+
+	q.mu.Lock()
+	q.restore(stored)
+	for _, item := range q.items {
+		if item.State == QueueItemStarting {
+			item.State = QueueItemQueued
+		}
+	}
+	if q.state == QueueStarting {
+		q.state = QueueIdle
+	}
+	q.mu.Unlock()
+	return nil
+}
+
+// Snapshot returns a copy of the queue for saving, syncing or display
+func (q *PrintQueue) Snapshot() printQueueData {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.snapshot()
+}
+
+// Replace takes over a queue received from the backend if it is newer than
+// the local one, reporting whether it did
+func (q *PrintQueue) Replace(remote printQueueData) bool {
+	q.mu.Lock()
+	if !remote.UpdatedAt.After(q.updatedAt) {
+		q.mu.Unlock()
+		return false
+	}
+	q.restore(remote)
+	data := q.snapshot()
+	q.mu.Unlock()
+
+	q.save(data)
+	q.notify()
+	return true
+}
+
+// Items returns copies of the queued items in order
+func (q *PrintQueue) Items() []QueueItem {
+	return q.Snapshot().Items
+}
+
+// Paused reports whether the queue is held
+func (q *PrintQueue) Paused() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.paused
+}
+
+// State returns what the queue is waiting for
+func (q *PrintQueue) State() QueueState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.state
+}
+
+// Printing returns the item being printed, if any
+func (q *PrintQueue) Printing() (QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, item := range q.items {
+		if item.State == QueueItemPrinting {
+			return *item, true
+		}
+	}
+	return QueueItem{}, false
+}
+
+// Add appends a file to the end of the queue
+func (q *PrintQueue) Add(file GCodeFile, copies int, printerID uint, capabilities []string) (QueueItem, error) {
+	return q.add(file, copies, printerID, capabilities, QueueItemQueued, "")
+}
+
+// Submit appends a file that waits for approval before it may print
+func (q *PrintQueue) Submit(file GCodeFile, copies int, printerID uint, capabilities []string, submittedBy string) (QueueItem, error) {
+	return q.add(file, copies, printerID, capabilities, QueueItemPending, submittedBy)
+}
+
+// Pending returns the items waiting for approval, oldest first
+func (q *PrintQueue) Pending() []QueueItem {
+	pending := []QueueItem{}
+	for _, item := range q.Items() {
+		if item.State == QueueItemPending {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
+
+// Approve lets a pending item print
+func (q *PrintQueue) Approve(id, reviewer string) error {
+	return q.review(id, reviewer, QueueItemQueued, "")
+}
+
+// Reject turns down a pending item. It stays in the queue, so the
+// submitter can see why, until done items are cleared.
+func (q *PrintQueue) Reject(id, reviewer, reason string) error {
+	return q.review(id, reviewer, QueueItemRejected, reason)
+}
+
+// review records the decision on a pending item
+func (q *PrintQueue) review(id, reviewer string, state QueueItemState, note string) error {
+	return q.update(func() error {
+		_, item := q.find(id)
+		if item == nil {
+			return fmt.Errorf("queue item not found")
+		}
+		if item.State != QueueItemPending {
+			return fmt.Errorf("%s is not waiting for approval", item.Name)
+		}
+		item.State = state
+		item.ReviewedBy = reviewer
+		item.ReviewedAt = time.Now()
+		item.ReviewNote = note
+		return nil
+	})
+}
+
+// add appends a file in the given state
+func (q *PrintQueue) add(file GCodeFile, copies int, printerID uint, capabilities []string, state QueueItemState, submittedBy string) (QueueItem, error) {
+	if copies < 1 {
+		return QueueItem{}, fmt.Errorf("copies must be at least 1")
+	}
+
+	var added QueueItem
+	err := q.update(func() error {
+		q.nextID++
+		item := &QueueItem{
+			ID:           fmt.Sprintf("%d-%d", time.Now().UnixNano(), q.nextID),
+			FileID:       file.ID,
+			Name:         file.Name,
+			FileName:     file.FileName,
+			Copies:       copies,
+			PrinterID:    printerID,
+			Capabilities: capabilities,
+			State:        state,
+			AddedAt:      time.Now(),
+			SubmittedBy:  submittedBy,
+		}
+		q.items = append(q.items, item)
+		added = *item
+		return nil
+	})
+	return added, err
+}
+
+// Remove takes an item off the queue. A printing item has to finish or be
+// cancelled first.
+func (q *PrintQueue) Remove(id string) error {
+	return q.update(func() error {
+		index, item := q.find(id)
+		if item == nil {
+			return fmt.Errorf("queue item not found")
+		}
+		if item.State == QueueItemPrinting || item.State == QueueItemStarting {
+			return fmt.Errorf("%s is printing", item.Name)
+		}
+		q.items = append(q.items[:index], q.items[index+1:]...)
+		return nil
+	})
+}
+
+// Move places an item at a new position in the queue
+func (q *PrintQueue) Move(id string, position int) error {
+	return q.update(func() error {
+		index, item := q.find(id)
+		if item == nil {
+			return fmt.Errorf("queue item not found")
+		}
+		if position < 0 || position >= len(q.items) {
+			return fmt.Errorf("position %d is outside the queue", position+1)
+		}
+		q.items = append(q.items[:index], q.items[index+1:]...)
+		q.items = append(q.items[:position], append([]*QueueItem{item}, q.items[position:]...)...)
+		return nil
+	})
+}
+
+// SetCopies changes how many copies of an item are printed. It cannot drop
+// below the copies already printed or being printed.
+func (q *PrintQueue) SetCopies(id string, copies int) error {
+	return q.update(func() error {
+		_, item := q.find(id)
+		if item == nil {
+			return fmt.Errorf("queue item not found")
+		}
+		minimum := item.CopiesDone
+		if item.State == QueueItemPrinting || item.State == QueueItemStarting {
+			minimum++
+		}
+		if minimum < 1 {
+			minimum = 1
+		}
+		if copies < minimum {
+			return fmt.Errorf("%s needs at least %d copies", item.Name, minimum)
+		}
+
+		item.Copies = copies
+		switch {
+		case item.State == QueueItemQueued && item.CopiesDone >= copies:
+			item.State = QueueItemDone
+		case item.State == QueueItemDone && item.CopiesDone < copies:
+			item.State = QueueItemQueued
+		}
+		return nil
+	})
+}
+
+// SetAssignment changes which printers may print an item
+func (q *PrintQueue) SetAssignment(id string, printerID uint, capabilities []string) error {
+	return q.update(func() error {
+		_, item := q.find(id)
+		if item == nil {
+			return fmt.Errorf("queue item not found")
+		}
+		item.PrinterID = printerID
+		item.Capabilities = capabilities
+		return nil
+	})
+}
+
+// Retry puts a failed item back in the queue
+func (q *PrintQueue) Retry(id string) error {
+	return q.update(func() error {
+		_, item := q.find(id)
+		if item == nil {
+			return fmt.Errorf("queue item not found")
+		}
+		if item.State != QueueItemFailed {
+			return fmt.Errorf("%s has not failed", item.Name)
+		}
+		item.State = QueueItemQueued
+		return nil
+	})
+}
+
+// ClearDone removes every finished or rejected item
+func (q *PrintQueue) ClearDone() error {
+	return q.update(func() error {
+		kept := q.items[:0]
+		for _, item := range q.items {
+			if item.State != QueueItemDone && item.State != QueueItemRejected {
+				kept = append(kept, item)
+			}
+		}
+		q.items = kept
+		return nil
+	})
+}
+
+// Pause stops the queue from starting further jobs. A job already printing
+// carries on.
+func (q *PrintQueue) Pause() error {
+	return q.update(func() error {
+		q.paused = true
+		return nil
+	})
+}
+
+// Resume lets the queue start jobs again
+func (q *PrintQueue) Resume() error {
+	return q.update(func() error {
+		q.paused = false
+		return nil
+	})
+}
+
+// Reserve claims the first item the printer may start, so no other caller
+// can start it while its backend job is created. There is none while the
+// queue is paused, printing, or waiting for the bed to be cleared. The caller follows up with
+// Start once the job exists, or Release if creating it failed.
+func (q *PrintQueue) Reserve(printer QueuePrinter) (QueueItem, bool) {
+	var reserved QueueItem
+	found := false
+	q.update(func() error {
+		if q.paused || q.state != QueueIdle {
+			return fmt.Errorf("the queue is not ready to start a job (%s)", q.state)
+		}
+		for _, item := range q.items {
+			if item.State == QueueItemQueued && item.AssignableTo(printer) {
+				item.State = QueueItemStarting
+				q.state = QueueStarting
+				reserved, found = *item, true
+				return nil
+			}
+		}
+		return fmt.Errorf("nothing to start")
+	})
+	return reserved, found
+}
+
+// Release puts a reserved item back in the queue after its job could not be
+// created
+func (q *PrintQueue) Release(id string) error {
+	return q.update(func() error {
+		_, item := q.find(id)
+		if item == nil {
+			return fmt.Errorf("queue item not found")
+		}
+		if item.State != QueueItemStarting {
+			return fmt.Errorf("%s is not being started", item.Name)
+		}
+		item.State = QueueItemQueued
+		q.state = QueueIdle
+		return nil
+	})
+}
+
+// Start records that a reserved item was sent to the printer as a backend job
+func (q *PrintQueue) Start(id string, jobID uint) error {
+	return q.update(func() error {
+		_, item := q.find(id)
+		if item == nil {
+			return fmt.Errorf("queue item not found")
+		}
+		if item.State != QueueItemStarting {
+			return fmt.Errorf("%s has not been reserved", item.Name)
+		}
+		item.State = QueueItemPrinting
+		item.JobID = jobID
+		q.state = QueuePrinting
+		return nil
+	})
+}
+
+// Finish records the end of the printing copy. Whatever the outcome the
+// queue then waits for the bed to be cleared; a completed copy counts
+// towards the item's copies, a failed one holds the item until retried.
+func (q *PrintQueue) Finish(jobID uint, completed bool) error {
+	return q.update(func() error {
+		var item *QueueItem
+		for _, candidate := range q.items {
+			if candidate.State == QueueItemPrinting && candidate.JobID == jobID {
+				item = candidate
+				break
+			}
+		}
+		if item == nil {
+			return fmt.Errorf("no queued job %d is printing", jobID)
+		}
+
+		item.JobID = 0
+		switch {
+		case !completed:
+			item.State = QueueItemFailed
+		case item.CopiesDone+1 >= item.Copies:
+			item.CopiesDone++
+			item.State = QueueItemDone
+		default:
+			item.CopiesDone++
+			item.State = QueueItemQueued
+		}
+		q.state = QueueBedClear
+		return nil
+	})
+}
+
+// BedCleared records that the finished print was taken off the bed, so the
+// next job may start
+func (q *PrintQueue) BedCleared() error {
+	return q.update(func() error {
+		if q.state != QueueBedClear {
+			return fmt.Errorf("the queue is not waiting for the bed to be cleared")
+		}
+		q.state = QueueIdle
+		return nil
+	})
+}
+
+// update applies a change under the lock, then saves the copy taken under
+// it and notifies if the change succeeded
+func (q *PrintQueue) update(change func() error) error {
+	q.mu.Lock()
+	if err := change(); err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	q.updatedAt = time.Now()
+	data := q.snapshot()
+	q.mu.Unlock()
+
+	if err := q.save(data); err != nil {
+		return fmt.Errorf("failed to save queue: %v", err)
+	}
+	q.notify()
+	return nil
+}
+
+// find returns the index and item with the given ID, or nil
+func (q *PrintQueue) find(id string) (int, *QueueItem) {
+	for i, item := range q.items {
+		if item.ID == id {
+			return i, item
+		}
+	}
+	return -1, nil
+}
+
+// snapshot copies the queue; the caller holds the lock
+func (q *PrintQueue) snapshot() printQueueData {
+	data := printQueueData{
+		Items:     make([]QueueItem, len(q.items)),
+		Paused:    q.paused,
+		State:     q.state,
+		UpdatedAt: q.updatedAt,
+	}
+	for i, item := range q.items {
+		data.Items[i] = *item
+	}
+	return data
+}
+
+// restore replaces the queue with stored data; the caller holds the lock
+func (q *PrintQueue) restore(data printQueueData) {
+	q.items = make([]*QueueItem, len(data.Items))
+	for i := range data.Items {
+		item := data.Items[i]
+		q.items[i] = &item
+	}
+	q.paused = data.Paused
+	q.state = data.State
+	if q.state == "" {
+		q.state = QueueIdle
+	}
+	q.updatedAt = data.UpdatedAt
+}
+
+// save writes a copy of the queue to disk. A copy older than the one last
+// written is skipped, so concurrent updates cannot roll the file back.
+func (q *PrintQueue) save(data printQueueData) error {
+	if q.path == "" {
+		return nil
+	}
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+	if data.UpdatedAt.Before(q.savedAt) {
+		return nil
+	}
+
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(q.path, jsonData, 0600); err != nil {
+		return err
+	}
+	q.savedAt = data.UpdatedAt
+	return nil
+}
+
+// notify runs the change callback
+func (q *PrintQueue) notify() {
+	q.mu.Lock()
+	callback := q.onChange
+	q.mu.Unlock()
+	if callback != nil {
+		callback()
+	}
+}
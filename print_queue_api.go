@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// fetchQueue loads the queue stored on the backend and takes it over if it
+// is newer than the local copy, e.g. after the touchscreen was replaced
+func (ui *PrintJobsUI) fetchQueue() {
+	go func() {
+		url := fmt.Sprintf("%s/api/v1/print-queue?printer_id=%d", ui.backendURL, ui.currentPrinter.ID)
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return
+		}
+
+		req.Header.Set("Authorization", "Bearer "+ui.authToken)
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Printf("Failed to fetch print queue: %v", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			var remote printQueueData
+			if err := json.NewDecoder(resp.Body).Decode(&remote); err == nil {
+				ui.queue.Replace(remote)
+			}
+		}
+	}()
+}
+
+// syncQueue sends the queue to the backend. A failed sync is retried with
+// the next change; the local file stays authoritative meanwhile.
+func (ui *PrintJobsUI) syncQueue() {
+	jsonData, err := json.Marshal(ui.queue.Snapshot())
+	if err != nil {
+		return
+	}
+
+	go func() {
+		url := fmt.Sprintf("%s/api/v1/print-queue?printer_id=%d", ui.backendURL, ui.currentPrinter.ID)
+		req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return
+		}
+
+		req.Header.Set("Authorization", "Bearer "+ui.authToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Printf("Failed to sync print queue: %v", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			log.Printf("Failed to sync print queue: %s", resp.Status)
+		}
+	}()
+}
+
+// createPrintJob asks the backend to print a file on the current printer
+func (ui *PrintJobsUI) createPrintJob(fileID uint) (*PrintJob, error) {
+	reqBody := struct {
+		PrinterID uint `json:"printer_id"`
+		FileID    uint `json:"file_id"`
+	}{
+		PrinterID: ui.currentPrinter.ID,
+		FileID:    fileID,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/api/v1/print-jobs", ui.backendURL)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+ui.authToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to start print: %s", resp.Status)
+	}
+
+	var job PrintJob
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// addTestItem queues copies of a file, failing the test on error
+func addTestItem(t *testing.T, q *PrintQueue, name string, copies int) QueueItem {
+	t.Helper()
+	item, err := q.Add(GCodeFile{ID: uint(len(q.Items()) + 1), Name: name, FileName: name + ".gcode"}, copies, 0, nil)
+	if err != nil {
+		t.Fatalf("add %s: %v", name, err)
+	}
+	return item
+}
+
+// queueItem returns the current copy of an item
+func queueItem(t *testing.T, q *PrintQueue, id string) QueueItem {
+	t.Helper()
+	for _, item := range q.Items() {
+		if item.ID == id {
+			return item
+		}
+	}
+	t.Fatalf("item %s is not in the queue", id)
+	return QueueItem{}
+}
+
+func TestPrintQueueCopies(t *testing.T) {
+	q := NewPrintQueue("")
+	item := addTestItem(t, q, "bracket", 2)
+	printer := QueuePrinter{ID: 1}
+
+	for copy := 1; copy <= 2; copy++ {
+		reserved, ok := q.Reserve(printer)
+		if !ok || reserved.ID != item.ID {
+			t.Fatalf("copy %d: nothing reserved", copy)
+		}
+		if q.State() != QueueStarting {
+			t.Errorf("copy %d: queue state %s, want starting", copy, q.State())
+		}
+		if err := q.Start(item.ID, uint(copy)); err != nil {
+			t.Fatalf("copy %d: start: %v", copy, err)
+		}
+		if q.State() != QueuePrinting {
+			t.Errorf("copy %d: queue state %s, want printing", copy, q.State())
+		}
+		if _, ok := q.Reserve(printer); ok {
+			t.Errorf("copy %d: reserved while printing", copy)
+		}
+		if err := q.Finish(uint(copy), true); err != nil {
+			t.Fatalf("copy %d: finish: %v", copy, err)
+		}
+		if _, ok := q.Reserve(printer); ok {
+			t.Errorf("copy %d: reserved before the bed was cleared", copy)
+		}
+		if err := q.BedCleared(); err != nil {
+			t.Fatalf("copy %d: bed cleared: %v", copy, err)
+		}
+	}
+
+	done := queueItem(t, q, item.ID)
+	if done.State != QueueItemDone || done.CopiesDone != 2 {
+		t.Errorf("item %s with %d copies done, want done with 2", done.State, done.CopiesDone)
+	}
+	if _, ok := q.Reserve(printer); ok {
+		t.Error("reserved a finished item")
+	}
+}
+
+func TestPrintQueueReserveIsExclusive(t *testing.T) {
+	q := NewPrintQueue("")
+	first := addTestItem(t, q, "first", 1)
+	addTestItem(t, q, "second", 1)
+
+	if _, ok := q.Reserve(QueuePrinter{ID: 1}); !ok {
+		t.Fatal("nothing reserved")
+	}
+	// A second trigger while the job is being created must not start
+	// anything else
+	if item, ok := q.Reserve(QueuePrinter{ID: 1}); ok {
+		t.Errorf("reserved %s while %s was starting", item.Name, first.Name)
+	}
+	if err := q.Remove(first.ID); err == nil {
+		t.Error("removed an item that was starting")
+	}
+}
+
+func TestPrintQueueConcurrentReserve(t *testing.T) {
+	q := NewPrintQueue(filepath.Join(t.TempDir(), "print_queue.json"))
+	addTestItem(t, q, "first", 1)
+	addTestItem(t, q, "second", 1)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := q.Reserve(QueuePrinter{ID: 1}); ok {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if reserved != 1 {
+		t.Errorf("%d triggers reserved an item, want 1", reserved)
+	}
+
+	// The file holds the final state, not an earlier copy
+	stored := NewPrintQueue(q.path)
+	if err := stored.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got, want := stored.Snapshot().UpdatedAt, q.Snapshot().UpdatedAt; !got.Equal(want) {
+		t.Errorf("stored queue from %v, want %v", got, want)
+	}
+}
+
+func TestPrintQueueReleaseAfterFailedStart(t *testing.T) {
+	q := NewPrintQueue("")
+	item := addTestItem(t, q, "bracket", 1)
+
+	if _, ok := q.Reserve(QueuePrinter{ID: 1}); !ok {
+		t.Fatal("nothing reserved")
+	}
+	if err := q.Release(item.ID); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if got := queueItem(t, q, item.ID).State; got != QueueItemQueued {
+		t.Errorf("item %s after release, want queued", got)
+	}
+	if q.State() != QueueIdle {
+		t.Errorf("queue %s after release, want idle", q.State())
+	}
+	if err := q.Start(item.ID, 7); err == nil {
+		t.Error("started an item that was not reserved")
+	}
+	if reserved, ok := q.Reserve(QueuePrinter{ID: 1}); !ok || reserved.ID != item.ID {
+		t.Error("released item was not reserved again")
+	}
+}
+
+func TestPrintQueueFailureHoldsItem(t *testing.T) {
+	q := NewPrintQueue("")
+	item := addTestItem(t, q, "bracket", 3)
+
+	q.Reserve(QueuePrinter{ID: 1})
+	if err := q.Start(item.ID, 5); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := q.Finish(99, false); err == nil {
+		t.Error("finished a job the queue did not start")
+	}
+	if err := q.Finish(5, false); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	failed := queueItem(t, q, item.ID)
+	if failed.State != QueueItemFailed || failed.CopiesDone != 0 {
+		t.Errorf("item %s with %d copies done, want failed with 0", failed.State, failed.CopiesDone)
+	}
+
+	if err := q.BedCleared(); err != nil {
+		t.Fatalf("bed cleared: %v", err)
+	}
+	if _, ok := q.Reserve(QueuePrinter{ID: 1}); ok {
+		t.Error("reserved a failed item")
+	}
+	if err := q.Retry(item.ID); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if _, ok := q.Reserve(QueuePrinter{ID: 1}); !ok {
+		t.Error("retried item was not reserved")
+	}
+}
+
+func TestPrintQueuePausedAndAssigned(t *testing.T) {
+	q := NewPrintQueue("")
+	other, err := q.Add(GCodeFile{ID: 1, Name: "other"}, 1, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enclosed, err := q.Add(GCodeFile{ID: 2, Name: "enclosed"}, 1, 0, []string{"enclosure"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := addTestItem(t, q, "plain", 1)
+
+	q.Pause()
+	if _, ok := q.Reserve(QueuePrinter{ID: 1}); ok {
+		t.Error("reserved while paused")
+	}
+	q.Resume()
+
+	item, ok := q.Reserve(QueuePrinter{ID: 1, Capabilities: []string{"Enclosure"}})
+	if !ok || item.ID != enclosed.ID {
+		t.Errorf("reserved %q, want %q (%q belongs to printer 2)", item.Name, enclosed.Name, other.Name)
+	}
+	q.Release(item.ID)
+
+	item, ok = q.Reserve(QueuePrinter{ID: 1})
+	if !ok || item.ID != plain.ID {
+		t.Errorf("reserved %q, want %q", item.Name, plain.Name)
+	}
+}
+
+func TestPrintQueueApproval(t *testing.T) {
+	q := NewPrintQueue("")
+	pending, err := q.Submit(GCodeFile{ID: 1, Name: "student"}, 1, 0, nil, "sam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected, err := q.Submit(GCodeFile{ID: 2, Name: "guest"}, 1, 0, nil, "guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := q.Reserve(QueuePrinter{ID: 1}); ok {
+		t.Error("reserved an item awaiting approval")
+	}
+	if err := q.Reject(rejected.ID, "alex", "Too large"); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if err := q.Approve(rejected.ID, "alex"); err == nil {
+		t.Error("approved a rejected item")
+	}
+	if err := q.Approve(pending.ID, "alex"); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if item, ok := q.Reserve(QueuePrinter{ID: 1}); !ok || item.ID != pending.ID {
+		t.Error("approved item was not reserved")
+	}
+
+	got := queueItem(t, q, rejected.ID)
+	if label := got.StateLabel(); label != "Rejected by alex" {
+		t.Errorf("label %q, want %q", label, "Rejected by alex")
+	}
+}
+
+func TestPrintQueueStateLabels(t *testing.T) {
+	for state, want := range map[QueueItemState]string{
+		QueueItemQueued:   "Queued",
+		QueueItemStarting: "Starting",
+		QueueItemPrinting: "Printing",
+		QueueItemDone:     "Done",
+		QueueItemFailed:   "Failed",
+		QueueItemPending:  "Awaiting approval",
+		QueueItemRejected: "Rejected",
+	} {
+		item := QueueItem{State: state}
+		if got := item.StateLabel(); got != want {
+			t.Errorf("%s: label %q, want %q", state, got, want)
+		}
+	}
+}
+
+func TestPrintQueueLoadReleasesStartingItem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "print_queue.json")
+	q := NewPrintQueue(path)
+	item := addTestItem(t, q, "bracket", 1)
+	q.Reserve(QueuePrinter{ID: 1})
+
+	// The touchscreen stopped before the job was created
+	restarted := NewPrintQueue(path)
+	if err := restarted.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := queueItem(t, restarted, item.ID).State; got != QueueItemQueued {
+		t.Errorf("item %s after restart, want queued", got)
+	}
+	if restarted.State() != QueueIdle {
+		t.Errorf("queue %s after restart, want idle", restarted.State())
+	}
+}
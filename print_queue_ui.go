@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Assignment choices offered when queueing a file
+const (
+	queueAnyPrinter  = "Any printer"
+	queueThisPrinter = "This printer"
+)
+
+// createQueueSection creates the print queue section
+func (ui *PrintJobsUI) createQueueSection() fyne.CanvasObject {
+	ui.queueStatus = widget.NewLabel("")
+
+	pauseBtn := widget.NewButtonWithIcon("Pause Queue", theme.MediaPauseIcon(), nil)
+	pauseBtn.OnTapped = func() {
+		if !ui.requireApprover("pause the queue") {
+			return
+		}
+		var err error
+		if ui.queue.Paused() {
+			err = ui.queue.Resume()
+		} else {
+			err = ui.queue.Pause()
+		}
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.startNextQueued()
+	}
+
+	bedClearedBtn := widget.NewButtonWithIcon("Bed Cleared", theme.ConfirmIcon(), func() {
+		ui.confirmBedCleared()
+	})
+	bedClearedBtn.Importance = widget.HighImportance
+
+	clearDoneBtn := widget.NewButtonWithIcon("Clear Done", theme.DeleteIcon(), func() {
+		if !ui.requireApprover("clear the queue") {
+			return
+		}
+		if err := ui.queue.ClearDone(); err != nil {
+			dialog.ShowError(err, ui.window)
+		}
+	})
+
+	ui.queueList = widget.NewList(
+		func() int { return len(ui.queueItems) },
+		func() fyne.CanvasObject {
+			return container.NewHBox(
+				container.NewVBox(
+					widget.NewLabel("Job name"),
+					widget.NewLabel("Details"),
+				),
+				layout.NewSpacer(),
+				widget.NewButtonWithIcon("", theme.MoveUpIcon(), nil),
+				widget.NewButtonWithIcon("", theme.MoveDownIcon(), nil),
+				widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), nil),
+				widget.NewButtonWithIcon("", theme.DeleteIcon(), nil),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(ui.queueItems) {
+				return
+			}
+
+			item := ui.queueItems[id]
+			hbox := obj.(*fyne.Container)
+
+			info := hbox.Objects[0].(*fyne.Container)
+			info.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%d. %s", id+1, item.Name))
+			info.Objects[1].(*widget.Label).SetText(fmt.Sprintf(
+				"%s | Copies: %d/%d | %s",
+				item.StateLabel(),
+				item.CopiesDone, item.Copies,
+				item.Assignment(),
+			))
+
+			position := id
+			hbox.Objects[2].(*widget.Button).OnTapped = func() {
+				ui.moveQueueItem(item, position-1)
+			}
+			hbox.Objects[3].(*widget.Button).OnTapped = func() {
+				ui.moveQueueItem(item, position+1)
+			}
+			hbox.Objects[4].(*widget.Button).OnTapped = func() {
+				ui.showEditQueueItemDialog(item)
+			}
+			hbox.Objects[5].(*widget.Button).OnTapped = func() {
+				ui.removeQueueItem(item)
+			}
+		},
+	)
+
+	updateButtons := func() {
+		if ui.queue.Paused() {
+			pauseBtn.SetText("Resume Queue")
+			pauseBtn.SetIcon(theme.MediaPlayIcon())
+		} else {
+			pauseBtn.SetText("Pause Queue")
+			pauseBtn.SetIcon(theme.MediaPauseIcon())
+		}
+		if ui.queue.State() == QueueBedClear {
+			bedClearedBtn.Enable()
+		} else {
+			bedClearedBtn.Disable()
+		}
+	}
+	ui.queue.SetOnChange(func() {
+		updateButtons()
+		ui.refreshQueue()
+		ui.refreshApprovals()
+		ui.syncQueue()
+	})
+	updateButtons()
+	ui.refreshQueue()
+
+	scheduledBtn := widget.NewButtonWithIcon("Scheduled", theme.HistoryIcon(), func() {
+		ui.showSchedules()
+	})
+
+	controls := container.NewGridWithColumns(4, pauseBtn, bedClearedBtn, clearDoneBtn, scheduledBtn)
+
+	return container.NewBorder(
+		container.NewVBox(controls, container.NewPadded(ui.queueStatus)),
+		nil, nil, nil,
+		ui.queueList,
+	)
+}
+
+// refreshQueue redraws the queue list and its status line
+func (ui *PrintJobsUI) refreshQueue() {
+	ui.queueItems = ui.queue.Items()
+	if ui.queueList != nil {
+		ui.queueList.Refresh()
+	}
+	if ui.queueStatus == nil {
+		return
+	}
+
+	waiting, pending := 0, 0
+	for _, item := range ui.queueItems {
+		switch item.State {
+		case QueueItemQueued:
+			waiting += item.Copies - item.CopiesDone
+		case QueueItemPending:
+			pending++
+		}
+	}
+
+	var status string
+	switch ui.queue.State() {
+	case QueuePrinting:
+		status = "Printing"
+		if item, ok := ui.queue.Printing(); ok {
+			status = fmt.Sprintf("Printing %s (copy %d of %d)", item.Name, item.CopiesDone+1, item.Copies)
+		}
+	case QueueStarting:
+		status = "Starting the next job"
+	case QueueBedClear:
+		status = "Clear the bed and tap Bed Cleared to continue"
+	default:
+		status = "Ready"
+	}
+	if ui.queue.Paused() {
+		status += " | Queue paused"
+	}
+	status = fmt.Sprintf("%s | %d prints waiting", status, waiting)
+	if pending > 0 {
+		status += fmt.Sprintf(" | %d awaiting approval", pending)
+	}
+	ui.queueStatus.SetText(status)
+}
+
+// loadQueue restores the stored queue, picks up a newer copy from the
+// backend, and resumes monitoring a queued job that was printing when the
+// touchscreen restarted
+func (ui *PrintJobsUI) loadQueue() {
+	if err := ui.queue.Load(); err != nil {
+		log.Printf("Failed to load print queue: %v", err)
+	}
+	ui.refreshQueue()
+	ui.fetchQueue()
+
+	if item, ok := ui.queue.Printing(); ok && ui.currentJob == nil {
+		job := &PrintJob{ID: item.JobID, Name: item.Name, FileName: item.FileName, Status: "printing"}
+		ui.currentJob = job
+		ui.updateActiveJobUI()
+		go ui.monitorPrintJob(job)
+	}
+}
+
+// SetPrinterProfile sets the capabilities queued jobs are matched against
+func (ui *PrintJobsUI) SetPrinterProfile(profile *PrinterProfile) {
+	ui.profile = profile
+	ui.startNextQueued()
+}
+
+// queuePrinter describes the current printer for job assignment
+func (ui *PrintJobsUI) queuePrinter() QueuePrinter {
+	printer := QueuePrinter{ID: ui.currentPrinter.ID}
+	if ui.profile != nil {
+		printer.Capabilities = ui.profile.Capabilities
+	}
+	return printer
+}
+
+// startNextQueued starts the next queued job this printer may print, if the
+// printer is free and the queue is ready
+func (ui *PrintJobsUI) startNextQueued() {
+	if ui.currentJob != nil {
+		return
+	}
+	item, ok := ui.queue.Reserve(ui.queuePrinter())
+	if !ok {
+		return
+	}
+
+	go func() {
+		// Files submitted from other screens are known by name only
+		fileID := item.FileID
+		if file, ok := ui.fileNamed(item.FileName); fileID == 0 && ok {
+			fileID = file.ID
+		}
+		var job *PrintJob
+		err := fmt.Errorf("%s is not in the file list", item.FileName)
+		if fileID != 0 {
+			job, err = ui.createPrintJob(fileID)
+		}
+		if err != nil {
+			if err := ui.queue.Release(item.ID); err != nil {
+				log.Printf("Failed to release queue item %s: %v", item.ID, err)
+			}
+			ui.statusLabel.SetText(fmt.Sprintf("Failed to start %s from the queue", item.Name))
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		if err := ui.queue.Start(item.ID, job.ID); err != nil {
+			log.Printf("Failed to record queued job %d: %v", job.ID, err)
+		}
+
+		ui.currentJob = job
+		ui.statusLabel.SetText(fmt.Sprintf("Print started from queue: %s", item.Name))
+		ui.updateActiveJobUI()
+		ui.updatePrintButton()
+
+		go ui.monitorPrintJob(job)
+	}()
+}
+
+// queueJobEnded records the end of a job started from the queue and asks
+// for the bed to be cleared before the next one starts
+func (ui *PrintJobsUI) queueJobEnded(jobID uint, completed bool) {
+	if err := ui.queue.Finish(jobID, completed); err != nil {
+		// Not a queued job
+		return
+	}
+
+	message := "The print has finished."
+	if !completed {
+		message = "The print did not complete and is held in the queue."
+	}
+	dialog.ShowCustomConfirm("Clear the Bed", "Bed Cleared", "Later",
+		widget.NewLabel(message+"\nRemove it from the bed, then tap Bed Cleared to start the next job."),
+		func(cleared bool) {
+			if cleared {
+				ui.confirmBedCleared()
+			}
+		},
+		ui.window,
+	)
+}
+
+// confirmBedCleared lets the queue continue with the next job
+func (ui *PrintJobsUI) confirmBedCleared() {
+	if err := ui.queue.BedCleared(); err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	ui.startNextQueued()
+}
+
+// moveQueueItem moves an item to a new position, ignoring moves past either end
+func (ui *PrintJobsUI) moveQueueItem(item QueueItem, position int) {
+	if position < 0 || position >= len(ui.queueItems) {
+		return
+	}
+	if !ui.requireApprover("reorder the queue") {
+		return
+	}
+	if err := ui.queue.Move(item.ID, position); err != nil {
+		dialog.ShowError(err, ui.window)
+	}
+}
+
+// removeQueueItem takes an item off the queue after confirmation. Students
+// and guests may withdraw their own submissions.
+func (ui *PrintJobsUI) removeQueueItem(item QueueItem) {
+	if item.SubmittedBy == "" || item.SubmittedBy != ui.userName() {
+		if !ui.requireApprover("remove jobs from the queue") {
+			return
+		}
+	}
+	dialog.ShowConfirm("Remove from Queue",
+		fmt.Sprintf("Remove '%s' from the queue?", item.Name),
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := ui.queue.Remove(item.ID); err != nil {
+				dialog.ShowError(err, ui.window)
+			}
+		},
+		ui.window,
+	)
+}
+
+// showAddToQueueDialog asks for the copies and printer assignment of a
+// file. Users who may not start prints submit it for approval instead.
+func (ui *PrintJobsUI) showAddToQueueDialog(file *GCodeFile) {
+	copies, assignment, capabilities := ui.queueFormEntries(1, 0, nil)
+
+	title, confirm := "Add to Queue", "Add"
+	if !ui.canApprove() {
+		title, confirm = "Submit for Approval", "Submit"
+	}
+	dialog.ShowForm(title, confirm, "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Copies", copies),
+		widget.NewFormItem("Printer", assignment),
+		widget.NewFormItem("Capabilities", capabilities),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		count, printerID, required, err := ui.parseQueueForm(copies, assignment, capabilities)
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		if !ui.canApprove() {
+			ui.submitForApproval(*file, count, printerID, required)
+			return
+		}
+		if _, err := ui.queue.Add(*file, count, printerID, required); err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.statusLabel.SetText(fmt.Sprintf("Queued: %s", file.Name))
+		ui.startNextQueued()
+	}, ui.window)
+}
+
+// showEditQueueItemDialog changes the copies and printer assignment of an item
+func (ui *PrintJobsUI) showEditQueueItemDialog(item QueueItem) {
+	if !ui.requireApprover("edit queued jobs") {
+		return
+	}
+	copies, assignment, capabilities := ui.queueFormEntries(item.Copies, item.PrinterID, item.Capabilities)
+
+	dialog.ShowForm("Edit "+item.Name, "Save", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Copies", copies),
+		widget.NewFormItem("Printer", assignment),
+		widget.NewFormItem("Capabilities", capabilities),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		count, printerID, required, err := ui.parseQueueForm(copies, assignment, capabilities)
+		if err == nil {
+			err = ui.queue.SetCopies(item.ID, count)
+		}
+		if err == nil {
+			err = ui.queue.SetAssignment(item.ID, printerID, required)
+		}
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.startNextQueued()
+	}, ui.window)
+}
+
+// queueFormEntries creates the inputs shared by the add and edit dialogs
+func (ui *PrintJobsUI) queueFormEntries(copies int, printerID uint, capabilities []string) (*widget.Entry, *widget.Select, *widget.Entry) {
+	copiesEntry := widget.NewEntry()
+	copiesEntry.SetText(strconv.Itoa(copies))
+
+	thisPrinter := fmt.Sprintf("%s (#%d)", queueThisPrinter, ui.currentPrinter.ID)
+	options := []string{queueAnyPrinter, thisPrinter}
+	selected := queueAnyPrinter
+	switch {
+	case printerID == ui.currentPrinter.ID:
+		selected = thisPrinter
+	case printerID != 0:
+		// Assigned to another printer on the backend
+		selected = fmt.Sprintf("Printer #%d", printerID)
+		options = append(options, selected)
+	}
+	assignment := widget.NewSelect(options, nil)
+	assignment.SetSelected(selected)
+
+	capabilitiesEntry := widget.NewEntry()
+	capabilitiesEntry.SetPlaceHolder("e.g. idex, enclosure (any printer only)")
+	capabilitiesEntry.SetText(strings.Join(capabilities, ", "))
+
+	return copiesEntry, assignment, capabilitiesEntry
+}
+
+// parseQueueForm reads the copies and assignment from the dialog inputs
+func (ui *PrintJobsUI) parseQueueForm(copies *widget.Entry, assignment *widget.Select, capabilities *widget.Entry) (int, uint, []string, error) {
+	count, err := strconv.Atoi(strings.TrimSpace(copies.Text))
+	if err != nil || count < 1 {
+		return 0, 0, nil, fmt.Errorf("copies must be a whole number of at least 1")
+	}
+
+	var printerID uint
+	switch {
+	case assignment.Selected == queueAnyPrinter:
+	case strings.HasPrefix(assignment.Selected, queueThisPrinter):
+		printerID = ui.currentPrinter.ID
+	default:
+		id, _ := strconv.ParseUint(strings.TrimPrefix(assignment.Selected, "Printer #"), 10, 64)
+		printerID = uint(id)
+	}
+
+	required := []string{}
+	for _, capability := range strings.Split(capabilities.Text, ",") {
+		if capability = strings.TrimSpace(capability); capability != "" {
+			required = append(required, capability)
+		}
+	}
+	return count, printerID, required, nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkpointInterval is how often a checkpoint is written within a layer;
+// layer changes are always written
+const checkpointInterval = 10 * time.Second
+
+// checkpointEndStatuses end a print cleanly, so its checkpoint is dropped
+var checkpointEndStatuses = map[string]bool{
+	"complete":  true,
+	"completed": true,
+	"cancelled": true,
+	"idle":      true,
+	"ready":     true,
+	"standby":   true,
+}
+
+// PrintCheckpoint is the last known position of a running print
+type PrintCheckpoint struct {
+	FileName     string          `json:"file_name"`
+	FilePosition int64           `json:"file_position"` // Byte offset in the file
+	LineNumber   int             `json:"line_number"`   // 0 when only the offset is known
+	Layer        int             `json:"layer"`
+	Z            float64         `json:"z"`
+	E            float64         `json:"e"`
+	HotendTemp   float64         `json:"hotend_temp"`            // Target, not the measured temperature
+	BedTemp      float64         `json:"bed_temp"`               // Target
+	ChamberTemp  float64         `json:"chamber_temp,omitempty"` // Target
+	ToolTemps    map[int]float64 `json:"tool_temps,omitempty"`   // Target of each numbered hotend
+	FanSpeed     float64         `json:"fan_speed"`              // 0-255
+	SavedAt      time.Time       `json:"saved_at"`
+}
+
+// ResolveLine returns the source line of the checkpoint in a parsed file
+func (cp *PrintCheckpoint) ResolveLine(model *GCodeModel) int {
+	return model.ResolveStatusLine(PrinterStatus{LineNumber: cp.LineNumber, FilePosition: cp.FilePosition})
+}
+
+// CheckpointStore writes checkpoints from the status stream so a print can
+// be recovered after a power cut
+type CheckpointStore struct {
+	mu        sync.Mutex
+	path      string
+	last      PrintCheckpoint
+	lastSaved time.Time
+	active    bool // A print was seen running since start-up
+}
+
+// NewCheckpointStore creates a store writing to path
+func NewCheckpointStore(path string) *CheckpointStore {
+	return &CheckpointStore{path: path}
+}
+
+// printCheckpointFile is where the touchscreen keeps the running print's
+// checkpoint
+func printCheckpointFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "print_checkpoint.json")
+}
+
+// Observe takes a status update. Running prints are checkpointed on every
+// layer change and at least every checkpointInterval; a print that ends
+// cleanly removes its checkpoint. A checkpoint left from before start-up
+// is kept until recovered or discarded.
+func (s *CheckpointStore) Observe(status PrinterStatus) error {
+	state := strings.ToLower(status.Status)
+
+	s.mu.Lock()
+	if checkpointEndStatuses[state] {
+		wasActive := s.active
+		s.active = false
+		s.mu.Unlock()
+		if wasActive {
+			return s.Clear()
+		}
+		return nil
+	}
+	if state != "printing" && state != "paused" {
+		s.mu.Unlock()
+		return nil
+	}
+	if status.FileName == "" || (status.LineNumber <= 0 && status.FilePosition <= 0) {
+		s.mu.Unlock()
+		return nil
+	}
+
+	targets := heaterTargets(status)
+	checkpoint := PrintCheckpoint{
+		FileName:     status.FileName,
+		FilePosition: status.FilePosition,
+		LineNumber:   status.LineNumber,
+		Layer:        status.CurrentLayer,
+		Z:            status.PositionZ,
+		E:            status.PositionE,
+		HotendTemp:   targets.hotend,
+		BedTemp:      targets.bed,
+		ChamberTemp:  targets.chamber,
+		ToolTemps:    targets.tools,
+		FanSpeed:     status.FanSpeed,
+		SavedAt:      time.Now(),
+	}
+	// Heaters switched off during a pause keep the print's targets
+	if s.active && checkpoint.FileName == s.last.FileName {
+		if checkpoint.HotendTemp <= 0 {
+			checkpoint.HotendTemp = s.last.HotendTemp
+		}
+		if checkpoint.BedTemp <= 0 {
+			checkpoint.BedTemp = s.last.BedTemp
+		}
+		if checkpoint.ChamberTemp <= 0 {
+			checkpoint.ChamberTemp = s.last.ChamberTemp
+		}
+		for tool, temp := range s.last.ToolTemps {
+			if checkpoint.ToolTemps[tool] <= 0 {
+				if checkpoint.ToolTemps == nil {
+					checkpoint.ToolTemps = map[int]float64{}
+				}
+				checkpoint.ToolTemps[tool] = temp
+			}
+		}
+	}
+	due := !s.active || checkpoint.FileName != s.last.FileName || checkpoint.Layer != s.last.Layer ||
+		checkpoint.SavedAt.Sub(s.lastSaved) >= checkpointInterval
+	s.active = true
+	s.last = checkpoint
+	if !due {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSaved = checkpoint.SavedAt
+	s.mu.Unlock()
+
+	return s.save(checkpoint)
+}
+
+// checkpointTargets are the heater targets of a status
+type checkpointTargets struct {
+	hotend, bed, chamber float64
+	tools                map[int]float64 // Hotends the backend reports by number
+}
+
+// heaterTargets returns the target of the first heated tool, of every
+// numbered tool, and of the bed and chamber
+func heaterTargets(status PrinterStatus) checkpointTargets {
+	targets := checkpointTargets{}
+	for _, reading := range status.Readings() {
+		switch reading.HeaterKind() {
+		case HeaterKindTool:
+			if targets.hotend <= 0 {
+				targets.hotend = reading.Target
+			}
+			if index, ok := heaterIndex(strings.ToLower(reading.Name)); ok && reading.Target > 0 {
+				if targets.tools == nil {
+					targets.tools = map[int]float64{}
+				}
+				targets.tools[index] = reading.Target
+			}
+		case HeaterKindBed:
+			targets.bed = reading.Target
+		case HeaterKindChamber:
+			targets.chamber = reading.Target
+		}
+	}
+	return targets
+}
+
+// Load returns the stored checkpoint, or nil when there is none
+func (s *CheckpointStore) Load() (*PrintCheckpoint, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint PrintCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file: %v", err)
+	}
+	return &checkpoint, nil
+}
+
+// Clear removes the stored checkpoint
+func (s *CheckpointStore) Clear() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// save writes a checkpoint. The file is replaced by a rename so a power cut
+// mid-write leaves the previous checkpoint intact.
+func (s *CheckpointStore) save(checkpoint PrintCheckpoint) error {
+	if s.path == "" {
+		return nil
+	}
+
+	jsonData, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	temp := s.path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(jsonData); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp, s.path)
+}
+
+// RecoveryPlan is a checkpointed print ready to be resumed
+type RecoveryPlan struct {
+	Checkpoint PrintCheckpoint
+	Resume     *ResumePlan
+	Warnings   []string
+}
+
+// PlanRecovery reconstructs the modal state of the file at the checkpoint
+// and plans resuming it. Checkpointed temperatures are used only where the
+// file set none; differences between the checkpoint and the file are
+// reported as warnings.
+func PlanRecovery(model *GCodeModel, checkpoint PrintCheckpoint, opts ResumeOptions) (*RecoveryPlan, error) {
+	if model == nil {
+		return nil, fmt.Errorf("no G-code loaded")
+	}
+	line := checkpoint.ResolveLine(model)
+	if line <= 0 {
+		return nil, fmt.Errorf("the checkpoint has no file position")
+	}
+
+	if opts.HotendTemp <= 0 {
+		opts.HotendTemp = checkpoint.HotendTemp
+	}
+	if opts.BedTemp <= 0 {
+		opts.BedTemp = checkpoint.BedTemp
+	}
+	if opts.ChamberTemp <= 0 {
+		opts.ChamberTemp = checkpoint.ChamberTemp
+	}
+	if opts.ToolTemps == nil {
+		opts.ToolTemps = checkpoint.ToolTemps
+	}
+	resume, err := PlanPowerLossResume(model, line, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RecoveryPlan{Checkpoint: checkpoint, Resume: resume}
+	if checkpoint.Z > 0 && math.Abs(checkpoint.Z-resume.State.Z) > 0.5 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"The printer reported Z %.2f but the file is at Z %.2f on line %d.",
+			checkpoint.Z, resume.State.Z, line))
+	}
+	if checkpoint.Layer > 0 && checkpoint.Layer != resume.Layer+1 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"The printer reported layer %d but line %d is on layer %d.",
+			checkpoint.Layer, line, resume.Layer+1))
+	}
+	return plan, nil
+}
+
+// recoveryFileName names the derived file that resumes a print
+func recoveryFileName(fileName string) string {
+	base := PlainGCodeName(fileName)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	return base + ".recovery.gcode"
+}
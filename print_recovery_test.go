@@ -0,0 +1,225 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// printingStatus is a running print of file at a line and layer
+func printingStatus(file string, line, layer int) PrinterStatus {
+	return PrinterStatus{
+		Status:       "printing",
+		FileName:     file,
+		LineNumber:   line,
+		CurrentLayer: layer,
+		PositionZ:    0.2 * float64(layer),
+		Temperature:  212.4,
+		TargetTemp:   215,
+		BedTemp:      59.1,
+		BedTarget:    60,
+		FanSpeed:     128,
+	}
+}
+
+func newCheckpointStore(t *testing.T) *CheckpointStore {
+	t.Helper()
+	return NewCheckpointStore(filepath.Join(t.TempDir(), "print_checkpoint.json"))
+}
+
+func loadCheckpoint(t *testing.T, store *CheckpointStore) *PrintCheckpoint {
+	t.Helper()
+	checkpoint, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checkpoint
+}
+
+func TestCheckpointStoreKeepsTargets(t *testing.T) {
+	store := newCheckpointStore(t)
+	if err := store.Observe(printingStatus("bracket.gcode", 120, 3)); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := loadCheckpoint(t, store)
+	if checkpoint == nil {
+		t.Fatal("no checkpoint written")
+	}
+	if checkpoint.HotendTemp != 215 || checkpoint.BedTemp != 60 {
+		t.Errorf("checkpoint has hotend %g, bed %g; want the targets 215 and 60",
+			checkpoint.HotendTemp, checkpoint.BedTemp)
+	}
+	if checkpoint.FileName != "bracket.gcode" || checkpoint.LineNumber != 120 || checkpoint.Layer != 3 || checkpoint.FanSpeed != 128 {
+		t.Errorf("checkpoint = %+v", checkpoint)
+	}
+
+	// A pause that switches the heaters off keeps the print's targets
+	paused := printingStatus("bracket.gcode", 140, 4)
+	paused.Status = "paused"
+	paused.TargetTemp, paused.BedTarget = 0, 0
+	if err := store.Observe(paused); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint := loadCheckpoint(t, store); checkpoint.LineNumber != 140 || checkpoint.HotendTemp != 215 || checkpoint.BedTemp != 60 {
+		t.Errorf("paused checkpoint = %+v, want line 140 at 215/60", checkpoint)
+	}
+}
+
+func TestCheckpointStoreHeaterList(t *testing.T) {
+	store := newCheckpointStore(t)
+	status := printingStatus("bracket.gcode", 120, 3)
+	status.TargetTemp, status.BedTarget = 0, 0
+	status.Heaters = []HeaterReading{
+		{Name: "tool0", Actual: 150, Target: 0},
+		{Name: "tool1", Actual: 238, Target: 240},
+		{Name: "bed", Actual: 79, Target: 80},
+		{Name: "chamber", Actual: 44, Target: 45},
+		{Name: "mcu", Actual: 45},
+	}
+	if err := store.Observe(status); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := loadCheckpoint(t, store)
+	if checkpoint.HotendTemp != 240 || checkpoint.BedTemp != 80 || checkpoint.ChamberTemp != 45 {
+		t.Errorf("checkpoint has hotend %g, bed %g, chamber %g; want 240, 80 and 45",
+			checkpoint.HotendTemp, checkpoint.BedTemp, checkpoint.ChamberTemp)
+	}
+	if len(checkpoint.ToolTemps) != 1 || checkpoint.ToolTemps[1] != 240 {
+		t.Errorf("checkpoint tool targets %v, want tool 1 at 240", checkpoint.ToolTemps)
+	}
+
+	// Both hotends heated, then paused with everything off
+	status.Heaters[0].Target = 250
+	status.CurrentLayer = 4
+	if err := store.Observe(status); err != nil {
+		t.Fatal(err)
+	}
+	status.Status = "paused"
+	status.CurrentLayer = 5
+	for i := range status.Heaters {
+		status.Heaters[i].Target = 0
+	}
+	if err := store.Observe(status); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint = loadCheckpoint(t, store)
+	if checkpoint.Layer != 5 || checkpoint.ChamberTemp != 45 || checkpoint.ToolTemps[0] != 250 || checkpoint.ToolTemps[1] != 240 {
+		t.Errorf("paused checkpoint = %+v, want both hotends and the chamber kept", checkpoint)
+	}
+}
+
+func TestCheckpointStoreWritesOnLayerChange(t *testing.T) {
+	store := newCheckpointStore(t)
+	for _, status := range []PrinterStatus{
+		printingStatus("bracket.gcode", 100, 3),
+		printingStatus("bracket.gcode", 110, 3), // Within the interval
+	} {
+		if err := store.Observe(status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if checkpoint := loadCheckpoint(t, store); checkpoint.LineNumber != 100 {
+		t.Errorf("checkpoint at line %d, want 100 until the interval passes", checkpoint.LineNumber)
+	}
+
+	if err := store.Observe(printingStatus("bracket.gcode", 130, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint := loadCheckpoint(t, store); checkpoint.LineNumber != 130 {
+		t.Errorf("checkpoint at line %d, want 130 after the layer change", checkpoint.LineNumber)
+	}
+
+	// Statuses without a position are not written
+	if err := store.Observe(printingStatus("bracket.gcode", 0, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint := loadCheckpoint(t, store); checkpoint.LineNumber != 130 {
+		t.Errorf("checkpoint at line %d, want 130", checkpoint.LineNumber)
+	}
+}
+
+func TestCheckpointStoreClears(t *testing.T) {
+	store := newCheckpointStore(t)
+	if err := store.Observe(printingStatus("bracket.gcode", 100, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Observe(PrinterStatus{Status: "complete"}); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint := loadCheckpoint(t, store); checkpoint != nil {
+		t.Errorf("a finished print left %+v", checkpoint)
+	}
+
+	// A checkpoint left by a power cut survives the idle status at start-up
+	if err := store.Observe(printingStatus("bracket.gcode", 100, 3)); err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewCheckpointStore(store.path)
+	if err := restarted.Observe(PrinterStatus{Status: "idle"}); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint := loadCheckpoint(t, restarted); checkpoint == nil || checkpoint.LineNumber != 100 {
+		t.Errorf("checkpoint after restart = %+v, want line 100", checkpoint)
+	}
+}
+
+func TestCheckpointStoreRejectsCorruptFile(t *testing.T) {
+	store := newCheckpointStore(t)
+	if err := os.WriteFile(store.path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(); err == nil {
+		t.Error("expected an error for a corrupt checkpoint")
+	}
+}
+
+func TestPlanRecovery(t *testing.T) {
+	model, _ := parseFixture(t, "power_loss_cold.gcode")
+	checkpoint := PrintCheckpoint{FileName: "cold.gcode", LineNumber: 12, Layer: 2, Z: 0.4, HotendTemp: 205, BedTemp: 55}
+
+	plan, err := PlanRecovery(model, checkpoint, DefaultResumeOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Warnings) != 0 {
+		t.Errorf("unexpected warnings %v", plan.Warnings)
+	}
+	start := strings.Join(plan.Resume.Start, "\n")
+	if !strings.Contains(start, "M190 S55") || !strings.Contains(start, "M109 S205") {
+		t.Errorf("a file without temperatures should use the checkpoint's targets:\n%s", start)
+	}
+
+	// Every heater the checkpoint recorded is reheated
+	checkpoint.ChamberTemp = 45
+	checkpoint.ToolTemps = map[int]float64{0: 250, 1: 240}
+	plan, err = PlanRecovery(model, checkpoint, DefaultResumeOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start = strings.Join(plan.Resume.Start, "\n")
+	for _, command := range []string{"M141 S45", "M104 T1 S240", "M109 S250"} {
+		if !strings.Contains(start, command) {
+			t.Errorf("recovery lacks %q:\n%s", command, start)
+		}
+	}
+
+	// The file's own temperatures win; a mismatched position is reported
+	model, _ = parseFixture(t, "power_loss.gcode")
+	checkpoint = PrintCheckpoint{FileName: "fixture.gcode", LineNumber: 19, Layer: 3, Z: 1.2, HotendTemp: 190, BedTemp: 50}
+	plan, err = PlanRecovery(model, checkpoint, DefaultResumeOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start = strings.Join(plan.Resume.Start, "\n")
+	if !strings.Contains(start, "M190 S60") || !strings.Contains(start, "M109 S215") {
+		t.Errorf("the file's temperatures should be used:\n%s", start)
+	}
+	if len(plan.Warnings) != 2 {
+		t.Errorf("expected Z and layer warnings, got %v", plan.Warnings)
+	}
+
+	if _, err := PlanRecovery(model, PrintCheckpoint{FileName: "fixture.gcode"}, DefaultResumeOptions); err == nil {
+		t.Error("a checkpoint without a position should be rejected")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// offerRecovery asks whether to resume a print that was interrupted, e.g.
+// by a power cut, when a checkpoint was left behind
+func (app *IntegratedApp) offerRecovery() {
+	checkpoint, err := app.checkpoints.Load()
+	if err != nil {
+		log.Printf("Failed to read print checkpoint: %v", err)
+		return
+	}
+	if checkpoint == nil {
+		return
+	}
+
+	// Still running, e.g. only the touchscreen restarted
+	state := strings.ToLower(app.currentStatus.Status)
+	if state == "printing" || state == "paused" {
+		return
+	}
+
+	message := widget.NewLabel(fmt.Sprintf(
+		"%s was interrupted at layer %d (Z %.2f mm) on %s.\n\n"+
+			"Recovery reheats the printer, homes X and Y only and continues from where it stopped. "+
+			"Make sure the print is still attached and nothing blocks the nozzle.",
+		checkpoint.FileName, checkpoint.Layer, checkpoint.Z, checkpoint.SavedAt.Format("Jan 2 15:04")))
+	message.Wrapping = fyne.TextWrapWord
+
+	recovery := dialog.NewCustomConfirm("Resume Interrupted Print?", "Recover", "Discard", message, func(recover bool) {
+		if recover {
+			app.prepareRecovery(*checkpoint)
+			return
+		}
+		dialog.ShowConfirm("Discard Print", "The interrupted print cannot be resumed afterwards. Discard it?", func(discard bool) {
+			if !discard {
+				app.offerRecovery()
+				return
+			}
+			if err := app.checkpoints.Clear(); err != nil {
+				app.showError("Recovery", fmt.Sprintf("Failed to discard checkpoint: %v", err))
+			}
+		}, app.window)
+	}, app.window)
+	recovery.Resize(fyne.NewSize(520, 300))
+	recovery.Show()
+}
+
+// prepareRecovery fetches and parses the interrupted file and plans resuming it
+func (app *IntegratedApp) prepareRecovery(checkpoint PrintCheckpoint) {
+	progressDialog := dialog.NewProgressInfinite("Recovery", "Reading "+checkpoint.FileName+"...", app.window)
+	progressDialog.Show()
+
+	go func() {
+		model, err := app.downloadModel(checkpoint.FileName)
+		if err != nil {
+			progressDialog.Hide()
+			app.showError("Recovery", fmt.Sprintf("Failed to read %s: %v", checkpoint.FileName, err))
+			return
+		}
+		plan, err := PlanRecovery(model, checkpoint, DefaultResumeOptions)
+		progressDialog.Hide()
+		if err != nil {
+			app.showError("Recovery", fmt.Sprintf("Cannot resume %s: %v", checkpoint.FileName, err))
+			return
+		}
+		app.confirmRecovery(model, plan)
+	}()
+}
+
+// downloadModel fetches and parses a file stored on the backend
+func (app *IntegratedApp) downloadModel(fileName string) (*GCodeModel, error) {
+	reader, err := app.backend.DownloadGCode(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return NewGCodeParser().ParseGCode(reader)
+}
+
+// confirmRecovery shows the resume sequence, lets the lift and prime be
+// adjusted and sends the resumed print to the backend
+func (app *IntegratedApp) confirmRecovery(model *GCodeModel, plan *RecoveryPlan) {
+	preview := widget.NewMultiLineEntry()
+	preview.SetText(strings.Join(plan.Resume.Start, "\n"))
+	preview.Disable()
+	preview.SetMinRowsVisible(10)
+
+	liftEntry := widget.NewEntry()
+	liftEntry.SetText(formatCoordinate(DefaultResumeOptions.ZLift))
+	primeEntry := widget.NewEntry()
+	primeEntry.SetText(formatCoordinate(DefaultResumeOptions.PrimeLength))
+
+	replan := func() (*RecoveryPlan, error) {
+		opts := DefaultResumeOptions
+		var err error
+		if opts.ZLift, err = strconv.ParseFloat(strings.TrimSpace(liftEntry.Text), 64); err != nil || opts.ZLift < 0 {
+			return nil, fmt.Errorf("invalid Z lift %q", liftEntry.Text)
+		}
+		if opts.PrimeLength, err = strconv.ParseFloat(strings.TrimSpace(primeEntry.Text), 64); err != nil || opts.PrimeLength < 0 {
+			return nil, fmt.Errorf("invalid prime length %q", primeEntry.Text)
+		}
+		return PlanRecovery(model, plan.Checkpoint, opts)
+	}
+	update := func(string) {
+		if updated, err := replan(); err == nil {
+			preview.SetText(strings.Join(updated.Resume.Start, "\n"))
+		}
+	}
+	liftEntry.OnChanged = update
+	primeEntry.OnChanged = update
+
+	summary := widget.NewLabel(fmt.Sprintf("Resume %s from line %d, layer %d of %d, Z %.2f mm",
+		plan.Checkpoint.FileName, plan.Resume.Line, plan.Resume.Layer+1, len(model.Layers), plan.Resume.State.Z))
+	summary.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(summary)
+	for _, warning := range plan.Warnings {
+		label := widget.NewLabel("⚠ " + warning)
+		label.Wrapping = fyne.TextWrapWord
+		content.Add(label)
+	}
+	content.Add(widget.NewForm(
+		widget.NewFormItem("Z lift (mm)", liftEntry),
+		widget.NewFormItem("Prime (mm)", primeEntry),
+	))
+	content.Add(widget.NewLabel("Start sequence:"))
+	content.Add(preview)
+
+	confirm := dialog.NewCustomConfirm("Power-Loss Recovery", "Resume Print", "Cancel", content, func(resume bool) {
+		if !resume {
+			return
+		}
+		final, err := replan()
+		if err != nil {
+			app.showError("Recovery", err.Error())
+			return
+		}
+		app.sendRecovery(model, final)
+	}, app.window)
+	confirm.Resize(fyne.NewSize(600, 560))
+	confirm.Show()
+}
+
+// sendRecovery uploads the resumed print as a derived file and starts it
+func (app *IntegratedApp) sendRecovery(model *GCodeModel, plan *RecoveryPlan) {
+	name := recoveryFileName(plan.Checkpoint.FileName)
+	progressDialog := dialog.NewProgressInfinite("Recovery", "Sending "+name+"...", app.window)
+	progressDialog.Show()
+
+	go func() {
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(WriteResumeGCode(model, plan.Resume, writer))
+		}()
+		err := app.backend.UploadStream(name, reader)
+		reader.Close()
+		started := false
+		if err == nil {
+			started, err = app.startPrint(name)
+		}
+		progressDialog.Hide()
+
+		if err != nil {
+			app.showError("Recovery", fmt.Sprintf("Failed to resume print: %v", err))
+			return
+		}
+		if !started {
+			// The checkpoint is kept until staff let the resumed print start
+			app.showInfo("Recovery", fmt.Sprintf("%s is waiting for staff to approve it", name))
+			return
+		}
+		if err := app.checkpoints.Clear(); err != nil {
+			log.Printf("Failed to clear print checkpoint: %v", err)
+		}
+		if app.logEntry != nil {
+			app.logEntry.SetText(app.logEntry.Text + fmt.Sprintf("\nResumed %s from layer %d as %s",
+				plan.Checkpoint.FileName, plan.Resume.Layer+1, name))
+		}
+		app.showInfo("Recovery", fmt.Sprintf("%s resumed from layer %d", plan.Checkpoint.FileName, plan.Resume.Layer+1))
+	}()
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock tells the scheduler the time, so it can be driven by a fake clock
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// scheduleMissedAfter is how late a print with a fixed start time may still
+// start, e.g. when the touchscreen was off or the printer busy at that time
+const scheduleMissedAfter = 15 * time.Minute
+
+// PrintWindow is a daily period in which prints may start, such as
+// off-peak electricity hours or the hours the lab is staffed
+type PrintWindow struct {
+	Start int            `json:"start"`          // Minutes after midnight
+	End   int            `json:"end"`            // Before Start for windows across midnight
+	Days  []time.Weekday `json:"days,omitempty"` // Days the window opens on; empty for every day
+}
+
+// ParsePrintWindow creates a window from "HH:MM" times
+func ParsePrintWindow(from, to string, days []time.Weekday) (*PrintWindow, error) {
+	start, err := parseClockTime(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClockTime(to)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("the window must not start and end at the same time")
+	}
+	return &PrintWindow{Start: start, End: end, Days: days}, nil
+}
+
+// parseClockTime converts "HH:MM" to minutes after midnight
+func parseClockTime(value string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) == 2 {
+		hours, errH := strconv.Atoi(parts[0])
+		minutes, errM := strconv.Atoi(parts[1])
+		if errH == nil && errM == nil && hours >= 0 && hours < 24 && minutes >= 0 && minutes < 60 {
+			return hours*60 + minutes, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %q, use HH:MM", value)
+}
+
+// String formats the window, e.g. "22:00-06:00 Mon Tue"
+func (w *PrintWindow) String() string {
+	text := fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+	for _, day := range w.Days {
+		text += " " + day.String()[:3]
+	}
+	return text
+}
+
+// opensOn reports whether the window opens on a day
+func (w *PrintWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// wallClock returns the time on day's date at minutes after midnight on the
+// clock. Adding minutes to midnight is wrong on days when daylight saving
+// time starts or ends.
+func wallClock(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, day.Location())
+}
+
+// openedAt returns when the window containing t opened, if t is inside it
+func (w *PrintWindow) openedAt(t time.Time) (time.Time, bool) {
+	minute := t.Hour()*60 + t.Minute()
+
+	opened := t
+	switch {
+	case w.Start < w.End && minute >= w.Start && minute < w.End:
+	case w.Start > w.End && minute >= w.Start:
+	case w.Start > w.End && minute < w.End:
+		// Opened the evening before
+		opened = wallClock(t, 0).AddDate(0, 0, -1)
+	default:
+		return time.Time{}, false
+	}
+	if !w.opensOn(opened.Weekday()) {
+		return time.Time{}, false
+	}
+	return wallClock(opened, w.Start), true
+}
+
+// Contains reports whether prints may start at t
+func (w *PrintWindow) Contains(t time.Time) bool {
+	_, ok := w.openedAt(t)
+	return ok
+}
+
+// NextOpen returns t if the window is open then, otherwise when it next opens
+func (w *PrintWindow) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	for day := 0; day <= 7; day++ {
+		open := wallClock(t.AddDate(0, 0, day), w.Start)
+		if open.After(t) && w.opensOn(open.Weekday()) {
+			return open
+		}
+	}
+	return t
+}
+
+// Closes returns when the window open at t closes
+func (w *PrintWindow) Closes(t time.Time) time.Time {
+	opened, ok := w.openedAt(t)
+	if !ok {
+		return t
+	}
+	if w.End < w.Start {
+		return wallClock(opened.AddDate(0, 0, 1), w.End)
+	}
+	return wallClock(opened, w.End)
+}
+
+// ScheduleState is the lifecycle state of a scheduled print
+type ScheduleState string
+
+const (
+	ScheduleWaiting    ScheduleState = "waiting"
+	SchedulePreheating ScheduleState = "preheating"
+	ScheduleStarted    ScheduleState = "started"
+	ScheduleMissed     ScheduleState = "missed"
+	ScheduleCancelled  ScheduleState = "cancelled"
+)
+
+// ScheduledPrint is a file to print at a set time or inside a window
+type ScheduledPrint struct {
+	ID             uint          `json:"id"`
+	FileID         uint          `json:"file_id,omitempty"`
+	Name           string        `json:"name"`
+	FileName       string        `json:"file_name"`
+	PrintTime      int           `json:"print_time,omitempty"` // Estimated seconds, for the timeline
+	StartAt        time.Time     `json:"start_at,omitempty"`   // Not before this time; zero for as soon as the window allows
+	Window         *PrintWindow  `json:"window,omitempty"`
+	PreheatMinutes int           `json:"preheat_minutes,omitempty"`
+	HotendTemp     float64       `json:"hotend_temp,omitempty"`
+	BedTemp        float64       `json:"bed_temp,omitempty"`
+	State          ScheduleState `json:"state"`
+	LastError      string        `json:"last_error,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	StartedAt      time.Time     `json:"started_at,omitempty"`
+}
+
+// Pending reports whether the print is still to start
+func (s *ScheduledPrint) Pending() bool {
+	return s.State == ScheduleWaiting || s.State == SchedulePreheating
+}
+
+// NextStart returns the earliest time from now the print may start
+func (s *ScheduledPrint) NextStart(now time.Time) time.Time {
+	start := now
+	if s.StartAt.After(start) {
+		start = s.StartAt
+	}
+	if s.Window != nil {
+		start = s.Window.NextOpen(start)
+	}
+	return start
+}
+
+// PreheatAt returns when heating should begin for a start at start
+func (s *ScheduledPrint) PreheatAt(start time.Time) time.Time {
+	return start.Add(-time.Duration(s.PreheatMinutes) * time.Minute)
+}
+
+// Describe summarises when the print will start
+func (s *ScheduledPrint) Describe() string {
+	parts := []string{}
+	if !s.StartAt.IsZero() {
+		parts = append(parts, "at "+s.StartAt.Format("Mon Jan 2 15:04"))
+	}
+	if s.Window != nil {
+		parts = append(parts, "within "+s.Window.String())
+	}
+	if s.PreheatMinutes > 0 {
+		parts = append(parts, fmt.Sprintf("preheat %d min", s.PreheatMinutes))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PrintScheduler starts scheduled prints when they are due and preheats the
+// printer ahead of them. Schedules are written to disk on every change.
+type PrintScheduler struct {
+	mu        sync.Mutex
+	clock     Clock
+	schedules []*ScheduledPrint
+	nextID    uint
+	path      string
+
+	onPreheat  func(ScheduledPrint) error
+	onStart    func(ScheduledPrint) error
+	onCoolDown func(ScheduledPrint)
+	onChange   func()
+
+	stop chan struct{}
+}
+
+// NewPrintScheduler creates a scheduler stored at path; an empty path keeps
+// the schedules in memory only
+func NewPrintScheduler(path string, clock Clock) *PrintScheduler {
+	return &PrintScheduler{clock: clock, path: path, nextID: 1}
+}
+
+// printScheduleFile is where the touchscreen keeps its schedules
+func printScheduleFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "print_schedule.json")
+}
+
+// SetPreheatHandler sets how the printer is preheated before a print
+func (s *PrintScheduler) SetPreheatHandler(handler func(ScheduledPrint) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onPreheat = handler
+}
+
+// SetStartHandler sets how a due print is started. An error leaves the
+// print waiting to be retried on the next check.
+func (s *PrintScheduler) SetStartHandler(handler func(ScheduledPrint) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onStart = handler
+}
+
+// SetCoolDownHandler sets how the heaters are switched off when a preheated
+// print is cancelled, missed, or has to wait for the next window
+func (s *PrintScheduler) SetCoolDownHandler(handler func(ScheduledPrint)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onCoolDown = handler
+}
+
+// SetOnChange sets a callback run after every change to the schedules
+func (s *PrintScheduler) SetOnChange(callback func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = callback
+}
+
+// Now returns the scheduler's time
+func (s *PrintScheduler) Now() time.Time {
+	return s.clock.Now()
+}
+
+// Load reads the stored schedules. A missing file leaves the list empty.
+func (s *PrintScheduler) Load() error {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored []ScheduledPrint
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid schedule file: %v", err)
+	}
+
+	s.mu.Lock()
+	s.schedules = nil
+	for i := range stored {
+		schedule := stored[i]
+		s.schedules = append(s.schedules, &schedule)
+		if schedule.ID >= s.nextID {
+			s.nextID = schedule.ID + 1
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Schedules returns copies of the schedules, pending ones first in the
+// order they will start
+func (s *PrintScheduler) Schedules() []ScheduledPrint {
+	s.mu.Lock()
+	now := s.clock.Now()
+	schedules := make([]ScheduledPrint, len(s.schedules))
+	for i, schedule := range s.schedules {
+		schedules[i] = *schedule
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(schedules, func(i, j int) bool {
+		a, b := &schedules[i], &schedules[j]
+		if a.Pending() != b.Pending() {
+			return a.Pending()
+		}
+		if a.Pending() {
+			return a.NextStart(now).Before(b.NextStart(now))
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+	return schedules
+}
+
+// Add schedules a print. It needs a start time, a window, or both.
+func (s *PrintScheduler) Add(scheduled ScheduledPrint) (ScheduledPrint, error) {
+	now := s.clock.Now()
+	switch {
+	case scheduled.StartAt.IsZero() && scheduled.Window == nil:
+		return ScheduledPrint{}, fmt.Errorf("choose a start time or a window")
+	case !scheduled.StartAt.IsZero() && scheduled.StartAt.Before(now.Add(-time.Minute)):
+		return ScheduledPrint{}, fmt.Errorf("the start time has already passed")
+	case scheduled.PreheatMinutes < 0 || scheduled.HotendTemp < 0 || scheduled.BedTemp < 0:
+		return ScheduledPrint{}, fmt.Errorf("preheat time and temperatures cannot be negative")
+	}
+
+	s.mu.Lock()
+	scheduled.ID = s.nextID
+	s.nextID++
+	scheduled.State = ScheduleWaiting
+	scheduled.CreatedAt = now
+	s.schedules = append(s.schedules, &scheduled)
+	s.mu.Unlock()
+
+	return scheduled, s.changed()
+}
+
+// Cancel stops a pending print from starting, switching off the heaters if
+// it was preheating
+func (s *PrintScheduler) Cancel(id uint) error {
+	s.mu.Lock()
+	schedule := s.find(id)
+	if schedule == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("schedule not found")
+	}
+	if !schedule.Pending() {
+		s.mu.Unlock()
+		return fmt.Errorf("%s has already %s", schedule.Name, schedule.State)
+	}
+	before := *schedule
+	schedule.State = ScheduleCancelled
+	coolDown := s.onCoolDown
+	s.mu.Unlock()
+
+	if before.State == SchedulePreheating && coolDown != nil {
+		coolDown(before)
+	}
+	return s.changed()
+}
+
+// ClearFinished removes every schedule that is no longer pending
+func (s *PrintScheduler) ClearFinished() error {
+	s.mu.Lock()
+	kept := s.schedules[:0]
+	for _, schedule := range s.schedules {
+		if schedule.Pending() {
+			kept = append(kept, schedule)
+		}
+	}
+	s.schedules = kept
+	s.mu.Unlock()
+	return s.changed()
+}
+
+// Tick preheats and starts whatever is due at the clock's current time. At
+// most one print is started per tick, as the printer is busy afterwards.
+func (s *PrintScheduler) Tick() {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	pending := []ScheduledPrint{}
+	for _, schedule := range s.schedules {
+		if schedule.Pending() {
+			pending = append(pending, *schedule)
+		}
+	}
+	preheat, start, coolDown := s.onPreheat, s.onStart, s.onCoolDown
+	s.mu.Unlock()
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].NextStart(now).Before(pending[j].NextStart(now))
+	})
+
+	changed := false
+	started := false
+	cooled := func(schedule ScheduledPrint) {
+		if schedule.State == SchedulePreheating && coolDown != nil {
+			coolDown(schedule)
+		}
+	}
+	for _, schedule := range pending {
+		next := schedule.NextStart(now)
+		switch {
+		case schedule.Window == nil && now.Sub(schedule.StartAt) > scheduleMissedAfter:
+			if s.update(schedule.ID, func(p *ScheduledPrint) {
+				p.State = ScheduleMissed
+			}) {
+				cooled(schedule)
+				changed = true
+			}
+
+		case !started && !now.Before(next):
+			err := fmt.Errorf("no start handler")
+			if start != nil {
+				err = start(schedule)
+			}
+			if err == nil {
+				started = true
+			}
+			changed = s.update(schedule.ID, func(p *ScheduledPrint) {
+				if err != nil {
+					p.LastError = err.Error()
+					return
+				}
+				p.State = ScheduleStarted
+				p.StartedAt = now
+				p.LastError = ""
+			}) || changed
+
+		case schedule.State == SchedulePreheating && now.Before(schedule.PreheatAt(next)):
+			// The window closed before the print could start, so it waits
+			// for the next one without keeping the heaters on
+			if s.update(schedule.ID, func(p *ScheduledPrint) {
+				p.State = ScheduleWaiting
+			}) {
+				cooled(schedule)
+				changed = true
+			}
+
+		case schedule.State == ScheduleWaiting && schedule.PreheatMinutes > 0 && !now.Before(schedule.PreheatAt(next)):
+			err := fmt.Errorf("no preheat handler")
+			if preheat != nil {
+				err = preheat(schedule)
+			}
+			changed = s.update(schedule.ID, func(p *ScheduledPrint) {
+				if err != nil {
+					p.LastError = err.Error()
+					return
+				}
+				p.State = SchedulePreheating
+				p.LastError = ""
+			}) || changed
+		}
+	}
+
+	if changed {
+		s.changed()
+	}
+}
+
+// Start checks the schedules every interval until Stop is called
+func (s *PrintScheduler) Start(interval time.Duration) {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	s.stop = stop
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		s.Tick()
+		for {
+			select {
+			case <-ticker.C:
+				s.Tick()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic checks
+func (s *PrintScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// update changes a schedule that is still pending, reporting whether it did
+func (s *PrintScheduler) update(id uint, change func(*ScheduledPrint)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule := s.find(id)
+	if schedule == nil || !schedule.Pending() {
+		return false
+	}
+	change(schedule)
+	return true
+}
+
+// find returns the schedule with the given ID, or nil
+func (s *PrintScheduler) find(id uint) *ScheduledPrint {
+	for _, schedule := range s.schedules {
+		if schedule.ID == id {
+			return schedule
+		}
+	}
+	return nil
+}
+
+// changed saves the schedules and notifies the UI
+func (s *PrintScheduler) changed() error {
+	s.mu.Lock()
+	callback := s.onChange
+	s.mu.Unlock()
+
+	err := s.save()
+	if callback != nil {
+		callback()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save schedules: %v", err)
+	}
+	return nil
+}
+
+// save writes the schedules to disk
+func (s *PrintScheduler) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	stored := make([]ScheduledPrint, len(s.schedules))
+	for i, schedule := range s.schedules {
+		stored[i] = *schedule
+	}
+	s.mu.Unlock()
+
+	jsonData, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, jsonData, 0600)
+}
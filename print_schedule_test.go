@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// fakeClock is a Clock the test moves by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// scheduleRecorder stands in for the printer behind the scheduler handlers
+type scheduleRecorder struct {
+	busy     bool
+	preheats []string
+	starts   []string
+	coolings []string
+}
+
+func (r *scheduleRecorder) attach(s *PrintScheduler) {
+	s.SetPreheatHandler(func(p ScheduledPrint) error {
+		if r.busy {
+			return fmt.Errorf("printer is busy")
+		}
+		r.preheats = append(r.preheats, p.Name)
+		return nil
+	})
+	s.SetStartHandler(func(p ScheduledPrint) error {
+		if r.busy {
+			return fmt.Errorf("printer is busy")
+		}
+		r.starts = append(r.starts, p.Name)
+		r.busy = true
+		return nil
+	})
+	s.SetCoolDownHandler(func(p ScheduledPrint) {
+		r.coolings = append(r.coolings, p.Name)
+	})
+}
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return location
+}
+
+func scheduleState(t *testing.T, s *PrintScheduler, id uint) ScheduledPrint {
+	t.Helper()
+	for _, schedule := range s.Schedules() {
+		if schedule.ID == id {
+			return schedule
+		}
+	}
+	t.Fatalf("schedule %d not found", id)
+	return ScheduledPrint{}
+}
+
+func TestPrintWindow(t *testing.T) {
+	local := time.UTC
+	at := func(day, hour, minute int) time.Time {
+		// June 2024 starts on a Saturday
+		return time.Date(2024, 6, day, hour, minute, 0, 0, local)
+	}
+	night := &PrintWindow{Start: 22 * 60, End: 6 * 60}
+	weekdays := &PrintWindow{Start: 9 * 60, End: 17 * 60, Days: []time.Weekday{time.Monday, time.Tuesday}}
+
+	tests := []struct {
+		name   string
+		window *PrintWindow
+		t      time.Time
+		open   bool
+		next   time.Time
+		closes time.Time
+	}{
+		{"night before it opens", night, at(3, 21, 59), false, at(3, 22, 0), time.Time{}},
+		{"night in the evening", night, at(3, 23, 0), true, at(3, 23, 0), at(4, 6, 0)},
+		{"night after midnight", night, at(4, 5, 59), true, at(4, 5, 59), at(4, 6, 0)},
+		{"night at its end", night, at(4, 6, 0), false, at(4, 22, 0), time.Time{}},
+		{"weekday window on Monday", weekdays, at(3, 10, 0), true, at(3, 10, 0), at(3, 17, 0)},
+		{"weekday window on Saturday", weekdays, at(1, 10, 0), false, at(3, 9, 0), time.Time{}},
+		{"weekday window after Tuesday", weekdays, at(4, 17, 0), false, at(10, 9, 0), time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.window.Contains(test.t); got != test.open {
+				t.Errorf("Contains = %v, want %v", got, test.open)
+			}
+			if got := test.window.NextOpen(test.t); !got.Equal(test.next) {
+				t.Errorf("NextOpen = %v, want %v", got, test.next)
+			}
+			if test.open {
+				if got := test.window.Closes(test.t); !got.Equal(test.closes) {
+					t.Errorf("Closes = %v, want %v", got, test.closes)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintWindowDaylightSaving(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, berlin)
+	}
+	night := &PrintWindow{Start: 22 * 60, End: 6 * 60}
+	early := &PrintWindow{Start: 4 * 60, End: 8 * 60}
+
+	tests := []struct {
+		name   string
+		window *PrintWindow
+		t      time.Time
+		opened time.Time
+		closes time.Time
+	}{
+		// Clocks go forward at 02:00 on 31 March, so that day has 23 hours
+		{"evening of the short day", night, at(time.March, 31, 22, 30), at(time.March, 31, 22, 0), at(time.April, 1, 6, 0)},
+		{"night into the short day", night, at(time.March, 31, 1, 0), at(time.March, 30, 22, 0), at(time.March, 31, 6, 0)},
+		{"morning of the short day", early, at(time.March, 31, 4, 30), at(time.March, 31, 4, 0), at(time.March, 31, 8, 0)},
+		// Clocks go back at 03:00 on 27 October, so that day has 25 hours
+		{"evening of the long day", night, at(time.October, 27, 22, 30), at(time.October, 27, 22, 0), at(time.October, 28, 6, 0)},
+		{"night into the long day", night, at(time.October, 27, 5, 0), at(time.October, 26, 22, 0), at(time.October, 27, 6, 0)},
+		{"morning of the long day", early, at(time.October, 27, 4, 30), at(time.October, 27, 4, 0), at(time.October, 27, 8, 0)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opened, ok := test.window.openedAt(test.t)
+			if !ok {
+				t.Fatalf("window not open at %v", test.t)
+			}
+			if !opened.Equal(test.opened) {
+				t.Errorf("opened %v, want %v", opened, test.opened)
+			}
+			if got := test.window.Closes(test.t); !got.Equal(test.closes) {
+				t.Errorf("closes %v, want %v", got, test.closes)
+			}
+		})
+	}
+
+	// Just before the window opens on the short day it is still closed and
+	// opens at 22:00 on the clock
+	before := at(time.March, 31, 21, 30)
+	if night.Contains(before) {
+		t.Errorf("window open at %v", before)
+	}
+	if got := night.NextOpen(before); !got.Equal(at(time.March, 31, 22, 0)) {
+		t.Errorf("NextOpen = %v, want 22:00", got)
+	}
+}
+
+func TestSchedulerPreheatsThenStarts(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)}
+	s := NewPrintScheduler("", clock)
+	printer := &scheduleRecorder{}
+	printer.attach(s)
+
+	added, err := s.Add(ScheduledPrint{
+		Name: "bracket", StartAt: clock.now.Add(time.Hour),
+		PreheatMinutes: 10, HotendTemp: 215, BedTemp: 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.advance(49 * time.Minute)
+	s.Tick()
+	if len(printer.preheats) != 0 {
+		t.Errorf("preheated %d minutes early", 60-49)
+	}
+
+	clock.advance(time.Minute)
+	s.Tick()
+	if got := scheduleState(t, s, added.ID).State; got != SchedulePreheating || len(printer.preheats) != 1 {
+		t.Errorf("state %s after %d preheats, want preheating after 1", got, len(printer.preheats))
+	}
+
+	clock.advance(10 * time.Minute)
+	s.Tick()
+	started := scheduleState(t, s, added.ID)
+	if started.State != ScheduleStarted || !started.StartedAt.Equal(clock.now) {
+		t.Errorf("state %s at %v, want started at %v", started.State, started.StartedAt, clock.now)
+	}
+	if len(printer.coolings) != 0 {
+		t.Errorf("cooled down a started print")
+	}
+}
+
+func TestSchedulerStartsOnePrintPerTick(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)}
+	s := NewPrintScheduler("", clock)
+	printer := &scheduleRecorder{}
+	printer.attach(s)
+
+	first, _ := s.Add(ScheduledPrint{Name: "first", StartAt: clock.now.Add(time.Minute)})
+	second, _ := s.Add(ScheduledPrint{Name: "second", StartAt: clock.now.Add(2 * time.Minute)})
+
+	clock.advance(5 * time.Minute)
+	s.Tick()
+	if len(printer.starts) != 1 || printer.starts[0] != "first" {
+		t.Fatalf("started %v, want [first]", printer.starts)
+	}
+	waiting := scheduleState(t, s, second.ID)
+	if waiting.State != ScheduleWaiting || waiting.LastError != "" {
+		t.Errorf("second is %s (%q), want waiting", waiting.State, waiting.LastError)
+	}
+
+	// The printer is now busy, so the second print waits with the reason
+	s.Tick()
+	waiting = scheduleState(t, s, second.ID)
+	if waiting.State != ScheduleWaiting || waiting.LastError != "printer is busy" {
+		t.Errorf("second is %s (%q), want waiting on a busy printer", waiting.State, waiting.LastError)
+	}
+
+	printer.busy = false
+	s.Tick()
+	if got := scheduleState(t, s, second.ID).State; got != ScheduleStarted {
+		t.Errorf("second is %s once the printer is free, want started", got)
+	}
+	if got := scheduleState(t, s, first.ID).State; got != ScheduleStarted {
+		t.Errorf("first is %s, want started", got)
+	}
+}
+
+func TestSchedulerMissedPreheatCoolsDown(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)}
+	s := NewPrintScheduler("", clock)
+	printer := &scheduleRecorder{}
+	printer.attach(s)
+
+	added, _ := s.Add(ScheduledPrint{Name: "bracket", StartAt: clock.now.Add(30 * time.Minute), PreheatMinutes: 30, BedTemp: 60})
+	s.Tick()
+	if got := scheduleState(t, s, added.ID).State; got != SchedulePreheating {
+		t.Fatalf("state %s, want preheating", got)
+	}
+
+	// Someone starts a print by hand, so the scheduled one never can
+	printer.busy = true
+	clock.advance(40 * time.Minute)
+	s.Tick()
+	if got := scheduleState(t, s, added.ID).State; got != SchedulePreheating {
+		t.Errorf("state %s while the printer is busy, want preheating", got)
+	}
+	clock.advance(10 * time.Minute)
+	s.Tick()
+	if got := scheduleState(t, s, added.ID).State; got != ScheduleMissed {
+		t.Errorf("state %s after %v, want missed", got, scheduleMissedAfter)
+	}
+	if len(printer.coolings) != 1 {
+		t.Errorf("cooled down %d times, want once", len(printer.coolings))
+	}
+}
+
+func TestSchedulerMissedWithoutPreheatLeavesHeaters(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)}
+	s := NewPrintScheduler("", clock)
+	printer := &scheduleRecorder{busy: true}
+	printer.attach(s)
+
+	added, _ := s.Add(ScheduledPrint{Name: "bracket", StartAt: clock.now})
+	clock.advance(scheduleMissedAfter + time.Minute)
+	s.Tick()
+	if got := scheduleState(t, s, added.ID).State; got != ScheduleMissed {
+		t.Errorf("state %s, want missed", got)
+	}
+	if len(printer.coolings) != 0 {
+		t.Errorf("cooled down a print that never preheated")
+	}
+}
+
+func TestSchedulerCancelCoolsDownPreheat(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)}
+	s := NewPrintScheduler("", clock)
+	printer := &scheduleRecorder{}
+	printer.attach(s)
+
+	waiting, _ := s.Add(ScheduledPrint{Name: "later", StartAt: clock.now.Add(5 * time.Hour), PreheatMinutes: 10})
+	preheating, _ := s.Add(ScheduledPrint{Name: "soon", StartAt: clock.now.Add(5 * time.Minute), PreheatMinutes: 10, HotendTemp: 215})
+	s.Tick()
+
+	if err := s.Cancel(waiting.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(printer.coolings) != 0 {
+		t.Errorf("cooled down for a print that was not preheating")
+	}
+	if err := s.Cancel(preheating.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(printer.coolings) != 1 || printer.coolings[0] != "soon" {
+		t.Errorf("cooled down for %v, want [soon]", printer.coolings)
+	}
+	if err := s.Cancel(preheating.ID); err == nil {
+		t.Error("cancelled a print twice")
+	}
+}
+
+func TestSchedulerWindowClosesWhilePreheating(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 3, 21, 0, 0, 0, time.UTC)}
+	s := NewPrintScheduler("", clock)
+	printer := &scheduleRecorder{}
+	printer.attach(s)
+
+	window := &PrintWindow{Start: 22 * 60, End: 23 * 60}
+	added, _ := s.Add(ScheduledPrint{Name: "overnight", Window: window, PreheatMinutes: 15, BedTemp: 60})
+
+	clock.advance(45 * time.Minute)
+	s.Tick()
+	if got := scheduleState(t, s, added.ID).State; got != SchedulePreheating {
+		t.Fatalf("state %s, want preheating", got)
+	}
+
+	// Busy for the whole window
+	printer.busy = true
+	clock.advance(30 * time.Minute)
+	s.Tick()
+	clock.advance(time.Hour)
+	s.Tick()
+	skipped := scheduleState(t, s, added.ID)
+	if skipped.State != ScheduleWaiting {
+		t.Errorf("state %s after the window closed, want waiting", skipped.State)
+	}
+	if len(printer.coolings) != 1 {
+		t.Errorf("cooled down %d times, want once", len(printer.coolings))
+	}
+	if next := skipped.NextStart(clock.now); !next.Equal(time.Date(2024, 6, 4, 22, 0, 0, 0, time.UTC)) {
+		t.Errorf("next start %v, want the next evening", next)
+	}
+
+	// It preheats again ahead of the next window
+	printer.busy = false
+	clock.now = time.Date(2024, 6, 4, 21, 45, 0, 0, time.UTC)
+	s.Tick()
+	if got := scheduleState(t, s, added.ID).State; got != SchedulePreheating || len(printer.preheats) != 2 {
+		t.Errorf("state %s after %d preheats, want preheating again", got, len(printer.preheats))
+	}
+}
+
+func TestSchedulerRejectsBadSchedules(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)}
+	s := NewPrintScheduler("", clock)
+
+	for _, schedule := range []ScheduledPrint{
+		{Name: "no time"},
+		{Name: "past", StartAt: clock.now.Add(-time.Hour)},
+		{Name: "negative", StartAt: clock.now.Add(time.Hour), PreheatMinutes: -5},
+	} {
+		if _, err := s.Add(schedule); err == nil {
+			t.Errorf("%s: expected an error", schedule.Name)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// scheduleStartLayout is the format of the start time entry
+const scheduleStartLayout = "2006-01-02 15:04"
+
+// Timeline range and size
+const (
+	timelineBefore  = time.Hour
+	timelineSpan    = 24 * time.Hour
+	timelineMaxRows = 5
+	timelineRowSize = 22
+)
+
+// scheduleWeekdays are the day choices of a window, Monday first
+var scheduleWeekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+// ScheduleTimeline shows the next day of scheduled prints, their preheat
+// lead and the windows they wait for
+type ScheduleTimeline struct {
+	widget.BaseWidget
+
+	scheduler *PrintScheduler
+	width     float32
+	height    float32
+
+	windowColor  color.Color
+	preheatColor color.Color
+	printColor   color.Color
+	nowColor     color.Color
+	gridColor    color.Color
+	textColor    color.Color
+}
+
+// NewScheduleTimeline creates a timeline of a scheduler's prints
+func NewScheduleTimeline(scheduler *PrintScheduler) *ScheduleTimeline {
+	timeline := &ScheduleTimeline{
+		scheduler:    scheduler,
+		windowColor:  color.NRGBA{R: 52, G: 199, B: 89, A: 48},    // Faint green
+		preheatColor: color.NRGBA{R: 255, G: 149, B: 0, A: 255},   // Orange
+		printColor:   color.NRGBA{R: 0, G: 122, B: 255, A: 255},   // Blue
+		nowColor:     color.NRGBA{R: 255, G: 69, B: 58, A: 255},   // Red
+		gridColor:    color.NRGBA{R: 200, G: 200, B: 200, A: 128}, // Light gray
+		textColor:    color.NRGBA{R: 28, G: 28, B: 30, A: 255},    // Dark
+	}
+	timeline.ExtendBaseWidget(timeline)
+	return timeline
+}
+
+// CreateRenderer creates the timeline renderer
+func (t *ScheduleTimeline) CreateRenderer() fyne.WidgetRenderer {
+	return &scheduleTimelineRenderer{timeline: t}
+}
+
+// scheduleTimelineRenderer renders the schedule timeline
+type scheduleTimelineRenderer struct {
+	timeline *ScheduleTimeline
+}
+
+func (r *scheduleTimelineRenderer) Layout(size fyne.Size) {
+	r.timeline.width = size.Width
+	r.timeline.height = size.Height
+}
+
+func (r *scheduleTimelineRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(400, 30+timelineMaxRows*timelineRowSize)
+}
+
+func (r *scheduleTimelineRenderer) Refresh() {
+	// Objects are rebuilt on every draw
+}
+
+func (r *scheduleTimelineRenderer) Destroy() {
+	// Nothing to destroy
+}
+
+func (r *scheduleTimelineRenderer) Objects() []fyne.CanvasObject {
+	t := r.timeline
+	now := t.scheduler.Now()
+	from := now.Add(-timelineBefore)
+	to := from.Add(timelineSpan)
+
+	x := func(at time.Time) float32 {
+		if at.Before(from) {
+			at = from
+		}
+		if at.After(to) {
+			at = to
+		}
+		return float32(at.Sub(from)) / float32(timelineSpan) * t.width
+	}
+
+	objects := []fyne.CanvasObject{}
+
+	// Hour grid every three hours
+	first := from.Truncate(time.Hour).Add(time.Hour)
+	for at := first; at.Before(to); at = at.Add(time.Hour) {
+		if at.Hour()%3 != 0 {
+			continue
+		}
+		line := canvas.NewLine(t.gridColor)
+		line.Position1 = fyne.NewPos(x(at), 0)
+		line.Position2 = fyne.NewPos(x(at), t.height-16)
+		objects = append(objects, line)
+
+		label := canvas.NewText(at.Format("15:04"), t.textColor)
+		label.TextSize = 10
+		label.Move(fyne.NewPos(x(at)-12, t.height-14))
+		objects = append(objects, label)
+	}
+
+	rows := []ScheduledPrint{}
+	for _, schedule := range t.scheduler.Schedules() {
+		running := schedule.State == ScheduleStarted &&
+			schedule.StartedAt.Add(time.Duration(schedule.PrintTime)*time.Second).After(from)
+		if (schedule.Pending() || running) && len(rows) < timelineMaxRows {
+			rows = append(rows, schedule)
+		}
+	}
+
+	if len(rows) == 0 {
+		text := canvas.NewText("No prints scheduled", t.textColor)
+		text.Move(fyne.NewPos(t.width/2-60, (t.height-16)/2-8))
+		objects = append(objects, text)
+	}
+
+	for i, schedule := range rows {
+		y := float32(4 + i*timelineRowSize)
+		barHeight := float32(timelineRowSize - 6)
+
+		start := schedule.StartedAt
+		if schedule.Pending() {
+			start = schedule.NextStart(now)
+		}
+		end := start.Add(time.Duration(schedule.PrintTime) * time.Second)
+		if end.Sub(start) < 15*time.Minute {
+			end = start.Add(15 * time.Minute) // Unknown print time
+		}
+
+		// Window the print waits for
+		if schedule.Pending() && schedule.Window != nil {
+			for open := schedule.Window.NextOpen(from); open.Before(to); {
+				closes := schedule.Window.Closes(open)
+				objects = append(objects, timelineRect(t.windowColor, x(open), y-2, x(closes)-x(open), barHeight+4))
+				next := schedule.Window.NextOpen(closes.Add(time.Minute))
+				if !next.After(open) {
+					break
+				}
+				open = next
+			}
+		}
+
+		if schedule.Pending() && schedule.PreheatMinutes > 0 {
+			preheat := schedule.PreheatAt(start)
+			objects = append(objects, timelineRect(t.preheatColor, x(preheat), y, x(start)-x(preheat), barHeight))
+		}
+		if start.Before(to) {
+			objects = append(objects, timelineRect(t.printColor, x(start), y, x(end)-x(start), barHeight))
+
+			label := canvas.NewText(fmt.Sprintf("%s %s", start.Format("15:04"), schedule.Name), t.textColor)
+			label.TextSize = 11
+			label.Move(fyne.NewPos(x(start)+4, y+1))
+			objects = append(objects, label)
+		}
+	}
+
+	nowLine := canvas.NewLine(t.nowColor)
+	nowLine.StrokeWidth = 2
+	nowLine.Position1 = fyne.NewPos(x(now), 0)
+	nowLine.Position2 = fyne.NewPos(x(now), t.height-16)
+	objects = append(objects, nowLine)
+
+	return objects
+}
+
+// timelineRect creates a positioned bar of the timeline
+func timelineRect(fill color.Color, x, y, width, height float32) *canvas.Rectangle {
+	rect := canvas.NewRectangle(fill)
+	rect.Move(fyne.NewPos(x, y))
+	rect.Resize(fyne.NewSize(width, height))
+	return rect
+}
+
+// showScheduleDialog asks when to print a file and adds it to the scheduler.
+// base carries the file; its timing fields are filled from the form. Only
+// users who may approve prints may schedule them.
+func showScheduleDialog(window fyne.Window, scheduler *PrintScheduler, auth *AuthManager, base ScheduledPrint) {
+	if err := checkApprover(auth, "schedule prints"); err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	startEntry := widget.NewEntry()
+	startEntry.SetPlaceHolder(scheduler.Now().Add(time.Hour).Format(scheduleStartLayout))
+
+	windowFrom := widget.NewEntry()
+	windowFrom.SetPlaceHolder("22:00")
+	windowTo := widget.NewEntry()
+	windowTo.SetPlaceHolder("06:00")
+
+	dayNames := make([]string, len(scheduleWeekdays))
+	for i, day := range scheduleWeekdays {
+		dayNames[i] = day.String()[:3]
+	}
+	days := widget.NewCheckGroup(dayNames, nil)
+	days.Horizontal = true
+
+	preheatEntry := widget.NewEntry()
+	preheatEntry.SetText("0")
+	hotendEntry := widget.NewEntry()
+	hotendEntry.SetPlaceHolder("e.g. 210")
+	bedEntry := widget.NewEntry()
+	bedEntry.SetPlaceHolder("e.g. 60")
+
+	dialog.ShowForm("Schedule "+base.Name, "Schedule", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Start at", startEntry),
+		widget.NewFormItem("Window from", windowFrom),
+		widget.NewFormItem("Window to", windowTo),
+		widget.NewFormItem("Window days", days),
+		widget.NewFormItem("Preheat (min)", preheatEntry),
+		widget.NewFormItem("Hotend °C", hotendEntry),
+		widget.NewFormItem("Bed °C", bedEntry),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		scheduled := base
+		var err error
+		if text := strings.TrimSpace(startEntry.Text); text != "" {
+			scheduled.StartAt, err = time.ParseInLocation(scheduleStartLayout, text, time.Local)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("invalid start time %q, use %s", text, scheduleStartLayout), window)
+				return
+			}
+		}
+
+		if strings.TrimSpace(windowFrom.Text) != "" || strings.TrimSpace(windowTo.Text) != "" {
+			selected := []time.Weekday{}
+			for i, name := range dayNames {
+				for _, chosen := range days.Selected {
+					if chosen == name {
+						selected = append(selected, scheduleWeekdays[i])
+					}
+				}
+			}
+			scheduled.Window, err = ParsePrintWindow(windowFrom.Text, windowTo.Text, selected)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+		}
+
+		for _, field := range []struct {
+			entry *widget.Entry
+			name  string
+			value *float64
+		}{
+			{hotendEntry, "hotend temperature", &scheduled.HotendTemp},
+			{bedEntry, "bed temperature", &scheduled.BedTemp},
+		} {
+			if text := strings.TrimSpace(field.entry.Text); text != "" {
+				if *field.value, err = strconv.ParseFloat(text, 64); err != nil {
+					dialog.ShowError(fmt.Errorf("invalid %s %q", field.name, text), window)
+					return
+				}
+			}
+		}
+		if text := strings.TrimSpace(preheatEntry.Text); text != "" {
+			if scheduled.PreheatMinutes, err = strconv.Atoi(text); err != nil {
+				dialog.ShowError(fmt.Errorf("invalid preheat time %q", text), window)
+				return
+			}
+		}
+		if scheduled.PreheatMinutes > 0 && scheduled.HotendTemp == 0 && scheduled.BedTemp == 0 {
+			dialog.ShowError(fmt.Errorf("enter a hotend or bed temperature to preheat to"), window)
+			return
+		}
+
+		added, err := SchedulePrint(scheduler, auth, scheduled)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		next := added.NextStart(scheduler.Now())
+		dialog.ShowInformation("Scheduled", fmt.Sprintf("%s will start %s", added.Name, next.Format("Mon Jan 2 15:04")), window)
+	}, window)
+}
+
+// showSchedulesDialog lists the scheduled prints
+func showSchedulesDialog(window fyne.Window, scheduler *PrintScheduler) {
+	schedules := scheduler.Schedules()
+
+	var list *widget.List
+	list = widget.NewList(
+		func() int { return len(schedules) },
+		func() fyne.CanvasObject {
+			return container.NewHBox(
+				container.NewVBox(
+					widget.NewLabel("Job name"),
+					widget.NewLabel("Details"),
+				),
+				layout.NewSpacer(),
+				widget.NewButton("Cancel", nil),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(schedules) {
+				return
+			}
+
+			schedule := schedules[id]
+			hbox := obj.(*fyne.Container)
+
+			details := schedule.Describe()
+			switch {
+			case schedule.Pending():
+				details = fmt.Sprintf("%s | Next start %s", details, schedule.NextStart(scheduler.Now()).Format("Mon 15:04"))
+			case schedule.State == ScheduleStarted:
+				details = fmt.Sprintf("%s | Started %s", details, schedule.StartedAt.Format("Mon Jan 2 15:04"))
+			default:
+				details = fmt.Sprintf("%s | %s", details, capitalize(string(schedule.State)))
+			}
+			if schedule.LastError != "" {
+				details += " | " + schedule.LastError
+			}
+
+			info := hbox.Objects[0].(*fyne.Container)
+			info.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s (%s)", schedule.Name, schedule.State))
+			info.Objects[1].(*widget.Label).SetText(details)
+
+			cancelBtn := hbox.Objects[2].(*widget.Button)
+			if schedule.Pending() {
+				cancelBtn.Enable()
+			} else {
+				cancelBtn.Disable()
+			}
+			cancelBtn.OnTapped = func() {
+				if err := scheduler.Cancel(schedule.ID); err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				schedules = scheduler.Schedules()
+				list.Refresh()
+			}
+		},
+	)
+
+	clearBtn := widget.NewButton("Clear Finished", func() {
+		if err := scheduler.ClearFinished(); err != nil {
+			dialog.ShowError(err, window)
+		}
+		schedules = scheduler.Schedules()
+		list.Refresh()
+	})
+
+	content := container.NewBorder(nil, clearBtn, nil, nil, list)
+	schedulesDialog := dialog.NewCustom("Scheduled Prints", "Close", content, window)
+	schedulesDialog.Resize(fyne.NewSize(680, 420))
+	schedulesDialog.Show()
+}
+
+// scheduleIdleStatuses are the printer states a scheduled print may start
+// or preheat in
+var scheduleIdleStatuses = map[string]bool{
+	"idle":        true,
+	"ready":       true,
+	"standby":     true,
+	"operational": true,
+}
+
+// printerIdle asks the backend whether the printer is free for a scheduled
+// print, returning an error saying why not
+func printerIdle(backend *BackendClient) error {
+	status, err := backend.GetPrinterStatus()
+	if err != nil {
+		return err
+	}
+	if !scheduleIdleStatuses[strings.ToLower(status.Status)] {
+		return fmt.Errorf("printer is busy (%s)", status.Status)
+	}
+	return nil
+}
+
+// startScheduledFile starts a due print through the backend once the
+// printer is idle
+func startScheduledFile(backend *BackendClient, schedule ScheduledPrint) error {
+	if err := printerIdle(backend); err != nil {
+		return err
+	}
+	return backend.StartPrint(schedule.FileName)
+}
+
+// preheatScheduled heats the printer ahead of a scheduled print. A busy
+// printer is left alone and the preheat is retried on the next check.
+func preheatScheduled(backend *BackendClient, schedule ScheduledPrint) error {
+	if err := printerIdle(backend); err != nil {
+		return err
+	}
+	if schedule.BedTemp > 0 {
+		if err := backend.SetTemperature("bed", schedule.BedTemp); err != nil {
+			return err
+		}
+	}
+	if schedule.HotendTemp > 0 {
+		if err := backend.SetTemperature("hotend", schedule.HotendTemp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// coolDownScheduled switches off the heaters a scheduled print preheated,
+// unless something else has started printing since
+func coolDownScheduled(backend *BackendClient, schedule ScheduledPrint) {
+	if err := printerIdle(backend); err != nil {
+		log.Printf("Leaving heaters on for %s: %v", schedule.Name, err)
+		return
+	}
+	if schedule.HotendTemp > 0 {
+		backend.SetTemperature("hotend", 0)
+	}
+	if schedule.BedTemp > 0 {
+		backend.SetTemperature("bed", 0)
+	}
+}
+
+// SetScheduler lets files be scheduled from the print jobs screen. Due
+// prints are started like a print started from the Files tab, and
+// preheated through the backend.
+func (ui *PrintJobsUI) SetScheduler(scheduler *PrintScheduler, backend *BackendClient) {
+	ui.scheduler = scheduler
+	ui.backend = backend
+	scheduler.SetStartHandler(ui.startScheduled)
+	scheduler.SetPreheatHandler(func(schedule ScheduledPrint) error {
+		return preheatScheduled(backend, schedule)
+	})
+	scheduler.SetCoolDownHandler(func(schedule ScheduledPrint) {
+		coolDownScheduled(backend, schedule)
+	})
+	if ui.scheduleButton != nil {
+		ui.updatePrintButton()
+	}
+}
+
+// startScheduled starts a scheduled print unless the printer is busy, in
+// which case the scheduler tries again later
+func (ui *PrintJobsUI) startScheduled(schedule ScheduledPrint) error {
+	if ui.currentJob != nil {
+		return fmt.Errorf("printer is busy")
+	}
+	if err := printerIdle(ui.backend); err != nil {
+		return err
+	}
+	if schedule.FileID == 0 {
+		// Scheduled from the File Manager, which only knows the file name
+		return ui.backend.StartPrint(schedule.FileName)
+	}
+	_, err := ui.launchPrint(schedule.FileID, schedule.Name)
+	return err
+}
+
+// showScheduleFileDialog schedules a file from the Files tab
+func (ui *PrintJobsUI) showScheduleFileDialog(file *GCodeFile) {
+	showScheduleDialog(ui.window, ui.scheduler, ui.auth, ScheduledPrint{
+		FileID:    file.ID,
+		Name:      file.Name,
+		FileName:  file.FileName,
+		PrintTime: file.PrintTime,
+	})
+}
+
+// showSchedules lists the scheduled prints
+func (ui *PrintJobsUI) showSchedules() {
+	if ui.scheduler == nil {
+		dialog.ShowInformation("Scheduled Prints", "Scheduling is not available", ui.window)
+		return
+	}
+	showSchedulesDialog(ui.window, ui.scheduler)
+}
@@ -288,13 +288,8 @@ func (ui *PrinterProfileUI) showParkPositionDialog() {
 
 // handleIDEXModeChange handles IDEX mode changes
 func (ui *PrinterProfileUI) handleIDEXModeChange(mode string) {
-	commands := map[string]string{
-		"Normal":            "M605 S0",
-		"Mirror Mode":       "M605 S2",
-		"Duplication Mode":  "M605 S1",
-	}
-	
-	if cmd, ok := commands[mode]; ok {
+	if idexMode, ok := idexModeNamed(mode); ok {
+		cmd := idexModeCommands[idexMode]
 		// In real implementation, send command to printer
 		ui.showInfo("Mode Changed", 
 			fmt.Sprintf("IDEX mode changed to: %s\nCommand sent: %s", mode, cmd))
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ThreeMFFilament is a filament used by a sliced plate
+type ThreeMFFilament struct {
+	ID    int
+	Type  string
+	Color string
+	UsedM float64 // Metres
+	UsedG float64 // Grams
+}
+
+// ThreeMFPlate is one build plate of a 3MF project
+type ThreeMFPlate struct {
+	Index     int
+	Name      string
+	GCodeFile string  // Archive entry of the sliced G-code, empty if not sliced
+	Thumbnail []byte  // PNG preview
+	PrintTime float64 // Slicer estimate in seconds
+	Weight    float64 // Filament weight in grams
+	Objects   []string
+	Filaments []ThreeMFFilament
+	Settings  map[string]string // Plate metadata from the slicer
+}
+
+// Sliced reports whether the plate carries G-code
+func (p *ThreeMFPlate) Sliced() bool {
+	return p.GCodeFile != ""
+}
+
+// DisplayName returns the plate number and its name, if it has one
+func (p *ThreeMFPlate) DisplayName() string {
+	if p.Name != "" {
+		return fmt.Sprintf("Plate %d: %s", p.Index, p.Name)
+	}
+	return fmt.Sprintf("Plate %d", p.Index)
+}
+
+// ThreeMFProject is an opened .3mf project
+type ThreeMFProject struct {
+	Path     string
+	Plates   []ThreeMFPlate
+	Settings map[string]string // Project-wide slicer settings
+
+	archive *zip.ReadCloser
+	files   map[string]*zip.File
+
+	mu     sync.Mutex
+	users  int  // Open entries and background tasks still reading
+	closed bool // Close was called; the last user closes the archive
+}
+
+// Locations of the slicer data inside a 3MF archive
+const (
+	threeMFSliceInfo       = "Metadata/slice_info.config"
+	threeMFModelSettings   = "Metadata/model_settings.config"
+	threeMFProjectSettings = "Metadata/project_settings.config"
+	threeMFPrusaConfig     = "Metadata/Slic3r_PE.config"
+	threeMFThumbnail       = "Metadata/thumbnail.png"
+)
+
+// threeMFPlateFile matches the per-plate G-code and thumbnail entries
+var threeMFPlateFile = regexp.MustCompile(`^Metadata/plate_(\d+)\.(gcode|png)$`)
+
+// threeMFConfig is the XML layout shared by slice_info.config and
+// model_settings.config
+type threeMFConfig struct {
+	Plates []struct {
+		Metadata []threeMFMetadata `xml:"metadata"`
+		Objects  []struct {
+			Name    string `xml:"name,attr"`
+			Skipped string `xml:"skipped,attr"`
+		} `xml:"object"`
+		Filaments []struct {
+			ID    string `xml:"id,attr"`
+			Type  string `xml:"type,attr"`
+			Color string `xml:"color,attr"`
+			UsedM string `xml:"used_m,attr"`
+			UsedG string `xml:"used_g,attr"`
+		} `xml:"filament"`
+	} `xml:"plate"`
+}
+
+// threeMFMetadata is a key/value element of a slicer config
+type threeMFMetadata struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// OpenThreeMF opens a 3MF project and reads its plates. Bambu Studio and
+// OrcaSlicer projects list every plate; other projects are one plate.
+func OpenThreeMF(path string) (*ThreeMFProject, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("not a 3MF project: %v", err)
+	}
+
+	project := &ThreeMFProject{
+		Path:     path,
+		Settings: make(map[string]string),
+		archive:  archive,
+		files:    make(map[string]*zip.File),
+	}
+	for _, file := range archive.File {
+		project.files[strings.TrimPrefix(file.Name, "/")] = file
+	}
+
+	if err := project.readPlates(); err != nil {
+		archive.Close()
+		return nil, err
+	}
+	project.readSettings()
+	return project, nil
+}
+
+// Close closes the archive. Entries still open, and tasks holding the
+// project, keep it open until they finish.
+func (p *ThreeMFProject) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.users > 0 {
+		return nil
+	}
+	return p.archive.Close()
+}
+
+// hold keeps the archive open for a task until release is called. It
+// fails once the project is closed.
+func (p *ThreeMFProject) hold() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("%s is closed", filepath.Base(p.Path))
+	}
+	p.users++
+	return nil
+}
+
+// release ends a hold, closing the archive after the last one if the
+// project was closed meanwhile
+func (p *ThreeMFProject) release() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users--
+	if p.users == 0 && p.closed {
+		return p.archive.Close()
+	}
+	return nil
+}
+
+// threeMFEntry is an open archive entry holding its project
+type threeMFEntry struct {
+	io.ReadCloser
+	project *ThreeMFProject
+	once    sync.Once
+}
+
+func (e *threeMFEntry) Close() error {
+	err := e.ReadCloser.Close()
+	e.once.Do(func() {
+		if releaseErr := e.project.release(); err == nil {
+			err = releaseErr
+		}
+	})
+	return err
+}
+
+// Name returns the project file name without its extension
+func (p *ThreeMFProject) Name() string {
+	base := filepath.Base(p.Path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// readPlates builds the plate list from the slicer configs and the plate
+// files present in the archive
+func (p *ThreeMFProject) readPlates() error {
+	plates := make(map[int]*ThreeMFPlate)
+	plate := func(index int) *ThreeMFPlate {
+		if plates[index] == nil {
+			plates[index] = &ThreeMFPlate{Index: index, Settings: make(map[string]string)}
+		}
+		return plates[index]
+	}
+
+	// Plate names and files
+	if config, err := p.readConfig(threeMFModelSettings); err != nil {
+		return err
+	} else if config != nil {
+		for _, entry := range config.Plates {
+			settings := threeMFMetadataMap(entry.Metadata)
+			index, err := strconv.Atoi(settings["plater_id"])
+			if err != nil {
+				continue
+			}
+			pl := plate(index)
+			pl.Name = settings["plater_name"]
+			for key, value := range settings {
+				pl.Settings[key] = value
+			}
+			if file := strings.TrimPrefix(settings["gcode_file"], "/"); p.files[file] != nil {
+				pl.GCodeFile = file
+			}
+			if thumbnail := strings.TrimPrefix(settings["thumbnail_file"], "/"); p.files[thumbnail] != nil {
+				pl.Thumbnail, _ = p.readFile(thumbnail)
+			}
+		}
+	}
+
+	// Slicing results
+	if config, err := p.readConfig(threeMFSliceInfo); err != nil {
+		return err
+	} else if config != nil {
+		for _, entry := range config.Plates {
+			settings := threeMFMetadataMap(entry.Metadata)
+			index, err := strconv.Atoi(settings["index"])
+			if err != nil {
+				continue
+			}
+			pl := plate(index)
+			for key, value := range settings {
+				pl.Settings[key] = value
+			}
+			pl.PrintTime, _ = strconv.ParseFloat(settings["prediction"], 64)
+			pl.Weight, _ = strconv.ParseFloat(settings["weight"], 64)
+			for _, object := range entry.Objects {
+				if object.Skipped != "true" {
+					pl.Objects = append(pl.Objects, object.Name)
+				}
+			}
+			for _, filament := range entry.Filaments {
+				id, _ := strconv.Atoi(filament.ID)
+				usedM, _ := strconv.ParseFloat(filament.UsedM, 64)
+				usedG, _ := strconv.ParseFloat(filament.UsedG, 64)
+				pl.Filaments = append(pl.Filaments, ThreeMFFilament{
+					ID: id, Type: filament.Type, Color: filament.Color, UsedM: usedM, UsedG: usedG,
+				})
+			}
+		}
+	}
+
+	// Plate files not named in any config
+	for name := range p.files {
+		match := threeMFPlateFile.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		pl := plate(index)
+		if match[2] == "gcode" && pl.GCodeFile == "" {
+			pl.GCodeFile = name
+		} else if match[2] == "png" && pl.Thumbnail == nil {
+			pl.Thumbnail, _ = p.readFile(name)
+		}
+	}
+
+	// Projects without plates are a single unsliced plate
+	if len(plates) == 0 {
+		pl := plate(1)
+		if p.files[threeMFThumbnail] != nil {
+			pl.Thumbnail, _ = p.readFile(threeMFThumbnail)
+		}
+	}
+
+	for _, pl := range plates {
+		p.Plates = append(p.Plates, *pl)
+	}
+	sort.Slice(p.Plates, func(i, j int) bool {
+		return p.Plates[i].Index < p.Plates[j].Index
+	})
+	return nil
+}
+
+// readSettings reads the project-wide slicer settings, either Bambu/Orca
+// JSON or a PrusaSlicer "; key = value" config
+func (p *ThreeMFProject) readSettings() {
+	if data, err := p.readFile(threeMFProjectSettings); err == nil {
+		var settings map[string]interface{}
+		if json.Unmarshal(data, &settings) == nil {
+			for key, value := range settings {
+				p.Settings[key] = threeMFSettingString(value)
+			}
+		}
+		return
+	}
+
+	if data, err := p.readFile(threeMFPrusaConfig); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(line), ";"), "=", 2)
+			if len(parts) == 2 {
+				p.Settings[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+		}
+	}
+}
+
+// threeMFSettingString flattens a JSON setting; per-extruder lists are
+// joined with commas as in a slicer config
+func threeMFSettingString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = threeMFSettingString(item)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// threeMFMetadataMap turns config metadata elements into a map
+func threeMFMetadataMap(metadata []threeMFMetadata) map[string]string {
+	values := make(map[string]string, len(metadata))
+	for _, entry := range metadata {
+		values[entry.Key] = entry.Value
+	}
+	return values
+}
+
+// readConfig parses an XML config, returning nil if the archive lacks it
+func (p *ThreeMFProject) readConfig(name string) (*threeMFConfig, error) {
+	data, err := p.readFile(name)
+	if err != nil {
+		return nil, nil
+	}
+	config := &threeMFConfig{}
+	if err := xml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", filepath.Base(name), err)
+	}
+	return config, nil
+}
+
+// readFile reads a whole archive entry
+func (p *ThreeMFProject) readFile(name string) ([]byte, error) {
+	reader, err := p.openFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// openFile opens an archive entry, which keeps the archive open until it
+// is closed
+func (p *ThreeMFProject) openFile(name string) (io.ReadCloser, error) {
+	file := p.files[name]
+	if file == nil {
+		return nil, fmt.Errorf("%s not found in %s", name, filepath.Base(p.Path))
+	}
+	if err := p.hold(); err != nil {
+		return nil, err
+	}
+	reader, err := file.Open()
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	return &threeMFEntry{ReadCloser: reader, project: p}, nil
+}
+
+// OpenPlateGCode opens the sliced G-code of a plate
+func (p *ThreeMFProject) OpenPlateGCode(plate *ThreeMFPlate) (io.ReadCloser, error) {
+	if !plate.Sliced() {
+		return nil, fmt.Errorf("%s has not been sliced", plate.DisplayName())
+	}
+	return p.openFile(plate.GCodeFile)
+}
+
+// PlateFileName names a plate's G-code after the project, e.g.
+// bracket_plate_2.gcode
+func (p *ThreeMFProject) PlateFileName(plate *ThreeMFPlate) string {
+	return fmt.Sprintf("%s_plate_%d.gcode", p.Name(), plate.Index)
+}
+
+// ParsePlate parses the sliced G-code of a plate
+func (p *ThreeMFProject) ParsePlate(plate *ThreeMFPlate) (*GCodeModel, error) {
+	reader, err := p.OpenPlateGCode(plate)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return NewGCodeParser().ParseGCode(reader)
+}
+
+// ExtractPlateGCode copies a plate's G-code into dir so it can be reloaded
+// and post-processed like any other file, returning the path written
+func (p *ThreeMFProject) ExtractPlateGCode(plate *ThreeMFPlate, dir string) (string, error) {
+	reader, err := p.OpenPlateGCode(plate)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// The name comes from the project file, so keep it inside dir
+	name := filepath.Base(p.PlateFileName(plate))
+	path := filepath.Join(dir, name)
+	if rel, err := filepath.Rel(dir, path); err != nil || rel != name || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid plate file name %q", name)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// threeMFCacheDir is where plate G-code is extracted for viewing
+func threeMFCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return filepath.Join(cacheDir, "innovate-os", "3mf")
+}
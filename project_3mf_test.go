@@ -0,0 +1,151 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func openTestProject(t *testing.T) *ThreeMFProject {
+	t.Helper()
+	project, err := OpenThreeMF("testdata/multi_plate.3mf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+func TestOpenThreeMF(t *testing.T) {
+	project := openTestProject(t)
+	defer project.Close()
+
+	if project.Name() != "multi_plate" {
+		t.Errorf("Name = %q", project.Name())
+	}
+	var plates []string
+	for _, plate := range project.Plates {
+		plates = append(plates, plate.DisplayName())
+	}
+	if want := []string{"Plate 1: Brackets", "Plate 2: Lid", "Plate 3"}; !reflect.DeepEqual(plates, want) {
+		t.Fatalf("plates %q, want %q", plates, want)
+	}
+
+	brackets, lid, clip := project.Plates[0], project.Plates[1], project.Plates[2]
+	if !brackets.Sliced() || brackets.GCodeFile != "Metadata/plate_1.gcode" || len(brackets.Thumbnail) == 0 {
+		t.Errorf("plate 1 = %+v", brackets)
+	}
+	if brackets.PrintTime != 1834 || brackets.Weight != 12.41 || brackets.Settings["printer_model_id"] != "C11" {
+		t.Errorf("plate 1 slicing results %g s, %g g, %v", brackets.PrintTime, brackets.Weight, brackets.Settings)
+	}
+	if want := []string{"bracket_left", "bracket_right"}; !reflect.DeepEqual(brackets.Objects, want) {
+		t.Errorf("plate 1 objects %q, want %q without the skipped one", brackets.Objects, want)
+	}
+	wantFilaments := []ThreeMFFilament{
+		{ID: 1, Type: "PLA", Color: "#FF8000", UsedM: 3.21, UsedG: 9.58},
+		{ID: 2, Type: "PETG", Color: "#000000", UsedM: 0.93, UsedG: 2.83},
+	}
+	if !reflect.DeepEqual(brackets.Filaments, wantFilaments) {
+		t.Errorf("plate 1 filaments %+v", brackets.Filaments)
+	}
+	if lid.Sliced() || len(lid.Thumbnail) == 0 {
+		t.Errorf("plate 2 should be an unsliced plate with a thumbnail: %+v", lid)
+	}
+	// Found from the archive listing alone
+	if !clip.Sliced() || clip.GCodeFile != "Metadata/plate_3.gcode" || clip.PrintTime != 600 {
+		t.Errorf("plate 3 = %+v", clip)
+	}
+
+	for key, want := range map[string]string{
+		"layer_height":    "0.2",
+		"nozzle_diameter": "0.4,0.4",
+		"wall_loops":      "2",
+		"enable_support":  "false",
+	} {
+		if got := project.Settings[key]; got != want {
+			t.Errorf("setting %s = %q, want %q", key, got, want)
+		}
+	}
+
+	if _, err := OpenThreeMF("testdata/power_loss.gcode"); err == nil {
+		t.Error("expected an error for a file that is not a 3MF project")
+	}
+}
+
+func TestThreeMFPlateGCode(t *testing.T) {
+	project := openTestProject(t)
+	defer project.Close()
+	brackets, lid := &project.Plates[0], &project.Plates[1]
+
+	model, err := project.ParsePlate(brackets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(model.Layers) != 2 || PrintTool(model) != 1 {
+		t.Errorf("plate 1 parsed to %d layers with tool %d, want 2 layers with tool 1", len(model.Layers), PrintTool(model))
+	}
+
+	dir := t.TempDir()
+	path, err := project.ExtractPlateGCode(brackets, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "multi_plate_plate_1.gcode") {
+		t.Errorf("extracted to %s", path)
+	}
+	extracted, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(extracted), "; HEADER_BLOCK_START\n") || !strings.HasSuffix(string(extracted), "G1 X10 Y40 E1.5\n") {
+		t.Errorf("extracted G-code differs:\n%s", extracted)
+	}
+
+	if _, err := project.OpenPlateGCode(lid); err == nil {
+		t.Error("an unsliced plate has no G-code")
+	}
+	if _, err := project.ExtractPlateGCode(lid, dir); err == nil {
+		t.Error("an unsliced plate cannot be extracted")
+	}
+}
+
+func TestThreeMFCloseWaitsForReaders(t *testing.T) {
+	project := openTestProject(t)
+	reader, err := project.OpenPlateGCode(&project.Plates[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.hold(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the picker while a plate is still being read
+	if err := project.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil || !strings.Contains(string(data), "G1 X10 Y40 E1.5") {
+		t.Errorf("reading after Close = %d bytes, %v", len(data), err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+	if project.users != 1 {
+		t.Errorf("%d users after the reader closed, want the hold only", project.users)
+	}
+	if err := project.release(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := project.OpenPlateGCode(&project.Plates[2]); err == nil {
+		t.Error("a closed project opened a plate")
+	}
+	if err := project.hold(); err == nil {
+		t.Error("a closed project was held")
+	}
+	if err := project.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
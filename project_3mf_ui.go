@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// threeMFSummarySettings are the project settings shown next to a plate
+var threeMFSummarySettings = []struct{ key, label string }{
+	{"printer_model", "Printer"},
+	{"nozzle_diameter", "Nozzle"},
+	{"layer_height", "Layer height"},
+	{"sparse_infill_density", "Infill"},
+	{"fill_density", "Infill"},
+	{"filament_type", "Filament"},
+}
+
+// openThreeMF opens a 3MF project and lets the user pick a plate
+func (ui *GCodeViewerUI) openThreeMF(path string) {
+	project, err := OpenThreeMF(path)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to open project: %v", err), ui.window)
+		return
+	}
+	ui.showPlatePicker(project)
+}
+
+// showPlatePicker lists the plates of a project with their thumbnails and
+// settings; the selected plate can be loaded into the viewer or uploaded
+func (ui *GCodeViewerUI) showPlatePicker(project *ThreeMFProject) {
+	selected := -1
+
+	thumbnail := container.NewMax()
+	details := widget.NewLabel("Select a plate")
+	details.Wrapping = fyne.TextWrapWord
+
+	loadBtn := widget.NewButton("Load", nil)
+	uploadBtn := widget.NewButton("Upload", nil)
+	loadBtn.Disable()
+	uploadBtn.Disable()
+
+	plateList := widget.NewList(
+		func() int {
+			return len(project.Plates)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("Plate")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			plate := &project.Plates[id]
+			text := plate.DisplayName()
+			if !plate.Sliced() {
+				text += " (not sliced)"
+			}
+			item.(*widget.Label).SetText(text)
+		},
+	)
+	plateList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		plate := &project.Plates[id]
+
+		thumbnail.Objects = nil
+		if plate.Thumbnail != nil {
+			image := canvas.NewImageFromReader(bytes.NewReader(plate.Thumbnail), fmt.Sprintf("plate_%d.png", plate.Index))
+			image.FillMode = canvas.ImageFillContain
+			image.SetMinSize(fyne.NewSize(240, 180))
+			thumbnail.Objects = []fyne.CanvasObject{image}
+		}
+		thumbnail.Refresh()
+		details.SetText(plateSummary(project, plate))
+
+		if plate.Sliced() {
+			loadBtn.Enable()
+			uploadBtn.Enable()
+		} else {
+			loadBtn.Disable()
+			uploadBtn.Disable()
+		}
+	}
+
+	content := container.NewHSplit(
+		plateList,
+		container.NewBorder(thumbnail, container.NewGridWithColumns(2, loadBtn, uploadBtn), nil, nil,
+			container.NewVScroll(details)),
+	)
+	content.SetOffset(0.35)
+
+	picker := dialog.NewCustom(project.Name(), "Close", content, ui.window)
+	picker.SetOnClosed(func() {
+		project.Close()
+	})
+
+	loadBtn.OnTapped = func() {
+		if selected < 0 {
+			return
+		}
+		plate := &project.Plates[selected]
+		ui.loadPlate(project, plate, picker)
+	}
+	uploadBtn.OnTapped = func() {
+		if selected < 0 {
+			return
+		}
+		ui.uploadPlate(project, &project.Plates[selected])
+	}
+
+	picker.Resize(fyne.NewSize(720, 480))
+	picker.Show()
+	if len(project.Plates) == 1 {
+		plateList.Select(0)
+	}
+}
+
+// plateSummary describes a plate and the project settings it was sliced with
+func plateSummary(project *ThreeMFProject, plate *ThreeMFPlate) string {
+	lines := []string{plate.DisplayName()}
+	if !plate.Sliced() {
+		lines = append(lines, "Not sliced: slice this plate and save the project to print it")
+	}
+	if plate.PrintTime > 0 {
+		lines = append(lines, "Print time: "+formatSeconds(plate.PrintTime))
+	}
+	if plate.Weight > 0 {
+		lines = append(lines, fmt.Sprintf("Filament: %.1f g", plate.Weight))
+	}
+	for _, filament := range plate.Filaments {
+		lines = append(lines, fmt.Sprintf("  %d: %s %s, %.2f m / %.1f g",
+			filament.ID, filament.Type, filament.Color, filament.UsedM, filament.UsedG))
+	}
+	if len(plate.Objects) > 0 {
+		lines = append(lines, "Objects: "+strings.Join(plate.Objects, ", "))
+	}
+
+	shown := make(map[string]bool)
+	for _, setting := range threeMFSummarySettings {
+		value, ok := project.Settings[setting.key]
+		if !ok || value == "" || shown[setting.label] {
+			continue
+		}
+		shown[setting.label] = true
+		lines = append(lines, fmt.Sprintf("%s: %s", setting.label, value))
+	}
+
+	// Remaining plate metadata, for anything the summary does not cover
+	keys := make([]string, 0, len(plate.Settings))
+	for key := range plate.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, plate.Settings[key]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// loadPlate extracts a plate's G-code and shows it in the viewer
+func (ui *GCodeViewerUI) loadPlate(project *ThreeMFProject, plate *ThreeMFPlate, picker dialog.Dialog) {
+	// Closing the picker while loading leaves the archive open until done
+	if err := project.hold(); err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	progressDialog := dialog.NewProgressInfinite("Loading G-code", "Parsing "+plate.DisplayName()+"...", ui.window)
+	progressDialog.Show()
+
+	go func() {
+		path, err := project.ExtractPlateGCode(plate, threeMFCacheDir())
+		project.release()
+		if err == nil {
+			err = ui.LoadGCodeFromFile(path)
+		}
+		progressDialog.Hide()
+
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to load %s: %v", plate.DisplayName(), err), ui.window)
+			return
+		}
+		picker.Hide()
+	}()
+}
+
+// uploadPlate sends a plate's G-code to the printer
+func (ui *GCodeViewerUI) uploadPlate(project *ThreeMFProject, plate *ThreeMFPlate) {
+	if ui.backend == nil {
+		dialog.ShowError(fmt.Errorf("not connected to a printer"), ui.window)
+		return
+	}
+
+	if err := project.hold(); err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	progressDialog := dialog.NewProgressInfinite("Upload", "Uploading "+plate.DisplayName()+"...", ui.window)
+	progressDialog.Show()
+
+	go func() {
+		reader, err := project.OpenPlateGCode(plate)
+		project.release()
+		name := project.PlateFileName(plate)
+		if err == nil {
+			name, err = ui.backend.UploadGCode(name, reader)
+			reader.Close()
+		}
+		progressDialog.Hide()
+
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to upload %s: %v", plate.DisplayName(), err), ui.window)
+			return
+		}
+		dialog.ShowInformation("Upload", fmt.Sprintf("%s sent to the printer", name), ui.window)
+	}()
+}
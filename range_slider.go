@@ -0,0 +1,204 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Range slider sizing, large enough to grab on the touchscreen
+const (
+	rangeSliderHandleSize = 28.0
+	rangeSliderTrackWidth = 6.0
+)
+
+// rangeHandle identifies a range slider handle
+type rangeHandle int
+
+const (
+	rangeHandleNone rangeHandle = iota
+	rangeHandleLow
+	rangeHandleHigh
+)
+
+// RangeSlider is a slider with two handles selecting a value range
+type RangeSlider struct {
+	widget.BaseWidget
+
+	Min, Max  float64
+	Low, High float64
+	Step      float64
+
+	OnChanged func(low, high float64)
+
+	width    float32
+	height   float32
+	dragging rangeHandle
+}
+
+// NewRangeSlider creates a range slider covering min to max
+func NewRangeSlider(min, max float64) *RangeSlider {
+	slider := &RangeSlider{
+		Min:  min,
+		Max:  max,
+		Low:  min,
+		High: max,
+		Step: 1,
+	}
+	slider.ExtendBaseWidget(slider)
+	return slider
+}
+
+// SetLimits changes the slider bounds and selects the full range
+func (s *RangeSlider) SetLimits(min, max float64) {
+	s.Min = min
+	s.Max = max
+	s.SetRange(min, max)
+}
+
+// SetRange selects a range and notifies OnChanged
+func (s *RangeSlider) SetRange(low, high float64) {
+	low, high = s.snap(low), s.snap(high)
+	if low > high {
+		low, high = high, low
+	}
+	if low == s.Low && high == s.High {
+		s.Refresh()
+		return
+	}
+
+	s.Low = low
+	s.High = high
+	s.Refresh()
+
+	if s.OnChanged != nil {
+		s.OnChanged(s.Low, s.High)
+	}
+}
+
+// Dragged moves the handle closest to where the drag started
+func (s *RangeSlider) Dragged(event *fyne.DragEvent) {
+	if s.dragging == rangeHandleNone {
+		s.dragging = s.nearestHandle(event.Position.X)
+	}
+	s.moveHandle(s.dragging, event.Position.X)
+}
+
+// DragEnd releases the dragged handle
+func (s *RangeSlider) DragEnd() {
+	s.dragging = rangeHandleNone
+}
+
+// Tapped moves the nearest handle to the tapped position
+func (s *RangeSlider) Tapped(event *fyne.PointEvent) {
+	s.moveHandle(s.nearestHandle(event.Position.X), event.Position.X)
+}
+
+// moveHandle sets one handle from a screen position without crossing the other
+func (s *RangeSlider) moveHandle(handle rangeHandle, x float32) {
+	value := s.valueAt(x)
+	switch handle {
+	case rangeHandleLow:
+		s.SetRange(math.Min(value, s.High), s.High)
+	case rangeHandleHigh:
+		s.SetRange(s.Low, math.Max(value, s.Low))
+	}
+}
+
+// nearestHandle returns the handle closest to a screen position
+func (s *RangeSlider) nearestHandle(x float32) rangeHandle {
+	lowX, highX := s.positionOf(s.Low), s.positionOf(s.High)
+	if math.Abs(float64(x-lowX)) < math.Abs(float64(x-highX)) {
+		return rangeHandleLow
+	}
+	if lowX == highX && x < lowX {
+		// Handles overlap; dragging left can only mean the low one
+		return rangeHandleLow
+	}
+	return rangeHandleHigh
+}
+
+// positionOf converts a value to an x position on the track
+func (s *RangeSlider) positionOf(value float64) float32 {
+	usable := s.width - rangeSliderHandleSize
+	if s.Max <= s.Min || usable <= 0 {
+		return rangeSliderHandleSize / 2
+	}
+	return rangeSliderHandleSize/2 + float32((value-s.Min)/(s.Max-s.Min))*usable
+}
+
+// valueAt converts an x position on the track to a value
+func (s *RangeSlider) valueAt(x float32) float64 {
+	usable := s.width - rangeSliderHandleSize
+	if usable <= 0 {
+		return s.Min
+	}
+	ratio := float64((x - rangeSliderHandleSize/2) / usable)
+	ratio = math.Max(0, math.Min(1, ratio))
+	return s.Min + ratio*(s.Max-s.Min)
+}
+
+// snap rounds a value to the step and clamps it to the limits
+func (s *RangeSlider) snap(value float64) float64 {
+	if s.Step > 0 {
+		value = s.Min + math.Round((value-s.Min)/s.Step)*s.Step
+	}
+	return math.Max(s.Min, math.Min(s.Max, value))
+}
+
+// CreateRenderer creates the range slider renderer
+func (s *RangeSlider) CreateRenderer() fyne.WidgetRenderer {
+	return &rangeSliderRenderer{slider: s}
+}
+
+// rangeSliderRenderer renders the range slider
+type rangeSliderRenderer struct {
+	slider *RangeSlider
+}
+
+func (r *rangeSliderRenderer) Layout(size fyne.Size) {
+	r.slider.width = size.Width
+	r.slider.height = size.Height
+}
+
+func (r *rangeSliderRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(rangeSliderHandleSize*4, rangeSliderHandleSize+8)
+}
+
+func (r *rangeSliderRenderer) Refresh() {
+	// Refresh handled by redrawing
+}
+
+func (r *rangeSliderRenderer) Destroy() {
+	// Nothing to destroy
+}
+
+func (r *rangeSliderRenderer) Objects() []fyne.CanvasObject {
+	s := r.slider
+	centerY := s.height / 2
+	lowX, highX := s.positionOf(s.Low), s.positionOf(s.High)
+
+	track := canvas.NewRectangle(color.NRGBA{R: 80, G: 80, B: 85, A: 255})
+	track.CornerRadius = rangeSliderTrackWidth / 2
+	track.Move(fyne.NewPos(rangeSliderHandleSize/2, centerY-rangeSliderTrackWidth/2))
+	track.Resize(fyne.NewSize(s.width-rangeSliderHandleSize, rangeSliderTrackWidth))
+
+	active := canvas.NewRectangle(color.NRGBA{R: 0, G: 122, B: 255, A: 255})
+	active.Move(fyne.NewPos(lowX, centerY-rangeSliderTrackWidth/2))
+	active.Resize(fyne.NewSize(highX-lowX, rangeSliderTrackWidth))
+
+	objects := []fyne.CanvasObject{track, active}
+	for _, x := range []float32{lowX, highX} {
+		handle := canvas.NewCircle(color.White)
+		handle.StrokeColor = color.NRGBA{R: 0, G: 122, B: 255, A: 255}
+		handle.StrokeWidth = 2
+		handle.Move(fyne.NewPos(x-rangeSliderHandleSize/2, centerY-rangeSliderHandleSize/2))
+		handle.Resize(fyne.NewSize(rangeSliderHandleSize, rangeSliderHandleSize))
+		objects = append(objects, handle)
+	}
+
+	return objects
+}
@@ -0,0 +1,705 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spoolLowWeight is the remaining weight in grams below which a spool is
+// reported as running low
+const spoolLowWeight = 50.0
+
+// Spool is a reel of filament in the inventory
+type Spool struct {
+	ID              uint      `json:"id"`
+	SpoolmanID      int       `json:"spoolman_id,omitempty"` // 0 when not synced
+	Name            string    `json:"name"`
+	Vendor          string    `json:"vendor,omitempty"`
+	Material        string    `json:"material"`
+	Color           string    `json:"color"`            // #RRGGBB
+	Diameter        float64   `json:"diameter"`         // mm
+	Density         float64   `json:"density"`          // g/cm³
+	InitialWeight   float64   `json:"initial_weight"`   // Net filament weight in g
+	RemainingWeight float64   `json:"remaining_weight"` // g
+	Cost            float64   `json:"cost"`             // Price of the full spool
+	AddedAt         time.Time `json:"added_at"`
+	LastUsedAt      time.Time `json:"last_used_at,omitempty"`
+}
+
+// Label returns a short description of the spool
+func (s *Spool) Label() string {
+	name := s.Name
+	if name == "" {
+		name = fmt.Sprintf("Spool %d", s.ID)
+	}
+	if s.Material == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, s.Material)
+}
+
+// Grams returns the weight of a length of the spool's filament
+func (s *Spool) Grams(lengthMM float64) float64 {
+	diameter, density := s.Diameter, s.Density
+	if diameter <= 0 {
+		diameter = defaultFilamentDiameter
+	}
+	if density <= 0 {
+		density = defaultFilamentDensity
+	}
+	return filamentMMToGrams(lengthMM, diameter, density)
+}
+
+// CostPerGram returns the price of a gram of filament, or 0 when unknown
+func (s *Spool) CostPerGram() float64 {
+	if s.Cost <= 0 || s.InitialWeight <= 0 {
+		return 0
+	}
+	return s.Cost / s.InitialWeight
+}
+
+// IsLow reports whether the spool is running out
+func (s *Spool) IsLow() bool {
+	return s.RemainingWeight < spoolLowWeight
+}
+
+// SpoolUsage is the filament a job took from a spool
+type SpoolUsage struct {
+	SpoolID    uint
+	SpoolmanID int
+	Material   string
+	LengthMM   float64
+	Grams      float64
+	Cost       float64
+}
+
+// spoolInventoryData is the stored inventory
+type spoolInventoryData struct {
+	Spools      []Spool      `json:"spools"`
+	Tools       map[int]uint `json:"tools"` // Loaded spool per tool
+	NextID      uint         `json:"next_id"`
+	SpoolmanURL string       `json:"spoolman_url,omitempty"`
+}
+
+// SpoolInventory keeps the spools on hand and which one each tool is
+// loaded with
+type SpoolInventory struct {
+	mu          sync.Mutex
+	spools      []Spool
+	tools       map[int]uint
+	nextID      uint
+	spoolmanURL string
+	path        string
+	onChange    func()
+}
+
+// NewSpoolInventory creates an inventory stored at path
+func NewSpoolInventory(path string) *SpoolInventory {
+	return &SpoolInventory{tools: make(map[int]uint), nextID: 1, path: path}
+}
+
+// spoolInventoryFile is where the touchscreen keeps its spools
+func spoolInventoryFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "spools.json")
+}
+
+// SetOnChange sets a callback run after every change to the inventory
+func (inv *SpoolInventory) SetOnChange(callback func()) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.onChange = callback
+}
+
+// Load reads the stored inventory. A missing file leaves it empty.
+func (inv *SpoolInventory) Load() error {
+	data, err := ioutil.ReadFile(inv.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored spoolInventoryData
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid spool file: %v", err)
+	}
+
+	inv.mu.Lock()
+	inv.spools = stored.Spools
+	inv.tools = stored.Tools
+	if inv.tools == nil {
+		inv.tools = make(map[int]uint)
+	}
+	inv.nextID = stored.NextID
+	for _, spool := range inv.spools {
+		if spool.ID >= inv.nextID {
+			inv.nextID = spool.ID + 1
+		}
+	}
+	inv.spoolmanURL = stored.SpoolmanURL
+	inv.mu.Unlock()
+	return nil
+}
+
+// Spools returns the spools in the order they were added
+func (inv *SpoolInventory) Spools() []Spool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	spools := append([]Spool(nil), inv.spools...)
+	sort.SliceStable(spools, func(i, j int) bool { return spools[i].ID < spools[j].ID })
+	return spools
+}
+
+// Get returns a spool by ID
+func (inv *SpoolInventory) Get(id uint) (Spool, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if spool := inv.find(id); spool != nil {
+		return *spool, true
+	}
+	return Spool{}, false
+}
+
+// Add adds a spool and returns it with its ID
+func (inv *SpoolInventory) Add(spool Spool) (Spool, error) {
+	if err := validateSpool(&spool); err != nil {
+		return Spool{}, err
+	}
+	err := inv.update(func() error {
+		spool.ID = inv.nextID
+		inv.nextID++
+		if spool.AddedAt.IsZero() {
+			spool.AddedAt = time.Now()
+		}
+		inv.spools = append(inv.spools, spool)
+		return nil
+	})
+	return spool, err
+}
+
+// Edit replaces the details of a spool
+func (inv *SpoolInventory) Edit(spool Spool) error {
+	if err := validateSpool(&spool); err != nil {
+		return err
+	}
+	return inv.update(func() error {
+		existing := inv.find(spool.ID)
+		if existing == nil {
+			return fmt.Errorf("spool %d not found", spool.ID)
+		}
+		spool.AddedAt = existing.AddedAt
+		spool.LastUsedAt = existing.LastUsedAt
+		*existing = spool
+		return nil
+	})
+}
+
+// Remove deletes a spool, unloading it from its tool
+func (inv *SpoolInventory) Remove(id uint) error {
+	return inv.update(func() error {
+		for i := range inv.spools {
+			if inv.spools[i].ID == id {
+				inv.spools = append(inv.spools[:i], inv.spools[i+1:]...)
+				for tool, loaded := range inv.tools {
+					if loaded == id {
+						delete(inv.tools, tool)
+					}
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("spool %d not found", id)
+	})
+}
+
+// LoadSpool assigns a spool to a tool. A spool is loaded on one tool at a
+// time, so it is unloaded from any other.
+func (inv *SpoolInventory) LoadSpool(tool int, id uint) error {
+	return inv.update(func() error {
+		if tool < 0 {
+			return fmt.Errorf("invalid tool %d", tool)
+		}
+		if inv.find(id) == nil {
+			return fmt.Errorf("spool %d not found", id)
+		}
+		for other, loaded := range inv.tools {
+			if loaded == id {
+				delete(inv.tools, other)
+			}
+		}
+		inv.tools[tool] = id
+		return nil
+	})
+}
+
+// Unload clears the spool of a tool
+func (inv *SpoolInventory) Unload(tool int) error {
+	return inv.update(func() error {
+		delete(inv.tools, tool)
+		return nil
+	})
+}
+
+// Loaded returns the spool loaded on a tool
+func (inv *SpoolInventory) Loaded(tool int) (Spool, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if spool := inv.find(inv.tools[tool]); spool != nil {
+		return *spool, true
+	}
+	return Spool{}, false
+}
+
+// ToolOf returns the tool a spool is loaded on, or -1
+func (inv *SpoolInventory) ToolOf(id uint) int {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for tool, loaded := range inv.tools {
+		if loaded == id {
+			return tool
+		}
+	}
+	return -1
+}
+
+// CheckJob returns a warning when the spool on a tool is unlikely to hold a
+// job's filament length, or "" when it does or no spool is loaded
+func (inv *SpoolInventory) CheckJob(tool int, lengthMM float64) string {
+	spool, ok := inv.Loaded(tool)
+	if !ok || lengthMM <= 0 {
+		return ""
+	}
+	needed := spool.Grams(lengthMM)
+	if needed <= spool.RemainingWeight {
+		return ""
+	}
+	return fmt.Sprintf("%s on tool %d has %.0f g left but the job needs about %.0f g.",
+		spool.Label(), tool, spool.RemainingWeight, needed)
+}
+
+// Usage returns what a length of filament from the spool on a tool weighs
+// and costs, without deducting it. Without a loaded spool the weight is
+// estimated for PLA.
+func (inv *SpoolInventory) Usage(tool int, lengthMM float64) SpoolUsage {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return spoolUsage(inv.find(inv.tools[tool]), lengthMM)
+}
+
+// Consume deducts a length of filament from the spool on a tool and returns
+// what it weighed and cost. Without a loaded spool nothing is deducted and
+// the weight is estimated for PLA.
+func (inv *SpoolInventory) Consume(tool int, lengthMM float64) (SpoolUsage, error) {
+	usage := spoolUsage(nil, lengthMM)
+	if lengthMM <= 0 {
+		return usage, nil
+	}
+
+	err := inv.update(func() error {
+		spool := inv.find(inv.tools[tool])
+		if spool == nil {
+			return nil
+		}
+		usage = spoolUsage(spool, lengthMM)
+		spool.RemainingWeight -= usage.Grams
+		if spool.RemainingWeight < 0 {
+			spool.RemainingWeight = 0
+		}
+		spool.LastUsedAt = time.Now()
+		return nil
+	})
+	return usage, err
+}
+
+// spoolUsage works out the weight and cost of a length of filament from a
+// spool, which may be nil
+func spoolUsage(spool *Spool, lengthMM float64) SpoolUsage {
+	usage := SpoolUsage{
+		LengthMM: lengthMM,
+		Grams:    filamentMMToGrams(lengthMM, defaultFilamentDiameter, defaultFilamentDensity),
+	}
+	if spool == nil || lengthMM <= 0 {
+		return usage
+	}
+	usage.SpoolID = spool.ID
+	usage.SpoolmanID = spool.SpoolmanID
+	usage.Material = spool.Material
+	usage.Grams = spool.Grams(lengthMM)
+	usage.Cost = usage.Grams * spool.CostPerGram()
+	return usage
+}
+
+// SpoolmanURL returns the Spoolman server the inventory syncs with, or ""
+func (inv *SpoolInventory) SpoolmanURL() string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return inv.spoolmanURL
+}
+
+// SetSpoolmanURL sets the Spoolman server; "" disables syncing
+func (inv *SpoolInventory) SetSpoolmanURL(url string) error {
+	return inv.update(func() error {
+		inv.spoolmanURL = strings.TrimRight(strings.TrimSpace(url), "/")
+		return nil
+	})
+}
+
+// MergeSpoolman takes over spools fetched from Spoolman. Known spools are
+// updated in place so their IDs and tool assignments stay; new ones are
+// added.
+func (inv *SpoolInventory) MergeSpoolman(remote []Spool) (added, updated int, err error) {
+	err = inv.update(func() error {
+		for _, spool := range remote {
+			if spool.SpoolmanID == 0 {
+				continue
+			}
+			var existing *Spool
+			for i := range inv.spools {
+				if inv.spools[i].SpoolmanID == spool.SpoolmanID {
+					existing = &inv.spools[i]
+					break
+				}
+			}
+			if existing == nil {
+				spool.ID = inv.nextID
+				inv.nextID++
+				if spool.AddedAt.IsZero() {
+					spool.AddedAt = time.Now()
+				}
+				inv.spools = append(inv.spools, spool)
+				added++
+				continue
+			}
+			spool.ID = existing.ID
+			spool.AddedAt = existing.AddedAt
+			spool.LastUsedAt = existing.LastUsedAt
+			*existing = spool
+			updated++
+		}
+		return nil
+	})
+	return added, updated, err
+}
+
+// validateSpool checks a spool and fills in defaults
+func validateSpool(spool *Spool) error {
+	spool.Name = strings.TrimSpace(spool.Name)
+	spool.Material = strings.ToUpper(strings.TrimSpace(spool.Material))
+	if spool.Name == "" && spool.Material == "" {
+		return fmt.Errorf("a spool needs a name or material")
+	}
+	if spool.Diameter == 0 {
+		spool.Diameter = defaultFilamentDiameter
+	}
+	if spool.Density == 0 {
+		spool.Density = materialDensity(spool.Material)
+	}
+	if spool.Diameter < 0 || spool.Density < 0 || spool.InitialWeight < 0 || spool.RemainingWeight < 0 || spool.Cost < 0 {
+		return fmt.Errorf("spool values cannot be negative")
+	}
+	if spool.RemainingWeight > spool.InitialWeight && spool.InitialWeight > 0 {
+		return fmt.Errorf("remaining weight exceeds the spool weight")
+	}
+	return nil
+}
+
+// materialDensities are typical densities in g/cm³ by material
+var materialDensities = map[string]float64{
+	"PLA":  1.24,
+	"PETG": 1.27,
+	"ABS":  1.04,
+	"ASA":  1.07,
+	"TPU":  1.21,
+	"PA":   1.14,
+	"PC":   1.20,
+	"PVA":  1.23,
+	"HIPS": 1.04,
+}
+
+// materialDensity returns the typical density of a material, or PLA's
+func materialDensity(material string) float64 {
+	if density, ok := materialDensities[strings.ToUpper(material)]; ok {
+		return density
+	}
+	return defaultFilamentDensity
+}
+
+// update applies a change, saves the inventory and notifies listeners
+func (inv *SpoolInventory) update(change func() error) error {
+	inv.mu.Lock()
+	if err := change(); err != nil {
+		inv.mu.Unlock()
+		return err
+	}
+	inv.mu.Unlock()
+
+	if err := inv.save(); err != nil {
+		return fmt.Errorf("failed to save spools: %v", err)
+	}
+	inv.notify()
+	return nil
+}
+
+// find returns the spool with the given ID, or nil; the caller holds the lock
+func (inv *SpoolInventory) find(id uint) *Spool {
+	for i := range inv.spools {
+		if inv.spools[i].ID == id {
+			return &inv.spools[i]
+		}
+	}
+	return nil
+}
+
+// save writes the inventory to disk
+func (inv *SpoolInventory) save() error {
+	if inv.path == "" {
+		return nil
+	}
+
+	inv.mu.Lock()
+	jsonData, err := json.MarshalIndent(spoolInventoryData{
+		Spools:      inv.spools,
+		Tools:       inv.tools,
+		NextID:      inv.nextID,
+		SpoolmanURL: inv.spoolmanURL,
+	}, "", "  ")
+	inv.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(inv.path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(inv.path, jsonData, 0600)
+}
+
+// notify runs the change callback
+func (inv *SpoolInventory) notify() {
+	inv.mu.Lock()
+	callback := inv.onChange
+	inv.mu.Unlock()
+	if callback != nil {
+		callback()
+	}
+}
+
+// ExtrusionTracker follows the extrusion total and progress of the status
+// stream and reports how much filament a print used once it ends
+type ExtrusionTracker struct {
+	printing bool
+	extruded float64
+	progress float64
+	fileName string
+}
+
+// Observe takes a status update. When a print has just ended it returns the
+// filament it extruded in mm and true. The length is 0 when the backend
+// does not report filament_used; Progress then tells how far it got.
+func (t *ExtrusionTracker) Observe(status PrinterStatus) (float64, bool) {
+	state := strings.ToLower(status.Status)
+	if state == "" {
+		return 0, false
+	}
+	active := state == "printing" || state == "paused"
+	if active {
+		if !t.printing {
+			t.printing = true
+			t.extruded = 0
+			t.progress = 0
+			t.fileName = ""
+		}
+		if status.FileName != "" {
+			t.fileName = status.FileName
+		}
+		t.observeTotals(status)
+		return 0, false
+	}
+	if !t.printing {
+		return 0, false
+	}
+
+	t.printing = false
+	t.observeTotals(status)
+	if state == "complete" || state == "completed" {
+		t.progress = 1
+	}
+	return t.extruded, true
+}
+
+// observeTotals keeps the highest extrusion and progress reported, as
+// backends reset both when a print ends
+func (t *ExtrusionTracker) observeTotals(status PrinterStatus) {
+	if status.FilamentUsed > t.extruded {
+		t.extruded = status.FilamentUsed
+	}
+	if status.Progress > t.progress {
+		t.progress = math.Min(status.Progress, 1)
+	}
+}
+
+// FileName returns the file of the print being followed, or of the one that
+// ended last
+func (t *ExtrusionTracker) FileName() string {
+	return t.fileName
+}
+
+// Progress returns how far the print being followed, or the one that ended
+// last, got from 0 to 1
+func (t *ExtrusionTracker) Progress() float64 {
+	return t.progress
+}
+
+// ReadToolExtrusion returns the filament in mm each tool extrudes in G-code
+// of any supported format
+func ReadToolExtrusion(r io.Reader) (map[int]float64, error) {
+	reader, err := NewGCodeReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	parser := NewGCodeParser()
+	state := NewGCodeMachineState()
+	extruded := map[int]float64{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		cmd := parser.parseLine(scanner.Text(), line)
+		before := state.E
+		state.Apply(cmd)
+		switch cmd.Type {
+		case "G0", "G1", "G2", "G3":
+			extruded[state.Tool] += state.E - before
+		}
+	}
+	for tool, length := range extruded {
+		if length <= 0 {
+			delete(extruded, tool)
+		}
+	}
+	return extruded, scanner.Err()
+}
+
+// fileExtrusion returns the filament each tool extrudes in a file on the
+// backend, or nil when it cannot be read
+func fileExtrusion(backend *BackendClient, fileName string) map[int]float64 {
+	if backend == nil || fileName == "" {
+		return nil
+	}
+	reader, err := backend.DownloadGCode(fileName)
+	if err != nil {
+		log.Printf("Failed to download %s to measure its filament: %v", fileName, err)
+		return nil
+	}
+	defer reader.Close()
+	extruded, err := ReadToolExtrusion(reader)
+	if err != nil {
+		log.Printf("Failed to measure the filament of %s: %v", fileName, err)
+		return nil
+	}
+	return extruded
+}
+
+// toolExtrusion shares the filament a print used between its tools. A
+// measured length is split in the proportions the file extrudes on each
+// tool, and goes to tool 0 when the file is unknown; without one the file's
+// own lengths are scaled by the progress the print reached.
+func toolExtrusion(measured, progress float64, file map[int]float64) map[int]float64 {
+	total := 0.0
+	for _, length := range file {
+		total += length
+	}
+
+	used := map[int]float64{}
+	switch {
+	case measured > 0 && total <= 0:
+		used[0] = measured
+	case measured > 0:
+		for tool, length := range file {
+			used[tool] = measured * length / total
+		}
+	default:
+		for tool, length := range file {
+			if length*progress > 0 {
+				used[tool] = length * progress
+			}
+		}
+	}
+	return used
+}
+
+// PrintTool returns the tool a parsed file prints with: the first tool it
+// selects, or tool 0 when it selects none. Job warnings and history are
+// charged to this tool's spool; the filament deducted at the end of a
+// print is split between tools by toolExtrusion. The model may be nil.
+func PrintTool(model *GCodeModel) int {
+	if model == nil {
+		return 0
+	}
+	for _, cmd := range model.Commands {
+		if len(cmd.Type) > 1 && cmd.Type[0] == 'T' {
+			if tool, ok := parseToolNumber(cmd.Type); ok {
+				return tool
+			}
+		}
+	}
+	return 0
+}
+
+// ReadPrintTool is PrintTool for G-code in any supported format, reading
+// only up to the first tool selection
+func ReadPrintTool(r io.Reader) (int, error) {
+	reader, err := NewGCodeReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(strings.SplitN(scanner.Text(), ";", 2)[0])
+		if len(fields) == 0 {
+			continue
+		}
+		command := strings.ToUpper(fields[0])
+		if len(command) > 1 && command[0] == 'T' {
+			if tool, ok := parseToolNumber(command); ok {
+				return tool, nil
+			}
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// fileTool returns the tool a file on the backend prints with. Printers with
+// a single tool always use tool 0, so the file is not downloaded for them.
+func fileTool(backend *BackendClient, fileName string, tools int) int {
+	if backend == nil || fileName == "" || tools <= 1 {
+		return 0
+	}
+	reader, err := backend.DownloadGCode(fileName)
+	if err != nil {
+		log.Printf("Failed to download %s to find its tool: %v", fileName, err)
+		return 0
+	}
+	defer reader.Close()
+	tool, err := ReadPrintTool(reader)
+	if err != nil {
+		log.Printf("Failed to read the tool of %s: %v", fileName, err)
+	}
+	return tool
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrintTool(t *testing.T) {
+	tests := []struct {
+		name  string
+		gcode string
+		want  int
+	}{
+		{"no tool selected", "G28\nM104 S210\nG1 X10 E1\n", 0},
+		{"first selection wins", "G28\nT1\nG1 X10 E1\nT0\n", 1},
+		{"comments are ignored", "; T2 is the support tool\nM104 S210 ; T2\nt3\nG1 X10 E1\n", 3},
+		{"macros are not tools", "TEMPERATURE_WAIT SENSOR=extruder\nT2\n", 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ReadPrintTool(strings.NewReader(test.gcode))
+			if err != nil || got != test.want {
+				t.Errorf("ReadPrintTool = %d, %v; want %d", got, err, test.want)
+			}
+
+			model, err := NewGCodeParser().ParseGCode(strings.NewReader(test.gcode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := PrintTool(model); got != test.want {
+				t.Errorf("PrintTool = %d, want %d", got, test.want)
+			}
+		})
+	}
+	if got := PrintTool(nil); got != 0 {
+		t.Errorf("PrintTool(nil) = %d, want 0", got)
+	}
+}
+
+func TestConsumeDeductsFromTool(t *testing.T) {
+	inv := NewSpoolInventory(filepath.Join(t.TempDir(), "spools.json"))
+	first, err := inv.Add(Spool{Name: "First", Material: "PLA", InitialWeight: 1000, RemainingWeight: 1000, Cost: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := inv.Add(Spool{Name: "Second", Material: "PETG", InitialWeight: 1000, RemainingWeight: 1000, Cost: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.LoadSpool(0, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.LoadSpool(1, second.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	estimate := inv.Usage(1, 10000)
+	if spool, _ := inv.Get(second.ID); spool.RemainingWeight != 1000 {
+		t.Errorf("Usage deducted filament: %.1f g left", spool.RemainingWeight)
+	}
+
+	usage, err := inv.Consume(1, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != estimate {
+		t.Errorf("Consume = %+v, Usage = %+v", usage, estimate)
+	}
+	if usage.SpoolID != second.ID || usage.Material != "PETG" || usage.Grams <= 0 || usage.Cost <= 0 {
+		t.Errorf("usage = %+v, want spool %d", usage, second.ID)
+	}
+	if spool, _ := inv.Get(second.ID); spool.RemainingWeight != 1000-usage.Grams {
+		t.Errorf("tool 1 spool has %.1f g left, want %.1f g", spool.RemainingWeight, 1000-usage.Grams)
+	}
+	if spool, _ := inv.Get(first.ID); spool.RemainingWeight != 1000 {
+		t.Errorf("tool 0 spool should be untouched, has %.1f g left", spool.RemainingWeight)
+	}
+
+	if warning := inv.CheckJob(1, 1e6); !strings.Contains(warning, "tool 1") {
+		t.Errorf("CheckJob warning %q should name tool 1", warning)
+	}
+	if usage, err := inv.Consume(2, 1000); err != nil || usage.SpoolID != 0 {
+		t.Errorf("Consume on an empty tool = %+v, %v", usage, err)
+	}
+}
+
+func TestExtrusionTracker(t *testing.T) {
+	var tracker ExtrusionTracker
+	statuses := []PrinterStatus{
+		{Status: "idle"},
+		{Status: "printing", FileName: "bracket.gcode", FilamentUsed: 100},
+		{Status: "paused", FilamentUsed: 250},
+		{Status: "printing", FileName: "bracket.gcode", FilamentUsed: 400},
+	}
+	for _, status := range statuses {
+		if _, ended := tracker.Observe(status); ended {
+			t.Fatalf("print reported ended at %+v", status)
+		}
+	}
+
+	used, ended := tracker.Observe(PrinterStatus{Status: "idle"})
+	if !ended || used != 400 || tracker.FileName() != "bracket.gcode" {
+		t.Errorf("end of print = %.0f mm, %v, %q; want 400 mm of bracket.gcode", used, ended, tracker.FileName())
+	}
+	if _, ended := tracker.Observe(PrinterStatus{Status: "idle"}); ended {
+		t.Error("an idle printer should not end the print twice")
+	}
+
+	tracker.Observe(PrinterStatus{Status: "printing", FilamentUsed: 10})
+	if tracker.FileName() != "" {
+		t.Errorf("a new print without a file name kept %q", tracker.FileName())
+	}
+}
+
+func TestExtrusionTrackerProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		end      PrinterStatus
+		progress float64
+	}{
+		{"cancelled", PrinterStatus{Status: "cancelled"}, 0.4},
+		{"completed", PrinterStatus{Status: "complete"}, 1},
+		{"reported at the end", PrinterStatus{Status: "error", Progress: 0.45}, 0.45},
+	}
+	for _, test := range tests {
+		var tracker ExtrusionTracker
+		tracker.Observe(PrinterStatus{Status: "printing", FileName: "bracket.gcode", Progress: 0.25})
+		tracker.Observe(PrinterStatus{Status: "paused", Progress: 0.4})
+		used, ended := tracker.Observe(test.end)
+		if !ended || used != 0 || tracker.Progress() != test.progress {
+			t.Errorf("%s: end of print = %.0f mm at %g, %v; want 0 mm at %g",
+				test.name, used, tracker.Progress(), ended, test.progress)
+		}
+	}
+}
+
+func TestReadToolExtrusion(t *testing.T) {
+	tests := []struct {
+		name  string
+		gcode string
+		want  map[int]float64
+	}{
+		{"absolute extrusion", "M82\nG92 E0\nG1 X10 E5\nG1 X20 E12\nG92 E0\nG1 X30 E3\n", map[int]float64{0: 15}},
+		{"retractions cancel out", "M83\nG1 X10 E5\nG1 E-1\nG0 X20\nG1 E1\nG1 X30 E2\n", map[int]float64{0: 7}},
+		{"split by tool", "M83\nT0\nG1 X10 E5\nT1\nG1 X20 E3\nG1 X30 E4\nT0\nG1 X40 E1\n", map[int]float64{0: 6, 1: 7}},
+		{"travel only", "G28\nG1 X10 Y10\n", map[int]float64{}},
+	}
+	for _, test := range tests {
+		got, err := ReadToolExtrusion(strings.NewReader(test.gcode))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ReadToolExtrusion = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestToolExtrusion(t *testing.T) {
+	file := map[int]float64{0: 300, 1: 100}
+	tests := []struct {
+		name     string
+		measured float64
+		progress float64
+		file     map[int]float64
+		want     map[int]float64
+	}{
+		{"measured, split like the file", 200, 1, file, map[int]float64{0: 150, 1: 50}},
+		{"measured, file unknown", 200, 1, nil, map[int]float64{0: 200}},
+		{"estimated from progress", 0, 0.5, file, map[int]float64{0: 150, 1: 50}},
+		{"completed without a measurement", 0, 1, file, map[int]float64{0: 300, 1: 100}},
+		{"nothing known", 0, 0.5, nil, map[int]float64{}},
+		{"never started", 0, 0, file, map[int]float64{}},
+	}
+	for _, test := range tests {
+		if got := toolExtrusion(test.measured, test.progress, test.file); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: toolExtrusion = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// spoolMaterials are offered when adding a spool
+var spoolMaterials = []string{"PLA", "PETG", "ABS", "ASA", "TPU", "PA", "PC", "PVA", "HIPS"}
+
+// SpoolInventoryUI manages the spools and what each tool is loaded with
+type SpoolInventoryUI struct {
+	window    fyne.Window
+	inventory *SpoolInventory
+	tools     int
+
+	list      *widget.List
+	toolsBox  *fyne.Container
+	spools    []Spool
+	selected  int
+	editBtn   *widget.Button
+	deleteBtn *widget.Button
+	loadBtn   *widget.Button
+}
+
+// NewSpoolInventoryUI creates the spool manager for a printer with the given
+// number of tools
+func NewSpoolInventoryUI(window fyne.Window, inventory *SpoolInventory, tools int) *SpoolInventoryUI {
+	if tools < 1 {
+		tools = 1
+	}
+	return &SpoolInventoryUI{
+		window:    window,
+		inventory: inventory,
+		tools:     tools,
+		selected:  -1,
+	}
+}
+
+// CreateUI builds the spool manager
+func (ui *SpoolInventoryUI) CreateUI() fyne.CanvasObject {
+	ui.list = widget.NewList(
+		func() int { return len(ui.spools) },
+		func() fyne.CanvasObject {
+			swatch := canvas.NewRectangle(color.Black)
+			swatch.SetMinSize(fyne.NewSize(24, 24))
+			return container.NewHBox(
+				container.NewCenter(swatch),
+				container.NewVBox(
+					widget.NewLabel("Spool name"),
+					widget.NewLabel("Details"),
+				),
+				layout.NewSpacer(),
+				widget.NewLabel("Remaining"),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(ui.spools) {
+				return
+			}
+
+			spool := ui.spools[id]
+			hbox := obj.(*fyne.Container)
+
+			swatch := hbox.Objects[0].(*fyne.Container).Objects[0].(*canvas.Rectangle)
+			swatch.FillColor = spoolColor(spool.Color)
+			swatch.Refresh()
+
+			name := spool.Label()
+			if tool := ui.inventory.ToolOf(spool.ID); tool >= 0 {
+				name = fmt.Sprintf("%s - loaded on T%d", name, tool)
+			}
+			details := fmt.Sprintf("%.2f mm | %.2f g/cm³", spool.Diameter, spool.Density)
+			if spool.Vendor != "" {
+				details = spool.Vendor + " | " + details
+			}
+			if spool.Cost > 0 {
+				details += fmt.Sprintf(" | %.2f per kg", spool.CostPerGram()*1000)
+			}
+			if spool.SpoolmanID != 0 {
+				details += fmt.Sprintf(" | Spoolman #%d", spool.SpoolmanID)
+			}
+
+			info := hbox.Objects[1].(*fyne.Container)
+			info.Objects[0].(*widget.Label).SetText(name)
+			info.Objects[1].(*widget.Label).SetText(details)
+
+			remaining := fmt.Sprintf("%.0f g", spool.RemainingWeight)
+			if spool.InitialWeight > 0 {
+				remaining = fmt.Sprintf("%.0f / %.0f g", spool.RemainingWeight, spool.InitialWeight)
+			}
+			if spool.IsLow() {
+				remaining = "⚠ " + remaining
+			}
+			hbox.Objects[3].(*widget.Label).SetText(remaining)
+		},
+	)
+	ui.list.OnSelected = func(id widget.ListItemID) {
+		ui.selected = id
+		ui.updateButtons()
+	}
+	ui.list.OnUnselected = func(id widget.ListItemID) {
+		ui.selected = -1
+		ui.updateButtons()
+	}
+
+	addBtn := widget.NewButtonWithIcon("Add Spool", theme.ContentAddIcon(), func() {
+		ui.showSpoolDialog(Spool{})
+	})
+	ui.editBtn = widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), func() {
+		if spool, ok := ui.selectedSpool(); ok {
+			ui.showSpoolDialog(spool)
+		}
+	})
+	ui.deleteBtn = widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
+		spool, ok := ui.selectedSpool()
+		if !ok {
+			return
+		}
+		dialog.ShowConfirm("Delete Spool", fmt.Sprintf("Remove %s from the inventory?", spool.Label()), func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			if err := ui.inventory.Remove(spool.ID); err != nil {
+				dialog.ShowError(err, ui.window)
+			}
+		}, ui.window)
+	})
+	ui.deleteBtn.Importance = widget.DangerImportance
+	ui.loadBtn = widget.NewButtonWithIcon("Load on Tool", theme.DownloadIcon(), func() {
+		if spool, ok := ui.selectedSpool(); ok {
+			ui.showLoadDialog(spool)
+		}
+	})
+	spoolmanBtn := widget.NewButtonWithIcon("Spoolman", theme.ViewRefreshIcon(), func() {
+		ui.showSpoolmanDialog()
+	})
+
+	ui.toolsBox = container.NewVBox()
+	ui.inventory.SetOnChange(ui.refresh)
+	ui.refresh()
+
+	buttons := container.NewGridWithColumns(5, addBtn, ui.editBtn, ui.deleteBtn, ui.loadBtn, spoolmanBtn)
+	return container.NewBorder(
+		widget.NewCard("Loaded Spools", "", ui.toolsBox),
+		container.NewPadded(buttons),
+		nil, nil,
+		ui.list,
+	)
+}
+
+// refresh reloads the spools and loaded tools
+func (ui *SpoolInventoryUI) refresh() {
+	ui.spools = ui.inventory.Spools()
+	if ui.selected >= len(ui.spools) {
+		ui.selected = -1
+		ui.list.UnselectAll()
+	}
+	ui.list.Refresh()
+
+	ui.toolsBox.Objects = nil
+	for tool := 0; tool < ui.tools; tool++ {
+		tool := tool
+		text := fmt.Sprintf("T%d: no spool loaded", tool)
+		spool, loaded := ui.inventory.Loaded(tool)
+		if loaded {
+			text = fmt.Sprintf("T%d: %s - %.0f g left", tool, spool.Label(), spool.RemainingWeight)
+			if spool.IsLow() {
+				text += " (low)"
+			}
+		}
+		unloadBtn := widget.NewButton("Unload", func() {
+			if err := ui.inventory.Unload(tool); err != nil {
+				dialog.ShowError(err, ui.window)
+			}
+		})
+		if !loaded {
+			unloadBtn.Disable()
+		}
+		ui.toolsBox.Add(container.NewBorder(nil, nil, nil, unloadBtn, widget.NewLabel(text)))
+	}
+	ui.toolsBox.Refresh()
+	ui.updateButtons()
+}
+
+// updateButtons enables the actions that need a selected spool
+func (ui *SpoolInventoryUI) updateButtons() {
+	for _, btn := range []*widget.Button{ui.editBtn, ui.deleteBtn, ui.loadBtn} {
+		if btn == nil {
+			continue
+		}
+		if ui.selected >= 0 {
+			btn.Enable()
+		} else {
+			btn.Disable()
+		}
+	}
+}
+
+// selectedSpool returns the spool selected in the list
+func (ui *SpoolInventoryUI) selectedSpool() (Spool, bool) {
+	if ui.selected < 0 || ui.selected >= len(ui.spools) {
+		return Spool{}, false
+	}
+	return ui.spools[ui.selected], true
+}
+
+// showSpoolDialog adds a spool, or edits it when it has an ID
+func (ui *SpoolInventoryUI) showSpoolDialog(spool Spool) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(spool.Name)
+	nameEntry.SetPlaceHolder("e.g. Galaxy Black")
+	vendorEntry := widget.NewEntry()
+	vendorEntry.SetText(spool.Vendor)
+	materialSelect := widget.NewSelectEntry(spoolMaterials)
+	materialSelect.SetText(spool.Material)
+	colorEntry := widget.NewEntry()
+	colorEntry.SetText(spool.Color)
+	colorEntry.SetPlaceHolder("#RRGGBB")
+
+	numberEntry := func(value float64, placeholder string) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder(placeholder)
+		if value != 0 {
+			entry.SetText(strconv.FormatFloat(value, 'f', -1, 64))
+		}
+		return entry
+	}
+	diameterEntry := numberEntry(spool.Diameter, "1.75")
+	densityEntry := numberEntry(spool.Density, "From material")
+	initialEntry := numberEntry(spool.InitialWeight, "e.g. 1000")
+	remainingEntry := numberEntry(spool.RemainingWeight, "Same as spool weight")
+	costEntry := numberEntry(spool.Cost, "e.g. 24.99")
+
+	title, confirm := "Add Spool", "Add"
+	if spool.ID != 0 {
+		title, confirm = "Edit "+spool.Label(), "Save"
+	}
+
+	dialog.ShowForm(title, confirm, "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Vendor", vendorEntry),
+		widget.NewFormItem("Material", materialSelect),
+		widget.NewFormItem("Color", colorEntry),
+		widget.NewFormItem("Diameter (mm)", diameterEntry),
+		widget.NewFormItem("Density (g/cm³)", densityEntry),
+		widget.NewFormItem("Spool weight (g)", initialEntry),
+		widget.NewFormItem("Remaining (g)", remainingEntry),
+		widget.NewFormItem("Cost", costEntry),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		edited := spool
+		edited.Name = nameEntry.Text
+		edited.Vendor = strings.TrimSpace(vendorEntry.Text)
+		edited.Material = materialSelect.Text
+		edited.Color = strings.TrimSpace(colorEntry.Text)
+		if edited.Color != "" && !strings.HasPrefix(edited.Color, "#") {
+			edited.Color = "#" + edited.Color
+		}
+
+		for _, field := range []struct {
+			entry *widget.Entry
+			name  string
+			value *float64
+		}{
+			{diameterEntry, "diameter", &edited.Diameter},
+			{densityEntry, "density", &edited.Density},
+			{initialEntry, "spool weight", &edited.InitialWeight},
+			{remainingEntry, "remaining weight", &edited.RemainingWeight},
+			{costEntry, "cost", &edited.Cost},
+		} {
+			*field.value = 0
+			if text := strings.TrimSpace(field.entry.Text); text != "" {
+				var err error
+				if *field.value, err = strconv.ParseFloat(text, 64); err != nil {
+					dialog.ShowError(fmt.Errorf("invalid %s %q", field.name, text), ui.window)
+					return
+				}
+			}
+		}
+		if strings.TrimSpace(remainingEntry.Text) == "" {
+			edited.RemainingWeight = edited.InitialWeight
+		}
+
+		var err error
+		if spool.ID == 0 {
+			_, err = ui.inventory.Add(edited)
+		} else {
+			err = ui.inventory.Edit(edited)
+		}
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+		}
+	}, ui.window)
+}
+
+// showLoadDialog asks which tool to load a spool on
+func (ui *SpoolInventoryUI) showLoadDialog(spool Spool) {
+	options := make([]string, ui.tools)
+	for tool := range options {
+		options[tool] = fmt.Sprintf("T%d", tool)
+	}
+	toolSelect := widget.NewSelect(options, nil)
+	toolSelect.SetSelectedIndex(0)
+
+	dialog.ShowForm("Load "+spool.Label(), "Load", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Tool", toolSelect),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if err := ui.inventory.LoadSpool(toolSelect.SelectedIndex(), spool.ID); err != nil {
+			dialog.ShowError(err, ui.window)
+		}
+	}, ui.window)
+}
+
+// showSpoolmanDialog sets the Spoolman server and pulls its spools
+func (ui *SpoolInventoryUI) showSpoolmanDialog() {
+	urlEntry := widget.NewEntry()
+	urlEntry.SetText(ui.inventory.SpoolmanURL())
+	urlEntry.SetPlaceHolder("http://spoolman.local:7912 (empty to disable)")
+
+	dialog.ShowForm("Spoolman Sync", "Sync", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Server", urlEntry),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if err := ui.inventory.SetSpoolmanURL(urlEntry.Text); err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		if ui.inventory.SpoolmanURL() == "" {
+			return
+		}
+
+		go func() {
+			added, updated, err := SyncSpoolman(ui.inventory)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("Spoolman sync failed: %v", err), ui.window)
+				return
+			}
+			dialog.ShowInformation("Spoolman Sync",
+				fmt.Sprintf("%d spools added, %d updated", added, updated), ui.window)
+		}()
+	}, ui.window)
+}
+
+// spoolColor parses a #RRGGBB spool color, falling back to gray
+func spoolColor(hex string) color.Color {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(strings.TrimPrefix(hex, "#"), "%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.NRGBA{R: 142, G: 142, B: 147, A: 255}
+	}
+	return color.NRGBA{R: r, G: g, B: b, A: 255}
+}
+
+// SetSpoolInventory records which spool finished jobs drew their filament
+// from and warns before starting a job that will not fit. The filament
+// itself is deducted by the app as prints end.
+func (ui *PrintJobsUI) SetSpoolInventory(inventory *SpoolInventory) {
+	ui.spools = inventory
+}
+
+// printTool returns the tool a file prints with
+func (ui *PrintJobsUI) printTool(fileName string) int {
+	if ui.spools == nil || ui.profile == nil {
+		return 0
+	}
+	return fileTool(ui.backend, fileName, ui.profile.NozzleCount)
+}
+
+// confirmFilament runs start, first asking for confirmation when the spool
+// on the tool is short of a job's filament length
+func (ui *PrintJobsUI) confirmFilament(tool int, lengthMM float64, start func()) {
+	if ui.spools == nil {
+		start()
+		return
+	}
+	warning := ui.spools.CheckJob(tool, lengthMM)
+	if warning == "" {
+		start()
+		return
+	}
+	dialog.ShowConfirm("Low Filament", warning+"\n\nStart the print anyway?", func(confirmed bool) {
+		if confirmed {
+			start()
+		}
+	}, ui.window)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SpoolmanClient talks to a Spoolman-compatible filament manager
+type SpoolmanClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// spoolmanSpool is a spool as Spoolman reports it
+type spoolmanSpool struct {
+	ID              int      `json:"id"`
+	RemainingWeight *float64 `json:"remaining_weight"`
+	InitialWeight   *float64 `json:"initial_weight"`
+	Price           *float64 `json:"price"`
+	Archived        bool     `json:"archived"`
+	Filament        struct {
+		Name     string   `json:"name"`
+		Material string   `json:"material"`
+		ColorHex string   `json:"color_hex"`
+		Diameter float64  `json:"diameter"`
+		Density  float64  `json:"density"`
+		Weight   *float64 `json:"weight"`
+		Price    *float64 `json:"price"`
+		Vendor   *struct {
+			Name string `json:"name"`
+		} `json:"vendor"`
+	} `json:"filament"`
+}
+
+// NewSpoolmanClient creates a client for the Spoolman server at baseURL,
+// e.g. http://spoolman.local:7912
+func NewSpoolmanClient(baseURL string) *SpoolmanClient {
+	return &SpoolmanClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// FetchSpools returns the spools that are not archived
+func (c *SpoolmanClient) FetchSpools() ([]Spool, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/spool")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch spools: %s", resp.Status)
+	}
+
+	var remote []spoolmanSpool
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err != nil {
+		return nil, fmt.Errorf("invalid Spoolman response: %v", err)
+	}
+
+	spools := []Spool{}
+	for _, s := range remote {
+		if s.Archived {
+			continue
+		}
+		spools = append(spools, s.toSpool())
+	}
+	return spools, nil
+}
+
+// UseFilament reports filament taken from a spool
+func (c *SpoolmanClient) UseFilament(spoolmanID int, grams float64) error {
+	jsonData, err := json.Marshal(map[string]float64{"use_weight": grams})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/api/v1/spool/%d/use", c.baseURL, spoolmanID)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to report filament use: %s", resp.Status)
+	}
+	return nil
+}
+
+// toSpool converts a Spoolman spool. The spool's own price and weight take
+// precedence over those of its filament.
+func (s *spoolmanSpool) toSpool() Spool {
+	spool := Spool{
+		SpoolmanID: s.ID,
+		Name:       s.Filament.Name,
+		Material:   strings.ToUpper(s.Filament.Material),
+		Diameter:   s.Filament.Diameter,
+		Density:    s.Filament.Density,
+	}
+	if s.Filament.Vendor != nil {
+		spool.Vendor = s.Filament.Vendor.Name
+	}
+	if s.Filament.ColorHex != "" {
+		spool.Color = "#" + strings.TrimPrefix(s.Filament.ColorHex, "#")
+	}
+	if s.InitialWeight != nil {
+		spool.InitialWeight = *s.InitialWeight
+	} else if s.Filament.Weight != nil {
+		spool.InitialWeight = *s.Filament.Weight
+	}
+	if s.RemainingWeight != nil {
+		spool.RemainingWeight = *s.RemainingWeight
+	} else {
+		spool.RemainingWeight = spool.InitialWeight
+	}
+	if s.Price != nil {
+		spool.Cost = *s.Price
+	} else if s.Filament.Price != nil {
+		spool.Cost = *s.Filament.Price
+	}
+	if spool.Diameter == 0 {
+		spool.Diameter = defaultFilamentDiameter
+	}
+	if spool.Density == 0 {
+		spool.Density = materialDensity(spool.Material)
+	}
+	return spool
+}
+
+// SyncSpoolman fetches the spools of the configured Spoolman server into the
+// inventory
+func SyncSpoolman(inv *SpoolInventory) (added, updated int, err error) {
+	url := inv.SpoolmanURL()
+	if url == "" {
+		return 0, 0, fmt.Errorf("no Spoolman server configured")
+	}
+	spools, err := NewSpoolmanClient(url).FetchSpools()
+	if err != nil {
+		return 0, 0, err
+	}
+	return inv.MergeSpoolman(spools)
+}
+
+// ReportSpoolUsage passes filament use on to Spoolman when the spool came
+// from there
+func ReportSpoolUsage(inv *SpoolInventory, usage SpoolUsage) error {
+	url := inv.SpoolmanURL()
+	if url == "" || usage.SpoolmanID == 0 || usage.Grams <= 0 {
+		return nil
+	}
+	return NewSpoolmanClient(url).UseFilament(usage.SpoolmanID, usage.Grams)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// spoolmanStub serves a fixed spool list and records filament use reports
+type spoolmanStub struct {
+	mu     sync.Mutex
+	spools string
+	used   map[string]float64 // use_weight per request path
+	fail   bool
+}
+
+func (s *spoolmanStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/spool":
+		w.Write([]byte(s.spools))
+	case r.Method == http.MethodPut:
+		var body map[string]float64
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.used[r.URL.Path] += body["use_weight"]
+		w.Write([]byte("{}"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+const spoolmanStubSpools = `[
+	{"id": 7, "remaining_weight": 640, "initial_weight": 1000, "price": 25,
+	 "filament": {"name": "Galaxy Black", "material": "petg", "color_hex": "1a1a1a",
+	              "diameter": 1.75, "density": 1.27, "price": 30, "vendor": {"name": "Prusament"}}},
+	{"id": 8, "filament": {"name": "Basic White", "material": "PLA", "weight": 750}},
+	{"id": 9, "archived": true, "filament": {"name": "Empty", "material": "PLA"}}
+]`
+
+func newSpoolmanStub(t *testing.T) (*spoolmanStub, *SpoolInventory) {
+	t.Helper()
+	stub := &spoolmanStub{spools: spoolmanStubSpools, used: map[string]float64{}}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	inv := NewSpoolInventory(filepath.Join(t.TempDir(), "spools.json"))
+	if err := inv.SetSpoolmanURL(server.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	return stub, inv
+}
+
+func TestSyncSpoolman(t *testing.T) {
+	_, inv := newSpoolmanStub(t)
+
+	added, updated, err := SyncSpoolman(inv)
+	if err != nil || added != 2 || updated != 0 {
+		t.Fatalf("first sync = %d added, %d updated, %v; want 2, 0, nil", added, updated, err)
+	}
+	spools := inv.Spools()
+	if len(spools) != 2 {
+		t.Fatalf("got %d spools, want 2 (archived ones are skipped)", len(spools))
+	}
+
+	petg := spools[0]
+	if petg.SpoolmanID != 7 || petg.Material != "PETG" || petg.Color != "#1a1a1a" || petg.Vendor != "Prusament" {
+		t.Errorf("spool 7 converted as %+v", petg)
+	}
+	if petg.Cost != 25 || petg.InitialWeight != 1000 || petg.RemainingWeight != 640 {
+		t.Errorf("spool 7 should keep its own price and weights, got %+v", petg)
+	}
+
+	pla := spools[1]
+	if pla.InitialWeight != 750 || pla.RemainingWeight != 750 {
+		t.Errorf("spool 8 should fall back to the filament weight, got %+v", pla)
+	}
+	if pla.Diameter != defaultFilamentDiameter || pla.Density != materialDensity("PLA") {
+		t.Errorf("spool 8 should get default diameter and density, got %+v", pla)
+	}
+
+	if err := inv.LoadSpool(1, petg.ID); err != nil {
+		t.Fatal(err)
+	}
+	added, updated, err = SyncSpoolman(inv)
+	if err != nil || added != 0 || updated != 2 {
+		t.Fatalf("second sync = %d added, %d updated, %v; want 0, 2, nil", added, updated, err)
+	}
+	if loaded, ok := inv.Loaded(1); !ok || loaded.ID != petg.ID {
+		t.Errorf("resync should keep the spool on tool 1, got %+v, %v", loaded, ok)
+	}
+}
+
+func TestReportSpoolUsage(t *testing.T) {
+	stub, inv := newSpoolmanStub(t)
+	if _, _, err := SyncSpoolman(inv); err != nil {
+		t.Fatal(err)
+	}
+	local, err := inv.Add(Spool{Name: "Local", Material: "PLA", InitialWeight: 1000, RemainingWeight: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	petg := inv.Spools()[0]
+	if err := inv.LoadSpool(1, petg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.LoadSpool(0, local.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := inv.Consume(1, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ReportSpoolUsage(inv, usage); err != nil {
+		t.Fatal(err)
+	}
+	if got := stub.used["/api/v1/spool/7/use"]; math.Abs(got-usage.Grams) > 1e-9 || got == 0 {
+		t.Errorf("Spoolman was told %.3f g, want %.3f g", got, usage.Grams)
+	}
+
+	// Spools Spoolman does not know are not reported
+	usage, err = inv.Consume(0, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ReportSpoolUsage(inv, usage); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.used) != 1 {
+		t.Errorf("expected only spool 7 to be reported, got %v", stub.used)
+	}
+}
+
+func TestSpoolmanErrors(t *testing.T) {
+	stub, inv := newSpoolmanStub(t)
+	if _, _, err := SyncSpoolman(inv); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.LoadSpool(0, inv.Spools()[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := inv.Consume(0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub.fail = true
+	if _, _, err := SyncSpoolman(inv); err == nil {
+		t.Error("sync against a failing server should fail")
+	}
+	if err := ReportSpoolUsage(inv, usage); err == nil {
+		t.Error("reporting to a failing server should fail")
+	}
+
+	stub.fail = false
+	stub.spools = "not json"
+	if _, _, err := SyncSpoolman(inv); err == nil {
+		t.Error("sync of an invalid response should fail")
+	}
+
+	if _, _, err := SyncSpoolman(NewSpoolInventory(filepath.Join(t.TempDir(), "spools.json"))); err == nil {
+		t.Error("sync without a server should fail")
+	}
+}
@@ -12,13 +12,39 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
-// TemperatureDataPoint represents a single temperature measurement
+// TemperatureDataPoint represents a single temperature measurement of
+// every heater and sensor, indexed by heater name
 type TemperatureDataPoint struct {
 	Timestamp      time.Time
-	HotendActual   float64
-	HotendTarget   float64
-	BedActual      float64
-	BedTarget      float64
+	Heaters        map[string]HeaterReading
+}
+
+// NewTemperatureDataPoint indexes readings taken together by heater name
+func NewTemperatureDataPoint(timestamp time.Time, readings []HeaterReading) TemperatureDataPoint {
+	point := TemperatureDataPoint{
+		Timestamp: timestamp,
+		Heaters:   make(map[string]HeaterReading, len(readings)),
+	}
+	for _, reading := range readings {
+		point.Heaters[reading.Name] = reading
+	}
+	return point
+}
+
+// Reading returns the reading of one heater
+func (p *TemperatureDataPoint) Reading(name string) (HeaterReading, bool) {
+	reading, ok := p.Heaters[name]
+	return reading, ok
+}
+
+// Readings returns the readings in display order
+func (p *TemperatureDataPoint) Readings() []HeaterReading {
+	readings := make([]HeaterReading, 0, len(p.Heaters))
+	for _, reading := range p.Heaters {
+		readings = append(readings, reading)
+	}
+	sortHeaters(readings)
+	return readings
 }
 
 // TemperatureChart displays real-time temperature data
@@ -41,11 +67,11 @@ type TemperatureChart struct {
 	panOffsetX    float64
 	panOffsetY    float64
 	
+	// Heaters seen so far, in legend order, and their colours
+	heaters       []HeaterReading
+	heaterColors  map[string]color.NRGBA
+	
 	// Colors
-	hotendActualColor color.Color
-	hotendTargetColor color.Color
-	bedActualColor    color.Color
-	bedTargetColor    color.Color
 	gridColor         color.Color
 	textColor         color.Color
 	
@@ -66,12 +92,9 @@ func NewTemperatureChart() *TemperatureChart {
 		minTemp:       0,
 		maxTemp:       300,
 		zoomLevel:     1.0,
+		heaterColors:  make(map[string]color.NRGBA),
 		
 		// Colors
-		hotendActualColor: color.NRGBA{R: 255, G: 69, B: 58, A: 255},   // Red
-		hotendTargetColor: color.NRGBA{R: 255, G: 149, B: 0, A: 255},   // Orange
-		bedActualColor:    color.NRGBA{R: 52, G: 199, B: 89, A: 255},   // Green
-		bedTargetColor:    color.NRGBA{R: 48, G: 176, B: 199, A: 255},  // Blue
 		gridColor:         color.NRGBA{R: 200, G: 200, B: 200, A: 128}, // Light gray
 		textColor:         color.NRGBA{R: 28, G: 28, B: 30, A: 255},    // Dark
 	}
@@ -89,12 +112,51 @@ func (t *TemperatureChart) AddDataPoint(point TemperatureDataPoint) {
 		t.dataPoints = t.dataPoints[1:]
 	}
 	
+	t.trackHeaters(point)
+	
 	// Auto-scale Y axis
 	t.updateScale()
 	
 	t.Refresh()
 }
 
+// trackHeaters keeps the latest reading of every heater for the legend and
+// gives heaters seen for the first time a colour
+func (t *TemperatureChart) trackHeaters(point TemperatureDataPoint) {
+	added := false
+	for name, reading := range point.Heaters {
+		found := false
+		for i := range t.heaters {
+			if t.heaters[i].Name == name {
+				t.heaters[i] = reading
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.heaters = append(t.heaters, reading)
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	
+	// Colour each kind of heater from its own palette, in display order
+	sortHeaters(t.heaters)
+	perKind := make(map[HeaterKind]int)
+	for _, heater := range t.heaters {
+		kind := heater.HeaterKind()
+		t.heaterColors[heater.Name] = heaterColor(kind, perKind[kind])
+		perKind[kind]++
+	}
+}
+
+// Heaters returns the latest reading of every heater charted, in legend order
+func (t *TemperatureChart) Heaters() []HeaterReading {
+	return append([]HeaterReading(nil), t.heaters...)
+}
+
 // updateScale automatically adjusts the temperature scale
 func (t *TemperatureChart) updateScale() {
 	if len(t.dataPoints) == 0 {
@@ -105,16 +167,20 @@ func (t *TemperatureChart) updateScale() {
 	maxTemp := math.Inf(-1)
 	
 	for _, point := range t.dataPoints {
-		temps := []float64{point.HotendActual, point.HotendTarget, point.BedActual, point.BedTarget}
-		for _, temp := range temps {
-			if temp > 0 && temp < minTemp {
-				minTemp = temp
-			}
-			if temp > maxTemp {
-				maxTemp = temp
+		for _, reading := range point.Heaters {
+			for _, temp := range []float64{reading.Actual, reading.Target} {
+				if temp > 0 && temp < minTemp {
+					minTemp = temp
+				}
+				if temp > maxTemp {
+					maxTemp = temp
+				}
 			}
 		}
 	}
+	if math.IsInf(maxTemp, -1) {
+		return
+	}
 	
 	// Add some padding
 	padding := (maxTemp - minTemp) * 0.1
@@ -252,16 +318,20 @@ func (r *temperatureChartRenderer) drawTemperatureLines() []fyne.CanvasObject {
 		return fyne.NewPos(x, y)
 	}
 	
-	// Draw lines for each temperature type
-	lines := []struct {
+	// Draw an actual and a target line for each heater
+	type lineConfig struct {
 		getValue func(TemperatureDataPoint) float64
 		color    color.Color
 		width    float32
-	}{
-		{func(p TemperatureDataPoint) float64 { return p.HotendActual }, r.chart.hotendActualColor, 2},
-		{func(p TemperatureDataPoint) float64 { return p.HotendTarget }, r.chart.hotendTargetColor, 1},
-		{func(p TemperatureDataPoint) float64 { return p.BedActual }, r.chart.bedActualColor, 2},
-		{func(p TemperatureDataPoint) float64 { return p.BedTarget }, r.chart.bedTargetColor, 1},
+	}
+	lines := []lineConfig{}
+	for _, heater := range r.chart.heaters {
+		name := heater.Name
+		heaterColor := r.chart.heaterColors[name]
+		lines = append(lines,
+			lineConfig{func(p TemperatureDataPoint) float64 { return p.Heaters[name].Actual }, heaterColor, 2},
+			lineConfig{func(p TemperatureDataPoint) float64 { return p.Heaters[name].Target }, targetColor(heaterColor), 1},
+		)
 	}
 	
 	for _, lineConfig := range lines {
@@ -295,33 +365,36 @@ func (r *temperatureChartRenderer) drawTemperatureLines() []fyne.CanvasObject {
 func (r *temperatureChartRenderer) drawLegend() []fyne.CanvasObject {
 	objects := []fyne.CanvasObject{}
 	
-	legendItems := []struct {
+	type legendItem struct {
 		label string
 		color color.Color
 		width float32
-	}{
-		{"Hotend Actual", r.chart.hotendActualColor, 2},
-		{"Hotend Target", r.chart.hotendTargetColor, 1},
-		{"Bed Actual", r.chart.bedActualColor, 2},
-		{"Bed Target", r.chart.bedTargetColor, 1},
 	}
+	legendItems := []legendItem{}
+	for _, heater := range r.chart.heaters {
+		legendItems = append(legendItems, legendItem{heater.DisplayName(), r.chart.heaterColors[heater.Name], 2})
+	}
+	legendItems = append(legendItems, legendItem{"Target (faint)", r.chart.gridColor, 1})
 	
 	startY := float32(10)
 	lineHeight := float32(20)
+	columnWidth := float32(150)
+	rowsPerColumn := 4
 	
 	for i, item := range legendItems {
-		y := startY + float32(i)*lineHeight
+		x := 10 + float32(i/rowsPerColumn)*columnWidth
+		y := startY + float32(i%rowsPerColumn)*lineHeight
 		
 		// Color indicator line
 		line := canvas.NewLine(item.color)
-		line.Position1 = fyne.NewPos(10, y)
-		line.Position2 = fyne.NewPos(30, y)
+		line.Position1 = fyne.NewPos(x, y)
+		line.Position2 = fyne.NewPos(x+20, y)
 		line.StrokeWidth = item.width
 		objects = append(objects, line)
 		
 		// Label text
 		text := canvas.NewText(item.label, r.chart.textColor)
-		text.Move(fyne.NewPos(35, y-8))
+		text.Move(fyne.NewPos(x+25, y-8))
 		text.TextSize = 12
 		objects = append(objects, text)
 	}
@@ -404,6 +477,11 @@ func (t *TemperatureChart) GetCurrentTemperatures() *TemperatureDataPoint {
 	return &latest
 }
 
+// DataPoints returns a copy of the recorded readings
+func (t *TemperatureChart) DataPoints() []TemperatureDataPoint {
+	return append([]TemperatureDataPoint(nil), t.dataPoints...)
+}
+
 // ExportData triggers the export callback with current data
 func (t *TemperatureChart) ExportData() {
 	if t.onExport != nil {
@@ -419,5 +497,7 @@ func (t *TemperatureChart) SetExportCallback(callback func([]TemperatureDataPoin
 // Clear removes all data points
 func (t *TemperatureChart) Clear() {
 	t.dataPoints = make([]TemperatureDataPoint, 0)
+	t.heaters = nil
+	t.heaterColors = make(map[string]color.NRGBA)
 	t.Refresh()
 } 
\ No newline at end of file
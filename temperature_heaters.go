@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HeaterKind is what a heater or sensor measures
+type HeaterKind string
+
+const (
+	HeaterKindTool    HeaterKind = "tool"
+	HeaterKindBed     HeaterKind = "bed"
+	HeaterKindChamber HeaterKind = "chamber"
+	HeaterKindSensor  HeaterKind = "sensor" // Read only, e.g. the MCU, the Pi or the enclosure
+)
+
+// Heater names every backend understands. Backends reporting more heaters
+// also accept the names they report them under, e.g. tool1 or chamber.
+const (
+	HeaterHotend = "hotend" // The active tool
+	HeaterBed    = "bed"
+)
+
+// HeaterReading is a heater or temperature sensor at one moment
+type HeaterReading struct {
+	Name   string     `json:"name"`            // Backend ID, e.g. tool0, bed, chamber, mcu
+	Label  string     `json:"label,omitempty"` // Display name, if the backend has one
+	Kind   HeaterKind `json:"kind,omitempty"`  // Derived from the name when not reported
+	Actual float64    `json:"actual"`          // °C
+	Target float64    `json:"target"`          // °C; 0 when off, always 0 for sensors
+	Power  float64    `json:"power"`           // Heater duty cycle 0-1
+}
+
+// sensorLabels name the usual extra sensors
+var sensorLabels = map[string]string{
+	"mcu":          "MCU",
+	"pi":           "Pi",
+	"host":         "Pi",
+	"raspberry_pi": "Pi",
+	"enclosure":    "Enclosure",
+}
+
+// HeaterKind returns the reported kind, or the one its name suggests
+func (h *HeaterReading) HeaterKind() HeaterKind {
+	if h.Kind != "" {
+		return h.Kind
+	}
+	name := strings.ToLower(h.Name)
+	switch {
+	case strings.HasPrefix(name, "tool"), strings.HasPrefix(name, "extruder"), strings.HasPrefix(name, "hotend"):
+		return HeaterKindTool
+	case name == "bed", name == "heater_bed":
+		return HeaterKindBed
+	case strings.HasPrefix(name, "chamber"):
+		return HeaterKindChamber
+	}
+	return HeaterKindSensor
+}
+
+// DisplayName returns the label shown in legends and controls
+func (h *HeaterReading) DisplayName() string {
+	if h.Label != "" {
+		return h.Label
+	}
+	name := strings.ToLower(h.Name)
+	switch h.HeaterKind() {
+	case HeaterKindTool:
+		if index, ok := heaterIndex(name); ok {
+			return fmt.Sprintf("Hotend T%d", index)
+		}
+		return "Hotend"
+	case HeaterKindBed:
+		return "Bed"
+	case HeaterKindChamber:
+		return "Chamber"
+	}
+	if label, ok := sensorLabels[name]; ok {
+		return label
+	}
+	return capitalize(strings.ReplaceAll(name, "_", " "))
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	first, size := utf8.DecodeRuneInString(s)
+	if first == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(first)) + s[size:]
+}
+
+// Controllable reports whether the heater takes a target temperature
+func (h *HeaterReading) Controllable() bool {
+	return h.HeaterKind() != HeaterKindSensor
+}
+
+// MaxTarget is the highest target the touchscreen allows for the heater
+func (h *HeaterReading) MaxTarget() float64 {
+	switch h.HeaterKind() {
+	case HeaterKindBed:
+		return 120
+	case HeaterKindChamber:
+		return 80
+	}
+	return 300
+}
+
+// heaterIndex returns the number a heater name ends in, e.g. 1 for tool1
+func heaterIndex(name string) (int, bool) {
+	digits := strings.TrimLeft(name, "abcdefghijklmnopqrstuvwxyz_")
+	if digits == "" {
+		return 0, false
+	}
+	index, err := strconv.Atoi(digits)
+	return index, err == nil
+}
+
+// heaterKindOrder lists tools first, then the bed, chamber and sensors
+var heaterKindOrder = map[HeaterKind]int{
+	HeaterKindTool:    0,
+	HeaterKindBed:     1,
+	HeaterKindChamber: 2,
+	HeaterKindSensor:  3,
+}
+
+// sortHeaters orders readings tools first, then bed, chamber and sensors,
+// each by name
+func sortHeaters(readings []HeaterReading) {
+	sort.SliceStable(readings, func(i, j int) bool {
+		ki, kj := heaterKindOrder[readings[i].HeaterKind()], heaterKindOrder[readings[j].HeaterKind()]
+		if ki != kj {
+			return ki < kj
+		}
+		ni, iok := heaterIndex(strings.ToLower(readings[i].Name))
+		nj, jok := heaterIndex(strings.ToLower(readings[j].Name))
+		if iok && jok && ni != nj {
+			return ni < nj
+		}
+		return readings[i].Name < readings[j].Name
+	})
+}
+
+// Readings returns every heater and sensor of the status in display order.
+// Backends without a heater list report the hotend and bed only.
+func (s *PrinterStatus) Readings() []HeaterReading {
+	if len(s.Heaters) > 0 {
+		readings := append([]HeaterReading(nil), s.Heaters...)
+		sortHeaters(readings)
+		return readings
+	}
+	return []HeaterReading{
+		{Name: HeaterHotend, Kind: HeaterKindTool, Actual: s.Temperature, Target: s.TargetTemp},
+		{Name: HeaterBed, Kind: HeaterKindBed, Actual: s.BedTemp, Target: s.BedTarget},
+	}
+}
+
+// heaterSummary is a one line overview of heaters, e.g. for the dashboard
+func heaterSummary(readings []HeaterReading) string {
+	parts := make([]string, 0, len(readings))
+	for _, reading := range readings {
+		if reading.HeaterKind() == HeaterKindSensor {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s: %.1f°C", reading.DisplayName(), reading.Actual))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// heaterPalettes colour the heaters of each kind in turn
+var heaterPalettes = map[HeaterKind][]color.NRGBA{
+	HeaterKindTool: {
+		{R: 255, G: 69, B: 58, A: 255},  // Red
+		{R: 255, G: 149, B: 0, A: 255},  // Orange
+		{R: 255, G: 45, B: 85, A: 255},  // Pink
+		{R: 175, G: 82, B: 222, A: 255}, // Purple
+	},
+	HeaterKindBed:     {{R: 52, G: 199, B: 89, A: 255}},  // Green
+	HeaterKindChamber: {{R: 48, G: 176, B: 199, A: 255}}, // Blue
+	HeaterKindSensor: {
+		{R: 142, G: 142, B: 147, A: 255}, // Gray
+		{R: 162, G: 132, B: 94, A: 255},  // Brown
+		{R: 90, G: 200, B: 250, A: 255},  // Light blue
+	},
+}
+
+// heaterColor returns the colour of the nth heater of a kind
+func heaterColor(kind HeaterKind, n int) color.NRGBA {
+	palette := heaterPalettes[kind]
+	if len(palette) == 0 {
+		palette = heaterPalettes[HeaterKindSensor]
+	}
+	return palette[n%len(palette)]
+}
+
+// targetColor is the fainter colour a heater's target line is drawn in
+func targetColor(c color.NRGBA) color.NRGBA {
+	c.A = 140
+	return c
+}
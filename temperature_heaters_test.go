@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestHeaterDisplayName(t *testing.T) {
+	tests := []struct {
+		reading HeaterReading
+		want    string
+	}{
+		{HeaterReading{Name: "tool0"}, "Hotend T0"},
+		{HeaterReading{Name: "extruder1"}, "Hotend T1"},
+		{HeaterReading{Name: "heater_bed"}, "Bed"},
+		{HeaterReading{Name: "chamber"}, "Chamber"},
+		{HeaterReading{Name: "MCU"}, "MCU"},
+		{HeaterReading{Name: "raspberry_pi"}, "Pi"},
+		{HeaterReading{Name: "stepper_driver"}, "Stepper driver"},
+		{HeaterReading{Name: "élan"}, "Élan"},
+		{HeaterReading{Name: "bed", Label: "Build plate"}, "Build plate"},
+	}
+	for _, test := range tests {
+		if got := test.reading.DisplayName(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.reading.Name, got, test.want)
+		}
+	}
+}
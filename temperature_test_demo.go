@@ -38,7 +38,7 @@ func main() {
 	addRandomBtn := widget.NewButton("Add Random Data", func() {
 		hotend := 20 + rand.Float64()*250
 		bed := 20 + rand.Float64()*100
-		tempUI.AddTemperatureReading(hotend, hotend-5+rand.Float64()*10, bed, bed-2+rand.Float64()*4)
+		tempUI.AddTemperatureReading(demoReadings(hotend, hotend-5+rand.Float64()*10, bed, bed-2+rand.Float64()*4)...)
 	})
 	addRandomBtn.Resize(fyne.NewSize(200, 50))
 	
@@ -66,12 +66,8 @@ func main() {
 			
 			stats := "No data"
 			if current != nil {
-				stats = fmt.Sprintf(
-					"Latest: Hotend %.1f°C (target %.1f°C), Bed %.1f°C (target %.1f°C)\nData points: %d",
-					current.HotendActual, current.HotendTarget,
-					current.BedActual, current.BedTarget,
-					len(chart.dataPoints),
-				)
+				stats = fmt.Sprintf("Latest: %s\nData points: %d",
+					heaterSummary(current.Readings()), len(chart.dataPoints))
 			}
 			statsLabel.SetText(stats)
 		}
@@ -126,16 +122,42 @@ type MockBackend struct {
 }
 
 func (m *MockBackend) GetPrinterStatus() (*PrinterStatus, error) {
+	hotend := m.hotendTarget + rand.Float64()*10 - 5
+	bed := m.bedTarget + rand.Float64()*5 - 2.5
 	return &PrinterStatus{
 		Status:       m.status,
-		Temperature:  m.hotendTarget + rand.Float64()*10 - 5,
-		BedTemp:      m.bedTarget + rand.Float64()*5 - 2.5,
+		Temperature:  hotend,
+		BedTemp:      bed,
 		IsConnected:  true,
+		Heaters:      demoReadings(hotend, m.hotendTarget, bed, m.bedTarget),
 	}, nil
 }
 
+// demoReadings simulates a dual nozzle machine with a chamber and the usual
+// extra sensors; the second nozzle idles at standby temperature
+func demoReadings(hotend, hotendTarget, bed, bedTarget float64) []HeaterReading {
+	power := func(actual, target float64) float64 {
+		switch {
+		case target <= 0:
+			return 0
+		case actual >= target:
+			return 0.2 // Holding temperature
+		}
+		return math.Min(1, (target-actual)/20)
+	}
+	standby := math.Min(hotendTarget, 150)
+	return []HeaterReading{
+		{Name: "tool0", Kind: HeaterKindTool, Actual: hotend, Target: hotendTarget, Power: power(hotend, hotendTarget)},
+		{Name: "tool1", Kind: HeaterKindTool, Actual: standby + rand.Float64()*2 - 1, Target: standby, Power: 0.3},
+		{Name: "bed", Kind: HeaterKindBed, Actual: bed, Target: bedTarget, Power: power(bed, bedTarget)},
+		{Name: "chamber", Kind: HeaterKindChamber, Actual: 20 + bed/6, Target: 0},
+		{Name: "mcu", Kind: HeaterKindSensor, Actual: 38 + rand.Float64()},
+		{Name: "pi", Kind: HeaterKindSensor, Actual: 45 + rand.Float64()*2},
+	}
+}
+
 func (m *MockBackend) SetTemperature(heater string, temperature float64) error {
-	if heater == "hotend" {
+	if heater == "hotend" || heater == "tool0" {
 		m.hotendTarget = temperature
 		if temperature > 0 {
 			m.status = fmt.Sprintf("Heating hotend to %.0f°C", temperature)
@@ -175,10 +197,6 @@ func simulateHeatingCycle(tempUI *TemperatureUI) {
 	hotendTemp := 20.0
 	bedTemp := 20.0
 	
-	// Set targets
-	tempUI.hotendTarget.SetText("200")
-	tempUI.bedTarget.SetText("60")
-	
 	for i := 0; i < 300; i++ { // 5 minutes of simulation
 		// Simulate heating curves
 		hotendHeatRate := 0.8 + rand.Float64()*0.4  // 0.8-1.2°C per second
@@ -203,10 +221,10 @@ func simulateHeatingCycle(tempUI *TemperatureUI) {
 		hotendNoise := rand.Float64()*2 - 1
 		bedNoise := rand.Float64()*1 - 0.5
 		
-		tempUI.AddTemperatureReading(
+		tempUI.AddTemperatureReading(demoReadings(
 			hotendTemp+hotendNoise, hotendTarget,
 			bedTemp+bedNoise, bedTarget,
-		)
+		)...)
 		
 		time.Sleep(100 * time.Millisecond) // 10 updates per second
 	}
@@ -222,10 +240,6 @@ func simulateCooling(tempUI *TemperatureUI) {
 	bedTemp := 60.0
 	ambient := 20.0
 	
-	// Set targets to 0 (cooling down)
-	tempUI.hotendTarget.SetText("0")
-	tempUI.bedTarget.SetText("0")
-	
 	for i := 0; i < 600; i++ { // 10 minutes of cooling
 		// Exponential cooling curve
 		hotendCoolRate := (hotendTemp - ambient) * 0.01
@@ -246,10 +260,10 @@ func simulateCooling(tempUI *TemperatureUI) {
 		hotendNoise := rand.Float64()*1 - 0.5
 		bedNoise := rand.Float64()*0.5 - 0.25
 		
-		tempUI.AddTemperatureReading(
+		tempUI.AddTemperatureReading(demoReadings(
 			hotendTemp+hotendNoise, 0,
 			bedTemp+bedNoise, 0,
-		)
+		)...)
 		
 		time.Sleep(100 * time.Millisecond)
 		
@@ -279,10 +293,10 @@ func stressTestChart(tempUI *TemperatureUI) {
 		hotendNoise := rand.Float64()*4 - 2
 		bedNoise := rand.Float64()*2 - 1
 		
-		tempUI.AddTemperatureReading(
+		tempUI.AddTemperatureReading(demoReadings(
 			hotendWave+hotendNoise, baseHotend,
 			bedWave+bedNoise, baseBed,
-		)
+		)...)
 		
 		time.Sleep(10 * time.Millisecond) // Very fast updates
 	}
@@ -3,9 +3,11 @@ package main
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -24,15 +26,10 @@ type TemperatureUI struct {
 	// Chart
 	chart         *TemperatureChart
 	
-	// Controls
-	hotendTarget  *widget.Entry
-	bedTarget     *widget.Entry
-	setHotendBtn  *widget.Button
-	setBedBtn     *widget.Button
-	
-	// Current values display
-	hotendActual  *widget.Label
-	bedActual     *widget.Label
+	// Heater rows, rebuilt when the printer reports different heaters
+	heaterBox     *fyne.Container
+	heaterRows    map[string]*heaterRow
+	heaters       []HeaterReading
 	statusLabel   *widget.Label
 	
 	// Time range controls
@@ -52,6 +49,14 @@ type TemperatureUI struct {
 	content       *fyne.Container
 }
 
+// heaterRow shows one heater's actual, target and power with a target input
+type heaterRow struct {
+	actual *widget.Label
+	target *widget.Label
+	power  *widget.Label
+	entry  *widget.Entry
+}
+
 // NewTemperatureUI creates a new temperature interface
 func NewTemperatureUI(window fyne.Window, backend *BackendClient) *TemperatureUI {
 	ui := &TemperatureUI{
@@ -71,36 +76,11 @@ func NewTemperatureUI(window fyne.Window, backend *BackendClient) *TemperatureUI
 
 // createControls creates all the UI controls
 func (ui *TemperatureUI) createControls() {
-	// Current temperature displays
-	ui.hotendActual = widget.NewLabel("0°C")
-	ui.hotendActual.TextStyle = fyne.TextStyle{Bold: true}
-	
-	ui.bedActual = widget.NewLabel("0°C")
-	ui.bedActual.TextStyle = fyne.TextStyle{Bold: true}
-	
 	ui.statusLabel = widget.NewLabel("Standby")
 	
-	// Temperature target inputs
-	ui.hotendTarget = widget.NewEntry()
-	ui.hotendTarget.SetPlaceHolder("200")
-	ui.hotendTarget.Resize(fyne.NewSize(80, 40))
-	
-	ui.bedTarget = widget.NewEntry()
-	ui.bedTarget.SetPlaceHolder("60")
-	ui.bedTarget.Resize(fyne.NewSize(80, 40))
-	
-	// Set temperature buttons
-	ui.setHotendBtn = widget.NewButton("Set Hotend", func() {
-		ui.setHotendTemperature()
-	})
-	ui.setHotendBtn.Importance = widget.HighImportance
-	ui.setHotendBtn.Resize(fyne.NewSize(120, 50))
-	
-	ui.setBedBtn = widget.NewButton("Set Bed", func() {
-		ui.setBedTemperature()
-	})
-	ui.setBedBtn.Importance = widget.HighImportance
-	ui.setBedBtn.Resize(fyne.NewSize(120, 50))
+	// Heater rows start with the hotend and bed every printer has
+	ui.heaterBox = container.NewVBox()
+	ui.showHeaters((&PrinterStatus{}).Readings())
 	
 	// Time range selector
 	ui.timeRangeSelect = widget.NewSelect(
@@ -144,33 +124,20 @@ func (ui *TemperatureUI) createControls() {
 
 // createLayout creates the UI layout
 func (ui *TemperatureUI) createLayout() {
-	// Current temperature card
-	currentTempCard := widget.NewCard("Current Temperatures", "", container.NewVBox(
-		container.NewGridWithColumns(4,
-			widget.NewLabel("Hotend:"),
-			ui.hotendActual,
-			widget.NewLabel("Bed:"),
-			ui.bedActual,
-		),
-		ui.statusLabel,
-	))
-	
-	// Temperature control card
-	controlCard := widget.NewCard("Temperature Control", "", container.NewVBox(
-		// Hotend controls
-		container.NewGridWithColumns(3,
-			widget.NewLabel("Hotend Target:"),
-			ui.hotendTarget,
-			ui.setHotendBtn,
-		),
-		// Bed controls
-		container.NewGridWithColumns(3,
-			widget.NewLabel("Bed Target:"),
-			ui.bedTarget,
-			ui.setBedBtn,
+	// Heaters card: actual, target and power of every heater and sensor
+	heaterCard := widget.NewCard("Heaters", "", container.NewVBox(
+		container.NewGridWithColumns(6,
+			widget.NewLabelWithStyle("Heater", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabelWithStyle("Actual", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabelWithStyle("Target", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabelWithStyle("Power", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			layout.NewSpacer(),
+			layout.NewSpacer(),
 		),
+		ui.heaterBox,
 		// Quick preset buttons
 		ui.createPresetButtons(),
+		ui.statusLabel,
 	))
 	
 	// Chart controls card
@@ -191,11 +158,7 @@ func (ui *TemperatureUI) createLayout() {
 	))
 	
 	// Top controls
-	topControls := container.NewGridWithColumns(3,
-		currentTempCard,
-		controlCard,
-		chartControlCard,
-	)
+	topControls := container.NewBorder(nil, nil, nil, chartControlCard, heaterCard)
 	
 	// Chart takes up most of the space
 	chartContainer := container.NewMax(ui.chart)
@@ -267,52 +230,98 @@ func (ui *TemperatureUI) updateTemperatureData() {
 	}
 	
 	// Update current temperature displays
-	ui.hotendActual.SetText(fmt.Sprintf("%.1f°C", status.Temperature))
-	ui.bedActual.SetText(fmt.Sprintf("%.1f°C", status.BedTemp))
+	readings := status.Readings()
+	ui.showHeaters(readings)
 	ui.statusLabel.SetText(status.Status)
 	
 	// Add data point to chart
-	dataPoint := TemperatureDataPoint{
-		Timestamp:      time.Now(),
-		HotendActual:   status.Temperature,
-		HotendTarget:   status.Temperature, // TODO: Get actual target from backend
-		BedActual:      status.BedTemp,
-		BedTarget:      status.BedTemp,     // TODO: Get actual target from backend
-	}
-	
-	ui.chart.AddDataPoint(dataPoint)
+	ui.chart.AddDataPoint(NewTemperatureDataPoint(time.Now(), readings))
 }
 
-// setHotendTemperature sets the hotend target temperature
-func (ui *TemperatureUI) setHotendTemperature() {
-	tempStr := ui.hotendTarget.Text
-	if tempStr == "" {
-		return
+// showHeaters updates the heater rows, rebuilding them when heaters appear
+// or disappear, e.g. after a tool change head is fitted
+func (ui *TemperatureUI) showHeaters(readings []HeaterReading) {
+	changed := len(readings) != len(ui.heaters)
+	for i := 0; !changed && i < len(readings); i++ {
+		changed = readings[i].Name != ui.heaters[i].Name
 	}
+	ui.heaters = readings
 	
-	temp, err := strconv.ParseFloat(tempStr, 64)
-	if err != nil {
-		dialog.ShowError(fmt.Errorf("invalid temperature: %s", tempStr), ui.window)
-		return
+	if changed {
+		ui.heaterRows = make(map[string]*heaterRow, len(readings))
+		rows := make([]fyne.CanvasObject, len(readings))
+		for i, reading := range readings {
+			rows[i] = ui.createHeaterRow(reading)
+		}
+		ui.heaterBox.Objects = rows
+		ui.heaterBox.Refresh()
 	}
 	
-	if temp < 0 || temp > 300 {
-		dialog.ShowError(fmt.Errorf("temperature out of range (0-300°C): %.1f", temp), ui.window)
-		return
+	for _, reading := range readings {
+		row := ui.heaterRows[reading.Name]
+		row.actual.SetText(fmt.Sprintf("%.1f°C", reading.Actual))
+		if !reading.Controllable() {
+			continue
+		}
+		if reading.Target > 0 {
+			row.target.SetText(fmt.Sprintf("%.0f°C", reading.Target))
+		} else {
+			row.target.SetText("Off")
+		}
+		row.power.SetText(fmt.Sprintf("%.0f%%", reading.Power*100))
 	}
-	
-	err = ui.backend.SetTemperature("hotend", temp)
-	if err != nil {
-		dialog.ShowError(fmt.Errorf("failed to set hotend temperature: %v", err), ui.window)
-		return
+}
+
+// createHeaterRow creates the row of one heater. Sensors only show their
+// reading.
+func (ui *TemperatureUI) createHeaterRow(reading HeaterReading) fyne.CanvasObject {
+	row := &heaterRow{
+		actual: widget.NewLabelWithStyle("0°C", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		target: widget.NewLabel("-"),
+		power:  widget.NewLabel("-"),
+	}
+	ui.heaterRows[reading.Name] = row
+	
+	var entry, setBtn fyne.CanvasObject = layout.NewSpacer(), layout.NewSpacer()
+	if reading.Controllable() {
+		row.entry = widget.NewEntry()
+		row.entry.SetPlaceHolder(fmt.Sprintf("0-%.0f", reading.MaxTarget()))
+		name := reading.Name
+		btn := widget.NewButton("Set", func() {
+			ui.setHeaterTemperature(name)
+		})
+		btn.Importance = widget.HighImportance
+		entry, setBtn = row.entry, btn
 	}
 	
-	ui.statusLabel.SetText(fmt.Sprintf("Setting hotend to %.1f°C", temp))
+	return container.NewGridWithColumns(6,
+		widget.NewLabel(reading.DisplayName()),
+		row.actual,
+		row.target,
+		row.power,
+		entry,
+		setBtn,
+	)
+}
+
+// heater returns the latest reading of a heater
+func (ui *TemperatureUI) heater(name string) (HeaterReading, bool) {
+	for _, reading := range ui.heaters {
+		if reading.Name == name {
+			return reading, true
+		}
+	}
+	return HeaterReading{}, false
 }
 
-// setBedTemperature sets the bed target temperature
-func (ui *TemperatureUI) setBedTemperature() {
-	tempStr := ui.bedTarget.Text
+// setHeaterTemperature sets a heater's target from its row's input
+func (ui *TemperatureUI) setHeaterTemperature(name string) {
+	reading, ok := ui.heater(name)
+	row := ui.heaterRows[name]
+	if !ok || row == nil || row.entry == nil {
+		return
+	}
+	tempStr := row.entry.Text
 	if tempStr == "" {
 		return
 	}
@@ -323,44 +332,53 @@ func (ui *TemperatureUI) setBedTemperature() {
 		return
 	}
 	
-	if temp < 0 || temp > 120 {
-		dialog.ShowError(fmt.Errorf("bed temperature out of range (0-120°C): %.1f", temp), ui.window)
+	label := reading.DisplayName()
+	if temp < 0 || temp > reading.MaxTarget() {
+		dialog.ShowError(fmt.Errorf("%s temperature out of range (0-%.0f°C): %.1f", strings.ToLower(label), reading.MaxTarget(), temp), ui.window)
 		return
 	}
 	
-	err = ui.backend.SetTemperature("bed", temp)
+	err = ui.backend.SetTemperature(name, temp)
 	if err != nil {
-		dialog.ShowError(fmt.Errorf("failed to set bed temperature: %v", err), ui.window)
+		dialog.ShowError(fmt.Errorf("failed to set %s temperature: %v", strings.ToLower(label), err), ui.window)
 		return
 	}
 	
-	ui.statusLabel.SetText(fmt.Sprintf("Setting bed to %.1f°C", temp))
+	ui.statusLabel.SetText(fmt.Sprintf("Setting %s to %.1f°C", strings.ToLower(label), temp))
 }
 
-// setPresetTemperatures sets both hotend and bed temperatures
+// setPresetTemperatures sets every hotend and the bed. Cooling down (both
+// 0) turns off every heater, the chamber included.
 func (ui *TemperatureUI) setPresetTemperatures(hotend, bed float64) {
-	ui.hotendTarget.SetText(fmt.Sprintf("%.0f", hotend))
-	ui.bedTarget.SetText(fmt.Sprintf("%.0f", bed))
-	
-	// Set hotend first
-	if hotend > 0 {
-		err := ui.backend.SetTemperature("hotend", hotend)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("failed to set hotend temperature: %v", err), ui.window)
-			return
+	coolDown := hotend == 0 && bed == 0
+	
+	// Hotends first, then the bed and chamber
+	for _, reading := range ui.heaters {
+		var temp float64
+		switch reading.HeaterKind() {
+		case HeaterKindTool:
+			temp = hotend
+		case HeaterKindBed:
+			temp = bed
+		case HeaterKindChamber:
+			// Chambers are left alone unless cooling down
+		default:
+			continue
 		}
-	}
-	
-	// Then set bed
-	if bed > 0 {
-		err := ui.backend.SetTemperature("bed", bed)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("failed to set bed temperature: %v", err), ui.window)
+		if temp == 0 && !coolDown {
+			continue
+		}
+		
+		if row := ui.heaterRows[reading.Name]; row != nil && row.entry != nil {
+			row.entry.SetText(fmt.Sprintf("%.0f", temp))
+		}
+		if err := ui.backend.SetTemperature(reading.Name, temp); err != nil {
+			dialog.ShowError(fmt.Errorf("failed to set %s temperature: %v", strings.ToLower(reading.DisplayName()), err), ui.window)
 			return
 		}
 	}
 	
-	if hotend == 0 && bed == 0 {
+	if coolDown {
 		ui.statusLabel.SetText("Cooling down...")
 	} else {
 		ui.statusLabel.SetText(fmt.Sprintf("Setting preset: Hotend %.0f°C, Bed %.0f°C", hotend, bed))
@@ -425,35 +443,158 @@ func (ui *TemperatureUI) saveTemperatureDataToFile(data []TemperatureDataPoint)
 	}
 	defer file.Close()
 	
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := WriteTemperatureCSV(file, data); err != nil {
+		dialog.ShowError(fmt.Errorf("failed to write file: %v", err), ui.window)
+		return
+	}
+	
+	// Show success message
+	dialog.ShowInformation("Export Complete", 
+		fmt.Sprintf("Temperature data exported to:\n%s\n\n%d data points saved.", 
+			fullPath, len(data)), ui.window)
+}
+
+// WriteTemperatureCSV writes temperature readings as CSV, with actual,
+// target and power columns for every heater found in the readings
+func WriteTemperatureCSV(w io.Writer, data []TemperatureDataPoint) error {
+	writer := csv.NewWriter(w)
+	
+	// Every heater that appears anywhere gets columns
+	seen := make(map[string]bool)
+	heaters := []HeaterReading{}
+	for _, point := range data {
+		for name, reading := range point.Heaters {
+			if !seen[name] {
+				seen[name] = true
+				heaters = append(heaters, reading)
+			}
+		}
+	}
+	sortHeaters(heaters)
 	
 	// Write header
-	header := []string{
-		"Timestamp",
-		"Hotend Actual (°C)",
-		"Hotend Target (°C)",
-		"Bed Actual (°C)",
-		"Bed Target (°C)",
+	header := []string{"Timestamp"}
+	for _, heater := range heaters {
+		label := heater.DisplayName()
+		header = append(header, label+" Actual (°C)")
+		if heater.Controllable() {
+			header = append(header, label+" Target (°C)", label+" Power (%)")
+		}
 	}
 	writer.Write(header)
 	
-	// Write data
+	// Write data; a heater missing from a reading leaves its cells empty
 	for _, point := range data {
-		record := []string{
-			point.Timestamp.Format("2006-01-02 15:04:05"),
-			fmt.Sprintf("%.2f", point.HotendActual),
-			fmt.Sprintf("%.2f", point.HotendTarget),
-			fmt.Sprintf("%.2f", point.BedActual),
-			fmt.Sprintf("%.2f", point.BedTarget),
+		record := []string{point.Timestamp.Format("2006-01-02 15:04:05")}
+		for _, heater := range heaters {
+			reading, ok := point.Reading(heater.Name)
+			switch {
+			case !ok && heater.Controllable():
+				record = append(record, "", "", "")
+			case !ok:
+				record = append(record, "")
+			case heater.Controllable():
+				record = append(record,
+					fmt.Sprintf("%.2f", reading.Actual),
+					fmt.Sprintf("%.2f", reading.Target),
+					fmt.Sprintf("%.0f", reading.Power*100))
+			default:
+				record = append(record, fmt.Sprintf("%.2f", reading.Actual))
+			}
 		}
 		writer.Write(record)
 	}
 	
-	// Show success message
-	dialog.ShowInformation("Export Complete", 
-		fmt.Sprintf("Temperature data exported to:\n%s\n\n%d data points saved.", 
-			fullPath, len(data)), ui.window)
+	writer.Flush()
+	return writer.Error()
+}
+
+// temperatureCSVColumns are the columns WriteTemperatureCSV writes per heater
+var temperatureCSVColumns = []struct {
+	suffix string
+	scale  float64
+	set    func(reading *HeaterReading, value float64)
+}{
+	{" Actual (°C)", 1, func(r *HeaterReading, v float64) { r.Actual = v }},
+	{" Target (°C)", 1, func(r *HeaterReading, v float64) { r.Target = v }},
+	{" Power (%)", 0.01, func(r *HeaterReading, v float64) { r.Power = v }},
+}
+
+// ReadTemperatureCSV reads readings written by WriteTemperatureCSV, e.g. to
+// replay a recorded trace. Heaters are named after their column labels,
+// as the CSV does not keep the backend's names.
+func ReadTemperatureCSV(r io.Reader) ([]TemperatureDataPoint, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) == 0 || records[0][0] != "Timestamp" {
+		return nil, fmt.Errorf("not a temperature CSV file")
+	}
+	
+	// Map each column to a heater and the field it holds
+	type column struct {
+		heater string
+		field  int
+	}
+	header := records[0]
+	columns := make([]column, len(header))
+	labels := make(map[string]string)
+	controllable := make(map[string]bool)
+	for i := 1; i < len(header); i++ {
+		columns[i].field = -1
+		for field, col := range temperatureCSVColumns {
+			if !strings.HasSuffix(header[i], col.suffix) {
+				continue
+			}
+			label := strings.TrimSuffix(header[i], col.suffix)
+			name := strings.ToLower(strings.ReplaceAll(label, " ", "_"))
+			columns[i] = column{heater: name, field: field}
+			labels[name] = label
+			if field > 0 {
+				controllable[name] = true
+			}
+		}
+	}
+	
+	data := make([]TemperatureDataPoint, 0, len(records)-1)
+	for line, record := range records[1:] {
+		timestamp, err := time.ParseInLocation("2006-01-02 15:04:05", record[0], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid timestamp %q", line+2, record[0])
+		}
+	
+		readings := make(map[string]*HeaterReading)
+		for i := 1; i < len(record) && i < len(columns); i++ {
+			col := columns[i]
+			if col.field < 0 || record[i] == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(record[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s %q", line+2, header[i], record[i])
+			}
+			reading, ok := readings[col.heater]
+			if !ok {
+				reading = &HeaterReading{Name: col.heater, Label: labels[col.heater]}
+				// Only target columns tell a heater from a sensor
+				if kind := reading.HeaterKind(); kind == HeaterKindSensor && controllable[col.heater] {
+					reading.Kind = HeaterKindChamber
+				} else if !controllable[col.heater] {
+					reading.Kind = HeaterKindSensor
+				}
+				readings[col.heater] = reading
+			}
+			temperatureCSVColumns[col.field].set(reading, value*temperatureCSVColumns[col.field].scale)
+		}
+	
+		list := make([]HeaterReading, 0, len(readings))
+		for _, reading := range readings {
+			list = append(list, *reading)
+		}
+		data = append(data, NewTemperatureDataPoint(timestamp, list))
+	}
+	return data, nil
 }
 
 // GetContent returns the UI content
@@ -472,18 +613,10 @@ func (ui *TemperatureUI) GetChart() *TemperatureChart {
 }
 
 // AddTemperatureReading manually adds a temperature reading (for testing)
-func (ui *TemperatureUI) AddTemperatureReading(hotendActual, hotendTarget, bedActual, bedTarget float64) {
-	dataPoint := TemperatureDataPoint{
-		Timestamp:      time.Now(),
-		HotendActual:   hotendActual,
-		HotendTarget:   hotendTarget,
-		BedActual:      bedActual,
-		BedTarget:      bedTarget,
-	}
-	
-	ui.chart.AddDataPoint(dataPoint)
+func (ui *TemperatureUI) AddTemperatureReading(readings ...HeaterReading) {
+	sortHeaters(readings)
+	ui.chart.AddDataPoint(NewTemperatureDataPoint(time.Now(), readings))
 	
 	// Update current displays
-	ui.hotendActual.SetText(fmt.Sprintf("%.1f°C", hotendActual))
-	ui.bedActual.SetText(fmt.Sprintf("%.1f°C", bedActual))
+	ui.showHeaters(readings)
 } 
\ No newline at end of file
@@ -0,0 +1,102 @@
+"""Writes testdata/prusaslicer_sample.bgcode from prusaslicer_sample.gcode.
+
+Usage: python3 make_prusaslicer_sample.py prusaslicer_sample.gcode prusaslicer_sample.bgcode
+
+The file follows the binary G-code layout of PrusaSlicer 2.7 as described in
+libbgcode's specification, but the heatshrink and MeatPack encoders below
+were written for this fixture, not taken from PrusaSlicer. See README.md.
+"""
+
+import struct, zlib, sys
+
+def heatshrink_encode(data, wbits, lbits):
+    bits = []
+    def put(v, n):
+        for i in range(n - 1, -1, -1):
+            bits.append((v >> i) & 1)
+    window, maxlen = 1 << wbits, 1 << lbits
+    i = 0
+    while i < len(data):
+        best_len, best_off = 0, 0
+        for off in range(1, min(window, i) + 1):
+            l = 0
+            while l < maxlen and i + l < len(data) and data[i + l - off] == data[i + l]:
+                l += 1
+            if l > best_len:
+                best_len, best_off = l, off
+        if best_len >= 2:
+            put(0, 1); put(best_off - 1, wbits); put(best_len - 1, lbits)
+            i += best_len
+        else:
+            put(1, 1); put(data[i], 8)
+            i += 1
+    while len(bits) % 8:
+        bits.append(0)
+    return bytes(int(''.join(map(str, bits[j:j + 8])), 2) for j in range(0, len(bits), 8))
+
+def meatpack_encode(text, nospaces):
+    out = bytearray(b'\xff\xff\xfb')
+    if nospaces:
+        out += b'\xff\xff\xf7'
+    packable = '0123456789.' + ('E' if nospaces else ' ') + '\nGX'
+    def code(c):
+        return packable.index(c) if c in packable else 0xf
+    for line in text.split('\n')[:-1]:
+        if line.startswith(';'):
+            out += b'\xff\xff\xfa' + (line + '\n').encode() + b'\xff\xff\xfb'
+            continue
+        body, comment = line, ''
+        if ';' in line:
+            idx = line.index(';')
+            body, comment = line[:idx].rstrip(' '), line[len(line[:idx].rstrip(' ')):]
+        if nospaces and body.startswith('G'):
+            body = body.replace(' ', '')
+        chars = body + comment + '\n'
+        if len(chars) % 2:
+            chars += ' ' if not nospaces else 'E'  # ignored after '\n'
+        for j in range(0, len(chars), 2):
+            a, b = chars[j], chars[j + 1]
+            if a == '\n':
+                b = packable[0]
+            ca, cb = code(a), code(b)
+            out.append((cb << 4) | ca)
+            if ca == 0xf:
+                out.append(ord(a))
+            if cb == 0xf:
+                out.append(ord(b))
+    return bytes(out)
+
+def block(btype, compression, params, data):
+    if compression == 0:
+        stored = data
+        header = struct.pack('<HHI', btype, 0, len(data))
+    else:
+        stored = {1: lambda d: zlib.compress(d),
+                  2: lambda d: heatshrink_encode(d, 11, 4),
+                  3: lambda d: heatshrink_encode(d, 12, 4)}[compression](data)
+        header = struct.pack('<HHII', btype, compression, len(data), len(stored))
+    body = header + params + stored
+    return body + struct.pack('<I', zlib.crc32(body))
+
+def png_1x1():
+    def chunk(t, d):
+        return struct.pack('>I', len(d)) + t + d + struct.pack('>I', zlib.crc32(t + d))
+    return (b'\x89PNG\r\n\x1a\n' + chunk(b'IHDR', struct.pack('>IIBBBBB', 1, 1, 8, 2, 0, 0, 0))
+            + chunk(b'IDAT', zlib.compress(b'\x00\xff\x80\x00')) + chunk(b'IEND', b''))
+
+text = open(sys.argv[1]).read()
+lines = text.split('\n')[:-1]
+parts = ['\n'.join(lines[:21]) + '\n', '\n'.join(lines[21:41]) + '\n', '\n'.join(lines[41:]) + '\n']
+assert ''.join(parts) == text
+
+ini = struct.pack('<H', 0)
+out = b'GCDE' + struct.pack('<IH', 1, 1)
+out += block(0, 0, ini, b'Producer=PrusaSlicer 2.7.1\n')
+out += block(3, 1, ini, b'printer_model=MK4\nfilament_type=PLA\nnozzle_diameter=0.4\nbed_temperature=60\ntemperature=215\nextruder_colour=""\n')
+out += block(5, 0, struct.pack('<HHH', 0, 1, 1), png_1x1())
+out += block(4, 2, ini, b'filament used [mm]=14.29\nfilament used [g]=0.04\nestimated printing time (normal mode)=1m 52s\n')
+out += block(2, 1, ini, b'layer_height=0.2\nfill_density=15%\nperimeter_speed=45\n')
+out += block(1, 3, struct.pack('<H', 2), meatpack_encode(parts[0], True))
+out += block(1, 2, struct.pack('<H', 2), meatpack_encode(parts[1], False))
+out += block(1, 1, struct.pack('<H', 0), parts[2].encode())
+open(sys.argv[2], 'wb').write(out)
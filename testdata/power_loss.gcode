@@ -0,0 +1,25 @@
+; power loss fixture: three layers on tool 1 with relative extrusion
+M140 S60
+M104 S215
+M190 S60
+M109 S215
+G28
+G90
+M83
+T1
+;LAYER:0
+G1 Z0.2 F600
+G1 X10 Y10 F3000
+G1 X20 Y10 E0.5 F1200
+M106 S128
+;LAYER:1
+G1 Z0.4 F600
+G1 X20 Y20 E0.5 F1500
+M220 S90
+G1 X10 Y20 E0.5
+;LAYER:2
+G1 Z0.6 F600
+G1 X10 Y10 E0.5 F1800
+M107
+M104 S0
+M140 S0
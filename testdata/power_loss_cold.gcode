@@ -0,0 +1,12 @@
+; heated by the printer's start macro, so the file sets no temperatures
+G28
+G90
+M82
+;LAYER:0
+G1 Z0.2 F600
+G1 X10 Y10 F3000
+G1 X20 Y10 E1.2 F1200
+;LAYER:1
+G1 Z0.4 F600
+G1 X20 Y20 E2.4 F1500
+G1 X10 Y20 E3.6
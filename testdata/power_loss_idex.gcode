@@ -0,0 +1,23 @@
+; IDEX power loss fixture: both hotends heated in a heated chamber
+M141 S45
+M140 S100
+M104 T0 S250
+M104 T1 S240
+M190 S100
+M109 T0 S250
+M109 T1 S240
+G28
+G29
+G90
+M83
+;LAYER:0
+G1 Z0.2 F600
+G1 X10 Y10 F3000
+G1 X20 Y10 E0.5 F1200
+;LAYER:1
+T1
+G1 Z0.4 F600
+G1 X20 Y20 E0.5 F1500
+G1 X10 Y20 E0.5
+M104 T0 S180
+G1 X10 Y10 E0.5
@@ -0,0 +1,55 @@
+; generated by PrusaSlicer 2.7.1+linux-x64-GTK3 on 2024-01-15 at 10:21:33 UTC
+;
+; external perimeters extrusion width = 0.45mm
+; perimeters extrusion width = 0.45mm
+;
+M73 P0 R2
+M201 X4000 Y4000 Z200 E2500 ; sets maximum accelerations, mm/sec^2
+M203 X300 Y300 Z12 E120 ; sets maximum feedrates, mm / sec
+M104 S215 ; set extruder temp
+M140 S60 ; set bed temp
+M190 S60 ; wait for bed temp
+M109 S215 ; wait for extruder temp
+G28 W ; home all without mesh bed level
+G80 ; mesh bed leveling
+G21 ; set units to millimeters
+G90 ; use absolute coordinates
+M83 ; use relative distances for extrusion
+;LAYER_CHANGE
+;Z:0.2
+;HEIGHT:0.2
+G1 Z.2 F720
+G1 X60 Y-3 F1000
+G1 X100 Y-3 E12.5 F1000 ; intro line
+G92 E0
+;TYPE:External perimeter
+;WIDTH:0.45
+G1 X98.25 Y98.25 E.03211 F1800
+G1 X101.75 Y98.25 E.11247
+G1 X101.75 Y101.75 E.11247
+G1 X98.25 Y101.75 E.11247
+G1 X98.25 Y98.3 E.11086
+G1 E-.8 F2100 ;WIPE_START
+;LAYER_CHANGE
+;Z:0.4
+;HEIGHT:0.2
+G1 Z.4 F720
+G1 X98.25 Y98.25 E.8 F2100
+G1 X101.75 Y98.25 E.11247 F1800
+G1 X101.75 Y101.75 E.11247
+G1 X98.25 Y101.75 E.11247
+G1 X98.25 Y98.3 E.11086
+;LAYER_CHANGE
+;Z:0.6
+;HEIGHT:0.2
+G1 Z.6 F720
+G1 X101.75 Y98.25 E.11247 F1800
+G1 X101.75 Y101.75 E.11247
+G1 X98.25 Y101.75 E.11247
+G1 X98.25 Y98.3 E.11086
+M107
+G1 Z10.6 F720 ; Move print head up
+M104 S0 ; turn off temperature
+M140 S0 ; turn off heatbed
+M84 ; disable motors
+M73 P100 R0
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThermalAnomaly is something wrong the temperature stream shows
+type ThermalAnomaly string
+
+const (
+	ThermalRunaway        ThermalAnomaly = "runaway"         // Heating past the target, or while off
+	ThermalHeatingTimeout ThermalAnomaly = "heating_timeout" // Target not reached in time
+	ThermalSensorFault    ThermalAnomaly = "sensor_fault"    // Impossible reading or sudden jump
+	ThermalDrop           ThermalAnomaly = "temperature_drop"
+)
+
+// ThermalAnomalies lists the anomalies in the order they are configured
+var ThermalAnomalies = []ThermalAnomaly{ThermalRunaway, ThermalHeatingTimeout, ThermalSensorFault, ThermalDrop}
+
+// Label returns the name shown for the anomaly
+func (a ThermalAnomaly) Label() string {
+	switch a {
+	case ThermalRunaway:
+		return "Heater runaway"
+	case ThermalHeatingTimeout:
+		return "Heating timeout"
+	case ThermalSensorFault:
+		return "Sensor fault"
+	case ThermalDrop:
+		return "Temperature drop"
+	}
+	return string(a)
+}
+
+// ThermalAlarmAction is what an anomaly does when it is detected
+type ThermalAlarmAction struct {
+	Enabled       bool `json:"enabled"`
+	EmergencyStop bool `json:"emergency_stop"`
+}
+
+// ThermalAlarmSettings configures which anomalies raise an alarm and the
+// thresholds they are detected at
+type ThermalAlarmSettings struct {
+	Alarms map[ThermalAnomaly]ThermalAlarmAction `json:"alarms"`
+
+	RunawayMargin  float64 `json:"runaway_margin"`  // °C above the target, or above where an off heater cooled to
+	RunawaySeconds int     `json:"runaway_seconds"` // How long the heater must stay past the margin
+
+	HeatingTolerance      float64 `json:"heating_tolerance"` // °C from the target that counts as reached
+	ToolHeatingSeconds    int     `json:"tool_heating_seconds"`
+	BedHeatingSeconds     int     `json:"bed_heating_seconds"`
+	ChamberHeatingSeconds int     `json:"chamber_heating_seconds"`
+
+	SensorMin  float64 `json:"sensor_min"`  // Readings below this are a disconnected sensor
+	SensorMax  float64 `json:"sensor_max"`  // Readings above this are a shorted sensor
+	SensorJump float64 `json:"sensor_jump"` // °C per second no heater can change by
+
+	DropMargin  float64 `json:"drop_margin"` // °C below the target during a print
+	DropSeconds int     `json:"drop_seconds"`
+}
+
+// DefaultThermalAlarmSettings enables every alarm; runaways and sensor
+// faults also stop the printer
+func DefaultThermalAlarmSettings() ThermalAlarmSettings {
+	return ThermalAlarmSettings{
+		Alarms: map[ThermalAnomaly]ThermalAlarmAction{
+			ThermalRunaway:        {Enabled: true, EmergencyStop: true},
+			ThermalHeatingTimeout: {Enabled: true},
+			ThermalSensorFault:    {Enabled: true, EmergencyStop: true},
+			ThermalDrop:           {Enabled: true},
+		},
+		RunawayMargin:         15,
+		RunawaySeconds:        20,
+		HeatingTolerance:      3,
+		ToolHeatingSeconds:    300,
+		BedHeatingSeconds:     900,
+		ChamberHeatingSeconds: 1800,
+		SensorMin:             -5,
+		SensorMax:             500,
+		SensorJump:            25,
+		DropMargin:            10,
+		DropSeconds:           15,
+	}
+}
+
+// thermalAlarmFile is where the touchscreen keeps the alarm settings
+func thermalAlarmFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "thermal_alarms.json")
+}
+
+// thermalEventFile is where raised thermal alarms are logged
+func thermalEventFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "thermal_events.json")
+}
+
+// LoadThermalAlarmSettings reads the settings at path; a missing file gives
+// the defaults, as do settings missing from the file
+func LoadThermalAlarmSettings(path string) (ThermalAlarmSettings, error) {
+	settings := DefaultThermalAlarmSettings()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return DefaultThermalAlarmSettings(), fmt.Errorf("invalid thermal alarm settings file: %v", err)
+	}
+	defaults := DefaultThermalAlarmSettings()
+	if settings.Alarms == nil {
+		settings.Alarms = defaults.Alarms
+	}
+	for _, anomaly := range ThermalAnomalies {
+		if _, ok := settings.Alarms[anomaly]; !ok {
+			settings.Alarms[anomaly] = defaults.Alarms[anomaly]
+		}
+	}
+	return settings, nil
+}
+
+// SaveThermalAlarmSettings writes the settings to path
+func SaveThermalAlarmSettings(path string, settings ThermalAlarmSettings) error {
+	jsonData, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, jsonData, 0600)
+}
+
+// heatingTimeout is how long a heater of a kind may take to reach its target
+func (s *ThermalAlarmSettings) heatingTimeout(kind HeaterKind) time.Duration {
+	switch kind {
+	case HeaterKindBed:
+		return time.Duration(s.BedHeatingSeconds) * time.Second
+	case HeaterKindChamber:
+		return time.Duration(s.ChamberHeatingSeconds) * time.Second
+	}
+	return time.Duration(s.ToolHeatingSeconds) * time.Second
+}
+
+// ThermalAlarm is an anomaly detected on one heater or sensor
+type ThermalAlarm struct {
+	Time          time.Time
+	Heater        string // Heater name, e.g. tool0
+	Label         string // Display name of the heater
+	Anomaly       ThermalAnomaly
+	Actual        float64
+	Target        float64
+	Detail        string
+	EmergencyStop bool // The settings ask for the printer to be stopped
+}
+
+// Describe returns the alarm as one line of text
+func (a *ThermalAlarm) Describe() string {
+	return fmt.Sprintf("%s on %s: %s", a.Anomaly.Label(), a.Label, a.Detail)
+}
+
+// heaterWatch is what the monitor remembers about one heater
+type heaterWatch struct {
+	last     HeaterReading
+	lastTime time.Time
+
+	target       float64
+	heating      bool      // The target was raised and has not been reached yet
+	heatingSince time.Time // When the target was set
+	reached      bool      // The heater has been at its target since it was set
+	offFloor     float64   // Coolest reading since the heater was turned off
+
+	divergedSince time.Time // Start of a runaway, zero if none
+	droppedSince  time.Time // Start of a drop, zero if none
+
+	raised map[ThermalAnomaly]bool // Alarms raised and not yet cleared
+}
+
+// ThermalMonitor watches the temperature stream for heater runaway,
+// heaters that do not reach their target, faulty sensors and temperature
+// drops during a print. An alarm is raised once and again only after its
+// condition has cleared.
+type ThermalMonitor struct {
+	mu       sync.Mutex
+	settings ThermalAlarmSettings
+	heaters  map[string]*heaterWatch
+
+	// Backends without a heater list report no targets, so an off heater
+	// cannot be told from one heating
+	targetsReported bool
+}
+
+// NewThermalMonitor creates a monitor with the given settings
+func NewThermalMonitor(settings ThermalAlarmSettings) *ThermalMonitor {
+	return &ThermalMonitor{
+		settings: settings,
+		heaters:  make(map[string]*heaterWatch),
+	}
+}
+
+// Settings returns the monitor's settings
+func (m *ThermalMonitor) Settings() ThermalAlarmSettings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settings
+}
+
+// SetSettings changes the settings, keeping what is known of the heaters
+func (m *ThermalMonitor) SetSettings(settings ThermalAlarmSettings) {
+	m.mu.Lock()
+	m.settings = settings
+	m.mu.Unlock()
+}
+
+// Reset forgets the heaters, e.g. after connecting to another printer
+func (m *ThermalMonitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.heaters = make(map[string]*heaterWatch)
+	m.targetsReported = false
+}
+
+// Observe takes readings taken together, at the point's time, and returns
+// the alarms they raise. Printing tells whether a print is running, when
+// temperature drops are alarmed.
+func (m *ThermalMonitor) Observe(point TemperatureDataPoint, printing bool) []ThermalAlarm {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	alarms := []ThermalAlarm{}
+	for _, reading := range point.Readings() {
+		if reading.Target > 0 {
+			m.targetsReported = true
+		}
+		watch, ok := m.heaters[reading.Name]
+		if !ok {
+			watch = &heaterWatch{raised: make(map[ThermalAnomaly]bool)}
+			m.heaters[reading.Name] = watch
+		}
+		alarms = append(alarms, m.observeHeater(watch, reading, point.Timestamp, printing)...)
+		watch.last = reading
+		watch.lastTime = point.Timestamp
+	}
+	return alarms
+}
+
+// observeHeater checks one heater's reading against what came before
+func (m *ThermalMonitor) observeHeater(w *heaterWatch, reading HeaterReading, now time.Time, printing bool) []ThermalAlarm {
+	s := &m.settings
+	alarms := []ThermalAlarm{}
+	raise := func(anomaly ThermalAnomaly, active bool, detail string, args ...interface{}) {
+		if !active {
+			w.raised[anomaly] = false
+			return
+		}
+		action := s.Alarms[anomaly]
+		if w.raised[anomaly] || !action.Enabled {
+			return
+		}
+		w.raised[anomaly] = true
+		alarms = append(alarms, ThermalAlarm{
+			Time:          now,
+			Heater:        reading.Name,
+			Label:         reading.DisplayName(),
+			Anomaly:       anomaly,
+			Actual:        reading.Actual,
+			Target:        reading.Target,
+			Detail:        fmt.Sprintf(detail, args...),
+			EmergencyStop: action.EmergencyStop,
+		})
+	}
+	first := w.lastTime.IsZero()
+
+	// A faulty sensor says nothing about the heater, so nothing else is checked
+	switch {
+	case reading.Actual < s.SensorMin || reading.Actual > s.SensorMax:
+		raise(ThermalSensorFault, true, "reads %.1f°C, outside %.0f to %.0f°C", reading.Actual, s.SensorMin, s.SensorMax)
+		return alarms
+	case !first && now.After(w.lastTime):
+		change := reading.Actual - w.last.Actual
+		rate := math.Abs(change) / now.Sub(w.lastTime).Seconds()
+		if rate > s.SensorJump {
+			raise(ThermalSensorFault, true, "jumped from %.1f to %.1f°C in %s",
+				w.last.Actual, reading.Actual, now.Sub(w.lastTime).Round(time.Second))
+			return alarms
+		}
+	}
+	raise(ThermalSensorFault, false, "")
+	if !reading.Controllable() {
+		return alarms
+	}
+
+	// A new target restarts heating and the checks against the target
+	if first || reading.Target != w.target {
+		w.heating = reading.Target > 0 && (first || reading.Target > w.target) && reading.Actual < reading.Target
+		w.heatingSince = now
+		w.reached = false
+		w.offFloor = reading.Actual
+		w.divergedSince = time.Time{}
+		w.droppedSince = time.Time{}
+		w.target = reading.Target
+		for _, anomaly := range []ThermalAnomaly{ThermalRunaway, ThermalHeatingTimeout, ThermalDrop} {
+			w.raised[anomaly] = false
+		}
+	}
+	target := reading.Target
+	if target > 0 && !w.reached && math.Abs(reading.Actual-target) <= s.HeatingTolerance {
+		w.reached = true
+		w.heating = false
+	}
+	if reading.Actual < w.offFloor {
+		w.offFloor = reading.Actual
+	}
+
+	// Heating too slowly
+	timeout := s.heatingTimeout(reading.HeaterKind())
+	raise(ThermalHeatingTimeout, w.heating && timeout > 0 && now.Sub(w.heatingSince) > timeout,
+		"%.1f°C after %s, target %.0f°C", reading.Actual, now.Sub(w.heatingSince).Round(time.Second), target)
+
+	// Runaway: past the target once reached it, or heating while off
+	var diverged bool
+	var detail string
+	switch {
+	case target > 0 && w.reached && reading.Actual > target+s.RunawayMargin:
+		diverged = true
+		detail = fmt.Sprintf("%.1f°C, %.1f°C above the target of %.0f°C", reading.Actual, reading.Actual-target, target)
+	case target <= 0 && m.targetsReported && reading.Actual > w.offFloor+s.RunawayMargin:
+		diverged = true
+		detail = fmt.Sprintf("heating to %.1f°C while off, from %.1f°C", reading.Actual, w.offFloor)
+	}
+	raise(ThermalRunaway, sustained(&w.divergedSince, diverged, now, s.RunawaySeconds), "%s", detail)
+
+	// Falling below the target during a print
+	dropped := printing && target > 0 && w.reached && reading.Actual < target-s.DropMargin
+	raise(ThermalDrop, sustained(&w.droppedSince, dropped, now, s.DropSeconds),
+		"fell to %.1f°C, %.1f°C below the target of %.0f°C", reading.Actual, target-reading.Actual, target)
+
+	return alarms
+}
+
+// sustained reports whether a condition has held for the given seconds,
+// tracking when it started in since
+func sustained(since *time.Time, active bool, now time.Time, seconds int) bool {
+	if !active {
+		*since = time.Time{}
+		return false
+	}
+	if since.IsZero() {
+		*since = now
+	}
+	return now.Sub(*since) >= time.Duration(seconds)*time.Second
+}
+
+// ReplayThermalTrace runs a recorded temperature trace, e.g. one read with
+// ReadTemperatureCSV, through a new monitor and returns every alarm raised
+func ReplayThermalTrace(settings ThermalAlarmSettings, trace []TemperatureDataPoint, printing bool) []ThermalAlarm {
+	monitor := NewThermalMonitor(settings)
+	alarms := []ThermalAlarm{}
+	for _, point := range trace {
+		alarms = append(alarms, monitor.Observe(point, printing)...)
+	}
+	return alarms
+}
+
+// isPrintingState reports whether a printer status is a running print
+func isPrintingState(status string) bool {
+	return strings.ToLower(status) == "printing"
+}
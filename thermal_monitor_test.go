@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// loadTrace reads a recorded temperature trace from testdata
+func loadTrace(t *testing.T, name string) []TemperatureDataPoint {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace, err := ReadTemperatureCSV(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return trace
+}
+
+// alarmList describes alarms as "heater anomaly at time"
+func alarmList(alarms []ThermalAlarm) []string {
+	list := []string{}
+	for _, alarm := range alarms {
+		list = append(list, fmt.Sprintf("%s %s at %s", alarm.Heater, alarm.Anomaly, alarm.Time.Format("15:04:05")))
+	}
+	return list
+}
+
+func TestReadTemperatureCSV(t *testing.T) {
+	for _, name := range []string{"thermal_normal.csv", "thermal_runaway.csv", "thermal_heater_failure.csv"} {
+		trace := loadTrace(t, name)
+		if len(trace) != 48 {
+			t.Errorf("%s: %d points, want 48", name, len(trace))
+			continue
+		}
+		hotend, ok := trace[0].Reading("hotend_t0")
+		if !ok || hotend.HeaterKind() != HeaterKindTool || hotend.DisplayName() != "Hotend T0" || hotend.Target != 215 || hotend.Power != 1 {
+			t.Errorf("%s: hotend reading %+v", name, hotend)
+		}
+		if bed, ok := trace[0].Reading("bed"); !ok || bed.HeaterKind() != HeaterKindBed {
+			t.Errorf("%s: bed reading %+v", name, bed)
+		}
+
+		// The fixtures are as WriteTemperatureCSV writes them
+		data, _ := os.ReadFile("testdata/" + name)
+		var out bytes.Buffer
+		if err := WriteTemperatureCSV(&out, trace); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != string(data) {
+			t.Errorf("%s does not round trip:\n%s", name, out.String())
+		}
+	}
+
+	if _, err := ReadTemperatureCSV(bytes.NewReader([]byte("Time,Bed\n"))); err == nil {
+		t.Error("expected an error for a file without a Timestamp column")
+	}
+}
+
+func TestReplayThermalTrace(t *testing.T) {
+	tests := []struct {
+		name     string
+		trace    string
+		printing bool
+		want     []string
+	}{
+		{"normal heat-up", "thermal_normal.csv", true, []string{}},
+		{"runaway", "thermal_runaway.csv", false, []string{
+			"hotend_t0 runaway at 09:02:55", // Past the target since 09:02:35
+			"bed runaway at 09:02:55",       // Heating while off
+		}},
+		{"heater failure while printing", "thermal_heater_failure.csv", true, []string{
+			"hotend_t0 temperature_drop at 09:02:55",
+		}},
+		{"heater failure while idle", "thermal_heater_failure.csv", false, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			alarms := ReplayThermalTrace(DefaultThermalAlarmSettings(), loadTrace(t, test.trace), test.printing)
+			if got := alarmList(alarms); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("alarms %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplayThermalTraceSettings(t *testing.T) {
+	runaway := loadTrace(t, "thermal_runaway.csv")
+	alarms := ReplayThermalTrace(DefaultThermalAlarmSettings(), runaway, false)
+	for _, alarm := range alarms {
+		if !alarm.EmergencyStop {
+			t.Errorf("%s should stop the printer", alarm.Describe())
+		}
+	}
+
+	settings := DefaultThermalAlarmSettings()
+	settings.Alarms[ThermalRunaway] = ThermalAlarmAction{Enabled: false}
+	if alarms := ReplayThermalTrace(settings, runaway, false); len(alarms) != 0 {
+		t.Errorf("disabled runaway alarm raised %q", alarmList(alarms))
+	}
+
+	// A drop is not worth stopping for by default
+	failure := loadTrace(t, "thermal_heater_failure.csv")
+	alarms = ReplayThermalTrace(DefaultThermalAlarmSettings(), failure, true)
+	if len(alarms) != 1 || alarms[0].EmergencyStop || alarms[0].Target != 215 || alarms[0].Actual >= 205 {
+		t.Errorf("drop alarms %+v", alarms)
+	}
+
+	// A hotend that heats too slowly for a tighter timeout
+	settings = DefaultThermalAlarmSettings()
+	settings.ToolHeatingSeconds = 60
+	want := []string{"hotend_t0 heating_timeout at 09:01:05"}
+	if got := alarmList(ReplayThermalTrace(settings, loadTrace(t, "thermal_normal.csv"), true)); !reflect.DeepEqual(got, want) {
+		t.Errorf("alarms %q, want %q", got, want)
+	}
+}
+
+func TestReplayThermalTraceSensorFault(t *testing.T) {
+	trace := loadTrace(t, "thermal_normal.csv")
+
+	// The thermistor comes loose mid-print and is reseated, then loses
+	// contact once more. Jumping back to 215°C is the same fault.
+	for _, i := range []int{30, 31, 32, 40} {
+		reading := trace[i].Heaters["hotend_t0"]
+		reading.Actual = -14
+		trace[i].Heaters["hotend_t0"] = reading
+	}
+	alarms := ReplayThermalTrace(DefaultThermalAlarmSettings(), trace, true)
+	want := []string{
+		"hotend_t0 sensor_fault at 09:02:30",
+		"hotend_t0 sensor_fault at 09:03:20",
+	}
+	if got := alarmList(alarms); !reflect.DeepEqual(got, want) {
+		t.Errorf("alarms %q, want %q", got, want)
+	}
+	if len(alarms) > 0 && !alarms[0].EmergencyStop {
+		t.Error("a sensor fault should stop the printer")
+	}
+}
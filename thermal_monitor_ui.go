@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// thermalAlertColor is the background of the full-screen thermal alert
+var thermalAlertColor = color.NRGBA{R: 180, G: 20, B: 20, A: 255}
+
+// startThermalMonitor loads the alarm settings and the event log
+func (app *IntegratedApp) startThermalMonitor() {
+	settings, err := LoadThermalAlarmSettings(thermalAlarmFile())
+	if err != nil {
+		log.Printf("Failed to load thermal alarm settings: %v", err)
+	}
+	app.thermal = NewThermalMonitor(settings)
+	app.thermalEvents = NewAuditLog(thermalEventFile())
+	if err := app.thermalEvents.Load(); err != nil {
+		log.Printf("Failed to load thermal events: %v", err)
+	}
+}
+
+// checkThermals runs a status update through the thermal monitor and raises
+// the alarms it detects
+func (app *IntegratedApp) checkThermals(status PrinterStatus) {
+	point := NewTemperatureDataPoint(time.Now(), status.Readings())
+	for _, alarm := range app.thermal.Observe(point, isPrintingState(status.Status)) {
+		app.raiseThermalAlarm(alarm)
+	}
+}
+
+// raiseThermalAlarm stops the printer if the alarm asks for it, logs the
+// alarm and shows it full screen
+func (app *IntegratedApp) raiseThermalAlarm(alarm ThermalAlarm) {
+	details := alarm.Anomaly.Label() + ", " + alarm.Detail
+	if alarm.EmergencyStop {
+		if err := app.backend.EmergencyStop(); err != nil {
+			log.Printf("Emergency stop after thermal alarm failed: %v", err)
+			details += "; emergency stop failed: " + err.Error()
+		} else {
+			details += "; emergency stop sent"
+		}
+	}
+
+	log.Printf("Thermal alarm: %s", alarm.Describe())
+	app.appendLog(fmt.Sprintf("[%s] THERMAL ALARM %s", alarm.Time.Format("15:04:05"), alarm.Describe()))
+	err := app.thermalEvents.Record(AuditEvent{
+		Time:    alarm.Time,
+		User:    "Thermal monitor",
+		Action:  AuditThermalAlarm,
+		Subject: alarm.Label,
+		Details: details,
+	})
+	if err != nil {
+		log.Printf("Failed to log thermal alarm: %v", err)
+	}
+
+	app.thermalAlarms = append(app.thermalAlarms, alarm)
+	app.showThermalAlert(details)
+}
+
+// showThermalAlert covers the screen with the alarms raised since the last
+// acknowledgement. Another alarm replaces the alert with one listing both.
+func (app *IntegratedApp) showThermalAlert(latest string) {
+	if app.thermalAlert != nil {
+		app.thermalAlert.Hide()
+	}
+
+	title := canvas.NewText("THERMAL ALARM", color.White)
+	title.TextSize = 48
+	title.TextStyle = fyne.TextStyle{Bold: true}
+	title.Alignment = fyne.TextAlignCenter
+
+	lines := container.NewVBox()
+	for _, alarm := range app.thermalAlarms {
+		line := canvas.NewText(fmt.Sprintf("%s  %s", alarm.Time.Format("15:04:05"), alarm.Describe()), color.White)
+		line.TextSize = 20
+		line.Alignment = fyne.TextAlignCenter
+		lines.Add(line)
+	}
+	status := canvas.NewText(latest, color.White)
+	status.TextSize = 16
+	status.Alignment = fyne.TextAlignCenter
+
+	stopBtn := widget.NewButtonWithIcon("EMERGENCY STOP", theme.MediaStopIcon(), func() {
+		if err := app.backend.EmergencyStop(); err != nil {
+			status.Text = "Emergency stop failed: " + err.Error()
+		} else {
+			status.Text = "Emergency stop sent"
+		}
+		status.Refresh()
+	})
+	stopBtn.Importance = widget.DangerImportance
+	ackBtn := widget.NewButtonWithIcon("Acknowledge", theme.ConfirmIcon(), func() {
+		app.acknowledgeThermalAlarms()
+	})
+
+	content := container.NewMax(
+		canvas.NewRectangle(thermalAlertColor),
+		container.NewCenter(container.NewVBox(
+			title,
+			lines,
+			status,
+			layout.NewSpacer(),
+			container.NewGridWithColumns(2, stopBtn, ackBtn),
+		)),
+	)
+	app.thermalAlert = widget.NewModalPopUp(content, app.window.Canvas())
+	app.thermalAlert.Resize(app.window.Canvas().Size())
+	app.thermalAlert.Show()
+}
+
+// acknowledgeThermalAlarms closes the alert and logs who saw it
+func (app *IntegratedApp) acknowledgeThermalAlarms() {
+	user := "touchscreen"
+	if current := app.authManager.GetUser(); current != nil {
+		user = current.DisplayName()
+	}
+	for _, alarm := range app.thermalAlarms {
+		err := app.thermalEvents.Record(AuditEvent{
+			User:    user,
+			Role:    app.authManager.GetRole(),
+			Action:  AuditThermalAcknowledged,
+			Subject: alarm.Label,
+			Details: alarm.Anomaly.Label(),
+		})
+		if err != nil {
+			log.Printf("Failed to log thermal alarm acknowledgement: %v", err)
+		}
+	}
+	app.thermalAlarms = nil
+	if app.thermalAlert != nil {
+		app.thermalAlert.Hide()
+		app.thermalAlert = nil
+	}
+}
+
+// createThermalAlarmSettings is the settings card for thermal alarms
+func (app *IntegratedApp) createThermalAlarmSettings() fyne.CanvasObject {
+	settings := app.thermal.Settings()
+
+	type alarmChecks struct {
+		enabled *widget.Check
+		stop    *widget.Check
+	}
+	checks := make(map[ThermalAnomaly]alarmChecks, len(ThermalAnomalies))
+	alarmGrid := container.NewGridWithColumns(3)
+	for _, anomaly := range ThermalAnomalies {
+		action := settings.Alarms[anomaly]
+		c := alarmChecks{
+			enabled: widget.NewCheck("Alarm", nil),
+			stop:    widget.NewCheck("Emergency stop", nil),
+		}
+		c.enabled.SetChecked(action.Enabled)
+		c.stop.SetChecked(action.EmergencyStop)
+		checks[anomaly] = c
+		alarmGrid.Add(widget.NewLabel(anomaly.Label()))
+		alarmGrid.Add(c.enabled)
+		alarmGrid.Add(c.stop)
+	}
+
+	number := func(value float64) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetText(strconv.FormatFloat(value, 'f', -1, 64))
+		return entry
+	}
+	runawayMargin := number(settings.RunawayMargin)
+	runawaySeconds := number(float64(settings.RunawaySeconds))
+	toolMinutes := number(float64(settings.ToolHeatingSeconds) / 60)
+	bedMinutes := number(float64(settings.BedHeatingSeconds) / 60)
+	chamberMinutes := number(float64(settings.ChamberHeatingSeconds) / 60)
+	sensorMin := number(settings.SensorMin)
+	sensorMax := number(settings.SensorMax)
+	sensorJump := number(settings.SensorJump)
+	dropMargin := number(settings.DropMargin)
+	dropSeconds := number(float64(settings.DropSeconds))
+
+	form := widget.NewForm(
+		widget.NewFormItem("Runaway margin (°C)", runawayMargin),
+		widget.NewFormItem("Runaway after (s)", runawaySeconds),
+		widget.NewFormItem("Hotend heat-up limit (min)", toolMinutes),
+		widget.NewFormItem("Bed heat-up limit (min)", bedMinutes),
+		widget.NewFormItem("Chamber heat-up limit (min)", chamberMinutes),
+		widget.NewFormItem("Sensor minimum (°C)", sensorMin),
+		widget.NewFormItem("Sensor maximum (°C)", sensorMax),
+		widget.NewFormItem("Sensor jump (°C/s)", sensorJump),
+		widget.NewFormItem("Drop margin (°C)", dropMargin),
+		widget.NewFormItem("Drop after (s)", dropSeconds),
+	)
+
+	saveBtn := widget.NewButton("Save", func() {
+		updated := settings
+		updated.Alarms = make(map[ThermalAnomaly]ThermalAlarmAction, len(ThermalAnomalies))
+		for _, anomaly := range ThermalAnomalies {
+			updated.Alarms[anomaly] = ThermalAlarmAction{
+				Enabled:       checks[anomaly].enabled.Checked,
+				EmergencyStop: checks[anomaly].stop.Checked,
+			}
+		}
+
+		var invalid []string
+		parse := func(entry *widget.Entry, name string) float64 {
+			value, err := strconv.ParseFloat(strings.TrimSpace(entry.Text), 64)
+			if err != nil {
+				invalid = append(invalid, name)
+			}
+			return value
+		}
+		updated.RunawayMargin = parse(runawayMargin, "runaway margin")
+		updated.RunawaySeconds = int(parse(runawaySeconds, "runaway time"))
+		updated.ToolHeatingSeconds = int(parse(toolMinutes, "hotend heat-up limit") * 60)
+		updated.BedHeatingSeconds = int(parse(bedMinutes, "bed heat-up limit") * 60)
+		updated.ChamberHeatingSeconds = int(parse(chamberMinutes, "chamber heat-up limit") * 60)
+		updated.SensorMin = parse(sensorMin, "sensor minimum")
+		updated.SensorMax = parse(sensorMax, "sensor maximum")
+		updated.SensorJump = parse(sensorJump, "sensor jump")
+		updated.DropMargin = parse(dropMargin, "drop margin")
+		updated.DropSeconds = int(parse(dropSeconds, "drop time"))
+		if len(invalid) > 0 {
+			app.showError("Thermal Alarms", "Enter a number for the "+strings.Join(invalid, ", "))
+			return
+		}
+		if updated.SensorMin >= updated.SensorMax {
+			app.showError("Thermal Alarms", "The sensor minimum must be below the maximum")
+			return
+		}
+
+		if err := SaveThermalAlarmSettings(thermalAlarmFile(), updated); err != nil {
+			app.showError("Thermal Alarms", fmt.Sprintf("Failed to save settings: %v", err))
+			return
+		}
+		app.thermal.SetSettings(updated)
+		settings = updated
+		app.showInfo("Thermal Alarms", "Thermal alarm settings saved")
+	})
+
+	return widget.NewCard("Thermal Alarms", "", container.NewVBox(
+		alarmGrid,
+		form,
+		container.NewGridWithColumns(2,
+			saveBtn,
+			widget.NewButtonWithIcon("Thermal Events", theme.HistoryIcon(), func() {
+				app.showThermalEvents()
+			}),
+		),
+	))
+}
+
+// showThermalEvents lists the logged alarms and acknowledgements, newest
+// first
+func (app *IntegratedApp) showThermalEvents() {
+	events := app.thermalEvents.Events()
+
+	var content fyne.CanvasObject
+	if len(events) == 0 {
+		content = widget.NewLabel("No thermal alarms have been raised.")
+	} else {
+		list := widget.NewList(
+			func() int { return len(events) },
+			func() fyne.CanvasObject {
+				return container.NewVBox(
+					widget.NewLabelWithStyle("When", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+					widget.NewLabel("What"),
+				)
+			},
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				event := events[id]
+				box := obj.(*fyne.Container)
+				box.Objects[0].(*widget.Label).SetText(event.Time.Format("Jan 2 2006 15:04:05"))
+				box.Objects[1].(*widget.Label).SetText(event.Describe())
+			},
+		)
+		content = list
+	}
+
+	eventLog := dialog.NewCustom("Thermal Events", "Close", content, app.window)
+	eventLog.Resize(fyne.NewSize(640, 520))
+	eventLog.Show()
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultUSBMountRoot is where the system automounts removable media, as
+// /media/<label> or /media/<user>/<label>
+const defaultUSBMountRoot = "/media"
+
+// A new directory under the mount root is probed for a while, since
+// automounters create the mount point before mounting the medium
+const (
+	usbProbeInterval = 500 * time.Millisecond
+	usbProbeTimeout  = 10 * time.Second
+)
+
+// Limits for searching a stick, so a full backup drive does not stall the UI
+const (
+	usbScanDepth = 4
+	usbScanLimit = 500
+)
+
+// usbExportDir is the folder exports are written to on a stick
+const usbExportDir = "innovate-os"
+
+// usbThumbnailScanLimit is how far into a text file thumbnails are looked
+// for; slicers write them into the header
+const usbThumbnailScanLimit = 4 << 20
+
+// usbSkippedDirs are system folders that never hold prints
+var usbSkippedDirs = map[string]bool{
+	"system volume information": true,
+	"$recycle.bin":              true,
+	"lost+found":                true,
+	usbExportDir:                true,
+}
+
+// USBSettings configures removable media handling
+type USBSettings struct {
+	MountRoot string `json:"mount_root"`
+}
+
+// usbSettingsFile is where the touchscreen keeps the USB settings
+func usbSettingsFile() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "innovate-os", "usb.json")
+}
+
+// LoadUSBSettings reads the settings at path; a missing file gives the
+// defaults
+func LoadUSBSettings(path string) (USBSettings, error) {
+	settings := USBSettings{MountRoot: defaultUSBMountRoot}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("invalid USB settings file: %v", err)
+	}
+	if settings.MountRoot == "" {
+		settings.MountRoot = defaultUSBMountRoot
+	}
+	return settings, nil
+}
+
+// SaveUSBSettings writes the settings to path
+func SaveUSBSettings(path string, settings USBSettings) error {
+	jsonData, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, jsonData, 0600)
+}
+
+// USBWatcher reports removable media mounted under a root directory. Both
+// root/<label> and root/<user>/<label> are recognised. A directory counts
+// as a medium once it is a mount point or holds a file, so the watcher can
+// be pointed at a plain directory for testing.
+type USBWatcher struct {
+	root     string
+	interval time.Duration
+	timeout  time.Duration
+
+	mu        sync.Mutex
+	watcher   *fsnotify.Watcher
+	mounts    map[string]bool
+	probing   map[string]bool
+	onMount   func(mount string)
+	onUnmount func(mount string)
+	done      chan struct{}
+}
+
+// NewUSBWatcher creates a watcher for media mounted under root
+func NewUSBWatcher(root string) *USBWatcher {
+	return &USBWatcher{
+		root:     filepath.Clean(root),
+		interval: usbProbeInterval,
+		timeout:  usbProbeTimeout,
+		mounts:   make(map[string]bool),
+		probing:  make(map[string]bool),
+	}
+}
+
+// Root returns the watched mount root
+func (w *USBWatcher) Root() string {
+	return w.root
+}
+
+// SetOnMount sets the callback for a newly mounted medium
+func (w *USBWatcher) SetOnMount(callback func(mount string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onMount = callback
+}
+
+// SetOnUnmount sets the callback for a removed medium
+func (w *USBWatcher) SetOnUnmount(callback func(mount string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onUnmount = callback
+}
+
+// Start begins watching. Media already mounted are listed by Mounts but
+// not reported as new.
+func (w *USBWatcher) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watcher != nil {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(w.root); err != nil {
+		watcher.Close()
+		return fmt.Errorf("cannot watch %s: %v", w.root, err)
+	}
+
+	entries, _ := ioutil.ReadDir(w.root)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(w.root, entry.Name())
+		if isUSBMedium(dir) {
+			w.mounts[dir] = true
+			continue
+		}
+		// A per-user folder; its media appear one level down
+		watcher.Add(dir)
+		children, _ := ioutil.ReadDir(dir)
+		for _, child := range children {
+			if child.IsDir() && isUSBMedium(filepath.Join(dir, child.Name())) {
+				w.mounts[filepath.Join(dir, child.Name())] = true
+			}
+		}
+	}
+
+	w.watcher = watcher
+	w.done = make(chan struct{})
+	go w.run(watcher, w.done)
+	return nil
+}
+
+// Stop ends watching
+func (w *USBWatcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watcher == nil {
+		return
+	}
+	close(w.done)
+	w.watcher.Close()
+	w.watcher = nil
+}
+
+// Mounts returns the media currently mounted, sorted
+func (w *USBWatcher) Mounts() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	mounts := make([]string, 0, len(w.mounts))
+	for mount := range w.mounts {
+		mounts = append(mounts, mount)
+	}
+	sort.Strings(mounts)
+	return mounts
+}
+
+// isMount reports whether dir is a known medium
+func (w *USBWatcher) isMount(dir string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mounts[dir]
+}
+
+// run handles watcher events until stopped
+func (w *USBWatcher) run(watcher *fsnotify.Watcher, done chan struct{}) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			w.handle(watcher, event, done)
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// handle probes new directories and reports removed media
+func (w *USBWatcher) handle(watcher *fsnotify.Watcher, event fsnotify.Event, done chan struct{}) {
+	path := filepath.Clean(event.Name)
+	parent := filepath.Dir(path)
+
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		w.mu.Lock()
+		removed := w.mounts[path]
+		delete(w.mounts, path)
+		callback := w.onUnmount
+		w.mu.Unlock()
+		if removed && callback != nil {
+			callback(path)
+		}
+		return
+	}
+	if event.Op&fsnotify.Create == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	// Files written to a medium are not new media
+	if w.mounts[path] || w.mounts[parent] || w.probing[path] {
+		w.mu.Unlock()
+		return
+	}
+	w.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return
+	}
+	if parent == w.root {
+		// Could be a per-user folder created on first mount
+		watcher.Add(path)
+	} else if filepath.Dir(parent) != w.root {
+		return
+	}
+
+	w.mu.Lock()
+	w.probing[path] = true
+	w.mu.Unlock()
+	go w.probe(watcher, path, done)
+}
+
+// probe waits for a new directory to become a medium
+func (w *USBWatcher) probe(watcher *fsnotify.Watcher, dir string, done chan struct{}) {
+	defer func() {
+		w.mu.Lock()
+		delete(w.probing, dir)
+		w.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(w.timeout)
+	parent := filepath.Dir(dir)
+	for {
+		if isUSBMedium(dir) {
+			if parent == w.root {
+				watcher.Remove(dir)
+			} else if w.isMount(parent) || isUSBMedium(parent) {
+				// A folder on a medium rather than a medium in a
+				// per-user folder
+				return
+			}
+			w.mu.Lock()
+			w.mounts[dir] = true
+			callback := w.onMount
+			w.mu.Unlock()
+			if callback != nil {
+				callback(dir)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// isUSBMedium reports whether dir is a mount point or holds a visible file
+func isUSBMedium(dir string) bool {
+	if isMountPoint(dir) {
+		return true
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isMountPoint reports whether dir is listed in /proc/mounts
+func isMountPoint(dir string) bool {
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	// Spaces and tabs in mount points are written as octal escapes
+	unescape := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\134`, `\`)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && unescape.Replace(fields[1]) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// USBFile is a printable file found on a medium
+type USBFile struct {
+	Path    string
+	Name    string // Path on the medium, e.g. parts/bracket.gcode
+	Size    int64
+	ModTime time.Time
+	Project bool // A 3MF project rather than G-code
+}
+
+// errUSBScanLimit ends a scan that found usbScanLimit files
+var errUSBScanLimit = errors.New("too many files")
+
+// ScanUSBFiles lists the G-code and 3MF files on a medium, skipping hidden
+// and system folders
+func ScanUSBFiles(mount string) ([]USBFile, error) {
+	var files []USBFile
+	err := filepath.WalkDir(mount, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable folders are skipped, the medium itself is not
+			if path == mount {
+				return err
+			}
+			return nil
+		}
+		name := entry.Name()
+		rel, _ := filepath.Rel(mount, path)
+		if entry.IsDir() {
+			if path == mount {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || usbSkippedDirs[strings.ToLower(name)] ||
+				strings.Count(rel, string(filepath.Separator)) >= usbScanDepth-1 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || !entry.Type().IsRegular() {
+			return nil
+		}
+		project := strings.EqualFold(filepath.Ext(name), ".3mf")
+		if !project && !IsGCodeFileName(name) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, USBFile{
+			Path:    path,
+			Name:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Project: project,
+		})
+		if len(files) >= usbScanLimit {
+			return errUSBScanLimit
+		}
+		return nil
+	})
+	if err == errUSBScanLimit {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return strings.ToLower(files[i].Name) < strings.ToLower(files[j].Name)
+	})
+	return files, nil
+}
+
+// USBImportItem is something that can be imported from a medium: a G-code
+// file or one sliced plate of a 3MF project
+type USBImportItem struct {
+	File      USBFile
+	Plate     int    // 3MF plate index, 0 for G-code
+	Label     string // Shown in the import list
+	Thumbnail *GCodeThumbnail
+}
+
+// UploadName is the name the item is stored under on the printer
+func (item *USBImportItem) UploadName() string {
+	base := filepath.Base(item.File.Path)
+	if item.Plate > 0 {
+		return fmt.Sprintf("%s_plate_%d.gcode", strings.TrimSuffix(base, filepath.Ext(base)), item.Plate)
+	}
+	return base
+}
+
+// Open opens the item's G-code for uploading. The caller closes it; a
+// plate's project is closed along with it.
+func (item *USBImportItem) Open() (io.ReadCloser, error) {
+	if item.Plate == 0 {
+		return os.Open(item.File.Path)
+	}
+	project, err := OpenThreeMF(item.File.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer project.Close()
+	for i := range project.Plates {
+		if project.Plates[i].Index == item.Plate {
+			return project.OpenPlateGCode(&project.Plates[i])
+		}
+	}
+	return nil, fmt.Errorf("plate %d not found in %s", item.Plate, item.File.Name)
+}
+
+// USBImportItems turns scanned files into import items with thumbnails.
+// Each sliced plate of a project is an item of its own; projects without
+// sliced plates are left out.
+func USBImportItems(files []USBFile) []USBImportItem {
+	var items []USBImportItem
+	for _, file := range files {
+		if !file.Project {
+			items = append(items, USBImportItem{
+				File:      file,
+				Label:     file.Name,
+				Thumbnail: ReadGCodeThumbnail(file.Path),
+			})
+			continue
+		}
+
+		project, err := OpenThreeMF(file.Path)
+		if err != nil {
+			continue
+		}
+		for _, plate := range project.Plates {
+			if !plate.Sliced() {
+				continue
+			}
+			item := USBImportItem{
+				File:  file,
+				Plate: plate.Index,
+				Label: file.Name + " - " + plate.DisplayName(),
+			}
+			if plate.Thumbnail != nil {
+				item.Thumbnail = &GCodeThumbnail{Format: "PNG", Data: plate.Thumbnail}
+			}
+			items = append(items, item)
+		}
+		project.Close()
+	}
+	return items
+}
+
+// ReadGCodeThumbnail returns the largest displayable thumbnail of a G-code
+// file without parsing the whole file, or nil if it has none
+func ReadGCodeThumbnail(path string) *GCodeThumbnail {
+	reader, err := OpenGCodeFile(path)
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+
+	// bgcode thumbnails are read with the file header
+	model := &GCodeModel{Thumbnails: reader.Thumbnails}
+	if reader.Format == GCodeFormatText {
+		var collector textThumbnailCollector
+		scanner := bufio.NewScanner(io.LimitReader(reader, usbThumbnailScanLimit))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			// The header ends at the first command
+			if !strings.HasPrefix(line, ";") {
+				break
+			}
+			if thumbnail, _ := collector.add(strings.TrimSpace(strings.TrimPrefix(line, ";"))); thumbnail != nil {
+				model.Thumbnails = append(model.Thumbnails, *thumbnail)
+			}
+		}
+	}
+	return model.PreviewThumbnail()
+}
+
+// ExportToUSB writes files into a dated folder under innovate-os/ on a
+// medium and returns the folder. Each file is synced so the stick can be
+// pulled as soon as the export is reported done.
+func ExportToUSB(mount string, at time.Time, files map[string]func(io.Writer) error) (string, error) {
+	dir := filepath.Join(mount, usbExportDir, at.Format("2006-01-02_15-04-05"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeUSBFile(filepath.Join(dir, name), files[name]); err != nil {
+			return dir, fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir, nil
+}
+
+// writeUSBFile creates path and syncs it once write is done
+func writeUSBFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(file)
+	if err := write(buffered); err != nil {
+		file.Close()
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
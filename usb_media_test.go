@@ -0,0 +1,273 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// usbPreview is a 1x1 PNG used as a thumbnail
+var usbPreview, _ = base64.StdEncoding.DecodeString(
+	"iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==")
+
+// usbTextGCode is a text file with a thumbnail in its header
+var usbTextGCode = "; generated by PrusaSlicer\n" +
+	"; thumbnail begin 1x1\n" +
+	"; " + base64.StdEncoding.EncodeToString(usbPreview) + "\n" +
+	"; thumbnail end\n" +
+	"G28\nG1 X10 Y10 E1\n"
+
+// writeUSBTestFile creates a file and its folders under the mount
+func writeUSBTestFile(t *testing.T, mount, name string, data []byte) {
+	t.Helper()
+	path := filepath.Join(mount, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// usbTestProject zips entries into a 3MF project
+func usbTestProject(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, data := range entries {
+		entry, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write(data)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newUSBTestMount lays out a stick with prints, projects and folders that
+// are skipped
+func newUSBTestMount(t *testing.T) string {
+	t.Helper()
+	mount := filepath.Join(t.TempDir(), "media", "STICK")
+	bgcode, err := os.ReadFile("testdata/prusaslicer_sample.bgcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeUSBTestFile(t, mount, "Bracket.gcode", []byte(usbTextGCode))
+	writeUSBTestFile(t, mount, "parts/clip.bgcode", bgcode)
+	writeUSBTestFile(t, mount, "parts/plain.GCO", []byte("G28\n"))
+	writeUSBTestFile(t, mount, "a/b/c/level3.gcode", []byte("G28\n"))
+	writeUSBTestFile(t, mount, "a/b/c/d/too_deep.gcode", []byte("G28\n"))
+	writeUSBTestFile(t, mount, "box.3mf", usbTestProject(t, map[string][]byte{
+		"Metadata/plate_1.gcode": []byte("G28\nG1 X1 E1\n"),
+		"Metadata/plate_1.png":   usbPreview,
+		"Metadata/plate_2.png":   usbPreview,
+	}))
+	writeUSBTestFile(t, mount, "unsliced.3mf", usbTestProject(t, map[string][]byte{
+		"3D/3dmodel.model": []byte("<model/>"),
+	}))
+	writeUSBTestFile(t, mount, "notes.txt", []byte("not a print"))
+	writeUSBTestFile(t, mount, ".hidden.gcode", []byte("G28\n"))
+	writeUSBTestFile(t, mount, ".Trashes/deleted.gcode", []byte("G28\n"))
+	writeUSBTestFile(t, mount, "System Volume Information/index.gcode", []byte("G28\n"))
+	writeUSBTestFile(t, mount, "innovate-os/2026-01-01_09-00-00/export.gcode", []byte("G28\n"))
+	return mount
+}
+
+func TestUSBImportList(t *testing.T) {
+	mount := newUSBTestMount(t)
+
+	files, err := ScanUSBFiles(mount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+		if file.Path != filepath.Join(mount, filepath.FromSlash(file.Name)) {
+			t.Errorf("%s has path %s", file.Name, file.Path)
+		}
+	}
+	wantNames := []string{"a/b/c/level3.gcode", "box.3mf", "Bracket.gcode", "parts/clip.bgcode", "parts/plain.GCO", "unsliced.3mf"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("scanned %q, want %q", names, wantNames)
+	}
+
+	items := USBImportItems(files)
+	var labels, uploads []string
+	for _, item := range items {
+		labels = append(labels, item.Label)
+		uploads = append(uploads, item.UploadName())
+	}
+	wantLabels := []string{"a/b/c/level3.gcode", "box.3mf - Plate 1", "Bracket.gcode", "parts/clip.bgcode", "parts/plain.GCO"}
+	if !reflect.DeepEqual(labels, wantLabels) {
+		t.Errorf("import list %q, want %q", labels, wantLabels)
+	}
+	wantUploads := []string{"level3.gcode", "box_plate_1.gcode", "Bracket.gcode", "clip.bgcode", "plain.GCO"}
+	if !reflect.DeepEqual(uploads, wantUploads) {
+		t.Errorf("upload names %q, want %q", uploads, wantUploads)
+	}
+
+	for i, withThumbnail := range []bool{false, true, true, true, false} {
+		if got := items[i].Thumbnail != nil; got != withThumbnail {
+			t.Errorf("%s: thumbnail %v, want %v", items[i].Label, got, withThumbnail)
+		}
+	}
+	if thumbnail := items[2].Thumbnail; thumbnail != nil && !bytes.Equal(thumbnail.Data, usbPreview) {
+		t.Errorf("text G-code thumbnail was not decoded")
+	}
+
+	reader, err := items[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != "G28\nG1 X1 E1\n" {
+		t.Errorf("plate G-code = %q, %v", data, err)
+	}
+}
+
+func TestExportToUSB(t *testing.T) {
+	mount := newUSBTestMount(t)
+	at := time.Date(2026, 3, 14, 15, 9, 26, 0, time.Local)
+
+	dir, err := ExportToUSB(mount, at, map[string]func(io.Writer) error{
+		"print_history.csv": func(w io.Writer) error {
+			_, err := io.WriteString(w, "id,file\n1,Bracket.gcode\n")
+			return err
+		},
+		"bracket.recovery.gcode": func(w io.Writer) error {
+			_, err := io.WriteString(w, "G28\n")
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(mount, "innovate-os", "2026-03-14_15-09-26"); dir != want {
+		t.Errorf("exported to %s, want %s", dir, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "print_history.csv"))
+	if err != nil || string(data) != "id,file\n1,Bracket.gcode\n" {
+		t.Errorf("print_history.csv = %q, %v", data, err)
+	}
+
+	// Exports are not offered for import
+	files, err := ScanUSBFiles(mount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name, "innovate-os/") {
+			t.Errorf("export %s listed for import", file.Name)
+		}
+	}
+
+	failure := errors.New("disk full")
+	_, err = ExportToUSB(mount, at, map[string]func(io.Writer) error{
+		"report.txt": func(w io.Writer) error { return failure },
+	})
+	if err == nil || !strings.Contains(err.Error(), "report.txt") {
+		t.Errorf("failed export returned %v", err)
+	}
+}
+
+// waitForMedium returns the next medium a watcher reported
+func waitForMedium(t *testing.T, events chan string, what string) string {
+	t.Helper()
+	select {
+	case mount := <-events:
+		return mount
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s reported", what)
+		return ""
+	}
+}
+
+func TestUSBWatcher(t *testing.T) {
+	root := t.TempDir()
+	writeUSBTestFile(t, root, "OLD/old.gcode", []byte("G28\n"))
+	if err := os.Mkdir(filepath.Join(root, "pi"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewUSBWatcher(root)
+	watcher.interval = 10 * time.Millisecond
+	mounted, unmounted := make(chan string, 4), make(chan string, 4)
+	watcher.SetOnMount(func(mount string) { mounted <- mount })
+	watcher.SetOnUnmount(func(mount string) { unmounted <- mount })
+	if err := watcher.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Stop()
+
+	// Media mounted before the start are listed, not reported
+	if got, want := watcher.Mounts(), []string{filepath.Join(root, "OLD")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Mounts = %q, want %q", got, want)
+	}
+
+	// The automounter creates the mount point before the medium shows up
+	stick := filepath.Join(root, "STICK")
+	if err := os.Mkdir(stick, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	writeUSBTestFile(t, stick, "Bracket.gcode", []byte(usbTextGCode))
+	writeUSBTestFile(t, stick, "parts/clip.gcode", []byte("G28\n"))
+	writeUSBTestFile(t, stick, "notes.txt", []byte("not a print"))
+	if mount := waitForMedium(t, mounted, "mount"); mount != stick {
+		t.Fatalf("mounted %s, want %s", mount, stick)
+	}
+	files, err := ScanUSBFiles(stick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	if want := []string{"Bracket.gcode", "parts/clip.gcode"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("files on the stick = %q, want %q", names, want)
+	}
+
+	// A medium in a per-user folder
+	user := filepath.Join(root, "pi", "CARD")
+	if err := os.Mkdir(user, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeUSBTestFile(t, user, "part.bgcode", []byte("GCDE"))
+	if mount := waitForMedium(t, mounted, "per-user mount"); mount != user {
+		t.Fatalf("mounted %s, want %s", mount, user)
+	}
+
+	// Folders created on a medium are not media
+	writeUSBTestFile(t, stick, "exports/new.gcode", []byte("G28\n"))
+	select {
+	case mount := <-mounted:
+		t.Errorf("a folder on the stick was reported as medium %s", mount)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := os.RemoveAll(stick); err != nil {
+		t.Fatal(err)
+	}
+	if mount := waitForMedium(t, unmounted, "removal"); mount != stick {
+		t.Errorf("unmounted %s, want %s", mount, stick)
+	}
+	if got, want := watcher.Mounts(), []string{filepath.Join(root, "OLD"), user}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Mounts after removal = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// usbThumbnailSize is the preview size in the import list
+var usbThumbnailSize = fyne.NewSize(64, 48)
+
+// startUSBWatcher watches the configured mount root and offers an import
+// whenever a stick is plugged in. Calling it again restarts the watcher,
+// e.g. after the root was changed.
+func (app *IntegratedApp) startUSBWatcher() {
+	settings, err := LoadUSBSettings(usbSettingsFile())
+	if err != nil {
+		log.Printf("Failed to load USB settings: %v", err)
+	}
+	if app.usbWatcher != nil {
+		app.usbWatcher.Stop()
+	}
+
+	app.usbWatcher = NewUSBWatcher(settings.MountRoot)
+	app.usbWatcher.SetOnMount(func(mount string) {
+		app.appendLog("USB stick detected: " + filepath.Base(mount))
+		app.showUSBMedia(mount)
+	})
+	app.usbWatcher.SetOnUnmount(func(mount string) {
+		app.appendLog("USB stick removed: " + filepath.Base(mount))
+	})
+	if err := app.usbWatcher.Start(); err != nil {
+		log.Printf("Failed to watch for USB media: %v", err)
+	}
+}
+
+// openUSBMedia opens a stick that is already mounted, asking which one if
+// there are several
+func (app *IntegratedApp) openUSBMedia() {
+	if app.usbWatcher == nil {
+		app.showError("USB Stick", "USB media are not being watched")
+		return
+	}
+	mounts := app.usbWatcher.Mounts()
+	switch len(mounts) {
+	case 0:
+		app.showInfo("USB Stick", fmt.Sprintf("No USB stick found under %s", app.usbWatcher.Root()))
+	case 1:
+		app.showUSBMedia(mounts[0])
+	default:
+		labels := make([]string, len(mounts))
+		for i, mount := range mounts {
+			labels[i] = filepath.Base(mount)
+		}
+		mediaSelect := widget.NewSelect(labels, nil)
+		mediaSelect.SetSelectedIndex(0)
+		dialog.ShowForm("USB Stick", "Open", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("Stick", mediaSelect),
+		}, func(open bool) {
+			if open && mediaSelect.SelectedIndex() >= 0 {
+				app.showUSBMedia(mounts[mediaSelect.SelectedIndex()])
+			}
+		}, app.window)
+	}
+}
+
+// showUSBMedia reads a stick and shows what can be imported from it
+func (app *IntegratedApp) showUSBMedia(mount string) {
+	progressDialog := dialog.NewProgressInfinite("USB Stick", "Reading "+filepath.Base(mount)+"...", app.window)
+	progressDialog.Show()
+
+	go func() {
+		files, err := ScanUSBFiles(mount)
+		var items []USBImportItem
+		if err == nil {
+			items = USBImportItems(files)
+		}
+		progressDialog.Hide()
+
+		if err != nil {
+			app.showError("USB Stick", fmt.Sprintf("Failed to read %s: %v", filepath.Base(mount), err))
+			return
+		}
+		app.showUSBImportDialog(mount, items)
+	}()
+}
+
+// showUSBImportDialog lists the printable files on a stick with their
+// thumbnails; checked files are uploaded, and logs can be exported back
+func (app *IntegratedApp) showUSBImportDialog(mount string, items []USBImportItem) {
+	selected := make([]bool, len(items))
+	count := widget.NewLabel("")
+	updateCount := func() {
+		n := 0
+		for _, checked := range selected {
+			if checked {
+				n++
+			}
+		}
+		count.SetText(fmt.Sprintf("%d of %d selected", n, len(items)))
+	}
+	updateCount()
+
+	list := widget.NewList(
+		func() int {
+			return len(items)
+		},
+		func() fyne.CanvasObject {
+			image := canvas.NewImageFromResource(theme.FileIcon())
+			image.FillMode = canvas.ImageFillContain
+			image.SetMinSize(usbThumbnailSize)
+			return container.NewHBox(
+				widget.NewCheck("", nil),
+				image,
+				container.NewVBox(widget.NewLabel("File"), widget.NewLabel("Details")),
+			)
+		},
+		func(id widget.ListItemID, object fyne.CanvasObject) {
+			item := &items[id]
+			row := object.(*fyne.Container)
+
+			check := row.Objects[0].(*widget.Check)
+			check.OnChanged = nil
+			check.SetChecked(selected[id])
+			check.OnChanged = func(checked bool) {
+				selected[id] = checked
+				updateCount()
+			}
+
+			image := row.Objects[1].(*canvas.Image)
+			if item.Thumbnail != nil {
+				image.Resource = fyne.NewStaticResource(
+					fmt.Sprintf("usb_%d.%s", id, strings.ToLower(item.Thumbnail.Format)), item.Thumbnail.Data)
+			} else {
+				image.Resource = theme.FileIcon()
+			}
+			image.Refresh()
+
+			text := row.Objects[2].(*fyne.Container)
+			text.Objects[0].(*widget.Label).SetText(item.Label)
+			text.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%.1f MB, %s",
+				float64(item.File.Size)/(1024*1024), item.File.ModTime.Format("Jan 2 2006 15:04")))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		selected[id] = !selected[id]
+		list.Unselect(id)
+		list.RefreshItem(id)
+		updateCount()
+	}
+
+	setAll := func(checked bool) {
+		for i := range selected {
+			selected[i] = checked
+		}
+		list.Refresh()
+		updateCount()
+	}
+	selectAllBtn := widget.NewButton("Select All", func() { setAll(true) })
+	selectNoneBtn := widget.NewButton("Select None", func() { setAll(false) })
+
+	var usbDialog dialog.Dialog
+	importBtn := widget.NewButtonWithIcon("Import Selected", theme.DownloadIcon(), func() {
+		var chosen []USBImportItem
+		for i, checked := range selected {
+			if checked {
+				chosen = append(chosen, items[i])
+			}
+		}
+		if len(chosen) == 0 {
+			app.showError("USB Import", "Select the files to import")
+			return
+		}
+		usbDialog.Hide()
+		app.importUSBItems(chosen)
+	})
+	importBtn.Importance = widget.HighImportance
+	exportBtn := widget.NewButtonWithIcon("Export Logs", theme.UploadIcon(), func() {
+		app.exportToUSB(mount)
+	})
+
+	var center fyne.CanvasObject = list
+	if len(items) == 0 {
+		center = widget.NewLabel("No G-code or sliced 3MF files found on this stick.")
+		selectAllBtn.Disable()
+		selectNoneBtn.Disable()
+		importBtn.Disable()
+	}
+	content := container.NewBorder(
+		container.NewHBox(selectAllBtn, selectNoneBtn, count),
+		container.NewGridWithColumns(2, importBtn, exportBtn),
+		nil, nil,
+		center,
+	)
+
+	usbDialog = dialog.NewCustom("USB Stick: "+filepath.Base(mount), "Close", content, app.window)
+	usbDialog.Resize(fyne.NewSize(720, 520))
+	usbDialog.Show()
+}
+
+// importUSBItems uploads files from a stick one after another. Each file is
+// streamed from the stick, so large files are never held in memory.
+func (app *IntegratedApp) importUSBItems(items []USBImportItem) {
+	progressDialog := dialog.NewProgress("USB Import", fmt.Sprintf("Importing %d files...", len(items)), app.window)
+	progressDialog.Show()
+
+	go func() {
+		var imported, failed []string
+		for i, item := range items {
+			name, err := app.uploadUSBItem(item)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", item.Label, err))
+			} else {
+				imported = append(imported, name)
+			}
+			progressDialog.SetValue(float64(i+1) / float64(len(items)))
+		}
+		progressDialog.Hide()
+
+		app.refreshPrintJobs()
+		for _, name := range imported {
+			app.appendLog("Imported from USB: " + name)
+		}
+		if len(failed) > 0 {
+			app.showError("USB Import", fmt.Sprintf("Imported %d of %d files.\n\n%s",
+				len(imported), len(items), strings.Join(failed, "\n")))
+			return
+		}
+		app.showInfo("USB Import", fmt.Sprintf("Imported %d files:\n%s", len(imported), strings.Join(imported, "\n")))
+	}()
+}
+
+// uploadUSBItem streams one file from a stick to the printer and returns
+// the name it was stored under
+func (app *IntegratedApp) uploadUSBItem(item USBImportItem) (string, error) {
+	reader, err := item.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	return app.backend.UploadGCode(item.UploadName(), reader)
+}
+
+// exportToUSB writes the system log, the print history and the recorded
+// temperatures to a stick
+func (app *IntegratedApp) exportToUSB(mount string) {
+	progressDialog := dialog.NewProgressInfinite("USB Export", "Writing logs to "+filepath.Base(mount)+"...", app.window)
+	progressDialog.Show()
+
+	go func() {
+		files := make(map[string]func(io.Writer) error)
+		var warnings []string
+
+		if logs, err := app.backend.GetSystemLogs(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("System log not exported: %v", err))
+		} else {
+			files["system_log.txt"] = func(w io.Writer) error {
+				_, err := io.WriteString(w, strings.Join(logs, "\n")+"\n")
+				return err
+			}
+		}
+		if app.logEntry != nil {
+			text := app.logEntry.Text
+			files["touchscreen_log.txt"] = func(w io.Writer) error {
+				_, err := io.WriteString(w, text+"\n")
+				return err
+			}
+		}
+
+		history := NewPrintHistory(printHistoryFile())
+		if err := history.Load(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Print history not exported: %v", err))
+		} else {
+			entries := history.Entries(HistoryFilter{})
+			files["print_history.csv"] = func(w io.Writer) error {
+				return ExportHistoryCSV(w, entries)
+			}
+		}
+
+		if app.temperatureUI != nil && app.temperatureUI.GetChart() != nil {
+			data := app.temperatureUI.GetChart().DataPoints()
+			files["temperature.csv"] = func(w io.Writer) error {
+				return WriteTemperatureCSV(w, data)
+			}
+		}
+
+		dir, err := ExportToUSB(mount, time.Now(), files)
+		progressDialog.Hide()
+		if err != nil {
+			app.showError("USB Export", fmt.Sprintf("Export failed: %v", err))
+			return
+		}
+
+		rel, _ := filepath.Rel(mount, dir)
+		message := fmt.Sprintf("%d files written to %s on %s.", len(files), filepath.ToSlash(rel), filepath.Base(mount))
+		if len(warnings) > 0 {
+			message += "\n\n" + strings.Join(warnings, "\n")
+		}
+		app.appendLog("Exported logs to USB: " + dir)
+		app.showInfo("USB Export", message)
+	}()
+}
+
+// createUSBSettings is the settings card for removable media
+func (app *IntegratedApp) createUSBSettings() fyne.CanvasObject {
+	settings, err := LoadUSBSettings(usbSettingsFile())
+	if err != nil {
+		log.Printf("Failed to load USB settings: %v", err)
+	}
+
+	rootEntry := widget.NewEntry()
+	rootEntry.SetText(settings.MountRoot)
+	rootEntry.SetPlaceHolder(defaultUSBMountRoot)
+
+	saveBtn := widget.NewButton("Save", func() {
+		root := strings.TrimSpace(rootEntry.Text)
+		if root == "" {
+			root = defaultUSBMountRoot
+		}
+		if !filepath.IsAbs(root) {
+			app.showError("USB Media", "The mount root must be an absolute path")
+			return
+		}
+		if err := SaveUSBSettings(usbSettingsFile(), USBSettings{MountRoot: root}); err != nil {
+			app.showError("USB Media", fmt.Sprintf("Failed to save settings: %v", err))
+			return
+		}
+		app.startUSBWatcher()
+		app.showInfo("USB Media", "Watching "+root+" for USB sticks")
+	})
+
+	return widget.NewCard("USB Media", "", container.NewVBox(
+		widget.NewLabel("Mount Root (where sticks are mounted):"),
+		container.NewBorder(nil, nil, nil, saveBtn, rootEntry),
+		widget.NewButtonWithIcon("Open USB Stick", theme.StorageIcon(), func() {
+			app.openUSBMedia()
+		}),
+	))
+}